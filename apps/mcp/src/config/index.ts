@@ -4,6 +4,10 @@ const configSchema = z.object({
   nodeEnv: z.enum(['development', 'production', 'test']).default('development'),
   port: z.coerce.number().default(3001),
   goServiceUrl: z.string().default('http://localhost:8081'),
+  goServiceTlsCert: z.string().optional(),
+  goServiceTlsKey: z.string().optional(),
+  goServiceTlsCa: z.string().optional(),
+  goServiceRedact: z.enum(['true', 'false']).transform(val => val === 'true').default('true'),
   corsOrigins: z.string().transform(val => val.split(',')).default('http://localhost:3000,http://localhost:3001'),
   redisUrl: z.string().default('redis://localhost:6379'),
   logLevel: z.enum(['error', 'warn', 'info', 'debug']).default('info'),
@@ -21,6 +25,10 @@ function loadConfig(): Config {
     nodeEnv: process.env.NODE_ENV,
     port: process.env.PORT,
     goServiceUrl: process.env.GO_SERVICE_URL,
+    goServiceTlsCert: process.env.GO_SERVICE_TLS_CERT,
+    goServiceTlsKey: process.env.GO_SERVICE_TLS_KEY,
+    goServiceTlsCa: process.env.GO_SERVICE_TLS_CA,
+    goServiceRedact: process.env.GO_SERVICE_REDACT,
     corsOrigins: process.env.CORS_ORIGINS,
     redisUrl: process.env.REDIS_URL,
     logLevel: process.env.LOG_LEVEL,
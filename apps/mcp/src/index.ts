@@ -1,3 +1,6 @@
+import fs from 'fs';
+import https from 'https';
+import axios from 'axios';
 import express from 'express';
 import cors from 'cors';
 import helmet from 'helmet';
@@ -17,6 +20,21 @@ import { evidenceBuilder } from './core/evidence';
 // Initialize tracing
 initializeTracing();
 
+// Present a client certificate to the Go service when it requires mTLS
+if (config.goServiceTlsCert && config.goServiceTlsKey) {
+  axios.defaults.httpsAgent = new https.Agent({
+    cert: fs.readFileSync(config.goServiceTlsCert),
+    key: fs.readFileSync(config.goServiceTlsKey),
+    ca: config.goServiceTlsCa ? fs.readFileSync(config.goServiceTlsCa) : undefined,
+  });
+}
+
+// Responses from the Go service end up in LLM context, so request PII-redacted
+// data unless explicitly disabled
+if (config.goServiceRedact) {
+  axios.defaults.params = { ...axios.defaults.params, redact: 'true' };
+}
+
 const app: express.Application = express();
 
 // Security middleware
@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   accountIds: z.array(z.string()).optional(),
@@ -72,7 +73,10 @@ export const getHoldingsTool: MCPTool = {
         throw new Error(result.error || 'Failed to fetch holdings');
       }
 
-      let holdings: HoldingWithCalculations[] = result.data.holdings || [];
+      let holdings: HoldingWithCalculations[] = parseDecimals(
+        result.data.holdings || [],
+        ['quantity', 'institution_price', 'institution_value', 'cost_basis']
+      );
 
       // Filter by account IDs if specified
       if (args.accountIds && args.accountIds.length > 0) {
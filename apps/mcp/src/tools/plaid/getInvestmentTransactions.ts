@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   start: z.string().regex(/^\d{4}-\d{2}-\d{2}$/, 'Date must be in YYYY-MM-DD format'),
@@ -99,7 +100,7 @@ export const getInvestmentTransactionsTool: MCPTool = {
       }
 
       let transactions: InvestmentTransactionWithCalculations[] = 
-        result.data.investment_transactions || [];
+        parseDecimals(result.data.investment_transactions || [], ['quantity', 'amount', 'price', 'fees']);
 
       // Filter by account IDs if specified
       if (args.accountIds && args.accountIds.length > 0) {
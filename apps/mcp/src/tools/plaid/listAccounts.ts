@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   includeBalances: z.boolean().optional().default(true),
@@ -46,7 +47,10 @@ export const listAccountsTool: MCPTool = {
         throw new Error(result.error || 'Failed to fetch accounts');
       }
 
-      let accounts = result.data.accounts || [];
+      let accounts = parseDecimals<any>(
+        result.data.accounts || [],
+        ['balance_current', 'balance_available', 'balance_limit']
+      );
 
       // Filter by account types if specified
       if (args.accountTypes && args.accountTypes.length > 0) {
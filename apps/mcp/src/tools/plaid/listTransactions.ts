@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   start: z.string().regex(/^\d{4}-\d{2}-\d{2}$/, 'Date must be in YYYY-MM-DD format'),
@@ -12,13 +13,14 @@ const inputSchema = z.object({
   accountIds: z.array(z.string()).optional(),
   limit: z.number().min(1).max(1000).optional().default(100),
   includeAccountInfo: z.boolean().optional().default(true),
+  summary: z.boolean().optional().default(false),
 });
 
 type ListTransactionsInput = z.infer<typeof inputSchema>;
 
 export const listTransactionsTool: MCPTool = {
   name: 'list_transactions',
-  description: 'List financial transactions within a date range, with optional filtering by merchant, category, or account',
+  description: 'List financial transactions within a date range, with optional filtering by merchant, category, or account. Set summary to get totals and the top merchants and categories instead of every transaction',
   inputSchema,
   metadata: {
     category: 'banking',
@@ -69,6 +71,10 @@ export const listTransactionsTool: MCPTool = {
         url.searchParams.set('category', args.category);
       }
 
+      if (args.summary) {
+        url.searchParams.set('summary', 'true');
+      }
+
       const response = await axios.get(url.toString(), {
         method: 'GET',
         headers: {
@@ -83,7 +89,26 @@ export const listTransactionsTool: MCPTool = {
         throw new Error(result.error || 'Failed to fetch transactions');
       }
 
-      let transactions = result.data.transactions || [];
+      // Summaries are aggregated over the whole range by the Go service,
+      // so they carry no per-transaction evidence
+      if (args.summary) {
+        return {
+          data: result.data.summary,
+          meta: {
+            dateRange: { start: args.start, end: args.end },
+            filters: {
+              merchant: args.merchant,
+              category: args.category,
+            },
+            truncated: result.data.truncated,
+            source: 'go-ingestion-service',
+            timestamp: new Date().toISOString(),
+          },
+          evidence: [],
+        };
+      }
+
+      let transactions = parseDecimals<any>(result.data.transactions || [], ['amount']);
 
       // Filter by account IDs if specified
       if (args.accountIds && args.accountIds.length > 0) {
@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   window: z.enum(['7d', '30d', '90d', '1y']).optional(),
@@ -105,7 +106,7 @@ export const spendingSummaryTool: MCPTool = {
         throw new Error(result.error || 'Failed to fetch transactions');
       }
 
-      let transactions = result.data.transactions || [];
+      let transactions = parseDecimals<any>(result.data.transactions || [], ['amount']);
 
       // Filter transactions
       if (!args.includeIncome) {
@@ -3,6 +3,7 @@ import axios from 'axios';
 import { MCPTool } from '../../core/registry';
 import { config } from '../../config';
 import { logger } from '../../utils/logger';
+import { parseDecimals } from '../../utils/decimal';
 
 const inputSchema = z.object({
   symbols: z.array(z.string()).optional(),
@@ -69,7 +70,10 @@ export const getCryptoPositionsTool: MCPTool = {
         throw new Error(result.error || 'Failed to fetch crypto positions');
       }
 
-      let positions: CryptoPositionWithCalculations[] = result.data.positions || [];
+      let positions: CryptoPositionWithCalculations[] = parseDecimals(result.data.positions || [], [
+        'quantity', 'average_price', 'market_value', 'cost_basis', 'unrealized_pnl',
+        'last_price', 'price_change_24h', 'price_change_percent_24h',
+      ]);
 
       // Filter by symbols if specified
       if (args.symbols && args.symbols.length > 0) {
@@ -0,0 +1,16 @@
+/**
+ * The ingest service sends amounts, balances, prices and quantities as
+ * decimal strings so no precision is lost in transit. Tools that do
+ * arithmetic on them convert the fields they use back to numbers.
+ */
+export function parseDecimals<T>(records: any[], fields: string[]): T[] {
+  return records.map(record => {
+    const parsed = { ...record };
+    for (const field of fields) {
+      if (typeof parsed[field] === 'string') {
+        parsed[field] = Number(parsed[field]);
+      }
+    }
+    return parsed;
+  });
+}
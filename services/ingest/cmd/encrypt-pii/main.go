@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/finagent/ingest/internal/config"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+)
+
+// encrypt-pii encrypts PII columns in rows written before field-level
+// encryption was enabled. It is safe to re-run: already encrypted values
+// are skipped, including enc:v1 values from before envelope encryption,
+// which the re-encryption job moves to envelopes.
+func main() {
+	batchSize := flag.Int("batch", 500, "rows to encrypt per batch")
+	dryRun := flag.Bool("dry-run", false, "count rows needing encryption without writing")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL, cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	enc, err := encryption.Open(ctx, db, cfg.KeyManager)
+	if err != nil {
+		log.Fatalf("Failed to initialize encryption: %v", err)
+	}
+
+	total, err := encryptAccounts(ctx, db, enc, *batchSize, *dryRun)
+	if err != nil {
+		log.Fatalf("Failed to encrypt accounts: %v", err)
+	}
+
+	if *dryRun {
+		log.Printf("%d account rows need encryption", total)
+		return
+	}
+	log.Printf("Encrypted %d account rows", total)
+}
+
+// encryptAccounts encrypts account masks and official names in batches
+func encryptAccounts(ctx context.Context, db *database.Database, enc *encryption.Service, batchSize int, dryRun bool) (int, error) {
+	const pending = `
+		(mask IS NOT NULL AND mask NOT LIKE 'enc:v1:%' AND mask NOT LIKE 'enc:v2:%')
+		OR (official_name IS NOT NULL AND official_name NOT LIKE 'enc:v1:%' AND official_name NOT LIKE 'enc:v2:%')
+	`
+
+	if dryRun {
+		var count int
+		err := db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM accounts WHERE "+pending).Scan(&count)
+		return count, err
+	}
+
+	total := 0
+	for {
+		rows, err := db.Pool.Query(ctx, "SELECT id, mask, official_name FROM accounts WHERE "+pending+" LIMIT $1", batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		type account struct {
+			id           string
+			mask         *string
+			officialName *string
+		}
+		var batch []account
+		for rows.Next() {
+			var a account
+			if err := rows.Scan(&a.id, &a.mask, &a.officialName); err != nil {
+				rows.Close()
+				return total, err
+			}
+			batch = append(batch, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		for _, a := range batch {
+			mask, err := enc.EncryptStringPtr(ctx, a.mask)
+			if err != nil {
+				return total, err
+			}
+			officialName, err := enc.EncryptStringPtr(ctx, a.officialName)
+			if err != nil {
+				return total, err
+			}
+
+			_, err = db.Pool.Exec(ctx,
+				"UPDATE accounts SET mask = $2, official_name = $3 WHERE id = $1",
+				a.id, mask, officialName)
+			if err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		log.Printf("Encrypted %d account rows so far", total)
+	}
+}
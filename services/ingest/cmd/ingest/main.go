@@ -2,23 +2,80 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/finagent/ingest/internal/alerts"
+	"github.com/finagent/ingest/internal/apikeys"
+	"github.com/finagent/ingest/internal/attachments"
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/cache"
+	"github.com/finagent/ingest/internal/capture"
+	"github.com/finagent/ingest/internal/categories"
 	"github.com/finagent/ingest/internal/config"
+	"github.com/finagent/ingest/internal/contributions"
+	"github.com/finagent/ingest/internal/corporateactions"
+	"github.com/finagent/ingest/internal/costbasis"
+	"github.com/finagent/ingest/internal/cpi"
+	"github.com/finagent/ingest/internal/cryptoincome"
 	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/dedup"
+	"github.com/finagent/ingest/internal/dependents"
+	"github.com/finagent/ingest/internal/devenv"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/equity"
+	"github.com/finagent/ingest/internal/expenses"
+	"github.com/finagent/ingest/internal/exports"
+	"github.com/finagent/ingest/internal/faultinjection"
+	"github.com/finagent/ingest/internal/giving"
 	"github.com/finagent/ingest/internal/handlers"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/logging"
+	"github.com/finagent/ingest/internal/marketdata"
+	"github.com/finagent/ingest/internal/metrics"
+	"github.com/finagent/ingest/internal/middleware"
+	"github.com/finagent/ingest/internal/mtls"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/finagent/ingest/internal/paychecks"
 	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/prices"
+	"github.com/finagent/ingest/internal/privacy"
+	"github.com/finagent/ingest/internal/ratelimit"
+	"github.com/finagent/ingest/internal/recommendations"
+	"github.com/finagent/ingest/internal/rentals"
+	"github.com/finagent/ingest/internal/replay"
+	"github.com/finagent/ingest/internal/reports"
+	"github.com/finagent/ingest/internal/retention"
 	"github.com/finagent/ingest/internal/robinhood"
+	"github.com/finagent/ingest/internal/roundups"
+	"github.com/finagent/ingest/internal/security"
+	"github.com/finagent/ingest/internal/sessions"
+	"github.com/finagent/ingest/internal/snapshot"
+	"github.com/finagent/ingest/internal/statements"
 	"github.com/finagent/ingest/internal/tracing"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/finagent/ingest/internal/valuation"
+	"github.com/finagent/ingest/internal/watchlist"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/finagent/ingest/internal/yield"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
@@ -29,94 +86,862 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	// `ingest mcp` speaks MCP on stdout, so logs go to stderr
+	mcpMode := len(os.Args) > 1 && os.Args[1] == "mcp"
+	if mcpMode {
+		cfg.Logging.Output = os.Stderr
+	}
+	logging.Setup(cfg.Logging)
+
+	// With EMBEDDED_STORES the database and Redis run in-process and the
+	// schema is kept migrated
+	if cfg.Embedded.Enabled {
+		env, err := devenv.Start(cfg.Embedded)
+		if err != nil {
+			log.Fatalf("Failed to start embedded stores: %v", err)
+		}
+		defer env.Stop()
+		cfg.DatabaseURL = env.DatabaseURL
+		cfg.DatabaseReplicaURL = ""
+		cfg.RedisURL = env.RedisURL
+		cfg.MigrateOnStart = "up"
+		slog.Info("Using embedded stores", "postgres_port", cfg.Embedded.PostgresPort, "redis_url", env.RedisURL)
+	}
+
+	// `ingest migrate ...` manages the schema and exits; otherwise the schema
+	// is checked or migrated according to DB_MIGRATE_ON_START
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+	checkMigrations(cfg)
 
 	// Initialize tracing
-	tracerProvider, err := tracing.InitTracer(cfg.ServiceName, cfg.JaegerEndpoint)
+	tracerProvider, err := tracing.InitTracer(ctx, cfg.ServiceName, cfg.Tracing)
 	if err != nil {
-		log.Printf("Failed to initialize tracing: %v", err)
+		slog.Error("Failed to initialize tracing", "error", err)
 	}
 	if tracerProvider != nil {
     	defer tracerProvider.Shutdown(ctx)
 	}
 
+	// Background workers run until shutdown cancels this context
+	background, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
 	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL)
+	db, err := database.Connect(cfg.DatabaseURL, cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
+	if cfg.DatabaseReplicaURL != "" {
+		if err := db.AttachReplica(cfg.DatabaseReplicaURL, cfg.ReplicaCheckInterval); err != nil {
+			log.Fatalf("Failed to connect to read replica: %v", err)
+		}
+	}
+	metrics.RegisterDatabase(db)
+
 	// Initialize Redis
 	redisClient := database.ConnectRedis(cfg.RedisURL)
 	defer redisClient.Close()
 
+	// Initialize envelope encryption for tokens and PII at rest
+	enc, err := encryption.Open(ctx, db, cfg.KeyManager)
+	if err != nil {
+		log.Fatalf("Failed to initialize encryption: %v", err)
+	}
+
 	// Initialize Plaid client
-	plaidClient := plaid.NewClient(cfg.PlaidClientID, cfg.PlaidSecret, cfg.PlaidEnvironment)
+	plaidClient := plaid.NewClient(cfg.PlaidClientID, cfg.PlaidSecret, cfg.PlaidEnvironment, enc, cfg.PlaidBreaker, cfg.PlaidRetry)
+
+	// Apply rotated secrets without a restart
+	if cfg.Secrets != nil {
+		watchSecrets(background, cfg, db, plaidClient, enc)
+	}
 
 	// Initialize Robinhood client
-	rhClient := robinhood.NewClient(cfg.RobinhoodUsername, cfg.RobinhoodPassword)
+	rhClient := robinhood.NewClient(cfg.RobinhoodUsername, cfg.RobinhoodPassword, cfg.RobinhoodBreaker, cfg.RobinhoodRetry)
+
+	// Initialize outbound webhook dispatcher
+	dispatcher := webhooks.NewDispatcher(db)
+
+	// Initialize background job manager
+	jobManager := jobs.NewManager(db, cfg.Jobs)
+
+	// Initialize service API key store
+	keyStore := apikeys.NewStore(db)
+
+	// Initialize audit log and data export/deletion service
+	auditLog := audit.NewLogger(db)
+	privacySvc := privacy.NewService(db, enc, auditLog, cfg.UserDeletionGrace)
+	go privacySvc.RunPurger(background, time.Hour)
+
+	// Initialize transaction attachments, whose files are removed before a
+	// deleted user's rows
+	var attachmentSvc *attachments.Service
+	attachmentStore, err := attachments.NewObjectStore(ctx, cfg.Attachments)
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+	if attachmentStore != nil {
+		attachmentSvc = attachments.NewService(db, attachmentStore, cfg.Attachments)
+		privacySvc.OnPurge(attachmentSvc.DeleteUser)
+	}
+
+	// Initialize session tracking
+	sessionStore := sessions.NewStore(db, redisClient)
+
+	// Initialize rate limiting
+	var limiter *ratelimit.Limiter
+	switch cfg.RateLimitBackend {
+	case "redis":
+		limiter = ratelimit.New(redisClient)
+	case "memory":
+		limiter = ratelimit.NewMemory()
+	default:
+		log.Fatalf("Invalid RATE_LIMIT_BACKEND %q; use redis or memory", cfg.RateLimitBackend)
+	}
+
+	// Initialize insights and anomalous access detection
+	insightStore := insights.NewStore(db, dispatcher)
+	detector := security.NewDetector(db, redisClient, limiter, insightStore, auditLog, cfg.Security)
+
+	// Initialize notifications and the alert rules that raise them
+	notificationStore := notifications.NewStore(db, dispatcher, enc)
+	alertEngine := alerts.NewEngine(db, notificationStore, insightStore)
+	recommendationEngine := recommendations.NewEngine(db)
+
+	// Initialize the read cache for accounts, holdings and positions
+	var readCache *cache.Cache
+	if cfg.ReadCacheTTL > 0 {
+		readCache = cache.New(redisClient, cfg.ReadCacheTTL)
+	}
+
+	// Initialize Redis locks, shared by item syncs and maintenance
+	locker := locks.New(redisClient, cfg.SyncLockTTL)
+
+	// Initialize retention policies and their maintenance worker
+	retentionSvc := retention.NewService(db, locker, cfg.Retention)
+	if cfg.Retention.Interval > 0 {
+		go retentionSvc.Run(background)
+	}
+
+	// Initialize digest emails; the scheduler queues a job per due digest
+	// and only runs when an SMTP server is configured
+	digestSvc := digest.NewService(db, locker, jobManager, cfg.Digest)
+	if digestSvc.Enabled() && cfg.Digest.Interval > 0 {
+		go digestSvc.Run(background)
+	}
+
+	// Initialize watchlists; crypto is quoted by Robinhood and stocks at
+	// the latest price Plaid synced for a holding of them
+	watchlistSvc := watchlist.NewService(db, locker, prices.Router{
+		prices.AssetStock:  prices.NewSecurityProvider(db),
+		prices.AssetCrypto: prices.ProviderFunc(rhClient.GetMarketPrice),
+	}, notificationStore, cfg.Watchlist)
+	if cfg.Watchlist.Interval > 0 {
+		go watchlistSvc.Run(background)
+	}
+
+	// Initialize crypto income; staking rewards and interest are synced
+	// from the exchanges users trade on, valued at Robinhood's price when
+	// an exchange gives none
+	cryptoIncomeSvc := cryptoincome.NewService(db, map[string]cryptoincome.Connector{
+		cryptoincome.SourceRobinhood: rhClient,
+	}, prices.ProviderFunc(rhClient.GetMarketPrice))
+
+	// Initialize security classification; the scheduler queues a job
+	// when securities are due for enrichment
+	marketDataSvc := marketdata.NewService(db, locker, jobManager, marketdata.NewClient(), cfg.MarketData)
+	if cfg.MarketData.Interval > 0 {
+		go marketDataSvc.Run(background)
+	}
+
+	// Initialize end-of-day holdings valuations; the scheduler queues a
+	// job for each trading day once the market has closed
+	valuationSvc := valuation.NewService(db, locker, jobManager, cfg.Valuation)
+	if cfg.Valuation.Interval > 0 {
+		go valuationSvc.Run(background)
+	}
+
+	// Initialize stock split and symbol change handling; actions are
+	// recorded through the admin API and applied on their ex-date
+	corporateActionSvc := corporateactions.NewService(db, locker, nil, cfg.CorporateActions)
+	if cfg.CorporateActions.Interval > 0 {
+		go corporateActionSvc.Run(background)
+	}
+
+	// Initialize Plaid statement downloads, kept in the attachment storage
+	var statementSvc *statements.Service
+	if attachmentStore != nil {
+		statementSvc = statements.NewService(db, locker, plaidClient, attachmentStore, cfg.Statements)
+		privacySvc.OnPurge(statementSvc.DeleteUser)
+		if cfg.Statements.Interval > 0 {
+			go statementSvc.Run(background)
+		}
+	}
+
+	// Initialize the Consumer Price Index, fetched from the BLS once a
+	// month is published, for spending comparisons in current dollars
+	cpiSvc := cpi.NewService(db, locker, cpi.NewBLS(cfg.CPI.URL, cfg.CPI.APIKey), cfg.CPI)
+	if cfg.CPI.Interval > 0 {
+		go cpiSvc.Run(background)
+	}
+
+	// Initialize daily exports to users' own buckets
+	exportSvc := exports.NewService(db, locker, enc, cfg.Exports)
+	if cfg.Exports.Interval > 0 {
+		go exportSvc.Run(background)
+	}
+
+	// Initialize usage metering; counts are flushed to Postgres in the
+	// background and once more at shutdown
+	meter := usage.NewMeter(db, redisClient, cfg.Usage)
+	if cfg.Usage.FlushInterval > 0 {
+		go meter.Run(background)
+	}
+
+	// Sampled payload capture for debugging, nil unless enabled
+	recorder := capture.New(cfg.Capture)
+	if recorder != nil {
+		slog.Warn("Capturing sampled request and response payloads for debugging", "sample_rate", cfg.Capture.SampleRate)
+	}
+
+	// Failure injection for resilience testing, nil unless enabled
+	faults := faultinjection.New(cfg.Faults)
+	if faults != nil {
+		if cfg.PlaidEnvironment == "production" {
+			log.Fatalf("Fault injection must not be enabled in production")
+		}
+		slog.Warn("Injecting faults for resilience testing",
+			"http_error_rate", cfg.Faults.HTTPErrorRate,
+			"upstream_error_rate", cfg.Faults.UpstreamErrorRate,
+			"webhook_drop_rate", cfg.Faults.WebhookDropRate)
+		plaidClient.SetFaults(faults)
+		rhClient.SetFaults(faults)
+	}
 
 	// Initialize handlers
-	h := handlers.New(db, redisClient, plaidClient, rhClient)
+	h := handlers.New(handlers.Deps{
+		DB:               db,
+		Cache:            readCache,
+		Redis:            redisClient,
+		Plaid:            plaidClient,
+		Robinhood:        rhClient,
+		Webhooks:         dispatcher,
+		Jobs:             jobManager,
+		Locks:            locker,
+		APIKeys:          keyStore,
+		Encryption:       enc,
+		Audit:            auditLog,
+		Privacy:          privacySvc,
+		Sessions:         sessionStore,
+		Insights:         insightStore,
+		Notify:           notificationStore,
+		Alerts:           alertEngine,
+		Recommend:        recommendationEngine,
+		Watchlist:        watchlistSvc,
+		Digests:          digestSvc,
+		MarketData:       marketDataSvc,
+		Valuations:       valuationSvc,
+		CorporateActions: corporateActionSvc,
+		Attachments:      attachmentSvc,
+		Statements:       statementSvc,
+		Security:         detector,
+		Retention:        retentionSvc,
+		Limiter:          limiter,
+		Dedup:            dedup.NewEngine(db, enc),
+		Expenses:         expenses.NewService(db),
+		RoundUps:         roundups.NewService(db),
+		CPI:              cpiSvc,
+		Reports:          reports.NewService(db),
+		Exports:          exportSvc,
+		Categories:       categories.NewService(db, jobManager),
+		Contributions:    contributions.NewService(db),
+		Equity:           equity.NewService(db, prices.NewSecurityProvider(db)),
+		CryptoIncome:     cryptoIncomeSvc,
+		CostBasis:        costbasis.NewService(db),
+		Yield:            yield.NewService(db, insightStore, cfg.BenchmarkAPY),
+		Paychecks:        paychecks.NewService(db),
+		Rentals:          rentals.NewService(db),
+		Giving:           giving.NewService(db),
+		Dependents:       dependents.NewService(db),
+		Snapshots:        snapshot.NewService(db, enc),
+		Usage:            meter,
+		Capture:          recorder,
+		Faults:           faults,
+
+		ConfigChecksum:  cfg.Checksum(),
+		WebhookLagSLO:   cfg.PlaidWebhookLagSLO,
+		PlaidProcessors: cfg.PlaidProcessors,
+	})
+
+	// `ingest mcp` serves the MCP tools over stdio for a local agent host
+	// and exits. It runs no job workers; jobs it queues, such as dry-run
+	// fills, are run by the service's.
+	if mcpMode {
+		runMCP(background, cfg, h, os.Args[2:])
+		return
+	}
+
+	// Start the job queue workers once every job type is registered
+	jobManager.Start(background)
+
+	// Initialize JWT verification
+	verifier, err := auth.NewVerifier(auth.Options{
+		HMACSecret: cfg.JWTSecret,
+		JWKSURL:    cfg.JWTJWKSURL,
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		UserClaim:  cfg.JWTUserClaim,
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	// Without a verifier, requests without an API key would be served
+	// unauthenticated and trusted to name their own user
+	if verifier == nil {
+		if !cfg.AuthDisabled {
+			log.Fatalf("JWT_SECRET or JWT_JWKS_URL is required; set AUTH_DISABLED=true only for local development")
+		}
+		if cfg.PlaidEnvironment == "production" {
+			log.Fatalf("Authentication must not be disabled in production")
+		}
+		slog.Warn("JWT authentication is disabled by AUTH_DISABLED; requests without an API key are unauthenticated")
+	}
+	authn := middleware.Authenticate(verifier, keyStore, h.ResolveUserID)
+
+	// Layered rate limits: per IP for unauthenticated routes, per principal
+	// for everything behind authenticate, and stricter tiers on sensitive
+	// endpoints
+	ipLimit := middleware.RateLimit(limiter, "ip", ratelimit.PerMinute(cfg.RateLimitIP), middleware.ByIP)
+	userLimit := middleware.RateLimit(limiter, "user", ratelimit.PerMinute(cfg.RateLimitUser), middleware.ByPrincipal)
+	ordersLimit := middleware.RateLimit(limiter, "orders", ratelimit.PerMinute(cfg.RateLimitOrders), middleware.ByPrincipal)
+	transfersLimit := middleware.RateLimit(limiter, "transfers", ratelimit.PerMinute(cfg.RateLimitOrders), middleware.ByPrincipal)
+	exchangeLimit := middleware.RateLimit(limiter, "exchange", ratelimit.PerMinute(cfg.RateLimitExchange), middleware.ByPrincipal)
+
+	// With mutual TLS enabled, internal callers must present an allowed
+	// client certificate in addition to their API key or admin token
+	mtlsEnabled := cfg.TLSCertFile != "" && cfg.TLSClientCAFile != ""
+	if mtlsEnabled && len(cfg.TLSAllowedSANs) == 0 {
+		log.Fatalf("TLS_ALLOWED_CLIENT_SANS is required when TLS_CLIENT_CA_FILE is set")
+	}
+	serviceCert := func(next http.Handler) http.Handler { return next }
+	adminCert := serviceCert
+	if mtlsEnabled {
+		serviceCert = middleware.RequireServiceClientCert(cfg.TLSAllowedSANs)
+		adminCert = middleware.RequireClientCert(cfg.TLSAllowedSANs)
+	}
+
+	// Everything behind authenticate: IPs with repeated auth failures are
+	// blocked, user sessions and IPs are tracked for revocation and anomaly
+	// detection, browser sessions authenticated by cookie must also pass the
+	// CSRF check, and responses are PII-redacted on request or by key policy
+	trackSessions := middleware.TrackSessions(sessionStore)
+	blockFailedAuth := middleware.BlockFailedAuth(detector)
+	observeIP := middleware.ObserveClientIP(detector)
+	authenticate := chi.Chain(blockFailedAuth, authn, logging.TagUser, trackSessions, observeIP, serviceCert, middleware.CSRF, userLimit, middleware.Redact).Handler
+
+	// Client IPs key rate limits and anomaly detection, so forwarding
+	// headers are only believed from our own proxies
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Setup routes
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(chimiddleware.RequestID)
+	r.Use(tracing.Middleware(cfg.ServiceName))
+	r.Use(metrics.Middleware)
+	r.Use(middleware.RealIP(trustedProxies))
+	r.Use(logging.AccessLog(cfg.Logging))
+	r.Use(chimiddleware.Recoverer)
+	r.Use(chimiddleware.Timeout(60 * time.Second))
+	// Attachment uploads may exceed the JSON body limit
+	var maxUploadBytes int64
+	if attachmentSvc != nil {
+		maxUploadBytes = attachmentSvc.MaxBytes() + handlers.MultipartOverhead
+	}
+	r.Use(middleware.LimitBody(int64(cfg.MaxBodyBytes), maxUploadBytes, cfg.MaxJSONDepth))
+	r.Use(middleware.Compress(cfg.CompressionLevel, cfg.CompressionMinBytes))
+	r.Use(recorder.Middleware)
+	r.Use(faults.Middleware)
+	r.Use(middleware.CacheBypass)
 
 	// CORS configuration
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:3001"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Cache-Control", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	// Health check
-	r.Get("/healthz", h.HealthCheck)
+	r.With(ipLimit).Get("/healthz", h.HealthCheck)
+	r.With(ipLimit).Get("/readyz", h.ReadinessCheck)
+
+	// CSRF token for the SPA's cookie-authenticated requests
+	r.With(ipLimit).Get("/csrf-token", middleware.CSRFToken(cfg.CookieSecure))
 
 	// Plaid endpoints
 	r.Route("/plaid", func(r chi.Router) {
-		r.Post("/webhook", h.PlaidWebhook)
-		r.Post("/exchange-public", h.ExchangePublicToken)
-		r.Post("/sync", h.ManualSync)
-		r.Post("/link-token", h.CreateLinkToken)
+		// Called by Plaid, not by users; deliveries must carry a
+		// Plaid-Verification token signed by Plaid, and replays are rejected
+		webhookReplay := middleware.PlaidWebhookReplay(replay.New(redisClient, cfg.PlaidWebhookReplayWindow), plaid.NewWebhookVerifier(plaidClient))
+		r.With(ipLimit, webhookReplay).Post("/webhook", h.PlaidWebhook)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.With(exchangeLimit).Post("/exchange-public", h.ExchangePublicToken)
+			r.Post("/sync", h.ManualSync)
+			r.Post("/link-token", h.CreateLinkToken)
+		})
+
+		// Processor tokens hand one account to a money movement partner
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			r.Use(middleware.RequireScope(auth.ScopePayments))
+			r.Post("/processor-token", h.CreateProcessorToken)
+		})
+
+		// The products each item is synced for and when consent expires
+		r.Group(func(r chi.Router) {
+			r.Use(authenticate)
+			r.With(middleware.RequireScope(auth.ScopeRead)).Get("/items/consent", h.ListItemConsents)
+			r.With(middleware.RequireScope(auth.ScopeRead)).Get("/items/{id}/consent", h.GetItemConsent)
+			r.With(middleware.RequireScope(auth.ScopePrivacy)).Patch("/items/{id}/consent", h.UpdateItemConsent)
+		})
 	})
 
 	// Read endpoints for MCP server
 	r.Route("/read", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Use(middleware.RequireScope(auth.ScopeRead))
+		r.Use(middleware.PreferReplica)
 		r.Get("/accounts", h.GetAccounts)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Patch("/accounts/{id}", h.UpdateAccount)
+		r.Get("/accounts/{id}/statement-summary", h.GetStatementSummary)
+		r.Get("/accounts/yield", h.GetAccountYield)
+		r.Get("/recommendations", h.ListRecommendations)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Patch("/recommendations/{id}", h.DecideRecommendation)
 		r.Get("/transactions", h.GetTransactions)
+		r.Get("/transactions/geo", h.GetTransactionsGeo)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/transactions/expense-type", h.SetExpenseType)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/transactions/property", h.TagRentalTransactions)
+		r.Get("/transactions/{id}/attachments", h.ListAttachments)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/transactions/{id}/attachments", h.UploadAttachment)
+		r.Get("/transactions/{id}/attachments/{attachmentID}", h.GetAttachment)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Delete("/transactions/{id}/attachments/{attachmentID}", h.DeleteAttachment)
 		r.Get("/holdings", h.GetHoldings)
+		r.Get("/holdings/history", h.GetHoldingsHistory)
+		r.Get("/holdings/cost-basis", h.GetCostBasisReconciliation)
 		r.Get("/investment-transactions", h.GetInvestmentTransactions)
+		r.Get("/insights", h.GetInsights)
+		r.Get("/fees", h.GetFees)
+		r.Get("/contribution-limits", h.GetContributionLimits)
+		r.Get("/paychecks", h.GetPaychecks)
+		r.Get("/equity/vests", h.GetUpcomingVests)
+		r.Post("/mortgage/simulate", h.SimulateMortgage)
+		r.Get("/networth/projection", h.GetNetWorthProjection)
+		r.Get("/expense-report", h.GetExpenseReport)
+		r.Get("/properties/pnl", h.GetRentalPnL)
+		r.Get("/giving", h.GetGiving)
+		r.Get("/metrics/savings-rate", h.GetSavingsRate)
+		r.Get("/spending/comparison", h.GetSpendingComparison)
+		r.Get("/roundups", h.GetRoundUpLedger)
+		r.Get("/roundups/goals", h.ListRoundUpGoals)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/roundups/goals", h.CreateRoundUpGoal)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Delete("/roundups/goals/{id}", h.DeleteRoundUpGoal)
+		r.Get("/statements", h.GetStatements)
+		r.Get("/changes", h.GetChanges)
+		r.Get("/history/{table}/{id}", h.GetRecordHistory)
+		r.Get("/resolve-period", h.ResolvePeriod)
 	})
 
 	// Robinhood endpoints
 	r.Route("/rh", func(r chi.Router) {
-		r.Get("/positions", h.GetCryptoPositions)
-		r.Post("/orders", h.PlaceCryptoOrder)
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/positions", h.GetCryptoPositions)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/income", h.GetCryptoIncome)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/income/sync", h.SyncCryptoIncome)
+		r.With(middleware.RequireScope(auth.ScopeTrade), ordersLimit).Post("/orders", h.PlaceCryptoOrder)
+	})
+
+	// ACH transfers through Plaid Transfer, dry runs unless asked otherwise
+	r.Route("/transfers", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListTransfers)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/{id}", h.GetTransfer)
+		r.With(middleware.RequireScope(auth.ScopePayments), transfersLimit).Post("/", h.CreateTransfer)
+		r.With(middleware.RequireScope(auth.ScopePayments), transfersLimit).Post("/{id}/confirm", h.ConfirmTransfer)
 	})
 
-	// Metrics endpoint
-	r.Get("/metrics", h.GetMetrics)
+	// Long-running jobs
+	r.With(authenticate, middleware.RequireScope(auth.ScopeRead)).Get("/jobs/{id}", h.GetJob)
+
+	// Usage and quotas
+	r.With(authenticate, middleware.RequireScope(auth.ScopeRead)).Get("/usage", h.GetUsage)
+
+	// MCP tools over streamable HTTP, acting for the authenticated caller
+	r.With(authenticate, middleware.RequireScope(auth.ScopeRead)).Handle("/mcp", h.MCPServer())
+
+	// Delegated access grants
+	r.Route("/grants", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Use(middleware.RequireScope(auth.ScopeGrants))
+		r.Post("/", h.CreateGrant)
+		r.Get("/", h.ListGrants)
+		r.Delete("/{id}", h.RevokeGrant)
+	})
+
+	// Alert rules evaluated during sync, and the notifications they raise
+	r.Route("/alerts/rules", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListAlertRules)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/{id}", h.GetAlertRule)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateAlertRule)
+			r.Patch("/{id}", h.UpdateAlertRule)
+			r.Delete("/{id}", h.DeleteAlertRule)
+		})
+	})
+	// Saved report definitions and running them
+	r.Route("/reports", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeRead))
+			r.Use(middleware.PreferReplica)
+			r.Get("/definitions", h.ListReportDefinitions)
+			r.Get("/run/{definition_id}", h.RunReport)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/definitions", h.CreateReportDefinition)
+			r.Delete("/definitions/{id}", h.DeleteReportDefinition)
+		})
+	})
+	// Buckets users' data is exported to daily
+	r.Route("/exports/destinations", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Use(middleware.RequireScope(auth.ScopePrivacy))
+		r.Get("/", h.ListExportDestinations)
+		r.Post("/", h.CreateExportDestination)
+		r.Delete("/{id}", h.DeleteExportDestination)
+		r.Post("/{id}/run", h.RunExportDestination)
+	})
+	// Transactions or balances as a CSV or Parquet file
+	r.With(authenticate, middleware.RequireScope(auth.ScopePrivacy)).Get("/exports/{dataset}", h.DownloadDataset)
+	// An account's transactions as a QIF or OFX file for desktop finance software
+	r.With(authenticate, middleware.RequireScope(auth.ScopePrivacy)).Get("/exports/accounts/{id}", h.DownloadAccountStatement)
+	// RSU and option grants with their vesting schedules
+	r.Route("/equity/grants", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListEquityGrants)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateEquityGrant)
+			r.Delete("/{id}", h.DeleteEquityGrant)
+		})
+	})
+	// Rental properties that transactions are tagged to
+	r.Route("/properties", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListRentalProperties)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateRentalProperty)
+			r.Delete("/{id}", h.DeleteRentalProperty)
+		})
+	})
+	// Dependents with allowance schedules and pocket money ledgers
+	r.Route("/dependents", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeRead))
+			r.Get("/", h.ListDependents)
+			r.Get("/{id}/ledger", h.GetDependentLedger)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateDependent)
+			r.Patch("/{id}", h.UpdateDependent)
+			r.Delete("/{id}", h.DeleteDependent)
+			r.Post("/{id}/allowances", h.CreateAllowanceSchedule)
+			r.Delete("/{id}/allowances/{scheduleID}", h.DeleteAllowanceSchedule)
+			r.Post("/{id}/ledger", h.AddDependentLedgerEntry)
+		})
+	})
+	// Rules marking transactions as business or personal expenses
+	r.Route("/expense-rules", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListExpenseRules)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateExpenseRule)
+			r.Delete("/{id}", h.DeleteExpenseRule)
+		})
+	})
+
+	r.Route("/notifications", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListNotifications)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/{id}/read", h.MarkNotificationRead)
+
+		// Slack and Discord channels alert rules route to
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/channels", h.ListNotificationChannels)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/channels", h.CreateNotificationChannel)
+			r.Delete("/channels/{id}", h.DeleteNotificationChannel)
+			r.Post("/channels/{id}/test", h.TestNotificationChannel)
+		})
+	})
+
+	// Weekly and monthly digest emails
+	r.Route("/digests", func(r chi.Router) {
+		r.Use(authenticate, middleware.RequireScope(auth.ScopeRead))
+		r.Get("/", h.ListDigestDeliveries)
+		r.Get("/preview", h.PreviewDigest)
+	})
+
+	// Watched stock and crypto symbols and their price alerts
+	r.Route("/watchlist", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListWatchlist)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.AddWatchlistItem)
+			r.Delete("/{id}", h.RemoveWatchlistItem)
+			r.Post("/{id}/alerts", h.CreateWatchlistAlert)
+			r.Delete("/{id}/alerts/{alertID}", h.DeleteWatchlistAlert)
+		})
+	})
+
+	// Account groups such as Emergency Fund, totalled in account reads
+	r.Route("/account-groups", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListAccountGroups)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.CreateAccountGroup)
+			r.Patch("/{id}", h.RenameAccountGroup)
+			r.Delete("/{id}", h.DeleteAccountGroup)
+		})
+	})
+
+	// Households sharing accounts, net worth and budgets
+	r.Route("/households", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeRead))
+			r.Get("/", h.ListHouseholds)
+			r.Get("/{id}", h.GetHousehold)
+			r.Get("/{id}/networth", h.GetHouseholdNetWorth)
+			r.Get("/{id}/budgets", h.ListHouseholdBudgets)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeGrants))
+			r.Post("/", h.CreateHousehold)
+			r.Delete("/{id}", h.DeleteHousehold)
+			r.Post("/{id}/members", h.InviteHouseholdMember)
+			r.Post("/{id}/join", h.JoinHousehold)
+			r.Delete("/{id}/members/{userID}", h.RemoveHouseholdMember)
+			r.Put("/{id}/accounts/{accountID}/visibility", h.SetAccountVisibility)
+			r.Post("/{id}/budgets", h.SetHouseholdBudget)
+			r.Delete("/{id}/budgets/{budgetID}", h.DeleteHouseholdBudget)
+		})
+	})
+
+	// Account security status and unlocking after anomaly lockouts
+	r.Route("/security", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Get("/status", h.GetSecurityStatus)
+		r.With(middleware.RequireScope(auth.ScopeTrade)).Post("/unlock-trading", h.UnlockTrading)
+	})
+
+	// Login sessions of the calling user
+	r.Route("/sessions", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Get("/", h.ListSessions)
+		r.Delete("/{id}", h.RevokeSession)
+		r.Post("/revoke-others", h.RevokeOtherSessions)
+	})
+
+	// Timezone, locale and fiscal year preferences, and users' own periods
+	r.Route("/preferences", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.GetPreferences)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Put("/", h.UpdatePreferences)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/periods", h.ListPeriodDefinitions)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Post("/periods", h.CreatePeriodDefinition)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Delete("/periods/{id}", h.DeletePeriodDefinition)
+	})
+
+	// Duplicate accounts and transactions across linked sources, and
+	// refunds paired with their charges
+	r.Route("/duplicates", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.ListDuplicates)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/refunds", h.ListRefunds)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopeProfile))
+			r.Post("/", h.LinkDuplicate)
+			r.Post("/scan", h.ScanDuplicates)
+			r.Put("/{id}", h.UpdateDuplicate)
+			r.Put("/refunds/{id}", h.UpdateRefund)
+		})
+	})
+
+	// User registration and profiles. Registration also accepts tokens of
+	// identities that have no user yet.
+	register := chi.Chain(blockFailedAuth, middleware.Authenticate(verifier, keyStore, h.ResolveRegistrant), logging.TagUser, serviceCert, middleware.CSRF, userLimit).Handler
+	r.With(register, middleware.RequireScope(auth.ScopeProfile)).Post("/users", h.CreateUser)
+	r.Route("/users/{id}", func(r chi.Router) {
+		r.Use(authenticate)
+		r.With(middleware.RequireScope(auth.ScopeRead)).Get("/", h.GetUser)
+		r.With(middleware.RequireScope(auth.ScopeProfile)).Patch("/", h.UpdateUser)
+
+		// Data export and account deletion (GDPR/CCPA)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireScope(auth.ScopePrivacy))
+			r.Post("/export", h.ExportUserData)
+			r.Get("/exports/{exportID}", h.DownloadUserExport)
+			r.Delete("/", h.DeleteUser)
+			r.Post("/deletion/cancel", h.CancelUserDeletion)
+		})
+	})
+
+	// Outbound webhook subscriptions
+	r.Route("/webhooks/subscriptions", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Use(middleware.RequireScope(auth.ScopeProfile))
+		r.Post("/", h.CreateWebhookSubscription)
+		r.Get("/", h.ListWebhookSubscriptions)
+		r.Delete("/{id}", h.DeleteWebhookSubscription)
+		r.Get("/{id}/deliveries", h.ListWebhookDeliveries)
+	})
+
+	// Operator admin API
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(adminCert)
+		r.Use(middleware.AdminAuth(cfg.AdminToken))
+		r.Get("/users", h.AdminListUsers)
+		r.Delete("/users/{id}/sessions", h.AdminRevokeUserSessions)
+		r.Post("/users/{id}/unlock-trading", h.AdminUnlockTrading)
+		r.Get("/items/health", h.AdminItemHealth)
+		r.Get("/jobs", h.AdminJobsDashboard)
+		r.Get("/jobs/failed", h.AdminListFailedJobs)
+		r.Post("/jobs/{id}/requeue", h.AdminRequeueJob)
+		r.Get("/sync-overview", h.AdminSyncOverview)
+		r.Get("/webhooks/failures", h.AdminWebhookFailures)
+		r.Get("/webhooks/lag", h.AdminWebhookLag)
+		r.Get("/retention", h.AdminRetentionReport)
+		r.Post("/retention/run", h.AdminRunRetention)
+		r.Post("/securities/enrich", h.AdminEnrichSecurities)
+		r.Post("/categories/migrate", h.AdminMigrateCategories)
+		r.Get("/contribution-limits", h.AdminListContributionLimits)
+		r.Put("/contribution-limits/{year}/{kind}", h.AdminSetContributionLimit)
+		r.Get("/corporate-actions", h.AdminListCorporateActions)
+		r.Post("/corporate-actions", h.AdminRecordCorporateAction)
+		r.Put("/users/{id}/retention/{policy}", h.AdminSetRetentionOverride)
+		r.Delete("/users/{id}/retention/{policy}", h.AdminRemoveRetentionOverride)
+		r.Get("/users/{id}/snapshot", h.AdminExportSnapshot)
+		r.Post("/snapshots/restore", h.AdminRestoreSnapshot)
+
+		r.Post("/api-keys", h.AdminIssueAPIKey)
+		r.Get("/api-keys", h.AdminListAPIKeys)
+		r.Post("/api-keys/{id}/rotate", h.AdminRotateAPIKey)
+		r.Put("/api-keys/{id}/redact", h.AdminSetAPIKeyRedaction)
+		r.Delete("/api-keys/{id}", h.AdminRevokeAPIKey)
+
+		r.Post("/encryption/rotate", h.AdminRotateEncryptionKey)
+		r.Post("/encryption/reencrypt", h.AdminReencrypt)
+
+		r.Get("/debug/requests", h.AdminListCapturedRequests)
+		r.Delete("/debug/requests", h.AdminClearCapturedRequests)
+	})
+
+	// Build info, runtime diagnostics and, when enabled, profiling for
+	// operators
+	r.Route("/debug", func(r chi.Router) {
+		r.Use(adminCert)
+		r.Use(middleware.AdminAuth(cfg.AdminToken))
+		r.Get("/info", h.DebugInfo)
+		if cfg.EnablePprof {
+			r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+			r.HandleFunc("/pprof/profile", pprof.Profile)
+			r.HandleFunc("/pprof/symbol", pprof.Symbol)
+			r.HandleFunc("/pprof/trace", pprof.Trace)
+			r.HandleFunc("/pprof/*", pprof.Index)
+		}
+	})
+
+	// Prometheus scrape endpoint
+	r.With(ipLimit).Handle("/metrics", metrics.Handler())
+
+	// Allow prior-knowledge HTTP/2 over cleartext for internal MCP traffic
+	var handler http.Handler = r
+	if cfg.EnableH2C && cfg.TLSCertFile == "" {
+		handler = h2c.NewHandler(r, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
 
 	// Start server
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", cfg.Port),
-		Handler: r,
+		Addr:              fmt.Sprintf(":%s", cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	if cfg.TLSCertFile != "" {
+		server.TLSConfig, err = mtls.ServerConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+	}
+
+	// Plaintext health checks on a separate port, so probes don't need
+	// certificates and nothing else is served without TLS
+	var healthServer *http.Server
+	if cfg.HealthPort != "" {
+		healthRouter := chi.NewRouter()
+		healthRouter.Get("/healthz", h.HealthCheck)
+		healthRouter.Get("/readyz", h.ReadinessCheck)
+		healthServer = &http.Server{
+			Addr:              fmt.Sprintf(":%s", cfg.HealthPort),
+			Handler:           healthRouter,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		}
+		go func() {
+			slog.Info("Health checks listening", "port", cfg.HealthPort)
+			if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Health server failed to start: %v", err)
+			}
+		}()
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Go ingestion service running on port %s", cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			slog.Info("Go ingestion service running", "port", cfg.Port, "tls", true, "mtls", mtlsEnabled)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			slog.Info("Go ingestion service running", "port", cfg.Port, "tls", false)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
@@ -126,16 +951,178 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
 	// Create shutdown context with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	// Shutdown server
+	// Stop accepting requests, then stop background work and drain
+	// in-flight jobs, webhook and chat deliveries before the pools close
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
+	}
+
+	stopBackground()
+	if err := jobManager.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Interrupted running jobs; they will resume on restart", "error", err)
+	}
+	if err := dispatcher.Wait(shutdownCtx); err != nil {
+		slog.Warn("Abandoned in-flight webhook deliveries", "error", err)
+	}
+	if err := notificationStore.Wait(shutdownCtx); err != nil {
+		slog.Warn("Abandoned in-flight chat deliveries", "error", err)
+	}
+
+	if healthServer != nil {
+		healthServer.Shutdown(shutdownCtx)
+	}
+
+	slog.Info("Server exited")
+}
+
+// watchSecrets refreshes secrets periodically and applies rotated database
+// credentials, Plaid credentials and master keys to the running service
+func watchSecrets(ctx context.Context, cfg *config.Config, db *database.Database, plaidClient *plaid.Client, enc *encryption.Service) {
+	cfg.Secrets.OnChange(func(changed map[string]string) {
+		cfg.ApplySecrets(changed)
+
+		if _, ok := changed["DATABASE_URL"]; ok {
+			if err := db.UpdateURL(ctx, cfg.DatabaseURL); err != nil {
+				slog.Error("Failed to apply rotated database credentials", "error", err)
+			} else {
+				slog.Info("Reconnected to database with rotated credentials")
+			}
+		}
+
+		_, idChanged := changed["PLAID_CLIENT_ID"]
+		_, secretChanged := changed["PLAID_SECRET"]
+		if idChanged || secretChanged {
+			plaidClient.SetCredentials(cfg.PlaidClientID, cfg.PlaidSecret)
+			slog.Info("Applied rotated Plaid credentials")
+		}
+
+		_, keyChanged := changed["KMS_LOCAL_MASTER_KEY"]
+		_, keysChanged := changed["KMS_LOCAL_MASTER_KEYS"]
+		_, tokenChanged := changed["VAULT_TOKEN"]
+		if keyChanged || keysChanged || tokenChanged {
+			if err := enc.ReloadKeys(ctx, cfg.KeyManager); err != nil {
+				slog.Error("Failed to apply rotated master keys", "error", err)
+			} else {
+				slog.Info("Reloaded master keys")
+			}
+		}
+	})
+
+	go cfg.Secrets.Watch(ctx, cfg.SecretsRefreshInterval)
+}
+
+const migrateUsage = `usage: ingest migrate <command>
+
+commands:
+  up             apply all pending migrations
+  status         show the applied and latest schema versions
+  force VERSION  mark VERSION as applied and clear the dirty flag`
+
+// runMigrate implements the migrate subcommand
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	migrator, err := database.NewMigrator(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize migrations: %v", err)
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		printMigrationStatus(migrator)
+	case "status":
+		printMigrationStatus(migrator)
+	case "force":
+		if len(args) != 2 {
+			log.Fatalf("force requires a version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Invalid version %q", args[1])
+		}
+		if err := migrator.Force(version); err != nil {
+			log.Fatalf("Failed to force version: %v", err)
+		}
+		printMigrationStatus(migrator)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func printMigrationStatus(migrator *database.Migrator) {
+	status, err := migrator.Status()
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+	fmt.Printf("version: %d\nlatest: %d\npending: %d\ndirty: %t\n",
+		status.Version, status.Latest, status.Pending, status.Dirty)
+}
+
+// checkMigrations runs the startup migration check selected by
+// DB_MIGRATE_ON_START: "up" applies pending migrations, "check" refuses to
+// start on a dirty or outdated schema, and "off" skips the check
+func checkMigrations(cfg *config.Config) {
+	if cfg.MigrateOnStart == "off" {
+		return
 	}
 
-	log.Println("Server exited")
-}
\ No newline at end of file
+	migrator, err := database.NewMigrator(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize migrations: %v", err)
+	}
+	defer migrator.Close()
+
+	switch cfg.MigrateOnStart {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+		}
+	case "check":
+		if err := migrator.Check(); err != nil {
+			log.Fatalf("Schema check failed: %v; run `ingest migrate up`", err)
+		}
+	default:
+		log.Fatalf("Invalid DB_MIGRATE_ON_START %q; use off, check or up", cfg.MigrateOnStart)
+	}
+}
+
+const mcpUsage = `usage: ingest mcp [-user USER_ID]
+
+Serves the MCP tools over stdio, acting as the given user (default
+MCP_USER_ID). The tools are also served over HTTP at /mcp.`
+
+// runMCP implements the mcp subcommand
+func runMCP(ctx context.Context, cfg *config.Config, h *handlers.Handlers, args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, mcpUsage) }
+	userID := fs.String("user", cfg.MCPUserID, "user the tools act as")
+	fs.Parse(args)
+	if *userID == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// The local agent host stands in for the user, as a signed-in session would
+	ctx = auth.WithPrincipal(ctx, &auth.Principal{UserID: *userID, Name: "mcp-stdio"})
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Serving MCP over stdio", "user_id", *userID)
+	if err := h.MCPServer().ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		slog.Error("MCP server failed", "error", err)
+	}
+}
@@ -0,0 +1,36 @@
+-- Outbound webhook subscriptions for external consumers
+-- Created: 2026-10-16
+
+CREATE TABLE webhook_subscriptions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid REFERENCES users(id) ON DELETE CASCADE,
+    url text NOT NULL,
+    event_types text[] NOT NULL,
+    secret text NOT NULL,
+    is_active boolean DEFAULT true,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+-- Delivery log, one row per event per subscription
+CREATE TABLE webhook_deliveries (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id uuid REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer DEFAULT 0,
+    response_status integer,
+    error_message text,
+    last_attempt_at timestamptz,
+    delivered_at timestamptz,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_webhook_subscriptions_user_id ON webhook_subscriptions(user_id);
+CREATE INDEX idx_webhook_subscriptions_event_types ON webhook_subscriptions USING GIN (event_types);
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_status ON webhook_deliveries(status);
+
+CREATE TRIGGER update_webhook_subscriptions_updated_at BEFORE UPDATE ON webhook_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,19 @@
+-- Generalize sync_jobs into a jobs subsystem (syncs, backfills, reports, exports)
+-- Created: 2026-10-16
+
+ALTER TABLE sync_jobs RENAME TO jobs;
+
+ALTER TABLE jobs
+    ADD COLUMN params jsonb,
+    ADD COLUMN result jsonb,
+    ADD COLUMN progress integer DEFAULT 0,
+    ADD COLUMN updated_at timestamptz DEFAULT now();
+
+ALTER INDEX idx_sync_jobs_user_id RENAME TO idx_jobs_user_id;
+ALTER INDEX idx_sync_jobs_plaid_item RENAME TO idx_jobs_plaid_item;
+ALTER INDEX idx_sync_jobs_status RENAME TO idx_jobs_status;
+
+CREATE INDEX idx_jobs_type_status ON jobs(job_type, status);
+
+CREATE TRIGGER update_jobs_updated_at BEFORE UPDATE ON jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,21 @@
+-- API keys for service-to-service access
+-- Created: 2026-10-16
+
+CREATE TABLE api_keys (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL,
+    key_prefix text UNIQUE NOT NULL,
+    key_hash text NOT NULL,
+    scopes text[] NOT NULL,
+    rotated_from uuid REFERENCES api_keys(id) ON DELETE SET NULL,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    last_used_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_api_keys_active ON api_keys(key_prefix) WHERE revoked_at IS NULL;
+
+CREATE TRIGGER update_api_keys_updated_at BEFORE UPDATE ON api_keys
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
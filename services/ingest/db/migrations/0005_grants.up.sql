@@ -0,0 +1,19 @@
+-- Delegated access grants (advisor / partner read-only access)
+-- Created: 2026-10-16
+
+CREATE TABLE grants (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    grantee_user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('viewer', 'advisor')),
+    revoked_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE(owner_user_id, grantee_user_id),
+    CHECK (owner_user_id <> grantee_user_id)
+);
+
+CREATE INDEX idx_grants_grantee ON grants(grantee_user_id) WHERE revoked_at IS NULL;
+
+CREATE TRIGGER update_grants_updated_at BEFORE UPDATE ON grants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,17 @@
+-- Master encryption keys, so key rotations survive restarts
+-- Created: 2026-10-16
+
+CREATE TABLE encryption_keys (
+    key_id text PRIMARY KEY,
+    status text NOT NULL CHECK (status IN ('active', 'retired')),
+    activated_at timestamptz DEFAULT now(),
+    retired_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+-- At most one active key at a time
+CREATE UNIQUE INDEX idx_encryption_keys_active ON encryption_keys(status) WHERE status = 'active';
+
+CREATE TRIGGER update_encryption_keys_updated_at BEFORE UPDATE ON encryption_keys
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,43 @@
+-- Audit log, data exports and account deletion requests
+-- Created: 2026-10-17
+
+CREATE TABLE audit_log (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor text NOT NULL,
+    action text NOT NULL,
+    target_user_id uuid, -- no FK: entries outlive deleted users
+    metadata jsonb,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_audit_log_target ON audit_log(target_user_id, created_at DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action, created_at DESC);
+
+-- Generated data export archives, kept for a limited time
+CREATE TABLE data_exports (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    archive bytea NOT NULL,
+    size_bytes integer NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_data_exports_user ON data_exports(user_id, created_at DESC);
+
+-- Account deletion requests; rows are kept after the user is purged
+CREATE TABLE user_deletions (
+    user_id uuid PRIMARY KEY,
+    requested_by text NOT NULL,
+    requested_at timestamptz DEFAULT now(),
+    scheduled_for timestamptz NOT NULL,
+    cancelled_at timestamptz,
+    completed_at timestamptz,
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_user_deletions_due ON user_deletions(scheduled_for)
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
+
+CREATE TRIGGER update_user_deletions_updated_at BEFORE UPDATE ON user_deletions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,20 @@
+-- User sessions with device tracking and revocation
+-- Created: 2026-10-17
+
+CREATE TABLE sessions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_id text NOT NULL UNIQUE, -- sha256 of the token's sid/jti claim, or of the token itself
+    device text,
+    user_agent text,
+    ip_address text,
+    last_seen_at timestamptz DEFAULT now(),
+    revoked_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id, last_seen_at DESC);
+
+CREATE TRIGGER update_sessions_updated_at BEFORE UPDATE ON sessions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
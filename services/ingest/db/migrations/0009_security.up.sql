@@ -0,0 +1,34 @@
+-- Insights and anomalous access lockouts
+-- Created: 2026-10-17
+
+CREATE TABLE insights (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type text NOT NULL,
+    severity text NOT NULL DEFAULT 'info' CHECK (severity IN ('info', 'warning', 'critical')),
+    title text NOT NULL,
+    message text NOT NULL,
+    metadata jsonb,
+    resolved_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_insights_user ON insights(user_id, created_at DESC);
+
+CREATE TRIGGER update_insights_updated_at BEFORE UPDATE ON insights
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per user whose trading is (or was) locked after suspicious activity
+CREATE TABLE trading_locks (
+    user_id uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    reason text NOT NULL,
+    insight_id uuid REFERENCES insights(id) ON DELETE SET NULL,
+    locked_at timestamptz NOT NULL DEFAULT now(),
+    unlocked_at timestamptz,
+    unlocked_by text,
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE TRIGGER update_trading_locks_updated_at BEFORE UPDATE ON trading_locks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
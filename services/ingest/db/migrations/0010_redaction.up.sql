@@ -0,0 +1,4 @@
+-- Per-API-key PII redaction policy
+-- Created: 2026-10-17
+
+ALTER TABLE api_keys ADD COLUMN redact boolean NOT NULL DEFAULT false;
@@ -0,0 +1,12 @@
+-- Durable job queue: retries with backoff, worker leases and dead-lettering
+-- Created: 2026-10-17
+
+ALTER TABLE jobs
+    ADD COLUMN attempts integer NOT NULL DEFAULT 0,
+    ADD COLUMN max_attempts integer NOT NULL DEFAULT 5,
+    ADD COLUMN run_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN locked_by text,
+    ADD COLUMN locked_at timestamptz;
+
+CREATE INDEX idx_jobs_ready ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_locked ON jobs(locked_at) WHERE status = 'running';
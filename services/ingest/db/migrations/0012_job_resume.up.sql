@@ -0,0 +1,5 @@
+-- Jobs interrupted by shutdown are parked as 'retryable' and resumed first
+-- Created: 2026-10-17
+
+DROP INDEX idx_jobs_ready;
+CREATE INDEX idx_jobs_ready ON jobs(run_at) WHERE status IN ('pending', 'retryable');
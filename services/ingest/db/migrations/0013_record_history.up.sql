@@ -0,0 +1,70 @@
+-- Soft deletes and change history for financial records
+-- Created: 2026-10-17
+
+ALTER TABLE accounts ADD COLUMN deleted_at timestamptz;
+ALTER TABLE transactions ADD COLUMN deleted_at timestamptz;
+ALTER TABLE holdings ADD COLUMN deleted_at timestamptz;
+ALTER TABLE investment_transactions ADD COLUMN deleted_at timestamptz;
+
+-- Append-only history: one row per insert, change or soft delete
+CREATE TABLE record_versions (
+    id bigserial PRIMARY KEY,
+    table_name text NOT NULL,
+    record_id text NOT NULL,
+    user_id uuid REFERENCES users(id) ON DELETE CASCADE,
+    operation text NOT NULL CHECK (operation IN ('insert', 'update', 'delete')),
+    data jsonb NOT NULL,
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_record_versions_user ON record_versions(user_id, changed_at);
+CREATE INDEX idx_record_versions_record ON record_versions(table_name, record_id, id);
+
+-- Versions are rows' new state. Trigger arguments name columns left out of
+-- it: raw payloads and encrypted PII. Re-syncs that change nothing but
+-- updated_at are not recorded.
+CREATE OR REPLACE FUNCTION record_version()
+RETURNS TRIGGER AS $$
+DECLARE
+    omitted text[] := COALESCE(TG_ARGV, '{}');
+    new_data jsonb := to_jsonb(NEW) - omitted;
+    op text := 'insert';
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        IF new_data - 'updated_at' = (to_jsonb(OLD) - omitted) - 'updated_at' THEN
+            RETURN NULL;
+        END IF;
+        op := CASE
+            WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN 'delete'
+            ELSE 'update'
+        END;
+    END IF;
+
+    INSERT INTO record_versions (table_name, record_id, user_id, operation, data)
+    VALUES (TG_TABLE_NAME, NEW.id::text, NEW.user_id, op, new_data);
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_accounts_version AFTER INSERT OR UPDATE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION record_version('mask', 'official_name');
+
+CREATE TRIGGER record_transactions_version AFTER INSERT OR UPDATE ON transactions
+    FOR EACH ROW EXECUTE FUNCTION record_version('raw');
+
+CREATE TRIGGER record_holdings_version AFTER INSERT OR UPDATE ON holdings
+    FOR EACH ROW EXECUTE FUNCTION record_version();
+
+CREATE TRIGGER record_investment_transactions_version AFTER INSERT OR UPDATE ON investment_transactions
+    FOR EACH ROW EXECUTE FUNCTION record_version();
+
+-- History rows may be removed with their user but never rewritten
+CREATE OR REPLACE FUNCTION reject_record_version_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'record_versions is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_versions_append_only BEFORE UPDATE ON record_versions
+    FOR EACH ROW EXECUTE FUNCTION reject_record_version_update();
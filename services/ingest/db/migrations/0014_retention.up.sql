@@ -0,0 +1,30 @@
+-- Data retention: per-user policy overrides and archived records
+-- Created: 2026-10-17
+
+-- Per-user retention overrides; retain_days NULL keeps the user's records
+-- under that policy forever (e.g. a legal hold)
+CREATE TABLE retention_overrides (
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    policy text NOT NULL,
+    retain_days integer CHECK (retain_days > 0),
+    reason text,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (user_id, policy)
+);
+
+CREATE TRIGGER update_retention_overrides_updated_at BEFORE UPDATE ON retention_overrides
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Cold storage for records moved out of hot tables by an archive policy
+CREATE TABLE archived_records (
+    id bigserial PRIMARY KEY,
+    table_name text NOT NULL,
+    record_id text NOT NULL,
+    user_id uuid REFERENCES users(id) ON DELETE CASCADE,
+    data jsonb NOT NULL,
+    archived_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_archived_records_user ON archived_records(user_id, table_name);
+CREATE INDEX idx_archived_records_record ON archived_records(table_name, record_id);
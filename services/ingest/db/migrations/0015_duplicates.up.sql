@@ -0,0 +1,28 @@
+-- Duplicate accounts and transactions across linked sources
+-- Created: 2026-10-17
+
+-- A link marks duplicate_id as a copy of canonical_id. Detected and
+-- confirmed duplicates are left out of account lists, balances and
+-- spending; rejected links keep both records and stop detection from
+-- linking the pair again. Transaction links belong to the account link
+-- whose accounts they were matched across.
+CREATE TABLE duplicate_links (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    record_type text NOT NULL CHECK (record_type IN ('account', 'transaction')),
+    canonical_id text NOT NULL,
+    duplicate_id text NOT NULL,
+    parent_id uuid REFERENCES duplicate_links(id) ON DELETE CASCADE,
+    fingerprint text,
+    status text NOT NULL DEFAULT 'detected' CHECK (status IN ('detected', 'confirmed', 'rejected')),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (record_type, canonical_id, duplicate_id),
+    CHECK (canonical_id <> duplicate_id)
+);
+
+CREATE INDEX idx_duplicate_links_user ON duplicate_links(user_id, record_type, status);
+CREATE INDEX idx_duplicate_links_duplicate ON duplicate_links(record_type, duplicate_id) WHERE status <> 'rejected';
+
+CREATE TRIGGER update_duplicate_links_updated_at BEFORE UPDATE ON duplicate_links
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,14 @@
+-- Per-user usage metering
+-- Created: 2026-10-17
+
+-- Daily counts of each user's API calls, item syncs and orders. The day's
+-- running counts live in Redis, where quotas are enforced, and are copied
+-- here periodically; a row holds the largest count flushed for its day.
+CREATE TABLE usage_daily (
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day date NOT NULL,
+    metric text NOT NULL,
+    count bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (user_id, day, metric)
+);
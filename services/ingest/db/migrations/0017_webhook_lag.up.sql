@@ -0,0 +1,18 @@
+-- Plaid webhook delivery lag
+-- Created: 2026-10-17
+
+-- Time from receiving each Plaid transactions webhook to its data being
+-- persisted. Webhooks name items by Plaid's item_id, which is kept as
+-- given.
+CREATE TABLE webhook_lag (
+    id bigserial PRIMARY KEY,
+    plaid_item_id text NOT NULL,
+    webhook_code text NOT NULL,
+    received_at timestamptz NOT NULL,
+    persisted_at timestamptz NOT NULL,
+    lag_ms bigint NOT NULL,
+    slo_breached boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX idx_webhook_lag_persisted ON webhook_lag(persisted_at);
+CREATE INDEX idx_webhook_lag_item ON webhook_lag(plaid_item_id, persisted_at DESC);
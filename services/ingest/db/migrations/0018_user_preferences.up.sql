@@ -0,0 +1,14 @@
+-- User timezone and locale preferences
+-- Created: 2026-10-17
+
+-- Users without a row use the defaults: UTC, USD, Monday weeks and
+-- 1,234.56 numbers
+CREATE TABLE user_preferences (
+    user_id uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    timezone text NOT NULL DEFAULT 'UTC',
+    base_currency text NOT NULL DEFAULT 'USD',
+    week_start text NOT NULL DEFAULT 'monday' CHECK (week_start IN ('monday', 'sunday', 'saturday')),
+    number_format text NOT NULL DEFAULT '1,234.56',
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
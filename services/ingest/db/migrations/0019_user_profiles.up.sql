@@ -0,0 +1,14 @@
+-- User profiles and onboarding
+-- Created: 2026-10-17
+
+-- Onboarding runs registered -> linked (first item connected) -> complete
+ALTER TABLE users
+    ADD COLUMN display_name text,
+    ADD COLUMN onboarding_state text NOT NULL DEFAULT 'registered'
+        CHECK (onboarding_state IN ('registered', 'linked', 'complete'));
+
+-- Users who already have items are past linking
+UPDATE users SET onboarding_state = 'linked'
+WHERE EXISTS (SELECT 1 FROM plaid_items pi WHERE pi.user_id = users.id);
+
+CREATE INDEX idx_users_email_lower ON users(lower(email));
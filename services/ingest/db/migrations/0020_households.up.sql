@@ -0,0 +1,47 @@
+-- Households: users sharing a combined view of their finances
+-- Created: 2026-10-17
+
+CREATE TABLE households (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name text NOT NULL,
+    created_by uuid REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+-- Invited members join by accepting; the creator is the owner
+CREATE TABLE household_members (
+    household_id uuid NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role text NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+    status text NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'active')),
+    invited_by uuid REFERENCES users(id) ON DELETE SET NULL,
+    joined_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    PRIMARY KEY (household_id, user_id)
+);
+
+CREATE INDEX idx_household_members_user ON household_members(user_id);
+
+-- How much of an account the rest of the household sees. Accounts without
+-- a row are shared in full.
+CREATE TABLE household_account_visibility (
+    household_id uuid NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    visibility text NOT NULL CHECK (visibility IN ('full', 'balance_only', 'hidden')),
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (household_id, account_id)
+);
+
+-- Monthly spending limits per category, tracked against the household's
+-- fully shared transactions
+CREATE TABLE household_budgets (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    household_id uuid NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    category text NOT NULL,
+    monthly_limit numeric NOT NULL CHECK (monthly_limit > 0),
+    created_by uuid REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (household_id, category)
+);
@@ -0,0 +1,42 @@
+-- Alert rules evaluated during sync, and the notifications they raise
+-- Created: 2026-10-17
+
+CREATE TABLE alert_rules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    condition text NOT NULL,
+    severity text NOT NULL DEFAULT 'info' CHECK (severity IN ('info', 'warning', 'critical')),
+    enabled boolean NOT NULL DEFAULT true,
+    last_triggered_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_alert_rules_user ON alert_rules(user_id);
+
+CREATE TRIGGER update_alert_rules_updated_at BEFORE UPDATE ON alert_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Transactions and accounts a rule has fired for, so re-syncs don't fire
+-- it again. Balance matches are cleared once the balance recovers.
+CREATE TABLE alert_matches (
+    rule_id uuid NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    subject_id text NOT NULL,
+    matched_at timestamptz DEFAULT now(),
+    PRIMARY KEY (rule_id, subject_id)
+);
+
+CREATE TABLE notifications (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rule_id uuid REFERENCES alert_rules(id) ON DELETE SET NULL,
+    type text NOT NULL,
+    title text NOT NULL,
+    message text NOT NULL,
+    metadata jsonb,
+    read_at timestamptz,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, created_at DESC);
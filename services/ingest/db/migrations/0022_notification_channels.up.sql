@@ -0,0 +1,30 @@
+-- Slack and Discord channels that alert rules route notifications to
+-- Created: 2026-10-17
+
+-- webhook_url holds the incoming webhook URL, which is itself a credential,
+-- encrypted
+CREATE TABLE notification_channels (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('slack', 'discord')),
+    name text NOT NULL,
+    webhook_url text NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    last_delivery_at timestamptz,
+    last_error text,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_notification_channels_user ON notification_channels(user_id);
+
+CREATE TRIGGER update_notification_channels_updated_at BEFORE UPDATE ON notification_channels
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Channels each alert rule's notifications are sent to, besides the
+-- notification feed
+CREATE TABLE alert_rule_channels (
+    rule_id uuid NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    channel_id uuid NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+    PRIMARY KEY (rule_id, channel_id)
+);
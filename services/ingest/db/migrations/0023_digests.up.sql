@@ -0,0 +1,30 @@
+-- Scheduled weekly and monthly digest emails
+-- Created: 2026-10-17
+
+-- Digests are opt-in
+ALTER TABLE user_preferences
+    ADD COLUMN digest_frequency text NOT NULL DEFAULT 'off'
+        CHECK (digest_frequency IN ('off', 'weekly', 'monthly'));
+
+-- One row per user and digest period, created when the scheduler queues
+-- the digest so each period is sent at most once. The portfolio value is
+-- what the next digest's portfolio change is measured against.
+CREATE TABLE digest_deliveries (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    frequency text NOT NULL CHECK (frequency IN ('weekly', 'monthly')),
+    period_start date NOT NULL,
+    period_end date NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    portfolio_value numeric,
+    error text,
+    sent_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (user_id, frequency, period_start)
+);
+
+CREATE INDEX idx_digest_deliveries_user ON digest_deliveries(user_id, created_at DESC);
+
+CREATE TRIGGER update_digest_deliveries_updated_at BEFORE UPDATE ON digest_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,13 @@
+-- Plaid consent and data scope per item
+-- Created: 2026-10-17
+
+-- Plaid names items by its own item_id in webhooks, so keep it to map
+-- them back. Items linked before this have none until they are relinked.
+ALTER TABLE plaid_items
+    ADD COLUMN plaid_item_id text UNIQUE,
+    ADD COLUMN consented_products text[] NOT NULL DEFAULT '{}',
+    ADD COLUMN consent_expires_at timestamptz,
+    ADD COLUMN consent_updated_at timestamptz;
+
+-- Existing items were linked for everything the sync reads
+UPDATE plaid_items SET consented_products = ARRAY['transactions', 'investments'];
@@ -0,0 +1,9 @@
+-- Per-account visibility and analytics exclusion
+-- Created: 2026-10-17
+
+-- Hidden accounts are left out of account lists and everything computed
+-- from them; accounts excluded from analytics are listed but not counted
+-- in net worth, budgets or summaries. Neither is reset by a sync.
+ALTER TABLE accounts
+    ADD COLUMN hidden boolean NOT NULL DEFAULT false,
+    ADD COLUMN exclude_from_analytics boolean NOT NULL DEFAULT false;
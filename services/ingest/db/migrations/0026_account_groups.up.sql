@@ -0,0 +1,24 @@
+-- Account nicknames and user-defined account groups
+-- Created: 2026-10-17
+
+-- Groups such as Emergency Fund or Kids' College, named uniquely per user
+CREATE TABLE account_groups (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_account_groups_user_name ON account_groups(user_id, lower(name));
+
+CREATE TRIGGER update_account_groups_updated_at BEFORE UPDATE ON account_groups
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- A nickname is shown instead of the institution's name; neither it nor
+-- the group is reset by a sync. Deleting a group ungroups its accounts.
+ALTER TABLE accounts
+    ADD COLUMN nickname text,
+    ADD COLUMN group_id uuid REFERENCES account_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_accounts_group_id ON accounts(group_id) WHERE group_id IS NOT NULL;
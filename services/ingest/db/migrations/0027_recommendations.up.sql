@@ -0,0 +1,33 @@
+-- Transfer recommendations from surplus cash
+-- Created: 2026-10-17
+
+-- Advice only: nothing here moves money. A recommendation stays pending
+-- until the user accepts or dismisses it, or a later sync finds the
+-- surplus gone and expires it.
+CREATE TABLE recommendations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('transfer')),
+    from_account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    to_account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    amount numeric NOT NULL CHECK (amount > 0),
+    currency text NOT NULL,
+    title text NOT NULL,
+    message text NOT NULL,
+    metadata jsonb,
+    status text NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'dismissed', 'expired')),
+    decided_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+-- At most one pending recommendation per pair of accounts; regenerating
+-- refreshes it in place
+CREATE UNIQUE INDEX idx_recommendations_pending
+    ON recommendations(user_id, kind, from_account_id, to_account_id)
+    WHERE status = 'pending';
+CREATE INDEX idx_recommendations_user_created ON recommendations(user_id, created_at DESC);
+
+CREATE TRIGGER update_recommendations_updated_at BEFORE UPDATE ON recommendations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,45 @@
+-- Watchlist of stock and crypto symbols, and price alerts on them
+-- Created: 2026-10-17
+
+-- The latest quote of each watched symbol, refreshed in the background.
+-- day_open_price is the first quote of the UTC day, which daily moves
+-- are measured against.
+CREATE TABLE watchlist_items (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    symbol text NOT NULL,
+    asset_type text NOT NULL CHECK (asset_type IN ('stock', 'crypto')),
+    last_price numeric,
+    day_open_price numeric,
+    day_open_date date,
+    price_updated_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (user_id, asset_type, symbol)
+);
+
+CREATE INDEX idx_watchlist_items_symbol ON watchlist_items(asset_type, symbol);
+
+CREATE TRIGGER update_watchlist_items_updated_at BEFORE UPDATE ON watchlist_items
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Thresholds on a watched symbol: a price above or below threshold, or a
+-- move of threshold percent either way since the day's open. An alert
+-- fires when its condition becomes true and re-arms once it is false.
+CREATE TABLE watchlist_alerts (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES watchlist_items(id) ON DELETE CASCADE,
+    condition text NOT NULL CHECK (condition IN ('above', 'below', 'daily_move')),
+    threshold numeric NOT NULL CHECK (threshold > 0),
+    enabled boolean NOT NULL DEFAULT true,
+    triggered boolean NOT NULL DEFAULT false,
+    last_triggered_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_watchlist_alerts_item ON watchlist_alerts(item_id);
+
+CREATE TRIGGER update_watchlist_alerts_updated_at BEFORE UPDATE ON watchlist_alerts
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
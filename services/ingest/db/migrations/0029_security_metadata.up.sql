@@ -0,0 +1,22 @@
+-- Sector, asset class, expense ratio and logo of securities
+-- Created: 2026-10-17
+
+ALTER TABLE securities
+    ADD COLUMN sector text,
+    ADD COLUMN asset_class text,
+    ADD COLUMN expense_ratio numeric,
+    ADD COLUMN logo_url text,
+    ADD COLUMN enriched_at timestamptz;
+
+-- Market data lookups by symbol, shared by every user's securities so a
+-- symbol is looked up once per refresh period. found is false for symbols
+-- the provider does not know, which are not asked about again until then.
+CREATE TABLE security_metadata (
+    symbol text PRIMARY KEY,
+    found boolean NOT NULL,
+    sector text,
+    asset_class text,
+    expense_ratio numeric,
+    logo_url text,
+    fetched_at timestamptz NOT NULL DEFAULT now()
+);
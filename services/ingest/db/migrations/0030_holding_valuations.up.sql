@@ -0,0 +1,25 @@
+-- End-of-day price and value of each holding
+-- Created: 2026-10-17
+
+-- Plaid only reports a holding's latest price, so its history is kept by
+-- recording every holding once a day after the US market close. Rows
+-- outlive the holding, so sold positions still count in past periods.
+CREATE TABLE holding_valuations (
+    holding_id uuid NOT NULL,
+    date date NOT NULL,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    security_id uuid REFERENCES securities(id) ON DELETE SET NULL,
+    symbol text,
+    security_name text NOT NULL,
+    quantity numeric NOT NULL,
+    price numeric,
+    price_as_of date,
+    value numeric,
+    cost_basis numeric,
+    currency text NOT NULL,
+    created_at timestamptz DEFAULT now(),
+    PRIMARY KEY (holding_id, date)
+);
+
+CREATE INDEX idx_holding_valuations_user_date ON holding_valuations(user_id, date);
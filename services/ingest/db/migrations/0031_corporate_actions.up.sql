@@ -0,0 +1,24 @@
+-- Stock splits and symbol changes applied to holdings history
+-- Created: 2026-10-17
+
+-- An action is applied once, on or after its ex-date: splits rescale the
+-- quantities and prices recorded before the ex-date, symbol changes
+-- rename the security everywhere. result counts the rows each changed.
+CREATE TABLE corporate_actions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind text NOT NULL CHECK (kind IN ('split', 'symbol_change')),
+    symbol text NOT NULL,
+    new_symbol text,
+    split_from numeric CHECK (split_from > 0),
+    split_to numeric CHECK (split_to > 0),
+    ex_date date NOT NULL,
+    source text NOT NULL DEFAULT 'manual',
+    applied_at timestamptz,
+    result jsonb,
+    created_at timestamptz DEFAULT now(),
+    UNIQUE (kind, symbol, ex_date),
+    CHECK (kind <> 'split' OR (split_from IS NOT NULL AND split_to IS NOT NULL)),
+    CHECK (kind <> 'symbol_change' OR new_symbol IS NOT NULL)
+);
+
+CREATE INDEX idx_corporate_actions_pending ON corporate_actions(ex_date) WHERE applied_at IS NULL;
@@ -0,0 +1,8 @@
+-- Credit card statement cycles
+-- Created: 2026-10-17
+
+-- The day of the month a credit card's statement closes, as entered by
+-- the user. Months shorter than the day close on their last day. When it
+-- is not set, the cycle is inferred from the card's payments.
+ALTER TABLE accounts
+    ADD COLUMN statement_close_day smallint CHECK (statement_close_day BETWEEN 1 AND 31);
@@ -0,0 +1,26 @@
+-- Structured transaction locations
+-- Created: 2026-10-17
+
+-- Where a transaction took place, from the location Plaid reports. The
+-- raw payload stays in location; these columns are what location-based
+-- queries filter and cluster on.
+ALTER TABLE transactions
+    ADD COLUMN location_address text,
+    ADD COLUMN location_city text,
+    ADD COLUMN location_region text,
+    ADD COLUMN location_postal_code text,
+    ADD COLUMN location_country text,
+    ADD COLUMN latitude double precision CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude double precision CHECK (longitude BETWEEN -180 AND 180);
+
+UPDATE transactions SET
+    location_address = location->>'address',
+    location_city = location->>'city',
+    location_region = location->>'region',
+    location_postal_code = location->>'postal_code',
+    location_country = location->>'country',
+    latitude = (location->>'lat')::double precision,
+    longitude = (location->>'lon')::double precision
+WHERE jsonb_typeof(location) = 'object';
+
+CREATE INDEX idx_transactions_user_geo ON transactions(user_id, latitude, longitude) WHERE latitude IS NOT NULL;
@@ -0,0 +1,19 @@
+-- Receipts and documents attached to transactions
+-- Created: 2026-10-17
+
+-- The file itself is kept in object storage under object_key; sha256 is
+-- its digest, for spotting the same receipt uploaded twice.
+CREATE TABLE transaction_attachments (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    transaction_id text NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    object_key text NOT NULL UNIQUE,
+    filename text NOT NULL,
+    content_type text NOT NULL,
+    size_bytes bigint NOT NULL CHECK (size_bytes >= 0),
+    sha256 text NOT NULL,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_transaction_attachments_transaction ON transaction_attachments(transaction_id);
+CREATE INDEX idx_transaction_attachments_user ON transaction_attachments(user_id);
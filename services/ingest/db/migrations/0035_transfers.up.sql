@@ -0,0 +1,34 @@
+-- ACH transfers through Plaid Transfer
+-- Created: 2026-10-17
+
+-- A transfer is authorized when created and only moves money once it is
+-- confirmed before confirm_by. Dry runs are never sent to Plaid; their
+-- status is simulated. Live statuses follow Plaid's transfer events, the
+-- latest applied kept in last_event_id so the event sync can resume.
+CREATE TABLE transfers (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plaid_item_id uuid NOT NULL REFERENCES plaid_items(id) ON DELETE CASCADE,
+    account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    type text NOT NULL CHECK (type IN ('debit', 'credit')),
+    network text NOT NULL DEFAULT 'ach',
+    amount numeric NOT NULL CHECK (amount > 0),
+    description text NOT NULL,
+    status text NOT NULL CHECK (status IN ('authorized', 'declined', 'expired', 'pending', 'posted',
+        'settled', 'failed', 'returned', 'cancelled')),
+    dry_run boolean NOT NULL DEFAULT true,
+    authorization_id text,
+    decision_rationale text,
+    plaid_transfer_id text UNIQUE,
+    failure_reason text,
+    last_event_id bigint,
+    confirm_by timestamptz NOT NULL,
+    confirmed_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_transfers_user_created ON transfers(user_id, created_at DESC);
+
+CREATE TRIGGER update_transfers_updated_at BEFORE UPDATE ON transfers
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
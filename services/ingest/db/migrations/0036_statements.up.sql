@@ -0,0 +1,21 @@
+-- Monthly account statements from Plaid Statements
+-- Created: 2026-10-17
+
+-- The PDF is kept in object storage under object_key, alongside
+-- transaction attachments.
+CREATE TABLE statements (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plaid_item_id uuid NOT NULL REFERENCES plaid_items(id) ON DELETE CASCADE,
+    account_id text NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    plaid_statement_id text NOT NULL UNIQUE,
+    year smallint NOT NULL,
+    month smallint NOT NULL CHECK (month BETWEEN 1 AND 12),
+    date_posted date,
+    object_key text NOT NULL UNIQUE,
+    size_bytes bigint NOT NULL CHECK (size_bytes >= 0),
+    sha256 text NOT NULL,
+    created_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_statements_user_account ON statements(user_id, account_id, year DESC, month DESC);
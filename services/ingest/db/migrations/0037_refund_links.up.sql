@@ -0,0 +1,26 @@
+-- Refunds paired with the charges they reverse
+-- Created: 2026-10-17
+
+-- A link pairs refund_id, money coming back from a merchant, with the
+-- charge_id it reverses. Detected and confirmed pairs net to zero and are
+-- left out of spending analytics; rejected links keep both transactions
+-- counted and stop detection from pairing them again. A transaction is in
+-- at most one live pair.
+CREATE TABLE refund_links (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    charge_id text NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    refund_id text NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'detected' CHECK (status IN ('detected', 'confirmed', 'rejected')),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (charge_id, refund_id),
+    CHECK (charge_id <> refund_id)
+);
+
+CREATE INDEX idx_refund_links_user ON refund_links(user_id, status);
+CREATE UNIQUE INDEX idx_refund_links_charge ON refund_links(charge_id) WHERE status <> 'rejected';
+CREATE UNIQUE INDEX idx_refund_links_refund ON refund_links(refund_id) WHERE status <> 'rejected';
+
+CREATE TRIGGER update_refund_links_updated_at BEFORE UPDATE ON refund_links
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
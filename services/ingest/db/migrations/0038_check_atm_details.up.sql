@@ -0,0 +1,21 @@
+-- Check numbers and ATM withdrawals
+-- Created: 2026-10-17
+
+-- The check number Plaid reports or the descriptor shows, without leading
+-- zeros, and whether the transaction was cash taken out at an ATM. Rows
+-- synced before are filled in from their descriptors and categories.
+ALTER TABLE transactions
+    ADD COLUMN check_number text,
+    ADD COLUMN is_atm_withdrawal boolean NOT NULL DEFAULT false;
+
+UPDATE transactions SET check_number = NULLIF(ltrim(substring(description FROM
+    '(?i)\y(?:CHECK|CHK|CHEQUE)\y(?:\s+PAID)?\s*(?:#|NO\.?|NUMBER)?\s*(\d{1,10})\y'), '0'), '')
+WHERE description ~* '\y(CHECK|CHK|CHEQUE)\y';
+
+UPDATE transactions SET is_atm_withdrawal = true
+WHERE amount > 0
+  AND description !~* '\y(FEE|SURCHARGE|REBATE|REFUND|REIMB\w*|TRANSFER|XFER|DEPOSIT|PAYMENT)\y'
+  AND (description ~* '\yATM\y' OR (category[1] <> 'Bank Fees' AND 'ATM' = ANY(category[2:])));
+
+CREATE INDEX idx_transactions_user_check ON transactions(user_id, date) WHERE check_number IS NOT NULL;
+CREATE INDEX idx_transactions_user_atm ON transactions(user_id, date) WHERE is_atm_withdrawal;
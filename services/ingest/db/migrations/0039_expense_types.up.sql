@@ -0,0 +1,31 @@
+-- Business and personal expense classification
+-- Created: 2026-10-17
+
+-- Rules mark the transactions matching all of their criteria, from a
+-- merchant, a category and an account, as business or personal. When
+-- several match, the newest wins.
+CREATE TABLE expense_rules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expense_type text NOT NULL CHECK (expense_type IN ('business', 'personal')),
+    merchant text,
+    category text,
+    account_id text REFERENCES accounts(id) ON DELETE CASCADE,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CHECK (merchant IS NOT NULL OR category IS NOT NULL OR account_id IS NOT NULL)
+);
+
+CREATE INDEX idx_expense_rules_user ON expense_rules(user_id, created_at);
+
+CREATE TRIGGER update_expense_rules_updated_at BEFORE UPDATE ON expense_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- A transaction's type, with the rule that set it; a type set by hand has
+-- no rule and is never overridden by one
+ALTER TABLE transactions
+    ADD COLUMN expense_type text CHECK (expense_type IN ('business', 'personal')),
+    ADD COLUMN expense_rule_id uuid REFERENCES expense_rules(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_user_expense_type ON transactions(user_id, expense_type, date) WHERE expense_type IS NOT NULL;
+CREATE INDEX idx_transactions_expense_rule ON transactions(expense_rule_id) WHERE expense_rule_id IS NOT NULL;
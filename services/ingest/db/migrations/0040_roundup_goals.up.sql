@@ -0,0 +1,24 @@
+-- Round-up savings goals
+-- Created: 2026-10-17
+
+-- The goal a user's purchase round-ups, each purchase's difference to the
+-- next dollar, accumulate against. Nothing moves money; round-ups are
+-- worked out from transactions dated from started_on up to, not
+-- including, ended_on. Choosing a new goal ends the current one.
+CREATE TABLE roundup_goals (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    target_amount numeric NOT NULL CHECK (target_amount > 0),
+    started_on date NOT NULL,
+    ended_on date,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    CHECK (ended_on IS NULL OR ended_on >= started_on)
+);
+
+CREATE INDEX idx_roundup_goals_user ON roundup_goals(user_id, started_on);
+CREATE UNIQUE INDEX idx_roundup_goals_current ON roundup_goals(user_id) WHERE ended_on IS NULL;
+
+CREATE TRIGGER update_roundup_goals_updated_at BEFORE UPDATE ON roundup_goals
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
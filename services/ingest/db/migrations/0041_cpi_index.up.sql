@@ -0,0 +1,17 @@
+-- Consumer Price Index
+-- Created: 2026-10-17
+
+-- Monthly CPI values fetched from the Bureau of Labor Statistics, used to
+-- restate historical spending in current dollars. Shared by all users;
+-- month is the first day of the month the value is for.
+CREATE TABLE cpi_index (
+    series_id text NOT NULL,
+    month date NOT NULL,
+    value numeric NOT NULL CHECK (value > 0),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (series_id, month)
+);
+
+CREATE TRIGGER update_cpi_index_updated_at BEFORE UPDATE ON cpi_index
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,20 @@
+-- Saved report definitions
+-- Created: 2026-10-17
+
+-- Named reports a user runs again and again: a period expression such as
+-- "last month", resolved each time the report runs, transaction filters
+-- and how to group the totals.
+CREATE TABLE report_definitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    period text NOT NULL,
+    filters jsonb NOT NULL DEFAULT '{}',
+    group_by text CHECK (group_by IN ('category', 'merchant', 'account', 'expense_type', 'day', 'month')),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_report_definitions_updated_at BEFORE UPDATE ON report_definitions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,30 @@
+-- Scheduled data exports
+-- Created: 2026-10-17
+
+-- Buckets of the user's own, on S3 or Google Cloud Storage, that their
+-- transactions and account balances are written to once a day as CSV or
+-- Parquet. The access keys are encrypted by the service; kms_key_id asks
+-- S3 to encrypt the files with that KMS key in place of its own keys.
+CREATE TABLE export_destinations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider text NOT NULL CHECK (provider IN ('s3', 'gcs')),
+    bucket text NOT NULL,
+    region text,
+    prefix text NOT NULL DEFAULT '',
+    format text NOT NULL CHECK (format IN ('csv', 'parquet')),
+    access_key_id text NOT NULL,
+    secret_access_key text NOT NULL,
+    kms_key_id text,
+    enabled boolean NOT NULL DEFAULT true,
+    last_exported_at timestamptz,
+    last_error text,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_export_destinations_user ON export_destinations(user_id, created_at);
+CREATE INDEX idx_export_destinations_due ON export_destinations(last_exported_at) WHERE enabled;
+
+CREATE TRIGGER update_export_destinations_updated_at BEFORE UPDATE ON export_destinations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,13 @@
+-- Personal finance categories
+-- Created: 2026-10-17
+
+-- Plaid's personal_finance_category taxonomy, which replaces its legacy
+-- category arrays: a primary category such as FOOD_AND_DRINK and a
+-- detailed one such as FOOD_AND_DRINK_COFFEE. New syncs store what Plaid
+-- sends; rows synced before are filled in from their legacy categories by
+-- the admin category migration job.
+ALTER TABLE transactions
+    ADD COLUMN personal_finance_category text,
+    ADD COLUMN personal_finance_category_detailed text;
+
+CREATE INDEX idx_transactions_user_pfc ON transactions(user_id, personal_finance_category);
@@ -0,0 +1,38 @@
+-- Contribution limits
+-- Created: 2026-10-17
+
+-- The IRS's annual limits on contributions to tax-advantaged accounts:
+-- employee deferrals to 401(k), 403(b) and TSP plans, IRA contributions
+-- and HSA contributions by coverage. catch_up_amount is the extra allowed
+-- from catch_up_age. Operators add each year's limits once the IRS
+-- announces them.
+CREATE TABLE contribution_limits (
+    year integer NOT NULL CHECK (year BETWEEN 2000 AND 2100),
+    kind text NOT NULL CHECK (kind IN ('401k', 'ira', 'hsa_self', 'hsa_family')),
+    limit_amount numeric NOT NULL CHECK (limit_amount > 0),
+    catch_up_amount numeric NOT NULL DEFAULT 0 CHECK (catch_up_amount >= 0),
+    catch_up_age integer NOT NULL,
+    updated_at timestamptz DEFAULT now(),
+    PRIMARY KEY (year, kind)
+);
+
+CREATE TRIGGER update_contribution_limits_updated_at BEFORE UPDATE ON contribution_limits
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO contribution_limits (year, kind, limit_amount, catch_up_amount, catch_up_age) VALUES
+    (2023, '401k', 22500, 7500, 50),
+    (2023, 'ira', 6500, 1000, 50),
+    (2023, 'hsa_self', 3850, 1000, 55),
+    (2023, 'hsa_family', 7750, 1000, 55),
+    (2024, '401k', 23000, 7500, 50),
+    (2024, 'ira', 7000, 1000, 50),
+    (2024, 'hsa_self', 4150, 1000, 55),
+    (2024, 'hsa_family', 8300, 1000, 55),
+    (2025, '401k', 23500, 7500, 50),
+    (2025, 'ira', 7000, 1000, 50),
+    (2025, 'hsa_self', 4300, 1000, 55),
+    (2025, 'hsa_family', 8550, 1000, 55),
+    (2026, '401k', 24500, 8000, 50),
+    (2026, 'ira', 7500, 1100, 50),
+    (2026, 'hsa_self', 4400, 1000, 55),
+    (2026, 'hsa_family', 8750, 1000, 55);
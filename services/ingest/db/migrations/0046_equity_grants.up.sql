@@ -0,0 +1,39 @@
+-- Equity grants and vesting schedules
+-- Created: 2026-10-17
+
+-- RSU and stock option grants a user records, with the brokerage account
+-- their shares are delivered to, when known. strike_price is for options.
+CREATE TABLE equity_grants (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    symbol text NOT NULL,
+    grant_type text NOT NULL CHECK (grant_type IN ('rsu', 'option')),
+    strike_price numeric CHECK (strike_price >= 0),
+    total_shares numeric NOT NULL CHECK (total_shares > 0),
+    grant_date date NOT NULL,
+    account_id text REFERENCES accounts(id) ON DELETE SET NULL,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_equity_grants_user ON equity_grants(user_id);
+
+CREATE TRIGGER update_equity_grants_updated_at BEFORE UPDATE ON equity_grants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Each vest of a grant. Once it occurs, a vest is matched to the
+-- investment transaction delivering its shares, which is fewer than vest
+-- when shares are sold to cover taxes.
+CREATE TABLE equity_vests (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    grant_id uuid NOT NULL REFERENCES equity_grants(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    vest_date date NOT NULL,
+    shares numeric NOT NULL CHECK (shares > 0),
+    investment_transaction_id text UNIQUE REFERENCES investment_transactions(id) ON DELETE SET NULL,
+    matched_at timestamptz,
+    UNIQUE (grant_id, vest_date)
+);
+
+CREATE INDEX idx_equity_vests_user_date ON equity_vests(user_id, vest_date);
@@ -0,0 +1,24 @@
+-- Crypto staking rewards and interest
+-- Created: 2026-10-17
+
+-- Staking rewards and interest paid in crypto, as synced from exchange
+-- connectors. The fair market value at receipt is both the income to
+-- report and the cost basis of the coins received; price is per unit in
+-- USD. external_id is the exchange's ID for the payment, making syncs
+-- idempotent.
+CREATE TABLE crypto_income (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    symbol text NOT NULL,
+    kind text NOT NULL CHECK (kind IN ('staking', 'interest')),
+    quantity numeric NOT NULL CHECK (quantity > 0),
+    price numeric NOT NULL CHECK (price >= 0),
+    cost_basis numeric NOT NULL CHECK (cost_basis >= 0),
+    received_at timestamptz NOT NULL,
+    created_at timestamptz DEFAULT now(),
+    UNIQUE (user_id, source, external_id)
+);
+
+CREATE INDEX idx_crypto_income_user_received ON crypto_income(user_id, received_at);
@@ -0,0 +1,11 @@
+-- Depository account yields
+-- Created: 2026-10-17
+
+-- The annual percentage yield a depository account earns, in percent.
+-- Plaid does not report yields, so it is entered by the user ('user') or
+-- read from a rate the institution gives in the account's official name
+-- when it syncs ('provider'). Syncs never overwrite a user's APY.
+ALTER TABLE accounts
+    ADD COLUMN apy numeric CHECK (apy >= 0 AND apy <= 100),
+    ADD COLUMN apy_source text CHECK (apy_source IN ('user', 'provider')),
+    ADD COLUMN apy_updated_at timestamptz;
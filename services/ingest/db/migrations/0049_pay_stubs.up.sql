@@ -0,0 +1,30 @@
+-- Pay stubs from payroll income providers
+-- Created: 2026-10-17
+
+-- Pay stubs synced from items consented to income verification. They are
+-- matched to payroll deposits when paychecks are read, breaking net pay
+-- down into gross pay, taxes, benefits and other deductions. external_id
+-- is the provider's ID for the stub, making syncs idempotent.
+CREATE TABLE pay_stubs (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plaid_item_id uuid NOT NULL REFERENCES plaid_items(id) ON DELETE CASCADE,
+    external_id text NOT NULL,
+    employer text NOT NULL,
+    pay_date date NOT NULL,
+    period_start date,
+    period_end date,
+    gross_pay numeric NOT NULL CHECK (gross_pay >= 0),
+    taxes numeric NOT NULL CHECK (taxes >= 0),
+    benefits numeric NOT NULL CHECK (benefits >= 0),
+    other_deductions numeric NOT NULL CHECK (other_deductions >= 0),
+    net_pay numeric NOT NULL CHECK (net_pay >= 0),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now(),
+    UNIQUE (user_id, external_id)
+);
+
+CREATE INDEX idx_pay_stubs_user_date ON pay_stubs(user_id, pay_date);
+
+CREATE TRIGGER update_pay_stubs_updated_at BEFORE UPDATE ON pay_stubs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,34 @@
+-- Rental properties and their transactions
+-- Created: 2026-10-17
+
+-- Rental units a user manages through their linked accounts
+CREATE TABLE rental_properties (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    address text,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_rental_properties_user ON rental_properties(user_id);
+
+CREATE TRIGGER update_rental_properties_updated_at BEFORE UPDATE ON rental_properties
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Transactions tagged to a property, each to one, with what it is for.
+-- A mortgage payment keeps the interest it paid, the rest being
+-- principal, which is cash flow but not an expense.
+CREATE TABLE rental_transactions (
+    transaction_id text PRIMARY KEY REFERENCES transactions(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    property_id uuid NOT NULL REFERENCES rental_properties(id) ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('rent', 'repairs', 'mortgage', 'mortgage_interest', 'insurance',
+                                       'taxes', 'utilities', 'management', 'hoa', 'other')),
+    interest numeric CHECK (interest >= 0),
+    tagged_at timestamptz DEFAULT now(),
+    CHECK ((kind = 'mortgage') = (interest IS NOT NULL))
+);
+
+CREATE INDEX idx_rental_transactions_property ON rental_transactions(property_id);
+CREATE INDEX idx_rental_transactions_user ON rental_transactions(user_id);
@@ -0,0 +1,65 @@
+-- Dependent profiles, allowances and chore ledgers
+-- Created: 2026-10-17
+
+-- Children and other dependents a user keeps a pocket money ledger for.
+-- Dependents do not sign in; their parent manages them. Scopes are what
+-- of a dependent's data anyone but the parent sees, such as grantees and
+-- agents acting for the parent: its balance, its ledger entries and its
+-- allowance schedules.
+CREATE TABLE dependents (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    birth_year integer CHECK (birth_year BETWEEN 1900 AND 2100),
+    scopes text[] NOT NULL DEFAULT '{balance}'
+        CHECK (scopes <@ ARRAY['balance', 'ledger', 'allowances']::text[]),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_dependents_user ON dependents(user_id);
+
+CREATE TRIGGER update_dependents_updated_at BEFORE UPDATE ON dependents
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Allowances paid to a dependent on a schedule. next_date is the next
+-- payment still to be credited to the ledger.
+CREATE TABLE allowance_schedules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dependent_id uuid NOT NULL REFERENCES dependents(id) ON DELETE CASCADE,
+    amount numeric NOT NULL CHECK (amount > 0),
+    frequency text NOT NULL CHECK (frequency IN ('weekly', 'biweekly', 'monthly')),
+    next_date date NOT NULL,
+    description text,
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE INDEX idx_allowance_schedules_dependent ON allowance_schedules(dependent_id);
+CREATE INDEX idx_allowance_schedules_user ON allowance_schedules(user_id);
+
+CREATE TRIGGER update_allowance_schedules_updated_at BEFORE UPDATE ON allowance_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- What a dependent is owed. Amounts are positive for money credited to the
+-- dependent (allowances and chores) and negative for money paid out or
+-- spent on their behalf; adjustments may be either. Each scheduled
+-- allowance is credited once.
+CREATE TABLE dependent_ledger (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dependent_id uuid NOT NULL REFERENCES dependents(id) ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('allowance', 'chore', 'spend', 'adjustment')),
+    amount numeric NOT NULL CHECK (amount <> 0),
+    description text,
+    entry_date date NOT NULL,
+    schedule_id uuid REFERENCES allowance_schedules(id) ON DELETE SET NULL,
+    created_at timestamptz DEFAULT now(),
+    CHECK (kind NOT IN ('allowance', 'chore') OR amount > 0),
+    CHECK (kind <> 'spend' OR amount < 0),
+    UNIQUE (schedule_id, entry_date)
+);
+
+CREATE INDEX idx_dependent_ledger_dependent ON dependent_ledger(dependent_id, entry_date);
+CREATE INDEX idx_dependent_ledger_user ON dependent_ledger(user_id);
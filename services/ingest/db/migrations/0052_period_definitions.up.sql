@@ -0,0 +1,29 @@
+-- Fiscal years and custom period definitions
+-- Created: 2026-10-17
+
+-- fiscal_year_start is the month a user's fiscal year starts; fiscal years
+-- are named for the calendar year they end in. budget_period names one of
+-- the user's period definitions that budgets run on in place of calendar
+-- months.
+ALTER TABLE user_preferences
+    ADD COLUMN fiscal_year_start integer NOT NULL DEFAULT 1 CHECK (fiscal_year_start BETWEEN 1 AND 12),
+    ADD COLUMN budget_period text;
+
+-- Periods a user budgets on that are not calendar ones, such as pay
+-- periods of two weeks from a payday or months starting on the 25th. Each
+-- repeats every `every` units from anchor_date, before and after it.
+CREATE TABLE period_definitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    anchor_date date NOT NULL,
+    every integer NOT NULL CHECK (every BETWEEN 1 AND 366),
+    unit text NOT NULL CHECK (unit IN ('day', 'week', 'month')),
+    created_at timestamptz DEFAULT now(),
+    updated_at timestamptz DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_period_definitions_user_name ON period_definitions(user_id, lower(name));
+
+CREATE TRIGGER update_period_definitions_updated_at BEFORE UPDATE ON period_definitions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
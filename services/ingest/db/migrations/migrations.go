@@ -0,0 +1,11 @@
+// Package migrations embeds the versioned SQL schema migrations so the
+// ingest binary can apply them without access to the source tree. Files are
+// named NNNN_title.up.sql and applied in version order.
+package migrations
+
+import "embed"
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,398 @@
+// Package alerts evaluates user-defined alert rules against synced
+// transactions and account balances. A rule's condition is a small
+// expression over amount, category, merchant and balance fields, such as
+// `amount > 200 and account_type = "credit"`; a match raises a
+// notification and an insight.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// InsightType is the type of insights raised by alert rules
+const InsightType = "alert.rule"
+
+var (
+	// ErrNotFound is returned for a rule that does not exist or belongs to
+	// another user
+	ErrNotFound = errors.New("alert rule not found")
+	// ErrUnknownChannel is returned when a rule routes to a notification
+	// channel that does not exist or belongs to another user
+	ErrUnknownChannel = errors.New("unknown notification channel")
+)
+
+// Engine stores alert rules and evaluates them
+type Engine struct {
+	db            *database.Database
+	notifications *notifications.Store
+	insights      *insights.Store
+}
+
+// NewEngine creates a new alert rule engine
+func NewEngine(db *database.Database, notificationStore *notifications.Store, insightStore *insights.Store) *Engine {
+	return &Engine{db: db, notifications: notificationStore, insights: insightStore}
+}
+
+// CreateRule stores a rule and the channels it routes to, filling in its
+// ID, subject and timestamps. It returns ErrInvalidCondition if the
+// condition does not parse and ErrUnknownChannel if a channel is not the
+// user's.
+func (e *Engine) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	cond, err := Parse(rule.Condition)
+	if err != nil {
+		return err
+	}
+	rule.Subject = cond.Subject()
+
+	return e.db.InTx(ctx, func(ctx context.Context) error {
+		err := e.db.Writer(ctx).QueryRow(ctx, `
+			INSERT INTO alert_rules (user_id, name, condition, severity, enabled)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at, updated_at
+		`, rule.UserID, rule.Name, rule.Condition, rule.Severity, rule.Enabled).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create alert rule: %w", err)
+		}
+		return e.setChannels(ctx, rule)
+	})
+}
+
+// GetRule returns one of a user's rules
+func (e *Engine) GetRule(ctx context.Context, userID, ruleID string) (*models.AlertRule, error) {
+	rules, err := e.queryRules(ctx, "WHERE user_id = $1 AND id = $2", userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, ErrNotFound
+	}
+	return &rules[0], nil
+}
+
+// ListRules returns a user's rules, oldest first
+func (e *Engine) ListRules(ctx context.Context, userID string) ([]models.AlertRule, error) {
+	return e.queryRules(ctx, "WHERE user_id = $1", userID)
+}
+
+// UpdateRule saves a rule's name, condition, severity, enabled flag and
+// channels, as CreateRule checks them. Changing the condition forgets what
+// the rule matched before.
+func (e *Engine) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	cond, err := Parse(rule.Condition)
+	if err != nil {
+		return err
+	}
+	rule.Subject = cond.Subject()
+
+	return e.db.InTx(ctx, func(ctx context.Context) error {
+		var previous string
+		err := e.db.Writer(ctx).QueryRow(ctx, `
+			SELECT condition FROM alert_rules WHERE id = $1 AND user_id = $2 FOR UPDATE
+		`, rule.ID, rule.UserID).Scan(&previous)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query alert rule: %w", err)
+		}
+
+		err = e.db.Writer(ctx).QueryRow(ctx, `
+			UPDATE alert_rules SET name = $3, condition = $4, severity = $5, enabled = $6
+			WHERE id = $1 AND user_id = $2
+			RETURNING updated_at
+		`, rule.ID, rule.UserID, rule.Name, rule.Condition, rule.Severity, rule.Enabled).Scan(&rule.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update alert rule: %w", err)
+		}
+
+		if previous != rule.Condition {
+			if _, err := e.db.Writer(ctx).Exec(ctx, "DELETE FROM alert_matches WHERE rule_id = $1", rule.ID); err != nil {
+				return fmt.Errorf("failed to reset alert matches: %w", err)
+			}
+		}
+		return e.setChannels(ctx, rule)
+	})
+}
+
+// setChannels replaces the channels a rule routes to, within the caller's
+// transaction
+func (e *Engine) setChannels(ctx context.Context, rule *models.AlertRule) error {
+	rule.ChannelIDs = uniqueStrings(rule.ChannelIDs)
+	if _, err := e.db.Writer(ctx).Exec(ctx, "DELETE FROM alert_rule_channels WHERE rule_id = $1", rule.ID); err != nil {
+		return fmt.Errorf("failed to clear alert rule channels: %w", err)
+	}
+	if len(rule.ChannelIDs) == 0 {
+		return nil
+	}
+
+	tag, err := e.db.Writer(ctx).Exec(ctx, `
+		INSERT INTO alert_rule_channels (rule_id, channel_id)
+		SELECT $1, id FROM notification_channels
+		WHERE user_id = $2 AND id::text = ANY($3)
+	`, rule.ID, rule.UserID, rule.ChannelIDs)
+	if err != nil {
+		return fmt.Errorf("failed to route alert rule: %w", err)
+	}
+	if int(tag.RowsAffected()) != len(rule.ChannelIDs) {
+		return ErrUnknownChannel
+	}
+	return nil
+}
+
+// DeleteRule deletes one of a user's rules. Its notifications are kept.
+func (e *Engine) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	tag, err := e.db.Pool.Exec(ctx, "DELETE FROM alert_rules WHERE id = $1 AND user_id = $2", ruleID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (e *Engine) queryRules(ctx context.Context, where string, args ...interface{}) ([]models.AlertRule, error) {
+	rows, err := e.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, name, condition, severity, enabled, last_triggered_at, created_at, updated_at,
+			ARRAY(SELECT channel_id::text FROM alert_rule_channels WHERE rule_id = alert_rules.id ORDER BY channel_id)
+		FROM alert_rules `+where+`
+		ORDER BY created_at
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		var r models.AlertRule
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Name, &r.Condition, &r.Severity, &r.Enabled,
+			&r.LastTriggeredAt, &r.CreatedAt, &r.UpdatedAt, &r.ChannelIDs); err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		if cond, err := Parse(r.Condition); err == nil {
+			r.Subject = cond.Subject()
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// match is a transaction or account a rule fired for
+type match struct {
+	subjectID string
+	message   string
+	metadata  map[string]interface{}
+}
+
+// Evaluate tests a user's enabled rules against the accounts and
+// transactions of a sync and raises a notification and an insight for
+// each new match, returning how many it raised.
+//
+// A transaction fires a rule once. Pending transactions and those dated
+// before the rule was created are skipped, so backfills and the pending
+// copy of a charge don't fire it. An account fires a balance rule when it
+// starts matching and again only after it has stopped.
+func (e *Engine) Evaluate(ctx context.Context, userID string, accounts []models.PlaidAccount, transactions []models.PlaidTransaction) (int, error) {
+	rules, err := e.queryRules(ctx, "WHERE user_id = $1 AND enabled", userID)
+	if err != nil || len(rules) == 0 {
+		return 0, err
+	}
+
+	byID := make(map[string]models.PlaidAccount, len(accounts))
+	for _, acc := range accounts {
+		byID[acc.ID] = acc
+	}
+
+	raised := 0
+	for _, rule := range rules {
+		cond, err := Parse(rule.Condition)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping alert rule with invalid condition", "rule_id", rule.ID, "error", err)
+			continue
+		}
+
+		var matches []match
+		var cleared []string
+		switch cond.Subject() {
+		case SubjectTransaction:
+			since := rule.CreatedAt.UTC().Format("2006-01-02")
+			for _, txn := range transactions {
+				if txn.Pending || txn.Date < since {
+					continue
+				}
+				acc := byID[txn.AccountID]
+				if cond.Match(transactionFacts(txn, acc)) {
+					matches = append(matches, transactionMatch(txn, acc))
+				}
+			}
+		case SubjectAccount:
+			for _, acc := range accounts {
+				if cond.Match(accountFacts(acc)) {
+					matches = append(matches, accountMatch(acc))
+				} else {
+					cleared = append(cleared, acc.ID)
+				}
+			}
+		}
+
+		if len(cleared) > 0 {
+			_, err := e.db.Pool.Exec(ctx, `
+				DELETE FROM alert_matches WHERE rule_id = $1 AND subject_id = ANY($2)
+			`, rule.ID, cleared)
+			if err != nil {
+				return raised, fmt.Errorf("failed to clear alert matches: %w", err)
+			}
+		}
+		for _, m := range matches {
+			fired, err := e.fire(ctx, rule, m)
+			if err != nil {
+				return raised, err
+			}
+			if fired {
+				raised++
+			}
+		}
+	}
+	return raised, nil
+}
+
+// fire records a match and, if it is new, raises its notification and
+// insight
+func (e *Engine) fire(ctx context.Context, rule models.AlertRule, m match) (bool, error) {
+	tag, err := e.db.Pool.Exec(ctx, `
+		INSERT INTO alert_matches (rule_id, subject_id) VALUES ($1, $2)
+		ON CONFLICT (rule_id, subject_id) DO NOTHING
+	`, rule.ID, m.subjectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record alert match: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	m.metadata["rule_id"] = rule.ID
+	m.metadata["condition"] = rule.Condition
+	m.metadata["severity"] = rule.Severity
+
+	notification := &models.Notification{
+		UserID:   rule.UserID,
+		RuleID:   &rule.ID,
+		Type:     notifications.TypeAlert,
+		Title:    rule.Name,
+		Message:  m.message,
+		Metadata: m.metadata,
+	}
+	if err := e.notifications.Create(ctx, notification); err != nil {
+		return false, err
+	}
+
+	insight := &models.Insight{
+		UserID:   rule.UserID,
+		Type:     InsightType,
+		Severity: rule.Severity,
+		Title:    rule.Name,
+		Message:  m.message,
+		Metadata: m.metadata,
+	}
+	if err := e.insights.Create(ctx, insight); err != nil {
+		return false, err
+	}
+
+	_, err = e.db.Pool.Exec(ctx, "UPDATE alert_rules SET last_triggered_at = $2 WHERE id = $1", rule.ID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	return true, nil
+}
+
+func transactionFacts(txn models.PlaidTransaction, acc models.PlaidAccount) Facts {
+	return Facts{
+		Numbers: map[string]decimal.Decimal{"amount": txn.Amount},
+		Texts: map[string][]string{
+			"category":     txn.Category,
+			"merchant":     {merchantOf(txn)},
+			"account":      {acc.Name},
+			"account_type": {acc.Type},
+		},
+	}
+}
+
+func accountFacts(acc models.PlaidAccount) Facts {
+	facts := Facts{
+		Numbers: map[string]decimal.Decimal{},
+		Texts: map[string][]string{
+			"account":      {acc.Name},
+			"account_type": {acc.Type},
+		},
+	}
+	if acc.Balances.Current != nil {
+		facts.Numbers["balance"] = *acc.Balances.Current
+	}
+	if acc.Balances.Available != nil {
+		facts.Numbers["available"] = *acc.Balances.Available
+	}
+	return facts
+}
+
+func transactionMatch(txn models.PlaidTransaction, acc models.PlaidAccount) match {
+	message := fmt.Sprintf("%s at %s on %s", txn.Amount.StringFixed(2), merchantOf(txn), txn.Date)
+	if acc.Name != "" {
+		message += " (" + acc.Name + ")"
+	}
+	return match{
+		subjectID: txn.ID,
+		message:   message,
+		metadata: map[string]interface{}{
+			"transaction_id": txn.ID,
+			"account_id":     txn.AccountID,
+			"amount":         txn.Amount,
+			"merchant":       merchantOf(txn),
+			"date":           txn.Date,
+		},
+	}
+}
+
+func accountMatch(acc models.PlaidAccount) match {
+	metadata := map[string]interface{}{"account_id": acc.ID}
+	message := acc.Name + " balance changed"
+	if acc.Balances.Current != nil {
+		metadata["balance"] = *acc.Balances.Current
+		message = fmt.Sprintf("%s balance is %s", acc.Name, acc.Balances.Current.StringFixed(2))
+	}
+	if acc.Balances.Available != nil {
+		metadata["available"] = *acc.Balances.Available
+		message += fmt.Sprintf(" (%s available)", acc.Balances.Available.StringFixed(2))
+	}
+	return match{subjectID: acc.ID, message: message, metadata: metadata}
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	unique := []string{}
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+func merchantOf(txn models.PlaidTransaction) string {
+	if txn.MerchantName != nil && *txn.MerchantName != "" {
+		return *txn.MerchantName
+	}
+	return txn.Name
+}
@@ -0,0 +1,286 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidCondition is returned for conditions that do not parse
+var ErrInvalidCondition = errors.New("invalid condition")
+
+// Subjects a condition can test
+const (
+	SubjectTransaction = "transaction"
+	SubjectAccount     = "account"
+)
+
+type fieldKind int
+
+const (
+	kindNumber fieldKind = iota
+	kindText
+)
+
+type fieldSpec struct {
+	kind    fieldKind
+	subject string // empty for fields of both subjects
+}
+
+// fields are what conditions can test. Transaction amounts follow Plaid's
+// sign convention, where positive amounts are money out.
+var fields = map[string]fieldSpec{
+	"amount":       {kindNumber, SubjectTransaction},
+	"category":     {kindText, SubjectTransaction},
+	"merchant":     {kindText, SubjectTransaction},
+	"balance":      {kindNumber, SubjectAccount},
+	"available":    {kindNumber, SubjectAccount},
+	"account":      {kindText, ""},
+	"account_type": {kindText, ""},
+}
+
+// Comparison operators; ~ is a case-insensitive substring match
+var (
+	numberOps = []string{">=", "<=", "!=", ">", "<", "="}
+	textOps   = []string{"!=", "=", "~"}
+)
+
+// Condition is a parsed rule condition: comparisons joined by "and", with
+// groups of those joined by "or", such as
+//
+//	amount > 200 and account_type = "credit"
+//	balance < 500 or available < 100
+type Condition struct {
+	any     [][]comparison // any group matching, each needing all its comparisons
+	subject string
+}
+
+type comparison struct {
+	field  string
+	op     string
+	number decimal.Decimal
+	text   string
+}
+
+// Facts are the values of a transaction or account a condition is tested
+// against. Missing numbers never match; text fields may have several
+// values, such as a transaction's categories, any of which may match.
+type Facts struct {
+	Numbers map[string]decimal.Decimal
+	Texts   map[string][]string
+}
+
+// Subject returns whether the condition tests transactions or accounts
+func (c *Condition) Subject() string {
+	return c.subject
+}
+
+// Match reports whether facts satisfy the condition
+func (c *Condition) Match(facts Facts) bool {
+	for _, group := range c.any {
+		matched := true
+		for _, cmp := range group {
+			if !cmp.match(facts) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp comparison) match(facts Facts) bool {
+	if fields[cmp.field].kind == kindNumber {
+		v, ok := facts.Numbers[cmp.field]
+		if !ok {
+			return false
+		}
+		switch c := v.Cmp(cmp.number); cmp.op {
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case "=":
+			return c == 0
+		default:
+			return c != 0
+		}
+	}
+
+	values := facts.Texts[cmp.field]
+	if cmp.op == "!=" {
+		for _, v := range values {
+			if strings.EqualFold(v, cmp.text) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if cmp.op == "=" && strings.EqualFold(v, cmp.text) ||
+			cmp.op == "~" && strings.Contains(strings.ToLower(v), strings.ToLower(cmp.text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a condition, checking that every field exists, is compared
+// with an operator and value of its kind, and that the fields all belong
+// to transactions or all to accounts
+func Parse(expr string) (*Condition, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, invalid("empty")
+	}
+
+	cond := &Condition{}
+	group := []comparison{}
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 3 {
+			return nil, invalid("incomplete comparison at %q", strings.Join(tokens[i:], " "))
+		}
+		cmp, err := parseComparison(tokens[i], tokens[i+1], tokens[i+2])
+		if err != nil {
+			return nil, err
+		}
+		if err := cond.setSubject(cmp.field); err != nil {
+			return nil, err
+		}
+		group = append(group, cmp)
+		i += 3
+
+		if i == len(tokens) {
+			break
+		}
+		switch strings.ToLower(tokens[i]) {
+		case "and":
+		case "or":
+			cond.any = append(cond.any, group)
+			group = []comparison{}
+		default:
+			return nil, invalid("expected \"and\" or \"or\" before %q", tokens[i])
+		}
+		i++
+		if i == len(tokens) {
+			return nil, invalid("ends with %q", tokens[i-1])
+		}
+	}
+	cond.any = append(cond.any, group)
+
+	if cond.subject == "" {
+		return nil, invalid("must test a transaction field (amount, category, merchant) or a balance field (balance, available)")
+	}
+	return cond, nil
+}
+
+func (c *Condition) setSubject(field string) error {
+	subject := fields[field].subject
+	if subject == "" {
+		return nil
+	}
+	if c.subject != "" && c.subject != subject {
+		return invalid("cannot test both transaction and balance fields")
+	}
+	c.subject = subject
+	return nil
+}
+
+func parseComparison(field, op, value string) (comparison, error) {
+	field = strings.ToLower(field)
+	spec, ok := fields[field]
+	if !ok {
+		return comparison{}, invalid("unknown field %q", field)
+	}
+	cmp := comparison{field: field, op: op}
+
+	if spec.kind == kindNumber {
+		if !contains(numberOps, op) {
+			return comparison{}, invalid("%s must be compared with one of %s", field, strings.Join(numberOps, " "))
+		}
+		n, err := decimal.NewFromString(strings.ReplaceAll(strings.TrimPrefix(value, "$"), ",", ""))
+		if err != nil {
+			return comparison{}, invalid("%s must be compared with a number, not %q", field, value)
+		}
+		cmp.number = n
+		return cmp, nil
+	}
+
+	if !contains(textOps, op) {
+		return comparison{}, invalid("%s must be compared with one of %s", field, strings.Join(textOps, " "))
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return comparison{}, invalid("%s must be compared with a quoted string, not %s", field, value)
+	}
+	cmp.text = value[1 : len(value)-1]
+	return cmp, nil
+}
+
+// tokenize splits a condition into words, numbers, quoted strings (kept
+// with their quotes) and operators
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, invalid("unterminated string")
+			}
+			tokens = append(tokens, string(runes[i:end+1]))
+			i = end + 1
+		case strings.ContainsRune("<>=!~", r):
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' && r != '~' {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+				continue
+			}
+			if r == '!' {
+				return nil, invalid("unexpected \"!\"; use \"!=\"")
+			}
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("<>=!~\"", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCondition, fmt.Sprintf(format, args...))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// Package amortization schedules the monthly payments of a fixed-rate
+// loan such as a mortgage, and models paying it down faster: extra
+// principal each month, lump sums, and recasts, where the lender lowers
+// the payment after a lump sum so the loan still ends when it would have.
+// Interest accrues monthly on the balance at the annual rate over 12, in
+// cents; payments are principal and interest, without escrow.
+package amortization
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MonthLayout is the layout of payment months
+const MonthLayout = "2006-01"
+
+// MaxMonths bounds how long a loan is scheduled, 50 years
+const MaxMonths = 600
+
+// ErrPaymentTooLow is returned when the payment does not cover the first
+// month's interest, so the loan never amortizes
+var ErrPaymentTooLow = errors.New("monthly payment does not cover the interest")
+
+var (
+	hundred = decimal.NewFromInt(100)
+	twelve  = decimal.NewFromInt(12)
+)
+
+// Loan is a fixed-rate loan from its next payment. Rate is the annual
+// interest rate in percent; Payment is the monthly principal and interest.
+type Loan struct {
+	Principal decimal.Decimal
+	Rate      decimal.Decimal
+	Payment   decimal.Decimal
+	Start     time.Time // month of the first payment
+}
+
+// Lump is a one-off payment of principal made with a month's payment
+type Lump struct {
+	Month  string          `json:"month"` // YYYY-MM
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Plan is how a loan is paid down beyond its scheduled payments. A recast
+// re-amortizes the balance after its lump sum over the months the loan
+// had left without it.
+type Plan struct {
+	ExtraMonthly decimal.Decimal `json:"extra_monthly"`
+	Lumps        []Lump          `json:"lump_sums"`
+	Recast       *Lump           `json:"recast"`
+}
+
+// Row is one month of a schedule
+type Row struct {
+	Month     string          `json:"month"`
+	Payment   decimal.Decimal `json:"payment"` // principal and interest due
+	Interest  decimal.Decimal `json:"interest"`
+	Principal decimal.Decimal `json:"principal"`
+	Extra     decimal.Decimal `json:"extra"` // principal paid beyond the payment
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+// Schedule is a loan's payments until it is paid off
+type Schedule struct {
+	Payment       decimal.Decimal `json:"monthly_payment"`       // the payment due at the start
+	FinalPayment  decimal.Decimal `json:"final_monthly_payment"` // after any recast
+	PayoffMonth   string          `json:"payoff_month"`
+	Months        int             `json:"months"`
+	TotalInterest decimal.Decimal `json:"total_interest"`
+	TotalPaid     decimal.Decimal `json:"total_paid"`
+	Rows          []Row           `json:"schedule,omitempty"`
+}
+
+// Payment is the monthly payment paying principal off over months at an
+// annual rate in percent, rounded up to the cent
+func Payment(principal, rate decimal.Decimal, months int) decimal.Decimal {
+	if months <= 0 {
+		return principal
+	}
+	n := decimal.NewFromInt(int64(months))
+	monthly := rate.Div(hundred).Div(twelve)
+	if monthly.IsZero() {
+		return principal.Div(n).RoundUp(2)
+	}
+	// principal * r / (1 - (1 + r)^-n)
+	growth := decimal.NewFromInt(1).Add(monthly).Pow(n)
+	return principal.Mul(monthly).Mul(growth).Div(growth.Sub(decimal.NewFromInt(1))).RoundUp(2)
+}
+
+// Validate checks a loan and plan before they are scheduled
+func Validate(loan Loan, plan Plan) error {
+	if !loan.Principal.IsPositive() {
+		return errors.New("principal must be positive")
+	}
+	if loan.Rate.IsNegative() || loan.Rate.GreaterThan(decimal.NewFromInt(30)) {
+		return errors.New("interest_rate must be a percent from 0 to 30")
+	}
+	if !loan.Payment.IsPositive() {
+		return errors.New("monthly payment must be positive")
+	}
+	if plan.ExtraMonthly.IsNegative() {
+		return errors.New("extra_monthly must not be negative")
+	}
+	lumps := plan.Lumps
+	if plan.Recast != nil {
+		lumps = append(lumps[:len(lumps):len(lumps)], *plan.Recast)
+	}
+	for _, l := range lumps {
+		if _, err := time.Parse(MonthLayout, l.Month); err != nil {
+			return fmt.Errorf("month %q must be YYYY-MM", l.Month)
+		}
+		if !l.Amount.IsPositive() {
+			return errors.New("lump sum amounts must be positive")
+		}
+	}
+	return nil
+}
+
+// Amortize schedules a loan's payments under a plan until it is paid
+// off. A loan is paid off within MaxMonths or ErrPaymentTooLow is
+// returned.
+func Amortize(loan Loan, plan Plan) (*Schedule, error) {
+	monthly := loan.Rate.Div(hundred).Div(twelve)
+	first := time.Date(loan.Start.Year(), loan.Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	lumps := make(map[string]decimal.Decimal, len(plan.Lumps))
+	for _, l := range plan.Lumps {
+		lumps[l.Month] = lumps[l.Month].Add(l.Amount)
+	}
+
+	// The recast keeps the loan's original payoff month
+	var recastMonths int
+	if plan.Recast != nil {
+		base, err := Amortize(loan, Plan{})
+		if err != nil {
+			return nil, err
+		}
+		recastMonths = base.Months
+	}
+
+	s := &Schedule{Payment: loan.Payment, TotalInterest: decimal.Zero, TotalPaid: decimal.Zero}
+	balance, payment := loan.Principal, loan.Payment
+	for i := 0; balance.IsPositive(); i++ {
+		if i == MaxMonths {
+			return nil, ErrPaymentTooLow
+		}
+		month := first.AddDate(0, i, 0).Format(MonthLayout)
+		interest := balance.Mul(monthly).Round(2)
+		if i == 0 && !payment.GreaterThan(interest) {
+			return nil, ErrPaymentTooLow
+		}
+
+		row := Row{Month: month, Payment: payment, Interest: interest}
+		row.Principal = payment.Sub(interest)
+		if row.Principal.GreaterThan(balance) {
+			row.Principal = balance
+			row.Payment = balance.Add(interest)
+		}
+		balance = balance.Sub(row.Principal)
+
+		row.Extra = decimal.Min(plan.ExtraMonthly.Add(lumps[month]), balance)
+		recast := plan.Recast != nil && plan.Recast.Month == month
+		if recast {
+			row.Extra = decimal.Min(row.Extra.Add(plan.Recast.Amount), balance)
+		}
+		balance = balance.Sub(row.Extra)
+		row.Balance = balance
+
+		if recast && balance.IsPositive() && recastMonths > i+1 {
+			payment = Payment(balance, loan.Rate, recastMonths-i-1)
+		}
+
+		s.TotalInterest = s.TotalInterest.Add(interest)
+		s.TotalPaid = s.TotalPaid.Add(row.Payment).Add(row.Extra)
+		s.Rows = append(s.Rows, row)
+	}
+
+	s.Months = len(s.Rows)
+	s.FinalPayment = payment
+	if s.Months > 0 {
+		s.PayoffMonth = s.Rows[s.Months-1].Month
+	}
+	return s, nil
+}
+
+// Simulation compares a loan paid as scheduled with it paid under a plan
+type Simulation struct {
+	Baseline      *Schedule       `json:"baseline"`
+	Scenario      *Schedule       `json:"scenario"`
+	InterestSaved decimal.Decimal `json:"interest_saved"`
+	MonthsSaved   int             `json:"months_saved"`
+}
+
+// Simulate schedules a loan with and without a plan
+func Simulate(loan Loan, plan Plan) (*Simulation, error) {
+	baseline, err := Amortize(loan, Plan{})
+	if err != nil {
+		return nil, err
+	}
+	scenario, err := Amortize(loan, plan)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulation{
+		Baseline:      baseline,
+		Scenario:      scenario,
+		InterestSaved: baseline.TotalInterest.Sub(scenario.TotalInterest),
+		MonthsSaved:   baseline.Months - scenario.Months,
+	}, nil
+}
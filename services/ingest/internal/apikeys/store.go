@@ -0,0 +1,239 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// keyPrefix marks FinAgent API keys so they are recognisable in leaked-secret scans
+const keyPrefix = "fak_"
+
+var (
+	// ErrInvalidKey is returned for unknown, malformed, revoked or expired keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrNotFound is returned when an API key ID does not exist
+	ErrNotFound = errors.New("API key not found")
+)
+
+// Store issues, rotates, revokes and authenticates API keys
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates a new API key store
+func NewStore(db *database.Database) *Store {
+	return &Store{db: db}
+}
+
+// Issue creates a new key and returns its plaintext value, which is shown
+// only once. Keys issued with redact receive PII-redacted responses.
+func (s *Store) Issue(ctx context.Context, name string, scopes []string, redact bool, expiresAt *time.Time) (string, *models.APIKey, error) {
+	return s.issue(ctx, name, scopes, redact, expiresAt, nil)
+}
+
+func (s *Store) issue(ctx context.Context, name string, scopes []string, redact bool, expiresAt *time.Time, rotatedFrom *string) (string, *models.APIKey, error) {
+	for _, scope := range scopes {
+		if !auth.IsValidScope(scope) {
+			return "", nil, fmt.Errorf("unsupported scope: %s", scope)
+		}
+	}
+
+	plaintext, prefix, err := generateKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &models.APIKey{
+		Name:        name,
+		KeyPrefix:   prefix,
+		Scopes:      scopes,
+		Redact:      redact,
+		RotatedFrom: rotatedFrom,
+		ExpiresAt:   expiresAt,
+	}
+
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, redact, expires_at, rotated_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, name, prefix, hashKey(plaintext), scopes, redact, expiresAt, rotatedFrom).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return plaintext, key, nil
+}
+
+// Rotate issues a replacement key with the same name and scopes. The old key
+// keeps working for the grace period so callers can switch over.
+func (s *Store) Rotate(ctx context.Context, id string, grace time.Duration) (string, *models.APIKey, error) {
+	old, err := s.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if old.RevokedAt != nil {
+		return "", nil, fmt.Errorf("cannot rotate a revoked key")
+	}
+
+	plaintext, key, err := s.issue(ctx, old.Name, old.Scopes, old.Redact, old.ExpiresAt, &old.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1
+	`, old.ID, time.Now().Add(grace))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to expire rotated key: %w", err)
+	}
+
+	return plaintext, key, nil
+}
+
+// SetRedact changes whether a key's responses are PII-redacted
+func (s *Store) SetRedact(ctx context.Context, id string, redact bool) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"UPDATE api_keys SET redact = $2 WHERE id = $1 AND revoked_at IS NULL", id, redact)
+	if err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Revoke immediately disables a key
+func (s *Store) Revoke(ctx context.Context, id string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const keyColumns = `id, name, key_prefix, scopes, redact, rotated_from, expires_at, revoked_at, last_used_at, created_at`
+
+// Get returns key metadata by ID
+func (s *Store) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	key, err := scanKey(s.db.Pool.QueryRow(ctx, "SELECT "+keyColumns+" FROM api_keys WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+	return key, nil
+}
+
+// List returns metadata for all keys, newest first
+func (s *Store) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.Pool.Query(ctx, "SELECT "+keyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Authenticate validates a plaintext key and returns its metadata
+func (s *Store) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	prefix, ok := parsePrefix(plaintext)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	var keyHash string
+	row := s.db.Pool.QueryRow(ctx, "SELECT "+keyColumns+", key_hash FROM api_keys WHERE key_prefix = $1", prefix)
+
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.Scopes, &key.Redact, &key.RotatedFrom,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.CreatedAt, &keyHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashKey(plaintext)), []byte(keyHash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidKey
+	}
+
+	// Throttle last_used_at writes to once a minute per key
+	_, _ = s.db.Pool.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, key.ID)
+
+	return &key, nil
+}
+
+func scanKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.KeyPrefix, &key.Scopes, &key.Redact, &key.RotatedFrom,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// generateKey returns a new plaintext key of the form fak_<prefix>_<secret>
+// along with its lookup prefix
+func generateKey() (string, string, error) {
+	buf := make([]byte, 36)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	prefix := hex.EncodeToString(buf[:6])
+	secret := hex.EncodeToString(buf[6:])
+	return keyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+func parsePrefix(plaintext string) (string, bool) {
+	if !strings.HasPrefix(plaintext, keyPrefix) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(plaintext, keyPrefix), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != 12 || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func hashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,356 @@
+// Package attachments keeps receipts, warranties and other documents users
+// attach to their transactions. Files go to an object storage bucket and
+// their metadata to Postgres; they are downloaded through short-lived
+// signed URLs, so the service never streams them back itself.
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Providers
+const (
+	ProviderS3  = "s3"
+	ProviderGCS = "gcs"
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage, which
+// accepts HMAC keys in place of AWS credentials
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// MaxPerTransaction bounds the attachments on one transaction
+const MaxPerTransaction = 10
+
+var (
+	// ErrNotFound is returned for a transaction or attachment the user
+	// does not have
+	ErrNotFound = errors.New("attachment not found")
+	// ErrTooLarge is returned for a file over the size limit
+	ErrTooLarge = errors.New("attachment too large")
+	// ErrUnsupportedType is returned for a file that is not an allowed
+	// type, or whose content does not match its declared type
+	ErrUnsupportedType = errors.New("unsupported attachment type")
+	// ErrLimit is returned when a transaction has MaxPerTransaction
+	// attachments
+	ErrLimit = errors.New("attachment limit reached")
+)
+
+// allowedTypes are the content types accepted, by the extension stored
+// objects get
+var allowedTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/heic":      ".heic",
+}
+
+// attachmentID matches the UUIDs attachments are identified by, so other
+// IDs are not found rather than failing the query
+var attachmentID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ObjectStore stores attachment files in a bucket
+type ObjectStore interface {
+	// Put writes an object
+	Put(ctx context.Context, key, contentType string, body []byte) error
+	// Delete removes an object; a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads an object as filename until
+	// it expires
+	SignedURL(ctx context.Context, key, filename string, expiry time.Duration) (string, error)
+}
+
+// Options selects and configures the object store
+type Options struct {
+	Provider  string
+	Bucket    string
+	Region    string
+	Endpoint  string // S3-compatible endpoint; defaults to AWS, or GCS for the gcs provider
+	Prefix    string // key prefix within the bucket
+	MaxBytes  int64
+	URLExpiry time.Duration
+}
+
+// NewObjectStore creates the configured object store. It returns nil when
+// no provider is set and attachments are disabled.
+func NewObjectStore(ctx context.Context, opts Options) (ObjectStore, error) {
+	switch opts.Provider {
+	case "":
+		return nil, nil
+	case ProviderS3, ProviderGCS:
+		if opts.Bucket == "" {
+			return nil, fmt.Errorf("ATTACHMENT_BUCKET is required for %s attachments", opts.Provider)
+		}
+		if opts.Provider == ProviderGCS {
+			if opts.Endpoint == "" {
+				opts.Endpoint = gcsEndpoint
+			}
+			if opts.Region == "" {
+				opts.Region = "auto"
+			}
+		}
+		return NewS3(ctx, opts.Bucket, opts.Region, opts.Endpoint)
+	default:
+		return nil, fmt.Errorf("unknown attachment storage provider %q", opts.Provider)
+	}
+}
+
+// Service validates, stores and signs transaction attachments
+type Service struct {
+	db      *database.Database
+	objects ObjectStore
+	opts    Options
+}
+
+// NewService creates an attachment service storing files in objects
+func NewService(db *database.Database, objects ObjectStore, opts Options) *Service {
+	return &Service{db: db, objects: objects, opts: opts}
+}
+
+// MaxBytes is the largest file accepted
+func (s *Service) MaxBytes() int64 {
+	return s.opts.MaxBytes
+}
+
+// Upload attaches a file to one of a user's transactions. The content
+// type is sniffed from the file and must be an allowed type agreeing with
+// contentType, when one is declared.
+func (s *Service) Upload(ctx context.Context, userID, transactionID, filename, contentType string, file io.Reader) (*models.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(file, s.opts.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if int64(len(data)) > s.opts.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	sniffed := sniffType(data)
+	ext, ok := allowedTypes[sniffed]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	declared, _, _ := strings.Cut(contentType, ";")
+	declared = strings.ToLower(strings.TrimSpace(declared))
+	if declared != "" && declared != "application/octet-stream" && declared != sniffed {
+		return nil, ErrUnsupportedType
+	}
+
+	var count int
+	err = s.db.Pool.QueryRow(ctx, `
+		SELECT count(a.id) FROM transactions t
+		LEFT JOIN transaction_attachments a ON a.transaction_id = t.id
+		WHERE t.id = $1 AND t.user_id = $2 AND t.deleted_at IS NULL
+		GROUP BY t.id
+	`, transactionID, userID).Scan(&count)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction: %w", err)
+	}
+	if count >= MaxPerTransaction {
+		return nil, ErrLimit
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+	key := path.Join(s.opts.Prefix, userID, hex.EncodeToString(token)+ext)
+	if err := s.objects.Put(ctx, key, sniffed, data); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := &models.Attachment{
+		TransactionID: transactionID,
+		Filename:      cleanFilename(filename, ext),
+		ContentType:   sniffed,
+		SizeBytes:     int64(len(data)),
+		SHA256:        hex.EncodeToString(sum[:]),
+	}
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO transaction_attachments (user_id, transaction_id, object_key, filename, content_type, size_bytes, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, userID, transactionID, key, attachment.Filename, attachment.ContentType, attachment.SizeBytes,
+		attachment.SHA256).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		if delErr := s.objects.Delete(context.Background(), key); delErr != nil {
+			slog.ErrorContext(ctx, "Failed to remove orphaned attachment", "key", key, "error", delErr)
+		}
+		return nil, fmt.Errorf("failed to record attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// List returns the attachments on one of a user's transactions, oldest
+// first, each with a signed download URL
+func (s *Service) List(ctx context.Context, userID, transactionID string) ([]models.Attachment, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, transaction_id, object_key, filename, content_type, size_bytes, sha256, created_at
+		FROM transaction_attachments
+		WHERE user_id = $1 AND transaction_id = $2
+		ORDER BY created_at
+	`, userID, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		var key string
+		if err := rows.Scan(&a.ID, &a.TransactionID, &key, &a.Filename, &a.ContentType,
+			&a.SizeBytes, &a.SHA256, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		if err := s.sign(ctx, &a, key); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// Get returns one of a user's attachments with a signed download URL, or
+// ErrNotFound
+func (s *Service) Get(ctx context.Context, userID, transactionID, id string) (*models.Attachment, error) {
+	if !attachmentID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	var a models.Attachment
+	var key string
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT id, transaction_id, object_key, filename, content_type, size_bytes, sha256, created_at
+		FROM transaction_attachments
+		WHERE id = $1 AND user_id = $2 AND transaction_id = $3
+	`, id, userID, transactionID).Scan(&a.ID, &a.TransactionID, &key, &a.Filename,
+		&a.ContentType, &a.SizeBytes, &a.SHA256, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachment: %w", err)
+	}
+	if err := s.sign(ctx, &a, key); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Delete removes one of a user's attachments and its file, or returns
+// ErrNotFound
+func (s *Service) Delete(ctx context.Context, userID, transactionID, id string) error {
+	if !attachmentID.MatchString(id) {
+		return ErrNotFound
+	}
+	var key string
+	err := s.db.Pool.QueryRow(ctx, `
+		DELETE FROM transaction_attachments
+		WHERE id = $1 AND user_id = $2 AND transaction_id = $3
+		RETURNING object_key
+	`, id, userID, transactionID).Scan(&key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if err := s.objects.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete attachment file: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser removes the files of all of a user's attachments, before the
+// user's rows are deleted
+func (s *Service) DeleteUser(ctx context.Context, userID string) error {
+	rows, err := s.db.Pool.Query(ctx,
+		"SELECT object_key FROM transaction_attachments WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to query attachments: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query attachments: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.objects.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete attachment file: %w", err)
+		}
+	}
+	return nil
+}
+
+// sign sets an attachment's download URL and its expiry
+func (s *Service) sign(ctx context.Context, a *models.Attachment, key string) error {
+	url, err := s.objects.SignedURL(ctx, key, a.Filename, s.opts.URLExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to sign attachment URL: %w", err)
+	}
+	expires := time.Now().Add(s.opts.URLExpiry).UTC()
+	a.URL = url
+	a.URLExpiresAt = &expires
+	return nil
+}
+
+// sniffType detects a file's content type from its first bytes.
+// net/http does not know HEIC, so its ftyp brands are checked here.
+func sniffType(data []byte) string {
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		switch string(data[8:12]) {
+		case "heic", "heix", "mif1", "msf1":
+			return "image/heic"
+		}
+	}
+	detected, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return detected
+}
+
+// cleanFilename keeps the base name of an uploaded file, without path or
+// control characters, falling back to a generic name with ext
+func cleanFilename(name, ext string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment" + ext
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
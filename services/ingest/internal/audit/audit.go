@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/database"
+)
+
+// Audited actions
+const (
+	ActionUserRegistered    = "user.registered"
+	ActionProfileUpdated    = "user.profile_updated"
+	ActionDataExport        = "user.data_export"
+	ActionExportDestination = "user.export_destination_added"
+	ActionDeletionRequested = "user.deletion_requested"
+	ActionDeletionCancelled = "user.deletion_cancelled"
+	ActionDeletionCompleted = "user.deletion_completed"
+	ActionTradingLocked     = "security.trading_locked"
+	ActionTradingUnlocked   = "security.trading_unlocked"
+	ActionSnapshotExported  = "admin.snapshot_exported"
+	ActionSnapshotRestored  = "admin.snapshot_restored"
+	ActionHouseholdCreated  = "household.created"
+	ActionHouseholdDeleted  = "household.deleted"
+	ActionMemberInvited     = "household.member_invited"
+	ActionMemberJoined      = "household.member_joined"
+	ActionMemberRemoved     = "household.member_removed"
+	ActionConsentUpdated    = "plaid.consent_updated"
+	ActionProcessorToken    = "plaid.processor_token_created"
+	ActionTransferConfirmed = "plaid.transfer_confirmed"
+)
+
+// ActorSystem identifies actions taken by background workers
+const ActorSystem = "system"
+
+// Entry is a single audit record
+type Entry struct {
+	Action       string
+	TargetUserID string
+	Metadata     map[string]interface{}
+}
+
+// Logger writes audit records
+type Logger struct {
+	db *database.Database
+}
+
+// NewLogger creates a new audit logger
+func NewLogger(db *database.Database) *Logger {
+	return &Logger{db: db}
+}
+
+// Record stores an entry attributed to the caller in ctx
+func (l *Logger) Record(ctx context.Context, entry Entry) error {
+	return l.RecordAs(ctx, Actor(ctx), entry)
+}
+
+// RecordAs stores an entry attributed to an explicit actor
+func (l *Logger) RecordAs(ctx context.Context, actor string, entry Entry) error {
+	var target *string
+	if entry.TargetUserID != "" {
+		target = &entry.TargetUserID
+	}
+
+	_, err := l.db.Pool.Exec(ctx, `
+		INSERT INTO audit_log (actor, action, target_user_id, metadata)
+		VALUES ($1, $2, $3, $4)
+	`, actor, entry.Action, target, entry.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Actor describes the authenticated caller in ctx for the audit log
+func Actor(ctx context.Context) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return "anonymous"
+	}
+	if principal.IsService() {
+		return "api_key:" + principal.APIKeyID
+	}
+	return "user:" + principal.UserID
+}
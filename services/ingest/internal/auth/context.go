@@ -0,0 +1,43 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+// Principal is the authenticated caller of a request: either an end user
+// (from a JWT) or an internal service (from an API key)
+type Principal struct {
+	UserID   string // internal users.id
+	AuthID   string // identity provider subject (users.auth_id)
+	Claims   map[string]interface{}
+	APIKeyID string
+	Name     string
+	Scopes   []string
+	TokenID  string // identifies the user's login session
+
+	// FromCookie is set when the token came from the session cookie rather
+	// than an Authorization header, making the request subject to CSRF checks
+	FromCookie bool
+
+	// Redact is set for API keys whose responses must have PII masked
+	Redact bool
+}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// UserIDFromContext returns the authenticated user ID, or "" if unauthenticated
+func UserIDFromContext(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.UserID
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWKS caches RSA public keys fetched from a JSON Web Key Set endpoint
+type JWKS struct {
+	url        string
+	refresh    time.Duration
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewJWKS creates a JWKS cache that refetches keys every refresh interval
+func NewJWKS(url string, refresh time.Duration) *JWKS {
+	return &JWKS{
+		url:        url,
+		refresh:    refresh,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Key returns the public key for kid, refetching the key set if it is stale
+// or the kid is unknown (to pick up rotated keys)
+func (j *JWKS) Key(kid string) (*rsa.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > j.refresh
+	j.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+
+	if err := j.fetch(); err != nil {
+		if ok {
+			// Serve the cached key if the endpoint is temporarily unavailable
+			return key, nil
+		}
+		return nil, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id: %s", kid)
+}
+
+func (j *JWKS) fetch() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Another goroutine may have refreshed while we waited for the lock
+	if time.Since(j.fetchedAt) < time.Second {
+		return nil
+	}
+
+	resp, err := j.httpClient.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := parseRSAKey(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func parseRSAKey(k jsonWebKey) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	e := new(big.Int).SetBytes(eBytes)
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("exponent too large")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(e.Int64()),
+	}, nil
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for any token that fails verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// Options configures JWT verification
+type Options struct {
+	HMACSecret string // enables HS256
+	JWKSURL    string // enables RS256 with keys fetched from a JWKS endpoint
+	Issuer     string
+	Audience   string
+	UserClaim  string // claim holding the user ID, defaults to "sub"
+}
+
+// Verifier validates JWTs and extracts the caller's user ID
+type Verifier struct {
+	hmacSecret []byte
+	jwks       *JWKS
+	issuer     string
+	audience   string
+	userClaim  string
+}
+
+// NewVerifier creates a verifier. It returns nil when neither an HMAC secret
+// nor a JWKS URL is configured, meaning authentication is disabled.
+func NewVerifier(opts Options) (*Verifier, error) {
+	if opts.HMACSecret == "" && opts.JWKSURL == "" {
+		return nil, nil
+	}
+
+	if opts.HMACSecret != "" && len(opts.HMACSecret) < 32 {
+		return nil, fmt.Errorf("JWT HMAC secret must be at least 32 bytes")
+	}
+
+	v := &Verifier{
+		issuer:    opts.Issuer,
+		audience:  opts.Audience,
+		userClaim: opts.UserClaim,
+	}
+	if v.userClaim == "" {
+		v.userClaim = "sub"
+	}
+	if opts.HMACSecret != "" {
+		v.hmacSecret = []byte(opts.HMACSecret)
+	}
+	if opts.JWKSURL != "" {
+		v.jwks = NewJWKS(opts.JWKSURL, 10*time.Minute)
+	}
+
+	return v, nil
+}
+
+// Verify parses and validates a token, returning the principal it identifies.
+// The principal's UserID is left for the caller to resolve from AuthID.
+func (v *Verifier) Verify(tokenString string) (*Principal, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.validMethods()),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if v.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(v.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, v.keyFunc, parserOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	authID, ok := claims[v.userClaim].(string)
+	if !ok || authID == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.userClaim)
+	}
+
+	return &Principal{
+		AuthID:  authID,
+		Claims:  claims,
+		TokenID: tokenID(claims, tokenString),
+	}, nil
+}
+
+// tokenID identifies the login session a token belongs to: the sid claim
+// when the identity provider sets one, so refreshed tokens share a session,
+// else the jti claim, else the token itself. It is hashed so raw tokens are
+// never stored.
+func tokenID(claims jwt.MapClaims, token string) string {
+	id := token
+	if sid, ok := claims["sid"].(string); ok && sid != "" {
+		id = "sid:" + sid
+	} else if jti, ok := claims["jti"].(string); ok && jti != "" {
+		id = "jti:" + jti
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func (v *Verifier) validMethods() []string {
+	var methods []string
+	if v.hmacSecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if v.jwks != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	return methods
+}
+
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.hmacSecret == nil {
+			return nil, fmt.Errorf("HS256 tokens are not accepted")
+		}
+		return v.hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		if v.jwks == nil {
+			return nil, fmt.Errorf("RS256 tokens are not accepted")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.jwks.Key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
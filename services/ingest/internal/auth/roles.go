@@ -0,0 +1,32 @@
+package auth
+
+// Roles a user can hold over another user's data. Owners have full access to
+// their own data; viewers and advisors are read-only grantees.
+const (
+	RoleOwner   = "owner"
+	RoleAdvisor = "advisor"
+	RoleViewer  = "viewer"
+)
+
+// roleRank orders roles so that higher roles include the access of lower ones
+var roleRank = map[string]int{
+	RoleViewer:  1,
+	RoleAdvisor: 2,
+	RoleOwner:   3,
+}
+
+// IsGrantableRole checks if a role can be granted to another user
+func IsGrantableRole(role string) bool {
+	return role == RoleViewer || role == RoleAdvisor
+}
+
+// RoleAllows reports whether a holder of role has at least the required role.
+// Viewers see accounts and transactions, advisors additionally see
+// investments, and only owners can make changes or trade.
+func RoleAllows(role, required string) bool {
+	held, ok := roleRank[role]
+	if !ok {
+		return false
+	}
+	return held >= roleRank[required]
+}
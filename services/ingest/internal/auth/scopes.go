@@ -0,0 +1,38 @@
+package auth
+
+// Scopes that can be granted to service principals
+const (
+	ScopeRead     = "read"
+	ScopeTrade    = "trade"
+	ScopeGrants   = "grants"
+	ScopePrivacy  = "privacy"
+	ScopeProfile  = "profile"  // registering users, linking institutions, and changing profiles, preferences and webhook subscriptions
+	ScopePayments = "payments" // issuing Plaid processor tokens to money movement partners
+	ScopeAdmin    = "admin"
+)
+
+// IsValidScope checks if a scope can be granted
+func IsValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeTrade || scope == ScopeGrants || scope == ScopePrivacy || scope == ScopeProfile || scope == ScopePayments || scope == ScopeAdmin
+}
+
+// HasScope reports whether the principal may perform actions needing scope.
+// End users authenticated by JWT act on their own data with every scope but
+// admin, which is kept for operators; service principals are limited to the
+// scopes on their API key.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.IsService() {
+		return scope != ScopeAdmin
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsService reports whether the principal is an API key rather than an end user
+func (p *Principal) IsService() bool {
+	return p.APIKeyID != ""
+}
@@ -0,0 +1,109 @@
+// Package breaker guards calls to upstream providers with circuit
+// breakers. After enough consecutive failures a breaker opens and rejects
+// calls outright, so an outage fails fast instead of tying up goroutines
+// and database connections waiting on timeouts. Once open for a while it
+// lets a few probe calls through and closes again if they succeed.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/metrics"
+	"github.com/sony/gobreaker"
+)
+
+// Breaker states, as reported by State
+const (
+	StateClosed   = "closed"
+	StateHalfOpen = "half-open"
+	StateOpen     = "open"
+)
+
+// ErrOpen is returned for calls rejected while a breaker is open, or while
+// it is half-open and its probes are already in flight
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Options configures one provider's breaker
+type Options struct {
+	FailureThreshold int           // consecutive failures that open the breaker
+	OpenTimeout      time.Duration // how long it stays open before probing
+	HalfOpenProbes   int           // calls let through while half-open
+}
+
+// Breaker is the circuit breaker of one upstream provider
+type Breaker struct {
+	name string
+	cb   *gobreaker.TwoStepCircuitBreaker
+}
+
+// New creates a closed breaker for the named provider
+func New(name string, opts Options) *Breaker {
+	if opts.FailureThreshold < 1 {
+		opts.FailureThreshold = 1
+	}
+	if opts.HalfOpenProbes < 1 {
+		opts.HalfOpenProbes = 1
+	}
+
+	metrics.SetBreakerState(name, 0)
+	return &Breaker{
+		name: name,
+		cb: gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+			Name:        name,
+			MaxRequests: uint32(opts.HalfOpenProbes),
+			Timeout:     opts.OpenTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= uint32(opts.FailureThreshold)
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				slog.Warn("Circuit breaker changed state", "provider", name, "from", stateName(from), "to", stateName(to))
+				metrics.SetBreakerState(name, int(to))
+			},
+		}),
+	}
+}
+
+// Allow asks to make a call. It returns ErrOpen if the call is rejected;
+// otherwise done must be called with the call's result. Cancelled calls
+// count neither way, since the provider is not at fault.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	report, err := b.cb.Allow()
+	if err != nil {
+		metrics.ObserveBreakerRejection(b.name)
+		return nil, fmt.Errorf("%s: %w", b.name, ErrOpen)
+	}
+	return func(err error) {
+		if errors.Is(err, context.Canceled) {
+			// gobreaker has no way to drop a call, and a half-open breaker
+			// waits for every probe it let through, so treat it as a success
+			report(true)
+			return
+		}
+		report(err == nil)
+	}, nil
+}
+
+// Name is the provider the breaker guards
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State is the breaker's current state
+func (b *Breaker) State() string {
+	return stateName(b.cb.State())
+}
+
+func stateName(state gobreaker.State) string {
+	switch state {
+	case gobreaker.StateOpen:
+		return StateOpen
+	case gobreaker.StateHalfOpen:
+		return StateHalfOpen
+	default:
+		return StateClosed
+	}
+}
@@ -0,0 +1,57 @@
+// Package buildinfo identifies the running build. The git SHA and build
+// time are set at link time:
+//
+//	go build -ldflags "-X github.com/finagent/ingest/internal/buildinfo.GitSHA=$(git rev-parse HEAD) \
+//	  -X github.com/finagent/ingest/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Binaries built without them fall back to the VCS details Go stamps in
+// when building a module checkout, with the commit time as build time.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X
+var (
+	GitSHA    string
+	BuildTime string
+)
+
+// Info describes the running build
+type Info struct {
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"` // built from a checkout with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build's details, with "unknown" for any that
+// were not recorded
+func Get() Info {
+	info := Info{GitSHA: GitSHA, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.GitSHA == "" {
+					info.GitSHA = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.GitSHA == "" {
+		info.GitSHA = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
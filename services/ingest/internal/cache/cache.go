@@ -0,0 +1,88 @@
+// Package cache is a Redis cache-aside layer for per-user read results
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type bypassKey struct{}
+
+// WithBypass marks ctx so reads skip cached entries and refresh them
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// Bypassed reports whether ctx was marked with WithBypass
+func Bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// Cache stores JSON-encoded read results per user and endpoint. All of a
+// user's entries live in one Redis hash, so Invalidate is a single DEL.
+type Cache struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// New creates a cache whose entries expire after ttl
+func New(redisClient *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{redis: redisClient, ttl: ttl}
+}
+
+func userKey(userID string) string {
+	return fmt.Sprintf("cache:user:%s", userID)
+}
+
+// Get decodes the cached entry for userID and endpoint into dest, reporting
+// whether there was one. Bypassed contexts always miss.
+func (c *Cache) Get(ctx context.Context, userID, endpoint string, dest interface{}) (bool, error) {
+	if Bypassed(ctx) {
+		return false, nil
+	}
+
+	data, err := c.redis.HGet(ctx, userKey(userID), endpoint).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	return true, nil
+}
+
+// Set caches value for userID and endpoint. The user's entries expire
+// together, ttl after the latest write.
+func (c *Cache) Set(ctx context.Context, userID, endpoint string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	key := userKey(userID)
+	pipe := c.redis.TxPipeline()
+	pipe.HSet(ctx, key, endpoint, data)
+	pipe.Expire(ctx, key, c.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// Invalidate drops every cached entry for userID
+func (c *Cache) Invalidate(ctx context.Context, userID string) error {
+	if err := c.redis.Del(ctx, userKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package capture keeps a sample of recent request and response payloads
+// in memory for debugging malformed client requests, such as an agent
+// sending the wrong shape of JSON, without packet capture. Payloads are
+// redacted of PII and credentials before they are kept, and each instance
+// keeps only its own most recent captures.
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/finagent/ingest/internal/redact"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Options configures capturing
+type Options struct {
+	SampleRate   float64  // share of requests captured; 0 disables capturing
+	Size         int      // captures kept, oldest dropped first
+	MaxBodyBytes int      // bytes kept of each request and response body
+	SkipPrefixes []string // paths never captured
+}
+
+// Redaction levels of a captured body
+const (
+	RedactionFull    = "full"    // JSON redacted field by field
+	RedactionPartial = "partial" // unparseable; digit runs masked only
+	RedactionNone    = "none"    // empty body
+)
+
+// Body is a captured request or response body
+type Body struct {
+	ContentType string          `json:"content_type,omitempty"`
+	Size        int             `json:"size"`
+	Truncated   bool            `json:"truncated"`
+	Redaction   string          `json:"redaction"`
+	JSON        json.RawMessage `json:"json,omitempty"`
+	Text        string          `json:"text,omitempty"`
+}
+
+// Entry is one captured request and its response
+type Entry struct {
+	ID         uint64            `json:"id"`
+	Time       time.Time         `json:"time"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Method     string            `json:"method"`
+	Route      string            `json:"route,omitempty"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Status     int               `json:"status"`
+	DurationMS float64           `json:"duration_ms"`
+	Request    Body              `json:"request"`
+	Response   Body              `json:"response"`
+}
+
+// keptHeaders are the request headers worth seeing when debugging a
+// client; credentials and cookies are never kept
+var keptHeaders = []string{"Content-Type", "Content-Length", "User-Agent", "Accept", "Accept-Encoding", "X-Request-Id"}
+
+// digitRuns finds account, card and phone numbers in bodies that could not
+// be redacted field by field
+var digitRuns = regexp.MustCompile(`\d{4,}`)
+
+// Recorder samples requests into a ring buffer
+type Recorder struct {
+	opts   Options
+	nextID atomic.Uint64
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+}
+
+// New creates a recorder, or returns nil when capturing is disabled
+func New(opts Options) *Recorder {
+	if opts.SampleRate <= 0 || opts.Size <= 0 {
+		return nil
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 16 << 10
+	}
+	return &Recorder{opts: opts, entries: make([]Entry, 0, opts.Size)}
+}
+
+// Middleware captures the sampled share of requests. It belongs after
+// response compression, so it sees bodies as handlers wrote them.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	if rec == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.skipped(r.URL.Path) || rand.Float64() >= rec.opts.SampleRate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		reqBody := &limitedBuffer{limit: rec.opts.MaxBodyBytes}
+		if r.Body != nil {
+			r.Body = readCloser{Reader: io.TeeReader(r.Body, reqBody), Closer: r.Body}
+		}
+		respBody := &limitedBuffer{limit: rec.opts.MaxBodyBytes}
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(respBody)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		entry := Entry{
+			Time:       start.UTC(),
+			RequestID:  chimiddleware.GetReqID(r.Context()),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Headers:    make(map[string]string),
+			Status:     status,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Request:    captureBody(r.Header.Get("Content-Type"), reqBody),
+			Response:   captureBody(ww.Header().Get("Content-Type"), respBody),
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.Route = rctx.RoutePattern()
+		}
+		for _, name := range keptHeaders {
+			if v := r.Header.Get(name); v != "" {
+				entry.Headers[name] = v
+			}
+		}
+		rec.add(entry)
+	})
+}
+
+func (rec *Recorder) skipped(path string) bool {
+	for _, prefix := range rec.opts.SkipPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rec *Recorder) add(entry Entry) {
+	entry.ID = rec.nextID.Add(1)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.entries) < rec.opts.Size {
+		rec.entries = append(rec.entries, entry)
+		return
+	}
+	rec.entries[rec.next] = entry
+	rec.next = (rec.next + 1) % rec.opts.Size
+}
+
+// Filter selects captures; zero fields match everything
+type Filter struct {
+	MinStatus  int    // e.g. 400 for failed requests only
+	PathPrefix string // e.g. /read/
+	RequestID  string
+	Limit      int
+}
+
+// Entries returns the captures matching f, newest first
+func (rec *Recorder) Entries(f Filter) []Entry {
+	entries := []Entry{}
+	if rec == nil {
+		return entries
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for i := len(rec.entries) - 1; i >= 0; i-- {
+		e := rec.entries[(rec.next+i)%len(rec.entries)]
+		if e.Status < f.MinStatus || !strings.HasPrefix(e.Path, f.PathPrefix) ||
+			(f.RequestID != "" && e.RequestID != f.RequestID) {
+			continue
+		}
+		entries = append(entries, e)
+		if f.Limit > 0 && len(entries) == f.Limit {
+			break
+		}
+	}
+	return entries
+}
+
+// Clear drops every capture
+func (rec *Recorder) Clear() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = rec.entries[:0]
+	rec.next = 0
+}
+
+// Enabled reports whether requests are being captured
+func (rec *Recorder) Enabled() bool {
+	return rec != nil
+}
+
+// SampleRate is the share of requests captured
+func (rec *Recorder) SampleRate() float64 {
+	if rec == nil {
+		return 0
+	}
+	return rec.opts.SampleRate
+}
+
+// captureBody redacts a body for keeping. JSON is redacted field by field;
+// a body that does not parse, often the very thing being debugged, is kept
+// as text with digit runs masked. Other content types are only sized.
+func captureBody(contentType string, buf *limitedBuffer) Body {
+	body := Body{ContentType: contentType, Size: buf.size, Truncated: buf.size > buf.Len(), Redaction: RedactionNone}
+	if buf.Len() == 0 {
+		return body
+	}
+
+	ct := strings.ToLower(contentType)
+	if !body.Truncated {
+		if data, err := redact.SecretsJSON(buf.Bytes()); err == nil {
+			body.Redaction = RedactionFull
+			body.JSON = data
+			return body
+		}
+	}
+	if ct == "" || strings.Contains(ct, "json") || strings.HasPrefix(ct, "text/") {
+		body.Redaction = RedactionPartial
+		body.Text = digitRuns.ReplaceAllString(buf.String(), redact.MaskedNumber)
+	}
+	return body
+}
+
+// limitedBuffer keeps the first limit bytes written to it and counts the
+// rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+	size  int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.size += len(p)
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,140 @@
+// Package categories moves transactions from Plaid's legacy category
+// arrays to its personal finance category taxonomy
+package categories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/jobs"
+)
+
+// migrateBatchSize bounds the rows one update of the migration writes
+const migrateBatchSize = 5000
+
+// legacyOnly selects transactions Plaid sent without a personal finance
+// category, whose category is the migration's to set. Those Plaid
+// categorized itself are left alone.
+const legacyOnly = `COALESCE(jsonb_typeof(raw->'personal_finance_category'), 'null') <> 'object'`
+
+// Report counts the transactions a migration changes, or with DryRun
+// would change
+type Report struct {
+	DryRun   bool     `json:"dry_run"`
+	Scanned  int64    `json:"scanned"`  // transactions without a category from Plaid
+	Changed  int64    `json:"changed"`  // transactions whose category changes
+	Unmapped int64    `json:"unmapped"` // transactions whose legacy categories have no mapping
+	Changes  []Change `json:"changes"`  // by legacy category, most transactions first
+}
+
+// Change is the transactions of one legacy category moving from one
+// personal finance category, or none, to another
+type Change struct {
+	Legacy []string `json:"legacy_category"`
+	From   *string  `json:"from,omitempty"`
+	To     Category `json:"to"`
+	Count  int64    `json:"count"`
+}
+
+// Service plans and runs category migrations
+type Service struct {
+	db   *database.Database
+	jobs *jobs.Manager
+}
+
+// NewService creates a category migration service
+func NewService(db *database.Database, jobManager *jobs.Manager) *Service {
+	return &Service{db: db, jobs: jobManager}
+}
+
+// Queue enqueues a migration job and returns its ID
+func (s *Service) Queue(ctx context.Context) (string, error) {
+	return s.jobs.Enqueue(ctx, jobs.Params{Type: jobs.TypeCategoryMigration})
+}
+
+// Plan reports what a migration would change without changing anything
+func (s *Service) Plan(ctx context.Context) (*Report, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT category, personal_finance_category_detailed, count(*)
+		FROM transactions
+		WHERE `+legacyOnly+`
+		GROUP BY 1, 2
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction categories: %w", err)
+	}
+	defer rows.Close()
+
+	report := &Report{DryRun: true, Changes: []Change{}}
+	for rows.Next() {
+		var change Change
+		if err := rows.Scan(&change.Legacy, &change.From, &change.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction categories: %w", err)
+		}
+		report.Scanned += change.Count
+
+		to, ok := FromLegacy(change.Legacy)
+		if !ok {
+			report.Unmapped += change.Count
+			continue
+		}
+		if change.From != nil && *change.From == to.Detailed {
+			continue
+		}
+		change.To = to
+		report.Changed += change.Count
+		report.Changes = append(report.Changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transaction categories: %w", err)
+	}
+
+	sort.Slice(report.Changes, func(i, j int) bool {
+		return report.Changes[i].Count > report.Changes[j].Count
+	})
+	return report, nil
+}
+
+// Migrate sets the personal finance category of every transaction Plaid
+// did not categorize from its legacy categories, in batches, and reports
+// the transactions changed. Running it again changes only transactions
+// synced or recategorized since.
+func (s *Service) Migrate(ctx context.Context, progress *jobs.Progress) (*Report, error) {
+	report, err := s.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = false
+	report.Changed = 0
+
+	for i := range report.Changes {
+		change := &report.Changes[i]
+		change.Count = 0
+		for {
+			tag, err := s.db.Pool.Exec(ctx, `
+				UPDATE transactions
+				SET personal_finance_category = $1, personal_finance_category_detailed = $2
+				WHERE id IN (
+					SELECT id FROM transactions
+					WHERE category = $3
+					  AND personal_finance_category_detailed IS NOT DISTINCT FROM $4
+					  AND `+legacyOnly+`
+					LIMIT $5)
+			`, change.To.Primary, change.To.Detailed, change.Legacy, change.From, migrateBatchSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to migrate transaction categories: %w", err)
+			}
+			change.Count += tag.RowsAffected()
+			report.Changed += tag.RowsAffected()
+			if tag.RowsAffected() < migrateBatchSize {
+				break
+			}
+		}
+		if progress != nil {
+			progress.Update(ctx, (i+1)*100/len(report.Changes), int(report.Changed))
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,121 @@
+package categories
+
+import "strings"
+
+// Category is a personal finance category: a primary category and the
+// detailed one within it
+type Category struct {
+	Primary  string `json:"primary"`
+	Detailed string `json:"detailed"`
+}
+
+// legacy maps Plaid's legacy category hierarchies, joined with ">", to the
+// personal finance category taxonomy. A hierarchy not listed takes the
+// category of its longest listed prefix.
+var legacy = map[string]Category{
+	"Bank Fees":                                            {"BANK_FEES", "BANK_FEES_OTHER_BANK_FEES"},
+	"Bank Fees>ATM":                                        {"BANK_FEES", "BANK_FEES_ATM_FEES"},
+	"Bank Fees>Foreign Transaction":                        {"BANK_FEES", "BANK_FEES_FOREIGN_TRANSACTION_FEES"},
+	"Bank Fees>Insufficient Funds":                         {"BANK_FEES", "BANK_FEES_INSUFFICIENT_FUNDS"},
+	"Bank Fees>Interest Charged":                           {"BANK_FEES", "BANK_FEES_INTEREST_CHARGE"},
+	"Bank Fees>Overdraft":                                  {"BANK_FEES", "BANK_FEES_OVERDRAFT_FEES"},
+	"Cash Advance":                                         {"TRANSFER_IN", "TRANSFER_IN_CASH_ADVANCES_AND_LOANS"},
+	"Community":                                            {"GOVERNMENT_AND_NON_PROFIT", "GOVERNMENT_AND_NON_PROFIT_OTHER_GOVERNMENT_AND_NON_PROFIT"},
+	"Community>Animal Shelter":                             {"GOVERNMENT_AND_NON_PROFIT", "GOVERNMENT_AND_NON_PROFIT_DONATIONS"},
+	"Community>Day Care and Preschools":                    {"GENERAL_SERVICES", "GENERAL_SERVICES_CHILDCARE"},
+	"Community>Education":                                  {"GENERAL_SERVICES", "GENERAL_SERVICES_EDUCATION"},
+	"Community>Government Departments and Agencies":        {"GOVERNMENT_AND_NON_PROFIT", "GOVERNMENT_AND_NON_PROFIT_GOVERNMENT_DEPARTMENTS_AND_AGENCIES"},
+	"Community>Religious":                                  {"GOVERNMENT_AND_NON_PROFIT", "GOVERNMENT_AND_NON_PROFIT_DONATIONS"},
+	"Food and Drink":                                       {"FOOD_AND_DRINK", "FOOD_AND_DRINK_OTHER_FOOD_AND_DRINK"},
+	"Food and Drink>Bar":                                   {"FOOD_AND_DRINK", "FOOD_AND_DRINK_BEER_WINE_AND_LIQUOR"},
+	"Food and Drink>Coffee":                                {"FOOD_AND_DRINK", "FOOD_AND_DRINK_COFFEE"},
+	"Food and Drink>Groceries":                             {"FOOD_AND_DRINK", "FOOD_AND_DRINK_GROCERIES"},
+	"Food and Drink>Restaurants":                           {"FOOD_AND_DRINK", "FOOD_AND_DRINK_RESTAURANT"},
+	"Food and Drink>Restaurants>Coffee Shop":               {"FOOD_AND_DRINK", "FOOD_AND_DRINK_COFFEE"},
+	"Food and Drink>Restaurants>Fast Food":                 {"FOOD_AND_DRINK", "FOOD_AND_DRINK_FAST_FOOD"},
+	"Healthcare":                                           {"MEDICAL", "MEDICAL_OTHER_MEDICAL"},
+	"Healthcare>Healthcare Services>Dentists":              {"MEDICAL", "MEDICAL_DENTAL_CARE"},
+	"Healthcare>Healthcare Services>Optometrists":          {"MEDICAL", "MEDICAL_EYE_CARE"},
+	"Healthcare>Healthcare Services>Veterinarians":         {"MEDICAL", "MEDICAL_VETERINARY_SERVICES"},
+	"Healthcare>Physicians":                                {"MEDICAL", "MEDICAL_PRIMARY_CARE"},
+	"Interest":                                             {"INCOME", "INCOME_INTEREST_EARNED"},
+	"Interest>Interest Charged":                            {"BANK_FEES", "BANK_FEES_INTEREST_CHARGE"},
+	"Payment":                                              {"LOAN_PAYMENTS", "LOAN_PAYMENTS_OTHER_PAYMENT"},
+	"Payment>Credit Card":                                  {"LOAN_PAYMENTS", "LOAN_PAYMENTS_CREDIT_CARD_PAYMENT"},
+	"Payment>Loan":                                         {"LOAN_PAYMENTS", "LOAN_PAYMENTS_PERSONAL_LOAN_PAYMENT"},
+	"Payment>Rent":                                         {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_RENT"},
+	"Payroll":                                              {"INCOME", "INCOME_WAGES"},
+	"Recreation":                                           {"ENTERTAINMENT", "ENTERTAINMENT_OTHER_ENTERTAINMENT"},
+	"Recreation>Arts and Entertainment":                    {"ENTERTAINMENT", "ENTERTAINMENT_SPORTING_EVENTS_AMUSEMENT_PARKS_AND_MUSEUMS"},
+	"Recreation>Gyms and Fitness Centers":                  {"PERSONAL_CARE", "PERSONAL_CARE_GYMS_AND_FITNESS_CENTERS"},
+	"Recreation>Zoo":                                       {"ENTERTAINMENT", "ENTERTAINMENT_SPORTING_EVENTS_AMUSEMENT_PARKS_AND_MUSEUMS"},
+	"Service":                                              {"GENERAL_SERVICES", "GENERAL_SERVICES_OTHER_GENERAL_SERVICES"},
+	"Service>Automotive":                                   {"GENERAL_SERVICES", "GENERAL_SERVICES_AUTOMOTIVE"},
+	"Service>Cable":                                        {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_INTERNET_AND_CABLE"},
+	"Service>Financial":                                    {"GENERAL_SERVICES", "GENERAL_SERVICES_ACCOUNTING_AND_FINANCIAL_PLANNING"},
+	"Service>Financial>ATMs":                               {"TRANSFER_OUT", "TRANSFER_OUT_WITHDRAWAL"},
+	"Service>Insurance":                                    {"GENERAL_SERVICES", "GENERAL_SERVICES_INSURANCE"},
+	"Service>Internet Services":                            {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_INTERNET_AND_CABLE"},
+	"Service>Legal":                                        {"GENERAL_SERVICES", "GENERAL_SERVICES_CONSULTING_AND_LEGAL"},
+	"Service>Personal Care":                                {"PERSONAL_CARE", "PERSONAL_CARE_OTHER_PERSONAL_CARE"},
+	"Service>Personal Care>Hair Salons and Barbers":        {"PERSONAL_CARE", "PERSONAL_CARE_HAIR_AND_BEAUTY"},
+	"Service>Personal Care>Laundry and Garment Services":   {"PERSONAL_CARE", "PERSONAL_CARE_LAUNDRY_AND_DRY_CLEANING"},
+	"Service>Shipping and Freight":                         {"GENERAL_SERVICES", "GENERAL_SERVICES_POSTAGE_AND_SHIPPING"},
+	"Service>Storage":                                      {"GENERAL_SERVICES", "GENERAL_SERVICES_STORAGE"},
+	"Service>Subscription":                                 {"GENERAL_SERVICES", "GENERAL_SERVICES_OTHER_GENERAL_SERVICES"},
+	"Service>Telecommunication Services":                   {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_TELEPHONE"},
+	"Service>Utilities":                                    {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_OTHER_UTILITIES"},
+	"Service>Utilities>Electric":                           {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_GAS_AND_ELECTRICITY"},
+	"Service>Utilities>Gas":                                {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_GAS_AND_ELECTRICITY"},
+	"Service>Utilities>Sanitary and Waste Management":      {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_SEWAGE_AND_WASTE_MANAGEMENT"},
+	"Service>Utilities>Water":                              {"RENT_AND_UTILITIES", "RENT_AND_UTILITIES_WATER"},
+	"Shops":                                                {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_OTHER_GENERAL_MERCHANDISE"},
+	"Shops>Bookstores":                                     {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_BOOKSTORES_AND_NEWSSTANDS"},
+	"Shops>Clothing and Accessories":                       {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_CLOTHING_AND_ACCESSORIES"},
+	"Shops>Computers and Electronics":                      {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_ELECTRONICS"},
+	"Shops>Convenience Stores":                             {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_CONVENIENCE_STORES"},
+	"Shops>Department Stores":                              {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_DEPARTMENT_STORES"},
+	"Shops>Discount Stores":                                {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_DISCOUNT_STORES"},
+	"Shops>Food and Beverage Store":                        {"FOOD_AND_DRINK", "FOOD_AND_DRINK_GROCERIES"},
+	"Shops>Food and Beverage Store>Beer, Wine and Spirits": {"FOOD_AND_DRINK", "FOOD_AND_DRINK_BEER_WINE_AND_LIQUOR"},
+	"Shops>Furniture and Home Decor":                       {"HOME_IMPROVEMENT", "HOME_IMPROVEMENT_FURNITURE"},
+	"Shops>Gift and Novelty":                               {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_GIFTS_AND_NOVELTIES"},
+	"Shops>Hardware Store":                                 {"HOME_IMPROVEMENT", "HOME_IMPROVEMENT_HARDWARE"},
+	"Shops>Office Supplies":                                {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_OFFICE_SUPPLIES"},
+	"Shops>Pets":                                           {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_PET_SUPPLIES"},
+	"Shops>Pharmacies":                                     {"MEDICAL", "MEDICAL_PHARMACIES_AND_SUPPLEMENTS"},
+	"Shops>Sporting Goods":                                 {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_SPORTING_GOODS"},
+	"Shops>Supermarkets and Groceries":                     {"FOOD_AND_DRINK", "FOOD_AND_DRINK_GROCERIES"},
+	"Shops>Tobacco":                                        {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_TOBACCO_AND_VAPE"},
+	"Shops>Warehouses and Wholesale Stores":                {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_SUPERSTORES"},
+	"Shops>Digital Purchase":                               {"GENERAL_MERCHANDISE", "GENERAL_MERCHANDISE_ONLINE_MARKETPLACES"},
+	"Tax":                                                  {"GOVERNMENT_AND_NON_PROFIT", "GOVERNMENT_AND_NON_PROFIT_TAX_PAYMENT"},
+	"Tax>Refund":                                           {"INCOME", "INCOME_TAX_REFUND"},
+	"Transfer":                                             {"TRANSFER_OUT", "TRANSFER_OUT_ACCOUNT_TRANSFER"},
+	"Transfer>Deposit":                                     {"TRANSFER_IN", "TRANSFER_IN_DEPOSIT"},
+	"Transfer>Payroll":                                     {"INCOME", "INCOME_WAGES"},
+	"Transfer>Withdrawal":                                  {"TRANSFER_OUT", "TRANSFER_OUT_WITHDRAWAL"},
+	"Transfer>Withdrawal>ATM":                              {"TRANSFER_OUT", "TRANSFER_OUT_WITHDRAWAL"},
+	"Travel":                                               {"TRAVEL", "TRAVEL_OTHER_TRAVEL"},
+	"Travel>Airlines and Aviation Services":                {"TRAVEL", "TRAVEL_FLIGHTS"},
+	"Travel>Car Service":                                   {"TRANSPORTATION", "TRANSPORTATION_TAXIS_AND_RIDE_SHARES"},
+	"Travel>Car Service>Ride Share":                        {"TRANSPORTATION", "TRANSPORTATION_TAXIS_AND_RIDE_SHARES"},
+	"Travel>Gas Stations":                                  {"TRANSPORTATION", "TRANSPORTATION_GAS"},
+	"Travel>Lodging":                                       {"TRAVEL", "TRAVEL_LODGING"},
+	"Travel>Parking":                                       {"TRANSPORTATION", "TRANSPORTATION_PARKING"},
+	"Travel>Public Transportation Services":                {"TRANSPORTATION", "TRANSPORTATION_PUBLIC_TRANSIT"},
+	"Travel>Rental Cars":                                   {"TRAVEL", "TRAVEL_RENTAL_CARS"},
+	"Travel>Taxi":                                          {"TRANSPORTATION", "TRANSPORTATION_TAXIS_AND_RIDE_SHARES"},
+	"Travel>Tolls and Fees":                                {"TRANSPORTATION", "TRANSPORTATION_TOLLS"},
+}
+
+// FromLegacy returns the personal finance category of a legacy category
+// hierarchy, and false for an empty or unknown one
+func FromLegacy(hierarchy []string) (Category, bool) {
+	for n := len(hierarchy); n > 0; n-- {
+		if c, ok := legacy[strings.Join(hierarchy[:n], ">")]; ok {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
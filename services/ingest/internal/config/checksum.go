@@ -0,0 +1,61 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+)
+
+// redacted replaces secrets in the checksummed config, so the checksum
+// changes when a secret is set or cleared but not when it is rotated
+const redacted = "[REDACTED]"
+
+// Checksum fingerprints the effective configuration, so instances can be
+// compared for drift without exposing it. Secrets are redacted first.
+func (c *Config) Checksum() string {
+	cp := *c
+	cp.Secrets = nil
+	cp.DatabaseURL = redactURL(cp.DatabaseURL)
+	cp.DatabaseReplicaURL = redactURL(cp.DatabaseReplicaURL)
+	cp.RedisURL = redactURL(cp.RedisURL)
+	for _, s := range []*string{
+		&cp.PlaidSecret, &cp.RobinhoodPassword, &cp.AdminToken, &cp.JWTSecret,
+		&cp.KeyManager.VaultToken, &cp.KeyManager.LocalMasterKey, &cp.KeyManager.LocalMasterKeys,
+		&cp.KeyManager.LegacyKey,
+		&cp.Digest.SMTPPassword,
+	} {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	if len(cp.Tracing.OTLPHeaders) > 0 {
+		headers := make(map[string]string, len(cp.Tracing.OTLPHeaders))
+		for k := range cp.Tracing.OTLPHeaders {
+			headers[k] = redacted
+		}
+		cp.Tracing.OTLPHeaders = headers
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactURL drops the password from a connection URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if raw == "" {
+			return ""
+		}
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
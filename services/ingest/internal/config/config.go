@@ -1,8 +1,36 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/finagent/ingest/internal/attachments"
+	"github.com/finagent/ingest/internal/breaker"
+	"github.com/finagent/ingest/internal/capture"
+	"github.com/finagent/ingest/internal/corporateactions"
+	"github.com/finagent/ingest/internal/cpi"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/devenv"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/exports"
+	"github.com/finagent/ingest/internal/faultinjection"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/keymanager"
+	"github.com/finagent/ingest/internal/logging"
+	"github.com/finagent/ingest/internal/marketdata"
+	"github.com/finagent/ingest/internal/retention"
+	"github.com/finagent/ingest/internal/retry"
+	"github.com/finagent/ingest/internal/secrets"
+	"github.com/finagent/ingest/internal/security"
+	"github.com/finagent/ingest/internal/statements"
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/finagent/ingest/internal/valuation"
+	"github.com/finagent/ingest/internal/watchlist"
 	"github.com/joho/godotenv"
 )
 
@@ -16,8 +44,175 @@ type Config struct {
 	PlaidEnvironment  string
 	RobinhoodUsername string
 	RobinhoodPassword string
-	JaegerEndpoint    string
-	EncryptionKey     string
+	AdminToken        string
+
+	// Log level, format and access log sampling
+	Logging logging.Options
+
+	// Span exporter and sampling
+	Tracing tracing.Options
+
+	// Connection pool sizing, connect/statement timeouts and slow query logging
+	Database database.Options
+
+	// Schema migration check at startup: off, check or up
+	MigrateOnStart string
+
+	// Run Postgres and Redis in-process for local development, replacing
+	// DatabaseURL and RedisURL
+	Embedded devenv.Options
+
+	// Optional read replica for /read endpoints, health-checked every
+	// ReplicaCheckInterval; reads fall back to the primary while it is down
+	DatabaseReplicaURL   string
+	ReplicaCheckInterval time.Duration
+
+	// TTL of cached account, holding and position reads; 0 disables caching
+	ReadCacheTTL time.Duration
+
+	// Background job queue workers, retries and leases
+	Jobs jobs.Options
+
+	// Lease on the per-item lock held while a Plaid item syncs; renewed
+	// while the sync runs and expires on its own if the holder dies
+	SyncLockTTL time.Duration
+
+	// Secrets loaded from Vault or SSM, overriding the environment; nil when
+	// secrets come from the environment only
+	Secrets                *secrets.Store
+	SecretsRefreshInterval time.Duration
+
+	// Envelope encryption master key provider
+	KeyManager keymanager.Options
+
+	// JWT authentication; one of secret or JWKS URL is required unless
+	// AuthDisabled is set for local development
+	JWTSecret    string
+	JWTJWKSURL   string
+	JWTIssuer    string
+	JWTAudience  string
+	JWTUserClaim string
+	AuthDisabled bool
+
+	// How long shutdown waits for requests, jobs and webhook deliveries
+	ShutdownTimeout time.Duration
+
+	// HTTP server tuning
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	MaxBodyBytes      int
+	MaxJSONDepth      int
+	EnableH2C         bool
+
+	// Proxies, as IPs or CIDR ranges, whose X-Forwarded-For is trusted to
+	// name the client; without any the peer address is used
+	TrustedProxies []string
+
+	// TLS for the main listener; client certificates are verified against
+	// the client CA and required from internal callers when it is set.
+	// HealthPort serves plaintext /healthz and /readyz for probes.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	TLSAllowedSANs  []string
+	HealthPort      string
+
+	// Mark session and CSRF cookies Secure (HTTPS only)
+	CookieSecure bool
+
+	// Rate limit counters in redis or, for a single instance, memory
+	RateLimitBackend string
+
+	// Rate limits in requests per minute; 0 disables a tier
+	RateLimitIP       int
+	RateLimitUser     int
+	RateLimitOrders   int
+	RateLimitExchange int
+
+	// Anomalous access detection and lockout
+	Security security.Options
+
+	// Grace period before a requested account deletion is carried out
+	UserDeletionGrace time.Duration
+
+	// Retention periods and the maintenance worker that applies them
+	Retention retention.Options
+
+	// Digest email scheduling and the SMTP server digests are sent through
+	Digest digest.Options
+
+	// How often watched symbols are quoted and their price alerts checked
+	Watchlist watchlist.Options
+
+	// How often securities are classified through the market data provider
+	MarketData marketdata.Options
+
+	// How often the end-of-day holdings valuation scheduler runs
+	Valuation valuation.Options
+
+	// How often stock splits and symbol changes are checked and applied
+	CorporateActions corporateactions.Options
+
+	// Object storage for transaction attachments; off unless a provider
+	// is set
+	Attachments attachments.Options
+
+	// How often Plaid statements are downloaded into the attachment
+	// storage
+	Statements statements.Options
+
+	// Where the Consumer Price Index comes from and how often it is
+	// checked for a newly published month
+	CPI cpi.Options
+
+	// How often users' export destinations are checked for the day's
+	// export
+	Exports exports.Options
+
+	// Yield, in percent, of the high-yield savings account depository
+	// accounts are compared with
+	BenchmarkAPY float64
+
+	// How long Plaid webhook deliveries are accepted and remembered for
+	// rejecting replays
+	PlaidWebhookReplayWindow time.Duration
+
+	// Longest acceptable time from a Plaid webhook arriving to its data
+	// being persisted; slower webhooks are logged and counted. 0 disables.
+	PlaidWebhookLagSLO time.Duration
+
+	// Processors, such as dwolla, that users may issue Plaid processor
+	// tokens for; none when empty
+	PlaidProcessors []string
+
+	// Circuit breakers and retries around the Plaid and Robinhood APIs
+	PlaidBreaker     breaker.Options
+	RobinhoodBreaker breaker.Options
+	PlaidRetry       retry.Policy
+	RobinhoodRetry   retry.Policy
+
+	// Per-user daily usage quotas and how often usage reaches Postgres
+	Usage usage.Options
+
+	// Sampled request/response capture for debugging; off unless the
+	// sample rate is set
+	Capture capture.Options
+
+	// Failure injection for resilience testing; never enabled in production
+	Faults faultinjection.Options
+
+	// Serve pprof profiles under /debug/pprof to admins
+	EnablePprof bool
+
+	// User the stdio MCP server (`ingest mcp`) acts as
+	MCPUserID string
+
+	// Response compression
+	CompressionLevel    int
+	CompressionMinBytes int
 }
 
 func Load() (*Config, error) {
@@ -34,16 +229,358 @@ func Load() (*Config, error) {
 		PlaidEnvironment:  getEnv("PLAID_ENVIRONMENT", "sandbox"),
 		RobinhoodUsername: getEnv("ROBINHOOD_USERNAME", ""),
 		RobinhoodPassword: getEnv("ROBINHOOD_PASSWORD", ""),
-		JaegerEndpoint:    getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
-		EncryptionKey:     getEnv("ENCRYPTION_KEY", "dev-key-32-chars-long-for-aes-256"),
+		AdminToken:        getEnv("ADMIN_TOKEN", ""),
+
+		Logging: logging.Options{
+			Level:         getEnv("LOG_LEVEL", "info"),
+			Format:        getEnv("LOG_FORMAT", "json"),
+			SampleRate:    getEnvFloat("LOG_SAMPLE_RATE", 0.1),
+			SampledRoutes: getEnvList("LOG_SAMPLED_ROUTES"),
+		},
+
+		Tracing: tracing.Options{
+			Exporter:       getEnv("TRACING_EXPORTER", tracing.ExporterOTLPGRPC),
+			OTLPEndpoint:   getEnv("OTLP_ENDPOINT", "localhost:4317"),
+			OTLPHeaders:    getEnvMap("OTLP_HEADERS"),
+			OTLPInsecure:   getEnvBool("OTLP_INSECURE", true),
+			JaegerEndpoint: getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
+			SampleRatio:    getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+
+		Database: database.Options{
+			MaxConns:          int32(getEnvInt("DATABASE_MAX_CONNS", 30)),
+			MinConns:          int32(getEnvInt("DATABASE_MIN_CONNS", 5)),
+			ConnectTimeout:    getEnvDuration("DATABASE_CONNECT_TIMEOUT", 10*time.Second),
+			StatementTimeout:  getEnvDuration("DATABASE_STATEMENT_TIMEOUT", 30*time.Second),
+			HealthCheckPeriod: getEnvDuration("DATABASE_HEALTH_CHECK_PERIOD", time.Minute),
+			SlowQueryLog:      getEnvDuration("DATABASE_SLOW_QUERY_LOG", 500*time.Millisecond),
+		},
+
+		MigrateOnStart: getEnv("DB_MIGRATE_ON_START", "off"),
+
+		Embedded: devenv.Options{
+			Enabled:      getEnvBool("EMBEDDED_STORES", false),
+			DataDir:      getEnv("EMBEDDED_DATA_DIR", ".devdata"),
+			PostgresPort: uint32(getEnvInt("EMBEDDED_POSTGRES_PORT", 5433)),
+			RedisAddr:    getEnv("EMBEDDED_REDIS_ADDR", ""),
+		},
+
+		DatabaseReplicaURL:   getEnv("DATABASE_REPLICA_URL", ""),
+		ReplicaCheckInterval: getEnvDuration("DATABASE_REPLICA_CHECK_INTERVAL", 10*time.Second),
+
+		ReadCacheTTL: getEnvDuration("READ_CACHE_TTL", 5*time.Minute),
+
+		Jobs: jobs.Options{
+			Workers:      getEnvInt("JOB_WORKERS", 4),
+			MaxAttempts:  getEnvInt("JOB_MAX_ATTEMPTS", 5),
+			LockTimeout:  getEnvDuration("JOB_LOCK_TIMEOUT", 5*time.Minute),
+			PollInterval: getEnvDuration("JOB_POLL_INTERVAL", time.Second),
+			RetryBackoff: getEnvDuration("JOB_RETRY_BACKOFF", 30*time.Second),
+			MaxBackoff:   getEnvDuration("JOB_MAX_BACKOFF", time.Hour),
+			MaxDeferral:  getEnvDuration("JOB_MAX_DEFERRAL", time.Hour),
+		},
+
+		SyncLockTTL: getEnvDuration("SYNC_LOCK_TTL", time.Minute),
+
+		SecretsRefreshInterval: getEnvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute),
+
+		KeyManager: keymanager.Options{
+			Provider:        getEnv("KMS_PROVIDER", ""),
+			AWSKeyID:        getEnv("AWS_KMS_KEY_ID", ""),
+			AWSRegion:       getEnv("AWS_REGION", ""),
+			VaultAddr:       getEnv("VAULT_ADDR", ""),
+			VaultToken:      getEnv("VAULT_TOKEN", ""),
+			VaultTransitKey: getEnv("VAULT_TRANSIT_KEY", ""),
+			LocalMasterKey:  getEnv("KMS_LOCAL_MASTER_KEY", ""),
+			LocalMasterKeys: getEnv("KMS_LOCAL_MASTER_KEYS", ""),
+			LegacyKey:       getEnv("ENCRYPTION_KEY", ""),
+		},
+
+		JWTSecret:    getEnv("JWT_SECRET", ""),
+		JWTJWKSURL:   getEnv("JWT_JWKS_URL", ""),
+		JWTIssuer:    getEnv("JWT_ISSUER", ""),
+		JWTAudience:  getEnv("JWT_AUDIENCE", ""),
+		JWTUserClaim: getEnv("JWT_USER_CLAIM", "sub"),
+		AuthDisabled: getEnvBool("AUTH_DISABLED", false),
+
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 65*time.Second),
+		IdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		MaxHeaderBytes:    getEnvInt("HTTP_MAX_HEADER_BYTES", 1<<20),
+		MaxBodyBytes:      getEnvInt("HTTP_MAX_BODY_BYTES", 1<<20),
+		MaxJSONDepth:      getEnvInt("HTTP_MAX_JSON_DEPTH", 32),
+		EnableH2C:         getEnvBool("HTTP_ENABLE_H2C", false),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		TLSCertFile:     getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile: getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSAllowedSANs:  getEnvList("TLS_ALLOWED_CLIENT_SANS"),
+		HealthPort:      getEnv("HEALTH_PORT", ""),
+
+		CookieSecure: getEnvBool("COOKIE_SECURE", true),
+
+		RateLimitBackend:  getEnv("RATE_LIMIT_BACKEND", "redis"),
+		RateLimitIP:       getEnvInt("RATE_LIMIT_IP", 120),
+		RateLimitUser:     getEnvInt("RATE_LIMIT_USER", 600),
+		RateLimitOrders:   getEnvInt("RATE_LIMIT_ORDERS", 20),
+		RateLimitExchange: getEnvInt("RATE_LIMIT_EXCHANGE", 5),
+
+		Security: security.Options{
+			OrderBurst:        getEnvInt("ANOMALY_ORDER_BURST", 5),
+			OrderWindow:       getEnvDuration("ANOMALY_ORDER_WINDOW", time.Minute),
+			AuthFailures:      getEnvInt("ANOMALY_AUTH_FAILURES", 20),
+			AuthFailureWindow: getEnvDuration("ANOMALY_AUTH_FAILURE_WINDOW", 5*time.Minute),
+			IPLockout:         getEnvDuration("ANOMALY_IP_LOCKOUT", 15*time.Minute),
+			ReverifyWindow:    getEnvDuration("ANOMALY_REVERIFY_WINDOW", 5*time.Minute),
+		},
+
+		UserDeletionGrace: getEnvDuration("USER_DELETION_GRACE", 30*24*time.Hour),
+
+		Retention: retention.Options{
+			TransactionDays:           getEnvInt("RETENTION_TRANSACTIONS_DAYS", 7*365),
+			InvestmentTransactionDays: getEnvInt("RETENTION_INVESTMENT_TRANSACTIONS_DAYS", 7*365),
+			WebhookDeliveryDays:       getEnvInt("RETENTION_WEBHOOK_DELIVERIES_DAYS", 90),
+			JobDays:                   getEnvInt("RETENTION_JOBS_DAYS", 30),
+			RecordVersionDays:         getEnvInt("RETENTION_RECORD_VERSIONS_DAYS", 2*365),
+			WebhookLagDays:            getEnvInt("RETENTION_WEBHOOK_LAG_DAYS", 30),
+			Interval:                  getEnvDuration("RETENTION_INTERVAL", 24*time.Hour),
+			BatchSize:                 getEnvInt("RETENTION_BATCH_SIZE", 1000),
+			DryRun:                    getEnvBool("RETENTION_DRY_RUN", false),
+		},
+
+		Digest: digest.Options{
+			Interval:     getEnvDuration("DIGEST_INTERVAL", time.Hour),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("DIGEST_FROM", "FinAgent <digest@localhost>"),
+		},
+
+		Watchlist: watchlist.Options{
+			Interval: getEnvDuration("WATCHLIST_REFRESH_INTERVAL", 5*time.Minute),
+		},
+
+		MarketData: marketdata.Options{
+			Interval:     getEnvDuration("SECURITY_ENRICH_INTERVAL", 24*time.Hour),
+			RefreshAfter: getEnvDuration("SECURITY_METADATA_TTL", 30*24*time.Hour),
+		},
+
+		Valuation: valuation.Options{
+			Interval: getEnvDuration("VALUATION_INTERVAL", time.Hour),
+		},
+
+		CorporateActions: corporateactions.Options{
+			Interval: getEnvDuration("CORPORATE_ACTIONS_INTERVAL", time.Hour),
+		},
+
+		Attachments: attachments.Options{
+			Provider:  getEnv("ATTACHMENT_STORAGE", ""),
+			Bucket:    getEnv("ATTACHMENT_BUCKET", ""),
+			Region:    getEnv("ATTACHMENT_REGION", getEnv("AWS_REGION", "")),
+			Endpoint:  getEnv("ATTACHMENT_ENDPOINT", ""),
+			Prefix:    getEnv("ATTACHMENT_PREFIX", "attachments"),
+			MaxBytes:  int64(getEnvInt("ATTACHMENT_MAX_BYTES", 10<<20)),
+			URLExpiry: getEnvDuration("ATTACHMENT_URL_TTL", 15*time.Minute),
+		},
+
+		Statements: statements.Options{
+			Interval:  getEnvDuration("STATEMENTS_INTERVAL", 24*time.Hour),
+			Prefix:    getEnv("STATEMENTS_PREFIX", "statements"),
+			URLExpiry: getEnvDuration("ATTACHMENT_URL_TTL", 15*time.Minute),
+		},
+
+		CPI: cpi.Options{
+			Interval:     getEnvDuration("CPI_INTERVAL", 24*time.Hour),
+			URL:          getEnv("CPI_URL", "https://api.bls.gov/publicAPI/v2/timeseries/data/"),
+			SeriesID:     getEnv("CPI_SERIES_ID", "CUUR0000SA0"),
+			APIKey:       getEnv("BLS_API_KEY", ""),
+			HistoryYears: getEnvInt("CPI_HISTORY_YEARS", 20),
+		},
+
+		Exports: exports.Options{
+			Interval: getEnvDuration("EXPORTS_INTERVAL", time.Hour),
+		},
+
+		BenchmarkAPY: getEnvFloat("HYSA_BENCHMARK_APY", 4),
+
+		PlaidWebhookReplayWindow: getEnvDuration("PLAID_WEBHOOK_REPLAY_WINDOW", 5*time.Minute),
+		PlaidWebhookLagSLO:       getEnvDuration("PLAID_WEBHOOK_LAG_SLO", 5*time.Minute),
+		PlaidProcessors:          getEnvList("PLAID_PROCESSORS"),
+
+		PlaidBreaker: breaker.Options{
+			FailureThreshold: getEnvInt("PLAID_BREAKER_FAILURES", 5),
+			OpenTimeout:      getEnvDuration("PLAID_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+			HalfOpenProbes:   getEnvInt("PLAID_BREAKER_HALF_OPEN_PROBES", 1),
+		},
+		RobinhoodBreaker: breaker.Options{
+			FailureThreshold: getEnvInt("ROBINHOOD_BREAKER_FAILURES", 3),
+			OpenTimeout:      getEnvDuration("ROBINHOOD_BREAKER_OPEN_TIMEOUT", time.Minute),
+			HalfOpenProbes:   getEnvInt("ROBINHOOD_BREAKER_HALF_OPEN_PROBES", 1),
+		},
+		PlaidRetry: retry.Policy{
+			MaxAttempts: getEnvInt("PLAID_RETRY_MAX_ATTEMPTS", 3),
+			BaseDelay:   getEnvDuration("PLAID_RETRY_BASE_DELAY", 250*time.Millisecond),
+			MaxDelay:    getEnvDuration("PLAID_RETRY_MAX_DELAY", 10*time.Second),
+			Budget:      getEnvDuration("PLAID_RETRY_BUDGET", 30*time.Second),
+		},
+		RobinhoodRetry: retry.Policy{
+			MaxAttempts: getEnvInt("ROBINHOOD_RETRY_MAX_ATTEMPTS", 3),
+			BaseDelay:   getEnvDuration("ROBINHOOD_RETRY_BASE_DELAY", 250*time.Millisecond),
+			MaxDelay:    getEnvDuration("ROBINHOOD_RETRY_MAX_DELAY", 5*time.Second),
+			Budget:      getEnvDuration("ROBINHOOD_RETRY_BUDGET", 15*time.Second),
+		},
+
+		Usage: usage.Options{
+			Quotas: map[string]int64{
+				usage.MetricAPICalls: int64(getEnvInt("USAGE_QUOTA_API_CALLS", 0)),
+				usage.MetricSyncs:    int64(getEnvInt("USAGE_QUOTA_SYNCS", 0)),
+				usage.MetricOrders:   int64(getEnvInt("USAGE_QUOTA_ORDERS", 0)),
+			},
+			FlushInterval: getEnvDuration("USAGE_FLUSH_INTERVAL", time.Minute),
+		},
+
+		Capture: capture.Options{
+			SampleRate:   getEnvFloat("DEBUG_CAPTURE_RATE", 0),
+			Size:         getEnvInt("DEBUG_CAPTURE_SIZE", 200),
+			MaxBodyBytes: getEnvInt("DEBUG_CAPTURE_MAX_BODY_BYTES", 16384),
+			SkipPrefixes: []string{"/admin/", "/metrics", "/healthz", "/readyz"},
+		},
+
+		Faults: faultinjection.Options{
+			Enabled:             getEnvBool("FAULTS_ENABLED", false),
+			Latency:             getEnvDuration("FAULT_LATENCY", 500*time.Millisecond),
+			LatencyRate:         getEnvFloat("FAULT_LATENCY_RATE", 0),
+			HTTPErrorRate:       getEnvFloat("FAULT_HTTP_ERROR_RATE", 0),
+			UpstreamLatencyRate: getEnvFloat("FAULT_UPSTREAM_LATENCY_RATE", 0),
+			UpstreamErrorRate:   getEnvFloat("FAULT_UPSTREAM_ERROR_RATE", 0),
+			WebhookDropRate:     getEnvFloat("FAULT_WEBHOOK_DROP_RATE", 0),
+			SkipPrefixes:        []string{"/admin/", "/metrics", "/healthz", "/readyz"},
+		},
+
+		EnablePprof: getEnvBool("DEBUG_PPROF_ENABLED", false),
+		MCPUserID:   getEnv("MCP_USER_ID", ""),
+
+		CompressionLevel:    getEnvInt("COMPRESSION_LEVEL", 5),
+		CompressionMinBytes: getEnvInt("COMPRESSION_MIN_BYTES", 1024),
+	}
+
+	store, err := secrets.Load(context.Background(), secrets.Options{
+		Provider:   getEnv("SECRETS_PROVIDER", ""),
+		VaultAddr:  getEnv("VAULT_ADDR", ""),
+		VaultToken: getEnv("VAULT_TOKEN", ""),
+		VaultPath:  getEnv("SECRETS_VAULT_PATH", ""),
+		SSMPath:    getEnv("SECRETS_SSM_PATH", ""),
+		AWSRegion:  getEnv("AWS_REGION", ""),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+	if store != nil {
+		cfg.Secrets = store
+		cfg.ApplySecrets(store.Values())
 	}
 
 	return cfg, nil
 }
 
+// ApplySecrets overrides config fields with values from the secrets store.
+// DATABASE_URL, the Plaid credentials and the KMS keys can be rotated at
+// runtime; the remaining secrets are only read at startup.
+func (c *Config) ApplySecrets(values map[string]string) {
+	for key, value := range values {
+		switch key {
+		case "DATABASE_URL":
+			c.DatabaseURL = value
+		case "PLAID_CLIENT_ID":
+			c.PlaidClientID = value
+		case "PLAID_SECRET":
+			c.PlaidSecret = value
+		case "KMS_LOCAL_MASTER_KEY":
+			c.KeyManager.LocalMasterKey = value
+		case "KMS_LOCAL_MASTER_KEYS":
+			c.KeyManager.LocalMasterKeys = value
+		case "ENCRYPTION_KEY":
+			c.KeyManager.LegacyKey = value
+		case "VAULT_TOKEN":
+			c.KeyManager.VaultToken = value
+		case "ROBINHOOD_PASSWORD":
+			c.RobinhoodPassword = value
+		case "ADMIN_TOKEN":
+			c.AdminToken = value
+		case "JWT_SECRET":
+			c.JWTSecret = value
+		case "SMTP_PASSWORD":
+			c.Digest.SMTPPassword = value
+		}
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvMap parses comma-separated key=value pairs
+func getEnvMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range getEnvList(key) {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
 }
\ No newline at end of file
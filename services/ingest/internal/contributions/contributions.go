@@ -0,0 +1,282 @@
+// Package contributions totals a user's contributions to 401(k), IRA and
+// HSA accounts in a year against the IRS's limits. Contributions are read
+// from the investment transactions of linked retirement and HSA accounts;
+// payments from a bank account into one the user has not linked are
+// recognized by their descriptors, such as "VANGUARD IRA CONTRIB".
+// Contributions count toward the year they are made.
+package contributions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of tax-advantaged account
+const (
+	Kind401k = "401k" // employee deferrals to 401(k), 403(b) and TSP plans
+	KindIRA  = "ira"  // traditional and Roth IRAs
+	KindHSA  = "hsa"
+)
+
+// Kinds lists every kind of account, in report order
+var Kinds = []string{Kind401k, KindIRA, KindHSA}
+
+// Limit kinds, HSA limits depending on coverage
+const (
+	LimitHSASelf   = "hsa_self"
+	LimitHSAFamily = "hsa_family"
+)
+
+// LimitKinds lists every kind of limit
+var LimitKinds = []string{Kind401k, KindIRA, LimitHSASelf, LimitHSAFamily}
+
+// Contribution sources
+const (
+	SourceInvestment  = "investment"
+	SourceTransaction = "transaction"
+)
+
+// ErrUnknownKind is returned when setting a limit of a kind not in LimitKinds
+var ErrUnknownKind = errors.New("unknown contribution limit kind")
+
+// accountKinds maps Plaid account subtypes to the kind of account. SEP and
+// SIMPLE IRAs and 457(b) plans have limits of their own and are left out.
+var accountKinds = map[string]string{
+	"401k":                   Kind401k,
+	"403B":                   Kind401k,
+	"403b":                   Kind401k,
+	"roth 401k":              Kind401k,
+	"thrift savings plan":    Kind401k,
+	"ira":                    KindIRA,
+	"roth":                   KindIRA,
+	"hsa":                    KindHSA,
+	"health savings account": KindHSA,
+}
+
+// descriptors recognize bank transactions paying into each kind of account
+var descriptors = map[string]*regexp.Regexp{
+	Kind401k: regexp.MustCompile(`(?i)\b(401 ?\(?K|403 ?\(?B|TSP\b|THRIFT SAVINGS)`),
+	KindIRA:  regexp.MustCompile(`(?i)\bIRA\b`),
+	KindHSA:  regexp.MustCompile(`(?i)\bHSA\b|HEALTH SAVINGS`),
+}
+
+// notContribution matches money moved into an account that does not count
+// toward its limit, and money taken out of one
+var notContribution = regexp.MustCompile(`(?i)ROLLOVER|CONVERSION|RECHARACTERI|TRANSFER|DISTRIB|WITHDRAW|REIMB|REFUND|\bLOAN\b|\bFEE\b|\bRMD\b`)
+
+// notDeferral matches an employer's contributions to a 401(k), which do
+// not count toward the employee deferral limit
+var notDeferral = regexp.MustCompile(`(?i)EMPLOYER|\bMATCH|PROFIT SHARING`)
+
+// AccountKind returns the kind of tax-advantaged account a is, or "" when
+// it is none
+func AccountKind(a models.Account) string {
+	if a.Subtype == nil {
+		return ""
+	}
+	return accountKinds[*a.Subtype]
+}
+
+// Detect finds the contributions among investment and bank transactions.
+// Payments from a bank account count only toward kinds of account the
+// user has not linked, as those linked show the same money arriving.
+func Detect(accounts []models.Account, investments []models.InvestmentTransaction, transactions []models.Transaction) map[string][]models.Contribution {
+	kinds := make(map[string]string)
+	linked := make(map[string]bool)
+	for _, a := range accounts {
+		if kind := AccountKind(a); kind != "" {
+			kinds[a.ID] = kind
+			linked[kind] = true
+		}
+	}
+
+	found := make(map[string][]models.Contribution)
+	for _, it := range investments {
+		kind := kinds[it.AccountID]
+		if kind == "" || it.Subtype == nil {
+			continue
+		}
+		if *it.Subtype != "contribution" && *it.Subtype != "deposit" {
+			continue
+		}
+		if notContribution.MatchString(it.Name) || (kind == Kind401k && notDeferral.MatchString(it.Name)) {
+			continue
+		}
+		found[kind] = append(found[kind], models.Contribution{
+			Date:          it.Date.Format(period.DateLayout),
+			AccountID:     it.AccountID,
+			Name:          it.Name,
+			Amount:        it.Amount.Abs(),
+			TransactionID: it.ID,
+			Source:        SourceInvestment,
+		})
+	}
+
+	for _, txn := range transactions {
+		descriptor := ""
+		if txn.Description != nil {
+			descriptor = *txn.Description
+		}
+		if notContribution.MatchString(descriptor) {
+			continue
+		}
+
+		// HSAs held at a bank show deposits as transactions of their own
+		kind := kinds[txn.AccountID]
+		switch {
+		case kind == KindHSA && txn.Amount.IsNegative():
+		case kind == "" && txn.Amount.IsPositive():
+			for _, k := range Kinds {
+				if !linked[k] && descriptors[k].MatchString(descriptor) {
+					kind = k
+					break
+				}
+			}
+			if kind == "" {
+				continue
+			}
+		default:
+			continue
+		}
+		found[kind] = append(found[kind], models.Contribution{
+			Date:          txn.Date.Format(period.DateLayout),
+			AccountID:     txn.AccountID,
+			Name:          descriptor,
+			Amount:        txn.Amount.Abs(),
+			TransactionID: txn.ID,
+			Source:        SourceTransaction,
+		})
+	}
+
+	for _, contributions := range found {
+		sort.Slice(contributions, func(i, j int) bool {
+			return contributions[i].Date < contributions[j].Date
+		})
+	}
+	return found
+}
+
+// Options are what a user's limits depend on
+type Options struct {
+	CatchUp   bool // of catch-up age, for every kind
+	HSAFamily bool // HSA covers a family, not only the user
+}
+
+// Report totals each kind's contributions against its limit for the year
+func Report(found map[string][]models.Contribution, limits map[string]models.ContributionLimit, opts Options) []models.ContributionHeadroom {
+	report := make([]models.ContributionHeadroom, 0, len(Kinds))
+	for _, kind := range Kinds {
+		headroom := models.ContributionHeadroom{
+			Kind:          kind,
+			Contributed:   decimal.Zero,
+			Contributions: found[kind],
+		}
+		for _, c := range found[kind] {
+			headroom.Contributed = headroom.Contributed.Add(c.Amount)
+		}
+
+		limitKind := kind
+		if kind == KindHSA {
+			limitKind = LimitHSASelf
+			if opts.HSAFamily {
+				limitKind = LimitHSAFamily
+			}
+		}
+		if l, ok := limits[limitKind]; ok {
+			limit := l.Limit
+			if opts.CatchUp {
+				limit = limit.Add(l.CatchUp)
+			}
+			remaining := decimal.Max(limit.Sub(headroom.Contributed), decimal.Zero)
+			headroom.Limit = &limit
+			headroom.Remaining = &remaining
+			headroom.OverLimit = headroom.Contributed.GreaterThan(limit)
+		}
+		report = append(report, headroom)
+	}
+	return report
+}
+
+// Service stores the contribution limits of each year
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a contribution limits service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// Limits returns a year's limits by kind; a year without any returns none
+func (s *Service) Limits(ctx context.Context, year int) (map[string]models.ContributionLimit, error) {
+	limits, err := s.query(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	byKind := make(map[string]models.ContributionLimit, len(limits))
+	for _, l := range limits {
+		byKind[l.Kind] = l
+	}
+	return byKind, nil
+}
+
+// List returns every year's limits, most recent year first
+func (s *Service) List(ctx context.Context) ([]models.ContributionLimit, error) {
+	return s.query(ctx, 0)
+}
+
+// Set adds or replaces the limit of a kind for a year
+func (s *Service) Set(ctx context.Context, l models.ContributionLimit) error {
+	known := false
+	for _, kind := range LimitKinds {
+		known = known || kind == l.Kind
+	}
+	if !known {
+		return ErrUnknownKind
+	}
+
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO contribution_limits (year, kind, limit_amount, catch_up_amount, catch_up_age)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (year, kind) DO UPDATE SET
+			limit_amount = EXCLUDED.limit_amount,
+			catch_up_amount = EXCLUDED.catch_up_amount,
+			catch_up_age = EXCLUDED.catch_up_age
+	`, l.Year, l.Kind, l.Limit, l.CatchUp, l.CatchUpAge)
+	if err != nil {
+		return fmt.Errorf("failed to set contribution limit: %w", err)
+	}
+	return nil
+}
+
+// query reads the limits of a year, or of every year when year is 0
+func (s *Service) query(ctx context.Context, year int) ([]models.ContributionLimit, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT year, kind, limit_amount, catch_up_amount, catch_up_age
+		FROM contribution_limits
+		WHERE $1 = 0 OR year = $1
+		ORDER BY year DESC, kind
+	`, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contribution limits: %w", err)
+	}
+	defer rows.Close()
+
+	limits := []models.ContributionLimit{}
+	for rows.Next() {
+		var l models.ContributionLimit
+		if err := rows.Scan(&l.Year, &l.Kind, &l.Limit, &l.CatchUp, &l.CatchUpAge); err != nil {
+			return nil, fmt.Errorf("failed to scan contribution limit: %w", err)
+		}
+		limits = append(limits, l)
+	}
+	return limits, rows.Err()
+}
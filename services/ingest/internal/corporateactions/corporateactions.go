@@ -0,0 +1,402 @@
+// Package corporateactions applies stock splits and symbol changes to
+// what is stored about a security, so holdings history and returns stay
+// continuous across them. Actions are recorded by operators, or imported
+// from a feed, and applied once their ex-date arrives.
+//
+// A split multiplies quantities recorded before its ex-date by the split
+// ratio and divides their prices by it: end-of-day valuations,
+// investment transactions, holdings not yet refreshed by Plaid, and the
+// prices watchlists compare against. Values and total cost basis do not
+// change. A symbol change renames the security in securities, valuations
+// and watchlists.
+package corporateactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+)
+
+// Action sources
+const (
+	SourceManual = "manual"
+	SourceFeed   = "feed"
+)
+
+// marketTimezone is the timezone ex-dates are in
+const marketTimezone = "America/New_York"
+
+var (
+	// ErrNotFound is returned for an action that does not exist
+	ErrNotFound = errors.New("corporate action not found")
+	// ErrConflict is returned when recording an action already recorded
+	ErrConflict = errors.New("corporate action already recorded")
+)
+
+// Feed reports corporate actions with ex-dates from since on
+type Feed interface {
+	Actions(ctx context.Context, since time.Time) ([]models.CorporateAction, error)
+}
+
+// Options sets how often due actions are applied. 0 disables the worker.
+type Options struct {
+	Interval time.Duration
+}
+
+// Service records corporate actions and applies them
+type Service struct {
+	db    *database.Database
+	locks *locks.Locker
+	feed  Feed
+	opts  Options
+}
+
+// NewService creates a corporate action service. feed may be nil, leaving
+// actions to be recorded by operators. When locker is set, only one
+// instance applies actions at a time.
+func NewService(db *database.Database, locker *locks.Locker, feed Feed, opts Options) *Service {
+	return &Service{db: db, locks: locker, feed: feed, opts: opts}
+}
+
+// Today returns the current date where ex-dates are set
+func Today(now time.Time) time.Time {
+	loc, err := time.LoadLocation(marketTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// Validate normalizes an action's symbols and checks it can be applied
+func Validate(a *models.CorporateAction) error {
+	a.Symbol = strings.ToUpper(strings.TrimSpace(a.Symbol))
+	if a.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+	if _, err := time.Parse(period.DateLayout, a.ExDate); err != nil {
+		return errors.New("ex_date must be YYYY-MM-DD")
+	}
+
+	switch a.Kind {
+	case models.CorporateActionSplit:
+		if a.SplitFrom == nil || a.SplitTo == nil || !a.SplitFrom.IsPositive() || !a.SplitTo.IsPositive() {
+			return errors.New("a split needs positive split_from and split_to")
+		}
+		if a.SplitFrom.Equal(*a.SplitTo) {
+			return errors.New("split_from and split_to must differ")
+		}
+		a.NewSymbol = nil
+	case models.CorporateActionSymbolChange:
+		if a.NewSymbol == nil {
+			return errors.New("a symbol change needs new_symbol")
+		}
+		symbol := strings.ToUpper(strings.TrimSpace(*a.NewSymbol))
+		if symbol == "" || symbol == a.Symbol {
+			return errors.New("new_symbol must differ from symbol")
+		}
+		a.NewSymbol = &symbol
+		a.SplitFrom, a.SplitTo = nil, nil
+	default:
+		return errors.New("kind must be 'split' or 'symbol_change'")
+	}
+	return nil
+}
+
+// Run imports actions from the feed and applies those due every Interval
+// until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "corporate_actions")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock corporate actions", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	today := Today(time.Now())
+	if s.feed != nil {
+		if err := s.importFeed(ctx, today.AddDate(0, 0, -7)); err != nil {
+			slog.ErrorContext(ctx, "Failed to import corporate actions", "error", err)
+		}
+	}
+
+	applied, err := s.ApplyDue(ctx, today)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to apply corporate actions", "error", err)
+	}
+	if applied > 0 {
+		slog.InfoContext(ctx, "Applied corporate actions", "count", applied)
+	}
+}
+
+// importFeed records the feed's actions from since on, skipping those
+// already recorded
+func (s *Service) importFeed(ctx context.Context, since time.Time) error {
+	actions, err := s.feed.Actions(ctx, since)
+	if err != nil {
+		return err
+	}
+	for i := range actions {
+		a := &actions[i]
+		a.Source = SourceFeed
+		if err := Validate(a); err != nil {
+			slog.WarnContext(ctx, "Skipping invalid corporate action", "symbol", a.Symbol, "error", err)
+			continue
+		}
+		if err := s.Record(ctx, a); err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record stores a validated action to be applied on its ex-date
+func (s *Service) Record(ctx context.Context, a *models.CorporateAction) error {
+	if a.Source == "" {
+		a.Source = SourceManual
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO corporate_actions (kind, symbol, new_symbol, split_from, split_to, ex_date, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (kind, symbol, ex_date) DO NOTHING
+		RETURNING id, created_at
+	`, a.Kind, a.Symbol, a.NewSymbol, a.SplitFrom, a.SplitTo, a.ExDate, a.Source).Scan(&a.ID, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record corporate action: %w", err)
+	}
+	return nil
+}
+
+// Get returns an action
+func (s *Service) Get(ctx context.Context, id string) (*models.CorporateAction, error) {
+	a, err := scanAction(s.db.Pool.QueryRow(ctx, `
+		SELECT `+actionColumns+` FROM corporate_actions WHERE id = $1
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query corporate action: %w", err)
+	}
+	return a, nil
+}
+
+// List returns recorded actions, latest ex-date first
+func (s *Service) List(ctx context.Context, limit, offset int) ([]models.CorporateAction, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT `+actionColumns+` FROM corporate_actions
+		ORDER BY ex_date DESC, created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query corporate actions: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.CorporateAction{}
+	for rows.Next() {
+		a, err := scanAction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan corporate action: %w", err)
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+// ApplyDue applies every pending action with an ex-date on or before
+// today, oldest first, returning how many it applied
+func (s *Service) ApplyDue(ctx context.Context, today time.Time) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id FROM corporate_actions
+		WHERE applied_at IS NULL AND ex_date <= $1
+		ORDER BY ex_date, created_at
+	`, today.Format(period.DateLayout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query due corporate actions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan corporate action: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query due corporate actions: %w", err)
+	}
+
+	applied := 0
+	for _, id := range ids {
+		ok, err := s.apply(ctx, id)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// apply applies one pending action and marks it applied, all in one
+// transaction. It reports false for an action already applied.
+func (s *Service) apply(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := scanAction(tx.QueryRow(ctx, `
+		SELECT `+actionColumns+` FROM corporate_actions WHERE id = $1 AND applied_at IS NULL FOR UPDATE
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock corporate action: %w", err)
+	}
+
+	var result map[string]interface{}
+	switch a.Kind {
+	case models.CorporateActionSplit:
+		result, err = applySplit(ctx, tx, a)
+	case models.CorporateActionSymbolChange:
+		result, err = applySymbolChange(ctx, tx, a)
+	default:
+		err = fmt.Errorf("unknown corporate action kind %q", a.Kind)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE corporate_actions SET applied_at = NOW(), result = $2 WHERE id = $1
+	`, id, result); err != nil {
+		return false, fmt.Errorf("failed to mark corporate action applied: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit corporate action: %w", err)
+	}
+	slog.InfoContext(ctx, "Applied corporate action", "kind", a.Kind, "symbol", a.Symbol, "ex_date", a.ExDate, "result", result)
+	return true, nil
+}
+
+// applySplit rescales what was recorded about a symbol before the split's
+// ex-date to post-split shares
+func applySplit(ctx context.Context, tx pgx.Tx, a *models.CorporateAction) (map[string]interface{}, error) {
+	ratio := a.SplitTo.Div(*a.SplitFrom)
+	statements := []struct {
+		name  string
+		query string
+	}{
+		{"holding_valuations", `
+			UPDATE holding_valuations SET quantity = quantity * $2, price = price / $2
+			WHERE upper(symbol) = $1 AND date < $3`},
+		{"investment_transactions", `
+			UPDATE investment_transactions it SET quantity = it.quantity * $2, price = it.price / $2
+			FROM securities s
+			WHERE it.security_id = s.id AND upper(s.symbol) = $1 AND it.date < $3 AND it.quantity IS NOT NULL`},
+		// Holdings Plaid has priced since the ex-date are post-split already
+		{"holdings", `
+			UPDATE holdings h SET quantity = h.quantity * $2, institution_price = h.institution_price / $2
+			FROM securities s
+			WHERE h.security_id = s.id AND upper(s.symbol) = $1 AND h.deleted_at IS NULL
+			  AND coalesce(h.institution_price_as_of, h.last_refresh::date) < $3`},
+		{"watchlist_items", `
+			UPDATE watchlist_items SET
+				last_price = CASE WHEN price_updated_at::date < $3 THEN last_price / $2 ELSE last_price END,
+				day_open_price = CASE WHEN day_open_date < $3 THEN day_open_price / $2 ELSE day_open_price END
+			WHERE asset_type = 'stock' AND symbol = $1`},
+	}
+
+	result := map[string]interface{}{"ratio": ratio}
+	for _, stmt := range statements {
+		tag, err := tx.Exec(ctx, stmt.query, a.Symbol, ratio, a.ExDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply split to %s: %w", stmt.name, err)
+		}
+		result[stmt.name] = tag.RowsAffected()
+	}
+	return result, nil
+}
+
+// applySymbolChange renames a symbol in securities, valuations and
+// watchlists. A user watching both symbols keeps the new symbol's item.
+func applySymbolChange(ctx context.Context, tx pgx.Tx, a *models.CorporateAction) (map[string]interface{}, error) {
+	statements := []struct {
+		name  string
+		query string
+	}{
+		{"securities", `
+			UPDATE securities SET symbol = $2, enriched_at = NULL WHERE upper(symbol) = $1`},
+		{"holding_valuations", `
+			UPDATE holding_valuations SET symbol = $2 WHERE upper(symbol) = $1`},
+		{"watchlist_duplicates", `
+			DELETE FROM watchlist_items o
+			WHERE o.asset_type = 'stock' AND o.symbol = $1
+			  AND EXISTS (SELECT 1 FROM watchlist_items n WHERE n.user_id = o.user_id AND n.asset_type = 'stock' AND n.symbol = $2)`},
+		{"watchlist_items", `
+			UPDATE watchlist_items SET symbol = $2 WHERE asset_type = 'stock' AND symbol = $1`},
+		{"security_metadata", `
+			DELETE FROM security_metadata WHERE symbol IN ($1, $2)`},
+	}
+
+	result := map[string]interface{}{}
+	for _, stmt := range statements {
+		tag, err := tx.Exec(ctx, stmt.query, a.Symbol, *a.NewSymbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply symbol change to %s: %w", stmt.name, err)
+		}
+		result[stmt.name] = tag.RowsAffected()
+	}
+	return result, nil
+}
+
+const actionColumns = `id, kind, symbol, new_symbol, split_from, split_to, ex_date::text, source, applied_at, result, created_at`
+
+func scanAction(row pgx.Row) (*models.CorporateAction, error) {
+	var a models.CorporateAction
+	if err := row.Scan(&a.ID, &a.Kind, &a.Symbol, &a.NewSymbol, &a.SplitFrom, &a.SplitTo, &a.ExDate,
+		&a.Source, &a.AppliedAt, &a.Result, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,318 @@
+// Package costbasis reconciles the cost basis institutions report for
+// holdings with the basis of their lots, computed first in, first out
+// from the investment transactions synced for each holding's account and
+// security. A difference beyond a threshold is worth checking against
+// the institution's statements: it may be an error in its data, such as a
+// transfer recorded at the wrong cost, or an adjustment it makes that the
+// transactions do not show, such as for a wash sale.
+//
+// Splits are applied to investment transactions by corporate actions, so
+// split transactions are not counted again. Plaid's history reaches back
+// two years at most; a holding bought earlier, or transferred in without
+// a cost, cannot be reconciled and is reported as incomplete.
+package costbasis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultThresholdPercent is how far, in percent of the computed basis,
+// the reported basis may be from it before it is flagged
+const DefaultThresholdPercent = 1.0
+
+// minDiscrepancy is the smallest difference flagged whatever the
+// threshold, so rounding on small holdings is not
+var minDiscrepancy = decimal.NewFromInt(1)
+
+// quantityTolerance is how far the shares of the lots may be from the
+// holding's and still account for it, allowing for rounded fractions
+var quantityTolerance = decimal.RequireFromString("0.0001")
+
+// splitSubtypes are Plaid subtypes of splits, which corporate actions
+// have already applied to earlier transactions
+var splitSubtypes = map[string]bool{
+	"split":         true,
+	"stock split":   true,
+	"reverse split": true,
+}
+
+// statusOrder sorts comparisons needing attention first
+var statusOrder = map[string]int{
+	models.CostBasisDiscrepancy: 0,
+	models.CostBasisIncomplete:  1,
+	models.CostBasisUnreported:  2,
+	models.CostBasisMatched:     3,
+}
+
+// Trade is an investment transaction in a holding's security. Quantity is
+// positive for shares received and negative for shares given up; Amount
+// is what was paid, fees included, for a purchase.
+type Trade struct {
+	ID       string
+	Date     string
+	Type     string
+	Subtype  string
+	Quantity decimal.Decimal
+	Amount   decimal.Decimal
+	Price    *decimal.Decimal
+}
+
+// Lots returns the lots left after trades, oldest first. Sales take
+// shares from the oldest lots first, with their share of its cost. When
+// the trades sell more shares than they bought, the lots cannot be known
+// and the reason is returned.
+func Lots(trades []Trade) ([]models.CostBasisLot, string) {
+	trades = append([]Trade(nil), trades...)
+	for i := range trades {
+		// Some institutions report sales with positive quantities
+		if trades[i].Type == "sell" && trades[i].Quantity.IsPositive() {
+			trades[i].Quantity = trades[i].Quantity.Neg()
+		}
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].Date != trades[j].Date {
+			return trades[i].Date < trades[j].Date
+		}
+		// Shares bought on a day are there to sell that day
+		return trades[i].Quantity.IsPositive() && !trades[j].Quantity.IsPositive()
+	})
+
+	lots := []models.CostBasisLot{}
+	for _, t := range trades {
+		if t.Quantity.IsZero() || splitSubtypes[strings.ToLower(t.Subtype)] {
+			continue
+		}
+		switch t.Type {
+		case "buy", "sell", "transfer":
+		default:
+			continue
+		}
+
+		if t.Quantity.IsPositive() {
+			lots = append(lots, models.CostBasisLot{
+				TransactionID: t.ID,
+				Date:          t.Date,
+				Quantity:      t.Quantity,
+				CostBasis:     tradeCost(t),
+			})
+			continue
+		}
+
+		remaining := t.Quantity.Neg()
+		for len(lots) > 0 && remaining.IsPositive() {
+			lot := &lots[0]
+			if lot.Quantity.LessThanOrEqual(remaining) {
+				remaining = remaining.Sub(lot.Quantity)
+				lots = lots[1:]
+				continue
+			}
+			left := lot.Quantity.Sub(remaining)
+			if lot.CostBasis != nil {
+				cost := lot.CostBasis.Mul(left).Div(lot.Quantity)
+				lot.CostBasis = &cost
+			}
+			lot.Quantity, remaining = left, decimal.Zero
+		}
+		if remaining.GreaterThan(quantityTolerance) {
+			return lots, fmt.Sprintf("%s on %s gives up %s more shares than the synced history acquired",
+				t.ID, t.Date, remaining.String())
+		}
+	}
+	return lots, ""
+}
+
+// tradeCost is what was paid for the shares a trade received, or nil for
+// a transfer in, whose cost the transaction does not give
+func tradeCost(t Trade) *decimal.Decimal {
+	if t.Type != "buy" {
+		return nil
+	}
+	if !t.Amount.IsZero() {
+		cost := t.Amount.Abs()
+		return &cost
+	}
+	if t.Price != nil {
+		cost := t.Price.Mul(t.Quantity)
+		return &cost
+	}
+	return nil
+}
+
+// Compare reconciles a holding with its lots, or the reason they are
+// unknown. The holding's CostBasis is what the institution reports.
+func Compare(h models.Holding, lots []models.CostBasisLot, reason string, thresholdPercent float64) models.CostBasisComparison {
+	c := models.CostBasisComparison{
+		HoldingID:        h.ID,
+		AccountID:        h.AccountID,
+		AccountName:      h.AccountName,
+		Symbol:           h.Symbol,
+		SecurityName:     h.SecurityName,
+		Quantity:         h.Quantity,
+		ComputedQuantity: decimal.Zero,
+		BrokerCostBasis:  h.CostBasis,
+		Lots:             lots,
+	}
+
+	computed := decimal.Zero
+	known := true
+	for _, lot := range lots {
+		c.ComputedQuantity = c.ComputedQuantity.Add(lot.Quantity)
+		if lot.CostBasis == nil {
+			known = false
+		} else {
+			computed = computed.Add(*lot.CostBasis)
+		}
+	}
+
+	switch {
+	case reason != "":
+		c.Status, c.Reason = models.CostBasisIncomplete, reason
+		return c
+	case c.ComputedQuantity.Sub(h.Quantity).Abs().GreaterThan(quantityTolerance):
+		c.Status = models.CostBasisIncomplete
+		c.Reason = fmt.Sprintf("the synced history accounts for %s of %s shares",
+			c.ComputedQuantity.String(), h.Quantity.String())
+		return c
+	case !known:
+		c.Status = models.CostBasisIncomplete
+		c.Reason = "shares were transferred in without a cost"
+		return c
+	}
+
+	computed = computed.Round(2)
+	c.ComputedCostBasis = &computed
+	if h.CostBasis == nil {
+		c.Status = models.CostBasisUnreported
+		return c
+	}
+
+	diff := h.CostBasis.Sub(computed).Round(2)
+	c.Difference = &diff
+	c.Status = models.CostBasisMatched
+	if computed.IsPositive() {
+		pct, _ := diff.Div(computed).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+		c.DifferencePercent = &pct
+		if diff.Abs().GreaterThan(minDiscrepancy) && (pct > thresholdPercent || pct < -thresholdPercent) {
+			c.Status = models.CostBasisDiscrepancy
+		}
+	} else if diff.Abs().GreaterThan(minDiscrepancy) {
+		c.Status = models.CostBasisDiscrepancy
+	}
+	return c
+}
+
+// Service reads holdings and their transactions to reconcile
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a cost basis reconciliation service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// Reconcile compares the reported and computed cost basis of each of a
+// user's holdings, those needing attention first. Holdings of duplicate
+// accounts are left out.
+func (s *Service) Reconcile(ctx context.Context, userID string, thresholdPercent float64) (*models.CostBasisReconciliation, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT h.id, h.account_id, h.security_id::text, h.quantity, h.cost_basis,
+		       s.symbol, s.name, a.name
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		JOIN accounts a ON h.account_id = a.id
+		WHERE h.user_id = $1 AND h.deleted_at IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'account' AND dl.duplicate_id = h.account_id AND dl.status <> 'rejected')
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []models.Holding
+	var securities []string
+	for rows.Next() {
+		var h models.Holding
+		var securityID string
+		if err := rows.Scan(&h.ID, &h.AccountID, &securityID, &h.Quantity, &h.CostBasis,
+			&h.Symbol, &h.SecurityName, &h.AccountName); err != nil {
+			return nil, fmt.Errorf("failed to scan holding: %w", err)
+		}
+		holdings = append(holdings, h)
+		securities = append(securities, securityID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	trades, err := s.trades(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CostBasisReconciliation{
+		ThresholdPercent: thresholdPercent,
+		Holdings:         make([]models.CostBasisComparison, 0, len(holdings)),
+	}
+	for i, h := range holdings {
+		lots, reason := Lots(trades[h.AccountID+"/"+securities[i]])
+		c := Compare(h, lots, reason, thresholdPercent)
+		switch c.Status {
+		case models.CostBasisMatched:
+			report.Matched++
+		case models.CostBasisDiscrepancy:
+			report.Discrepancies++
+		case models.CostBasisIncomplete:
+			report.Incomplete++
+		case models.CostBasisUnreported:
+			report.Unreported++
+		}
+		report.Holdings = append(report.Holdings, c)
+	}
+	sort.SliceStable(report.Holdings, func(i, j int) bool {
+		a, b := report.Holdings[i], report.Holdings[j]
+		if statusOrder[a.Status] != statusOrder[b.Status] {
+			return statusOrder[a.Status] < statusOrder[b.Status]
+		}
+		return a.SecurityName < b.SecurityName
+	})
+	return report, nil
+}
+
+// trades returns a user's investment transactions by account and
+// security, keyed "account/security", leaving out deleted ones
+func (s *Service) trades(ctx context.Context, userID string) (map[string][]Trade, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, account_id, security_id::text, date::text, type, COALESCE(subtype, ''),
+		       COALESCE(quantity, 0), amount, price
+		FROM investment_transactions
+		WHERE user_id = $1 AND security_id IS NOT NULL AND deleted_at IS NULL
+		ORDER BY date, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query investment transactions: %w", err)
+	}
+	defer rows.Close()
+
+	trades := make(map[string][]Trade)
+	for rows.Next() {
+		var t Trade
+		var accountID, securityID string
+		if err := rows.Scan(&t.ID, &accountID, &securityID, &t.Date, &t.Type, &t.Subtype,
+			&t.Quantity, &t.Amount, &t.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan investment transaction: %w", err)
+		}
+		key := accountID + "/" + securityID
+		trades[key] = append(trades[key], t)
+	}
+	return trades, rows.Err()
+}
@@ -0,0 +1,113 @@
+package cpi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BLS reads series from the Bureau of Labor Statistics public data API
+type BLS struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewBLS creates a BLS source. apiKey may be empty, which the API accepts
+// with lower daily limits.
+func NewBLS(url, apiKey string) *BLS {
+	return &BLS{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Fetch returns the series' monthly values for the years from startYear
+// to endYear inclusive, oldest first. Annual averages, and months the API
+// has no value for, are left out.
+func (b *BLS) Fetch(ctx context.Context, seriesID string, startYear, endYear int) ([]Point, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"seriesid":        []string{seriesID},
+		"startyear":       strconv.Itoa(startYear),
+		"endyear":         strconv.Itoa(endYear),
+		"registrationkey": b.apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CPI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("BLS returned %d: %s", resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+
+	var out struct {
+		Status  string   `json:"status"`
+		Message []string `json:"message"`
+		Results struct {
+			Series []struct {
+				Data []struct {
+					Year   string `json:"year"`
+					Period string `json:"period"`
+					Value  string `json:"value"`
+				} `json:"data"`
+			} `json:"series"`
+		} `json:"Results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode CPI: %w", err)
+	}
+	if out.Status != "REQUEST_SUCCEEDED" {
+		return nil, fmt.Errorf("BLS request failed: %s: %s", out.Status, strings.Join(out.Message, "; "))
+	}
+
+	var points []Point
+	for _, series := range out.Results.Series {
+		for _, d := range series.Data {
+			// Periods M01 to M12 are months; M13 is the annual average
+			month, err := strconv.Atoi(strings.TrimPrefix(d.Period, "M"))
+			if err != nil || !strings.HasPrefix(d.Period, "M") || month < 1 || month > 12 {
+				continue
+			}
+			year, err := strconv.Atoi(d.Year)
+			if err != nil {
+				continue
+			}
+			value, err := decimal.NewFromString(d.Value)
+			if err != nil || !value.IsPositive() {
+				continue
+			}
+			points = append(points, Point{
+				Month: time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC),
+				Value: value,
+			})
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Month.Before(points[j].Month)
+	})
+	return points, nil
+}
@@ -0,0 +1,226 @@
+// Package cpi keeps the US Consumer Price Index, fetched monthly from the
+// Bureau of Labor Statistics, so amounts from past years can be restated
+// in current dollars and compared fairly.
+package cpi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/shopspring/decimal"
+)
+
+// maxYearsPerFetch is the most years of a series the BLS API returns to
+// a request without a registration key
+const maxYearsPerFetch = 10
+
+// Options sets where the index comes from and how often it is checked
+// for a newly published month
+type Options struct {
+	Interval     time.Duration // time between checks; 0 disables the worker
+	URL          string        // BLS timeseries API endpoint
+	SeriesID     string        // CPI series; CUUR0000SA0 is all items in U.S. city average, not seasonally adjusted
+	APIKey       string        // optional BLS registration key, which raises the API's daily limits
+	HistoryYears int           // years fetched when nothing is stored yet
+}
+
+// Point is a series' value for a month
+type Point struct {
+	Month time.Time
+	Value decimal.Decimal
+}
+
+// Source reports a series' monthly values for the years from startYear to
+// endYear inclusive
+type Source interface {
+	Fetch(ctx context.Context, seriesID string, startYear, endYear int) ([]Point, error)
+}
+
+// Service stores the index and restates amounts with it
+type Service struct {
+	db     *database.Database
+	locks  *locks.Locker
+	source Source
+	opts   Options
+}
+
+// NewService creates a CPI service. When locker is set, only one instance
+// fetches the index at a time.
+func NewService(db *database.Database, locker *locks.Locker, source Source, opts Options) *Service {
+	return &Service{db: db, locks: locker, source: source, opts: opts}
+}
+
+// Run checks for newly published months every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "cpi_refresh")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock CPI refresh", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	stored, err := s.Refresh(ctx, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to refresh CPI", "series_id", s.opts.SeriesID, "error", err)
+		return
+	}
+	if stored > 0 {
+		slog.InfoContext(ctx, "Refreshed CPI", "series_id", s.opts.SeriesID, "months", stored)
+	}
+}
+
+// Refresh fetches the months published since the latest stored, or
+// HistoryYears of them when none are, returning how many it stored. A
+// month's CPI is published during the next, so once last month is stored
+// nothing is fetched until the month turns.
+func (s *Service) Refresh(ctx context.Context, now time.Time) (int, error) {
+	var latest *time.Time
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT MAX(month) FROM cpi_index WHERE series_id = $1", s.opts.SeriesID).Scan(&latest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query latest CPI month: %w", err)
+	}
+
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	startYear := now.Year() - s.opts.HistoryYears + 1
+	if latest != nil {
+		if !latest.Before(lastMonth) {
+			return 0, nil
+		}
+		startYear = latest.Year()
+	}
+
+	stored := 0
+	for from := startYear; from <= now.Year(); from += maxYearsPerFetch {
+		to := from + maxYearsPerFetch - 1
+		if to > now.Year() {
+			to = now.Year()
+		}
+		points, err := s.source.Fetch(ctx, s.opts.SeriesID, from, to)
+		if err != nil {
+			return stored, err
+		}
+		n, err := s.store(ctx, points)
+		stored += n
+		if err != nil {
+			return stored, err
+		}
+	}
+	return stored, nil
+}
+
+// store upserts points, taking revised values, and returns how many it
+// wrote
+func (s *Service) store(ctx context.Context, points []Point) (int, error) {
+	stored := 0
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		for _, p := range points {
+			tag, err := s.db.Writer(ctx).Exec(ctx, `
+				INSERT INTO cpi_index (series_id, month, value)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (series_id, month) DO UPDATE SET value = EXCLUDED.value
+				WHERE cpi_index.value <> EXCLUDED.value
+			`, s.opts.SeriesID, p.Month, p.Value)
+			if err != nil {
+				return fmt.Errorf("failed to store CPI: %w", err)
+			}
+			stored += int(tag.RowsAffected())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
+
+// Index loads the stored series
+func (s *Service) Index(ctx context.Context) (*Index, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT month, value FROM cpi_index
+		WHERE series_id = $1
+		ORDER BY month
+	`, s.opts.SeriesID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CPI: %w", err)
+	}
+	defer rows.Close()
+
+	index := &Index{SeriesID: s.opts.SeriesID}
+	for rows.Next() {
+		var p Point
+		if err := rows.Scan(&p.Month, &p.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan CPI: %w", err)
+		}
+		index.points = append(index.points, p)
+	}
+	return index, rows.Err()
+}
+
+// Index is a CPI series by month, oldest first, which restates amounts
+// in the prices of its latest month
+type Index struct {
+	SeriesID string
+	points   []Point
+}
+
+// Empty reports whether the series has no months
+func (ix *Index) Empty() bool {
+	return len(ix.points) == 0
+}
+
+// Base returns the latest month, whose prices amounts are restated in
+func (ix *Index) Base() time.Time {
+	return ix.points[len(ix.points)-1].Month
+}
+
+// Factor is what an amount spent on date is multiplied by to restate it
+// in the prices of the latest month. Dates after the latest month use
+// that month, so their factor is 1; dates before the first use the first,
+// and months missing from the series use the one before.
+func (ix *Index) Factor(date time.Time) decimal.Decimal {
+	month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	i := sort.Search(len(ix.points), func(i int) bool {
+		return !ix.points[i].Month.Before(month)
+	})
+	switch {
+	case i == len(ix.points):
+		i--
+	case i > 0 && !ix.points[i].Month.Equal(month):
+		i--
+	}
+	return ix.points[len(ix.points)-1].Value.Div(ix.points[i].Value)
+}
+
+// Adjust restates amount, spent on date, in the prices of the latest
+// month, rounded to cents
+func (ix *Index) Adjust(amount decimal.Decimal, date time.Time) decimal.Decimal {
+	return amount.Mul(ix.Factor(date)).Round(2)
+}
@@ -0,0 +1,256 @@
+// Package cryptoincome records staking rewards and interest paid in
+// crypto, synced from exchange connectors. A payment is ordinary income
+// at its fair market value when received, which is also the cost basis of
+// the coins received, so each is stored at the exchange's price then.
+package cryptoincome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/prices"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of crypto income
+const (
+	KindStaking  = "staking"
+	KindInterest = "interest"
+)
+
+// Kinds lists every kind of income, in report order
+var Kinds = []string{KindStaking, KindInterest}
+
+// SourceRobinhood is the source of payments synced from Robinhood
+const SourceRobinhood = "robinhood"
+
+// syncOverlap is how far before the latest payment stored a sync starts,
+// catching payments an exchange posts late. Payments already stored are
+// skipped by their IDs.
+const syncOverlap = 7 * 24 * time.Hour
+
+// kinds maps the payment types exchanges report to kinds of income
+var kinds = map[string]string{
+	"staking":        KindStaking,
+	"staking_reward": KindStaking,
+	"reward":         KindStaking,
+	"interest":       KindInterest,
+	"lending":        KindInterest,
+}
+
+// Connector is an exchange paying crypto income
+type Connector interface {
+	// GetCryptoRewards returns the rewards and interest paid since a time,
+	// each with its id, symbol, type, quantity, USD price and received_at
+	GetCryptoRewards(ctx context.Context, since time.Time) ([]map[string]interface{}, error)
+}
+
+// Service syncs and reports crypto income
+type Service struct {
+	db         *database.Database
+	connectors map[string]Connector
+	prices     prices.Provider
+}
+
+// NewService creates a crypto income service syncing from connectors, by
+// source. Payments an exchange reports without a price are valued by
+// provider when synced.
+func NewService(db *database.Database, connectors map[string]Connector, provider prices.Provider) *Service {
+	return &Service{db: db, connectors: connectors, prices: provider}
+}
+
+// Sync stores the payments made to a user since the last sync from each
+// connector, returning how many were new
+func (s *Service) Sync(ctx context.Context, userID string) (int, error) {
+	sources := make([]string, 0, len(s.connectors))
+	for source := range s.connectors {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	added := 0
+	for _, source := range sources {
+		since, err := s.since(ctx, userID, source)
+		if err != nil {
+			return added, err
+		}
+		rewards, err := s.connectors[source].GetCryptoRewards(ctx, since)
+		if err != nil {
+			return added, fmt.Errorf("failed to fetch %s rewards: %w", source, err)
+		}
+
+		for _, raw := range rewards {
+			income, err := s.parse(ctx, source, raw)
+			if err != nil {
+				slog.WarnContext(ctx, "Skipping crypto income payment", "source", source, "error", err)
+				continue
+			}
+			tag, err := s.db.Pool.Exec(ctx, `
+				INSERT INTO crypto_income (user_id, source, external_id, symbol, kind, quantity, price, cost_basis, received_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				ON CONFLICT (user_id, source, external_id) DO NOTHING
+			`, userID, income.Source, income.ExternalID, income.Symbol, income.Kind, income.Quantity,
+				income.Price, income.CostBasis, income.ReceivedAt)
+			if err != nil {
+				return added, fmt.Errorf("failed to store crypto income: %w", err)
+			}
+			added += int(tag.RowsAffected())
+		}
+	}
+	return added, nil
+}
+
+// since is when to sync a source's payments from: syncOverlap before the
+// latest stored, or the beginning when there are none
+func (s *Service) since(ctx context.Context, userID, source string) (time.Time, error) {
+	var latest *time.Time
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT max(received_at) FROM crypto_income WHERE user_id = $1 AND source = $2
+	`, userID, source).Scan(&latest)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to query latest crypto income: %w", err)
+	}
+	if latest == nil {
+		return time.Time{}, nil
+	}
+	return latest.Add(-syncOverlap), nil
+}
+
+// parse reads a payment as a connector reports it
+func (s *Service) parse(ctx context.Context, source string, raw map[string]interface{}) (models.CryptoIncome, error) {
+	field := func(name string) string {
+		v, _ := raw[name].(string)
+		return strings.TrimSpace(v)
+	}
+
+	income := models.CryptoIncome{
+		Source:     source,
+		ExternalID: field("id"),
+		Symbol:     strings.ToUpper(field("symbol")),
+		Kind:       kinds[strings.ToLower(field("type"))],
+	}
+	if income.ExternalID == "" || income.Symbol == "" {
+		return income, errors.New("payment has no id or symbol")
+	}
+	if income.Kind == "" {
+		return income, fmt.Errorf("payment %s has unknown type %q", income.ExternalID, field("type"))
+	}
+
+	quantity, err := decimal.NewFromString(field("quantity"))
+	if err != nil || !quantity.IsPositive() {
+		return income, fmt.Errorf("payment %s has invalid quantity %q", income.ExternalID, field("quantity"))
+	}
+	income.Quantity = quantity
+
+	income.ReceivedAt, err = time.Parse(time.RFC3339, field("received_at"))
+	if err != nil {
+		return income, fmt.Errorf("payment %s has invalid received_at %q", income.ExternalID, field("received_at"))
+	}
+
+	// Without the exchange's price at receipt, the price now stands in;
+	// syncs run soon enough after payments for it to be close
+	if p := field("price"); p != "" {
+		income.Price, err = decimal.NewFromString(p)
+		if err != nil || income.Price.IsNegative() {
+			return income, fmt.Errorf("payment %s has invalid price %q", income.ExternalID, p)
+		}
+	} else {
+		income.Price, err = s.prices.Price(ctx, income.Symbol)
+		if err != nil {
+			return income, fmt.Errorf("payment %s: %w", income.ExternalID, err)
+		}
+	}
+	income.CostBasis = income.Quantity.Mul(income.Price).Round(2)
+	return income, nil
+}
+
+// List returns the payments made to a user from start to end, inclusive
+// dates in UTC, by when they were received
+func (s *Service) List(ctx context.Context, userID, start, end string) ([]models.CryptoIncome, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, source, external_id, symbol, kind, quantity, price, cost_basis, received_at
+		FROM crypto_income
+		WHERE user_id = $1
+		  AND received_at >= $2::date
+		  AND received_at < $3::date + 1
+		ORDER BY received_at, symbol
+	`, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query crypto income: %w", err)
+	}
+	defer rows.Close()
+
+	payments := []models.CryptoIncome{}
+	for rows.Next() {
+		var p models.CryptoIncome
+		if err := rows.Scan(&p.ID, &p.Source, &p.ExternalID, &p.Symbol, &p.Kind, &p.Quantity,
+			&p.Price, &p.CostBasis, &p.ReceivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan crypto income: %w", err)
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// Report totals payments from start to end by kind, asset, largest first,
+// and month. The total is the ordinary income to report for the period;
+// each asset's cost basis is the basis of the coins received.
+func Report(payments []models.CryptoIncome, start, end time.Time) models.CryptoIncomeReport {
+	report := models.CryptoIncomeReport{
+		Period: models.Period{
+			StartDate: start.Format(period.DateLayout),
+			EndDate:   end.Format(period.DateLayout),
+			Days:      int(end.Sub(start).Hours()/24) + 1,
+		},
+		Total:    decimal.Zero,
+		ByKind:   make(map[string]decimal.Decimal, len(Kinds)),
+		ByAsset:  []models.CryptoIncomeAsset{},
+		ByMonth:  []models.MonthlyCryptoIncome{},
+		Payments: payments,
+	}
+	for _, kind := range Kinds {
+		report.ByKind[kind] = decimal.Zero
+	}
+
+	months := make(map[string]int)
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for m := first; !m.After(end); m = m.AddDate(0, 1, 0) {
+		months[m.Format("2006-01")] = len(report.ByMonth)
+		report.ByMonth = append(report.ByMonth, models.MonthlyCryptoIncome{Month: m.Format("2006-01"), Amount: decimal.Zero})
+	}
+
+	assets := make(map[string]int)
+	for _, p := range payments {
+		report.Total = report.Total.Add(p.CostBasis)
+		report.ByKind[p.Kind] = report.ByKind[p.Kind].Add(p.CostBasis)
+		if i, ok := months[p.ReceivedAt.UTC().Format("2006-01")]; ok {
+			report.ByMonth[i].Amount = report.ByMonth[i].Amount.Add(p.CostBasis)
+		}
+
+		i, ok := assets[p.Symbol]
+		if !ok {
+			i = len(report.ByAsset)
+			assets[p.Symbol] = i
+			report.ByAsset = append(report.ByAsset, models.CryptoIncomeAsset{
+				Symbol: p.Symbol, Quantity: decimal.Zero, CostBasis: decimal.Zero,
+			})
+		}
+		a := &report.ByAsset[i]
+		a.Quantity = a.Quantity.Add(p.Quantity)
+		a.CostBasis = a.CostBasis.Add(p.CostBasis)
+		a.Count++
+	}
+	sort.SliceStable(report.ByAsset, func(i, j int) bool {
+		return report.ByAsset[i].CostBasis.GreaterThan(report.ByAsset[j].CostBasis)
+	})
+	return report
+}
@@ -3,24 +3,50 @@ package database
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Options sizes the connection pools and bounds how long connecting and
+// individual statements may take
+type Options struct {
+	MaxConns          int32
+	MinConns          int32
+	ConnectTimeout    time.Duration
+	StatementTimeout  time.Duration // server-side limit per statement; 0 disables
+	HealthCheckPeriod time.Duration // how often idle connections are checked
+	SlowQueryLog      time.Duration // queries slower than this are logged; 0 disables
+}
+
 type Database struct {
 	Pool *pgxpool.Pool
+	opts Options
+
+	// target holds the connection settings new pool connections use, so
+	// rotated credentials apply without replacing Pool
+	mu     sync.RWMutex
+	target *pgx.ConnConfig
+
+	// replica optionally serves reads that tolerate replication lag
+	replica *replica
 }
 
-func Connect(databaseURL string) (*Database, error) {
+func Connect(databaseURL string, opts Options) (*Database, error) {
 	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database URL: %w", err)
 	}
 
 	// Configure connection pool
-	config.MaxConns = 30
-	config.MinConns = 5
+	applyOptions(config, opts)
+
+	db := &Database{opts: opts, target: config.ConnConfig.Copy()}
+	config.BeforeConnect = db.applyTarget
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
@@ -32,13 +58,72 @@ func Connect(databaseURL string) (*Database, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Database{Pool: pool}, nil
+	db.Pool = pool
+	return db, nil
+}
+
+// applyOptions sets pool sizing and timeouts. The statement timeout is a
+// session default, so a query that needs longer can raise it with SET LOCAL.
+func applyOptions(config *pgxpool.Config, opts Options) {
+	if opts.MaxConns > 0 {
+		config.MaxConns = opts.MaxConns
+	}
+	config.MinConns = opts.MinConns
+	if opts.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	if opts.ConnectTimeout > 0 {
+		config.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+	}
+	if opts.StatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+	config.ConnConfig.Tracer = &queryTracer{slowThreshold: opts.SlowQueryLog}
+}
+
+// UpdateURL switches the pool to new connection settings, e.g. after a
+// credential rotation. The new settings are verified with a test connection,
+// then idle connections are closed so the pool reconnects with them.
+func (db *Database) UpdateURL(ctx context.Context, databaseURL string) error {
+	config, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect with new credentials: %w", err)
+	}
+	conn.Close(ctx)
+
+	db.mu.Lock()
+	db.target = config
+	db.mu.Unlock()
+
+	db.Pool.Reset()
+	return nil
+}
+
+func (db *Database) applyTarget(ctx context.Context, cc *pgx.ConnConfig) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	cc.Host = db.target.Host
+	cc.Port = db.target.Port
+	cc.Database = db.target.Database
+	cc.User = db.target.User
+	cc.Password = db.target.Password
+	cc.TLSConfig = db.target.TLSConfig
+	cc.Fallbacks = db.target.Fallbacks
+	return nil
 }
 
 func (db *Database) Close() {
 	if db.Pool != nil {
 		db.Pool.Close()
 	}
+	if db.replica != nil {
+		db.replica.close()
+	}
 }
 
 func ConnectRedis(redisURL string) *redis.Client {
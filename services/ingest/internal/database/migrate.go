@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/finagent/ingest/db/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	migratepgx "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// ErrPendingMigrations is returned by Check when the schema is behind the
+// migrations embedded in the binary
+var ErrPendingMigrations = errors.New("database schema has pending migrations")
+
+// MigrationStatus describes the schema version of a database
+type MigrationStatus struct {
+	Version uint `json:"version"`
+	Latest  uint `json:"latest"`
+	Dirty   bool `json:"dirty"`
+	Pending int  `json:"pending"`
+}
+
+// Migrator applies the embedded schema migrations, tracking the applied
+// version in the schema_migrations table
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+}
+
+// NewMigrator connects to the database for running migrations
+func NewMigrator(databaseURL string) (*Migrator, error) {
+	config, err := pgx.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	db := stdlib.OpenDB(*config)
+	driver, err := migratepgx.WithInstance(db, &migratepgx.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize migrations: %w", err)
+	}
+
+	return &Migrator{m: m, source: src}, nil
+}
+
+// Up applies all pending migrations
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return nil
+}
+
+// Force records version as applied without running anything and clears the
+// dirty flag. It is used to recover from a failed migration once fixed by
+// hand, or to adopt a database whose schema was applied before versioning.
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	return nil
+}
+
+// Status returns the applied and latest available schema versions
+func (mg *Migrator) Status() (*MigrationStatus, error) {
+	status := &MigrationStatus{}
+
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	status.Version = version
+	status.Dirty = dirty
+
+	if err := status.countMigrations(mg.source); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// MigrationStatus reads the schema version over the pool, for checks that
+// run too often to open a migration connection each time
+func (db *Database) MigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	status := &MigrationStatus{}
+
+	var version int64
+	err := db.Pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &status.Dirty)
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		status.Version = uint(version)
+	case errors.Is(err, pgx.ErrNoRows):
+	case errors.As(err, &pgErr) && pgErr.Code == "42P01": // undefined_table: never migrated
+	default:
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer src.Close()
+
+	if err := status.countMigrations(src); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// countMigrations fills in the latest available version and how many are
+// newer than the applied one
+func (status *MigrationStatus) countMigrations(src source.Driver) error {
+	v, err := src.First()
+	for err == nil {
+		if v > status.Latest {
+			status.Latest = v
+		}
+		if v > status.Version {
+			status.Pending++
+		}
+		v, err = src.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	return nil
+}
+
+// Check returns an error if the schema is dirty or has pending migrations
+func (mg *Migrator) Check() error {
+	status, err := mg.Status()
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("database schema is dirty at version %d; fix it and run migrate force", status.Version)
+	}
+	if status.Pending > 0 {
+		return fmt.Errorf("%w: at version %d of %d", ErrPendingMigrations, status.Version, status.Latest)
+	}
+	return nil
+}
+
+// Close releases the migration connection
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
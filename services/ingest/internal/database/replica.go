@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is the read/write query surface shared by pools and transactions
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type replicaKey struct{}
+
+// WithReplica marks ctx as tolerating replication lag, so Reader may serve
+// its queries from the read replica
+func WithReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// replica is a read-only pool with a background health check
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+	stop    chan struct{}
+}
+
+// AttachReplica connects a read replica and pings it every interval. Reads
+// fall back to the primary while the replica is unreachable.
+func (db *Database) AttachReplica(replicaURL string, interval time.Duration) error {
+	config, err := pgxpool.ParseConfig(replicaURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse replica URL: %w", err)
+	}
+	applyOptions(config, db.opts)
+	config.MinConns = 0
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return fmt.Errorf("failed to create replica pool: %w", err)
+	}
+
+	rep := &replica{pool: pool, stop: make(chan struct{})}
+	rep.check(interval)
+	go rep.watch(interval)
+
+	db.replica = rep
+	return nil
+}
+
+// Reader returns the pool to read from: the transaction ctx is running in,
+// else the replica when ctx was marked with WithReplica and the replica is
+// healthy, otherwise the primary
+func (db *Database) Reader(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	if db.replica != nil && db.replica.healthy.Load() {
+		if ok, _ := ctx.Value(replicaKey{}).(bool); ok {
+			return db.replica.pool
+		}
+	}
+	return db.Pool
+}
+
+// ReplicaStatus reports whether a replica is attached and currently healthy
+func (db *Database) ReplicaStatus() (attached, healthy bool) {
+	if db.replica == nil {
+		return false, false
+	}
+	return true, db.replica.healthy.Load()
+}
+
+func (r *replica) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check(interval)
+		}
+	}
+}
+
+func (r *replica) check(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := r.pool.Ping(ctx)
+	healthy := err == nil
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			slog.Info("Read replica healthy; routing reads to replica")
+		} else {
+			slog.Warn("Read replica unhealthy, falling back to primary", "error", err)
+		}
+	}
+}
+
+func (r *replica) close() {
+	close(r.stop)
+	r.pool.Close()
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer records every query and COPY as a span tagged with the
+// operation that issued it, and logs those slower than slowThreshold
+type queryTracer struct {
+	slowThreshold time.Duration
+}
+
+type tracedQueryKey struct{}
+
+type tracedQuery struct {
+	sql       string
+	operation string
+	start     time.Time
+	span      trace.Span
+}
+
+func (t *queryTracer) start(ctx context.Context, spanName, sql string) context.Context {
+	operation := tracing.Operation(ctx)
+	ctx, span := tracing.StartClientSpan(ctx, spanName)
+	span.SetAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBStatementKey.String(sql),
+		attribute.String("db.caller", operation),
+	)
+	return context.WithValue(ctx, tracedQueryKey{}, &tracedQuery{
+		sql:       sql,
+		operation: operation,
+		start:     time.Now(),
+		span:      span,
+	})
+}
+
+func (t *queryTracer) end(ctx context.Context, rows int64, err error) {
+	q, ok := ctx.Value(tracedQueryKey{}).(*tracedQuery)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(q.start)
+
+	q.span.SetAttributes(
+		attribute.Int64("db.rows", rows),
+		attribute.Int64("db.duration_ms", elapsed.Milliseconds()),
+	)
+	tracing.SetSpanError(q.span, err)
+	q.span.End()
+
+	if t.slowThreshold > 0 && elapsed >= t.slowThreshold {
+		slog.WarnContext(ctx, "Slow query", "operation", q.operation, "duration", elapsed, "rows", rows, "sql", compactSQL(q.sql))
+	}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.start(ctx, "db.query", data.SQL)
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t *queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	sql := fmt.Sprintf("COPY %s (%s) FROM STDIN", data.TableName.Sanitize(), strings.Join(data.ColumnNames, ", "))
+	return t.start(ctx, "db.copy", sql)
+}
+
+func (t *queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+// compactSQL collapses a query's whitespace onto one line for logging
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type txKey struct{}
+
+// InTx runs fn in a transaction on the primary, committing if fn returns
+// nil and rolling back otherwise. Queries fn makes through Writer or Reader
+// with the ctx it is given join the transaction. Calling InTx inside fn
+// opens a savepoint, so a nested failure rolls back only its own writes.
+func (db *Database) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := db.Writer(ctx).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Writer returns the transaction ctx is running in, or the primary pool
+func (db *Database) Writer(ctx context.Context) Querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db.Pool
+}
@@ -0,0 +1,320 @@
+// Package dedup finds accounts and transactions that reached the database
+// through more than one source, such as the same bank account linked under
+// two Plaid items, and links them so balances and spending count them once.
+// It also pairs refunds with the charges they reverse, so that neither
+// skews spending.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Record types
+const (
+	RecordAccount     = "account"
+	RecordTransaction = "transaction"
+)
+
+// Link statuses. Detected and confirmed duplicates are left out of reads;
+// rejected links keep both records and are never detected again.
+const (
+	StatusDetected  = "detected"
+	StatusConfirmed = "confirmed"
+	StatusRejected  = "rejected"
+)
+
+var (
+	// ErrNotFound is returned for a link that does not exist or belongs to
+	// another user
+	ErrNotFound = errors.New("duplicate link not found")
+	// ErrInvalidLink is returned when the records to link are not two
+	// distinct records of the user
+	ErrInvalidLink = errors.New("records cannot be linked")
+)
+
+// Engine detects duplicates and records user overrides
+type Engine struct {
+	db  *database.Database
+	enc *encryption.Service
+}
+
+// NewEngine creates a new deduplication engine
+func NewEngine(db *database.Database, enc *encryption.Service) *Engine {
+	return &Engine{db: db, enc: enc}
+}
+
+// ScanResult counts the links a scan created
+type ScanResult struct {
+	Accounts     int `json:"accounts"`
+	Transactions int `json:"transactions"`
+	Refunds      int `json:"refunds"`
+}
+
+type candidate struct {
+	id          string
+	plaidItemID string
+	fingerprint string
+	createdAt   time.Time
+}
+
+// Scan links a user's accounts that share a fingerprint across different
+// items, then the matching transactions of every linked account pair, then
+// refunds with their charges. The earliest linked account is kept as
+// canonical. Existing links, including rejected ones, are left as they are.
+func (e *Engine) Scan(ctx context.Context, userID string) (*ScanResult, error) {
+	candidates, err := e.accountCandidates(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]candidate)
+	for _, c := range candidates {
+		groups[c.fingerprint] = append(groups[c.fingerprint], c)
+	}
+
+	result := &ScanResult{}
+	err = e.db.InTx(ctx, func(ctx context.Context) error {
+		for fingerprint, group := range groups {
+			sort.Slice(group, func(i, j int) bool {
+				if group[i].createdAt.Equal(group[j].createdAt) {
+					return group[i].id < group[j].id
+				}
+				return group[i].createdAt.Before(group[j].createdAt)
+			})
+
+			canonical := group[0]
+			for _, c := range group[1:] {
+				// Accounts of one item are distinct even if they look alike
+				if c.plaidItemID == canonical.plaidItemID {
+					continue
+				}
+				tag, err := e.db.Writer(ctx).Exec(ctx, `
+					INSERT INTO duplicate_links (user_id, record_type, canonical_id, duplicate_id, fingerprint)
+					VALUES ($1, 'account', $2, $3, $4)
+					ON CONFLICT (record_type, canonical_id, duplicate_id) DO NOTHING
+				`, userID, canonical.id, c.id, fingerprint)
+				if err != nil {
+					return fmt.Errorf("failed to link duplicate account: %w", err)
+				}
+				result.Accounts += int(tag.RowsAffected())
+			}
+		}
+
+		count, err := e.linkTransactions(ctx, userID)
+		if err != nil {
+			return err
+		}
+		result.Transactions = count
+
+		if result.Refunds, err = e.linkRefunds(ctx, userID); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// accountCandidates fingerprints a user's open accounts by type, subtype,
+// currency and mask. Accounts without a mask cannot be told apart and are
+// skipped.
+func (e *Engine) accountCandidates(ctx context.Context, userID string) ([]candidate, error) {
+	rows, err := e.db.Pool.Query(ctx, `
+		SELECT id, COALESCE(plaid_item_id::text, ''), type, COALESCE(subtype, ''), currency, mask, created_at
+		FROM accounts
+		WHERE user_id = $1 AND is_closed = false AND deleted_at IS NULL
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	type account struct {
+		candidate
+		accountType, subtype, currency string
+		mask                           *string
+	}
+	var accounts []account
+	for rows.Next() {
+		var a account
+		if err := rows.Scan(&a.id, &a.plaidItemID, &a.accountType, &a.subtype, &a.currency, &a.mask, &a.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+
+	candidates := make([]candidate, 0, len(accounts))
+	for _, a := range accounts {
+		mask, err := e.enc.DecryptStringPtr(ctx, a.mask)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt mask of account %s: %w", a.id, err)
+		}
+		if mask == nil || *mask == "" {
+			continue
+		}
+		a.fingerprint = fingerprint(a.accountType, a.subtype, a.currency, *mask)
+		candidates = append(candidates, a.candidate)
+	}
+	return candidates, nil
+}
+
+// linkTransactions pairs the transactions of each linked account pair by
+// date, amount and description. Repeats of the same purchase on one day
+// are paired in order, so an extra copy on one side stays unlinked.
+func (e *Engine) linkTransactions(ctx context.Context, userID string) (int, error) {
+	tag, err := e.db.Writer(ctx).Exec(ctx, `
+		WITH t AS (
+			SELECT id, account_id, date, amount,
+			       lower(COALESCE(merchant_name, description, '')) AS name,
+			       row_number() OVER (
+			           PARTITION BY account_id, date, amount, lower(COALESCE(merchant_name, description, ''))
+			           ORDER BY id) AS n
+			FROM transactions
+			WHERE user_id = $1 AND deleted_at IS NULL
+		)
+		INSERT INTO duplicate_links (user_id, record_type, canonical_id, duplicate_id, parent_id, fingerprint)
+		SELECT $1, 'transaction', c.id, d.id, l.id,
+		       encode(sha256(convert_to(c.date::text || '|' || c.amount::text || '|' || c.name, 'UTF8')), 'hex')
+		FROM duplicate_links l
+		JOIN t c ON c.account_id = l.canonical_id
+		JOIN t d ON d.account_id = l.duplicate_id
+		        AND d.date = c.date AND d.amount = c.amount AND d.name = c.name AND d.n = c.n
+		WHERE l.user_id = $1 AND l.record_type = 'account' AND l.status <> 'rejected'
+		ON CONFLICT (record_type, canonical_id, duplicate_id) DO NOTHING
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to link duplicate transactions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// List returns a user's links, newest first; empty recordType or status
+// match all links
+func (e *Engine) List(ctx context.Context, userID, recordType, status string, limit, offset int) ([]models.DuplicateLink, error) {
+	rows, err := e.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, record_type, canonical_id, duplicate_id, parent_id,
+		       fingerprint, status, created_at, updated_at
+		FROM duplicate_links
+		WHERE user_id = $1 AND ($2 = '' OR record_type = $2) AND ($3 = '' OR status = $3)
+		ORDER BY created_at DESC, id
+		LIMIT $4 OFFSET $5
+	`, userID, recordType, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.DuplicateLink{}
+	for rows.Next() {
+		var link models.DuplicateLink
+		err := rows.Scan(&link.ID, &link.UserID, &link.RecordType, &link.CanonicalID, &link.DuplicateID,
+			&link.ParentID, &link.Fingerprint, &link.Status, &link.CreatedAt, &link.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Link marks duplicateID as a confirmed copy of canonicalID, for
+// duplicates detection missed. An existing link between them is confirmed.
+func (e *Engine) Link(ctx context.Context, userID, recordType, canonicalID, duplicateID string) (*models.DuplicateLink, error) {
+	var table string
+	switch recordType {
+	case RecordAccount:
+		table = "accounts"
+	case RecordTransaction:
+		table = "transactions"
+	default:
+		return nil, ErrInvalidLink
+	}
+	if canonicalID == duplicateID {
+		return nil, ErrInvalidLink
+	}
+
+	var owned int
+	err := e.db.Pool.QueryRow(ctx,
+		"SELECT COUNT(*) FROM "+table+" WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL",
+		userID, []string{canonicalID, duplicateID}).Scan(&owned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up records to link: %w", err)
+	}
+	if owned != 2 {
+		return nil, ErrInvalidLink
+	}
+
+	var link models.DuplicateLink
+	err = e.db.Pool.QueryRow(ctx, `
+		INSERT INTO duplicate_links (user_id, record_type, canonical_id, duplicate_id, status)
+		VALUES ($1, $2, $3, $4, 'confirmed')
+		ON CONFLICT (record_type, canonical_id, duplicate_id) DO UPDATE SET status = 'confirmed'
+		RETURNING id, user_id, record_type, canonical_id, duplicate_id, parent_id,
+		          fingerprint, status, created_at, updated_at
+	`, userID, recordType, canonicalID, duplicateID).Scan(&link.ID, &link.UserID, &link.RecordType,
+		&link.CanonicalID, &link.DuplicateID, &link.ParentID, &link.Fingerprint, &link.Status,
+		&link.CreatedAt, &link.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link duplicates: %w", err)
+	}
+	return &link, nil
+}
+
+// SetStatus confirms or rejects a user's link. Rejecting an account link
+// also drops the transaction links detected under it; confirming it again
+// lets the next scan find them anew.
+func (e *Engine) SetStatus(ctx context.Context, userID, linkID, status string) error {
+	if status != StatusConfirmed && status != StatusRejected {
+		return fmt.Errorf("invalid duplicate link status %q", status)
+	}
+
+	return e.db.InTx(ctx, func(ctx context.Context) error {
+		var recordType string
+		err := e.db.Writer(ctx).QueryRow(ctx, `
+			UPDATE duplicate_links SET status = $3
+			WHERE id = $1 AND user_id = $2
+			RETURNING record_type
+		`, linkID, userID, status).Scan(&recordType)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update duplicate link: %w", err)
+		}
+
+		if recordType == RecordAccount && status == StatusRejected {
+			_, err := e.db.Writer(ctx).Exec(ctx,
+				"DELETE FROM duplicate_links WHERE parent_id = $1 AND status = 'detected'", linkID)
+			if err != nil {
+				return fmt.Errorf("failed to drop detected transaction links: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func fingerprint(parts ...string) string {
+	normalized := make([]string, len(parts))
+	for i, part := range parts {
+		normalized[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(normalized, "|")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,190 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+)
+
+// RefundWindow is how long after a charge a refund of it may arrive
+const RefundWindow = 90 * 24 * time.Hour
+
+// ErrRefundPaired is returned when confirming a rejected refund link whose
+// charge or refund has since been paired with another transaction
+var ErrRefundPaired = errors.New("transaction already paired with another")
+
+// refundCandidate is a posted transaction not yet paired with another
+type refundCandidate struct {
+	id     string
+	date   time.Time
+	amount decimal.Decimal
+	name   string
+}
+
+// linkRefunds pairs each of a user's refunds with the charge it reverses:
+// the latest unpaired charge from the same merchant of exactly the
+// inverse amount, on or before the refund and within RefundWindow of it.
+// Duplicate copies, pending transactions and pairs the user rejected are
+// never paired.
+func (e *Engine) linkRefunds(ctx context.Context, userID string) (int, error) {
+	db := e.db.Writer(ctx)
+
+	rows, err := db.Query(ctx, `
+		SELECT t.id, t.date, t.amount, lower(COALESCE(t.merchant_name, t.description))
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.deleted_at IS NULL AND t.is_pending = false AND t.amount <> 0
+		  AND COALESCE(t.merchant_name, t.description, '') <> ''
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'transaction' AND dl.duplicate_id = t.id AND dl.status <> 'rejected')
+		  AND NOT EXISTS (
+		      SELECT 1 FROM refund_links rl
+		      WHERE (rl.charge_id = t.id OR rl.refund_id = t.id) AND rl.status <> 'rejected')
+		ORDER BY t.date, t.id
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query refund candidates: %w", err)
+	}
+	// Plaid amounts are positive for money leaving the account, so a
+	// refund is a negative amount matching a positive charge
+	charges := make(map[string][]refundCandidate)
+	var refunds []refundCandidate
+	for rows.Next() {
+		var c refundCandidate
+		if err := rows.Scan(&c.id, &c.date, &c.amount, &c.name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan refund candidate: %w", err)
+		}
+		if c.amount.IsPositive() {
+			key := c.name + "|" + c.amount.String()
+			charges[key] = append(charges[key], c)
+		} else {
+			refunds = append(refunds, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query refund candidates: %w", err)
+	}
+	if len(refunds) == 0 {
+		return 0, nil
+	}
+
+	rejected, err := e.rejectedRefunds(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	paired := make(map[string]bool)
+	for _, refund := range refunds {
+		group := charges[refund.name+"|"+refund.amount.Neg().String()]
+		// Candidates are in date order, so the first fit walking back is
+		// the closest charge before the refund
+		for i := len(group) - 1; i >= 0; i-- {
+			charge := group[i]
+			if charge.date.After(refund.date) || paired[charge.id] || rejected[charge.id+"|"+refund.id] {
+				continue
+			}
+			if refund.date.Sub(charge.date) > RefundWindow {
+				break
+			}
+			tag, err := db.Exec(ctx, `
+				INSERT INTO refund_links (user_id, charge_id, refund_id)
+				VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING
+			`, userID, charge.id, refund.id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to link refund: %w", err)
+			}
+			paired[charge.id] = true
+			linked += int(tag.RowsAffected())
+			break
+		}
+	}
+	return linked, nil
+}
+
+// rejectedRefunds returns the charge|refund pairs a user rejected
+func (e *Engine) rejectedRefunds(ctx context.Context, userID string) (map[string]bool, error) {
+	rows, err := e.db.Writer(ctx).Query(ctx,
+		"SELECT charge_id, refund_id FROM refund_links WHERE user_id = $1 AND status = 'rejected'", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejected refunds: %w", err)
+	}
+	defer rows.Close()
+
+	rejected := make(map[string]bool)
+	for rows.Next() {
+		var chargeID, refundID string
+		if err := rows.Scan(&chargeID, &refundID); err != nil {
+			return nil, fmt.Errorf("failed to scan rejected refund: %w", err)
+		}
+		rejected[chargeID+"|"+refundID] = true
+	}
+	return rejected, rows.Err()
+}
+
+// ListRefunds returns a user's refund links with the transactions they
+// pair, newest refund first; an empty status matches all links
+func (e *Engine) ListRefunds(ctx context.Context, userID, status string, limit, offset int) ([]models.RefundLink, error) {
+	rows, err := e.db.Reader(ctx).Query(ctx, `
+		SELECT rl.id, rl.charge_id, rl.refund_id, c.merchant_name, COALESCE(c.description, ''), c.amount,
+		       c.date, r.date, rl.status, rl.created_at, rl.updated_at
+		FROM refund_links rl
+		JOIN transactions c ON c.id = rl.charge_id
+		JOIN transactions r ON r.id = rl.refund_id
+		WHERE rl.user_id = $1 AND ($2 = '' OR rl.status = $2)
+		ORDER BY r.date DESC, rl.id
+		LIMIT $3 OFFSET $4
+	`, userID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refund links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.RefundLink{}
+	for rows.Next() {
+		var link models.RefundLink
+		err := rows.Scan(&link.ID, &link.ChargeID, &link.RefundID, &link.MerchantName, &link.Description,
+			&link.Amount, &link.ChargeDate, &link.RefundDate, &link.Status, &link.CreatedAt, &link.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// SetRefundStatus confirms or rejects a user's refund link. A rejected
+// pair counts in spending again, and its transactions may be paired
+// with others by the next scan.
+func (e *Engine) SetRefundStatus(ctx context.Context, userID, linkID, status string) error {
+	if status != StatusConfirmed && status != StatusRejected {
+		return fmt.Errorf("invalid refund link status %q", status)
+	}
+
+	var id string
+	err := e.db.Pool.QueryRow(ctx, `
+		UPDATE refund_links SET status = $3
+		WHERE id::text = $1 AND user_id = $2
+		RETURNING id
+	`, linkID, userID, status).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrRefundPaired
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update refund link: %w", err)
+	}
+	return nil
+}
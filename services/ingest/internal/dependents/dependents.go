@@ -0,0 +1,395 @@
+// Package dependents keeps profiles of a user's children and other
+// dependents with a pocket money ledger for each: allowances credited on
+// a schedule, money earned for chores, and money paid out or spent on
+// their behalf. Dependents do not sign in; their parent manages them, and
+// a dependent's scopes limit what anyone else acting for the parent, such
+// as a grantee or an agent, sees of them.
+package dependents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MaxDependents bounds the dependents a user may keep
+	MaxDependents = 20
+	// MaxSchedules bounds the allowance schedules of a dependent
+	MaxSchedules = 10
+)
+
+// maxPostings bounds how many payments of one schedule are credited at
+// once, so a schedule started long ago cannot flood the ledger
+const maxPostings = 400
+
+var (
+	// ErrNotFound is returned for a dependent or schedule that does not
+	// exist or belongs to another user
+	ErrNotFound = errors.New("dependent not found")
+	// ErrLimit is returned when the user has MaxDependents dependents or
+	// the dependent has MaxSchedules schedules
+	ErrLimit = errors.New("dependent limit reached")
+)
+
+// Scopes lists the dependent data scopes
+var Scopes = []string{models.DependentScopeBalance, models.DependentScopeLedger, models.DependentScopeAllowances}
+
+// IsScope reports whether s is a dependent data scope
+func IsScope(s string) bool {
+	for _, scope := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether a dependent shares scope
+func HasScope(d models.Dependent, scope string) bool {
+	for _, s := range d.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFrequency reports whether f is an allowance frequency
+func IsFrequency(f string) bool {
+	return f == models.AllowanceWeekly || f == models.AllowanceBiweekly || f == models.AllowanceMonthly
+}
+
+// Next returns the payment after date on a schedule of frequency. Monthly
+// allowances fall on the same day of the next month, or its last day when
+// it is shorter, and stay on that earlier day from then on.
+func Next(date time.Time, frequency string) time.Time {
+	switch frequency {
+	case models.AllowanceWeekly:
+		return date.AddDate(0, 0, 7)
+	case models.AllowanceBiweekly:
+		return date.AddDate(0, 0, 14)
+	}
+	firstOfNext := time.Date(date.Year(), date.Month()+1, 1, 0, 0, 0, 0, date.Location())
+	lastDay := firstOfNext.AddDate(0, 1, -1).Day()
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfNext.AddDate(0, 0, day-1)
+}
+
+// Service keeps dependents, their allowance schedules and ledgers
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a dependent service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+const dependentColumns = "id, user_id, name, birth_year, scopes, created_at, updated_at"
+
+func scanDependent(row pgx.Row) (models.Dependent, error) {
+	var d models.Dependent
+	err := row.Scan(&d.ID, &d.UserID, &d.Name, &d.BirthYear, &d.Scopes, &d.CreatedAt, &d.UpdatedAt)
+	return d, err
+}
+
+// Create adds a dependent and sets its ID and timestamps
+func (s *Service) Create(ctx context.Context, d *models.Dependent) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO dependents (user_id, name, birth_year, scopes)
+		SELECT $1, $2, $3, $4
+		WHERE (SELECT count(*) FROM dependents WHERE user_id = $1) < $5
+		RETURNING id, created_at, updated_at
+	`, d.UserID, d.Name, d.BirthYear, d.Scopes, MaxDependents).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLimit
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create dependent: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's dependents by name, without balances, schedules
+// or ledgers
+func (s *Service) List(ctx context.Context, userID string) ([]models.Dependent, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT `+dependentColumns+`
+		FROM dependents
+		WHERE user_id = $1
+		ORDER BY lower(name), created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependents: %w", err)
+	}
+	defer rows.Close()
+
+	dependents := []models.Dependent{}
+	for rows.Next() {
+		d, err := scanDependent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dependent: %w", err)
+		}
+		dependents = append(dependents, d)
+	}
+	return dependents, rows.Err()
+}
+
+// Get returns one of a user's dependents, or ErrNotFound
+func (s *Service) Get(ctx context.Context, userID, id string) (*models.Dependent, error) {
+	d, err := scanDependent(s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT `+dependentColumns+` FROM dependents WHERE id::text = $1 AND user_id = $2
+	`, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependent: %w", err)
+	}
+	return &d, nil
+}
+
+// DependentUpdate holds the fields of a dependent to change; nil fields
+// are left as they are. A BirthYear of zero clears it.
+type DependentUpdate struct {
+	Name      *string
+	BirthYear *int
+	Scopes    []string
+}
+
+// Update changes one of a user's dependents, returning it, or ErrNotFound
+func (s *Service) Update(ctx context.Context, userID, id string, u DependentUpdate) (*models.Dependent, error) {
+	d, err := scanDependent(s.db.Pool.QueryRow(ctx, `
+		UPDATE dependents SET
+			name = COALESCE($3, name),
+			birth_year = CASE WHEN $4::integer IS NULL THEN birth_year ELSE NULLIF($4, 0) END,
+			scopes = COALESCE($5, scopes)
+		WHERE id::text = $1 AND user_id = $2
+		RETURNING `+dependentColumns+`
+	`, id, userID, u.Name, u.BirthYear, u.Scopes))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update dependent: %w", err)
+	}
+	return &d, nil
+}
+
+// Delete deletes one of a user's dependents with their schedules and
+// ledger
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx, "DELETE FROM dependents WHERE id::text = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete dependent: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Balances returns the ledger balance of each of a user's dependents that
+// has entries, by dependent ID
+func (s *Service) Balances(ctx context.Context, userID string) (map[string]decimal.Decimal, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT dependent_id, sum(amount) FROM dependent_ledger WHERE user_id = $1 GROUP BY dependent_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependent balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var id string
+		var balance decimal.Decimal
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, fmt.Errorf("failed to scan dependent balance: %w", err)
+		}
+		balances[id] = balance
+	}
+	return balances, rows.Err()
+}
+
+// AddSchedule adds an allowance schedule to one of a user's dependents and
+// sets its ID and creation time, or returns ErrNotFound or ErrLimit
+func (s *Service) AddSchedule(ctx context.Context, userID string, a *models.AllowanceSchedule) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO allowance_schedules (user_id, dependent_id, amount, frequency, next_date, description)
+		SELECT $1, d.id, $3, $4, $5, $6
+		FROM dependents d
+		WHERE d.id::text = $2 AND d.user_id = $1
+		  AND (SELECT count(*) FROM allowance_schedules WHERE dependent_id = d.id) < $7
+		RETURNING id, created_at
+	`, userID, a.DependentID, a.Amount, a.Frequency, a.NextDate, a.Description, MaxSchedules).Scan(&a.ID, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.Get(ctx, userID, a.DependentID); err != nil {
+			return err
+		}
+		return ErrLimit
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create allowance schedule: %w", err)
+	}
+	return nil
+}
+
+// Schedules returns a dependent's allowance schedules, next due first
+func (s *Service) Schedules(ctx context.Context, userID, dependentID string) ([]models.AllowanceSchedule, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, dependent_id, amount, frequency, next_date::text, description, created_at
+		FROM allowance_schedules
+		WHERE user_id = $1 AND dependent_id::text = $2
+		ORDER BY next_date, created_at
+	`, userID, dependentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query allowance schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.AllowanceSchedule{}
+	for rows.Next() {
+		var a models.AllowanceSchedule
+		if err := rows.Scan(&a.ID, &a.DependentID, &a.Amount, &a.Frequency, &a.NextDate, &a.Description,
+			&a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan allowance schedule: %w", err)
+		}
+		schedules = append(schedules, a)
+	}
+	return schedules, rows.Err()
+}
+
+// DeleteSchedule stops one of a dependent's allowances. Allowances it
+// already credited stay in the ledger.
+func (s *Service) DeleteSchedule(ctx context.Context, userID, dependentID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM allowance_schedules WHERE id::text = $1 AND dependent_id::text = $2 AND user_id = $3
+	`, id, dependentID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete allowance schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddEntry adds an entry to one of a user's dependents' ledger and sets
+// its ID and creation time, or returns ErrNotFound
+func (s *Service) AddEntry(ctx context.Context, userID string, e *models.DependentLedgerEntry) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO dependent_ledger (user_id, dependent_id, kind, amount, description, entry_date)
+		SELECT $1, d.id, $3, $4, $5, $6
+		FROM dependents d
+		WHERE d.id::text = $2 AND d.user_id = $1
+		RETURNING id, created_at
+	`, userID, e.DependentID, e.Kind, e.Amount, e.Description, e.Date).Scan(&e.ID, &e.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add ledger entry: %w", err)
+	}
+	return nil
+}
+
+// Ledger returns a dependent's entries dated from start to end, inclusive
+// dates, newest first
+func (s *Service) Ledger(ctx context.Context, userID, dependentID, start, end string) ([]models.DependentLedgerEntry, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, dependent_id, kind, amount, description, entry_date::text, schedule_id, created_at
+		FROM dependent_ledger
+		WHERE user_id = $1 AND dependent_id::text = $2 AND entry_date BETWEEN $3 AND $4
+		ORDER BY entry_date DESC, created_at DESC
+	`, userID, dependentID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.DependentLedgerEntry{}
+	for rows.Next() {
+		var e models.DependentLedgerEntry
+		if err := rows.Scan(&e.ID, &e.DependentID, &e.Kind, &e.Amount, &e.Description, &e.Date, &e.ScheduleID,
+			&e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// PostDue credits the allowances of a user's dependents that have fallen
+// due by today to their ledgers and moves each schedule on to its next
+// payment, returning how many were credited. Allowances are credited when
+// a dependent is next read rather than by a job, so a schedule only ever
+// lags until someone looks.
+func (s *Service) PostDue(ctx context.Context, userID string, today time.Time) (int, error) {
+	posted := 0
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		rows, err := s.db.Writer(ctx).Query(ctx, `
+			SELECT id, dependent_id, amount, frequency, next_date::text, description
+			FROM allowance_schedules
+			WHERE user_id = $1 AND next_date <= $2
+			FOR UPDATE
+		`, userID, today.Format(period.DateLayout))
+		if err != nil {
+			return fmt.Errorf("failed to query due allowances: %w", err)
+		}
+		var due []models.AllowanceSchedule
+		for rows.Next() {
+			var a models.AllowanceSchedule
+			if err := rows.Scan(&a.ID, &a.DependentID, &a.Amount, &a.Frequency, &a.NextDate, &a.Description); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan allowance schedule: %w", err)
+			}
+			due = append(due, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, a := range due {
+			date, err := time.ParseInLocation(period.DateLayout, a.NextDate, today.Location())
+			if err != nil {
+				return fmt.Errorf("failed to parse allowance date: %w", err)
+			}
+			for n := 0; !date.After(today) && n < maxPostings; n++ {
+				tag, err := s.db.Writer(ctx).Exec(ctx, `
+					INSERT INTO dependent_ledger (user_id, dependent_id, kind, amount, description, entry_date, schedule_id)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)
+					ON CONFLICT (schedule_id, entry_date) DO NOTHING
+				`, userID, a.DependentID, models.LedgerAllowance, a.Amount, a.Description,
+					date.Format(period.DateLayout), a.ID)
+				if err != nil {
+					return fmt.Errorf("failed to credit allowance: %w", err)
+				}
+				posted += int(tag.RowsAffected())
+				date = Next(date, a.Frequency)
+			}
+			if _, err := s.db.Writer(ctx).Exec(ctx,
+				"UPDATE allowance_schedules SET next_date = $2 WHERE id = $1",
+				a.ID, date.Format(period.DateLayout)); err != nil {
+				return fmt.Errorf("failed to advance allowance schedule: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return posted, nil
+}
@@ -0,0 +1,80 @@
+// Package descriptors reads what kind of payment a transaction was from
+// the details Plaid reports and the descriptor the bank sends, such as
+// "CHECK # 1042" or "ATM WITHDRAWAL 1200 MAIN ST", so that checks and
+// cash withdrawals can be filtered on.
+package descriptors
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/finagent/ingest/internal/models"
+)
+
+// Kinds a transaction can be filtered by
+const (
+	KindCheck = "check"
+	KindATM   = "atm"
+)
+
+var (
+	// checkPattern captures the number of a check in a descriptor. A
+	// number must follow directly, so "CHECK CARD PURCHASE" and "CHECK
+	// DEPOSIT" are not checks.
+	checkPattern = regexp.MustCompile(`(?i)\b(?:CHECK|CHK|CHEQUE)\b(?:\s+PAID)?\s*(?:#|NO\.?|NUMBER)?\s*(\d{1,10})\b`)
+	atmPattern   = regexp.MustCompile(`(?i)\bATM\b`)
+	// notWithdrawal marks ATM descriptors of fees, their rebates and
+	// transfers rather than cash taken out
+	notWithdrawal = regexp.MustCompile(`(?i)\b(?:FEE|SURCHARGE|REBATE|REFUND|REIMB\w*|TRANSFER|XFER|DEPOSIT|PAYMENT)\b`)
+)
+
+// Details are the structured fields found for a transaction
+type Details struct {
+	CheckNumber     *string
+	IsATMWithdrawal bool
+}
+
+// Parse finds a transaction's check number, preferring the one Plaid
+// reports, and whether it was cash taken out at an ATM
+func Parse(txn models.PlaidTransaction) Details {
+	var d Details
+	if txn.CheckNumber != nil {
+		d.CheckNumber = normalizeCheckNumber(*txn.CheckNumber)
+	}
+	if d.CheckNumber == nil {
+		if m := checkPattern.FindStringSubmatch(txn.Name); m != nil {
+			d.CheckNumber = normalizeCheckNumber(m[1])
+		}
+	}
+	d.IsATMWithdrawal = isATMWithdrawal(txn)
+	return d
+}
+
+// normalizeCheckNumber drops the leading zeros some banks pad check
+// numbers with, returning nil when no number is left
+func normalizeCheckNumber(number string) *string {
+	number = strings.TrimLeft(strings.TrimSpace(number), "0")
+	if number == "" {
+		return nil
+	}
+	return &number
+}
+
+// isATMWithdrawal reports whether money left the account as cash at an
+// ATM, by Plaid's transaction code or category or by the descriptor
+func isATMWithdrawal(txn models.PlaidTransaction) bool {
+	if !txn.Amount.IsPositive() || notWithdrawal.MatchString(txn.Name) {
+		return false
+	}
+	if txn.TransactionCode != nil && *txn.TransactionCode == "atm" {
+		return true
+	}
+	if len(txn.Category) > 0 && txn.Category[0] != "Bank Fees" {
+		for _, c := range txn.Category[1:] {
+			if c == "ATM" {
+				return true
+			}
+		}
+	}
+	return atmPattern.MatchString(txn.Name)
+}
@@ -0,0 +1,120 @@
+// Package devenv runs the service's backing stores in-process, so local demos
+// and tests need neither a Postgres server nor Redis. Postgres is a real
+// server downloaded once and cached, so every migration and query behaves as
+// in production; Redis is an in-memory implementation of its protocol whose
+// key TTLs are advanced with the wall clock.
+package devenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// Options configures the embedded stores
+type Options struct {
+	Enabled      bool
+	DataDir      string // Postgres data kept between runs; empty uses a throwaway directory
+	PostgresPort uint32
+	RedisAddr    string // empty picks a free port
+}
+
+// Env is a running embedded Postgres and Redis
+type Env struct {
+	DatabaseURL string
+	RedisURL    string
+
+	postgres *embeddedpostgres.EmbeddedPostgres
+	redis    *miniredis.Miniredis
+	tempDir  string
+	stop     chan struct{}
+}
+
+// Start launches Postgres and Redis. The first start downloads the Postgres
+// binaries into the user cache directory.
+func Start(opts Options) (*Env, error) {
+	env := &Env{}
+
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		dir, err := os.MkdirTemp("", "finagent-devenv-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+		env.tempDir = dir
+		dataDir = dir
+	}
+
+	pgConfig := embeddedpostgres.DefaultConfig().
+		Port(opts.PostgresPort).
+		Database("finagent").
+		DataPath(filepath.Join(dataDir, "postgres")).
+		RuntimePath(filepath.Join(dataDir, "runtime")).
+		Logger(io.Discard)
+	env.postgres = embeddedpostgres.NewDatabase(pgConfig)
+	if err := env.postgres.Start(); err != nil {
+		env.cleanup()
+		return nil, fmt.Errorf("failed to start embedded postgres: %w", err)
+	}
+	env.DatabaseURL = pgConfig.GetConnectionURL() + "?sslmode=disable"
+
+	env.redis = miniredis.NewMiniRedis()
+	addr := opts.RedisAddr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	if err := env.redis.StartAddr(addr); err != nil {
+		env.Stop()
+		return nil, fmt.Errorf("failed to start in-memory redis: %w", err)
+	}
+	env.RedisURL = "redis://" + env.redis.Addr()
+
+	env.stop = make(chan struct{})
+	go env.expireKeys()
+
+	return env, nil
+}
+
+// expireKeys moves the in-memory Redis clock forward, which otherwise only
+// expires keys when told to, so lock leases and cache TTLs lapse on time
+func (e *Env) expireKeys() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.redis.FastForward(time.Second)
+		}
+	}
+}
+
+// Stop shuts both stores down and removes a throwaway data directory
+func (e *Env) Stop() error {
+	if e.stop != nil {
+		close(e.stop)
+	}
+	if e.redis != nil {
+		e.redis.Close()
+	}
+	var err error
+	if e.postgres != nil {
+		if stopErr := e.postgres.Stop(); stopErr != nil {
+			err = fmt.Errorf("failed to stop embedded postgres: %w", stopErr)
+		}
+	}
+	e.cleanup()
+	return err
+}
+
+func (e *Env) cleanup() {
+	if e.tempDir != "" {
+		os.RemoveAll(e.tempDir)
+	}
+}
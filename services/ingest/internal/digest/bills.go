@@ -0,0 +1,107 @@
+package digest
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// BillHistoryDays is how far back UpcomingBills should be given charges:
+// enough for three monthly charges
+const BillHistoryDays = 100
+
+// Monthly charges land between these many days apart, allowing for short
+// months and weekends, and vary by at most billAmountTolerance
+const (
+	minBillInterval = 26
+	maxBillInterval = 35
+)
+
+var billAmountTolerance = decimal.NewFromFloat(0.2)
+
+// UpcomingBills projects the monthly charges due from the day after
+// today through days after it, from history's charges. A merchant counts
+// as billing monthly once it has charged at least twice, about a month
+// apart each time, for about the same amount. Bills are ordered by due
+// date.
+func UpcomingBills(history []models.Transaction, today time.Time, days int) []models.UpcomingBill {
+	charges := make(map[string][]models.Transaction)
+	names := make(map[string]string)
+	for _, txn := range history {
+		if txn.IsPending || !txn.Amount.IsPositive() {
+			continue
+		}
+		name := merchantName(txn)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		charges[key] = append(charges[key], txn)
+		names[key] = name
+	}
+
+	from := today.AddDate(0, 0, 1).Format(period.DateLayout)
+	until := today.AddDate(0, 0, days).Format(period.DateLayout)
+
+	bills := []models.UpcomingBill{}
+	for key, txns := range charges {
+		if len(txns) < 2 {
+			continue
+		}
+		sort.Slice(txns, func(i, j int) bool { return txns[i].Date.Before(txns[j].Date) })
+		if !monthly(txns) {
+			continue
+		}
+
+		last := txns[len(txns)-1]
+		due := last.Date.AddDate(0, 1, 0).Format(period.DateLayout)
+		if due < from || due > until {
+			continue
+		}
+		bills = append(bills, models.UpcomingBill{
+			Merchant: names[key],
+			Amount:   last.Amount,
+			DueDate:  due,
+		})
+	}
+
+	sort.Slice(bills, func(i, j int) bool {
+		if bills[i].DueDate != bills[j].DueDate {
+			return bills[i].DueDate < bills[j].DueDate
+		}
+		return bills[i].Merchant < bills[j].Merchant
+	})
+	return bills
+}
+
+// monthly reports whether charges, oldest first, are about a month apart
+// and about the same amount as the latest
+func monthly(txns []models.Transaction) bool {
+	last := txns[len(txns)-1].Amount
+	for i := 1; i < len(txns); i++ {
+		gap := int(txns[i].Date.Sub(txns[i-1].Date).Hours()/24 + 0.5)
+		if gap < minBillInterval || gap > maxBillInterval {
+			return false
+		}
+	}
+	for _, txn := range txns {
+		if txn.Amount.Sub(last).Abs().GreaterThan(last.Mul(billAmountTolerance)) {
+			return false
+		}
+	}
+	return true
+}
+
+func merchantName(txn models.Transaction) string {
+	if txn.MerchantName != nil && *txn.MerchantName != "" {
+		return *txn.MerchantName
+	}
+	if txn.Description != nil {
+		return *txn.Description
+	}
+	return ""
+}
@@ -0,0 +1,319 @@
+// Package digest schedules and sends weekly and monthly digest emails: a
+// summary of spending, upcoming bills, portfolio change and new insights
+// for the period just ended, in the user's timezone. The scheduler queues
+// one job per due digest; the job's handler composes the digest and hands
+// it back to Send.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Delivery statuses
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// ErrNotFound is returned for a delivery that does not exist
+var ErrNotFound = errors.New("digest delivery not found")
+
+// ErrNoMailer is returned when sending without an SMTP server configured
+var ErrNoMailer = errors.New("digest email is not configured")
+
+// Options sets how often the scheduler looks for due digests and the SMTP
+// server they are sent through. Digests are off without an SMTP host.
+type Options struct {
+	Interval time.Duration // time between scheduler runs
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string // sender address, such as "FinAgent <digest@example.com>"
+}
+
+// Input is the input of a digest job
+type Input struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// Service schedules digests, records their deliveries and sends them
+type Service struct {
+	db     *database.Database
+	locks  *locks.Locker
+	jobs   *jobs.Manager
+	mailer *mailer
+	opts   Options
+}
+
+// NewService creates a digest service. When locker is set, only one
+// instance schedules digests at a time.
+func NewService(db *database.Database, locker *locks.Locker, jobManager *jobs.Manager, opts Options) *Service {
+	s := &Service{db: db, locks: locker, jobs: jobManager, opts: opts}
+	if opts.SMTPHost != "" {
+		s.mailer = newMailer(opts)
+	}
+	return s
+}
+
+// Enabled reports whether digests can be sent
+func (s *Service) Enabled() bool {
+	return s.mailer != nil
+}
+
+// Range returns the period a digest of the given frequency covers when
+// sent at now: the last whole week or month in now's location
+func Range(frequency string, now time.Time, weekStart time.Weekday) (period.Range, error) {
+	switch frequency {
+	case models.DigestWeekly:
+		return period.Resolve("last week", now, weekStart)
+	case models.DigestMonthly:
+		return period.Resolve("last month", now, weekStart)
+	}
+	return period.Range{}, fmt.Errorf("unknown digest frequency %q", frequency)
+}
+
+// Run queues due digests every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "digests")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock digest scheduling", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	queued, err := s.Schedule(ctx, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to schedule digests", "error", err)
+	}
+	if queued > 0 {
+		slog.InfoContext(ctx, "Queued digests", "count", queued)
+	}
+}
+
+// Schedule queues a digest job for every user whose latest digest period
+// has ended without one, returning how many it queued. Users without an
+// email address or with a deletion pending are skipped.
+func (s *Service) Schedule(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT p.user_id, p.digest_frequency, p.timezone, p.week_start
+		FROM user_preferences p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.digest_frequency <> 'off' AND u.email IS NOT NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM user_deletions d
+		      WHERE d.user_id = p.user_id AND d.cancelled_at IS NULL AND d.completed_at IS NULL
+		  )
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query digest subscribers: %w", err)
+	}
+	type subscriber struct {
+		userID, frequency, timezone, weekStart string
+	}
+	var subscribers []subscriber
+	for rows.Next() {
+		var sub subscriber
+		if err := rows.Scan(&sub.userID, &sub.frequency, &sub.timezone, &sub.weekStart); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan digest subscriber: %w", err)
+		}
+		subscribers = append(subscribers, sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query digest subscribers: %w", err)
+	}
+
+	queued := 0
+	for _, sub := range subscribers {
+		loc, err := time.LoadLocation(sub.timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		rng, err := Range(sub.frequency, now.In(loc), period.WeekStarts[sub.weekStart])
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping digest", "user_id", sub.userID, "error", err)
+			continue
+		}
+
+		ok, err := s.queue(ctx, sub.userID, sub.frequency, rng)
+		if err != nil {
+			return queued, err
+		}
+		if ok {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// queue records a user's digest for a period and enqueues its job, unless
+// the period already has one
+func (s *Service) queue(ctx context.Context, userID, frequency string, rng period.Range) (bool, error) {
+	var deliveryID string
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO digest_deliveries (user_id, frequency, period_start, period_end)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, frequency, period_start) DO NOTHING
+		RETURNING id
+	`, userID, frequency, rng.Start.Format(period.DateLayout), rng.End.Format(period.DateLayout)).Scan(&deliveryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record digest delivery: %w", err)
+	}
+
+	_, err = s.jobs.Enqueue(ctx, jobs.Params{
+		UserID: userID,
+		Type:   jobs.TypeDigest,
+		Input:  Input{DeliveryID: deliveryID},
+	})
+	if err != nil {
+		// Forget the delivery so the next run queues it again
+		if _, delErr := s.db.Pool.Exec(ctx, "DELETE FROM digest_deliveries WHERE id = $1", deliveryID); delErr != nil {
+			slog.ErrorContext(ctx, "Failed to remove unqueued digest delivery", "delivery_id", deliveryID, "error", delErr)
+		}
+		return false, fmt.Errorf("failed to queue digest: %w", err)
+	}
+	return true, nil
+}
+
+// Delivery returns one digest delivery
+func (s *Service) Delivery(ctx context.Context, deliveryID string) (*models.DigestDelivery, error) {
+	deliveries, err := s.queryDeliveries(ctx, "WHERE id = $1", deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+	return &deliveries[0], nil
+}
+
+// ListDeliveries returns a user's digest deliveries, newest first
+func (s *Service) ListDeliveries(ctx context.Context, userID string, limit, offset int) ([]models.DigestDelivery, error) {
+	return s.queryDeliveries(ctx, "WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3", userID, limit, offset)
+}
+
+// PreviousPortfolioValue returns the portfolio value recorded by the last
+// digest of a frequency sent for a period starting before start, or nil if
+// there is none
+func (s *Service) PreviousPortfolioValue(ctx context.Context, userID, frequency, start string) (*decimal.Decimal, error) {
+	var value *decimal.Decimal
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT portfolio_value FROM digest_deliveries
+		WHERE user_id = $1 AND frequency = $2 AND period_start < $3 AND status = 'sent'
+		ORDER BY period_start DESC
+		LIMIT 1
+	`, userID, frequency, start).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query previous digest: %w", err)
+	}
+	return value, nil
+}
+
+// Send emails a composed digest to the address and marks its delivery
+// sent. A failed send is recorded on the delivery and returned, so the job
+// retries it.
+func (s *Service) Send(ctx context.Context, deliveryID, to string, d *models.Digest) error {
+	if s.mailer == nil {
+		return ErrNoMailer
+	}
+
+	msg, err := Render(d)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	if err := s.mailer.send(msg); err != nil {
+		s.recordError(ctx, deliveryID, StatusPending, err)
+		return err
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		UPDATE digest_deliveries
+		SET status = 'sent', sent_at = NOW(), error = NULL, portfolio_value = $2
+		WHERE id = $1
+	`, deliveryID, d.Portfolio.Value)
+	if err != nil {
+		return fmt.Errorf("failed to record digest delivery: %w", err)
+	}
+	return nil
+}
+
+// Fail marks a delivery that cannot be sent, such as to a user who has
+// removed their email address
+func (s *Service) Fail(ctx context.Context, deliveryID string, reason error) {
+	s.recordError(ctx, deliveryID, StatusFailed, reason)
+}
+
+func (s *Service) recordError(ctx context.Context, deliveryID, status string, deliveryErr error) {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE digest_deliveries SET status = $2, error = $3 WHERE id = $1
+	`, deliveryID, status, deliveryErr.Error())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record digest error", "delivery_id", deliveryID, "error", err)
+	}
+}
+
+func (s *Service) queryDeliveries(ctx context.Context, where string, args ...interface{}) ([]models.DigestDelivery, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, frequency, period_start::text, period_end::text, status,
+		       portfolio_value, error, sent_at, created_at
+		FROM digest_deliveries `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.DigestDelivery{}
+	for rows.Next() {
+		var d models.DigestDelivery
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Frequency, &d.PeriodStart, &d.PeriodEnd, &d.Status,
+			&d.PortfolioValue, &d.Error, &d.SentAt, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan digest delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
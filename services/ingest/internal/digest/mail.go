@@ -0,0 +1,109 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Message is a rendered digest email with plain text and HTML bodies
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// mailer sends messages through an SMTP server, upgrading to TLS when the
+// server offers it
+type mailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func newMailer(opts Options) *mailer {
+	m := &mailer{
+		addr: net.JoinHostPort(opts.SMTPHost, strconv.Itoa(opts.SMTPPort)),
+		host: opts.SMTPHost,
+		from: opts.From,
+	}
+	if opts.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", opts.SMTPUsername, opts.SMTPPassword, opts.SMTPHost)
+	}
+	return m
+}
+
+func (m *mailer) send(msg Message) error {
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	body, err := m.build(from, to, msg)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, body); err != nil {
+		return fmt.Errorf("failed to send digest email: %w", err)
+	}
+	return nil
+}
+
+// build writes msg as a multipart/alternative MIME message, plain text
+// first so clients without HTML show it
+func (m *mailer) build(from, to *mail.Address, msg Message) ([]byte, error) {
+	boundary, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", boundary, m.host)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("failed to encode digest email: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode digest email: %w", err)
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
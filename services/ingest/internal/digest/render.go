@@ -0,0 +1,201 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// currencySymbols are written before amounts; other currencies are written
+// as a code after them
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+}
+
+// view is what the email templates render: the digest and its changes
+// worded for reading
+type view struct {
+	*models.Digest
+	Title           string
+	SpendingChange  string // such as "up 12.5% on the week before"
+	PortfolioChange string // such as "up $1,204.10 (2.3%)"
+}
+
+const textTemplate = `{{.Title}}
+{{.Period.StartDate}} to {{.Period.EndDate}}
+
+SPENDING
+Spent {{money .Spending.TotalSpent}} across {{.Spending.TransactionCount}} transactions{{with .SpendingChange}}, {{.}}{{end}}.
+Income {{money .Spending.TotalIncome}}, net cash flow {{money .Spending.NetCashFlow}}.
+{{range .Spending.Categories}}  - {{.Category}}: {{money .Amount}} ({{percent .Percentage}})
+{{end}}
+UPCOMING BILLS
+{{range .UpcomingBills}}  - {{.DueDate}} {{.Merchant}}: {{money .Amount}}
+{{else}}  None expected.
+{{end}}
+PORTFOLIO
+Worth {{money .Portfolio.Value}}{{with .PortfolioChange}}, {{.}} since your last digest{{end}}.
+{{range .Portfolio.TopPositions}}  - {{.Symbol}}: {{money .Value}} ({{percent .Percentage}})
+{{end}}
+NEW INSIGHTS
+{{range .Insights}}  - {{.Title}}: {{.Message}}
+{{else}}  Nothing new.
+{{end}}
+You receive this because digest emails are on. Set digest_frequency to "off" in your preferences to stop them.
+`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; color: #222; max-width: 600px; margin: 0 auto;">
+<h1 style="font-size: 20px;">{{.Title}}</h1>
+<p style="color: #666;">{{.Period.StartDate}} to {{.Period.EndDate}}</p>
+
+<h2 style="font-size: 16px;">Spending</h2>
+<p>Spent <strong>{{money .Spending.TotalSpent}}</strong> across {{.Spending.TransactionCount}} transactions{{with .SpendingChange}}, {{.}}{{end}}.<br>
+Income {{money .Spending.TotalIncome}}, net cash flow {{money .Spending.NetCashFlow}}.</p>
+{{if .Spending.Categories}}<table cellpadding="4">
+{{range .Spending.Categories}}<tr><td>{{.Category}}</td><td align="right">{{money .Amount}}</td><td align="right" style="color: #666;">{{percent .Percentage}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2 style="font-size: 16px;">Upcoming bills</h2>
+{{if .UpcomingBills}}<table cellpadding="4">
+{{range .UpcomingBills}}<tr><td>{{.DueDate}}</td><td>{{.Merchant}}</td><td align="right">{{money .Amount}}</td></tr>
+{{end}}</table>{{else}}<p>None expected.</p>{{end}}
+
+<h2 style="font-size: 16px;">Portfolio</h2>
+<p>Worth <strong>{{money .Portfolio.Value}}</strong>{{with .PortfolioChange}}, {{.}} since your last digest{{end}}.</p>
+{{if .Portfolio.TopPositions}}<table cellpadding="4">
+{{range .Portfolio.TopPositions}}<tr><td>{{.Symbol}}</td><td align="right">{{money .Value}}</td><td align="right" style="color: #666;">{{percent .Percentage}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2 style="font-size: 16px;">New insights</h2>
+{{if .Insights}}<ul>
+{{range .Insights}}<li><strong>{{.Title}}</strong>: {{.Message}}</li>
+{{end}}</ul>{{else}}<p>Nothing new.</p>{{end}}
+
+<p style="color: #999; font-size: 12px;">You receive this because digest emails are on. Set digest_frequency to "off" in your preferences to stop them.</p>
+</body>
+</html>
+`
+
+// Render writes a digest as an email, with amounts in the user's currency
+// and number format
+func Render(d *models.Digest) (Message, error) {
+	v := view{
+		Digest:          d,
+		Title:           fmt.Sprintf("Your %s FinAgent digest: %s", d.Frequency, d.Label),
+		SpendingChange:  spendingChange(d),
+		PortfolioChange: portfolioChange(d),
+	}
+	money := func(amount decimal.Decimal) string {
+		return FormatAmount(amount, d.Currency, d.NumberFormat)
+	}
+	percent := func(pct float64) string {
+		return fmt.Sprintf("%.1f%%", pct)
+	}
+
+	text, err := texttemplate.New("text").
+		Funcs(texttemplate.FuncMap{"money": money, "percent": percent}).
+		Parse(textTemplate)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse digest template: %w", err)
+	}
+	html, err := htmltemplate.New("html").
+		Funcs(htmltemplate.FuncMap{"money": money, "percent": percent}).
+		Parse(htmlTemplate)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse digest template: %w", err)
+	}
+
+	var textBody, htmlBody bytes.Buffer
+	if err := text.Execute(&textBody, v); err != nil {
+		return Message{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := html.Execute(&htmlBody, v); err != nil {
+		return Message{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	return Message{Subject: v.Title, Text: textBody.String(), HTML: htmlBody.String()}, nil
+}
+
+func spendingChange(d *models.Digest) string {
+	if d.PreviousSpent == nil || !d.PreviousSpent.IsPositive() {
+		return ""
+	}
+	before := "the week before"
+	if d.Frequency == models.DigestMonthly {
+		before = "the month before"
+	}
+	pct, _ := d.Spending.TotalSpent.Sub(*d.PreviousSpent).Div(*d.PreviousSpent).Mul(decimal.NewFromInt(100)).Float64()
+	switch {
+	case pct > 0:
+		return fmt.Sprintf("up %.1f%% on %s", pct, before)
+	case pct < 0:
+		return fmt.Sprintf("down %.1f%% on %s", -pct, before)
+	}
+	return "the same as " + before
+}
+
+func portfolioChange(d *models.Digest) string {
+	p := d.Portfolio
+	if p.Change == nil {
+		return ""
+	}
+	direction := "up"
+	if p.Change.IsNegative() {
+		direction = "down"
+	}
+	change := direction + " " + FormatAmount(p.Change.Abs(), d.Currency, d.NumberFormat)
+	if p.ChangePercent != nil {
+		pct := *p.ChangePercent
+		if pct < 0 {
+			pct = -pct
+		}
+		change += fmt.Sprintf(" (%.1f%%)", pct)
+	}
+	return change
+}
+
+// FormatAmount writes an amount to two places in a currency and one of the
+// number_format preferences, such as $1,234.56 or 1.234,56 CHF
+func FormatAmount(amount decimal.Decimal, currency, format string) string {
+	group, point := ",", "."
+	switch format {
+	case "1.234,56":
+		group, point = ".", ","
+	case "1 234,56":
+		group, point = " ", ","
+	case "1'234.56":
+		group = "'"
+	case "1234.56":
+		group = ""
+	}
+
+	fixed := amount.Abs().StringFixed(2)
+	whole, cents := fixed[:len(fixed)-3], fixed[len(fixed)-2:]
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(digit)
+	}
+	number := b.String() + point + cents
+
+	sign := ""
+	if amount.IsNegative() {
+		sign = "-"
+	}
+	if symbol, ok := currencySymbols[currency]; ok {
+		return sign + symbol + number
+	}
+	return sign + number + " " + currency
+}
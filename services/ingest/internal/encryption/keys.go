@@ -0,0 +1,93 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/keymanager"
+	"github.com/jackc/pgx/v5"
+)
+
+// KeyStore records which master key is active
+type KeyStore struct {
+	db *database.Database
+}
+
+// NewKeyStore creates a new key store
+func NewKeyStore(db *database.Database) *KeyStore {
+	return &KeyStore{db: db}
+}
+
+// Active returns the active master key ID. If none has been recorded yet,
+// fallback becomes the active key.
+func (s *KeyStore) Active(ctx context.Context, fallback string) (string, error) {
+	var keyID string
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT key_id FROM encryption_keys WHERE status = 'active'").Scan(&keyID)
+	if err == nil {
+		return keyID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to query active encryption key: %w", err)
+	}
+
+	if err := s.Activate(ctx, fallback); err != nil {
+		return "", err
+	}
+	return fallback, nil
+}
+
+// Activate retires the current active key and activates keyID
+func (s *KeyStore) Activate(ctx context.Context, keyID string) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE encryption_keys SET status = 'retired', retired_at = NOW()
+		WHERE status = 'active' AND key_id <> $1
+	`, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to retire encryption key: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO encryption_keys (key_id, status, activated_at)
+		VALUES ($1, 'active', NOW())
+		ON CONFLICT (key_id)
+		DO UPDATE SET status = 'active', activated_at = NOW(), retired_at = NULL
+	`, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to activate encryption key: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Open creates an encryption service whose active master key is the one
+// recorded in the database, falling back to the configured key on first run
+func Open(ctx context.Context, db *database.Database, opts keymanager.Options) (*Service, error) {
+	store := NewKeyStore(db)
+
+	activeID, err := store.Active(ctx, opts.DefaultKeyID())
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := keymanager.NewKeyring(ctx, opts, activeID)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := NewService(keys, store)
+	if opts.LegacyKey != "" {
+		if svc.legacy, err = newLegacyAEAD(opts.LegacyKey); err != nil {
+			return nil, err
+		}
+	}
+	return svc, nil
+}
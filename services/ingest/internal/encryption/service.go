@@ -0,0 +1,419 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/keymanager"
+)
+
+// Envelope versions, stored as the first byte of every ciphertext. Version 3
+// records the ID of the master key that wrapped the data key; version 2
+// envelopes were all wrapped with the configured default key.
+const (
+	envelopeV2 = 2
+	envelopeV3 = 3
+)
+
+// textPrefix marks encrypted values stored in text columns so plaintext rows
+// written before encryption was enabled can still be read
+const textPrefix = "enc:v2:"
+
+// legacyTextPrefix marks text values written before envelope encryption.
+// They, and access tokens of that time, are nonce || ciphertext sealed
+// directly with the SHA-256 of ENCRYPTION_KEY, and stay readable with it
+// until re-encryption moves them to envelopes.
+const legacyTextPrefix = "enc:v1:"
+
+// Unwrapped data keys are cached briefly so reading a page of rows does not
+// cost one KMS round trip per field
+const (
+	dataKeyCacheTTL  = 10 * time.Minute
+	dataKeyCacheSize = 1024
+)
+
+// Service encrypts secrets and PII at rest using envelope encryption: every
+// value gets its own AES-256-GCM data key, which is stored alongside the
+// ciphertext wrapped by the active master key.
+type Service struct {
+	keys   *keymanager.Keyring
+	store  *KeyStore
+	legacy cipher.AEAD // nil without a legacy key
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+type cachedKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// NewService creates an encryption service backed by a keyring. The store,
+// if set, persists the active key across restarts when it is rotated.
+func NewService(keys *keymanager.Keyring, store *KeyStore) *Service {
+	return &Service{
+		keys:  keys,
+		store: store,
+		cache: make(map[string]cachedKey),
+	}
+}
+
+// ActiveKeyID returns the master key used for new encryptions
+func (s *Service) ActiveKeyID() string {
+	return s.keys.ActiveID()
+}
+
+// RotateKey makes keyID the active master key. Existing ciphertexts remain
+// readable and can be migrated with Reencrypt.
+func (s *Service) RotateKey(ctx context.Context, keyID string) error {
+	if err := s.keys.SetActive(ctx, keyID); err != nil {
+		return err
+	}
+	if s.store != nil {
+		if err := s.store.Activate(ctx, keyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReloadKeys rebuilds the master keys from new options, e.g. after the key
+// material or KMS credentials were rotated in the secrets store
+func (s *Service) ReloadKeys(ctx context.Context, opts keymanager.Options) error {
+	return s.keys.Reload(ctx, opts)
+}
+
+// Encrypt seals plaintext under a fresh data key and returns the envelope
+// version || key ID length || key ID || wrapped key length || wrapped key ||
+// nonce || ciphertext
+func (s *Service) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	keyID, km := s.keys.Active()
+	if len(keyID) > 0xFF {
+		return nil, fmt.Errorf("master key ID too long")
+	}
+
+	dataKey, wrapped, err := km.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xFFFF {
+		return nil, fmt.Errorf("wrapped data key too large")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, 4+len(keyID)+len(wrapped)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, envelopeV3, byte(len(keyID)))
+	out = append(out, keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens an envelope produced by Encrypt, or a ciphertext sealed
+// with the legacy key
+func (s *Service) Decrypt(ctx context.Context, envelope []byte) ([]byte, error) {
+	plaintext, err := s.openEnvelope(ctx, envelope)
+	if err != nil && s.legacy != nil {
+		// Legacy ciphertexts have no version byte, so one may start with
+		// an envelope version by chance; GCM authentication tells them apart
+		if legacy, legacyErr := s.openLegacy(envelope); legacyErr == nil {
+			return legacy, nil
+		}
+	}
+	return plaintext, err
+}
+
+// openEnvelope opens an envelope produced by Encrypt
+func (s *Service) openEnvelope(ctx context.Context, envelope []byte) ([]byte, error) {
+	keyID, wrapped, rest, err := s.parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := s.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(rest) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, rest[:nonceSize], rest[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// KeyID returns the ID of the master key an envelope was wrapped with
+func (s *Service) KeyID(envelope []byte) (string, error) {
+	keyID, _, _, err := s.parseEnvelope(envelope)
+	return keyID, err
+}
+
+// Reencrypt re-seals an envelope, or a legacy ciphertext, under the active
+// master key. It returns false without doing any work if the envelope
+// already uses the active key.
+func (s *Service) Reencrypt(ctx context.Context, envelope []byte) ([]byte, bool, error) {
+	if keyID, err := s.KeyID(envelope); err == nil && keyID == s.ActiveKeyID() && envelope[0] == envelopeV3 {
+		return envelope, false, nil
+	}
+
+	plaintext, err := s.Decrypt(ctx, envelope)
+	if err != nil {
+		return nil, false, err
+	}
+	out, err := s.Encrypt(ctx, plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// parseEnvelope splits an envelope into master key ID, wrapped data key and
+// nonce || ciphertext
+func (s *Service) parseEnvelope(envelope []byte) (string, []byte, []byte, error) {
+	if len(envelope) < 1 {
+		return "", nil, nil, fmt.Errorf("ciphertext too short")
+	}
+
+	var keyID string
+	rest := envelope[1:]
+	switch envelope[0] {
+	case envelopeV2:
+		keyID = s.keys.DefaultID()
+	case envelopeV3:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return "", nil, nil, fmt.Errorf("ciphertext too short")
+		}
+		keyID = string(rest[1 : 1+int(rest[0])])
+		rest = rest[1+int(rest[0]):]
+	default:
+		return "", nil, nil, fmt.Errorf("unsupported ciphertext version %d", envelope[0])
+	}
+
+	if len(rest) < 2 {
+		return "", nil, nil, fmt.Errorf("ciphertext too short")
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(rest[:2]))
+	rest = rest[2:]
+	if len(rest) < wrappedLen {
+		return "", nil, nil, fmt.Errorf("ciphertext too short")
+	}
+
+	return keyID, rest[:wrappedLen], rest[wrappedLen:], nil
+}
+
+// unwrap returns the plaintext data key, consulting the cache first
+func (s *Service) unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	cacheKey := keyID + "\x00" + string(wrapped)
+	now := time.Now()
+
+	s.mu.Lock()
+	if entry, ok := s.cache[cacheKey]; ok && now.Before(entry.expiresAt) {
+		s.mu.Unlock()
+		return entry.key, nil
+	}
+	s.mu.Unlock()
+
+	km, err := s.keys.Get(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := km.DecryptDataKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= dataKeyCacheSize {
+		s.cache = make(map[string]cachedKey)
+	}
+	s.cache[cacheKey] = cachedKey{key: dataKey, expiresAt: now.Add(dataKeyCacheTTL)}
+	s.mu.Unlock()
+
+	return dataKey, nil
+}
+
+// openLegacy opens nonce || ciphertext sealed with the legacy key
+func (s *Service) openLegacy(ciphertext []byte) ([]byte, error) {
+	if s.legacy == nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY is required to read values encrypted before envelope encryption")
+	}
+
+	nonceSize := s.legacy.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	plaintext, err := s.legacy.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// newLegacyAEAD derives the legacy cipher the way it was derived before
+// envelope encryption: from the SHA-256 of the key material
+func newLegacyAEAD(key string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(key))
+	return newAEAD(sum[:])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// IsEncrypted reports whether a text value was produced by EncryptString,
+// now or before envelope encryption
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, textPrefix) || strings.HasPrefix(value, legacyTextPrefix)
+}
+
+// EncryptString encrypts a value for storage in a text column. Empty and
+// already-encrypted values are returned unchanged.
+func (s *Service) EncryptString(ctx context.Context, value string) (string, error) {
+	if value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+
+	ciphertext, err := s.Encrypt(ctx, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString decrypts a value written by EncryptString. Values without
+// an encryption prefix are legacy plaintext and are returned unchanged.
+func (s *Service) DecryptString(ctx context.Context, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	ciphertext, legacy, err := decodeText(value)
+	if err != nil {
+		return "", err
+	}
+
+	var plaintext []byte
+	if legacy {
+		plaintext, err = s.openLegacy(ciphertext)
+	} else {
+		plaintext, err = s.Decrypt(ctx, ciphertext)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// ReencryptString re-seals a value written by EncryptString under the
+// active master key, reporting whether it changed. Plaintext values are
+// encrypted, and legacy values moved to envelopes.
+func (s *Service) ReencryptString(ctx context.Context, value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncrypted(value) {
+		encrypted, err := s.EncryptString(ctx, value)
+		return encrypted, err == nil, err
+	}
+
+	ciphertext, legacy, err := decodeText(value)
+	if err != nil {
+		return "", false, err
+	}
+	if legacy {
+		plaintext, err := s.openLegacy(ciphertext)
+		if err != nil {
+			return "", false, err
+		}
+		out, err := s.Encrypt(ctx, plaintext)
+		if err != nil {
+			return "", false, err
+		}
+		return textPrefix + base64.StdEncoding.EncodeToString(out), true, nil
+	}
+
+	out, changed, err := s.Reencrypt(ctx, ciphertext)
+	if err != nil || !changed {
+		return value, false, err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(out), true, nil
+}
+
+// decodeText splits an encrypted text value into its ciphertext and whether
+// it predates envelope encryption
+func decodeText(value string) ([]byte, bool, error) {
+	encoded, legacy := strings.CutPrefix(value, legacyTextPrefix)
+	if !legacy {
+		encoded = strings.TrimPrefix(value, textPrefix)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	return ciphertext, legacy, nil
+}
+
+// EncryptStringPtr is EncryptString for nullable columns
+func (s *Service) EncryptStringPtr(ctx context.Context, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encrypted, err := s.EncryptString(ctx, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+// DecryptStringPtr is DecryptString for nullable columns
+func (s *Service) DecryptStringPtr(ctx context.Context, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	decrypted, err := s.DecryptString(ctx, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
@@ -0,0 +1,388 @@
+// Package equity keeps users' RSU and stock option grants and their
+// vesting schedules, values the vests to come and matches vests that have
+// occurred to the brokerage transactions delivering their shares
+package equity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/prices"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Grant types
+const (
+	GrantRSU    = "rsu"
+	GrantOption = "option"
+)
+
+// MaxGrants bounds the grants a user may keep
+const MaxGrants = 50
+
+// maxVests bounds the vests of one grant, 10 years of monthly vests
+const maxVests = 120
+
+var (
+	// ErrNotFound is returned for a grant that does not exist or belongs
+	// to another user
+	ErrNotFound = errors.New("equity grant not found")
+	// ErrLimit is returned when the user has MaxGrants grants
+	ErrLimit = errors.New("equity grant limit reached")
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9.\-]{1,12}$`)
+
+// Schedule is a vesting schedule of equal vests every FrequencyMonths
+// from StartDate over TotalMonths, none vesting before a cliff of
+// CliffMonths, when those accrued by then vest at once. Vests are whole
+// shares; the last takes the remainder.
+type Schedule struct {
+	StartDate       string `json:"start_date"`
+	TotalMonths     int    `json:"total_months"`
+	CliffMonths     int    `json:"cliff_months"`
+	FrequencyMonths int    `json:"frequency_months"`
+}
+
+// Vests returns the vests of total shares on the schedule
+func (sc Schedule) Vests(total decimal.Decimal) ([]models.EquityVest, error) {
+	start, err := time.Parse(period.DateLayout, sc.StartDate)
+	if err != nil {
+		return nil, errors.New("schedule start_date must be YYYY-MM-DD")
+	}
+	if sc.FrequencyMonths <= 0 || sc.TotalMonths <= 0 || sc.TotalMonths%sc.FrequencyMonths != 0 {
+		return nil, errors.New("schedule total_months must be a positive multiple of frequency_months")
+	}
+	if sc.CliffMonths < 0 || sc.CliffMonths > sc.TotalMonths || sc.CliffMonths%sc.FrequencyMonths != 0 {
+		return nil, errors.New("schedule cliff_months must be a multiple of frequency_months within total_months")
+	}
+	periods := sc.TotalMonths / sc.FrequencyMonths
+	if periods > maxVests {
+		return nil, fmt.Errorf("a schedule may have at most %d vests", maxVests)
+	}
+
+	perPeriod := total.Div(decimal.NewFromInt(int64(periods))).Floor()
+	var vests []models.EquityVest
+	vested := decimal.Zero
+	for i := 1; i <= periods; i++ {
+		months := i * sc.FrequencyMonths
+		if months < sc.CliffMonths {
+			continue
+		}
+		shares := perPeriod.Mul(decimal.NewFromInt(int64(i))).Sub(vested)
+		if i == periods {
+			shares = total.Sub(vested)
+		}
+		if !shares.IsPositive() {
+			continue
+		}
+		vested = vested.Add(shares)
+		vests = append(vests, models.EquityVest{
+			Date:   start.AddDate(0, months, 0).Format(period.DateLayout),
+			Shares: shares,
+		})
+	}
+	return vests, nil
+}
+
+// Validate checks a grant and its vests before it is stored, sorting the
+// vests by date. The vests must add up to the grant's shares.
+func Validate(g *models.EquityGrant) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" || len(g.Name) > 100 {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+	g.Symbol = strings.ToUpper(strings.TrimSpace(g.Symbol))
+	if !symbolPattern.MatchString(g.Symbol) {
+		return errors.New("symbol must be a ticker symbol")
+	}
+	switch g.GrantType {
+	case GrantRSU:
+		if g.StrikePrice != nil {
+			return errors.New("strike_price is for options only")
+		}
+	case GrantOption:
+		if g.StrikePrice == nil || g.StrikePrice.IsNegative() {
+			return errors.New("options need a strike_price")
+		}
+	default:
+		return errors.New("grant_type must be 'rsu' or 'option'")
+	}
+	if !g.TotalShares.IsPositive() {
+		return errors.New("total_shares must be positive")
+	}
+	if _, err := time.Parse(period.DateLayout, g.GrantDate); err != nil {
+		return errors.New("grant_date must be YYYY-MM-DD")
+	}
+
+	if len(g.Vests) == 0 || len(g.Vests) > maxVests {
+		return fmt.Errorf("a grant needs from 1 to %d vests", maxVests)
+	}
+	sum := decimal.Zero
+	dates := make(map[string]bool, len(g.Vests))
+	for _, v := range g.Vests {
+		if _, err := time.Parse(period.DateLayout, v.Date); err != nil {
+			return errors.New("vest dates must be YYYY-MM-DD")
+		}
+		if dates[v.Date] {
+			return fmt.Errorf("two vests on %s", v.Date)
+		}
+		dates[v.Date] = true
+		if !v.Shares.IsPositive() {
+			return errors.New("vest shares must be positive")
+		}
+		sum = sum.Add(v.Shares)
+	}
+	if !sum.Equal(g.TotalShares) {
+		return fmt.Errorf("vests add up to %s shares, not the %s granted", sum, g.TotalShares)
+	}
+	sort.Slice(g.Vests, func(i, j int) bool { return g.Vests[i].Date < g.Vests[j].Date })
+	return nil
+}
+
+// Service stores grants and values their vests
+type Service struct {
+	db     *database.Database
+	prices prices.Provider
+}
+
+// NewService creates an equity grant service quoting symbols from
+// provider
+func NewService(db *database.Database, provider prices.Provider) *Service {
+	return &Service{db: db, prices: provider}
+}
+
+// Create stores a validated grant with its vests. Shares vested count
+// vests up to today.
+func (s *Service) Create(ctx context.Context, g *models.EquityGrant, today string) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		err := s.db.Writer(ctx).QueryRow(ctx, `
+			INSERT INTO equity_grants (user_id, name, symbol, grant_type, strike_price, total_shares, grant_date, account_id)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8
+			WHERE (SELECT count(*) FROM equity_grants WHERE user_id = $1) < $9
+			RETURNING id, created_at, updated_at
+		`, g.UserID, g.Name, g.Symbol, g.GrantType, g.StrikePrice, g.TotalShares, g.GrantDate, g.AccountID,
+			MaxGrants).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrLimit
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create equity grant: %w", err)
+		}
+
+		for i := range g.Vests {
+			v := &g.Vests[i]
+			v.GrantID = g.ID
+			err := s.db.Writer(ctx).QueryRow(ctx, `
+				INSERT INTO equity_vests (grant_id, user_id, vest_date, shares)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id
+			`, g.ID, g.UserID, v.Date, v.Shares).Scan(&v.ID)
+			if err != nil {
+				return fmt.Errorf("failed to create equity vest: %w", err)
+			}
+		}
+		g.VestedShares, g.UnvestedShares = splitVested(g.Vests, today)
+		return nil
+	})
+}
+
+// List returns a user's grants with their vests, by grant date. Shares
+// vested count vests up to today.
+func (s *Service) List(ctx context.Context, userID string, today string) ([]models.EquityGrant, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, name, symbol, grant_type, strike_price, total_shares, grant_date::text,
+		       account_id, created_at, updated_at
+		FROM equity_grants
+		WHERE user_id = $1
+		ORDER BY grant_date, name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query equity grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []models.EquityGrant{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var g models.EquityGrant
+		if err := rows.Scan(&g.ID, &g.UserID, &g.Name, &g.Symbol, &g.GrantType, &g.StrikePrice,
+			&g.TotalShares, &g.GrantDate, &g.AccountID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan equity grant: %w", err)
+		}
+		g.Vests = []models.EquityVest{}
+		index[g.ID] = len(grants)
+		grants = append(grants, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	vests, err := s.vests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vests {
+		if i, ok := index[v.GrantID]; ok {
+			grants[i].Vests = append(grants[i].Vests, v)
+		}
+	}
+	for i := range grants {
+		grants[i].VestedShares, grants[i].UnvestedShares = splitVested(grants[i].Vests, today)
+	}
+	return grants, nil
+}
+
+// Delete deletes one of a user's grants with its vests
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM equity_grants WHERE id::text = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete equity grant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Upcoming returns a user's vests after today up to end, valued at each
+// symbol's current price. Options are valued at what the price exceeds
+// the strike by, nothing when under water.
+func (s *Service) Upcoming(ctx context.Context, userID, today, end string) ([]models.UpcomingVest, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT g.id, g.name, g.grant_type, g.symbol, g.strike_price, v.vest_date::text, v.shares
+		FROM equity_vests v
+		JOIN equity_grants g ON g.id = v.grant_id
+		WHERE v.user_id = $1 AND v.vest_date > $2 AND v.vest_date <= $3
+		ORDER BY v.vest_date, g.name
+	`, userID, today, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upcoming vests: %w", err)
+	}
+	defer rows.Close()
+
+	upcoming := []models.UpcomingVest{}
+	var strikes []*decimal.Decimal
+	for rows.Next() {
+		var v models.UpcomingVest
+		var strike *decimal.Decimal
+		if err := rows.Scan(&v.GrantID, &v.GrantName, &v.GrantType, &v.Symbol, &strike, &v.Date, &v.Shares); err != nil {
+			return nil, fmt.Errorf("failed to scan upcoming vest: %w", err)
+		}
+		upcoming = append(upcoming, v)
+		strikes = append(strikes, strike)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	quotes := make(map[string]*decimal.Decimal)
+	for i := range upcoming {
+		v := &upcoming[i]
+		price, quoted := quotes[v.Symbol]
+		if !quoted {
+			p, err := s.prices.Price(ctx, v.Symbol)
+			if err != nil && !errors.Is(err, prices.ErrNoPrice) {
+				return nil, err
+			}
+			if err == nil {
+				price = &p
+			}
+			quotes[v.Symbol] = price
+		}
+		if price == nil {
+			continue
+		}
+		perShare := *price
+		if strikes[i] != nil {
+			perShare = decimal.Max(perShare.Sub(*strikes[i]), decimal.Zero)
+		}
+		value := perShare.Mul(v.Shares).Round(2)
+		v.Price, v.Value = price, &value
+	}
+	return upcoming, nil
+}
+
+// Project adds the value of vests due in each of the months after today's
+// to netWorth, giving the net worth expected at the end of each month
+func Project(netWorth decimal.Decimal, upcoming []models.UpcomingVest, today time.Time, months int) models.NetWorthProjection {
+	projection := models.NetWorthProjection{
+		NetWorth: netWorth,
+		Months:   make([]models.ProjectedNetWorth, 0, months+1),
+		Unpriced: []string{},
+	}
+
+	byMonth := make(map[string]decimal.Decimal)
+	unpriced := make(map[string]bool)
+	for _, v := range upcoming {
+		if v.Value == nil {
+			if !unpriced[v.Symbol] {
+				unpriced[v.Symbol] = true
+				projection.Unpriced = append(projection.Unpriced, v.Symbol)
+			}
+			continue
+		}
+		month := v.Date[:7]
+		byMonth[month] = byMonth[month].Add(*v.Value)
+	}
+
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	running := netWorth
+	for i := 0; i <= months; i++ {
+		month := first.AddDate(0, i, 0).Format("2006-01")
+		running = running.Add(byMonth[month])
+		projection.Months = append(projection.Months, models.ProjectedNetWorth{
+			Month:     month,
+			VestValue: byMonth[month],
+			NetWorth:  running,
+		})
+	}
+	return projection
+}
+
+// vests returns a user's vests by date
+func (s *Service) vests(ctx context.Context, userID string) ([]models.EquityVest, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, grant_id, vest_date::text, shares, investment_transaction_id, matched_at
+		FROM equity_vests
+		WHERE user_id = $1
+		ORDER BY vest_date
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query equity vests: %w", err)
+	}
+	defer rows.Close()
+
+	var vests []models.EquityVest
+	for rows.Next() {
+		var v models.EquityVest
+		if err := rows.Scan(&v.ID, &v.GrantID, &v.Date, &v.Shares, &v.InvestmentTransactionID, &v.MatchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan equity vest: %w", err)
+		}
+		vests = append(vests, v)
+	}
+	return vests, rows.Err()
+}
+
+// splitVested totals the shares of vests up to today and after it
+func splitVested(vests []models.EquityVest, today string) (decimal.Decimal, decimal.Decimal) {
+	vested, unvested := decimal.Zero, decimal.Zero
+	for _, v := range vests {
+		if v.Date <= today {
+			vested = vested.Add(v.Shares)
+		} else {
+			unvested = unvested.Add(v.Shares)
+		}
+	}
+	return vested, unvested
+}
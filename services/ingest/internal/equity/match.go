@@ -0,0 +1,70 @@
+package equity
+
+import (
+	"context"
+	"fmt"
+)
+
+// A vest's shares may be recorded by the brokerage a few days before its
+// date, or take over a week to settle
+const (
+	matchDaysBefore = 3
+	matchDaysAfter  = 10
+)
+
+// Match links a user's RSU vests up to today to the investment
+// transactions delivering their shares: transactions adding shares of
+// the grant's symbol near the vest date, in the grant's account when
+// set, and no more shares than vested, as some are usually sold to cover
+// taxes. The transaction nearest the vest date wins, and each matches
+// one vest. It returns how many vests were matched.
+func (s *Service) Match(ctx context.Context, userID, today string) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT v.id, it.id
+		FROM equity_vests v
+		JOIN equity_grants g ON g.id = v.grant_id
+		JOIN investment_transactions it ON it.user_id = v.user_id
+		JOIN securities sec ON sec.id = it.security_id
+		WHERE v.user_id = $1 AND v.investment_transaction_id IS NULL AND v.vest_date <= $2
+		  AND g.grant_type = 'rsu'
+		  AND upper(sec.symbol) = g.symbol
+		  AND (g.account_id IS NULL OR it.account_id = g.account_id)
+		  AND it.deleted_at IS NULL AND it.type <> 'sell'
+		  AND it.quantity > 0 AND it.quantity <= v.shares
+		  AND it.date BETWEEN v.vest_date - $3::int AND v.vest_date + $4::int
+		  AND NOT EXISTS (SELECT 1 FROM equity_vests o WHERE o.investment_transaction_id = it.id)
+		ORDER BY abs(it.date - v.vest_date), it.quantity DESC, v.vest_date
+	`, userID, today, matchDaysBefore, matchDaysAfter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query vest candidates: %w", err)
+	}
+	defer rows.Close()
+
+	matches := make(map[string]string)
+	used := make(map[string]bool)
+	for rows.Next() {
+		var vestID, txnID string
+		if err := rows.Scan(&vestID, &txnID); err != nil {
+			return 0, fmt.Errorf("failed to scan vest candidate: %w", err)
+		}
+		if _, ok := matches[vestID]; ok || used[txnID] {
+			continue
+		}
+		matches[vestID] = txnID
+		used[txnID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for vestID, txnID := range matches {
+		_, err := s.db.Pool.Exec(ctx, `
+			UPDATE equity_vests SET investment_transaction_id = $2, matched_at = NOW()
+			WHERE id = $1 AND investment_transaction_id IS NULL
+		`, vestID, txnID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to match vest: %w", err)
+		}
+	}
+	return len(matches), nil
+}
@@ -0,0 +1,342 @@
+// Package expenses marks transactions as business or personal expenses,
+// by hand or by rules matching merchants, categories and accounts, and
+// reports them by category for reimbursement claims and Schedule C
+// preparation
+package expenses
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Expense types
+const (
+	TypeBusiness = "business"
+	TypePersonal = "personal"
+)
+
+var (
+	// ErrNotFound is returned for a rule that does not exist or belongs to
+	// another user
+	ErrNotFound = errors.New("expense rule not found")
+	// ErrInvalidRule is returned for a rule without a valid type or
+	// without any criteria
+	ErrInvalidRule = errors.New("expense rule needs a type of business or personal and a merchant, category or account")
+	// ErrUnknownAccount is returned for a rule on an account the user
+	// does not have
+	ErrUnknownAccount = errors.New("account not found")
+)
+
+// IsType reports whether t is an expense type
+func IsType(t string) bool {
+	return t == TypeBusiness || t == TypePersonal
+}
+
+// Service classifies expenses
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates an expense service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// SetType marks a user's transactions as expenseType by hand, where rules
+// will not change it, returning how many were marked. An empty expenseType
+// clears the mark and lets rules apply again.
+func (s *Service) SetType(ctx context.Context, userID string, transactionIDs []string, expenseType string) (int, error) {
+	if expenseType != "" && !IsType(expenseType) {
+		return 0, fmt.Errorf("invalid expense type %q", expenseType)
+	}
+
+	var marked int
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		tag, err := s.db.Writer(ctx).Exec(ctx, `
+			UPDATE transactions SET expense_type = NULLIF($3, ''), expense_rule_id = NULL
+			WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL
+		`, userID, transactionIDs, expenseType)
+		if err != nil {
+			return fmt.Errorf("failed to mark transactions: %w", err)
+		}
+		marked = int(tag.RowsAffected())
+
+		if expenseType == "" {
+			_, err = s.ApplyRules(ctx, userID)
+		}
+		return err
+	})
+	return marked, err
+}
+
+// CreateRule adds a rule and applies it to the user's transactions,
+// returning how many it marked
+func (s *Service) CreateRule(ctx context.Context, rule *models.ExpenseRule) (int, error) {
+	if !IsType(rule.ExpenseType) || (rule.Merchant == nil && rule.Category == nil && rule.AccountID == nil) {
+		return 0, ErrInvalidRule
+	}
+
+	var marked int
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		if rule.AccountID != nil {
+			var owned bool
+			err := s.db.Writer(ctx).QueryRow(ctx,
+				"SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)",
+				*rule.AccountID, rule.UserID).Scan(&owned)
+			if err != nil {
+				return fmt.Errorf("failed to look up account: %w", err)
+			}
+			if !owned {
+				return ErrUnknownAccount
+			}
+		}
+
+		err := s.db.Writer(ctx).QueryRow(ctx, `
+			INSERT INTO expense_rules (user_id, expense_type, merchant, category, account_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at, updated_at
+		`, rule.UserID, rule.ExpenseType, rule.Merchant, rule.Category, rule.AccountID).Scan(
+			&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create expense rule: %w", err)
+		}
+
+		marked, err = s.ApplyRules(ctx, rule.UserID)
+		return err
+	})
+	return marked, err
+}
+
+// ListRules returns a user's rules, newest, and so winning, first
+func (s *Service) ListRules(ctx context.Context, userID string) ([]models.ExpenseRule, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, expense_type, merchant, category, account_id, created_at, updated_at
+		FROM expense_rules
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ExpenseRule{}
+	for rows.Next() {
+		var rule models.ExpenseRule
+		err := rows.Scan(&rule.ID, &rule.UserID, &rule.ExpenseType, &rule.Merchant, &rule.Category,
+			&rule.AccountID, &rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expense rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteRule deletes one of a user's rules. The transactions it marked
+// are marked again by the remaining rules, or left unmarked.
+func (s *Service) DeleteRule(ctx context.Context, userID, ruleID string) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		_, err := s.db.Writer(ctx).Exec(ctx, `
+			UPDATE transactions SET expense_type = NULL, expense_rule_id = NULL
+			WHERE user_id = $1 AND expense_rule_id::text = $2
+		`, userID, ruleID)
+		if err != nil {
+			return fmt.Errorf("failed to unmark transactions: %w", err)
+		}
+
+		tag, err := s.db.Writer(ctx).Exec(ctx,
+			"DELETE FROM expense_rules WHERE id::text = $1 AND user_id = $2", ruleID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete expense rule: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		_, err = s.ApplyRules(ctx, userID)
+		return err
+	})
+}
+
+// ApplyRules marks each of a user's transactions not marked by hand with
+// the newest rule it matches, returning how many changed. Merchants match
+// a part of the merchant name or descriptor, ignoring case.
+func (s *Service) ApplyRules(ctx context.Context, userID string) (int, error) {
+	tag, err := s.db.Writer(ctx).Exec(ctx, `
+		WITH matched AS (
+			SELECT DISTINCT ON (t.id) t.id, r.id AS rule_id, r.expense_type
+			FROM transactions t
+			JOIN expense_rules r ON r.user_id = t.user_id
+			     AND (r.merchant IS NULL OR COALESCE(t.merchant_name, t.description) ILIKE '%' || r.merchant || '%')
+			     AND (r.category IS NULL OR r.category = ANY(t.category))
+			     AND (r.account_id IS NULL OR r.account_id = t.account_id)
+			WHERE t.user_id = $1 AND t.deleted_at IS NULL
+			  AND (t.expense_type IS NULL OR t.expense_rule_id IS NOT NULL)
+			ORDER BY t.id, r.created_at DESC, r.id
+		)
+		UPDATE transactions t SET expense_type = m.expense_type, expense_rule_id = m.rule_id
+		FROM matched m
+		WHERE t.id = m.id AND t.expense_rule_id IS DISTINCT FROM m.rule_id
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply expense rules: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Schedule C lines, as the IRS numbers them
+const (
+	LineAdvertising  = "8 Advertising"
+	LineCar          = "9 Car and truck expenses"
+	LineInsurance    = "15 Insurance (other than health)"
+	LineInterest     = "16b Interest (other)"
+	LineProfessional = "17 Legal and professional services"
+	LineOffice       = "18 Office expense"
+	LineRent         = "20b Rent or lease (other business property)"
+	LineRepairs      = "21 Repairs and maintenance"
+	LineSupplies     = "22 Supplies"
+	LineTaxes        = "23 Taxes and licenses"
+	LineTravel       = "24a Travel"
+	LineMeals        = "24b Deductible meals"
+	LineUtilities    = "25 Utilities"
+	LineOther        = "27a Other expenses"
+)
+
+// subcategoryLines map Plaid subcategories to Schedule C lines, before
+// their top-level category is looked up in categoryLines
+var subcategoryLines = map[string]string{
+	"Advertising and Marketing":      LineAdvertising,
+	"Gas Stations":                   LineCar,
+	"Parking":                        LineCar,
+	"Automotive":                     LineCar,
+	"Insurance":                      LineInsurance,
+	"Legal":                          LineProfessional,
+	"Accounting and Bookkeeping":     LineProfessional,
+	"Office Supplies":                LineOffice,
+	"Computers and Electronics":      LineOffice,
+	"Real Estate":                    LineRent,
+	"Rent":                           LineRent,
+	"Repair Services":                LineRepairs,
+	"Telecommunication Services":     LineUtilities,
+	"Utilities":                      LineUtilities,
+	"Internet Services":              LineUtilities,
+	"Cable":                          LineUtilities,
+	"Business Services":              LineOther,
+	"Shipping and Freight":           LineOther,
+	"Restaurants":                    LineMeals,
+	"Airlines and Aviation Services": LineTravel,
+	"Lodging":                        LineTravel,
+}
+
+var categoryLines = map[string]string{
+	"Travel":         LineTravel,
+	"Food and Drink": LineMeals,
+	"Tax":            LineTaxes,
+	"Interest":       LineInterest,
+	"Shops":          LineSupplies,
+}
+
+// ScheduleCLine returns the Schedule C line a business expense in the
+// Plaid category most likely belongs on, or Other expenses
+func ScheduleCLine(category []string) string {
+	for i := len(category) - 1; i > 0; i-- {
+		if line, ok := subcategoryLines[category[i]]; ok {
+			return line
+		}
+	}
+	if len(category) > 0 {
+		if line, ok := categoryLines[category[0]]; ok {
+			return line
+		}
+	}
+	return LineOther
+}
+
+// uncategorized groups transactions Plaid did not categorize
+const uncategorized = "Uncategorized"
+
+// Report totals the expenses of expenseType among transactions dated from
+// start to end by top-level category and, for business expenses, by
+// Schedule C line. Transfers and payments are left out, being money moved
+// rather than spent; other credits are refunds and reduce the net.
+func Report(transactions []models.Transaction, expenseType string, start, end time.Time) models.ExpenseReport {
+	report := models.ExpenseReport{
+		Type: expenseType,
+		Period: models.Period{
+			StartDate: start.Format("2006-01-02"),
+			EndDate:   end.Format("2006-01-02"),
+			Days:      int(end.Sub(start).Hours()/24) + 1,
+		},
+		Charged:      decimal.Zero,
+		Refunded:     decimal.Zero,
+		Net:          decimal.Zero,
+		Categories:   []models.ExpenseCategoryTotal{},
+		Transactions: []models.Transaction{},
+	}
+
+	categories := make(map[string]*models.ExpenseCategoryTotal)
+	lines := make(map[string]*models.ExpenseCategoryTotal)
+	for _, txn := range transactions {
+		top := uncategorized
+		if len(txn.Category) > 0 {
+			top = txn.Category[0]
+		}
+		if top == "Transfer" || top == "Payment" || (top == "Interest" && !txn.Amount.IsPositive()) {
+			continue
+		}
+
+		if txn.Amount.IsPositive() {
+			report.Charged = report.Charged.Add(txn.Amount)
+		} else {
+			report.Refunded = report.Refunded.Add(txn.Amount.Neg())
+		}
+		report.Net = report.Net.Add(txn.Amount)
+		report.Transactions = append(report.Transactions, txn)
+
+		addTotal(categories, top, txn.Amount)
+		if expenseType == TypeBusiness {
+			addTotal(lines, ScheduleCLine(txn.Category), txn.Amount)
+		}
+	}
+
+	report.Categories = sortedTotals(categories)
+	if expenseType == TypeBusiness {
+		report.ScheduleC = sortedTotals(lines)
+	}
+	return report
+}
+
+func addTotal(totals map[string]*models.ExpenseCategoryTotal, name string, amount decimal.Decimal) {
+	t, ok := totals[name]
+	if !ok {
+		t = &models.ExpenseCategoryTotal{Name: name, Net: decimal.Zero}
+		totals[name] = t
+	}
+	t.Net = t.Net.Add(amount)
+	if amount.IsPositive() {
+		t.Count++
+	}
+}
+
+// sortedTotals returns totals largest first
+func sortedTotals(totals map[string]*models.ExpenseCategoryTotal) []models.ExpenseCategoryTotal {
+	sorted := make([]models.ExpenseCategoryTotal, 0, len(totals))
+	for _, t := range totals {
+		sorted = append(sorted, *t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].Net.Equal(sorted[j].Net) {
+			return sorted[i].Net.GreaterThan(sorted[j].Net)
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
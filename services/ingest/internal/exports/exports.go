@@ -0,0 +1,483 @@
+// Package exports writes users' transactions and account balances to
+// buckets of their own on S3 or Google Cloud Storage once a day, as CSV
+// or Parquet, so they can run their own analysis or keep their own backup.
+// Each day's files go under <prefix>/<date>/, written with the access
+// keys the user gave, which are kept encrypted.
+package exports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+)
+
+// Providers
+const (
+	ProviderS3  = "s3"
+	ProviderGCS = "gcs"
+)
+
+// Formats. Datasets and destinations take CSV or Parquet; QIF and OFX are
+// statements of a single account.
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatQIF     = "qif"
+	FormatOFX     = "ofx"
+)
+
+// Datasets are the files an export holds
+const (
+	DatasetTransactions = "transactions"
+	DatasetBalances     = "balances"
+)
+
+// MaxDestinations bounds the destinations a user may keep
+const MaxDestinations = 5
+
+var (
+	// ErrNotFound is returned for a destination that does not exist or
+	// belongs to another user
+	ErrNotFound = errors.New("export destination not found")
+	// ErrLimit is returned when the user has MaxDestinations destinations
+	ErrLimit = errors.New("export destination limit reached")
+	// ErrUnknownDataset is returned for a dataset other than the Dataset
+	// constants
+	ErrUnknownDataset = errors.New("unknown dataset")
+)
+
+var (
+	bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,61}[a-z0-9]$`)
+	regionName = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+)
+
+// Options sets how often destinations are checked for a due export. 0
+// disables the worker.
+type Options struct {
+	Interval time.Duration
+}
+
+// Service manages export destinations and writes exports to them
+type Service struct {
+	db    *database.Database
+	locks *locks.Locker
+	enc   *encryption.Service
+	opts  Options
+}
+
+// NewService creates an export service. When locker is set, only one
+// instance runs exports at a time.
+func NewService(db *database.Database, locker *locks.Locker, enc *encryption.Service, opts Options) *Service {
+	return &Service{db: db, locks: locker, enc: enc, opts: opts}
+}
+
+// Validate normalizes a destination and checks where it points
+func Validate(d *models.ExportDestination) error {
+	if d.Provider != ProviderS3 && d.Provider != ProviderGCS {
+		return errors.New("provider must be 's3' or 'gcs'")
+	}
+	if d.Format == "" {
+		d.Format = FormatCSV
+	}
+	if d.Format != FormatCSV && d.Format != FormatParquet {
+		return errors.New("format must be 'csv' or 'parquet'")
+	}
+
+	d.Bucket = strings.TrimSpace(d.Bucket)
+	if !bucketName.MatchString(d.Bucket) {
+		return errors.New("bucket is not a valid bucket name")
+	}
+	if d.Region != nil && !regionName.MatchString(*d.Region) {
+		return errors.New("region is not a valid region")
+	}
+	d.Prefix = strings.Trim(strings.TrimSpace(d.Prefix), "/")
+	if len(d.Prefix) > 200 || strings.Contains(d.Prefix, "..") || strings.Contains(d.Prefix, "//") {
+		return errors.New("prefix must be at most 200 characters of plain path segments")
+	}
+	if d.KMSKeyID != nil && d.Provider != ProviderS3 {
+		return errors.New("kms_key_id applies to s3 destinations only")
+	}
+	return nil
+}
+
+// Create stores a validated destination with its access keys encrypted
+func (s *Service) Create(ctx context.Context, d *models.ExportDestination, accessKeyID, secretAccessKey string) error {
+	encryptedID, err := s.enc.EncryptString(ctx, accessKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access key: %w", err)
+	}
+	encryptedSecret, err := s.enc.EncryptString(ctx, secretAccessKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access key: %w", err)
+	}
+
+	d.Enabled = true
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO export_destinations (user_id, provider, bucket, region, prefix, format,
+		                                 access_key_id, secret_access_key, kms_key_id)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+		WHERE (SELECT count(*) FROM export_destinations WHERE user_id = $1) < $10
+		RETURNING id, created_at, updated_at
+	`, d.UserID, d.Provider, d.Bucket, d.Region, d.Prefix, d.Format,
+		encryptedID, encryptedSecret, d.KMSKeyID, MaxDestinations).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLimit
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create export destination: %w", err)
+	}
+	return nil
+}
+
+const destinationColumns = `id, user_id, provider, bucket, region, prefix, format, kms_key_id,
+	enabled, last_exported_at, last_error, created_at, updated_at`
+
+// List returns a user's destinations, oldest first
+func (s *Service) List(ctx context.Context, userID string) ([]models.ExportDestination, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT `+destinationColumns+` FROM export_destinations
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := []models.ExportDestination{}
+	for rows.Next() {
+		d, err := scanDestination(rows)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, *d)
+	}
+	return destinations, rows.Err()
+}
+
+// Delete deletes one of a user's destinations. Files already exported
+// stay in the bucket.
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM export_destinations WHERE id::text = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete export destination: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Run exports to the destinations due every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "data_exports")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock data exports", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	exported, failed, err := s.ExportDue(ctx, time.Now().UTC())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to run data exports", "error", err)
+	}
+	if exported > 0 || failed > 0 {
+		slog.InfoContext(ctx, "Ran data exports", "exported", exported, "failed", failed)
+	}
+}
+
+// ExportDue exports to every enabled destination not yet exported to
+// today, in UTC, returning how many exports succeeded and failed. Failed
+// exports are tried again on the next run.
+func (s *Service) ExportDue(ctx context.Context, now time.Time) (int, int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+destinationColumns+` FROM export_destinations
+		WHERE enabled AND (last_exported_at IS NULL OR last_exported_at < $1)
+		ORDER BY last_exported_at NULLS FIRST
+	`, today)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query due export destinations: %w", err)
+	}
+	var due []models.ExportDestination
+	for rows.Next() {
+		d, err := scanDestination(rows)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		due = append(due, *d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	exported, failed := 0, 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := s.export(ctx, &due[i], now); err != nil {
+			slog.WarnContext(ctx, "Data export failed", "destination_id", due[i].ID, "user_id", due[i].UserID, "error", err)
+			failed++
+			continue
+		}
+		exported++
+	}
+	return exported, failed, nil
+}
+
+// ExportNow exports to one of a user's destinations at once, returning
+// the keys of the files written
+func (s *Service) ExportNow(ctx context.Context, userID, id string) ([]string, error) {
+	d, err := scanDestination(s.db.Pool.QueryRow(ctx, `
+		SELECT `+destinationColumns+` FROM export_destinations
+		WHERE id::text = $1 AND user_id = $2
+	`, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.export(ctx, d, time.Now().UTC())
+}
+
+// export writes a destination's files for now's date and records the
+// outcome on it
+func (s *Service) export(ctx context.Context, d *models.ExportDestination, now time.Time) ([]string, error) {
+	keys, err := s.write(ctx, d, now)
+
+	var lastError *string
+	if err != nil {
+		msg := err.Error()
+		lastError = &msg
+	}
+	_, recordErr := s.db.Pool.Exec(ctx, `
+		UPDATE export_destinations
+		SET last_exported_at = CASE WHEN $2::text IS NULL THEN $3 ELSE last_exported_at END,
+		    last_error = $2
+		WHERE id = $1
+	`, d.ID, lastError, now)
+	if recordErr != nil {
+		slog.ErrorContext(ctx, "Failed to record data export", "destination_id", d.ID, "error", recordErr)
+	}
+	return keys, err
+}
+
+func (s *Service) write(ctx context.Context, d *models.ExportDestination, now time.Time) ([]string, error) {
+	var encryptedID, encryptedSecret string
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT access_key_id, secret_access_key FROM export_destinations WHERE id = $1", d.ID).Scan(&encryptedID, &encryptedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export credentials: %w", err)
+	}
+	accessKeyID, err := s.enc.DecryptString(ctx, encryptedID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt access key: %w", err)
+	}
+	secretAccessKey, err := s.enc.DecryptString(ctx, encryptedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt access key: %w", err)
+	}
+
+	b := openBucket(d, accessKeyID, secretAccessKey)
+	dir := now.Format(period.DateLayout)
+	if d.Prefix != "" {
+		dir = d.Prefix + "/" + dir
+	}
+
+	var keys []string
+	for _, name := range []string{DatasetTransactions, DatasetBalances} {
+		body, contentType, err := s.Dataset(ctx, d.UserID, name, d.Format)
+		if err != nil {
+			return keys, err
+		}
+
+		key := dir + "/" + name + "." + d.Format
+		if err := b.put(ctx, key, contentType, body); err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Dataset encodes one of a user's datasets as CSV or Parquet, returning it
+// with its content type. Parquet keeps amounts as DECIMAL(18,2) and dates
+// as DATE, so they load into DuckDB or pandas without parsing.
+func (s *Service) Dataset(ctx context.Context, userID, name, format string) ([]byte, string, error) {
+	var t *table
+	var err error
+	switch name {
+	case DatasetTransactions:
+		t, err = s.transactions(ctx, userID)
+	case DatasetBalances:
+		t, err = s.balances(ctx, userID)
+	default:
+		return nil, "", ErrUnknownDataset
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body []byte
+	contentType := "text/csv"
+	if format == FormatParquet {
+		body, err = writeParquet(t)
+		contentType = "application/vnd.apache.parquet"
+	} else {
+		body, err = writeCSV(t)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return body, contentType, nil
+}
+
+// transactions reads every transaction the user has, oldest first
+func (s *Service) transactions(ctx context.Context, userID string) (*table, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT t.id, t.date, t.account_id, a.name, t.amount, t.merchant_name, t.description,
+		       array_to_string(t.category, ' > '), t.is_pending, t.check_number, t.expense_type
+		FROM transactions t
+		JOIN accounts a ON a.id = t.account_id
+		WHERE t.user_id = $1 AND t.deleted_at IS NULL
+		ORDER BY t.date, t.id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	t := &table{columns: []column{
+		{"transaction_id", colString},
+		{"date", colDate},
+		{"account_id", colString},
+		{"account_name", colString},
+		{"amount", colDecimal},
+		{"merchant_name", colString},
+		{"description", colString},
+		{"category", colString},
+		{"pending", colBool},
+		{"check_number", colString},
+		{"expense_type", colString},
+	}}
+	for rows.Next() {
+		var txn models.Transaction
+		var accountName string
+		var category *string
+		if err := rows.Scan(&txn.ID, &txn.Date, &txn.AccountID, &accountName, &txn.Amount,
+			&txn.MerchantName, &txn.Description, &category, &txn.IsPending,
+			&txn.CheckNumber, &txn.ExpenseType); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		t.rows = append(t.rows, []interface{}{
+			txn.ID, txn.Date, txn.AccountID, accountName, txn.Amount,
+			orNull(txn.MerchantName), orNull(txn.Description), orNull(category), txn.IsPending,
+			orNull(txn.CheckNumber), orNull(txn.ExpenseType),
+		})
+	}
+	return t, rows.Err()
+}
+
+// balances reads the user's accounts with their current balances
+func (s *Service) balances(ctx context.Context, userID string) (*table, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, name, type, subtype, currency, balance_current, balance_available, balance_limit, is_closed
+		FROM accounts
+		WHERE user_id = $1
+		ORDER BY name, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	t := &table{columns: []column{
+		{"account_id", colString},
+		{"name", colString},
+		{"type", colString},
+		{"subtype", colString},
+		{"currency", colString},
+		{"balance_current", colDecimal},
+		{"balance_available", colDecimal},
+		{"balance_limit", colDecimal},
+		{"closed", colBool},
+	}}
+	for rows.Next() {
+		var a models.Account
+		var closed *bool
+		if err := rows.Scan(&a.ID, &a.Name, &a.Type, &a.Subtype, &a.Currency,
+			&a.BalanceCurrent, &a.BalanceAvailable, &a.BalanceLimit, &closed); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		row := []interface{}{a.ID, a.Name, a.Type, orNull(a.Subtype), a.Currency, nil, nil, nil, closed != nil && *closed}
+		if a.BalanceCurrent != nil {
+			row[5] = *a.BalanceCurrent
+		}
+		if a.BalanceAvailable != nil {
+			row[6] = *a.BalanceAvailable
+		}
+		if a.BalanceLimit != nil {
+			row[7] = *a.BalanceLimit
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, rows.Err()
+}
+
+func scanDestination(row pgx.Row) (*models.ExportDestination, error) {
+	var d models.ExportDestination
+	err := row.Scan(&d.ID, &d.UserID, &d.Provider, &d.Bucket, &d.Region, &d.Prefix, &d.Format, &d.KMSKeyID,
+		&d.Enabled, &d.LastExportedAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan export destination: %w", err)
+	}
+	return &d, nil
+}
+
+// orNull is the value s points to, or nil for a null
+func orNull(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
@@ -0,0 +1,156 @@
+package exports
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ofxHeader starts an OFX 1.02 file. The SGML form is the one Quicken,
+// GnuCash and most banks' downloads share.
+const ofxHeader = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:UNICODE
+CHARSET:NONE
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+`
+
+// ofxBankID stands in for the routing number OFX requires of bank
+// accounts, which Plaid does not give
+const ofxBankID = "000000000"
+
+// ofxNameLength is the longest NAME OFX 1.02 allows
+const ofxNameLength = 32
+
+const ofxTimeLayout = "20060102150405"
+
+// WriteOFX encodes s as an OFX bank or credit card statement, generated
+// at now. Each transaction's FITID is its ID, so importing overlapping
+// ranges does not duplicate transactions.
+func WriteOFX(s *Statement, now time.Time) []byte {
+	credit := s.Account.Type == "credit"
+
+	var buf bytes.Buffer
+	buf.WriteString(ofxHeader)
+	tag := func(name, value string) {
+		buf.WriteString("<" + name + ">" + ofxEscape(value) + "\n")
+	}
+	open := func(name string) { buf.WriteString("<" + name + ">\n") }
+	end := func(name string) { buf.WriteString("</" + name + ">\n") }
+	status := func() {
+		open("STATUS")
+		tag("CODE", "0")
+		tag("SEVERITY", "INFO")
+		end("STATUS")
+	}
+
+	open("OFX")
+	open("SIGNONMSGSRSV1")
+	open("SONRS")
+	status()
+	tag("DTSERVER", now.UTC().Format(ofxTimeLayout))
+	tag("LANGUAGE", "ENG")
+	end("SONRS")
+	end("SIGNONMSGSRSV1")
+
+	msgs, trnrs, stmtrs := "BANKMSGSRSV1", "STMTTRNRS", "STMTRS"
+	if credit {
+		msgs, trnrs, stmtrs = "CREDITCARDMSGSRSV1", "CCSTMTTRNRS", "CCSTMTRS"
+	}
+	open(msgs)
+	open(trnrs)
+	tag("TRNUID", "0")
+	status()
+	open(stmtrs)
+	tag("CURDEF", s.Account.Currency)
+	if credit {
+		open("CCACCTFROM")
+		tag("ACCTID", s.Account.ID)
+		end("CCACCTFROM")
+	} else {
+		open("BANKACCTFROM")
+		tag("BANKID", ofxBankID)
+		tag("ACCTID", s.Account.ID)
+		tag("ACCTTYPE", ofxAccountType(s.Account.Subtype))
+		end("BANKACCTFROM")
+	}
+
+	open("BANKTRANLIST")
+	tag("DTSTART", s.Start.Format("20060102"))
+	tag("DTEND", s.End.Format("20060102"))
+	for _, txn := range s.posted() {
+		// Plaid amounts are positive for money leaving the account, OFX's
+		// negative
+		amount := txn.Amount.Neg()
+		trnType := "CREDIT"
+		if amount.IsNegative() {
+			trnType = "DEBIT"
+		}
+		if txn.CheckNumber != nil {
+			trnType = "CHECK"
+		}
+
+		open("STMTTRN")
+		tag("TRNTYPE", trnType)
+		tag("DTPOSTED", txn.Date.Format("20060102"))
+		tag("TRNAMT", amount.StringFixed(2))
+		tag("FITID", txn.ID)
+		if txn.CheckNumber != nil {
+			tag("CHECKNUM", *txn.CheckNumber)
+		}
+		if name := payee(txn); name != "" {
+			tag("NAME", truncate(name, ofxNameLength))
+		}
+		if txn.Description != nil && *txn.Description != payee(txn) {
+			tag("MEMO", *txn.Description)
+		}
+		end("STMTTRN")
+	}
+	end("BANKTRANLIST")
+
+	if s.Account.BalanceCurrent != nil {
+		balance := *s.Account.BalanceCurrent
+		if credit {
+			// A card's balance is what is owed on it
+			balance = balance.Neg()
+		}
+		open("LEDGERBAL")
+		tag("BALAMT", balance.StringFixed(2))
+		tag("DTASOF", s.Account.UpdatedAt.UTC().Format(ofxTimeLayout))
+		end("LEDGERBAL")
+	}
+	end(stmtrs)
+	end(trnrs)
+	end(msgs)
+	end("OFX")
+	return buf.Bytes()
+}
+
+func ofxAccountType(subtype *string) string {
+	if subtype != nil {
+		switch *subtype {
+		case "savings", "cd":
+			return "SAVINGS"
+		case "money market":
+			return "MONEYMRKT"
+		}
+	}
+	return "CHECKING"
+}
+
+// ofxEscape makes s safe as an SGML element value, on one line
+var ofxEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", " ", "\n", " ").Replace
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
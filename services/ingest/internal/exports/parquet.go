@@ -0,0 +1,329 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The subset of Parquet written here: one row group of optional columns,
+// each a single uncompressed, PLAIN-encoded data page, with the file
+// metadata in Thrift's compact protocol. It is what Spark, DuckDB, pandas
+// and BigQuery need to load a table, without taking on a Parquet library.
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+)
+
+// Parquet converted types
+const (
+	convertedUTF8    = 0
+	convertedDecimal = 5
+	convertedDate    = 6
+)
+
+// Parquet enums
+const (
+	repetitionOptional = 1
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+// decimalPrecision and decimalScale describe the DECIMAL(18,2) amounts
+// are stored as, in INT64 cents
+const (
+	decimalPrecision = 18
+	decimalScale     = 2
+)
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// writeParquet encodes t as a Parquet file
+func writeParquet(t *table) ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset int64
+		size   int64
+		values int64
+	}
+	chunks := make([]chunk, len(t.columns))
+	if len(t.rows) > 0 {
+		for i, c := range t.columns {
+			page := encodePage(t, i, c.kind)
+
+			header := newThriftWriter()
+			header.i32(1, pageTypeData)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(page)))
+			header.structBegin(5)
+			header.i32(1, int32(len(t.rows)))
+			header.i32(2, encodingPlain)
+			header.i32(3, encodingRLE)
+			header.i32(4, encodingRLE)
+			header.structEnd()
+			header.stop()
+
+			chunks[i] = chunk{
+				offset: int64(file.Len()),
+				size:   int64(header.buf.Len() + len(page)),
+				values: int64(len(t.rows)),
+			}
+			file.Write(header.buf.Bytes())
+			file.Write(page)
+		}
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+
+	meta.listBegin(2, thriftStruct, len(t.columns)+1)
+	meta.elemBegin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(t.columns)))
+	meta.elemEnd()
+	for _, c := range t.columns {
+		meta.elemBegin()
+		meta.i32(1, physicalType(c.kind))
+		meta.i32(3, repetitionOptional)
+		meta.binary(4, []byte(c.name))
+		switch c.kind {
+		case colString:
+			meta.i32(6, convertedUTF8)
+		case colDate:
+			meta.i32(6, convertedDate)
+		case colDecimal:
+			meta.i32(6, convertedDecimal)
+			meta.i32(7, decimalScale)
+			meta.i32(8, decimalPrecision)
+		}
+		meta.elemEnd()
+	}
+
+	meta.i64(3, int64(len(t.rows)))
+
+	rowGroups := 0
+	if len(t.rows) > 0 {
+		rowGroups = 1
+	}
+	meta.listBegin(4, thriftStruct, rowGroups)
+	if rowGroups > 0 {
+		var total int64
+		meta.elemBegin()
+		meta.listBegin(1, thriftStruct, len(t.columns))
+		for i, c := range t.columns {
+			ch := chunks[i]
+			total += ch.size
+			meta.elemBegin()
+			meta.i64(2, ch.offset)
+			meta.structBegin(3)
+			meta.i32(1, physicalType(c.kind))
+			meta.listBegin(2, thriftI32, 2)
+			meta.listI32(encodingPlain)
+			meta.listI32(encodingRLE)
+			meta.listBegin(3, thriftBinary, 1)
+			meta.listBinary([]byte(c.name))
+			meta.i32(4, codecUncompressed)
+			meta.i64(5, ch.values)
+			meta.i64(6, ch.size)
+			meta.i64(7, ch.size)
+			meta.i64(9, ch.offset)
+			meta.structEnd()
+			meta.elemEnd()
+		}
+		meta.i64(2, total)
+		meta.i64(3, int64(len(t.rows)))
+		meta.elemEnd()
+	}
+
+	meta.binary(6, []byte("finagent-ingest"))
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(meta.buf.Len()))
+	file.Write(length[:])
+	file.WriteString(parquetMagic)
+	return file.Bytes(), nil
+}
+
+func physicalType(kind int) int32 {
+	switch kind {
+	case colDate:
+		return parquetInt32
+	case colDecimal:
+		return parquetInt64
+	case colBool:
+		return parquetBoolean
+	}
+	return parquetByteArray
+}
+
+// encodePage encodes column i of t as a data page: its definition levels,
+// 1 for a value and 0 for a null, then its values
+func encodePage(t *table, i, kind int) []byte {
+	var levels, values bytes.Buffer
+	var bits []bool
+
+	// Definition levels are RLE runs, prefixed with their length
+	run, runLevel := 0, byte(0)
+	flush := func() {
+		if run > 0 {
+			writeUvarint(&levels, uint64(run)<<1)
+			levels.WriteByte(runLevel)
+		}
+	}
+
+	for _, row := range t.rows {
+		v := row[i]
+		level := byte(0)
+		if v != nil {
+			level = 1
+		}
+		if level != runLevel {
+			flush()
+			run, runLevel = 0, level
+		}
+		run++
+
+		switch v := v.(type) {
+		case string:
+			var n [4]byte
+			binary.LittleEndian.PutUint32(n[:], uint32(len(v)))
+			values.Write(n[:])
+			values.WriteString(v)
+		case time.Time:
+			var n [4]byte
+			days := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+			binary.LittleEndian.PutUint32(n[:], uint32(int32(days)))
+			values.Write(n[:])
+		case decimal.Decimal:
+			var n [8]byte
+			binary.LittleEndian.PutUint64(n[:], uint64(v.Round(decimalScale).Shift(decimalScale).IntPart()))
+			values.Write(n[:])
+		case bool:
+			bits = append(bits, v)
+		}
+	}
+	flush()
+
+	if kind == colBool {
+		packed := make([]byte, (len(bits)+7)/8)
+		for j, b := range bits {
+			if b {
+				packed[j/8] |= 1 << (j % 8)
+			}
+		}
+		values.Write(packed)
+	}
+
+	page := make([]byte, 4, 4+levels.Len()+values.Len())
+	binary.LittleEndian.PutUint32(page, uint32(levels.Len()))
+	page = append(page, levels.Bytes()...)
+	return append(page, values.Bytes()...)
+}
+
+// thriftWriter writes Thrift compact protocol structs
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // last field ID written in each open struct
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		writeZigzag(&w.buf, int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	writeZigzag(&w.buf, int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	writeZigzag(&w.buf, v)
+}
+
+func (w *thriftWriter) binary(id int16, v []byte) {
+	w.field(id, thriftBinary)
+	w.listBinary(v)
+}
+
+func (w *thriftWriter) structBegin(id int16) {
+	w.field(id, thriftStruct)
+	w.elemBegin()
+}
+
+func (w *thriftWriter) structEnd() {
+	w.elemEnd()
+}
+
+func (w *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elemType)
+	writeUvarint(&w.buf, uint64(size))
+}
+
+// elemBegin and elemEnd enclose a struct in a list
+func (w *thriftWriter) elemBegin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) elemEnd() {
+	w.stop()
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) listI32(v int32) {
+	writeZigzag(&w.buf, int64(v))
+}
+
+func (w *thriftWriter) listBinary(v []byte) {
+	writeUvarint(&w.buf, uint64(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(0)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var n [binary.MaxVarintLen64]byte
+	buf.Write(n[:binary.PutUvarint(n[:], v)])
+}
+
+func writeZigzag(buf *bytes.Buffer, v int64) {
+	writeUvarint(buf, uint64(v<<1)^uint64(v>>63))
+}
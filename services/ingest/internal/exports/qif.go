@@ -0,0 +1,92 @@
+package exports
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+)
+
+// Statement is an account's transactions from Start to End, as written to
+// QIF and OFX files for desktop finance software such as Quicken and
+// GnuCash
+type Statement struct {
+	Account      *models.Account
+	Transactions []models.Transaction
+	Start        time.Time
+	End          time.Time
+}
+
+// posted returns the statement's posted transactions, oldest first.
+// Pending ones are left out, as they are reposted under a new ID and
+// would be imported twice.
+func (s *Statement) posted() []models.Transaction {
+	var txns []models.Transaction
+	for _, txn := range s.Transactions {
+		if !txn.IsPending {
+			txns = append(txns, txn)
+		}
+	}
+	sort.SliceStable(txns, func(i, j int) bool {
+		return txns[i].Date.Before(txns[j].Date)
+	})
+	return txns
+}
+
+// WriteQIF encodes s as a QIF file of a single account. Amounts are
+// negative for money leaving the account, the reverse of Plaid's.
+func WriteQIF(s *Statement) []byte {
+	qifType := "Bank"
+	switch s.Account.Type {
+	case "credit":
+		qifType = "CCard"
+	case "loan":
+		qifType = "Oth L"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("!Account\n")
+	buf.WriteString("N" + qifLine(s.Account.DisplayName()) + "\n")
+	buf.WriteString("T" + qifType + "\n")
+	buf.WriteString("^\n")
+	buf.WriteString("!Type:" + qifType + "\n")
+
+	for _, txn := range s.posted() {
+		buf.WriteString("D" + txn.Date.Format("01/02/2006") + "\n")
+		buf.WriteString("T" + txn.Amount.Neg().StringFixed(2) + "\n")
+		if txn.CheckNumber != nil {
+			buf.WriteString("N" + qifLine(*txn.CheckNumber) + "\n")
+		}
+		if payee := payee(txn); payee != "" {
+			buf.WriteString("P" + qifLine(payee) + "\n")
+		}
+		if txn.Description != nil && *txn.Description != payee(txn) {
+			buf.WriteString("M" + qifLine(*txn.Description) + "\n")
+		}
+		if len(txn.Category) > 0 {
+			// QIF separates subcategories with a colon
+			buf.WriteString("L" + qifLine(strings.Join(txn.Category, ":")) + "\n")
+		}
+		buf.WriteString("^\n")
+	}
+	return buf.Bytes()
+}
+
+// payee is who a transaction was with: its merchant, or else its
+// description
+func payee(txn models.Transaction) string {
+	if txn.MerchantName != nil && *txn.MerchantName != "" {
+		return *txn.MerchantName
+	}
+	if txn.Description != nil {
+		return *txn.Description
+	}
+	return ""
+}
+
+// qifLine keeps a value to one line, as each QIF field is a line
+func qifLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,70 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage, which
+// accepts HMAC keys in place of AWS credentials
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// bucket writes export files to a destination with the user's keys. The
+// service's own AWS credentials are never used.
+type bucket struct {
+	client      *s3.Client
+	destination *models.ExportDestination
+}
+
+func openBucket(d *models.ExportDestination, accessKeyID, secretAccessKey string) *bucket {
+	region := "us-east-1"
+	if d.Provider == ProviderGCS {
+		region = "auto"
+	}
+	if d.Region != nil {
+		region = *d.Region
+	}
+
+	cfg := aws.Config{
+		Region:      region,
+		Credentials: credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if d.Provider == ProviderGCS {
+			o.BaseEndpoint = aws.String(gcsEndpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &bucket{client: client, destination: d}
+}
+
+// put writes an object. S3 encrypts it with the destination's KMS key, or
+// its own keys without one; Cloud Storage encrypts everything at rest.
+func (b *bucket) put(ctx context.Context, key, contentType string, body []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(b.destination.Bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   aws.String(contentType),
+	}
+	if b.destination.Provider == ProviderS3 {
+		input.ServerSideEncryption = types.ServerSideEncryptionAes256
+		if b.destination.KMSKeyID != nil {
+			input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+			input.SSEKMSKeyId = b.destination.KMSKeyID
+		}
+	}
+
+	if _, err := b.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+	return nil
+}
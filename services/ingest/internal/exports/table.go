@@ -0,0 +1,70 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// Column types
+const (
+	colString  = iota // string
+	colDate           // time.Time, a date in UTC
+	colDecimal        // decimal.Decimal, kept to cents
+	colBool           // bool
+)
+
+// column is a named, typed table column
+type column struct {
+	name string
+	kind int
+}
+
+// table is what an export file holds. A nil value is a null.
+type table struct {
+	columns []column
+	rows    [][]interface{}
+}
+
+// writeCSV encodes t as CSV with a header row. Nulls are empty.
+func writeCSV(t *table) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	record := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		record[i] = c.name
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+
+	for _, row := range t.rows {
+		for i, v := range row {
+			record[i] = formatValue(v)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(period.DateLayout)
+	case decimal.Decimal:
+		return v.StringFixed(2)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
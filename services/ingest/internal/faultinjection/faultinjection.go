@@ -0,0 +1,136 @@
+// Package faultinjection injects failures for resilience testing: latency
+// and 5xx responses on incoming requests, latency and transient errors on
+// calls to Plaid and Robinhood, and dropped Plaid webhooks. It is meant
+// for staging, to check that syncs retry, recover from missed webhooks and
+// stay idempotent, and does nothing unless enabled.
+package faultinjection
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/metrics"
+)
+
+// Header marks responses whose failure was injected
+const Header = "X-Fault-Injected"
+
+// ErrInjected is the cause of every injected upstream failure
+var ErrInjected = errors.New("injected fault")
+
+// Options sets the fault rates, each the share of requests, calls or
+// webhooks affected
+type Options struct {
+	Enabled             bool
+	Latency             time.Duration // delay added by latency faults
+	LatencyRate         float64       // incoming requests delayed
+	HTTPErrorRate       float64       // incoming requests answered with 503
+	UpstreamLatencyRate float64       // upstream calls delayed
+	UpstreamErrorRate   float64       // upstream calls failed with a transient error
+	WebhookDropRate     float64       // Plaid webhooks acknowledged but discarded
+	SkipPrefixes        []string      // paths never faulted, such as probes
+}
+
+// Injector decides which requests and calls fail. A nil Injector injects
+// nothing.
+type Injector struct {
+	opts Options
+}
+
+// New creates an injector, or returns nil when fault injection is disabled
+func New(opts Options) *Injector {
+	if !opts.Enabled {
+		return nil
+	}
+	return &Injector{opts: opts}
+}
+
+// Middleware delays and fails incoming requests at the configured rates
+func (in *Injector) Middleware(next http.Handler) http.Handler {
+	if in == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if in.skipped(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if hit(in.opts.LatencyRate) {
+			metrics.ObserveFault("http_latency")
+			if err := sleep(r.Context(), in.opts.Latency); err != nil {
+				return
+			}
+		}
+		if hit(in.opts.HTTPErrorRate) {
+			metrics.ObserveFault("http_error")
+			slog.InfoContext(r.Context(), "Injecting 503 response", "path", r.URL.Path)
+			w.Header().Set(Header, "true")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"success":false,"error":"Injected fault"}`))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Upstream delays and fails a call to provider at the configured rates,
+// returning ErrInjected when the call should fail as if the provider had
+// a transient outage
+func (in *Injector) Upstream(ctx context.Context, provider string) error {
+	if in == nil {
+		return nil
+	}
+	if hit(in.opts.UpstreamLatencyRate) {
+		metrics.ObserveFault(provider + "_latency")
+		if err := sleep(ctx, in.opts.Latency); err != nil {
+			return err
+		}
+	}
+	if hit(in.opts.UpstreamErrorRate) {
+		metrics.ObserveFault(provider + "_error")
+		slog.InfoContext(ctx, "Injecting upstream failure", "provider", provider)
+		return ErrInjected
+	}
+	return nil
+}
+
+// DropWebhook reports whether an incoming webhook should be discarded
+// after being acknowledged, as if it had never arrived
+func (in *Injector) DropWebhook(ctx context.Context) bool {
+	if in == nil || !hit(in.opts.WebhookDropRate) {
+		return false
+	}
+	metrics.ObserveFault("webhook_drop")
+	slog.InfoContext(ctx, "Dropping webhook")
+	return true
+}
+
+func (in *Injector) skipped(path string) bool {
+	for _, prefix := range in.opts.SkipPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
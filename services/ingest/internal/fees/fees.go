@@ -0,0 +1,143 @@
+// Package fees finds the bank fees and interest charges among a user's
+// transactions. Plaid categorizes some of them; others only show in the
+// descriptor the bank sends, such as "ATM FEE" or "INTEREST CHARGE ON
+// PURCHASES", so both are checked.
+package fees
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of fee, most specific first
+const (
+	KindATM         = "atm"
+	KindOverdraft   = "overdraft"
+	KindLate        = "late_payment"
+	KindForeign     = "foreign_transaction"
+	KindInterest    = "interest"
+	KindCashAdvance = "cash_advance"
+	KindService     = "service"
+	KindOther       = "other"
+)
+
+// rule classifies transactions whose category or descriptor matches
+type rule struct {
+	kind          string
+	subcategories []string // Plaid subcategories under Bank Fees or Interest
+	descriptor    *regexp.Regexp
+}
+
+// rules are checked in order; the first match wins
+var rules = []rule{
+	{KindATM, []string{"ATM"}, regexp.MustCompile(`(?i)\bATM\b.*\b(FEE|CHARGE|SURCHARGE)\b|\b(FEE|SURCHARGE)\b.*\bATM\b`)},
+	{KindOverdraft, []string{"Overdraft", "Insufficient Funds"}, regexp.MustCompile(`(?i)\bOVERDRAFT\b|\bOD FEE\b|\bNSF\b|INSUFFICIENT FUNDS|RETURNED ITEM`)},
+	{KindLate, []string{"Late Payment"}, regexp.MustCompile(`(?i)\bLATE (PAYMENT )?(FEE|CHARGE)\b`)},
+	{KindForeign, []string{"Foreign Transaction"}, regexp.MustCompile(`(?i)\b(FOREIGN|INTL|INTERNATIONAL) (TRANSACTION|TXN|EXCHANGE|CURRENCY)? ?(FEE|CHARGE)\b`)},
+	{KindInterest, []string{"Interest Charged"}, regexp.MustCompile(`(?i)\bINTEREST CHARGE|\bPURCHASE INTEREST\b|\bFINANCE CHARGE\b`)},
+	{KindCashAdvance, []string{"Cash Advance"}, regexp.MustCompile(`(?i)\bCASH ADVANCE (FEE|CHARGE)\b`)},
+	{KindService, []string{"Excess Activity", "Wire Transfer"}, regexp.MustCompile(`(?i)\b(MONTHLY|MAINTENANCE|SERVICE|ACCOUNT|ANNUAL|WIRE|PAPER STATEMENT) (MAINTENANCE )?(FEE|CHARGE)\b`)},
+}
+
+// Classify returns the kind of fee a transaction is, or "" when it is
+// not one. Interest earned is not a fee, nor are transfers and payments
+// whose descriptors mention one, such as overdraft protection transfers.
+func Classify(txn models.Transaction) string {
+	var top, sub string
+	if len(txn.Category) > 0 {
+		top = txn.Category[0]
+	}
+	if len(txn.Category) > 1 {
+		sub = txn.Category[1]
+	}
+	switch {
+	case top == "Interest" && sub != "Interest Charged", top == "Transfer", top == "Payment":
+		return ""
+	}
+
+	descriptor := ""
+	if txn.Description != nil {
+		descriptor = *txn.Description
+	}
+	for _, r := range rules {
+		if top == "Bank Fees" || top == "Interest" {
+			for _, s := range r.subcategories {
+				if sub == s {
+					return r.kind
+				}
+			}
+		}
+		if descriptor != "" && r.descriptor.MatchString(descriptor) {
+			return r.kind
+		}
+	}
+	if top == "Bank Fees" {
+		return KindOther
+	}
+	return ""
+}
+
+// Report totals the fees among transactions dated from start to end.
+// Fees refunded or reversed, which arrive as credits, reduce the net.
+// Annualized figures scale the net to 365 days.
+func Report(transactions []models.Transaction, start, end time.Time) models.FeeReport {
+	days := int(end.Sub(start).Hours()/24) + 1
+	report := models.FeeReport{
+		Period: models.Period{
+			StartDate: start.Format("2006-01-02"),
+			EndDate:   end.Format("2006-01-02"),
+			Days:      days,
+		},
+		Charged:      decimal.Zero,
+		Refunded:     decimal.Zero,
+		Kinds:        []models.FeeKindTotal{},
+		Transactions: []models.Transaction{},
+	}
+
+	kinds := make(map[string]*models.FeeKindTotal)
+	for _, txn := range transactions {
+		kind := Classify(txn)
+		if kind == "" {
+			continue
+		}
+		k, ok := kinds[kind]
+		if !ok {
+			k = &models.FeeKindTotal{Kind: kind, Net: decimal.Zero}
+			kinds[kind] = k
+		}
+		if txn.Amount.IsPositive() {
+			report.Charged = report.Charged.Add(txn.Amount)
+			k.Count++
+		} else {
+			report.Refunded = report.Refunded.Add(txn.Amount.Neg())
+		}
+		k.Net = k.Net.Add(txn.Amount)
+		report.Transactions = append(report.Transactions, txn)
+	}
+
+	report.Net = report.Charged.Sub(report.Refunded)
+	report.Annualized = annualize(report.Net, days)
+	for _, k := range kinds {
+		k.Annualized = annualize(k.Net, days)
+		report.Kinds = append(report.Kinds, *k)
+	}
+	sort.Slice(report.Kinds, func(i, j int) bool {
+		if !report.Kinds[i].Net.Equal(report.Kinds[j].Net) {
+			return report.Kinds[i].Net.GreaterThan(report.Kinds[j].Net)
+		}
+		return report.Kinds[i].Kind < report.Kinds[j].Kind
+	})
+	return report
+}
+
+// annualize scales an amount over days to a year
+func annualize(amount decimal.Decimal, days int) decimal.Decimal {
+	if days <= 0 {
+		return decimal.Zero
+	}
+	return amount.Mul(decimal.NewFromInt(365)).Div(decimal.NewFromInt(int64(days))).Round(2)
+}
@@ -0,0 +1,230 @@
+// Package giving finds a user's charitable donations among their
+// transactions and reports a year's giving for tax time. A transaction is
+// a donation when its category is one, or when its merchant is a charity
+// on a list of well-known ones. Receipts are the documents attached to a
+// donation; gifts of ReceiptThreshold or more need a written
+// acknowledgment from the charity to be deducted, so those without one
+// are flagged.
+package giving
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/finagent/ingest/internal/categories"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// donationCategory is the personal finance category of donations
+const donationCategory = "GOVERNMENT_AND_NON_PROFIT_DONATIONS"
+
+// ReceiptThreshold is the smallest single gift that needs a written
+// acknowledgment from the charity
+var ReceiptThreshold = decimal.NewFromInt(250)
+
+// charity is a well-known charity and the lowercase fragments of merchant
+// names and descriptions its donations appear under
+type charity struct {
+	name     string
+	patterns []string
+}
+
+// knownCharities are charities recognized by name. Crowdfunding sites are
+// left out, as gifts to individuals through them are not deductible, and
+// so are thrift stores, where most transactions are purchases.
+var knownCharities = []charity{
+	{"American Red Cross", []string{"red cross", "redcross"}},
+	{"UNICEF", []string{"unicef"}},
+	{"The Salvation Army", []string{"salvation army"}},
+	{"Doctors Without Borders", []string{"doctors without borders", "msf usa"}},
+	{"St. Jude Children's Research Hospital", []string{"st jude", "st. jude", "stjude"}},
+	{"Habitat for Humanity", []string{"habitat for humanity", "habitat for hum"}},
+	{"Feeding America", []string{"feeding america"}},
+	{"United Way", []string{"united way"}},
+	{"Wikimedia Foundation", []string{"wikimedia", "wikipedia"}},
+	{"World Wildlife Fund", []string{"world wildlife", "worldwildlife"}},
+	{"ASPCA", []string{"aspca"}},
+	{"Save the Children", []string{"save the children"}},
+	{"American Cancer Society", []string{"american cancer soc", "cancer.org"}},
+	{"Oxfam", []string{"oxfam"}},
+	{"charity: water", []string{"charity water", "charity: water", "charitywater"}},
+	{"Electronic Frontier Foundation", []string{"electronic frontier", "eff.org"}},
+	{"Khan Academy", []string{"khan academy"}},
+	{"Direct Relief", []string{"direct relief"}},
+	{"GiveDirectly", []string{"givedirectly", "give directly"}},
+	{"Against Malaria Foundation", []string{"against malaria"}},
+}
+
+// KnownCharity returns the well-known charity a transaction was paid to,
+// or an empty string
+func KnownCharity(txn models.Transaction) string {
+	for _, field := range []*string{txn.MerchantName, txn.Description} {
+		if field == nil {
+			continue
+		}
+		text := strings.ToLower(*field)
+		for _, c := range knownCharities {
+			for _, p := range c.patterns {
+				if strings.Contains(text, p) {
+					return c.name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Classify reports whether a transaction is a donation, to which charity
+// and why. Money in, such as a refunded gift, is never a donation.
+func Classify(txn models.Transaction) (string, string, bool) {
+	if !txn.Amount.IsPositive() {
+		return "", "", false
+	}
+
+	name := KnownCharity(txn)
+	if isDonationCategory(txn) {
+		if name == "" {
+			name = payee(txn)
+		}
+		return name, models.DonationCategory, true
+	}
+	if name != "" {
+		return name, models.DonationCharity, true
+	}
+	return "", "", false
+}
+
+// isDonationCategory reports whether a transaction's personal finance
+// category, or that of its legacy category, is donations
+func isDonationCategory(txn models.Transaction) bool {
+	if txn.PersonalFinanceCategoryDetailed != nil {
+		return *txn.PersonalFinanceCategoryDetailed == donationCategory
+	}
+	c, ok := categories.FromLegacy(txn.Category)
+	return ok && c.Detailed == donationCategory
+}
+
+// payee names who a donation went to
+func payee(txn models.Transaction) string {
+	if txn.MerchantName != nil && *txn.MerchantName != "" {
+		return *txn.MerchantName
+	}
+	if txn.Description != nil && *txn.Description != "" {
+		return *txn.Description
+	}
+	return "Unknown charity"
+}
+
+// Service looks up the receipts attached to donations
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a giving service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// Receipts returns the documents attached to a user's transactions, oldest
+// first, by transaction ID
+func (s *Service) Receipts(ctx context.Context, userID string, transactionIDs []string) (map[string][]models.Receipt, error) {
+	receipts := make(map[string][]models.Receipt)
+	if len(transactionIDs) == 0 {
+		return receipts, nil
+	}
+
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT transaction_id, id, filename, content_type
+		FROM transaction_attachments
+		WHERE user_id = $1 AND transaction_id = ANY($2)
+		ORDER BY created_at
+	`, userID, transactionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transactionID string
+		var r models.Receipt
+		if err := rows.Scan(&transactionID, &r.ID, &r.Filename, &r.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		receipts[transactionID] = append(receipts[transactionID], r)
+	}
+	return receipts, rows.Err()
+}
+
+// Donations returns the transactions that are donations, newest first,
+// without their receipts
+func Donations(transactions []models.Transaction) []models.Donation {
+	donations := []models.Donation{}
+	for _, txn := range transactions {
+		name, reason, ok := Classify(txn)
+		if !ok {
+			continue
+		}
+		donations = append(donations, models.Donation{
+			TransactionID: txn.ID,
+			AccountID:     txn.AccountID,
+			Date:          txn.Date.Format(period.DateLayout),
+			Amount:        txn.Amount,
+			Charity:       name,
+			Reason:        reason,
+			Receipts:      []models.Receipt{},
+		})
+	}
+	sort.SliceStable(donations, func(i, j int) bool { return donations[i].Date > donations[j].Date })
+	return donations
+}
+
+// Report matches donations to their receipts and totals a year's giving,
+// by charity largest first
+func Report(year int, donations []models.Donation, receipts map[string][]models.Receipt) models.GivingReport {
+	report := models.GivingReport{
+		Year:             year,
+		Total:            decimal.Zero,
+		WithReceipts:     decimal.Zero,
+		WithoutReceipts:  decimal.Zero,
+		ReceiptThreshold: ReceiptThreshold,
+		ByCharity:        []models.CharityTotal{},
+		Donations:        donations,
+	}
+
+	charities := make(map[string]int)
+	for i := range donations {
+		d := &donations[i]
+		if r, ok := receipts[d.TransactionID]; ok {
+			d.Receipts = r
+		}
+		report.Total = report.Total.Add(d.Amount)
+		report.Count++
+		if len(d.Receipts) > 0 {
+			report.WithReceipts = report.WithReceipts.Add(d.Amount)
+		} else {
+			report.WithoutReceipts = report.WithoutReceipts.Add(d.Amount)
+			if d.Amount.GreaterThanOrEqual(ReceiptThreshold) {
+				d.NeedsReceipt = true
+				report.NeedingReceipts++
+			}
+		}
+
+		i, ok := charities[d.Charity]
+		if !ok {
+			i = len(report.ByCharity)
+			charities[d.Charity] = i
+			report.ByCharity = append(report.ByCharity, models.CharityTotal{Charity: d.Charity, Total: decimal.Zero})
+		}
+		report.ByCharity[i].Total = report.ByCharity[i].Total.Add(d.Amount)
+		report.ByCharity[i].Count++
+	}
+	sort.SliceStable(report.ByCharity, func(i, j int) bool {
+		return report.ByCharity[i].Total.GreaterThan(report.ByCharity[j].Total)
+	})
+	return report
+}
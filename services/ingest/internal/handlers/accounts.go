@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxAccountNameLength bounds account nicknames and group names, in
+// characters
+const maxAccountNameLength = 64
+
+// UpdateAccount renames an account, moves it between account groups, or
+// hides it or excludes it from analytics, so closed, business or joint
+// accounts can stay linked without counting toward net worth, budgets and
+// summaries. It also sets the day a credit card's statement closes, and
+// the APY, in percent, a depository account earns, which syncs then keep.
+// An empty nickname or group_id, a statement_close_day of 0, or a
+// negative apy clears it.
+func (h *Handlers) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID               string           `json:"user_id"`
+		Nickname             *string          `json:"nickname"`
+		GroupID              *string          `json:"group_id"`
+		Hidden               *bool            `json:"hidden"`
+		ExcludeFromAnalytics *bool            `json:"exclude_from_analytics"`
+		StatementCloseDay    *int             `json:"statement_close_day"`
+		APY                  *decimal.Decimal `json:"apy"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Nickname == nil && req.GroupID == nil && req.Hidden == nil && req.ExcludeFromAnalytics == nil &&
+		req.StatementCloseDay == nil && req.APY == nil {
+		h.respondError(w, http.StatusBadRequest, "nickname, group_id, hidden, exclude_from_analytics, statement_close_day or apy is required")
+		return
+	}
+	if req.StatementCloseDay != nil && (*req.StatementCloseDay < 0 || *req.StatementCloseDay > 31) {
+		h.respondError(w, http.StatusBadRequest, "statement_close_day must be from 1 to 31, or 0 to clear it")
+		return
+	}
+	if req.APY != nil && req.APY.GreaterThan(decimal.NewFromInt(100)) {
+		h.respondError(w, http.StatusBadRequest, "apy must be a percent from 0 to 100, or negative to clear it")
+		return
+	}
+	if req.Nickname != nil {
+		nickname := strings.TrimSpace(*req.Nickname)
+		if len([]rune(nickname)) > maxAccountNameLength {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("nickname must be at most %d characters", maxAccountNameLength))
+			return
+		}
+		req.Nickname = &nickname
+	}
+	if req.GroupID != nil && *req.GroupID != "" {
+		_, err := h.store.Accounts.Group(ctx, *req.GroupID, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusBadRequest, "Account group not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to query account group", "user_id", userID, "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query account group")
+			return
+		}
+	}
+	if req.APY != nil && !req.APY.IsNegative() {
+		existing, err := h.store.Accounts.Get(ctx, chi.URLParam(r, "id"), userID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Account not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to query account", "user_id", userID, "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+			return
+		}
+		if existing.Type != "depository" {
+			h.respondError(w, http.StatusBadRequest, "apy may only be set on depository accounts")
+			return
+		}
+	}
+
+	account, err := h.store.Accounts.Update(ctx, chi.URLParam(r, "id"), userID, store.AccountUpdate{
+		Nickname:             req.Nickname,
+		GroupID:              req.GroupID,
+		Hidden:               req.Hidden,
+		ExcludeFromAnalytics: req.ExcludeFromAnalytics,
+		StatementCloseDay:    req.StatementCloseDay,
+		APY:                  req.APY,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update account", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update account")
+		return
+	}
+	if err := h.decryptPII(ctx, &account.Mask, &account.OfficialName); err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to decrypt account")
+		return
+	}
+
+	h.invalidateCache(ctx, userID)
+	h.publishResourceChanges(ctx, userID, resourceAccounts, resourceNetWorthLatest)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"account": account,
+	})
+}
+
+// ListAccountGroups returns a user's account groups with their accounts
+// totalled
+func (h *Handlers) ListAccountGroups(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	groups, err := h.store.Accounts.ListGroups(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list account groups", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query account groups")
+		return
+	}
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"groups":  groups,
+		"count":   len(groups),
+		"summary": summarizeAccountGroups(accounts, groups),
+	})
+}
+
+// CreateAccountGroup creates a named account group, such as Emergency Fund
+// or Business
+func (h *Handlers) CreateAccountGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Name   string `json:"name"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	name, ok := h.accountGroupName(w, req.Name)
+	if !ok {
+		return
+	}
+
+	group := &models.AccountGroup{UserID: userID, Name: name}
+	err := h.store.Accounts.CreateGroup(ctx, group)
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "An account group with that name already exists")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create account group", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create account group")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"group": group},
+	})
+}
+
+// RenameAccountGroup renames one of a user's account groups
+func (h *Handlers) RenameAccountGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Name   string `json:"name"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	name, ok := h.accountGroupName(w, req.Name)
+	if !ok {
+		return
+	}
+
+	group, err := h.store.Accounts.RenameGroup(ctx, chi.URLParam(r, "id"), userID, name)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account group not found")
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "An account group with that name already exists")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to rename account group", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to rename account group")
+		return
+	}
+
+	h.invalidateCache(ctx, userID)
+	h.respondSuccess(w, map[string]interface{}{
+		"group": group,
+	})
+}
+
+// DeleteAccountGroup deletes one of a user's account groups; its accounts
+// are left ungrouped
+func (h *Handlers) DeleteAccountGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.store.Accounts.DeleteGroup(ctx, chi.URLParam(r, "id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account group not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete account group", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete account group")
+		return
+	}
+
+	h.invalidateCache(ctx, userID)
+	h.publishResourceChanges(ctx, userID, resourceAccounts, resourceNetWorthLatest)
+	h.respondSuccess(w, map[string]interface{}{
+		"message": "Account group deleted",
+	})
+}
+
+// accountGroupName trims and checks a group name, writing an error
+// response and returning false when it is invalid
+func (h *Handlers) accountGroupName(w http.ResponseWriter, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		h.respondError(w, http.StatusBadRequest, "name is required")
+		return "", false
+	case len([]rune(name)) > maxAccountNameLength:
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name must be at most %d characters", maxAccountNameLength))
+		return "", false
+	}
+	return name, true
+}
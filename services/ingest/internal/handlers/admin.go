@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/capture"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminListUsers lists users with linked item and account counts
+func (h *Handlers) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := parsePagination(r, 100, 1000)
+
+	users, err := h.store.Users.List(ctx, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query users")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"users":  users,
+		"count":  len(users),
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// AdminItemHealth reports the health of Plaid items across all users
+func (h *Handlers) AdminItemHealth(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := r.URL.Query().Get("status")
+	limit, offset := parsePagination(r, 100, 1000)
+
+	items, err := h.store.Items.Health(ctx, status, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query item health")
+		return
+	}
+
+	for i := range items {
+		item := &items[i]
+		item.Healthy = item.Status == "active" && (item.LastJobStatus == nil || *item.LastJobStatus != jobs.StatusFailed)
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// AdminJobsDashboard summarises the job queue: depth and retries per type,
+// recent outcomes and how long the oldest ready job has waited
+func (h *Handlers) AdminJobsDashboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.jobs.Stats(r.Context())
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query job stats")
+		return
+	}
+
+	h.respondSuccess(w, stats)
+}
+
+// AdminListFailedJobs lists dead-lettered jobs (failed after exhausting
+// their retries), most recent first
+func (h *Handlers) AdminListFailedJobs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := parsePagination(r, 100, 1000)
+
+	failed, err := h.jobs.ListByStatus(ctx, jobs.StatusFailed, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query jobs")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"jobs":  failed,
+		"count": len(failed),
+	})
+}
+
+// AdminRequeueJob re-runs a failed sync job
+func (h *Handlers) AdminRequeueJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobID := chi.URLParam(r, "id")
+
+	job, err := h.jobs.Get(ctx, jobID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query job")
+		return
+	}
+
+	if !h.jobs.Handles(job.Type) {
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("job type %s cannot be requeued", job.Type))
+		return
+	}
+
+	if err := h.jobs.Requeue(ctx, jobID); err != nil {
+		if errors.Is(err, jobs.ErrNotFailed) {
+			h.respondError(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to requeue job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"job_id":  jobID,
+		"message": "Job requeued",
+	})
+}
+
+// AdminWebhookFailures lists outbound webhook deliveries that exhausted their retries
+func (h *Handlers) AdminWebhookFailures(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := parsePagination(r, 100, 1000)
+
+	failures, err := h.store.Subscriptions.ListFailedDeliveries(ctx, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query webhook failures")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"failures": failures,
+		"count":    len(failures),
+	})
+}
+
+// AdminSyncOverview summarises Plaid syncs over a window (default 24h, at
+// most 30 days): jobs per status, run times, failure rates per institution
+// and the lag from a webhook arriving to its data being visible
+func (h *Handlers) AdminSyncOverview(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > 30*24*time.Hour {
+			h.respondError(w, http.StatusBadRequest, "window must be a duration up to 720h, such as 24h")
+			return
+		}
+		window = d
+	}
+
+	overview, err := h.store.Items.SyncOverview(r.Context(), time.Now().Add(-window),
+		[]string{jobs.TypeTransactionsWebhook, jobs.TypeManualSync, jobs.TypeInitialSync},
+		jobs.TypeTransactionsWebhook)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to query sync overview", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query sync overview")
+		return
+	}
+
+	h.respondSuccess(w, overview)
+}
+
+// AdminWebhookLag reports each Plaid item's webhook lag over a window
+// (default 24h, at most 30 days): p50, p95 and worst time from a webhook
+// arriving to its data being persisted, and how often that breached the SLO
+func (h *Handlers) AdminWebhookLag(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > 30*24*time.Hour {
+			h.respondError(w, http.StatusBadRequest, "window must be a duration up to 720h, such as 24h")
+			return
+		}
+		window = d
+	}
+	limit, _ := parsePagination(r, 100, 1000)
+
+	items, err := h.store.Items.WebhookLag(r.Context(), time.Now().Add(-window), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to query webhook lag", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query webhook lag")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"items":       items,
+		"count":       len(items),
+		"slo_seconds": h.webhookLagSLO.Seconds(),
+	})
+}
+
+// parsePagination reads limit/offset query params with a default and maximum limit
+func parsePagination(r *http.Request, defaultLimit, maxLimit int) (int, int) {
+	limit := defaultLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxLimit {
+		limit = l
+	}
+
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	return limit, offset
+}
+
+// AdminListCapturedRequests lists the request/response payloads this
+// instance captured for debugging, newest first, optionally only those with
+// at least min_status, under a path prefix or with a request_id
+func (h *Handlers) AdminListCapturedRequests(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := parsePagination(r, 50, 500)
+	minStatus, _ := strconv.Atoi(q.Get("min_status"))
+
+	entries := h.capture.Entries(capture.Filter{
+		MinStatus:  minStatus,
+		PathPrefix: q.Get("path"),
+		RequestID:  q.Get("request_id"),
+		Limit:      limit,
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"enabled":     h.capture.Enabled(),
+		"sample_rate": h.capture.SampleRate(),
+		"requests":    entries,
+		"count":       len(entries),
+	})
+}
+
+// AdminClearCapturedRequests drops this instance's captured payloads
+func (h *Handlers) AdminClearCapturedRequests(w http.ResponseWriter, r *http.Request) {
+	h.capture.Clear()
+	h.respondSuccess(w, map[string]interface{}{
+		"message": "Captured requests cleared",
+	})
+}
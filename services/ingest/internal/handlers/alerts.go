@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/finagent/ingest/internal/alerts"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxAlertRuleNameLength bounds alert rule names, in characters
+const maxAlertRuleNameLength = 100
+
+// alertSeverities are the severities a rule's insights may have
+var alertSeverities = []string{insights.SeverityInfo, insights.SeverityWarning, insights.SeverityCritical}
+
+// CreateAlertRule adds an alert rule, such as a notification whenever a
+// charge over $200 hits a credit card, also posted to a Slack channel:
+//
+//	{"name": "Large card charge", "condition": "amount > 200 and account_type = \"credit\"", "channel_ids": ["..."]}
+func (h *Handlers) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID     string   `json:"user_id"`
+		Name       string   `json:"name"`
+		Condition  string   `json:"condition"`
+		Severity   string   `json:"severity"`
+		Enabled    *bool    `json:"enabled"`
+		ChannelIDs []string `json:"channel_ids"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rule := &models.AlertRule{
+		UserID:     userID,
+		Name:       strings.TrimSpace(req.Name),
+		Condition:  req.Condition,
+		Severity:   req.Severity,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		ChannelIDs: req.ChannelIDs,
+	}
+	if rule.Severity == "" {
+		rule.Severity = insights.SeverityInfo
+	}
+	if !h.validAlertRule(w, rule) {
+		return
+	}
+
+	err := h.alerts.CreateRule(ctx, rule)
+	if errors.Is(err, alerts.ErrInvalidCondition) || errors.Is(err, alerts.ErrUnknownChannel) {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create alert rule", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create alert rule")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"rule": rule,
+		},
+	})
+}
+
+// ListAlertRules returns a user's alert rules
+func (h *Handlers) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	rules, err := h.alerts.ListRules(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list alert rules", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query alert rules")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// GetAlertRule returns one alert rule
+func (h *Handlers) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	rule, ok := h.alertRule(w, r, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+// UpdateAlertRule changes the name, condition, severity, enabled flag or
+// channels given in the body
+func (h *Handlers) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID     string    `json:"user_id"`
+		Name       *string   `json:"name"`
+		Condition  *string   `json:"condition"`
+		Severity   *string   `json:"severity"`
+		Enabled    *bool     `json:"enabled"`
+		ChannelIDs *[]string `json:"channel_ids"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rule, ok := h.alertRule(w, r, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Condition != nil {
+		rule.Condition = *req.Condition
+	}
+	if req.Severity != nil {
+		rule.Severity = *req.Severity
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.ChannelIDs != nil {
+		rule.ChannelIDs = *req.ChannelIDs
+	}
+	if !h.validAlertRule(w, rule) {
+		return
+	}
+
+	err := h.alerts.UpdateRule(ctx, rule)
+	switch {
+	case errors.Is(err, alerts.ErrInvalidCondition), errors.Is(err, alerts.ErrUnknownChannel):
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, alerts.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "Alert rule not found")
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to update alert rule", "rule_id", rule.ID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update alert rule")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+// DeleteAlertRule deletes an alert rule; notifications it raised are kept
+func (h *Handlers) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ruleID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.alerts.DeleteRule(ctx, userID, ruleID)
+	if errors.Is(err, alerts.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Alert rule not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete alert rule", "rule_id", ruleID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete alert rule")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      ruleID,
+	})
+}
+
+// alertRule loads one of a user's rules, writing an error response and
+// returning false when it cannot
+func (h *Handlers) alertRule(w http.ResponseWriter, r *http.Request, userID, ruleID string) (*models.AlertRule, bool) {
+	ctx := r.Context()
+	rule, err := h.alerts.GetRule(ctx, userID, ruleID)
+	if errors.Is(err, alerts.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Alert rule not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query alert rule", "rule_id", ruleID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query alert rule")
+		return nil, false
+	}
+	return rule, true
+}
+
+// validAlertRule checks a rule's name and severity, writing an error
+// response and returning false when one is invalid. Conditions are checked
+// by the engine.
+func (h *Handlers) validAlertRule(w http.ResponseWriter, rule *models.AlertRule) bool {
+	switch {
+	case rule.Name == "":
+		h.respondError(w, http.StatusBadRequest, "name is required")
+		return false
+	case len([]rune(rule.Name)) > maxAlertRuleNameLength:
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name must be at most %d characters", maxAlertRuleNameLength))
+		return false
+	}
+	for _, s := range alertSeverities {
+		if rule.Severity == s {
+			return true
+		}
+	}
+	h.respondError(w, http.StatusBadRequest, "severity must be one of "+strings.Join(alertSeverities, ", "))
+	return false
+}
+
+// evaluateAlerts runs a user's alert rules over a sync's accounts and
+// transactions, logging rather than failing on errors
+func (h *Handlers) evaluateAlerts(ctx context.Context, userID string, accounts []models.PlaidAccount, transactions []models.PlaidTransaction) {
+	if h.alerts == nil {
+		return
+	}
+	raised, err := h.alerts.Evaluate(ctx, userID, accounts, transactions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to evaluate alert rules", "user_id", userID, "error", err)
+	}
+	if raised > 0 {
+		slog.InfoContext(ctx, "Raised alerts", "user_id", userID, "count", raised)
+	}
+}
+
+// ListNotifications returns a user's notifications, newest first
+func (h *Handlers) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	unread, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+	limit, offset := parsePagination(r, 50, 200)
+
+	list, err := h.notify.List(ctx, userID, unread, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"notifications": list,
+		"count":         len(list),
+	})
+}
+
+// MarkNotificationRead marks a notification as read
+func (h *Handlers) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	notificationID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.notify.MarkRead(ctx, userID, notificationID)
+	if errors.Is(err, notifications.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to update notification")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"read": true,
+		"id":   notificationID,
+	})
+}
+
+// CreateNotificationChannel adds a Slack or Discord incoming webhook that
+// alert rules can route notifications to:
+//
+//	{"kind": "slack", "name": "#money", "webhook_url": "https://hooks.slack.com/services/..."}
+func (h *Handlers) CreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID     string `json:"user_id"`
+		Kind       string `json:"kind"`
+		Name       string `json:"name"`
+		WebhookURL string `json:"webhook_url"`
+		Enabled    *bool  `json:"enabled"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	channel := &models.NotificationChannel{
+		UserID:  userID,
+		Kind:    strings.ToLower(req.Kind),
+		Name:    strings.TrimSpace(req.Name),
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+	switch {
+	case channel.Name == "":
+		h.respondError(w, http.StatusBadRequest, "name is required")
+		return
+	case len([]rune(channel.Name)) > maxAlertRuleNameLength:
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name must be at most %d characters", maxAlertRuleNameLength))
+		return
+	case !validChannelKind(channel.Kind):
+		h.respondError(w, http.StatusBadRequest, "kind must be one of "+strings.Join(notifications.ChannelKinds, ", "))
+		return
+	}
+
+	err := h.notify.CreateChannel(ctx, channel, strings.TrimSpace(req.WebhookURL))
+	if errors.Is(err, notifications.ErrInvalidWebhookURL) {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create notification channel", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create notification channel")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"channel": channel,
+		},
+	})
+}
+
+// ListNotificationChannels returns a user's chat channels with their last
+// delivery outcome
+func (h *Handlers) ListNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	channels, err := h.notify.ListChannels(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list notification channels", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query notification channels")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"channels": channels,
+		"count":    len(channels),
+	})
+}
+
+// DeleteNotificationChannel deletes a chat channel, removing it from the
+// rules that route to it
+func (h *Handlers) DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	channelID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.notify.DeleteChannel(ctx, userID, channelID)
+	if errors.Is(err, notifications.ErrChannelNotFound) {
+		h.respondError(w, http.StatusNotFound, "Notification channel not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete notification channel", "channel_id", channelID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete notification channel")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      channelID,
+	})
+}
+
+// TestNotificationChannel posts a test message to a chat channel, reporting
+// whether it was delivered
+func (h *Handlers) TestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	channelID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.notify.TestChannel(ctx, userID, channelID)
+	if errors.Is(err, notifications.ErrChannelNotFound) {
+		h.respondError(w, http.StatusNotFound, "Notification channel not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadGateway, "Test delivery failed: "+err.Error())
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"delivered": true,
+		"id":        channelID,
+	})
+}
+
+func validChannelKind(kind string) bool {
+	for _, k := range notifications.ChannelKinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
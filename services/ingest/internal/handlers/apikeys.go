@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/apikeys"
+	"github.com/go-chi/chi/v5"
+)
+
+// rotationGracePeriod is how long a rotated key keeps working
+const rotationGracePeriod = 24 * time.Hour
+
+// AdminIssueAPIKey issues a new service API key
+func (h *Handlers) AdminIssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		Redact        bool     `json:"redact,omitempty"`
+		ExpiresInDays int      `json:"expires_in_days,omitempty"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		h.respondError(w, http.StatusBadRequest, "name and scopes are required")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	plaintext, key, err := h.apiKeys.Issue(ctx, req.Name, req.Scopes, req.Redact, expiresAt)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"api_key": key,
+			"key":     plaintext,
+			"message": "Store the key securely; it cannot be retrieved again",
+		},
+	})
+}
+
+// AdminListAPIKeys lists API key metadata
+func (h *Handlers) AdminListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeys.List(r.Context())
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query API keys")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// AdminRotateAPIKey issues a replacement key and schedules the old one to expire
+func (h *Handlers) AdminRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	plaintext, key, err := h.apiKeys.Rotate(r.Context(), chi.URLParam(r, "id"), rotationGracePeriod)
+	if errors.Is(err, apikeys.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"api_key":          key,
+		"key":              plaintext,
+		"previous_expires": time.Now().Add(rotationGracePeriod).UTC(),
+		"message":          "Store the key securely; the previous key stops working after the grace period",
+	})
+}
+
+// AdminSetAPIKeyRedaction sets whether a key's responses have PII masked
+func (h *Handlers) AdminSetAPIKeyRedaction(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "id")
+
+	var req struct {
+		Redact *bool `json:"redact"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Redact == nil {
+		h.respondError(w, http.StatusBadRequest, "redact is required")
+		return
+	}
+
+	err := h.apiKeys.SetRedact(r.Context(), keyID, *req.Redact)
+	if errors.Is(err, apikeys.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to update API key")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"id":     keyID,
+		"redact": *req.Redact,
+	})
+}
+
+// AdminRevokeAPIKey immediately revokes an API key
+func (h *Handlers) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "id")
+
+	err := h.apiKeys.Revoke(r.Context(), keyID)
+	if errors.Is(err, apikeys.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"revoked": true,
+		"id":      keyID,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/attachments"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// MultipartOverhead allows for the form boundaries and headers around an
+// uploaded file
+const MultipartOverhead = 64 << 10
+
+// attachmentsEnabled responds 503 when no object storage is configured
+func (h *Handlers) attachmentsEnabled(w http.ResponseWriter) bool {
+	if h.attachments == nil {
+		h.respondError(w, http.StatusServiceUnavailable, "Attachments are not configured")
+		return false
+	}
+	return true
+}
+
+// respondAttachmentError maps attachment errors to responses
+func (h *Handlers) respondAttachmentError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, attachments.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "Attachment not found")
+	case errors.Is(err, attachments.ErrTooLarge):
+		h.respondError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Attachments must be at most %d bytes", h.attachments.MaxBytes()))
+	case errors.Is(err, attachments.ErrUnsupportedType):
+		h.respondError(w, http.StatusUnsupportedMediaType, "Attachments must be PDF, JPEG, PNG, WebP or HEIC")
+	case errors.Is(err, attachments.ErrLimit):
+		h.respondError(w, http.StatusConflict,
+			fmt.Sprintf("A transaction can have at most %d attachments", attachments.MaxPerTransaction))
+	default:
+		slog.ErrorContext(r.Context(), "Failed to handle attachment", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to handle attachment")
+	}
+}
+
+// UploadAttachment attaches a receipt or document, sent as the file field
+// of a multipart form, to a transaction
+func (h *Handlers) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	if !h.attachmentsEnabled(w) {
+		return
+	}
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.attachments.MaxBytes()+MultipartOverhead)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondAttachmentError(w, r, attachments.ErrTooLarge)
+			return
+		}
+		h.respondError(w, http.StatusBadRequest, "Expected a multipart form with a file field")
+		return
+	}
+	defer file.Close()
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	attachment, err := h.attachments.Upload(r.Context(), userID, chi.URLParam(r, "id"),
+		header.Filename, header.Header.Get("Content-Type"), file)
+	if err != nil {
+		h.respondAttachmentError(w, r, err)
+		return
+	}
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"attachment": attachment},
+	})
+}
+
+// ListAttachments returns a transaction's attachments with signed
+// download URLs
+func (h *Handlers) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	if !h.attachmentsEnabled(w) {
+		return
+	}
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	list, err := h.attachments.List(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondAttachmentError(w, r, err)
+		return
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"attachments": list,
+		"count":       len(list),
+	})
+}
+
+// GetAttachment returns one attachment with a signed download URL
+func (h *Handlers) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	if !h.attachmentsEnabled(w) {
+		return
+	}
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	attachment, err := h.attachments.Get(r.Context(), userID, chi.URLParam(r, "id"), chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		h.respondAttachmentError(w, r, err)
+		return
+	}
+	h.respondSuccess(w, map[string]interface{}{"attachment": attachment})
+}
+
+// DeleteAttachment removes an attachment and its file
+func (h *Handlers) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	if !h.attachmentsEnabled(w) {
+		return
+	}
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.attachments.Delete(r.Context(), userID, chi.URLParam(r, "id"), chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		h.respondAttachmentError(w, r, err)
+		return
+	}
+	h.respondSuccess(w, map[string]interface{}{"deleted": true})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+)
+
+const (
+	alice = "11111111-1111-1111-1111-111111111111"
+	bob   = "22222222-2222-2222-2222-222222222222"
+	carol = "33333333-3333-3333-3333-333333333333"
+)
+
+// fakeGrants holds the roles owners have granted, by owner then grantee
+type fakeGrants map[string]map[string]string
+
+func (g fakeGrants) Role(ctx context.Context, ownerID, granteeID string) (string, error) {
+	return g[ownerID][granteeID], nil
+}
+
+func (g fakeGrants) Upsert(ctx context.Context, grant *models.Grant) error { return nil }
+
+func (g fakeGrants) ListActive(ctx context.Context, userID string) ([]models.Grant, error) {
+	return nil, nil
+}
+
+func (g fakeGrants) Revoke(ctx context.Context, grantID, userID string) error { return nil }
+
+// newAuthzHandlers returns handlers whose grants are bob granting alice
+// the advisor role and carol granting alice the viewer role
+func newAuthzHandlers() *Handlers {
+	return &Handlers{store: &store.Stores{Grants: fakeGrants{
+		bob:   {alice: auth.RoleAdvisor},
+		carol: {alice: auth.RoleViewer},
+	}}}
+}
+
+func userPrincipal(userID string) *auth.Principal {
+	return &auth.Principal{UserID: userID, AuthID: "auth|" + userID}
+}
+
+func servicePrincipal() *auth.Principal {
+	return &auth.Principal{APIKeyID: "key-1", Name: "mcp", Scopes: []string{auth.ScopeRead}}
+}
+
+func TestAuthorizeQueryUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *auth.Principal
+		userID     string
+		role       string
+		wantStatus int
+		wantUser   string
+	}{
+		{"own data", userPrincipal(alice), alice, auth.RoleOwner, http.StatusOK, alice},
+		{"own data by default", userPrincipal(alice), "", auth.RoleOwner, http.StatusOK, alice},
+		{"another user's data", userPrincipal(bob), alice, auth.RoleViewer, http.StatusForbidden, ""},
+		{"service principal", servicePrincipal(), bob, auth.RoleOwner, http.StatusOK, bob},
+		{"service principal without user_id", servicePrincipal(), "", auth.RoleViewer, http.StatusBadRequest, ""},
+		{"unauthenticated without user_id", nil, "", auth.RoleViewer, http.StatusBadRequest, ""},
+		{"advisor grant on advisor route", userPrincipal(alice), bob, auth.RoleAdvisor, http.StatusOK, bob},
+		{"advisor grant on owner-only route", userPrincipal(alice), bob, auth.RoleOwner, http.StatusForbidden, ""},
+		{"viewer grant on viewer route", userPrincipal(alice), carol, auth.RoleViewer, http.StatusOK, carol},
+		{"viewer grant on advisor route", userPrincipal(alice), carol, auth.RoleAdvisor, http.StatusForbidden, ""},
+		{"viewer grant on owner-only route", userPrincipal(alice), carol, auth.RoleOwner, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newAuthzHandlers()
+
+			r := httptest.NewRequest(http.MethodGet, "/read/accounts?user_id="+tt.userID, nil)
+			if tt.principal != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+
+			var gotUser string
+			userID, ok := h.authorizeQueryUser(w, r, tt.role)
+			if ok {
+				gotUser = userID
+				w.WriteHeader(http.StatusOK)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if ok != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("ok = %v with status %d", ok, w.Code)
+			}
+			if gotUser != tt.wantUser {
+				t.Fatalf("user = %q, want %q", gotUser, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestAuthorizeUserBodyUserID(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *auth.Principal
+		requested  string
+		wantStatus int
+	}{
+		{"own data", userPrincipal(alice), alice, http.StatusOK},
+		{"another user's data", userPrincipal(alice), "44444444-4444-4444-4444-444444444444", http.StatusForbidden},
+		{"service principal", servicePrincipal(), alice, http.StatusOK},
+		{"service principal without user_id", servicePrincipal(), "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newAuthzHandlers()
+
+			// The query string must not override the user named in the body
+			r := httptest.NewRequest(http.MethodPost, "/plaid/sync?user_id="+alice, nil)
+			r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+			w := httptest.NewRecorder()
+
+			userID, ok := h.authorizeUser(w, r, tt.requested, auth.RoleOwner)
+			if ok {
+				w.WriteHeader(http.StatusOK)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if ok && tt.requested != "" && userID != tt.requested {
+				t.Fatalf("user = %q, want %q", userID, tt.requested)
+			}
+		})
+	}
+}
+
+func TestPlaceCryptoOrderForbidsOtherUsers(t *testing.T) {
+	h := newAuthzHandlers()
+
+	// bob granted alice the advisor role, but trading needs the owner
+	for _, userID := range []string{bob, carol} {
+		body := `{"user_id": "` + userID + `", "symbol": "BTC", "side": "buy", "quantity": "1"}`
+		r := httptest.NewRequest(http.MethodPost, "/rh/crypto/orders", strings.NewReader(body))
+		r = r.WithContext(auth.WithPrincipal(r.Context(), userPrincipal(alice)))
+		w := httptest.NewRecorder()
+
+		h.PlaceCryptoOrder(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("order for %s: status = %d, want %d (body %s)", userID, w.Code, http.StatusForbidden, w.Body.String())
+		}
+	}
+}
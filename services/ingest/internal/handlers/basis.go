@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+// Spending bases. Accrual counts a card purchase on the day it was made;
+// cash counts it on the day the card payment covering it was made.
+const (
+	basisAccrual = "accrual"
+	basisCash    = "cash"
+)
+
+// cashBasisLookbackDays is how far before a range purchases are read for a
+// cash-basis summary, so payments in the range can be matched to the
+// statements they pay off
+const cashBasisLookbackDays = 90
+
+// parseBasis reads a spending basis, defaulting to accrual
+func parseBasis(basis string) (string, bool) {
+	switch basis {
+	case "", basisAccrual:
+		return basisAccrual, true
+	case basisCash:
+		return basisCash, true
+	}
+	return "", false
+}
+
+// queryBasis reads the basis query parameter, responding 400 when it is
+// not a known basis
+func (h *Handlers) queryBasis(w http.ResponseWriter, r *http.Request) (string, bool) {
+	basis, ok := parseBasis(r.URL.Query().Get("basis"))
+	if !ok {
+		h.respondError(w, http.StatusBadRequest, "basis must be 'accrual' or 'cash'")
+	}
+	return basis, ok
+}
+
+// basisFilter widens a summary's filter to read the purchases a cash-basis
+// summary may attribute to its range
+func basisFilter(filter store.TransactionFilter, basis string) store.TransactionFilter {
+	if basis != basisCash {
+		return filter
+	}
+	if start, err := time.Parse(period.DateLayout, filter.StartDate); err == nil {
+		filter.StartDate = start.AddDate(0, 0, -cashBasisLookbackDays).Format(period.DateLayout)
+	}
+	return filter
+}
+
+// applyBasis attributes transactions read through basisFilter to the
+// days from start to end on basis
+func applyBasis(transactions []models.Transaction, basis, start, end string) []models.Transaction {
+	if basis != basisCash {
+		return transactions
+	}
+	return cashBasis(transactions, start, end)
+}
+
+// isCardPayment reports whether a transaction is a credit card payment,
+// either the payment into a card or the transfer out of a bank account
+func isCardPayment(txn models.Transaction) bool {
+	if len(txn.Category) == 0 {
+		return false
+	}
+	switch txn.Category[0] {
+	case "Payment":
+		return len(txn.Category) == 1 || txn.Category[1] == "Credit Card"
+	case "Transfer":
+		return txn.AccountType == "credit" && txn.Amount.IsNegative()
+	}
+	return false
+}
+
+// cashBasis moves credit card spending from the day of each purchase to
+// the day of the payment that covers it. Each card's payments pay off its
+// oldest unpaid purchases first, splitting a purchase that is only partly
+// paid. Purchases not yet paid fall out of the summary, as do the bank
+// side of card payments, whose spending the purchases now stand for. The
+// result is limited to the days from start to end, newest first.
+//
+// Balances carried from before the lookback are not read, so payments are
+// matched to purchases a statement early when a card carries one; the
+// amount paid in the range is still counted in full.
+func cashBasis(transactions []models.Transaction, start, end string) []models.Transaction {
+	inRange := func(date time.Time) bool {
+		day := date.Format(period.DateLayout)
+		return day >= start && day <= end
+	}
+
+	var result []models.Transaction
+	cards := make(map[string][]models.Transaction)
+	for _, txn := range transactions {
+		if txn.AccountType == "credit" {
+			cards[txn.AccountID] = append(cards[txn.AccountID], txn)
+		}
+	}
+	for _, txn := range transactions {
+		if txn.AccountType == "credit" || !inRange(txn.Date) {
+			continue
+		}
+		if len(cards) > 0 && isCardPayment(txn) && txn.Amount.IsPositive() {
+			continue
+		}
+		result = append(result, txn)
+	}
+
+	type purchase struct {
+		txn    models.Transaction
+		unpaid decimal.Decimal
+	}
+	for _, card := range cards {
+		sort.SliceStable(card, func(i, j int) bool { return card[i].Date.Before(card[j].Date) })
+
+		// unpaid holds the card's purchases not yet paid off, oldest first
+		var unpaid []purchase
+		for _, txn := range card {
+			if txn.IsPending {
+				continue
+			}
+			if txn.Amount.IsPositive() {
+				unpaid = append(unpaid, purchase{txn: txn, unpaid: txn.Amount})
+				continue
+			}
+			if !isCardPayment(txn) {
+				// Refunds and credits count on the day they post
+				if inRange(txn.Date) {
+					result = append(result, txn)
+				}
+				continue
+			}
+
+			paid := txn.Amount.Neg()
+			for len(unpaid) > 0 && paid.IsPositive() {
+				p := &unpaid[0]
+				amount := decimal.Min(p.unpaid, paid)
+				if inRange(txn.Date) {
+					spent := p.txn
+					spent.Date = txn.Date
+					spent.Amount = amount
+					result = append(result, spent)
+				}
+				p.unpaid = p.unpaid.Sub(amount)
+				paid = paid.Sub(amount)
+				if !p.unpaid.IsPositive() {
+					unpaid = unpaid[1:]
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Date.Equal(result[j].Date) {
+			return result[i].Date.After(result[j].Date)
+		}
+		return result[i].Amount.GreaterThan(result[j].Amount)
+	})
+	return result
+}
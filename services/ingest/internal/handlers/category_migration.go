@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/jobs"
+)
+
+// categoryMigrationTask moves transactions from legacy Plaid categories
+// to personal finance categories
+func (h *Handlers) categoryMigrationTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	return h.categories.Migrate(ctx, progress)
+}
+
+// AdminMigrateCategories starts a job setting the personal finance
+// category of every transaction Plaid sent with only legacy categories.
+// With dry_run=true it instead reports how many transactions would change
+// category, by legacy category, without changing any.
+func (h *Handlers) AdminMigrateCategories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	if dryRun {
+		report, err := h.categories.Plan(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to plan category migration", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to plan category migration")
+			return
+		}
+		h.respondSuccess(w, map[string]interface{}{
+			"report": report,
+		})
+		return
+	}
+
+	jobID, err := h.categories.Queue(ctx)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to start category migration job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"job_id": jobID,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/cpi"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+// maxComparisonPeriods bounds the periods one spending comparison covers
+const maxComparisonPeriods = 10
+
+// GetSpendingComparison summarizes a user's spending over each of a list
+// of periods, comma separated such as "2022,2023,this year" and by
+// default last year and this, fiscal years for users who set one, with
+// each period's change in spending from the one before. Periods may name
+// the user's own, such as "last pay period,this pay period". With real_terms=true, amounts are restated in the
+// prices of the latest month of the Consumer Price Index, so spending
+// years apart compares fairly.
+func (h *Handlers) GetSpendingComparison(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	now, cal := h.userCalendar(ctx, userID)
+	expressions := []string{"last year", "this year"}
+	if cal.FiscalYearStart > time.January {
+		expressions = []string{"last fiscal year", "this fiscal year"}
+	}
+	if s := r.URL.Query().Get("periods"); s != "" {
+		expressions = strings.Split(s, ",")
+	}
+	if len(expressions) < 2 || len(expressions) > maxComparisonPeriods {
+		h.respondError(w, http.StatusBadRequest, "periods must list 2 to 10 periods")
+		return
+	}
+
+	realTerms := false
+	if s := r.URL.Query().Get("real_terms"); s != "" {
+		var err error
+		if realTerms, err = strconv.ParseBool(s); err != nil {
+			h.respondError(w, http.StatusBadRequest, "real_terms must be true or false")
+			return
+		}
+	}
+
+	ranges := make([]period.Range, len(expressions))
+	for i, expr := range expressions {
+		resolved, err := cal.Resolve(expr, now)
+		if errors.Is(err, period.ErrUnrecognized) {
+			h.respondError(w, http.StatusBadRequest, "Unrecognized period \""+strings.TrimSpace(expr)+"\"; try \"2023\", \"Q2 2024\" or \"last year\"")
+			return
+		}
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ranges[i] = resolved
+	}
+
+	response := map[string]interface{}{"real_terms": realTerms}
+	var index *cpi.Index
+	if realTerms {
+		var err error
+		index, err = h.cpi.Index(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load CPI", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query inflation data")
+			return
+		}
+		if index.Empty() {
+			h.respondError(w, http.StatusServiceUnavailable, "Inflation data is not available yet")
+			return
+		}
+		response["cpi_series"] = index.SeriesID
+		response["price_base"] = index.Base().Format("2006-01")
+	}
+
+	periods := make([]models.PeriodSpending, len(ranges))
+	for i, rng := range ranges {
+		startDate := rng.Start.Format(period.DateLayout)
+		endDate := rng.End.Format(period.DateLayout)
+		transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+			UserID:    userID,
+			StartDate: startDate,
+			EndDate:   endDate,
+			Analytics: true,
+			Limit:     maxSummaryTransactions,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+			return
+		}
+		if index != nil {
+			for j := range transactions {
+				transactions[j].Amount = index.Adjust(transactions[j].Amount, transactions[j].Date)
+			}
+		}
+
+		p := &periods[i]
+		p.Label = rng.Label
+		p.Summary = summarizeSpending(transactions, startDate, endDate, summaryTopN)
+		p.Truncated = len(transactions) == maxSummaryTransactions
+		if i > 0 && periods[i-1].Summary.TotalSpent.IsPositive() {
+			before := periods[i-1].Summary.TotalSpent
+			change, _ := p.Summary.TotalSpent.Sub(before).Div(before).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+			p.SpentChangePercent = &change
+		}
+	}
+
+	response["periods"] = periods
+	h.respondSuccess(w, response)
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// consentNoticePeriod is how long before it lapses Plaid warns that an
+// item's consent is expiring, assumed when a webhook omits the time
+const consentNoticePeriod = 7 * 24 * time.Hour
+
+// handleConsentExpiring records when an item's consent lapses and, the
+// first time Plaid warns about that date, asks the user to reconnect the
+// item before syncing stops
+func (h *Handlers) handleConsentExpiring(ctx context.Context, webhook models.PlaidWebhook) error {
+	expiresAt := time.Now().Add(consentNoticePeriod).UTC()
+	if webhook.ConsentExpirationTime != nil {
+		expiresAt = webhook.ConsentExpirationTime.UTC()
+	}
+
+	consent, changed, err := h.store.Items.RecordConsentExpiration(ctx, webhook.ItemID, expiresAt)
+	if errors.Is(err, store.ErrNotFound) {
+		slog.WarnContext(ctx, "Consent expiring for unknown item", "plaid_item_id", webhook.ItemID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !changed || h.notify == nil {
+		return nil
+	}
+
+	institution := "your bank"
+	if consent.InstitutionName != nil && *consent.InstitutionName != "" {
+		institution = *consent.InstitutionName
+	}
+	err = h.notify.Create(ctx, &models.Notification{
+		UserID:  consent.UserID,
+		Type:    notifications.TypeConsent,
+		Title:   fmt.Sprintf("Reconnect %s", institution),
+		Message: fmt.Sprintf("Access to %s expires on %s. Reconnect it through Plaid Link before then to keep your accounts syncing.", institution, expiresAt.Format("January 2, 2006")),
+		Metadata: map[string]interface{}{
+			"plaid_item_id":      consent.ItemID,
+			"consent_expires_at": expiresAt,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to notify user of expiring consent: %w", err)
+	}
+	return nil
+}
+
+// ListItemConsents returns the products each of a user's items may be
+// read for and when their consent expires
+func (h *Handlers) ListItemConsents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	consents, err := h.store.Items.ListConsents(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list item consents", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query item consents")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"items": consents,
+		"count": len(consents),
+	})
+}
+
+// GetItemConsent returns one item's consent
+func (h *Handlers) GetItemConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	consent, err := h.store.Items.Consent(ctx, chi.URLParam(r, "id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query item consent", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query item consent")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"consent": consent,
+	})
+}
+
+// UpdateItemConsent narrows the products an item is synced for, or
+// records when its consent expires. Products can only be removed; adding
+// one needs the user to consent again through Plaid Link. Data already
+// synced for a removed product is kept until the user deletes it.
+func (h *Handlers) UpdateItemConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID           string     `json:"user_id"`
+		Products         []string   `json:"products"`
+		ConsentExpiresAt *time.Time `json:"consent_expires_at"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Products == nil && req.ConsentExpiresAt == nil {
+		h.respondError(w, http.StatusBadRequest, "products or consent_expires_at is required")
+		return
+	}
+
+	itemID := chi.URLParam(r, "id")
+	consent, err := h.store.Items.Consent(ctx, itemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query item consent", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query item consent")
+		return
+	}
+
+	products := consent.ConsentedProducts
+	if req.Products != nil {
+		if len(req.Products) == 0 {
+			h.respondError(w, http.StatusBadRequest, "products must not be empty; unlink the item to revoke all access")
+			return
+		}
+		products = []string{}
+		seen := make(map[string]bool)
+		for _, product := range req.Products {
+			if seen[product] {
+				continue
+			}
+			seen[product] = true
+			if !isPlaidProduct(product) {
+				h.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown product %q", product))
+				return
+			}
+			if !consent.Consented(product) {
+				h.respondError(w, http.StatusConflict, fmt.Sprintf("%s is not consented; relink the item through Plaid Link to add it", product))
+				return
+			}
+			products = append(products, product)
+		}
+		sort.Strings(products)
+	}
+
+	expiresAt := consent.ConsentExpiresAt
+	if req.ConsentExpiresAt != nil {
+		t := req.ConsentExpiresAt.UTC()
+		expiresAt = &t
+	}
+
+	updated, err := h.store.Items.UpdateConsent(ctx, itemID, userID, products, expiresAt)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update item consent", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update item consent")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionConsentUpdated,
+		TargetUserID: userID,
+		Metadata: map[string]interface{}{
+			"item_id":            itemID,
+			"consented_products": updated.ConsentedProducts,
+			"consent_expires_at": updated.ConsentExpiresAt,
+		},
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"consent": updated,
+	})
+}
+
+func isPlaidProduct(product string) bool {
+	for _, p := range plaid.Products {
+		if p == product {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/contributions"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// GetContributionLimits returns what a user has contributed to 401(k),
+// IRA and HSA accounts in a year, by default this one, against the IRS's
+// limits and the headroom left. catch_up=true adds the catch-up allowance
+// of those old enough; hsa_coverage=family uses the family HSA limit.
+func (h *Handlers) GetContributionLimits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	year := now.Year()
+	if s := r.URL.Query().Get("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 2000 || y > now.Year() {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("year must be from 2000 to %d", now.Year()))
+			return
+		}
+		year = y
+	}
+
+	var opts contributions.Options
+	if s := r.URL.Query().Get("catch_up"); s != "" {
+		catchUp, err := strconv.ParseBool(s)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "catch_up must be true or false")
+			return
+		}
+		opts.CatchUp = catchUp
+	}
+	switch r.URL.Query().Get("hsa_coverage") {
+	case "", "self":
+	case "family":
+		opts.HSAFamily = true
+	default:
+		h.respondError(w, http.StatusBadRequest, "hsa_coverage must be 'self' or 'family'")
+		return
+	}
+
+	limits, err := h.contributions.Limits(ctx, year)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query contribution limits", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query contribution limits")
+		return
+	}
+
+	accounts, err := h.listAccounts(ctx, userID, true)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	filter := store.TransactionFilter{
+		UserID:    userID,
+		StartDate: fmt.Sprintf("%d-01-01", year),
+		EndDate:   fmt.Sprintf("%d-12-31", year),
+		Limit:     maxSummaryTransactions,
+	}
+	investments, err := h.store.Transactions.ListInvestment(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list investment transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query investment transactions")
+		return
+	}
+	transactions, err := h.listTransactions(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	report := contributions.Report(contributions.Detect(accounts, investments, transactions), limits, opts)
+	if wantSummary(r) {
+		for i := range report {
+			report[i].Contributions = nil
+		}
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"year":          year,
+		"catch_up":      opts.CatchUp,
+		"limits_set":    len(limits) > 0,
+		"contributions": report,
+		"truncated":     len(investments) == maxSummaryTransactions || len(transactions) == maxSummaryTransactions,
+	})
+}
+
+// AdminListContributionLimits returns the contribution limits of every
+// year
+func (h *Handlers) AdminListContributionLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.contributions.List(r.Context())
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query contribution limits")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"limits": limits,
+		"count":  len(limits),
+	})
+}
+
+// AdminSetContributionLimit sets a year's limit of one kind, such as once
+// the IRS announces the next year's
+//
+//	PUT /admin/contribution-limits/2027/401k
+//	{"limit": "25000", "catch_up": "8000", "catch_up_age": 50}
+func (h *Handlers) AdminSetContributionLimit(w http.ResponseWriter, r *http.Request) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil || year < 2000 || year > 2100 {
+		h.respondError(w, http.StatusBadRequest, "year must be from 2000 to 2100")
+		return
+	}
+
+	var req struct {
+		Limit      decimal.Decimal `json:"limit"`
+		CatchUp    decimal.Decimal `json:"catch_up"`
+		CatchUpAge int             `json:"catch_up_age"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if !req.Limit.IsPositive() {
+		h.respondError(w, http.StatusBadRequest, "limit must be positive")
+		return
+	}
+	if req.CatchUp.IsNegative() {
+		h.respondError(w, http.StatusBadRequest, "catch_up must not be negative")
+		return
+	}
+	if req.CatchUpAge <= 0 {
+		h.respondError(w, http.StatusBadRequest, "catch_up_age is required")
+		return
+	}
+
+	limit := models.ContributionLimit{
+		Year:       year,
+		Kind:       chi.URLParam(r, "kind"),
+		Limit:      req.Limit,
+		CatchUp:    req.CatchUp,
+		CatchUpAge: req.CatchUpAge,
+	}
+	err = h.contributions.Set(r.Context(), limit)
+	if errors.Is(err, contributions.ErrUnknownKind) {
+		h.respondError(w, http.StatusBadRequest, "kind must be '401k', 'ira', 'hsa_self' or 'hsa_family'")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to set contribution limit")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"limit": limit,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/corporateactions"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// AdminListCorporateActions lists recorded splits and symbol changes,
+// latest ex-date first
+func (h *Handlers) AdminListCorporateActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit, offset := parsePagination(r, 100, 1000)
+
+	actions, err := h.corporateActions.List(ctx, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query corporate actions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"actions": actions,
+		"count":   len(actions),
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// AdminRecordCorporateAction records a split or symbol change. One whose
+// ex-date has arrived is applied right away; later ones on their ex-date.
+func (h *Handlers) AdminRecordCorporateAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var action models.CorporateAction
+	if !h.decodeJSON(w, r, &action) {
+		return
+	}
+	action.Source = corporateactions.SourceManual
+	action.AppliedAt, action.Result = nil, nil
+	if err := corporateactions.Validate(&action); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := h.corporateActions.Record(ctx, &action)
+	if errors.Is(err, corporateactions.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "Corporate action already recorded")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record corporate action", "symbol", action.Symbol, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to record corporate action")
+		return
+	}
+
+	if _, err := h.corporateActions.ApplyDue(ctx, corporateactions.Today(time.Now())); err != nil {
+		slog.ErrorContext(ctx, "Failed to apply corporate actions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Corporate action recorded but failed to apply")
+		return
+	}
+	recorded, err := h.corporateActions.Get(ctx, action.ID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query corporate action")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"action": recorded},
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/costbasis"
+)
+
+// GetCostBasisReconciliation compares the cost basis institutions report
+// for a user's holdings with the basis of their lots, computed first in,
+// first out from the synced investment transactions, flagging differences
+// of more than threshold percent (1 by default). With summary=true the
+// lots are left out.
+func (h *Handlers) GetCostBasisReconciliation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	threshold := costbasis.DefaultThresholdPercent
+	if s := r.URL.Query().Get("threshold"); s != "" {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(t) || math.IsInf(t, 0) || t < 0 || t > 100 {
+			h.respondError(w, http.StatusBadRequest, "threshold must be a percent from 0 to 100")
+			return
+		}
+		threshold = t
+	}
+
+	report, err := h.costBasis.Reconcile(ctx, userID, threshold)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reconcile cost basis", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to reconcile cost basis")
+		return
+	}
+
+	if wantSummary(r) {
+		for i := range report.Holdings {
+			report.Holdings[i].Lots = nil
+		}
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"reconciliation": report,
+	})
+}
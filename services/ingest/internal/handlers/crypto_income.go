@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/cryptoincome"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/period"
+)
+
+// SyncCryptoIncome starts a job syncing the staking rewards and interest
+// paid to a user by the exchanges they trade on
+func (h *Handlers) SyncCryptoIncome(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	jobID, err := h.jobs.Enqueue(ctx, jobs.Params{
+		UserID: userID,
+		Type:   jobs.TypeCryptoIncomeSync,
+	})
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to create crypto income sync job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"job_id":  jobID,
+		"message": "Crypto income sync job started",
+	})
+}
+
+// cryptoIncomeSyncTask stores the crypto income paid to a user since their
+// last sync
+func (h *Handlers) cryptoIncomeSyncTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	if task.UserID == "" {
+		return nil, jobs.Permanent(fmt.Errorf("job is missing user reference"))
+	}
+
+	added, err := h.cryptoIncome.Sync(ctx, task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if added > 0 {
+		h.invalidateCache(ctx, task.UserID)
+	}
+	return map[string]interface{}{
+		"added": added,
+	}, nil
+}
+
+// GetCryptoIncome reports the staking rewards and interest a user received
+// in a tax year, by default this one, by kind, asset and month. Each
+// payment is valued when received: the total is ordinary income for the
+// year, and an asset's cost basis is the basis of the coins received.
+// With summary=true the payments are left out.
+func (h *Handlers) GetCryptoIncome(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	year := now.Year()
+	if s := r.URL.Query().Get("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 2000 || y > now.Year() {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("year must be from 2000 to %d", now.Year()))
+			return
+		}
+		year = y
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	payments, err := h.cryptoIncome.List(ctx, userID, start.Format(period.DateLayout), end.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list crypto income", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query crypto income")
+		return
+	}
+
+	report := cryptoincome.Report(payments, start, end)
+	if wantSummary(r) {
+		report.Payments = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"report": report,
+		"year":   year,
+	})
+}
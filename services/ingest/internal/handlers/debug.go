@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/finagent/ingest/internal/buildinfo"
+)
+
+// DebugInfo identifies the running build and configuration and reports
+// runtime and connection pool state, for debugging a production instance
+func (h *Handlers) DebugInfo(w http.ResponseWriter, r *http.Request) {
+	stat := h.db.Pool.Stat()
+	replicaAttached, replicaHealthy := h.db.ReplicaStatus()
+
+	h.respondSuccess(w, map[string]interface{}{
+		"build":           buildinfo.Get(),
+		"config_checksum": h.configChecksum,
+		"runtime": map[string]interface{}{
+			"goroutines": runtime.NumGoroutine(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"num_cpu":    runtime.NumCPU(),
+		},
+		"db_pool": map[string]interface{}{
+			"total_conns":            stat.TotalConns(),
+			"idle_conns":             stat.IdleConns(),
+			"acquired_conns":         stat.AcquiredConns(),
+			"constructing_conns":     stat.ConstructingConns(),
+			"max_conns":              stat.MaxConns(),
+			"acquire_count":          stat.AcquireCount(),
+			"empty_acquire_count":    stat.EmptyAcquireCount(),
+			"canceled_acquire_count": stat.CanceledAcquireCount(),
+			"acquire_wait_ms":        stat.AcquireDuration().Milliseconds(),
+			"new_conns_count":        stat.NewConnsCount(),
+		},
+		"db_replica": map[string]interface{}{
+			"attached": replicaAttached,
+			"healthy":  replicaHealthy,
+		},
+	})
+}
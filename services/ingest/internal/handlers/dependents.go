@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/dependents"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxLedgerDescriptionLength bounds allowance and ledger entry
+// descriptions, in characters
+const maxLedgerDescriptionLength = 200
+
+// dependentsFullAccess reports whether the caller is the parent acting for
+// themselves, who sees all of their dependents' data whatever its scopes.
+// Grantees and service principals see only what the scopes share.
+func dependentsFullAccess(ctx context.Context, userID string) bool {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	return !ok || (!principal.IsService() && principal.UserID == userID)
+}
+
+// userToday is today's date in a user's timezone, at midnight UTC
+func (h *Handlers) userToday(ctx context.Context, userID string) time.Time {
+	now, _ := h.userClock(ctx, userID)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// respondDependentError maps dependent service errors to responses
+func (h *Handlers) respondDependentError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	switch {
+	case errors.Is(err, dependents.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "Dependent not found")
+	default:
+		slog.ErrorContext(r.Context(), "Failed to "+action, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// ListDependents returns a user's dependents by name with their balances
+// and allowance schedules, as far as their scopes share them with the
+// caller. Allowances that have fallen due are credited first.
+func (h *Handlers) ListDependents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	if _, err := h.dependents.PostDue(ctx, userID, h.userToday(ctx, userID)); err != nil {
+		h.respondDependentError(w, r, err, "credit allowances")
+		return
+	}
+
+	list, err := h.dependents.List(ctx, userID)
+	if err != nil {
+		h.respondDependentError(w, r, err, "query dependents")
+		return
+	}
+	balances, err := h.dependents.Balances(ctx, userID)
+	if err != nil {
+		h.respondDependentError(w, r, err, "query dependents")
+		return
+	}
+
+	full := dependentsFullAccess(ctx, userID)
+	for i := range list {
+		d := &list[i]
+		if full || dependents.HasScope(*d, models.DependentScopeBalance) {
+			balance := balances[d.ID]
+			d.Balance = &balance
+		}
+		if full || dependents.HasScope(*d, models.DependentScopeAllowances) {
+			d.Schedules, err = h.dependents.Schedules(ctx, userID, d.ID)
+			if err != nil {
+				h.respondDependentError(w, r, err, "query dependents")
+				return
+			}
+		}
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"dependents": list,
+		"count":      len(list),
+	})
+}
+
+// dependentRequest holds the dependent fields a parent sets
+type dependentRequest struct {
+	UserID    string   `json:"user_id"`
+	Name      *string  `json:"name"`
+	BirthYear *int     `json:"birth_year"`
+	Scopes    []string `json:"scopes"`
+}
+
+// validate trims and checks the fields present, returning a message for
+// the first invalid one
+func (req *dependentRequest) validate() string {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len([]rune(name)) > maxAccountNameLength {
+			return fmt.Sprintf("name is required and must be at most %d characters", maxAccountNameLength)
+		}
+		req.Name = &name
+	}
+	if req.BirthYear != nil && *req.BirthYear != 0 && (*req.BirthYear < 1900 || *req.BirthYear > time.Now().Year()) {
+		return "birth_year must be a past year, or 0 to clear it"
+	}
+	for _, s := range req.Scopes {
+		if !dependents.IsScope(s) {
+			return "scopes may only include balance, ledger and allowances"
+		}
+	}
+	return ""
+}
+
+// CreateDependent adds a dependent. scopes are what grantees and agents
+// acting for the user see of them, by default only their balance:
+//
+//	{"name": "Sam", "birth_year": 2015, "scopes": ["balance", "ledger"]}
+func (h *Handlers) CreateDependent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req dependentRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Name == nil {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxAccountNameLength))
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		h.respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	d := &models.Dependent{UserID: userID, Name: *req.Name, Scopes: req.Scopes}
+	if req.BirthYear != nil && *req.BirthYear != 0 {
+		d.BirthYear = req.BirthYear
+	}
+	if d.Scopes == nil {
+		d.Scopes = []string{models.DependentScopeBalance}
+	}
+
+	err := h.dependents.Create(ctx, d)
+	if errors.Is(err, dependents.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may keep at most 20 dependents")
+		return
+	}
+	if err != nil {
+		h.respondDependentError(w, r, err, "create dependent")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"dependent": d,
+		},
+	})
+}
+
+// UpdateDependent renames a dependent or changes their birth year or
+// scopes; omitted fields are left as they are
+func (h *Handlers) UpdateDependent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+
+	var req dependentRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if msg := req.validate(); msg != "" {
+		h.respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	d, err := h.dependents.Update(ctx, userID, dependentID, dependents.DependentUpdate{
+		Name:      req.Name,
+		BirthYear: req.BirthYear,
+		Scopes:    req.Scopes,
+	})
+	if err != nil {
+		h.respondDependentError(w, r, err, "update dependent")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"dependent": d,
+	})
+}
+
+// DeleteDependent deletes a dependent with their allowances and ledger
+func (h *Handlers) DeleteDependent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := h.dependents.Delete(ctx, userID, dependentID); err != nil {
+		h.respondDependentError(w, r, err, "delete dependent")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      dependentID,
+	})
+}
+
+// CreateAllowanceSchedule pays a dependent an allowance weekly, biweekly
+// or monthly from start_date, by default today. A start date up to a year
+// back credits the allowances since.
+//
+//	{"amount": "10", "frequency": "weekly", "start_date": "2026-10-03", "description": "Pocket money"}
+func (h *Handlers) CreateAllowanceSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID      string          `json:"user_id"`
+		Amount      decimal.Decimal `json:"amount"`
+		Frequency   string          `json:"frequency"`
+		StartDate   string          `json:"start_date"`
+		Description *string         `json:"description"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if !req.Amount.IsPositive() {
+		h.respondError(w, http.StatusBadRequest, "amount must be positive")
+		return
+	}
+	if !dependents.IsFrequency(req.Frequency) {
+		h.respondError(w, http.StatusBadRequest, "frequency must be weekly, biweekly or monthly")
+		return
+	}
+	description := trimmedOrNil(req.Description)
+	if description != nil && len([]rune(*description)) > maxLedgerDescriptionLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("description must be at most %d characters", maxLedgerDescriptionLength))
+		return
+	}
+	today := h.userToday(ctx, userID)
+	start := today
+	if req.StartDate != "" {
+		var err error
+		start, err = time.Parse(period.DateLayout, req.StartDate)
+		if err != nil || start.Before(today.AddDate(-1, 0, 0)) {
+			h.respondError(w, http.StatusBadRequest, "start_date must be a YYYY-MM-DD date no more than a year ago")
+			return
+		}
+	}
+
+	schedule := &models.AllowanceSchedule{
+		DependentID: dependentID,
+		Amount:      req.Amount,
+		Frequency:   req.Frequency,
+		NextDate:    start.Format(period.DateLayout),
+		Description: description,
+	}
+	err := h.dependents.AddSchedule(ctx, userID, schedule)
+	if errors.Is(err, dependents.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A dependent may have at most 10 allowance schedules")
+		return
+	}
+	if err != nil {
+		h.respondDependentError(w, r, err, "create allowance schedule")
+		return
+	}
+
+	credited, err := h.dependents.PostDue(ctx, userID, today)
+	if err != nil {
+		h.respondDependentError(w, r, err, "credit allowances")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"schedule": schedule,
+			"credited": credited,
+		},
+	})
+}
+
+// DeleteAllowanceSchedule stops a dependent's allowance. What it already
+// credited stays in their ledger.
+func (h *Handlers) DeleteAllowanceSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+	scheduleID := chi.URLParam(r, "scheduleID")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.dependents.DeleteSchedule(ctx, userID, dependentID, scheduleID)
+	if errors.Is(err, dependents.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Allowance schedule not found")
+		return
+	}
+	if err != nil {
+		h.respondDependentError(w, r, err, "delete allowance schedule")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      scheduleID,
+	})
+}
+
+// AddDependentLedgerEntry records money a dependent earned for a chore,
+// money they spent or were paid out, or an adjustment, dated today unless
+// date is given. Chore and spend amounts are positive, spending being
+// taken off the balance; adjustments are signed.
+//
+//	{"kind": "chore", "amount": "5", "description": "Mowed the lawn"}
+func (h *Handlers) AddDependentLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID      string          `json:"user_id"`
+		Kind        string          `json:"kind"`
+		Amount      decimal.Decimal `json:"amount"`
+		Description *string         `json:"description"`
+		Date        string          `json:"date"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	entry := &models.DependentLedgerEntry{
+		DependentID: dependentID,
+		Kind:        req.Kind,
+		Amount:      req.Amount,
+		Description: trimmedOrNil(req.Description),
+	}
+	switch req.Kind {
+	case models.LedgerChore, models.LedgerSpend:
+		if !req.Amount.IsPositive() {
+			h.respondError(w, http.StatusBadRequest, "amount must be positive")
+			return
+		}
+		if req.Kind == models.LedgerSpend {
+			entry.Amount = req.Amount.Neg()
+		}
+	case models.LedgerAdjustment:
+		if req.Amount.IsZero() {
+			h.respondError(w, http.StatusBadRequest, "amount must not be zero")
+			return
+		}
+	default:
+		h.respondError(w, http.StatusBadRequest, "kind must be chore, spend or adjustment")
+		return
+	}
+	if entry.Description == nil && req.Kind == models.LedgerChore {
+		h.respondError(w, http.StatusBadRequest, "description is required for chores")
+		return
+	}
+	if entry.Description != nil && len([]rune(*entry.Description)) > maxLedgerDescriptionLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("description must be at most %d characters", maxLedgerDescriptionLength))
+		return
+	}
+	today := h.userToday(ctx, userID)
+	entry.Date = today.Format(period.DateLayout)
+	if req.Date != "" {
+		date, err := time.Parse(period.DateLayout, req.Date)
+		if err != nil || date.After(today) {
+			h.respondError(w, http.StatusBadRequest, "date must be a YYYY-MM-DD date no later than today")
+			return
+		}
+		entry.Date = req.Date
+	}
+
+	if err := h.dependents.AddEntry(ctx, userID, entry); err != nil {
+		h.respondDependentError(w, r, err, "add ledger entry")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"entry": entry,
+		},
+	})
+}
+
+// GetDependentLedger returns a dependent's ledger entries over a period
+// such as "last month", by default this month, with what was credited and
+// debited over it and, where shared, their balance. Grantees and agents
+// see it only when the dependent's scopes include the ledger.
+func (h *Handlers) GetDependentLedger(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dependentID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	d, err := h.dependents.Get(ctx, userID, dependentID)
+	if err != nil {
+		h.respondDependentError(w, r, err, "query dependent")
+		return
+	}
+	full := dependentsFullAccess(ctx, userID)
+	if !full && !dependents.HasScope(*d, models.DependentScopeLedger) {
+		h.respondError(w, http.StatusForbidden, "This dependent's ledger is not shared")
+		return
+	}
+
+	expr := r.URL.Query().Get("period")
+	if expr == "" {
+		expr = "this month"
+	}
+	now, cal := h.userCalendar(ctx, userID)
+	resolved, err := cal.Resolve(expr, now)
+	if errors.Is(err, period.ErrUnrecognized) {
+		h.respondError(w, http.StatusBadRequest, "Unrecognized period; try \"last month\", \"YTD\" or \"Q2 2024\"")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.dependents.PostDue(ctx, userID, h.userToday(ctx, userID)); err != nil {
+		h.respondDependentError(w, r, err, "credit allowances")
+		return
+	}
+	entries, err := h.dependents.Ledger(ctx, userID, dependentID,
+		resolved.Start.Format(period.DateLayout), resolved.End.Format(period.DateLayout))
+	if err != nil {
+		h.respondDependentError(w, r, err, "query ledger")
+		return
+	}
+
+	credited, debited := decimal.Zero, decimal.Zero
+	for _, e := range entries {
+		if e.Amount.IsPositive() {
+			credited = credited.Add(e.Amount)
+		} else {
+			debited = debited.Add(e.Amount.Neg())
+		}
+	}
+
+	data := map[string]interface{}{
+		"dependent_id": dependentID,
+		"name":         d.Name,
+		"entries":      entries,
+		"count":        len(entries),
+		"credited":     credited,
+		"debited":      debited,
+		"label":        resolved.Label,
+	}
+	if full || dependents.HasScope(*d, models.DependentScopeBalance) {
+		balances, err := h.dependents.Balances(ctx, userID)
+		if err != nil {
+			h.respondDependentError(w, r, err, "query ledger")
+			return
+		}
+		data["balance"] = balances[dependentID]
+	}
+	h.respondSuccess(w, data)
+}
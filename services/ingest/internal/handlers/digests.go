@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+// digestTopN is how many categories and positions a digest lists
+const digestTopN = 5
+
+// digestMaxInsights bounds the insights read for a digest
+const digestMaxInsights = 200
+
+// digestTask composes and emails one queued digest
+func (h *Handlers) digestTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	var input digest.Input
+	if err := task.DecodeInput(&input); err != nil {
+		return nil, err
+	}
+
+	delivery, err := h.digests.Delivery(ctx, input.DeliveryID)
+	if errors.Is(err, digest.ErrNotFound) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != digest.StatusPending {
+		return map[string]interface{}{"delivery_id": delivery.ID, "status": delivery.Status}, nil
+	}
+
+	user, err := h.store.Users.Get(ctx, delivery.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if user.Email == nil || *user.Email == "" {
+		err := errors.New("user has no email address")
+		h.digests.Fail(ctx, delivery.ID, err)
+		return nil, jobs.Permanent(err)
+	}
+
+	prefs, err := h.store.Preferences.Get(ctx, delivery.UserID)
+	if err != nil {
+		return nil, err
+	}
+	start, _ := time.Parse(period.DateLayout, delivery.PeriodStart)
+	end, _ := time.Parse(period.DateLayout, delivery.PeriodEnd)
+
+	d, err := h.composeDigest(ctx, prefs, delivery.Frequency, period.Range{Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+	if err := h.digests.Send(ctx, delivery.ID, *user.Email, d); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"delivery_id": delivery.ID, "status": digest.StatusSent}, nil
+}
+
+// composeDigest gathers a user's digest for a period: spending against the
+// period before, monthly bills due over the next period, portfolio value
+// against the last digest sent, and insights raised during the period
+func (h *Handlers) composeDigest(ctx context.Context, prefs models.UserPreferences, frequency string, rng period.Range) (*models.Digest, error) {
+	userID := prefs.UserID
+	startDate := rng.Start.Format(period.DateLayout)
+	endDate := rng.End.Format(period.DateLayout)
+
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	today := time.Now().In(loc)
+
+	d := &models.Digest{
+		UserID:        userID,
+		Frequency:     frequency,
+		Period:        summaryPeriod(startDate, endDate),
+		Currency:      prefs.BaseCurrency,
+		NumberFormat:  prefs.NumberFormat,
+		UpcomingBills: []models.UpcomingBill{},
+		Insights:      []models.Insight{},
+	}
+	previous := rng.Start.AddDate(0, 0, -7)
+	if frequency == models.DigestMonthly {
+		d.Label = rng.Start.Format("January 2006")
+		previous = rng.Start.AddDate(0, -1, 0)
+	} else {
+		d.Label = "the week of " + rng.Start.Format("January 2")
+	}
+
+	// Spending, and the period before for comparison
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Spending = summarizeSpending(transactions, startDate, endDate, digestTopN)
+
+	previousStart := previous.Format(period.DateLayout)
+	previousEnd := rng.Start.AddDate(0, 0, -1).Format(period.DateLayout)
+	before, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: previousStart,
+		EndDate:   previousEnd,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(before) > 0 {
+		spent := summarizeSpending(before, previousStart, previousEnd, 0).TotalSpent
+		d.PreviousSpent = &spent
+	}
+
+	// Monthly charges due before the next digest
+	history, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: today.AddDate(0, 0, -digest.BillHistoryDays).Format(period.DateLayout),
+		EndDate:   today.Format(period.DateLayout),
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.UpcomingBills = digest.UpcomingBills(history, today, rng.Days())
+
+	// Portfolio value now against the last digest's
+	holdings, err := h.store.Holdings.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	positions, err := h.store.Orders.ListPositions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query crypto positions: %w", err)
+	}
+	combined := append(summarizeHoldings(holdings, len(holdings)).TopPositions,
+		summarizeCryptoPositions(positions, len(positions)).TopPositions...)
+	portfolio := summarizePositions(combined, digestTopN)
+	d.Portfolio = models.PortfolioChange{
+		Value:        portfolio.TotalValue,
+		TopPositions: portfolio.TopPositions,
+	}
+
+	previousValue, err := h.digests.PreviousPortfolioValue(ctx, userID, frequency, startDate)
+	if err != nil {
+		return nil, err
+	}
+	if previousValue != nil {
+		change := d.Portfolio.Value.Sub(*previousValue)
+		d.Portfolio.PreviousValue = previousValue
+		d.Portfolio.Change = &change
+		if previousValue.IsPositive() {
+			pct, _ := change.Div(*previousValue).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+			d.Portfolio.ChangePercent = &pct
+		}
+	}
+
+	activity, err := h.store.Transactions.ListInvestment(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query investment transactions: %w", err)
+	}
+	d.Portfolio.Activity = summarizeInvestmentActivity(activity, startDate, endDate)
+
+	// Insights raised during the period, in the user's days
+	if h.insights != nil {
+		insights, err := h.insights.List(ctx, userID, false, digestMaxInsights, 0)
+		if err != nil {
+			return nil, err
+		}
+		from := time.Date(rng.Start.Year(), rng.Start.Month(), rng.Start.Day(), 0, 0, 0, 0, loc)
+		until := time.Date(rng.End.Year(), rng.End.Month(), rng.End.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		for _, insight := range insights {
+			if !insight.CreatedAt.Before(from) && insight.CreatedAt.Before(until) {
+				d.Insights = append(d.Insights, insight)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// PreviewDigest composes the digest a user would receive now without
+// sending it, for the frequency given or their preferred one, with the
+// email's subject and plain text body
+func (h *Handlers) PreviewDigest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.store.Preferences.Get(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query preferences", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query preferences")
+		return
+	}
+
+	frequency := strings.ToLower(r.URL.Query().Get("frequency"))
+	if frequency == "" {
+		frequency = prefs.DigestFrequency
+	}
+	if frequency == models.DigestOff {
+		frequency = models.DigestWeekly
+	}
+
+	now, weekStart := h.userClock(ctx, userID)
+	rng, err := digest.Range(frequency, now, weekStart)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "frequency must be 'weekly' or 'monthly'")
+		return
+	}
+
+	d, err := h.composeDigest(ctx, prefs, frequency, rng)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to compose digest", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to compose digest")
+		return
+	}
+	msg, err := digest.Render(d)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to render digest", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to render digest")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"digest":  d,
+		"subject": msg.Subject,
+		"text":    msg.Text,
+	})
+}
+
+// ListDigestDeliveries returns the digests queued and sent to a user,
+// newest first
+func (h *Handlers) ListDigestDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(r, 20, 100)
+	deliveries, err := h.digests.ListDeliveries(ctx, userID, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list digest deliveries", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query digest deliveries")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+		"enabled":    h.digests.Enabled(),
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/dedup"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListDuplicates lists a user's duplicate links, optionally filtered by
+// record_type and status
+func (h *Handlers) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	recordType := r.URL.Query().Get("record_type")
+	status := r.URL.Query().Get("status")
+	limit, offset := parsePagination(r, 50, 500)
+
+	links, err := h.dedup.List(ctx, userID, recordType, status, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch duplicates")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"duplicates": links,
+		"count":      len(links),
+	})
+}
+
+// ScanDuplicates looks for duplicates in a user's data now rather than
+// after the next sync
+func (h *Handlers) ScanDuplicates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	result, err := h.dedup.Scan(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to scan for duplicates")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"linked": result,
+	})
+}
+
+// LinkDuplicate marks a record as a duplicate of another that detection
+// did not catch
+func (h *Handlers) LinkDuplicate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID      string `json:"user_id"`
+		RecordType  string `json:"record_type"`
+		CanonicalID string `json:"canonical_id"`
+		DuplicateID string `json:"duplicate_id"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	link, err := h.dedup.Link(ctx, userID, req.RecordType, req.CanonicalID, req.DuplicateID)
+	if err != nil {
+		if errors.Is(err, dedup.ErrInvalidLink) {
+			h.respondError(w, http.StatusBadRequest, "record_type must be 'account' or 'transaction' and both records must be the user's")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to link duplicates")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, link)
+}
+
+// UpdateDuplicate confirms a detected duplicate or rejects it, counting
+// both records again
+func (h *Handlers) UpdateDuplicate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	linkID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Status != dedup.StatusConfirmed && req.Status != dedup.StatusRejected {
+		h.respondError(w, http.StatusBadRequest, "status must be 'confirmed' or 'rejected'")
+		return
+	}
+
+	if err := h.dedup.SetStatus(ctx, userID, linkID, req.Status); err != nil {
+		if errors.Is(err, dedup.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Duplicate link not found")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to update duplicate link")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"id":     linkID,
+		"status": req.Status,
+	})
+}
+
+// ListRefunds lists the refunds paired with the charges they reverse,
+// optionally filtered by status. Paired transactions are left out of
+// spending summaries and other analytics.
+func (h *Handlers) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	limit, offset := parsePagination(r, 50, 500)
+
+	links, err := h.dedup.ListRefunds(ctx, userID, status, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch refunds")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"refunds": links,
+		"count":   len(links),
+	})
+}
+
+// UpdateRefund confirms a detected refund pairing or rejects it, counting
+// both transactions in spending again
+func (h *Handlers) UpdateRefund(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	linkID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Status != dedup.StatusConfirmed && req.Status != dedup.StatusRejected {
+		h.respondError(w, http.StatusBadRequest, "status must be 'confirmed' or 'rejected'")
+		return
+	}
+
+	if err := h.dedup.SetRefundStatus(ctx, userID, linkID, req.Status); err != nil {
+		if errors.Is(err, dedup.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Refund link not found")
+			return
+		}
+		if errors.Is(err, dedup.ErrRefundPaired) {
+			h.respondError(w, http.StatusConflict, "The charge or refund is already paired with another transaction")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to update refund link")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"id":     linkID,
+		"status": req.Status,
+	})
+}
+
+// scanDuplicates links duplicates after a sync, logging rather than
+// failing the sync on errors
+func (h *Handlers) scanDuplicates(ctx context.Context, userID string) {
+	if h.dedup == nil {
+		return
+	}
+	if _, err := h.dedup.Scan(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "Failed to scan for duplicates", "user_id", userID, "error", err)
+	}
+}
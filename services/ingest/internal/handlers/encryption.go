@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/jobs"
+)
+
+// reencryptBatchSize is the number of rows re-encrypted per query
+const reencryptBatchSize = 500
+
+// AdminRotateEncryptionKey switches to a new master key and starts a job
+// re-encrypting existing ciphertexts under it
+func (h *Handlers) AdminRotateEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		KeyID string `json:"key_id"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.KeyID == "" {
+		h.respondError(w, http.StatusBadRequest, "key_id is required")
+		return
+	}
+
+	previous := h.encryption.ActiveKeyID()
+	if err := h.encryption.RotateKey(ctx, req.KeyID); err != nil {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to rotate key: %v", err))
+		return
+	}
+
+	jobID, err := h.jobs.Enqueue(ctx, jobs.Params{Type: jobs.TypeReencrypt})
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Key rotated but failed to start re-encryption job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"previous_key_id": previous,
+		"active_key_id":   req.KeyID,
+		"job_id":          jobID,
+	})
+}
+
+// AdminReencrypt starts a job re-encrypting ciphertexts not yet under the
+// active master key, e.g. rows written by instances that rotated late
+func (h *Handlers) AdminReencrypt(w http.ResponseWriter, r *http.Request) {
+	jobID, err := h.jobs.Enqueue(r.Context(), jobs.Params{Type: jobs.TypeReencrypt})
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to start re-encryption job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"active_key_id": h.encryption.ActiveKeyID(),
+		"job_id":        jobID,
+	})
+}
+
+// reencryptJob re-encrypts every encrypted column under the active master key
+func (h *Handlers) reencryptJob(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	items, err := h.reencryptPlaidItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress.Update(ctx, 50, items)
+
+	accounts, err := h.reencryptAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress.Update(ctx, 100, items+accounts)
+
+	return map[string]interface{}{
+		"key_id":                  h.encryption.ActiveKeyID(),
+		"plaid_items_reencrypted": items,
+		"accounts_reencrypted":    accounts,
+	}, nil
+}
+
+func (h *Handlers) reencryptPlaidItems(ctx context.Context) (int, error) {
+	updated := 0
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for {
+		batch, err := h.store.Items.ListTokens(ctx, lastID, reencryptBatchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		for _, it := range batch {
+			token, changed, err := h.encryption.Reencrypt(ctx, it.Token)
+			if err != nil {
+				return updated, fmt.Errorf("failed to re-encrypt plaid item %s: %w", it.ID, err)
+			}
+			if changed {
+				it.Token = token
+				if err := h.store.Items.UpdateToken(ctx, it); err != nil {
+					return updated, err
+				}
+				updated++
+			}
+			lastID = it.ID
+		}
+	}
+}
+
+func (h *Handlers) reencryptAccounts(ctx context.Context) (int, error) {
+	updated := 0
+	lastID := ""
+	for {
+		batch, err := h.store.Accounts.ListEncrypted(ctx, lastID, reencryptBatchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		for _, a := range batch {
+			maskChanged, err := h.reencryptField(ctx, &a.Mask)
+			if err != nil {
+				return updated, fmt.Errorf("failed to re-encrypt account %s: %w", a.ID, err)
+			}
+			nameChanged, err := h.reencryptField(ctx, &a.OfficialName)
+			if err != nil {
+				return updated, fmt.Errorf("failed to re-encrypt account %s: %w", a.ID, err)
+			}
+
+			if maskChanged || nameChanged {
+				if err := h.store.Accounts.UpdateEncrypted(ctx, a); err != nil {
+					return updated, err
+				}
+				updated++
+			}
+			lastID = a.ID
+		}
+	}
+}
+
+// reencryptField re-encrypts a nullable text column value in place
+func (h *Handlers) reencryptField(ctx context.Context, field **string) (bool, error) {
+	if *field == nil {
+		return false, nil
+	}
+	value, changed, err := h.encryption.ReencryptString(ctx, **field)
+	if err != nil {
+		return false, err
+	}
+	*field = &value
+	return changed, nil
+}
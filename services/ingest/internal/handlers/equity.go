@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/equity"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Bounds of how far ahead vests are listed and net worth projected
+const (
+	defaultVestDays         = 365
+	maxVestDays             = 3650
+	defaultProjectionMonths = 12
+	maxProjectionMonths     = 60
+)
+
+// ListEquityGrants returns a user's RSU and option grants with their
+// vests and the shares vested so far
+func (h *Handlers) ListEquityGrants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	grants, err := h.equity.List(ctx, userID, now.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list equity grants", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query equity grants")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"grants": grants,
+		"count":  len(grants),
+	})
+}
+
+// CreateEquityGrant records an RSU or option grant. Its vests are listed,
+// or generated from a schedule of equal vests after a cliff:
+//
+//	{"name": "2026 refresh", "symbol": "ACME", "grant_type": "rsu",
+//	 "total_shares": "400", "grant_date": "2026-03-01", "account_id": "...",
+//	 "schedule": {"start_date": "2026-03-01", "total_months": 48,
+//	              "cliff_months": 12, "frequency_months": 3}}
+//
+// Vests already past are matched to the brokerage transactions
+// delivering their shares.
+func (h *Handlers) CreateEquityGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID      string           `json:"user_id"`
+		Name        string           `json:"name"`
+		Symbol      string           `json:"symbol"`
+		GrantType   string           `json:"grant_type"`
+		StrikePrice *decimal.Decimal `json:"strike_price"`
+		TotalShares decimal.Decimal  `json:"total_shares"`
+		GrantDate   string           `json:"grant_date"`
+		AccountID   *string          `json:"account_id"`
+		Schedule    *equity.Schedule `json:"schedule"`
+		Vests       []struct {
+			Date   string          `json:"date"`
+			Shares decimal.Decimal `json:"shares"`
+		} `json:"vests"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	grant := &models.EquityGrant{
+		UserID:      userID,
+		Name:        req.Name,
+		Symbol:      req.Symbol,
+		GrantType:   req.GrantType,
+		StrikePrice: req.StrikePrice,
+		TotalShares: req.TotalShares,
+		GrantDate:   req.GrantDate,
+		AccountID:   trimmedOrNil(req.AccountID),
+	}
+	switch {
+	case req.Schedule != nil && len(req.Vests) > 0:
+		h.respondError(w, http.StatusBadRequest, "Give either a schedule or vests, not both")
+		return
+	case req.Schedule != nil:
+		vests, err := req.Schedule.Vests(req.TotalShares)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		grant.Vests = vests
+	default:
+		for _, v := range req.Vests {
+			grant.Vests = append(grant.Vests, models.EquityVest{Date: v.Date, Shares: v.Shares})
+		}
+	}
+	if err := equity.Validate(grant); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if grant.AccountID != nil {
+		account, err := h.store.Accounts.Get(ctx, *grant.AccountID, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusBadRequest, "Account not found")
+			return
+		}
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+			return
+		}
+		if account.Type != "investment" {
+			h.respondError(w, http.StatusBadRequest, "Shares must be delivered to an investment account")
+			return
+		}
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	today := now.Format(period.DateLayout)
+	err := h.equity.Create(ctx, grant, today)
+	if errors.Is(err, equity.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may keep at most 50 equity grants")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create equity grant", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create equity grant")
+		return
+	}
+	h.matchVests(ctx, userID)
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"grant": grant,
+		},
+	})
+}
+
+// DeleteEquityGrant deletes a grant and its vests
+func (h *Handlers) DeleteEquityGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	grantID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.equity.Delete(ctx, userID, grantID)
+	if errors.Is(err, equity.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Equity grant not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete equity grant", "grant_id", grantID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete equity grant")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      grantID,
+	})
+}
+
+// GetUpcomingVests returns the vests due in the next days, by default a
+// year, valued at today's prices
+func (h *Handlers) GetUpcomingVests(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	days := defaultVestDays
+	if s := r.URL.Query().Get("days"); s != "" {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 1 || d > maxVestDays {
+			h.respondError(w, http.StatusBadRequest, "days must be from 1 to 3650")
+			return
+		}
+		days = d
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	end := now.AddDate(0, 0, days)
+	vests, err := h.equity.Upcoming(ctx, userID, now.Format(period.DateLayout), end.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list upcoming vests", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query upcoming vests")
+		return
+	}
+
+	total := decimal.Zero
+	for _, v := range vests {
+		if v.Value != nil {
+			total = total.Add(*v.Value)
+		}
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"vests":       vests,
+		"count":       len(vests),
+		"total_value": total,
+		"period":      summaryPeriod(now.Format(period.DateLayout), end.Format(period.DateLayout)),
+	})
+}
+
+// GetNetWorthProjection returns the user's net worth today and at the end
+// of each of the coming months, by default 12, as their equity vests.
+// Vests are valued at today's prices; no other income or spending is
+// projected.
+func (h *Handlers) GetNetWorthProjection(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	months := defaultProjectionMonths
+	if s := r.URL.Query().Get("months"); s != "" {
+		m, err := strconv.Atoi(s)
+		if err != nil || m < 1 || m > maxProjectionMonths {
+			h.respondError(w, http.StatusBadRequest, "months must be from 1 to 60")
+			return
+		}
+		months = m
+	}
+
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+	positions, err := h.store.Orders.ListPositions(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list crypto positions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query crypto positions")
+		return
+	}
+	netWorth := summarizeAccounts(accounts).Net.Add(summarizeCryptoPositions(positions, 0).TotalValue)
+
+	now, _ := h.userClock(ctx, userID)
+	end := time.Date(now.Year(), now.Month()+time.Month(months)+1, 0, 0, 0, 0, 0, time.UTC)
+	vests, err := h.equity.Upcoming(ctx, userID, now.Format(period.DateLayout), end.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list upcoming vests", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query upcoming vests")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"projection": equity.Project(netWorth, vests, now, months),
+	})
+}
+
+// matchVests links vests to the brokerage transactions delivering their
+// shares, logging rather than failing the caller on errors
+func (h *Handlers) matchVests(ctx context.Context, userID string) {
+	if h.equity == nil {
+		return
+	}
+	now, _ := h.userClock(ctx, userID)
+	if _, err := h.equity.Match(ctx, userID, now.Format(period.DateLayout)); err != nil {
+		slog.ErrorContext(ctx, "Failed to match equity vests", "user_id", userID, "error", err)
+	}
+}
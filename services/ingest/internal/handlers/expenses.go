@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/expenses"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxExpenseTypeTransactions bounds the transactions marked in one request
+const maxExpenseTypeTransactions = 1000
+
+// GetExpenseReport totals a user's business or personal expenses over a
+// quarter, by category and, for business expenses, by Schedule C line,
+// with the transactions counted. type is business (the default) or
+// personal; quarter is such as "Q2 2024" and defaults to this quarter.
+// With summary=true the transactions are left out.
+func (h *Handlers) GetExpenseReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	expenseType := r.URL.Query().Get("type")
+	if expenseType == "" {
+		expenseType = expenses.TypeBusiness
+	}
+	if !expenses.IsType(expenseType) {
+		h.respondError(w, http.StatusBadRequest, "type must be 'business' or 'personal'")
+		return
+	}
+
+	quarter := r.URL.Query().Get("quarter")
+	if quarter == "" {
+		quarter = "this quarter"
+	}
+	now, cal := h.userCalendar(ctx, userID)
+	resolved, err := cal.Resolve(quarter, now)
+	if errors.Is(err, period.ErrUnrecognized) {
+		h.respondError(w, http.StatusBadRequest, "Unrecognized quarter; try \"Q2 2024\" or \"last quarter\"")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:      userID,
+		StartDate:   resolved.Start.Format(period.DateLayout),
+		EndDate:     resolved.End.Format(period.DateLayout),
+		ExpenseType: expenseType,
+		Limit:       maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	report := expenses.Report(transactions, expenseType, resolved.Start, resolved.End)
+	if wantSummary(r) {
+		report.Transactions = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"report":    report,
+		"label":     resolved.Label,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
+
+// SetExpenseType marks transactions as business or personal expenses by
+// hand, overriding rules. An empty expense_type clears the mark so rules
+// apply again:
+//
+//	{"transaction_ids": ["..."], "expense_type": "business"}
+func (h *Handlers) SetExpenseType(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID         string   `json:"user_id"`
+		TransactionIDs []string `json:"transaction_ids"`
+		ExpenseType    string   `json:"expense_type"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if len(req.TransactionIDs) == 0 || len(req.TransactionIDs) > maxExpenseTypeTransactions {
+		h.respondError(w, http.StatusBadRequest, "transaction_ids must list 1 to 1000 transactions")
+		return
+	}
+	if req.ExpenseType != "" && !expenses.IsType(req.ExpenseType) {
+		h.respondError(w, http.StatusBadRequest, "expense_type must be 'business', 'personal' or empty")
+		return
+	}
+
+	marked, err := h.expenses.SetType(ctx, userID, req.TransactionIDs, req.ExpenseType)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to mark expense type", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to mark transactions")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"expense_type": req.ExpenseType,
+		"updated":      marked,
+	})
+}
+
+// ListExpenseRules returns a user's expense rules, newest first
+func (h *Handlers) ListExpenseRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	rules, err := h.expenses.ListRules(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list expense rules", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query expense rules")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// CreateExpenseRule adds a rule marking the transactions that match all
+// its criteria, now and as they sync, unless marked by hand. The newest
+// matching rule wins:
+//
+//	{"expense_type": "business", "merchant": "adobe"}
+func (h *Handlers) CreateExpenseRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID      string  `json:"user_id"`
+		ExpenseType string  `json:"expense_type"`
+		Merchant    *string `json:"merchant"`
+		Category    *string `json:"category"`
+		AccountID   *string `json:"account_id"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	rule := &models.ExpenseRule{
+		UserID:      userID,
+		ExpenseType: req.ExpenseType,
+		Merchant:    trimmedOrNil(req.Merchant),
+		Category:    trimmedOrNil(req.Category),
+		AccountID:   trimmedOrNil(req.AccountID),
+	}
+	marked, err := h.expenses.CreateRule(ctx, rule)
+	if errors.Is(err, expenses.ErrInvalidRule) || errors.Is(err, expenses.ErrUnknownAccount) {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create expense rule", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create expense rule")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"rule":    rule,
+			"applied": marked,
+		},
+	})
+}
+
+// DeleteExpenseRule deletes an expense rule, leaving the transactions it
+// marked to the remaining rules
+func (h *Handlers) DeleteExpenseRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ruleID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.expenses.DeleteRule(ctx, userID, ruleID)
+	if errors.Is(err, expenses.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Expense rule not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete expense rule", "rule_id", ruleID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete expense rule")
+		return
+	}
+	h.invalidateCache(ctx, userID)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      ruleID,
+	})
+}
+
+// applyExpenseRules marks newly synced transactions, logging rather than
+// failing the sync on errors
+func (h *Handlers) applyExpenseRules(ctx context.Context, userID string) {
+	if _, err := h.expenses.ApplyRules(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "Failed to apply expense rules", "user_id", userID, "error", err)
+	}
+}
+
+// trimmedOrNil returns s without surrounding space, or nil when nothing is
+// left
+func trimmedOrNil(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/exports"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListExportDestinations returns the buckets a user's data is exported
+// to daily, without their access keys
+func (h *Handlers) ListExportDestinations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	destinations, err := h.exports.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list export destinations", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query export destinations")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"destinations": destinations,
+		"count":        len(destinations),
+	})
+}
+
+// CreateExportDestination adds a bucket of the user's own that their
+// transactions and balances are written to once a day, with keys allowed
+// to put objects in it. GCS buckets take HMAC keys.
+//
+//	{"provider": "s3", "bucket": "my-finances", "region": "us-west-2",
+//	 "prefix": "finagent", "format": "parquet",
+//	 "access_key_id": "...", "secret_access_key": "..."}
+func (h *Handlers) CreateExportDestination(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID          string  `json:"user_id"`
+		Provider        string  `json:"provider"`
+		Bucket          string  `json:"bucket"`
+		Region          *string `json:"region"`
+		Prefix          string  `json:"prefix"`
+		Format          string  `json:"format"`
+		AccessKeyID     string  `json:"access_key_id"`
+		SecretAccessKey string  `json:"secret_access_key"`
+		KMSKeyID        *string `json:"kms_key_id"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	destination := &models.ExportDestination{
+		UserID:   userID,
+		Provider: req.Provider,
+		Bucket:   req.Bucket,
+		Region:   trimmedOrNil(req.Region),
+		Prefix:   req.Prefix,
+		Format:   req.Format,
+		KMSKeyID: trimmedOrNil(req.KMSKeyID),
+	}
+	if err := exports.Validate(destination); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	accessKeyID, secret := strings.TrimSpace(req.AccessKeyID), strings.TrimSpace(req.SecretAccessKey)
+	if accessKeyID == "" || secret == "" {
+		h.respondError(w, http.StatusBadRequest, "access_key_id and secret_access_key are required")
+		return
+	}
+
+	// Data leaves the service from here on, so a locked account may not
+	// add destinations, as it may not export
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check account lock")
+		return
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	err = h.exports.Create(ctx, destination, accessKeyID, secret)
+	if errors.Is(err, exports.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may keep at most 5 export destinations")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create export destination", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create export destination")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionExportDestination,
+		TargetUserID: userID,
+		Metadata: map[string]interface{}{
+			"destination_id": destination.ID,
+			"provider":       destination.Provider,
+			"bucket":         destination.Bucket,
+		},
+	})
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"destination": destination,
+		},
+	})
+}
+
+// DeleteExportDestination stops exports to a bucket. Files already
+// exported stay there.
+func (h *Handlers) DeleteExportDestination(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	destinationID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.exports.Delete(ctx, userID, destinationID)
+	if errors.Is(err, exports.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Export destination not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete export destination", "destination_id", destinationID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete export destination")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      destinationID,
+	})
+}
+
+// RunExportDestination exports to a bucket now, such as to check its
+// keys, returning the files written
+func (h *Handlers) RunExportDestination(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	destinationID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check account lock")
+		return
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	keys, err := h.exports.ExportNow(ctx, userID, destinationID)
+	if errors.Is(err, exports.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Export destination not found")
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Data export failed", "destination_id", destinationID, "error", err)
+		h.respondError(w, http.StatusBadGateway, "Export failed: "+err.Error())
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"files": keys,
+	})
+}
+
+// DownloadDataset returns a user's transactions or balances as a file.
+// format=parquet gives typed columns, decimal amounts and dates, for
+// loading into DuckDB or pandas; the default is csv.
+//
+//	GET /exports/transactions?user_id=...&format=parquet
+func (h *Handlers) DownloadDataset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dataset := chi.URLParam(r, "dataset")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exports.FormatCSV
+	}
+	if format != exports.FormatCSV && format != exports.FormatParquet {
+		h.respondError(w, http.StatusBadRequest, "format must be 'csv' or 'parquet'")
+		return
+	}
+
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check account lock")
+		return
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	body, contentType, err := h.exports.Dataset(ctx, userID, dataset, format)
+	if errors.Is(err, exports.ErrUnknownDataset) {
+		h.respondError(w, http.StatusNotFound, "Dataset must be 'transactions' or 'balances'")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to export dataset", "dataset", dataset, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to export "+dataset)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", dataset, format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// DownloadAccountStatement returns an account's posted transactions as a
+// QIF or OFX file, for importing into Quicken or GnuCash. The range is a
+// period such as "last year", or start to end, by default the past year.
+//
+//	GET /exports/accounts/{id}?user_id=...&format=ofx&period=YTD
+func (h *Handlers) DownloadAccountStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != exports.FormatQIF && format != exports.FormatOFX {
+		h.respondError(w, http.StatusBadRequest, "format must be 'qif' or 'ofx'")
+		return
+	}
+
+	now, cal := h.userCalendar(ctx, userID)
+	start, end := now.AddDate(0, 0, -364), now
+	if expr := r.URL.Query().Get("period"); expr != "" {
+		resolved, err := cal.Resolve(expr, now)
+		if errors.Is(err, period.ErrUnrecognized) {
+			h.respondError(w, http.StatusBadRequest, "Unrecognized period; try \"last year\", \"YTD\" or \"past 90 days\"")
+			return
+		}
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		start, end = resolved.Start, resolved.End
+	} else {
+		var err error
+		if s := r.URL.Query().Get("start"); s != "" {
+			if start, err = time.Parse(period.DateLayout, s); err != nil {
+				h.respondError(w, http.StatusBadRequest, "start must be YYYY-MM-DD")
+				return
+			}
+		}
+		if s := r.URL.Query().Get("end"); s != "" {
+			if end, err = time.Parse(period.DateLayout, s); err != nil {
+				h.respondError(w, http.StatusBadRequest, "end must be YYYY-MM-DD")
+				return
+			}
+		}
+	}
+	startDate, endDate := start.Format(period.DateLayout), end.Format(period.DateLayout)
+	if startDate > endDate {
+		h.respondError(w, http.StatusBadRequest, "start must not be after end")
+		return
+	}
+
+	account, err := h.store.Accounts.Get(ctx, chi.URLParam(r, "id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query account", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+		return
+	}
+
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check account lock")
+		return
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		AccountID: account.ID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	statement := &exports.Statement{
+		Account:      account,
+		Transactions: transactions,
+		Start:        start,
+		End:          end,
+	}
+	var body []byte
+	contentType := "application/qif"
+	if format == exports.FormatOFX {
+		body = exports.WriteOFX(statement, now)
+		contentType = "application/x-ofx"
+	} else {
+		body = exports.WriteQIF(statement)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"%s_%s_%s.%s\"", account.ID, startDate, endDate, format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
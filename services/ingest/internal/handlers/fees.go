@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/fees"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+)
+
+// GetFees reports the bank fees, ATM fees and interest a user was charged,
+// by kind and annualized, with the transactions found. The range is a
+// natural expression in period, such as "last year", or start and end;
+// by default the past 365 days. With summary=true the transactions are
+// left out.
+func (h *Handlers) GetFees(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	now, cal := h.userCalendar(ctx, userID)
+	start, end := now.AddDate(0, 0, -364), now
+	if expr := r.URL.Query().Get("period"); expr != "" {
+		resolved, err := cal.Resolve(expr, now)
+		if errors.Is(err, period.ErrUnrecognized) {
+			h.respondError(w, http.StatusBadRequest, "Unrecognized period; try \"last year\", \"YTD\" or \"past 90 days\"")
+			return
+		}
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		start, end = resolved.Start, resolved.End
+	} else {
+		var err error
+		if s := r.URL.Query().Get("start"); s != "" {
+			if start, err = time.Parse(period.DateLayout, s); err != nil {
+				h.respondError(w, http.StatusBadRequest, "start must be YYYY-MM-DD")
+				return
+			}
+		}
+		if s := r.URL.Query().Get("end"); s != "" {
+			if end, err = time.Parse(period.DateLayout, s); err != nil {
+				h.respondError(w, http.StatusBadRequest, "end must be YYYY-MM-DD")
+				return
+			}
+		}
+	}
+	startDate, endDate := start.Format(period.DateLayout), end.Format(period.DateLayout)
+	if startDate > endDate {
+		h.respondError(w, http.StatusBadRequest, "start must not be after end")
+		return
+	}
+
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	from, _ := time.Parse(period.DateLayout, startDate)
+	to, _ := time.Parse(period.DateLayout, endDate)
+	report := fees.Report(transactions, from, to)
+	if wantSummary(r) {
+		report.Transactions = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"fees":      report,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maxNearRadiusKm bounds the radius of a near filter
+	maxNearRadiusKm = 500
+
+	// defaultGeoPrecision clusters transactions to two decimal places of
+	// latitude and longitude, cells about 1 km across
+	defaultGeoPrecision = 2
+
+	// geoClusterMerchants is how many merchants a cluster lists
+	geoClusterMerchants = 3
+)
+
+// parseNear reads a near=lat,lon,radius filter, the radius in kilometres
+func parseNear(near string) (*store.GeoArea, error) {
+	parts := strings.Split(near, ",")
+	if len(parts) != 3 {
+		return nil, errors.New("near must be lat,lon,radius_km")
+	}
+	var values [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("near must be lat,lon,radius_km")
+		}
+		values[i] = v
+	}
+	area := &store.GeoArea{Lat: values[0], Lon: values[1], RadiusKm: values[2]}
+	switch {
+	case area.Lat < -90 || area.Lat > 90:
+		return nil, errors.New("near latitude must be from -90 to 90")
+	case area.Lon < -180 || area.Lon > 180:
+		return nil, errors.New("near longitude must be from -180 to 180")
+	case area.RadiusKm <= 0 || area.RadiusKm > maxNearRadiusKm:
+		return nil, fmt.Errorf("near radius must be more than 0 and at most %d km", maxNearRadiusKm)
+	}
+	return area, nil
+}
+
+// GetTransactionsGeo returns where a user spent from start to end, by
+// default the last 30 days, as clusters of located transactions for a
+// map. precision sets the cluster size in decimal places of latitude and
+// longitude, from 0 (about 110 km) to 4 (about 10 m); near limits the
+// transactions to an area as on GET /read/transactions.
+func (h *Handlers) GetTransactionsGeo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	startDate := r.URL.Query().Get("start")
+	endDate := r.URL.Query().Get("end")
+	if startDate == "" || endDate == "" {
+		now, _ := h.userClock(ctx, userID)
+		if startDate == "" {
+			startDate = now.AddDate(0, 0, -30).Format("2006-01-02")
+		}
+		if endDate == "" {
+			endDate = now.Format("2006-01-02")
+		}
+	}
+
+	precision := defaultGeoPrecision
+	if p := r.URL.Query().Get("precision"); p != "" {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 || v > 4 {
+			h.respondError(w, http.StatusBadRequest, "precision must be from 0 to 4")
+			return
+		}
+		precision = v
+	}
+
+	filter := store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Analytics: true,
+		Located:   true,
+		Limit:     maxSummaryTransactions,
+	}
+	if near := r.URL.Query().Get("near"); near != "" {
+		area, err := parseNear(near)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.Near = area
+	}
+
+	transactions, err := h.listTransactions(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	clusters := clusterTransactions(transactions, precision)
+	h.respondSuccess(w, map[string]interface{}{
+		"clusters":  clusters,
+		"count":     len(clusters),
+		"period":    summaryPeriod(startDate, endDate),
+		"precision": precision,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
+
+// clusterTransactions groups located transactions into grid cells
+// precision decimal places of a degree wide, placing each cluster at the
+// centroid of its transactions. Clusters are ordered by amount spent.
+func clusterTransactions(transactions []models.Transaction, precision int) []models.GeoCluster {
+	type cell struct {
+		cluster   models.GeoCluster
+		latSum    float64
+		lonSum    float64
+		merchants map[string]decimal.Decimal
+	}
+	scale := math.Pow(10, float64(precision))
+	cells := make(map[[2]int64]*cell)
+	var order [][2]int64
+	for _, txn := range transactions {
+		if txn.Location == nil || txn.Location.Lat == nil || txn.Location.Lon == nil {
+			continue
+		}
+		lat, lon := *txn.Location.Lat, *txn.Location.Lon
+		key := [2]int64{int64(math.Floor(lat * scale)), int64(math.Floor(lon * scale))}
+		c, ok := cells[key]
+		if !ok {
+			c = &cell{
+				cluster:   models.GeoCluster{Spent: decimal.Zero, City: txn.Location.City},
+				merchants: make(map[string]decimal.Decimal),
+			}
+			cells[key] = c
+			order = append(order, key)
+		}
+		c.latSum += lat
+		c.lonSum += lon
+		c.cluster.TransactionCount++
+		if txn.Amount.IsPositive() {
+			c.cluster.Spent = c.cluster.Spent.Add(txn.Amount)
+			if txn.MerchantName != nil && *txn.MerchantName != "" {
+				c.merchants[*txn.MerchantName] = c.merchants[*txn.MerchantName].Add(txn.Amount)
+			}
+		}
+	}
+
+	clusters := make([]models.GeoCluster, 0, len(cells))
+	for _, key := range order {
+		c := cells[key]
+		n := float64(c.cluster.TransactionCount)
+		c.cluster.Lat = math.Round(c.latSum/n*1e6) / 1e6
+		c.cluster.Lon = math.Round(c.lonSum/n*1e6) / 1e6
+
+		merchants := make([]string, 0, len(c.merchants))
+		for name := range c.merchants {
+			merchants = append(merchants, name)
+		}
+		sort.Slice(merchants, func(i, j int) bool {
+			a, b := c.merchants[merchants[i]], c.merchants[merchants[j]]
+			if !a.Equal(b) {
+				return a.GreaterThan(b)
+			}
+			return merchants[i] < merchants[j]
+		})
+		if len(merchants) > geoClusterMerchants {
+			merchants = merchants[:geoClusterMerchants]
+		}
+		c.cluster.Merchants = merchants
+		clusters = append(clusters, c.cluster)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Spent.GreaterThan(clusters[j].Spent)
+	})
+	return clusters
+}
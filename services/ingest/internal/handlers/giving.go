@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/giving"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+)
+
+// GetGiving returns a year's charitable donations, by default last year's
+// for tax time, totalled by charity. Each donation lists the receipts
+// attached to it, and those of $250 or more without one are flagged as
+// needing a written acknowledgment. With summary=true the donations are
+// left out.
+func (h *Handlers) GetGiving(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	year := now.Year() - 1
+	if s := r.URL.Query().Get("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 2000 || y > now.Year() {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("year must be from 2000 to %d", now.Year()))
+			return
+		}
+		year = y
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: start.Format(period.DateLayout),
+		EndDate:   end.Format(period.DateLayout),
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	donations := giving.Donations(transactions)
+	ids := make([]string, len(donations))
+	for i, d := range donations {
+		ids[i] = d.TransactionID
+	}
+	receipts, err := h.giving.Receipts(ctx, userID, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list receipts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query receipts")
+		return
+	}
+
+	report := giving.Report(year, donations, receipts)
+	if wantSummary(r) {
+		report.Donations = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"report":    report,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateGrant gives another user read-only access to the owner's data
+func (h *Handlers) CreateGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID       string `json:"user_id"`
+		GranteeEmail string `json:"grantee_email"`
+		Role         string `json:"role"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.GranteeEmail == "" {
+		h.respondError(w, http.StatusBadRequest, "grantee_email is required")
+		return
+	}
+	if !auth.IsGrantableRole(req.Role) {
+		h.respondError(w, http.StatusBadRequest, "role must be 'viewer' or 'advisor'")
+		return
+	}
+
+	granteeID, err := h.store.Users.IDByEmail(ctx, req.GranteeEmail)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Grantee not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to look up grantee")
+		return
+	}
+	if granteeID == userID {
+		h.respondError(w, http.StatusBadRequest, "Cannot grant access to yourself")
+		return
+	}
+
+	// Re-granting updates the role and reactivates a revoked grant
+	grant := models.Grant{
+		OwnerUserID:   userID,
+		GranteeUserID: granteeID,
+		GranteeEmail:  &req.GranteeEmail,
+		Role:          req.Role,
+	}
+	if err := h.store.Grants.Upsert(ctx, &grant); err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to create grant")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"grant": grant,
+		},
+	})
+}
+
+// ListGrants returns the active grants a user has given and received
+func (h *Handlers) ListGrants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	grants, err := h.store.Grants.ListActive(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query grants")
+		return
+	}
+
+	granted := []models.Grant{}
+	received := []models.Grant{}
+	for _, g := range grants {
+		if g.OwnerUserID == userID {
+			granted = append(granted, g)
+		} else {
+			received = append(received, g)
+		}
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"granted":  granted,
+		"received": received,
+	})
+}
+
+// RevokeGrant removes access; either the owner or the grantee may revoke
+func (h *Handlers) RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	grantID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.store.Grants.Revoke(ctx, grantID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Grant not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to revoke grant")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"revoked": true,
+		"id":      grantID,
+	})
+}
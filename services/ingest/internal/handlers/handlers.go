@@ -1,34 +1,263 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/finagent/ingest/internal/alerts"
+	"github.com/finagent/ingest/internal/apikeys"
+	"github.com/finagent/ingest/internal/attachments"
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/cache"
+	"github.com/finagent/ingest/internal/capture"
+	"github.com/finagent/ingest/internal/categories"
+	"github.com/finagent/ingest/internal/contributions"
+	"github.com/finagent/ingest/internal/corporateactions"
+	"github.com/finagent/ingest/internal/costbasis"
+	"github.com/finagent/ingest/internal/cpi"
+	"github.com/finagent/ingest/internal/cryptoincome"
 	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/dedup"
+	"github.com/finagent/ingest/internal/dependents"
+	"github.com/finagent/ingest/internal/descriptors"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/equity"
+	"github.com/finagent/ingest/internal/expenses"
+	"github.com/finagent/ingest/internal/exports"
+	"github.com/finagent/ingest/internal/faultinjection"
+	"github.com/finagent/ingest/internal/giving"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/marketdata"
 	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/finagent/ingest/internal/paychecks"
 	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/privacy"
+	"github.com/finagent/ingest/internal/ratelimit"
+	"github.com/finagent/ingest/internal/recommendations"
+	"github.com/finagent/ingest/internal/rentals"
+	"github.com/finagent/ingest/internal/reports"
+	"github.com/finagent/ingest/internal/retention"
 	"github.com/finagent/ingest/internal/robinhood"
+	"github.com/finagent/ingest/internal/roundups"
+	"github.com/finagent/ingest/internal/security"
+	"github.com/finagent/ingest/internal/sessions"
+	"github.com/finagent/ingest/internal/snapshot"
+	"github.com/finagent/ingest/internal/statements"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/finagent/ingest/internal/valuation"
+	"github.com/finagent/ingest/internal/watchlist"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/finagent/ingest/internal/yield"
 	"github.com/go-redis/redis/v8"
-	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
 )
 
 type Handlers struct {
-	db          *database.Database
-	redis       *redis.Client
-	plaidClient *plaid.Client
-	rhClient    *robinhood.Client
+	db               *database.Database
+	store            *store.Stores
+	cache            *cache.Cache
+	redis            *redis.Client
+	plaidClient      *plaid.Client
+	rhClient         *robinhood.Client
+	webhooks         *webhooks.Dispatcher
+	jobs             *jobs.Manager
+	locks            *locks.Locker
+	apiKeys          *apikeys.Store
+	encryption       *encryption.Service
+	audit            *audit.Logger
+	privacy          *privacy.Service
+	limiter          *ratelimit.Limiter
+	sessions         *sessions.Store
+	insights         *insights.Store
+	notify           *notifications.Store
+	alerts           *alerts.Engine
+	recommend        *recommendations.Engine
+	watchlist        *watchlist.Service
+	digests          *digest.Service
+	marketData       *marketdata.Service
+	valuations       *valuation.Service
+	corporateActions *corporateactions.Service
+	attachments      *attachments.Service
+	statements       *statements.Service
+	security         *security.Detector
+	retention        *retention.Service
+	dedup            *dedup.Engine
+	expenses         *expenses.Service
+	roundups         *roundups.Service
+	cpi              *cpi.Service
+	reports          *reports.Service
+	exports          *exports.Service
+	categories       *categories.Service
+	contributions    *contributions.Service
+	equity           *equity.Service
+	cryptoIncome     *cryptoincome.Service
+	costBasis        *costbasis.Service
+	yield            *yield.Service
+	paychecks        *paychecks.Service
+	rentals          *rentals.Service
+	giving           *giving.Service
+	dependents       *dependents.Service
+	snapshots        *snapshot.Service
+	usage            *usage.Meter
+	capture          *capture.Recorder
+	faults           *faultinjection.Injector
+
+	configChecksum string
+	webhookLagSLO  time.Duration
+
+	plaidProcessors []string
 }
 
-func New(db *database.Database, redis *redis.Client, plaidClient *plaid.Client, rhClient *robinhood.Client) *Handlers {
-	return &Handlers{
-		db:          db,
-		redis:       redis,
-		plaidClient: plaidClient,
-		rhClient:    rhClient,
+// Deps are the services the handlers use. Store defaults to the Postgres
+// repositories on DB when nil; a non-nil Cache caches its hot reads. Limiter
+// defaults to a Redis rate limiter.
+type Deps struct {
+	DB               *database.Database
+	Store            *store.Stores
+	Cache            *cache.Cache
+	Redis            *redis.Client
+	Plaid            *plaid.Client
+	Robinhood        *robinhood.Client
+	Webhooks         *webhooks.Dispatcher
+	Jobs             *jobs.Manager
+	Locks            *locks.Locker
+	APIKeys          *apikeys.Store
+	Encryption       *encryption.Service
+	Audit            *audit.Logger
+	Privacy          *privacy.Service
+	Sessions         *sessions.Store
+	Insights         *insights.Store
+	Notify           *notifications.Store
+	Alerts           *alerts.Engine
+	Recommend        *recommendations.Engine
+	Watchlist        *watchlist.Service
+	Digests          *digest.Service
+	MarketData       *marketdata.Service
+	Valuations       *valuation.Service
+	CorporateActions *corporateactions.Service
+	Attachments      *attachments.Service
+	Statements       *statements.Service
+	Security         *security.Detector
+	Retention        *retention.Service
+	Limiter          *ratelimit.Limiter
+	Dedup            *dedup.Engine
+	Expenses         *expenses.Service
+	RoundUps         *roundups.Service
+	CPI              *cpi.Service
+	Reports          *reports.Service
+	Exports          *exports.Service
+	Categories       *categories.Service
+	Contributions    *contributions.Service
+	Equity           *equity.Service
+	CryptoIncome     *cryptoincome.Service
+	CostBasis        *costbasis.Service
+	Yield            *yield.Service
+	Paychecks        *paychecks.Service
+	Rentals          *rentals.Service
+	Giving           *giving.Service
+	Dependents       *dependents.Service
+	Snapshots        *snapshot.Service
+	Usage            *usage.Meter
+	Capture          *capture.Recorder
+	Faults           *faultinjection.Injector
+
+	// Fingerprint of the effective configuration, reported by DebugInfo
+	ConfigChecksum string
+
+	// Longest acceptable time from a Plaid webhook arriving to its data
+	// being persisted; 0 disables alerting
+	WebhookLagSLO time.Duration
+
+	// Processors users may issue Plaid processor tokens for
+	PlaidProcessors []string
+}
+
+func New(deps Deps) *Handlers {
+	stores := deps.Store
+	if stores == nil {
+		stores = store.New(deps.DB)
+	}
+	if deps.Cache != nil {
+		stores = store.WithCache(stores, deps.Cache)
+	}
+
+	limiter := deps.Limiter
+	if limiter == nil {
+		limiter = ratelimit.New(deps.Redis)
+	}
+
+	h := &Handlers{
+		db:               deps.DB,
+		store:            stores,
+		cache:            deps.Cache,
+		redis:            deps.Redis,
+		plaidClient:      deps.Plaid,
+		rhClient:         deps.Robinhood,
+		webhooks:         deps.Webhooks,
+		jobs:             deps.Jobs,
+		locks:            deps.Locks,
+		apiKeys:          deps.APIKeys,
+		encryption:       deps.Encryption,
+		audit:            deps.Audit,
+		privacy:          deps.Privacy,
+		limiter:          limiter,
+		sessions:         deps.Sessions,
+		insights:         deps.Insights,
+		notify:           deps.Notify,
+		alerts:           deps.Alerts,
+		recommend:        deps.Recommend,
+		watchlist:        deps.Watchlist,
+		digests:          deps.Digests,
+		marketData:       deps.MarketData,
+		valuations:       deps.Valuations,
+		corporateActions: deps.CorporateActions,
+		attachments:      deps.Attachments,
+		statements:       deps.Statements,
+		security:         deps.Security,
+		retention:        deps.Retention,
+		dedup:            deps.Dedup,
+		expenses:         deps.Expenses,
+		roundups:         deps.RoundUps,
+		cpi:              deps.CPI,
+		reports:          deps.Reports,
+		exports:          deps.Exports,
+		categories:       deps.Categories,
+		contributions:    deps.Contributions,
+		equity:           deps.Equity,
+		cryptoIncome:     deps.CryptoIncome,
+		costBasis:        deps.CostBasis,
+		yield:            deps.Yield,
+		paychecks:        deps.Paychecks,
+		rentals:          deps.Rentals,
+		giving:           deps.Giving,
+		dependents:       deps.Dependents,
+		snapshots:        deps.Snapshots,
+		usage:            deps.Usage,
+		capture:          deps.Capture,
+		faults:           deps.Faults,
+
+		configChecksum: deps.ConfigChecksum,
+		webhookLagSLO:  deps.WebhookLagSLO,
+
+		plaidProcessors: deps.PlaidProcessors,
+	}
+	if h.jobs != nil {
+		h.registerJobs()
 	}
+	return h
 }
 
 type APIResponse struct {
@@ -51,6 +280,35 @@ func (h *Handlers) respondError(w http.ResponseWriter, statusCode int, message s
 	})
 }
 
+// decodeJSON strictly decodes a request body into v, rejecting unknown
+// fields and trailing data. It writes the error response and returns false
+// if the body is invalid. Bodies sent as another content type are refused,
+// since LimitBody only checks the nesting depth of JSON ones.
+func (h *Handlers) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !strings.Contains(strings.ToLower(contentType), "json") {
+		h.respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after JSON body")
+	}
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.respondError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return false
+		}
+		h.respondError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+	return true
+}
+
 func (h *Handlers) respondSuccess(w http.ResponseWriter, data interface{}) {
 	h.respondJSON(w, http.StatusOK, APIResponse{
 		Success: true,
@@ -58,98 +316,103 @@ func (h *Handlers) respondSuccess(w http.ResponseWriter, data interface{}) {
 	})
 }
 
-// HealthCheck returns service health status
-func (h *Handlers) HealthCheck(w http.ResponseWriter, r *http.Request) {
+// GetAccounts returns user accounts totalled per account group, or with
+// household_id the accounts a household shares. Hidden accounts are only
+// listed with include_hidden.
+func (h *Handlers) GetAccounts(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Check database connection
-	if err := h.db.Pool.Ping(ctx); err != nil {
-		h.respondError(w, http.StatusServiceUnavailable, "Database connection failed")
+	userID, household, ok := h.authorizeReadScope(w, r, auth.RoleViewer)
+	if !ok {
 		return
 	}
-
-	// Check Redis connection
-	if err := h.redis.Ping(ctx).Err(); err != nil {
-		h.respondError(w, http.StatusServiceUnavailable, "Redis connection failed")
+	if household != nil {
+		h.getHouseholdAccounts(w, r, household)
 		return
 	}
 
-	h.respondSuccess(w, map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
-		"service":   "finagent-ingest",
-	})
-}
-
-// GetAccounts returns user accounts
-func (h *Handlers) GetAccounts(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
-
-	if userID == "" {
-		h.respondError(w, http.StatusBadRequest, "user_id is required")
+	groups, err := h.store.Accounts.ListGroups(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list account groups", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query account groups")
 		return
 	}
 
-	query := `
-		SELECT a.id, a.name, a.mask, a.official_name, a.type, a.subtype, 
-		       a.currency, a.balance_current, a.balance_available, a.balance_limit,
-		       a.is_closed, a.updated_at
-		FROM accounts a
-		WHERE a.user_id = $1 AND a.is_closed = false
-		ORDER BY a.name
-	`
+	if wantSummary(r) {
+		accounts, err := h.store.Accounts.List(ctx, userID, false)
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+			return
+		}
+		h.respondSuccess(w, map[string]interface{}{
+			"summary": summarizeAccounts(accounts),
+			"groups":  summarizeAccountGroups(accounts, groups),
+		})
+		return
+	}
 
-	rows, err := h.db.Pool.Query(ctx, query, userID)
+	accounts, err := h.listAccounts(ctx, userID, r.URL.Query().Get("include_hidden") == "true")
 	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
 		return
 	}
-	defer rows.Close()
-
-	var accounts []models.Account
-	for rows.Next() {
-		var acc models.Account
-		err := rows.Scan(
-			&acc.ID, &acc.Name, &acc.Mask, &acc.OfficialName,
-			&acc.Type, &acc.Subtype, &acc.Currency,
-			&acc.BalanceCurrent, &acc.BalanceAvailable, &acc.BalanceLimit,
-			&acc.IsClosed, &acc.UpdatedAt,
-		)
-		if err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to scan account")
-			return
-		}
-		accounts = append(accounts, acc)
-	}
 
 	h.respondSuccess(w, map[string]interface{}{
 		"accounts": accounts,
 		"count":    len(accounts),
+		"groups":   summarizeAccountGroups(accounts, groups),
 	})
 }
 
-// GetTransactions returns user transactions with filtering
+// listAccounts returns a user's accounts with their PII decrypted
+func (h *Handlers) listAccounts(ctx context.Context, userID string, includeHidden bool) ([]models.Account, error) {
+	accounts, err := h.store.Accounts.List(ctx, userID, includeHidden)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		acc := &accounts[i]
+		if err := h.decryptPII(ctx, &acc.Mask, &acc.OfficialName); err != nil {
+			return nil, fmt.Errorf("failed to decrypt account: %w", err)
+		}
+	}
+	return accounts, nil
+}
+
+// GetTransactions returns user transactions with filtering, or with
+// household_id those of a household's fully shared accounts. near=lat,lon,
+// radius_km keeps those made within radius_km of a point; kind=check or
+// kind=atm keeps checks or ATM withdrawals, and check_number one check.
 func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
 	startDate := r.URL.Query().Get("start")
 	endDate := r.URL.Query().Get("end")
 	merchant := r.URL.Query().Get("merchant")
 	category := r.URL.Query().Get("category")
+	kind := r.URL.Query().Get("kind")
+	checkNumber := strings.TrimLeft(r.URL.Query().Get("check_number"), "0")
 	limit := r.URL.Query().Get("limit")
 
-	if userID == "" {
-		h.respondError(w, http.StatusBadRequest, "user_id is required")
+	userID, household, ok := h.authorizeReadScope(w, r, auth.RoleViewer)
+	if !ok {
 		return
 	}
 
-	// Default date range (last 30 days)
-	if startDate == "" {
-		startDate = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	if kind != "" && kind != descriptors.KindCheck && kind != descriptors.KindATM {
+		h.respondError(w, http.StatusBadRequest, "kind must be 'check' or 'atm'")
+		return
 	}
-	if endDate == "" {
-		endDate = time.Now().Format("2006-01-02")
+
+	// Default date range (last 30 days, in the user's timezone)
+	if startDate == "" || endDate == "" {
+		now, _ := h.userClock(ctx, userID)
+		if startDate == "" {
+			startDate = now.AddDate(0, 0, -30).Format("2006-01-02")
+		}
+		if endDate == "" {
+			endDate = now.Format("2006-01-02")
+		}
 	}
 
 	// Default limit
@@ -160,122 +423,142 @@ func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Build query
-	query := `
-		SELECT t.id, t.account_id, t.date, t.amount, t.merchant_name,
-		       t.category, t.category_detailed, t.description, t.is_pending,
-		       a.name as account_name, a.mask as account_mask
-		FROM transactions t
-		JOIN accounts a ON t.account_id = a.id
-		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3
-	`
-
-	args := []interface{}{userID, startDate, endDate}
-	argIndex := 4
-
-	if merchant != "" {
-		query += fmt.Sprintf(" AND t.merchant_name ILIKE $%d", argIndex)
-		args = append(args, "%"+merchant+"%")
-		argIndex++
+	filter := store.TransactionFilter{
+		UserID:      userID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Merchant:    merchant,
+		Category:    category,
+		Kind:        kind,
+		CheckNumber: checkNumber,
+		Limit:       limitInt,
 	}
-
-	if category != "" {
-		query += fmt.Sprintf(" AND $%d = ANY(t.category)", argIndex)
-		args = append(args, category)
-		argIndex++
+	near := r.URL.Query().Get("near")
+	if near != "" {
+		area, err := parseNear(near)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.Near = area
+	}
+	summary := wantSummary(r)
+	basis := basisAccrual
+	if summary {
+		// A summary covers the whole range, not just one page, of the
+		// accounts counted in analytics
+		filter.Limit = maxSummaryTransactions
+		filter.Analytics = true
+
+		if basis, ok = h.queryBasis(w, r); !ok {
+			return
+		}
+		filter = basisFilter(filter, basis)
 	}
 
-	query += " ORDER BY t.date DESC, t.amount DESC"
-	query += fmt.Sprintf(" LIMIT $%d", argIndex)
-	args = append(args, limitInt)
-
-	rows, err := h.db.Pool.Query(ctx, query, args...)
+	var transactions []models.Transaction
+	var err error
+	if household != nil {
+		transactions, err = h.listHouseholdTransactions(ctx, household, filter)
+	} else {
+		transactions, err = h.listTransactions(ctx, filter)
+	}
 	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
 		return
 	}
-	defer rows.Close()
 
-	var transactions []models.Transaction
-	for rows.Next() {
-		var txn models.Transaction
-		err := rows.Scan(
-			&txn.ID, &txn.AccountID, &txn.Date, &txn.Amount,
-			&txn.MerchantName, &txn.Category, &txn.CategoryDetailed,
-			&txn.Description, &txn.IsPending,
-			&txn.AccountName, &txn.AccountMask,
-		)
-		if err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to scan transaction")
-			return
-		}
-		transactions = append(transactions, txn)
+	filters := map[string]interface{}{
+		"start_date": startDate,
+		"end_date":   endDate,
+		"merchant":   merchant,
+		"category":   category,
+	}
+	if household != nil {
+		filters["household_id"] = household.ID
+	}
+	if near != "" {
+		filters["near"] = near
+	}
+	if kind != "" {
+		filters["kind"] = kind
+	}
+	if checkNumber != "" {
+		filters["check_number"] = checkNumber
+	}
+	if summary {
+		filters["basis"] = basis
+		h.respondSuccess(w, map[string]interface{}{
+			"summary":   summarizeSpending(applyBasis(transactions, basis, startDate, endDate), startDate, endDate, summaryTopN),
+			"truncated": len(transactions) == maxSummaryTransactions,
+			"filters":   filters,
+		})
+		return
 	}
 
+	filters["limit"] = limitInt
 	h.respondSuccess(w, map[string]interface{}{
 		"transactions": transactions,
 		"count":        len(transactions),
-		"filters": map[string]interface{}{
-			"start_date": startDate,
-			"end_date":   endDate,
-			"merchant":   merchant,
-			"category":   category,
-			"limit":      limitInt,
-		},
+		"filters":      filters,
 	})
 }
 
-// GetHoldings returns user investment holdings
+// listTransactions returns matching transactions with their PII decrypted
+func (h *Handlers) listTransactions(ctx context.Context, filter store.TransactionFilter) ([]models.Transaction, error) {
+	transactions, err := h.store.Transactions.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	for i := range transactions {
+		if err := h.decryptPII(ctx, &transactions[i].AccountMask); err != nil {
+			return nil, fmt.Errorf("failed to decrypt transaction: %w", err)
+		}
+	}
+	return transactions, nil
+}
+
+// GetHoldings returns user investment holdings, or with household_id those
+// in a household's fully shared accounts
 func (h *Handlers) GetHoldings(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
 
-	if userID == "" {
-		h.respondError(w, http.StatusBadRequest, "user_id is required")
+	userID, household, ok := h.authorizeReadScope(w, r, auth.RoleAdvisor)
+	if !ok {
 		return
 	}
 
-	query := `
-		SELECT h.id, h.account_id, h.quantity, h.institution_price, 
-		       h.institution_value, h.cost_basis, h.last_refresh,
-		       s.symbol, s.name as security_name, s.cusip, s.currency,
-		       a.name as account_name, a.mask as account_mask
-		FROM holdings h
-		JOIN securities s ON h.security_id = s.id
-		JOIN accounts a ON h.account_id = a.id
-		WHERE h.user_id = $1
-		ORDER BY h.institution_value DESC NULLS LAST
-	`
-
-	rows, err := h.db.Pool.Query(ctx, query, userID)
+	var holdings []models.Holding
+	var err error
+	if household != nil {
+		holdings, err = h.listHouseholdHoldings(ctx, household)
+	} else {
+		holdings, err = h.store.Holdings.List(ctx, userID)
+	}
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to query holdings")
 		return
 	}
-	defer rows.Close()
 
-	var holdings []models.Holding
-	totalValue := 0.0
-
-	for rows.Next() {
-		var holding models.Holding
-		err := rows.Scan(
-			&holding.ID, &holding.AccountID, &holding.Quantity,
-			&holding.InstitutionPrice, &holding.InstitutionValue,
-			&holding.CostBasis, &holding.LastRefresh,
-			&holding.Symbol, &holding.SecurityName, &holding.CUSIP,
-			&holding.Currency, &holding.AccountName, &holding.AccountMask,
-		)
-		if err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to scan holding")
+	if wantSummary(r) {
+		h.respondSuccess(w, map[string]interface{}{
+			"summary": summarizeHoldings(holdings, summaryTopN),
+		})
+		return
+	}
+
+	totalValue := decimal.Zero
+	for i := range holdings {
+		holding := &holdings[i]
+		if err := h.decryptPII(ctx, &holding.AccountMask); err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to decrypt holding")
 			return
 		}
 
-		if holding.InstitutionValue != nil {
-			totalValue += *holding.InstitutionValue
+		if holding.InstitutionValue != nil && !holding.Excluded {
+			totalValue = totalValue.Add(*holding.InstitutionValue)
 		}
-
-		holdings = append(holdings, holding)
 	}
 
 	h.respondSuccess(w, map[string]interface{}{
@@ -288,22 +571,24 @@ func (h *Handlers) GetHoldings(w http.ResponseWriter, r *http.Request) {
 // GetInvestmentTransactions returns user investment transactions
 func (h *Handlers) GetInvestmentTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
 	startDate := r.URL.Query().Get("start")
 	endDate := r.URL.Query().Get("end")
 	limit := r.URL.Query().Get("limit")
 
-	if userID == "" {
-		h.respondError(w, http.StatusBadRequest, "user_id is required")
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
 		return
 	}
 
-	// Default date range (last 90 days)
-	if startDate == "" {
-		startDate = time.Now().AddDate(0, 0, -90).Format("2006-01-02")
-	}
-	if endDate == "" {
-		endDate = time.Now().Format("2006-01-02")
+	// Default date range (last 90 days, in the user's timezone)
+	if startDate == "" || endDate == "" {
+		now, _ := h.userClock(ctx, userID)
+		if startDate == "" {
+			startDate = now.AddDate(0, 0, -90).Format("2006-01-02")
+		}
+		if endDate == "" {
+			endDate = now.Format("2006-01-02")
+		}
 	}
 
 	limitInt := 100
@@ -313,40 +598,35 @@ func (h *Handlers) GetInvestmentTransactions(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	query := `
-		SELECT it.id, it.account_id, it.date, it.name, it.quantity,
-		       it.amount, it.price, it.fees, it.type, it.subtype,
-		       s.symbol, s.name as security_name,
-		       a.name as account_name, a.mask as account_mask
-		FROM investment_transactions it
-		LEFT JOIN securities s ON it.security_id = s.id
-		JOIN accounts a ON it.account_id = a.id
-		WHERE it.user_id = $1 AND it.date >= $2 AND it.date <= $3
-		ORDER BY it.date DESC
-		LIMIT $4
-	`
-
-	rows, err := h.db.Pool.Query(ctx, query, userID, startDate, endDate, limitInt)
+	summary := wantSummary(r)
+	if summary {
+		limitInt = maxSummaryTransactions
+	}
+
+	transactions, err := h.store.Transactions.ListInvestment(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Limit:     limitInt,
+	})
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to query investment transactions")
 		return
 	}
-	defer rows.Close()
-
-	var transactions []models.InvestmentTransaction
-	for rows.Next() {
-		var txn models.InvestmentTransaction
-		err := rows.Scan(
-			&txn.ID, &txn.AccountID, &txn.Date, &txn.Name,
-			&txn.Quantity, &txn.Amount, &txn.Price, &txn.Fees,
-			&txn.Type, &txn.Subtype, &txn.Symbol, &txn.SecurityName,
-			&txn.AccountName, &txn.AccountMask,
-		)
-		if err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to scan investment transaction")
+
+	if summary {
+		h.respondSuccess(w, map[string]interface{}{
+			"summary":   summarizeInvestmentActivity(transactions, startDate, endDate),
+			"truncated": len(transactions) == maxSummaryTransactions,
+		})
+		return
+	}
+
+	for i := range transactions {
+		if err := h.decryptPII(ctx, &transactions[i].AccountMask); err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to decrypt investment transaction")
 			return
 		}
-		transactions = append(transactions, txn)
 	}
 
 	h.respondSuccess(w, map[string]interface{}{
@@ -358,50 +638,30 @@ func (h *Handlers) GetInvestmentTransactions(w http.ResponseWriter, r *http.Requ
 // GetCryptoPositions returns user crypto positions
 func (h *Handlers) GetCryptoPositions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := r.URL.Query().Get("user_id")
 
-	if userID == "" {
-		h.respondError(w, http.StatusBadRequest, "user_id is required")
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
 		return
 	}
 
-	query := `
-		SELECT id, symbol, name, quantity, average_price, market_value,
-		       cost_basis, unrealized_pnl, last_price, price_change_24h,
-		       price_change_percent_24h, last_refresh
-		FROM crypto_positions
-		WHERE user_id = $1
-		ORDER BY market_value DESC NULLS LAST
-	`
-
-	rows, err := h.db.Pool.Query(ctx, query, userID)
+	positions, err := h.store.Orders.ListPositions(ctx, userID)
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to query crypto positions")
 		return
 	}
-	defer rows.Close()
-
-	var positions []models.CryptoPosition
-	totalValue := 0.0
 
-	for rows.Next() {
-		var pos models.CryptoPosition
-		err := rows.Scan(
-			&pos.ID, &pos.Symbol, &pos.Name, &pos.Quantity,
-			&pos.AveragePrice, &pos.MarketValue, &pos.CostBasis,
-			&pos.UnrealizedPnL, &pos.LastPrice, &pos.PriceChange24h,
-			&pos.PriceChangePercent24h, &pos.LastRefresh,
-		)
-		if err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to scan crypto position")
-			return
-		}
+	if wantSummary(r) {
+		h.respondSuccess(w, map[string]interface{}{
+			"summary": summarizeCryptoPositions(positions, summaryTopN),
+		})
+		return
+	}
 
+	totalValue := decimal.Zero
+	for _, pos := range positions {
 		if pos.MarketValue != nil {
-			totalValue += *pos.MarketValue
+			totalValue = totalValue.Add(*pos.MarketValue)
 		}
-
-		positions = append(positions, pos)
 	}
 
 	h.respondSuccess(w, map[string]interface{}{
@@ -410,36 +670,3 @@ func (h *Handlers) GetCryptoPositions(w http.ResponseWriter, r *http.Request) {
 		"total_value": totalValue,
 	})
 }
-
-// GetMetrics returns basic service metrics
-func (h *Handlers) GetMetrics(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	// Get some basic metrics from database
-	var userCount, accountCount, transactionCount int
-
-	err := h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&userCount)
-	if err != nil && err != pgx.ErrNoRows {
-		userCount = 0
-	}
-
-	err = h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM accounts WHERE is_closed = false").Scan(&accountCount)
-	if err != nil && err != pgx.ErrNoRows {
-		accountCount = 0
-	}
-
-	err = h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM transactions WHERE date >= CURRENT_DATE - INTERVAL '30 days'").Scan(&transactionCount)
-	if err != nil && err != pgx.ErrNoRows {
-		transactionCount = 0
-	}
-
-	metrics := map[string]interface{}{
-		"users":                  userCount,
-		"active_accounts":        accountCount,
-		"transactions_last_30d":  transactionCount,
-		"timestamp":              time.Now().UTC(),
-		"service_uptime_seconds": time.Since(time.Now().Add(-time.Hour)).Seconds(), // placeholder
-	}
-
-	h.respondJSON(w, http.StatusOK, metrics)
-}
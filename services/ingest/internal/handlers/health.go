@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/breaker"
+)
+
+// readinessTimeout bounds each readiness check, so one hung dependency
+// cannot stall the probe
+const readinessTimeout = 3 * time.Second
+
+// startedAt is when the process started, reported by liveness checks
+var startedAt = time.Now()
+
+// HealthCheck is the liveness probe. It only reports that the process is
+// serving requests; dependencies are checked by ReadinessCheck, so an
+// outage of the database does not get every instance restarted.
+func (h *Handlers) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	h.respondSuccess(w, map[string]interface{}{
+		"status":         "alive",
+		"service":        "finagent-ingest",
+		"timestamp":      time.Now().UTC(),
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+	})
+}
+
+// ComponentStatus is the result of checking one dependency
+type ComponentStatus struct {
+	Status    string                 `json:"status"` // up or down
+	Critical  bool                   `json:"critical"`
+	LatencyMS float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type readinessCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) (map[string]interface{}, error)
+}
+
+// ReadinessCheck is the readiness probe. It checks the database, Redis,
+// the schema version, the Plaid API and the upstream circuit breakers
+// concurrently and reports each with its latency. Instances are ready while
+// every critical component is up; a down replica or upstream only makes
+// them degraded, since reads fall back to the primary and cached data can
+// still be served.
+func (h *Handlers) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
+	checks := []readinessCheck{
+		{name: "database", critical: true, check: func(ctx context.Context) (map[string]interface{}, error) {
+			return nil, h.db.Pool.Ping(ctx)
+		}},
+		{name: "redis", critical: true, check: func(ctx context.Context) (map[string]interface{}, error) {
+			return nil, h.redis.Ping(ctx).Err()
+		}},
+		{name: "migrations", critical: true, check: h.checkMigrations},
+		{name: "plaid", critical: false, check: func(ctx context.Context) (map[string]interface{}, error) {
+			details := map[string]interface{}{"breaker": h.plaidClient.Breaker().State()}
+			if err := checkBreaker(h.plaidClient.Breaker()); err != nil {
+				return details, err
+			}
+			return details, h.plaidClient.Ping(ctx)
+		}},
+		{name: "robinhood", critical: false, check: func(ctx context.Context) (map[string]interface{}, error) {
+			details := map[string]interface{}{"breaker": h.rhClient.Breaker().State()}
+			return details, checkBreaker(h.rhClient.Breaker())
+		}},
+	}
+	if attached, _ := h.db.ReplicaStatus(); attached {
+		checks = append(checks, readinessCheck{name: "replica", critical: false, check: func(ctx context.Context) (map[string]interface{}, error) {
+			if _, healthy := h.db.ReplicaStatus(); !healthy {
+				return nil, fmt.Errorf("replica unreachable; reads are served by the primary")
+			}
+			return nil, nil
+		}})
+	}
+
+	components := make(map[string]ComponentStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c readinessCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := c.check(ctx)
+			result := ComponentStatus{
+				Status:    "up",
+				Critical:  c.critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "down"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			components[c.name] = result
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	for _, c := range components {
+		if c.Status == "up" {
+			continue
+		}
+		if c.Critical {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+
+	h.respondJSON(w, code, APIResponse{
+		Success: code == http.StatusOK,
+		Data: map[string]interface{}{
+			"status":     status,
+			"components": components,
+			"timestamp":  time.Now().UTC(),
+		},
+	})
+}
+
+// checkMigrations fails while the schema is dirty or behind the binary,
+// e.g. during a rollout before `ingest migrate up` has run
+func (h *Handlers) checkMigrations(ctx context.Context) (map[string]interface{}, error) {
+	status, err := h.db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]interface{}{
+		"version": status.Version,
+		"latest":  status.Latest,
+		"pending": status.Pending,
+	}
+	if status.Dirty {
+		return details, fmt.Errorf("schema is dirty at version %d", status.Version)
+	}
+	if status.Pending > 0 {
+		return details, fmt.Errorf("%d pending migrations", status.Pending)
+	}
+	return details, nil
+}
+
+// checkBreaker fails while an upstream's circuit breaker is open, so its
+// calls are failing fast
+func checkBreaker(b *breaker.Breaker) error {
+	if b.State() == breaker.StateOpen {
+		return fmt.Errorf("%s circuit breaker is open", b.Name())
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// historyTables are the tables whose changes are recorded
+var historyTables = map[string]bool{
+	"accounts":                true,
+	"transactions":            true,
+	"holdings":                true,
+	"investment_transactions": true,
+}
+
+// GetChanges lists what changed in a user's accounts, transactions and
+// holdings since a point in time, by default the last 24 hours
+func (h *Handlers) GetChanges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	if s := r.URL.Query().Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "since must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+		since = t
+	}
+
+	table := r.URL.Query().Get("table")
+	if table != "" && !historyTables[table] {
+		h.respondError(w, http.StatusBadRequest, "Unknown table")
+		return
+	}
+
+	limit := 500
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 5000 {
+		limit = l
+	}
+
+	changes, err := h.store.History.Changes(ctx, userID, since, table, limit)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query changes")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"changes": changes,
+		"count":   len(changes),
+		"since":   since,
+	})
+}
+
+// GetRecordHistory returns every recorded version of one account,
+// transaction or holding, oldest first
+func (h *Handlers) GetRecordHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	table := chi.URLParam(r, "table")
+	if !historyTables[table] {
+		h.respondError(w, http.StatusBadRequest, "Unknown table")
+		return
+	}
+
+	versions, err := h.store.History.Record(ctx, userID, table, chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query record history")
+		return
+	}
+	if len(versions) == 0 {
+		h.respondError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
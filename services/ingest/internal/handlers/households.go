@@ -0,0 +1,781 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxHouseholdNameLength bounds household names, in characters
+const maxHouseholdNameLength = 100
+
+// householdVisibilities are the account visibilities members may choose
+var householdVisibilities = []string{models.VisibilityFull, models.VisibilityBalanceOnly, models.VisibilityHidden}
+
+// householdScope is a household as seen by one of its active members
+type householdScope struct {
+	ID         string
+	UserID     string   // the member acting
+	Members    []string // active members' user IDs
+	Owner      bool     // whether the acting member owns the household
+	visibility map[string]string
+}
+
+// visibilityOf returns how much of an account the household sees
+func (s *householdScope) visibilityOf(accountID string) string {
+	if v, ok := s.visibility[accountID]; ok {
+		return v
+	}
+	return models.VisibilityFull
+}
+
+// restricted returns the accounts not shared in full
+func (s *householdScope) restricted() []string {
+	var ids []string
+	for accountID, v := range s.visibility {
+		if v != models.VisibilityFull {
+			ids = append(ids, accountID)
+		}
+	}
+	return ids
+}
+
+// householdAccount is an account shared with a household
+type householdAccount struct {
+	models.Account
+	UserID     string `json:"user_id"`
+	Visibility string `json:"visibility"`
+}
+
+// authorizeReadScope resolves whose data a read request covers: the
+// user_id's as with authorizeQueryUser, or with household_id, what the
+// household shares with the caller, who must be an active member. The scope
+// is nil without household_id.
+func (h *Handlers) authorizeReadScope(w http.ResponseWriter, r *http.Request, role string) (string, *householdScope, bool) {
+	householdID := r.URL.Query().Get("household_id")
+	if householdID == "" {
+		userID, ok := h.authorizeQueryUser(w, r, role)
+		return userID, nil, ok
+	}
+
+	// Members act for themselves; grants don't extend to households
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return "", nil, false
+	}
+	scope, ok := h.householdScope(w, r, householdID, userID)
+	if !ok {
+		return "", nil, false
+	}
+	return userID, scope, true
+}
+
+// householdScope loads a household for one of its active members, writing
+// an error response and returning false when userID is not one
+func (h *Handlers) householdScope(w http.ResponseWriter, r *http.Request, householdID, userID string) (*householdScope, bool) {
+	ctx := r.Context()
+
+	household, err := h.store.Households.Get(ctx, householdID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Household not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query household", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query household")
+		return nil, false
+	}
+
+	scope := &householdScope{ID: household.ID, UserID: userID}
+	member := false
+	for _, m := range household.Members {
+		if m.Status != models.MemberActive {
+			continue
+		}
+		scope.Members = append(scope.Members, m.UserID)
+		if m.UserID == userID {
+			member = true
+			scope.Owner = m.Role == models.HouseholdRoleOwner
+		}
+	}
+	// Households are invisible to non-members
+	if !member {
+		h.respondError(w, http.StatusNotFound, "Household not found")
+		return nil, false
+	}
+
+	scope.visibility, err = h.store.Households.Visibility(ctx, householdID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query account visibility", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query household")
+		return nil, false
+	}
+	return scope, true
+}
+
+// listHouseholdAccounts returns the accounts of every member that are not
+// hidden from the household
+func (h *Handlers) listHouseholdAccounts(ctx context.Context, scope *householdScope) ([]householdAccount, error) {
+	accounts := []householdAccount{}
+	for _, memberID := range scope.Members {
+		memberAccounts, err := h.listAccounts(ctx, memberID, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, acc := range memberAccounts {
+			v := scope.visibilityOf(acc.ID)
+			if v == models.VisibilityHidden {
+				continue
+			}
+			accounts = append(accounts, householdAccount{Account: acc, UserID: memberID, Visibility: v})
+		}
+	}
+	return accounts, nil
+}
+
+// listHouseholdTransactions returns the transactions matching filter on
+// every member's fully shared accounts, newest first
+func (h *Handlers) listHouseholdTransactions(ctx context.Context, scope *householdScope, filter store.TransactionFilter) ([]models.Transaction, error) {
+	filter.ExcludeAccounts = scope.restricted()
+
+	var transactions []models.Transaction
+	for _, memberID := range scope.Members {
+		filter.UserID = memberID
+		memberTxns, err := h.listTransactions(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, memberTxns...)
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.After(transactions[j].Date)
+		}
+		return transactions[i].Amount.GreaterThan(transactions[j].Amount)
+	})
+	if len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+	return transactions, nil
+}
+
+// listHouseholdHoldings returns the holdings in every member's fully
+// shared accounts, largest first
+func (h *Handlers) listHouseholdHoldings(ctx context.Context, scope *householdScope) ([]models.Holding, error) {
+	var holdings []models.Holding
+	for _, memberID := range scope.Members {
+		memberHoldings, err := h.store.Holdings.List(ctx, memberID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query holdings: %w", err)
+		}
+		for _, holding := range memberHoldings {
+			if scope.visibilityOf(holding.AccountID) == models.VisibilityFull {
+				holdings = append(holdings, holding)
+			}
+		}
+	}
+
+	// Holdings without a value go last, as in HoldingStore.List
+	value := func(holding models.Holding) decimal.Decimal {
+		if holding.InstitutionValue == nil {
+			return decimal.NewFromInt(-1)
+		}
+		return *holding.InstitutionValue
+	}
+	sort.SliceStable(holdings, func(i, j int) bool {
+		return value(holdings[i]).GreaterThan(value(holdings[j]))
+	})
+	return holdings, nil
+}
+
+// getHouseholdAccounts answers GetAccounts for a household
+func (h *Handlers) getHouseholdAccounts(w http.ResponseWriter, r *http.Request, scope *householdScope) {
+	ctx := r.Context()
+
+	accounts, err := h.listHouseholdAccounts(ctx, scope)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list household accounts", "household_id", scope.ID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	if wantSummary(r) {
+		h.respondSuccess(w, map[string]interface{}{
+			"household_id": scope.ID,
+			"summary":      summarizeAccounts(plainAccounts(accounts)),
+		})
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household_id": scope.ID,
+		"accounts":     accounts,
+		"count":        len(accounts),
+	})
+}
+
+func plainAccounts(accounts []householdAccount) []models.Account {
+	plain := make([]models.Account, len(accounts))
+	for i, acc := range accounts {
+		plain[i] = acc.Account
+	}
+	return plain
+}
+
+// CreateHousehold creates a household owned by the caller
+func (h *Handlers) CreateHousehold(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Name   string `json:"name"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		h.respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if len([]rune(name)) > maxHouseholdNameLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name must be at most %d characters", maxHouseholdNameLength))
+		return
+	}
+
+	household := &models.Household{Name: name, CreatedBy: &userID}
+	if err := h.store.Households.Create(ctx, household); err != nil {
+		slog.ErrorContext(ctx, "Failed to create household", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create household")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionHouseholdCreated,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"household_id": household.ID},
+	})
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"household": household,
+		},
+	})
+}
+
+// ListHouseholds returns the households a user belongs to or is invited to
+func (h *Handlers) ListHouseholds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	households, err := h.store.Households.ListForUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list households", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query households")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"households": households,
+		"count":      len(households),
+	})
+}
+
+// GetHousehold returns a household with its members. Invited users may
+// see it before joining.
+func (h *Handlers) GetHousehold(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	household, err := h.store.Households.Get(ctx, householdID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Household not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query household", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query household")
+		return
+	}
+	member := false
+	for _, m := range household.Members {
+		member = member || m.UserID == userID
+	}
+	if !member {
+		h.respondError(w, http.StatusNotFound, "Household not found")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household": household,
+	})
+}
+
+// DeleteHousehold deletes a household; only its owner may
+func (h *Handlers) DeleteHousehold(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	scope, ok := h.householdScope(w, r, householdID, userID)
+	if !ok {
+		return
+	}
+	if !scope.Owner {
+		h.respondError(w, http.StatusForbidden, "Only the household owner can delete it")
+		return
+	}
+
+	if err := h.store.Households.Delete(ctx, householdID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(ctx, "Failed to delete household", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete household")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionHouseholdDeleted,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"household_id": householdID},
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      householdID,
+	})
+}
+
+// InviteHouseholdMember invites a user by email; only the owner may invite
+func (h *Handlers) InviteHouseholdMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	scope, ok := h.householdScope(w, r, householdID, userID)
+	if !ok {
+		return
+	}
+	if !scope.Owner {
+		h.respondError(w, http.StatusForbidden, "Only the household owner can invite members")
+		return
+	}
+
+	if req.Email == "" {
+		h.respondError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+	inviteeID, err := h.store.Users.IDByEmail(ctx, req.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to look up user")
+		return
+	}
+
+	err = h.store.Households.Invite(ctx, householdID, inviteeID, userID)
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "User is already a member or invited")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to invite household member", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to invite member")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionMemberInvited,
+		TargetUserID: inviteeID,
+		Metadata:     map[string]interface{}{"household_id": householdID},
+	})
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"household_id": householdID,
+			"user_id":      inviteeID,
+			"status":       models.MemberInvited,
+		},
+	})
+}
+
+// JoinHousehold accepts the caller's invitation to a household
+func (h *Handlers) JoinHousehold(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.store.Households.Join(ctx, householdID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to join household", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to join household")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionMemberJoined,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"household_id": householdID},
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household_id": householdID,
+		"user_id":      userID,
+		"status":       models.MemberActive,
+	})
+}
+
+// RemoveHouseholdMember removes a member or withdraws an invitation. The
+// owner may remove anyone else; members may remove themselves, leaving or
+// declining.
+func (h *Handlers) RemoveHouseholdMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+	memberID := chi.URLParam(r, "userID")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if memberID != userID {
+		scope, ok := h.householdScope(w, r, householdID, userID)
+		if !ok {
+			return
+		}
+		if !scope.Owner {
+			h.respondError(w, http.StatusForbidden, "Only the household owner can remove other members")
+			return
+		}
+	} else {
+		member, err := h.store.Households.Member(ctx, householdID, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Household not found")
+			return
+		}
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to query household")
+			return
+		}
+		if member.Role == models.HouseholdRoleOwner {
+			h.respondError(w, http.StatusBadRequest, "The owner cannot leave; delete the household instead")
+			return
+		}
+	}
+
+	err := h.store.Households.RemoveMember(ctx, householdID, memberID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to remove household member", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to remove member")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionMemberRemoved,
+		TargetUserID: memberID,
+		Metadata:     map[string]interface{}{"household_id": householdID},
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"removed":      true,
+		"household_id": householdID,
+		"user_id":      memberID,
+	})
+}
+
+// SetAccountVisibility sets how much of one of the caller's accounts their
+// household sees: everything, its balance only, or nothing
+func (h *Handlers) SetAccountVisibility(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+	accountID := chi.URLParam(r, "accountID")
+
+	var req struct {
+		UserID     string `json:"user_id"`
+		Visibility string `json:"visibility"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	if _, ok := h.householdScope(w, r, householdID, userID); !ok {
+		return
+	}
+
+	valid := false
+	for _, v := range householdVisibilities {
+		valid = valid || req.Visibility == v
+	}
+	if !valid {
+		h.respondError(w, http.StatusBadRequest, "visibility must be one of "+strings.Join(householdVisibilities, ", "))
+		return
+	}
+
+	err := h.store.Households.SetVisibility(ctx, householdID, userID, accountID, req.Visibility)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to set account visibility", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set account visibility")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household_id": householdID,
+		"account_id":   accountID,
+		"visibility":   req.Visibility,
+	})
+}
+
+// GetHouseholdNetWorth totals the balances of the accounts a household
+// shares, overall and per member. Hidden accounts are left out.
+func (h *Handlers) GetHouseholdNetWorth(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	scope, ok := h.householdScope(w, r, chi.URLParam(r, "id"), userID)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.listHouseholdAccounts(ctx, scope)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list household accounts", "household_id", scope.ID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	byMember := make(map[string][]models.Account, len(scope.Members))
+	for _, acc := range accounts {
+		byMember[acc.UserID] = append(byMember[acc.UserID], acc.Account)
+	}
+	members := make([]map[string]interface{}, 0, len(scope.Members))
+	for _, memberID := range scope.Members {
+		members = append(members, map[string]interface{}{
+			"user_id": memberID,
+			"summary": summarizeAccounts(byMember[memberID]),
+		})
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household_id": scope.ID,
+		"summary":      summarizeAccounts(plainAccounts(accounts)),
+		"members":      members,
+		"as_of":        time.Now().UTC(),
+	})
+}
+
+// SetHouseholdBudget creates a monthly budget for a category, or changes
+// the limit of the category's existing one
+func (h *Handlers) SetHouseholdBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	householdID := chi.URLParam(r, "id")
+
+	var req struct {
+		UserID       string          `json:"user_id"`
+		Category     string          `json:"category"`
+		MonthlyLimit decimal.Decimal `json:"monthly_limit"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	if _, ok := h.householdScope(w, r, householdID, userID); !ok {
+		return
+	}
+
+	if req.Category == "" {
+		h.respondError(w, http.StatusBadRequest, "category is required")
+		return
+	}
+	if !req.MonthlyLimit.IsPositive() {
+		h.respondError(w, http.StatusBadRequest, "monthly_limit must be positive")
+		return
+	}
+
+	budget := &models.Budget{HouseholdID: householdID, Category: req.Category, MonthlyLimit: req.MonthlyLimit}
+	if err := h.store.Households.UpsertBudget(ctx, budget, userID); err != nil {
+		slog.ErrorContext(ctx, "Failed to save budget", "household_id", householdID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to save budget")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"budget": budget,
+	})
+}
+
+// ListHouseholdBudgets returns a household's budgets with what its members
+// have spent against them this budget period, in the caller's timezone:
+// the calendar month, or the period of their own the caller's
+// budget_period preference names, such as a pay period. Limits apply to
+// each budget period. Only fully shared accounts that are not excluded
+// from analytics count.
+func (h *Handlers) ListHouseholdBudgets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	scope, ok := h.householdScope(w, r, chi.URLParam(r, "id"), userID)
+	if !ok {
+		return
+	}
+
+	budgets, err := h.store.Households.Budgets(ctx, scope.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list budgets", "household_id", scope.ID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query budgets")
+		return
+	}
+
+	now, cal := h.userCalendar(ctx, userID)
+	budgetPeriod := cal.Budget(now)
+	start, end := budgetPeriod.Start.Format(period.DateLayout), budgetPeriod.End.Format(period.DateLayout)
+
+	statuses := make([]models.BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		transactions, err := h.listHouseholdTransactions(ctx, scope, store.TransactionFilter{
+			StartDate: start,
+			EndDate:   end,
+			Category:  budget.Category,
+			Analytics: true,
+			Limit:     maxSummaryTransactions,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list budget transactions", "household_id", scope.ID, "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+			return
+		}
+
+		spent := decimal.Zero
+		for _, txn := range transactions {
+			if txn.Amount.IsPositive() {
+				spent = spent.Add(txn.Amount)
+			}
+		}
+		statuses = append(statuses, models.BudgetStatus{
+			Budget:     budget,
+			Spent:      spent,
+			Remaining:  budget.MonthlyLimit.Sub(spent),
+			Percentage: percentOf(spent, budget.MonthlyLimit),
+		})
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"household_id": scope.ID,
+		"period":       summaryPeriod(start, end),
+		"label":        budgetPeriod.Label,
+		"budgets":      statuses,
+		"count":        len(statuses),
+	})
+}
+
+// DeleteHouseholdBudget deletes one of a household's budgets
+func (h *Handlers) DeleteHouseholdBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	budgetID := chi.URLParam(r, "budgetID")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	scope, ok := h.householdScope(w, r, chi.URLParam(r, "id"), userID)
+	if !ok {
+		return
+	}
+
+	err := h.store.Households.DeleteBudget(ctx, scope.ID, budgetID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Budget not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete budget", "household_id", scope.ID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete budget")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      budgetID,
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/go-chi/chi/v5"
+)
+
+// Long-poll bounds; kept under the router's 60s request timeout
+const (
+	defaultJobWait = 30 * time.Second
+	maxJobWait     = 55 * time.Second
+)
+
+// registerJobs binds each job type to the handler the queue workers run
+func (h *Handlers) registerJobs() {
+	h.jobs.Register(jobs.TypeInitialSync, h.itemSyncTask)
+	h.jobs.Register(jobs.TypeManualSync, h.itemSyncTask)
+	h.jobs.Register(jobs.TypeTransactionsWebhook, h.transactionWebhookTask)
+	h.jobs.Register(jobs.TypeReencrypt, h.reencryptJob)
+	h.jobs.Register(jobs.TypeExport, h.exportTask)
+	h.jobs.Register(jobs.TypeOrderSimulation, h.orderSimulationTask)
+	h.jobs.Register(jobs.TypeDigest, h.digestTask)
+	h.jobs.Register(jobs.TypeEnrichSecurities, h.enrichSecuritiesTask)
+	h.jobs.Register(jobs.TypeHoldingValuation, h.valuationTask)
+	h.jobs.Register(jobs.TypeTransferSimulation, h.transferSimulationTask)
+	h.jobs.Register(jobs.TypeTransferEvents, h.transferEventsTask)
+	h.jobs.Register(jobs.TypeCategoryMigration, h.categoryMigrationTask)
+	h.jobs.Register(jobs.TypeCryptoIncomeSync, h.cryptoIncomeSyncTask)
+}
+
+// GetJob returns job status, progress and result, optionally long-polling
+// until the job finishes when wait=true
+func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobs.Get(ctx, jobID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query job")
+		return
+	}
+
+	// Jobs not tied to a user (e.g. webhook-triggered syncs) are not exposed
+	if job.UserID == nil || *job.UserID != userID {
+		h.respondError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	if r.URL.Query().Get("wait") == "true" && !jobs.IsTerminal(job.Status) {
+		wait := defaultJobWait
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			if d, err := time.ParseDuration(t); err == nil && d > 0 {
+				wait = d
+			}
+		}
+		if wait > maxJobWait {
+			wait = maxJobWait
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+
+		job, err = h.jobs.Wait(waitCtx, jobID)
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "Failed to query job")
+			return
+		}
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"job":      job,
+		"terminal": jobs.IsTerminal(job.Status),
+	})
+}
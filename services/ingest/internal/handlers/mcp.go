@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/descriptors"
+	"github.com/finagent/ingest/internal/mcp"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/redact"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/shopspring/decimal"
+)
+
+// MCP resource URIs
+const (
+	resourceAccounts       = "finagent://accounts"
+	resourceNetWorthLatest = "finagent://networth/latest"
+)
+
+// userIDProperty lets tools act on another user's data, subject to grants
+var userIDProperty = map[string]interface{}{
+	"type":        "string",
+	"description": "User whose data to use; defaults to the caller. Acting for another user needs their grant.",
+}
+
+// dateProperty describes a YYYY-MM-DD tool argument
+func dateProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "pattern": `^\d{4}-\d{2}-\d{2}$`, "description": description}
+}
+
+// basisProperty chooses when spending summaries count card purchases
+var basisProperty = map[string]interface{}{
+	"type":        "string",
+	"enum":        []string{basisAccrual, basisCash},
+	"default":     basisAccrual,
+	"description": "accrual counts card purchases on the day they were made; cash counts them when the card payment covering them was made",
+}
+
+// MCPServer exposes the read model and dry-run orders as MCP tools, and
+// account snapshots as resources that stdio sessions can subscribe to.
+// Tools and resources act for the principal in the request context, under
+// the same grants, quotas and redaction as the REST API.
+func (h *Handlers) MCPServer() *mcp.Server {
+	s := mcp.NewServer("finagent-ingest", "0.1.0")
+
+	s.AddResource(mcp.Resource{
+		URI:         resourceAccounts,
+		Name:        "accounts",
+		Description: "The caller's linked accounts with their latest balances. Updated when a sync completes.",
+		Read:        h.mcpResource(h.mcpReadAccounts),
+	})
+	s.AddResource(mcp.Resource{
+		URI:         resourceNetWorthLatest,
+		Name:        "networth/latest",
+		Description: "The caller's current net worth: account assets less credit and loan balances, plus crypto positions at market value. Updated when a sync completes.",
+		Read:        h.mcpResource(h.mcpReadNetWorth),
+	})
+	s.SetWatcher(h.watchMCPResources)
+
+	s.AddTool(h.mcpTool(mcp.Tool{
+		Name:        "get_transactions",
+		Description: "List bank and card transactions, newest first, optionally filtered by date range, merchant, category or kind, such as checks written. Positive amounts are money out.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"user_id":      userIDProperty,
+				"start":        dateProperty("First day, YYYY-MM-DD; defaults to 30 days ago"),
+				"end":          dateProperty("Last day, YYYY-MM-DD; defaults to today"),
+				"merchant":     map[string]interface{}{"type": "string", "description": "Merchant name to match"},
+				"category":     map[string]interface{}{"type": "string", "description": "Category to match"},
+				"kind":         map[string]interface{}{"type": "string", "enum": []string{descriptors.KindCheck, descriptors.KindATM}, "description": "Only checks or only ATM withdrawals"},
+				"check_number": map[string]interface{}{"type": "string", "description": "Number of one check to find"},
+				"limit":        map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100},
+				"summary":      map[string]interface{}{"type": "boolean", "description": "Return totals and the top categories and merchants over the whole range instead of the transactions"},
+				"basis":        basisProperty,
+			},
+		},
+		Handler: h.mcpGetTransactions,
+	}))
+
+	s.AddTool(h.mcpTool(mcp.Tool{
+		Name:        "get_spending_summary",
+		Description: "Summarise spending and income over a date range: totals, net cash flow and the top categories and merchants by amount spent.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"user_id": userIDProperty,
+				"start":   dateProperty("First day, YYYY-MM-DD; defaults to 30 days ago"),
+				"end":     dateProperty("Last day, YYYY-MM-DD; defaults to today"),
+				"top_n":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50, "default": 10},
+				"basis":   basisProperty,
+			},
+		},
+		Handler: h.mcpGetSpendingSummary,
+	}))
+
+	s.AddTool(h.mcpTool(mcp.Tool{
+		Name:        "place_crypto_order_dry_run",
+		Description: "Simulate a crypto market order without trading. The simulated order fills shortly after at the simulated market price.",
+		InputSchema: map[string]interface{}{
+			"type":     "object",
+			"required": []string{"symbol", "side", "quantity"},
+			"properties": map[string]interface{}{
+				"user_id":  userIDProperty,
+				"symbol":   map[string]interface{}{"type": "string", "description": "Crypto symbol, such as BTC"},
+				"side":     map[string]interface{}{"type": "string", "enum": []string{"buy", "sell"}},
+				"quantity": map[string]interface{}{"type": []string{"string", "number"}, "description": "Quantity, as a decimal string such as \"0.01\" to keep precision"},
+			},
+		},
+		Handler: h.mcpPlaceCryptoOrderDryRun,
+	}))
+
+	return s
+}
+
+// mcpTool wraps a tool so its results are redacted for callers whose API
+// key requires it
+func (h *Handlers) mcpTool(tool mcp.Tool) mcp.Tool {
+	handler := tool.Handler
+	tool.Handler = func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		result, err := handler(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return mcpRedact(ctx, result)
+	}
+	return tool
+}
+
+// mcpResource wraps a resource reader the way mcpTool wraps a tool
+func (h *Handlers) mcpResource(read func(ctx context.Context) (interface{}, error)) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		result, err := read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return mcpRedact(ctx, result)
+	}
+}
+
+// mcpRedact redacts a result for callers whose API key requires it
+func mcpRedact(ctx context.Context, result interface{}) (interface{}, error) {
+	if p, ok := auth.PrincipalFromContext(ctx); !ok || !p.Redact {
+		return result, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	redacted, err := redact.JSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(redacted), nil
+}
+
+// mcpUser returns the user a tool call may act on, counting the call
+// towards their API call quota like an authorized REST request
+func (h *Handlers) mcpUser(ctx context.Context, requested, role string) (string, error) {
+	userID, err := h.userFor(ctx, requested, role)
+	if err != nil {
+		if errors.Is(err, errUserRequired) || errors.Is(err, errNotGranted) {
+			return "", err
+		}
+		slog.ErrorContext(ctx, "Failed to authorize MCP tool call", "error", err)
+		return "", errors.New("failed to check access grants")
+	}
+
+	if h.usage != nil {
+		_, err := h.usage.Consume(ctx, userID, usage.MetricAPICalls)
+		if errors.Is(err, usage.ErrQuotaExceeded) {
+			return "", errors.New("daily " + usage.MetricAPICalls + " quota exceeded")
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to meter usage", "metric", usage.MetricAPICalls, "error", err)
+		}
+	}
+	return userID, nil
+}
+
+// mcpDateRange checks a tool's start and end dates, defaulting to the last
+// 30 days in the user's timezone
+func (h *Handlers) mcpDateRange(ctx context.Context, userID, start, end string) (string, string, error) {
+	if start == "" || end == "" {
+		now, _ := h.userClock(ctx, userID)
+		if start == "" {
+			start = now.AddDate(0, 0, -30).Format("2006-01-02")
+		}
+		if end == "" {
+			end = now.Format("2006-01-02")
+		}
+	}
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return "", "", errors.New("start must be a date in YYYY-MM-DD format")
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return "", "", errors.New("end must be a date in YYYY-MM-DD format")
+	}
+	if to.Before(from) {
+		return "", "", errors.New("start must not be after end")
+	}
+	return start, end, nil
+}
+
+func decodeToolArgs(args json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+func (h *Handlers) mcpGetTransactions(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		UserID      string `json:"user_id"`
+		Start       string `json:"start"`
+		End         string `json:"end"`
+		Merchant    string `json:"merchant"`
+		Category    string `json:"category"`
+		Kind        string `json:"kind"`
+		CheckNumber string `json:"check_number"`
+		Limit       int    `json:"limit"`
+		Summary     bool   `json:"summary"`
+		Basis       string `json:"basis"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.Kind != "" && args.Kind != descriptors.KindCheck && args.Kind != descriptors.KindATM {
+		return nil, errors.New("kind must be 'check' or 'atm'")
+	}
+	if args.Limit <= 0 || args.Limit > 1000 {
+		args.Limit = 100
+	}
+	basis, ok := parseBasis(args.Basis)
+	if !ok {
+		return nil, errors.New("basis must be 'accrual' or 'cash'")
+	}
+
+	userID, err := h.mcpUser(ctx, args.UserID, auth.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := h.mcpDateRange(ctx, userID, args.Start, args.End)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := store.TransactionFilter{
+		UserID:      userID,
+		StartDate:   start,
+		EndDate:     end,
+		Merchant:    args.Merchant,
+		Category:    args.Category,
+		Kind:        args.Kind,
+		CheckNumber: strings.TrimLeft(args.CheckNumber, "0"),
+		Limit:       args.Limit,
+	}
+	if args.Summary {
+		filter.Limit = maxSummaryTransactions
+		filter.Analytics = true
+		filter = basisFilter(filter, basis)
+	}
+	transactions, err := h.listTransactions(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		return nil, errors.New("failed to query transactions")
+	}
+
+	if args.Summary {
+		return map[string]interface{}{
+			"summary":   summarizeSpending(applyBasis(transactions, basis, start, end), start, end, summaryTopN),
+			"basis":     basis,
+			"truncated": len(transactions) == maxSummaryTransactions,
+		}, nil
+	}
+	return map[string]interface{}{
+		"transactions": transactions,
+		"count":        len(transactions),
+		"start_date":   start,
+		"end_date":     end,
+	}, nil
+}
+
+func (h *Handlers) mcpGetSpendingSummary(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		UserID string `json:"user_id"`
+		Start  string `json:"start"`
+		End    string `json:"end"`
+		TopN   int    `json:"top_n"`
+		Basis  string `json:"basis"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.TopN <= 0 || args.TopN > 50 {
+		args.TopN = 10
+	}
+	basis, ok := parseBasis(args.Basis)
+	if !ok {
+		return nil, errors.New("basis must be 'accrual' or 'cash'")
+	}
+
+	userID, err := h.mcpUser(ctx, args.UserID, auth.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := h.mcpDateRange(ctx, userID, args.Start, args.End)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := h.listTransactions(ctx, basisFilter(store.TransactionFilter{
+		UserID:    userID,
+		StartDate: start,
+		EndDate:   end,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	}, basis))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		return nil, errors.New("failed to query transactions")
+	}
+
+	summary := summarizeSpending(applyBasis(transactions, basis, start, end), start, end, args.TopN)
+	return map[string]interface{}{
+		"summary":   summary,
+		"basis":     basis,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	}, nil
+}
+
+func (h *Handlers) mcpPlaceCryptoOrderDryRun(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		UserID   string          `json:"user_id"`
+		Symbol   string          `json:"symbol"`
+		Side     string          `json:"side"`
+		Quantity decimal.Decimal `json:"quantity"`
+	}
+	if err := decodeToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	// Orders need the trade scope even as dry runs, as on the REST API
+	if p, ok := auth.PrincipalFromContext(ctx); ok && !p.HasScope(auth.ScopeTrade) {
+		return nil, errors.New("insufficient scope: " + auth.ScopeTrade + " required")
+	}
+
+	userID, err := h.mcpUser(ctx, args.UserID, auth.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	dryRun := true
+	order, err := h.submitCryptoOrder(ctx, models.CryptoOrderRequest{
+		UserID:   userID,
+		Symbol:   args.Symbol,
+		Side:     args.Side,
+		Quantity: args.Quantity,
+		DryRun:   &dryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"order":   order,
+		"dry_run": true,
+		"message": h.getOrderMessage(true, order.Side, order.Symbol),
+	}, nil
+}
+
+func (h *Handlers) mcpReadAccounts(ctx context.Context) (interface{}, error) {
+	userID, err := h.mcpUser(ctx, "", auth.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := h.listAccounts(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
+		return nil, errors.New("failed to query accounts")
+	}
+
+	return map[string]interface{}{
+		"accounts": accounts,
+		"count":    len(accounts),
+	}, nil
+}
+
+func (h *Handlers) mcpReadNetWorth(ctx context.Context) (interface{}, error) {
+	userID, err := h.mcpUser(ctx, "", auth.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
+		return nil, errors.New("failed to query accounts")
+	}
+	positions, err := h.store.Orders.ListPositions(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list crypto positions", "error", err)
+		return nil, errors.New("failed to query crypto positions")
+	}
+
+	groups, err := h.store.Accounts.ListGroups(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list account groups", "error", err)
+		return nil, errors.New("failed to query account groups")
+	}
+
+	accountsSummary := summarizeAccounts(accounts)
+	crypto := summarizeCryptoPositions(positions, 0)
+	var asOf time.Time
+	for _, acc := range accounts {
+		if acc.Counted() && acc.UpdatedAt.After(asOf) {
+			asOf = acc.UpdatedAt
+		}
+	}
+	for _, pos := range positions {
+		if pos.LastRefresh.After(asOf) {
+			asOf = pos.LastRefresh
+		}
+	}
+
+	netWorth := map[string]interface{}{
+		"net_worth":     accountsSummary.Net.Add(crypto.TotalValue),
+		"assets":        accountsSummary.Assets,
+		"liabilities":   accountsSummary.Liabilities,
+		"crypto_value":  crypto.TotalValue,
+		"account_count": accountsSummary.AccountCount,
+		"groups":        summarizeAccountGroups(accounts, groups),
+	}
+	if !asOf.IsZero() {
+		netWorth["as_of"] = asOf
+	}
+	return netWorth, nil
+}
+
+// resourceChannel is the Redis channel announcing changes to a user's MCP
+// resources
+func resourceChannel(userID string) string {
+	return "mcp:resources:user:" + userID
+}
+
+// publishResourceChanges tells MCP sessions, in whichever process they
+// run, that a user's resources changed
+func (h *Handlers) publishResourceChanges(ctx context.Context, userID string, uris ...string) {
+	if h.redis == nil {
+		return
+	}
+	data, err := json.Marshal(uris)
+	if err != nil {
+		return
+	}
+	if err := h.redis.Publish(ctx, resourceChannel(userID), data).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish MCP resource changes", "user_id", userID, "error", err)
+	}
+}
+
+// watchMCPResources relays changes to the session user's resources. Callers
+// without a user of their own have nothing to watch.
+func (h *Handlers) watchMCPResources(ctx context.Context, changed chan<- string) error {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok || p.UserID == "" || h.redis == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	pubsub := h.redis.Subscribe(ctx, resourceChannel(p.UserID))
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to resource changes: %w", err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var uris []string
+			if err := json.Unmarshal([]byte(msg.Payload), &uris); err != nil {
+				slog.WarnContext(ctx, "Ignoring malformed resource change", "error", err)
+				continue
+			}
+			for _, uri := range uris {
+				select {
+				case changed <- uri:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/amortization"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+// SimulateMortgage models paying down a mortgage faster with extra
+// monthly principal, lump sums or a recast, returning the payoff month
+// and interest paid with and without them and what the plan saves. The
+// loan is a mortgage account's balance, or a principal given, at an
+// annual interest rate, with its monthly principal and interest payment
+// or the months left to pay it off:
+//
+//	{"account_id": "...", "interest_rate": "6.5", "remaining_months": 312,
+//	 "extra_monthly": "200", "lump_sums": [{"month": "2027-01", "amount": "10000"}],
+//	 "recast": {"month": "2027-06", "amount": "50000"}, "schedule": true}
+//
+// With schedule=true every month's payment is returned.
+func (h *Handlers) SimulateMortgage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID          string              `json:"user_id"`
+		AccountID       string              `json:"account_id"`
+		Principal       *decimal.Decimal    `json:"principal"`
+		InterestRate    *decimal.Decimal    `json:"interest_rate"`
+		MonthlyPayment  *decimal.Decimal    `json:"monthly_payment"`
+		RemainingMonths int                 `json:"remaining_months"`
+		StartMonth      string              `json:"start_month"`
+		ExtraMonthly    decimal.Decimal     `json:"extra_monthly"`
+		LumpSums        []amortization.Lump `json:"lump_sums"`
+		Recast          *amortization.Lump  `json:"recast"`
+		Schedule        bool                `json:"schedule"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	if req.InterestRate == nil {
+		h.respondError(w, http.StatusBadRequest, "interest_rate is required")
+		return
+	}
+	loan := amortization.Loan{Rate: *req.InterestRate}
+
+	switch {
+	case req.AccountID != "" && req.Principal != nil:
+		h.respondError(w, http.StatusBadRequest, "Give either account_id or principal, not both")
+		return
+	case req.AccountID != "":
+		account, err := h.store.Accounts.Get(ctx, req.AccountID, userID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Account not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to query account", "user_id", userID, "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+			return
+		}
+		if account.Type != "loan" || account.BalanceCurrent == nil {
+			h.respondError(w, http.StatusBadRequest, "Account must be a loan with a balance")
+			return
+		}
+		loan.Principal = *account.BalanceCurrent
+	case req.Principal != nil:
+		loan.Principal = *req.Principal
+	default:
+		h.respondError(w, http.StatusBadRequest, "account_id or principal is required")
+		return
+	}
+
+	switch {
+	case req.MonthlyPayment != nil:
+		loan.Payment = *req.MonthlyPayment
+	case req.RemainingMonths >= 1 && req.RemainingMonths <= amortization.MaxMonths:
+		loan.Payment = amortization.Payment(loan.Principal, loan.Rate, req.RemainingMonths)
+	default:
+		h.respondError(w, http.StatusBadRequest, "monthly_payment, or remaining_months from 1 to 600, is required")
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	loan.Start = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	if req.StartMonth != "" {
+		start, err := time.Parse(amortization.MonthLayout, req.StartMonth)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "start_month must be YYYY-MM")
+			return
+		}
+		loan.Start = start
+	}
+
+	plan := amortization.Plan{ExtraMonthly: req.ExtraMonthly, Lumps: req.LumpSums, Recast: req.Recast}
+	if err := amortization.Validate(loan, plan); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	simulation, err := amortization.Simulate(loan, plan)
+	if errors.Is(err, amortization.ErrPaymentTooLow) {
+		h.respondError(w, http.StatusUnprocessableEntity, "The monthly payment does not cover the interest")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to simulate mortgage")
+		return
+	}
+
+	simulation.Baseline.Rows = nil
+	if !req.Schedule {
+		simulation.Scenario.Rows = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"principal":     loan.Principal,
+		"interest_rate": loan.Rate,
+		"start_month":   loan.Start.Format(amortization.MonthLayout),
+		"simulation":    simulation,
+	})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/paychecks"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+)
+
+// syncPayStubs stores the pay stubs of an item consented to income
+// verification
+func (h *Handlers) syncPayStubs(ctx context.Context, userID, plaidItemID, accessToken string) error {
+	stubs, err := h.plaidClient.GetPayStubs(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pay stubs: %w", err)
+	}
+	added, err := h.paychecks.StoreStubs(ctx, userID, plaidItemID, stubs)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		slog.InfoContext(ctx, "Stored pay stubs", "user_id", userID, "count", added)
+	}
+	return nil
+}
+
+// GetPaychecks returns a year's paychecks, by default this year's, with
+// totals to date and by month. Payroll deposits are broken down into
+// gross pay, taxes, benefits and other deductions where a pay stub from
+// an income provider matches them. With summary=true only the totals are
+// returned.
+func (h *Handlers) GetPaychecks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	year := now.Year()
+	if s := r.URL.Query().Get("year"); s != "" {
+		y, err := strconv.Atoi(s)
+		if err != nil || y < 2000 || y > now.Year() {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("year must be from 2000 to %d", now.Year()))
+			return
+		}
+		year = y
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	startDate, endDate := start.Format(period.DateLayout), end.Format(period.DateLayout)
+
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	stubs, err := h.paychecks.ListStubs(ctx, userID, startDate, endDate)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list pay stubs", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query pay stubs")
+		return
+	}
+
+	report := paychecks.Report(year, now, paychecks.Build(transactions, stubs))
+	if wantSummary(r) {
+		report.Paychecks = nil
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"report":    report,
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
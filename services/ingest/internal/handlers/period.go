@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+)
+
+// ResolvePeriod converts a natural date range expression in expr, such as
+// "last quarter" or "past 90 days", into start and end dates (YYYY-MM-DD)
+// as of today in the IANA timezone tz. Without tz, the user's timezone,
+// week start and fiscal year preferences apply, and expr may name one of
+// their own periods, such as "last pay period".
+func (h *Handlers) ResolvePeriod(w http.ResponseWriter, r *http.Request) {
+	expr := r.URL.Query().Get("expr")
+	if expr == "" {
+		h.respondError(w, http.StatusBadRequest, "expr is required")
+		return
+	}
+
+	var now time.Time
+	cal := period.Calendar{WeekStart: time.Monday}
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "tz must be an IANA timezone, such as America/New_York")
+			return
+		}
+		now = time.Now().In(loc)
+	} else {
+		userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+		if !ok {
+			return
+		}
+		now, cal = h.userCalendar(r.Context(), userID)
+	}
+
+	resolved, err := cal.Resolve(expr, now)
+	if errors.Is(err, period.ErrUnrecognized) {
+		h.respondError(w, http.StatusBadRequest, "Unrecognized period; try \"last month\", \"YTD\", \"past 90 days\" or \"Q2 2024\"")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"expr":  expr,
+		"label": resolved.Label,
+		"period": models.Period{
+			StartDate: resolved.Start.Format(period.DateLayout),
+			EndDate:   resolved.End.Format(period.DateLayout),
+			Days:      resolved.Days(),
+		},
+		"timezone": now.Location().String(),
+		"today":    now.Format(period.DateLayout),
+	})
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import "context"
+
+// decryptPII decrypts nullable PII columns in place after they are scanned.
+// Rows written before field encryption was enabled are passed through as-is.
+func (h *Handlers) decryptPII(ctx context.Context, fields ...**string) error {
+	for _, field := range fields {
+		decrypted, err := h.encryption.DecryptStringPtr(ctx, *field)
+		if err != nil {
+			return err
+		}
+		*field = decrypted
+	}
+	return nil
+}
@@ -3,13 +3,32 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/metrics"
 	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/finagent/ingest/internal/yield"
+	"github.com/shopspring/decimal"
 )
 
+// lowBalanceThreshold triggers a balance.low event for depository accounts
+var lowBalanceThreshold = decimal.NewFromInt(100)
+
+// transactionBackfillDays is how far back the first transaction sync reaches
+const transactionBackfillDays = 730
+
 // PlaidWebhook handles incoming Plaid webhooks
 func (h *Handlers) PlaidWebhook(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -20,8 +39,17 @@ func (h *Handlers) PlaidWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log the webhook for debugging
-	fmt.Printf("Received Plaid webhook: %+v\n", webhook)
+	slog.DebugContext(r.Context(), "Received Plaid webhook", "webhook_type", webhook.WebhookType,
+		"webhook_code", webhook.WebhookCode, "item_id", webhook.ItemID)
+
+	// Acknowledge but discard, like a delivery Plaid never made
+	if h.faults.DropWebhook(ctx) {
+		h.respondSuccess(w, map[string]interface{}{
+			"acknowledged": true,
+			"webhook_code": webhook.WebhookCode,
+		})
+		return
+	}
 
 	// Handle different webhook types
 	switch webhook.WebhookType {
@@ -35,10 +63,15 @@ func (h *Handlers) PlaidWebhook(w http.ResponseWriter, r *http.Request) {
 			h.respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to handle item webhook: %v", err))
 			return
 		}
+	case "TRANSFER":
+		if err := h.handleTransferWebhook(ctx, webhook); err != nil {
+			h.respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to handle transfer webhook: %v", err))
+			return
+		}
 	case "ASSETS":
 		// Handle assets webhook if needed
 	default:
-		fmt.Printf("Unhandled webhook type: %s\n", webhook.WebhookType)
+		slog.WarnContext(r.Context(), "Unhandled webhook type", "webhook_type", webhook.WebhookType)
 	}
 
 	// Acknowledge webhook
@@ -49,19 +82,21 @@ func (h *Handlers) PlaidWebhook(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) handleTransactionWebhook(ctx context.Context, webhook models.PlaidWebhook) error {
-	// Create sync job
-	jobID, err := h.createSyncJob(ctx, webhook.ItemID, "TRANSACTIONS")
+	// Queue the sync as a job so it survives restarts and can be polled.
+	// Plaid's item_id is not our item's ID, so it travels in the input.
+	_, err := h.jobs.Enqueue(ctx, jobs.Params{
+		Type: jobs.TypeTransactionsWebhook,
+		Input: map[string]interface{}{
+			"plaid_item_id":        webhook.ItemID,
+			"webhook_code":         webhook.WebhookCode,
+			"removed_transactions": webhook.RemovedTransactions,
+			"received_at":          time.Now().UTC(),
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create sync job: %w", err)
 	}
 
-	// Process sync job asynchronously
-	go func() {
-		if err := h.processSyncJob(context.Background(), jobID); err != nil {
-			fmt.Printf("Failed to process sync job %s: %v\n", jobID, err)
-		}
-	}()
-
 	return nil
 }
 
@@ -70,14 +105,9 @@ func (h *Handlers) handleItemWebhook(ctx context.Context, webhook models.PlaidWe
 	switch webhook.WebhookCode {
 	case "ERROR":
 		// Update item status to error
-		_, err := h.db.Pool.Exec(ctx,
-			"UPDATE plaid_items SET status = 'error', updated_at = NOW() WHERE access_token_enc = $1",
-			webhook.ItemID, // This would need to be properly mapped
-		)
-		return err
+		return h.store.Items.MarkError(ctx, webhook.ItemID)
 	case "PENDING_EXPIRATION":
-		// Handle pending expiration
-		fmt.Printf("Item %s is pending expiration\n", webhook.ItemID)
+		return h.handleConsentExpiring(ctx, webhook)
 	}
 	return nil
 }
@@ -91,10 +121,15 @@ func (h *Handlers) ExchangePublicToken(w http.ResponseWriter, r *http.Request) {
 		UserID      string `json:"user_id"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request payload")
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
 		return
 	}
+	req.UserID = userID
 
 	if req.PublicToken == "" || req.UserID == "" {
 		h.respondError(w, http.StatusBadRequest, "public_token and user_id are required")
@@ -102,52 +137,67 @@ func (h *Handlers) ExchangePublicToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Exchange public token for access token via Plaid
-	accessToken, itemID, err := h.plaidClient.ExchangePublicToken(req.PublicToken)
+	accessToken, itemID, err := h.plaidClient.ExchangePublicToken(ctx, req.PublicToken)
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to exchange token: %v", err))
 		return
 	}
 
 	// Encrypt access token
-	encryptedToken, err := h.plaidClient.EncryptToken(accessToken)
+	encryptedToken, err := h.plaidClient.EncryptToken(ctx, accessToken)
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to encrypt token")
 		return
 	}
 
 	// Get institution info
-	institution, err := h.plaidClient.GetInstitution(itemID)
+	institution, err := h.plaidClient.GetInstitution(ctx, itemID)
 	if err != nil {
-		fmt.Printf("Failed to get institution info: %v\n", err)
+		slog.WarnContext(ctx, "Failed to get institution info", "error", err)
 		// Continue without institution info
 	}
 
+	// The item is only synced for what the user consented to, so without
+	// knowing that it is not stored
+	consent, err := h.plaidClient.GetItem(ctx, accessToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get item consent", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get item consent")
+		return
+	}
+
 	// Store Plaid item in database
-	query := `
-		INSERT INTO plaid_items (user_id, access_token_enc, institution_id, institution_name, status)
-		VALUES ($1, $2, $3, $4, 'active')
-		RETURNING id
-	`
-
-	var plaidItemID string
-	err = h.db.Pool.QueryRow(ctx, query, req.UserID, encryptedToken,
-		getStringValue(institution, "institution_id"),
-		getStringValue(institution, "name")).Scan(&plaidItemID)
+	plaidItemID, err := h.store.Items.Create(ctx, store.NewItem{
+		UserID:           req.UserID,
+		PlaidItemID:      itemID,
+		EncryptedToken:   encryptedToken,
+		InstitutionID:    getStringValue(institution, "institution_id"),
+		InstitutionName:  getStringValue(institution, "name"),
+		Products:         consent.ConsentedProducts,
+		ConsentExpiresAt: consent.ConsentExpirationTime,
+	})
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to store Plaid item")
 		return
 	}
+	if err := h.store.Users.AdvanceOnboarding(ctx, req.UserID, models.OnboardingRegistered, models.OnboardingLinked); err != nil {
+		slog.ErrorContext(ctx, "Failed to update onboarding state", "error", err)
+	}
 
 	// Trigger initial sync
-	go func() {
-		if err := h.syncPlaidData(context.Background(), req.UserID, plaidItemID, accessToken); err != nil {
-			fmt.Printf("Failed to sync initial Plaid data: %v\n", err)
-		}
-	}()
+	jobID, err := h.jobs.Enqueue(ctx, jobs.Params{
+		UserID:      req.UserID,
+		PlaidItemID: plaidItemID,
+		Type:        jobs.TypeInitialSync,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to start initial sync", "error", err)
+	}
 
 	h.respondSuccess(w, map[string]interface{}{
 		"item_id":     plaidItemID,
 		"institution": institution,
+		"job_id":      jobID,
 		"message":     "Successfully linked account, syncing data...",
 	})
 }
@@ -158,17 +208,22 @@ func (h *Handlers) CreateLinkToken(w http.ResponseWriter, r *http.Request) {
 		UserID string `json:"user_id"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request payload")
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
 		return
 	}
+	req.UserID = userID
 
 	if req.UserID == "" {
 		h.respondError(w, http.StatusBadRequest, "user_id is required")
 		return
 	}
 
-	linkToken, expiration, err := h.plaidClient.CreateLinkToken(req.UserID)
+	linkToken, expiration, err := h.plaidClient.CreateLinkToken(r.Context(), req.UserID)
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create link token: %v", err))
 		return
@@ -189,147 +244,373 @@ func (h *Handlers) ManualSync(w http.ResponseWriter, r *http.Request) {
 		PlaidItemID string `json:"plaid_item_id"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request payload")
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
 		return
 	}
+	req.UserID = userID
 
 	if req.UserID == "" || req.PlaidItemID == "" {
 		h.respondError(w, http.StatusBadRequest, "user_id and plaid_item_id are required")
 		return
 	}
 
-	// Get encrypted access token
-	var encryptedToken []byte
-	err := h.db.Pool.QueryRow(ctx,
-		"SELECT access_token_enc FROM plaid_items WHERE id = $1 AND user_id = $2",
-		req.PlaidItemID, req.UserID).Scan(&encryptedToken)
-	if err != nil {
-		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+	if !h.checkUsage(w, r, req.UserID, usage.MetricSyncs) {
 		return
 	}
 
-	// Decrypt access token
-	accessToken, err := h.plaidClient.DecryptToken(encryptedToken)
+	// Make sure the item exists and belongs to the user
+	_, err := h.store.Items.AccessToken(ctx, req.PlaidItemID, req.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+		return
+	}
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to decrypt token")
+		h.respondError(w, http.StatusInternalServerError, "Failed to query Plaid item")
 		return
 	}
 
-	// Create sync job
-	jobID, err := h.createSyncJob(ctx, req.PlaidItemID, "MANUAL_SYNC")
+	// Queue the sync job
+	jobID, err := h.jobs.Enqueue(ctx, jobs.Params{
+		UserID:      req.UserID,
+		PlaidItemID: req.PlaidItemID,
+		Type:        jobs.TypeManualSync,
+	})
 	if err != nil {
 		h.respondError(w, http.StatusInternalServerError, "Failed to create sync job")
 		return
 	}
 
-	// Process sync job asynchronously
-	go func() {
-		if err := h.syncPlaidData(context.Background(), req.UserID, req.PlaidItemID, accessToken); err != nil {
-			fmt.Printf("Failed to sync Plaid data: %v\n", err)
-			// Update job status to failed
-			h.updateSyncJob(context.Background(), jobID, "failed", err.Error())
-		} else {
-			// Update job status to completed
-			h.updateSyncJob(context.Background(), jobID, "completed", "")
-		}
-	}()
-
 	h.respondSuccess(w, map[string]interface{}{
 		"job_id":  jobID,
 		"message": "Sync job started",
 	})
 }
 
-func (h *Handlers) createSyncJob(ctx context.Context, itemID, jobType string) (string, error) {
-	var jobID string
-	err := h.db.Pool.QueryRow(ctx,
-		`INSERT INTO sync_jobs (plaid_item_id, job_type, status, started_at)
-		 VALUES ($1, $2, 'running', NOW())
-		 RETURNING id`,
-		itemID, jobType).Scan(&jobID)
-	return jobID, err
+// itemSyncTask syncs one Plaid item and notifies subscribers. The access
+// token is loaded when the job runs so it is never stored in the queue.
+func (h *Handlers) itemSyncTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	if task.UserID == "" || task.PlaidItemID == "" {
+		return nil, jobs.Permanent(fmt.Errorf("job is missing user or item reference"))
+	}
+
+	ctx, unlock, err := h.lockItem(ctx, task.PlaidItemID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return h.syncItem(ctx, task.UserID, task.PlaidItemID, progress)
 }
 
-func (h *Handlers) updateSyncJob(ctx context.Context, jobID, status, errorMsg string) error {
-	_, err := h.db.Pool.Exec(ctx,
-		`UPDATE sync_jobs 
-		 SET status = $2, completed_at = NOW(), error_message = $3
-		 WHERE id = $1`,
-		jobID, status, errorMsg)
-	return err
+// syncItem syncs a user's item, which the caller has locked, and runs the
+// post-sync analyses
+func (h *Handlers) syncItem(ctx context.Context, userID, itemID string, progress *jobs.Progress) (map[string]interface{}, error) {
+	encryptedToken, err := h.store.Items.AccessToken(ctx, itemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, jobs.Permanent(fmt.Errorf("plaid item not found"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := h.plaidClient.DecryptToken(ctx, encryptedToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+
+	consent, err := h.store.Items.Consent(ctx, itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.syncPlaidData(ctx, userID, itemID, accessToken, *consent, progress)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to sync Plaid data", "error", err)
+		return nil, err
+	}
+	h.recordUsage(ctx, userID, usage.MetricSyncs)
+	h.scanDuplicates(ctx, userID)
+	h.applyExpenseRules(ctx, userID)
+	h.matchVests(ctx, userID)
+	h.invalidateCache(ctx, userID)
+	h.generateRecommendations(ctx, userID)
+	h.evaluateYield(ctx, userID)
+	h.publishResourceChanges(ctx, userID, resourceAccounts, resourceNetWorthLatest)
+
+	h.publishEvent(ctx, userID, webhooks.EventSyncCompleted, map[string]interface{}{
+		"job_id":        progress.JobID(),
+		"plaid_item_id": itemID,
+	})
+	return result, nil
 }
 
-func (h *Handlers) processSyncJob(ctx context.Context, jobID string) error {
-	// This would implement the actual sync logic
-	// For now, just update the job status
-	time.Sleep(2 * time.Second) // Simulate processing time
-	return h.updateSyncJob(ctx, jobID, "completed", "")
+// transactionWebhookTask handles a queued Plaid transactions webhook
+func (h *Handlers) transactionWebhookTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	var input struct {
+		PlaidItemID         string    `json:"plaid_item_id"`
+		WebhookCode         string    `json:"webhook_code"`
+		RemovedTransactions []string  `json:"removed_transactions"`
+		ReceivedAt          time.Time `json:"received_at"`
+	}
+	if err := task.DecodeInput(&input); err != nil {
+		return nil, err
+	}
+
+	// Webhooks name the item by Plaid's item_id; lock on our ID for it, as
+	// the other syncs do, so they never overlap. Items we do not know are
+	// ignored.
+	if input.PlaidItemID == "" {
+		return nil, nil
+	}
+	itemID, userID, err := h.store.Items.ItemID(ctx, input.PlaidItemID)
+	if errors.Is(err, store.ErrNotFound) {
+		slog.WarnContext(ctx, "Transactions webhook for unknown item", "plaid_item_id", input.PlaidItemID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := h.lockItem(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Removed transactions are soft-deleted so their history is kept. Only
+	// the item's own transactions are touched.
+	if input.WebhookCode == "TRANSACTIONS_REMOVED" && len(input.RemovedTransactions) > 0 {
+		removed, err := h.store.Transactions.MarkRemoved(ctx, itemID, input.RemovedTransactions)
+		if err != nil {
+			return nil, err
+		}
+		h.observeWebhookLag(ctx, userID, input.PlaidItemID, input.WebhookCode, input.ReceivedAt)
+		progress.Update(ctx, 100, removed)
+		return map[string]interface{}{"transactions_removed": removed}, nil
+	}
+
+	result, err := h.syncItem(ctx, userID, itemID, progress)
+	if err != nil {
+		return nil, err
+	}
+	h.observeWebhookLag(ctx, userID, input.PlaidItemID, input.WebhookCode, input.ReceivedAt)
+	return result, nil
 }
 
-func (h *Handlers) syncPlaidData(ctx context.Context, userID, plaidItemID, accessToken string) error {
-	// Sync accounts
-	if err := h.syncAccounts(ctx, userID, plaidItemID, accessToken); err != nil {
-		return fmt.Errorf("failed to sync accounts: %w", err)
+// observeWebhookLag records how long a webhook took from receipt to its
+// data being committed, alerting the item's owner when that breaches the
+// lag SLO. Webhooks queued before receipt times were recorded are skipped.
+func (h *Handlers) observeWebhookLag(ctx context.Context, userID, plaidItemID, webhookCode string, receivedAt time.Time) {
+	if receivedAt.IsZero() {
+		return
+	}
+	now := time.Now()
+	lag := now.Sub(receivedAt)
+	breached := h.webhookLagSLO > 0 && lag > h.webhookLagSLO
+	metrics.ObserveWebhookLag(lag, breached)
+	if breached {
+		slog.WarnContext(ctx, "Plaid webhook lag exceeded SLO", "plaid_item_id", plaidItemID,
+			"webhook_code", webhookCode, "lag", lag.String(), "slo", h.webhookLagSLO.String())
+		h.alertWebhookLag(ctx, userID, plaidItemID, webhookCode, lag)
 	}
 
-	// Sync transactions
-	if err := h.syncTransactions(ctx, userID, accessToken); err != nil {
-		return fmt.Errorf("failed to sync transactions: %w", err)
+	err := h.store.Items.RecordWebhookLag(ctx, store.WebhookLagSample{
+		PlaidItemID: plaidItemID,
+		WebhookCode: webhookCode,
+		ReceivedAt:  receivedAt,
+		PersistedAt: now,
+		SLOBreached: breached,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook lag", "error", err)
 	}
+}
 
-	// Sync investments if available
-	if err := h.syncInvestments(ctx, userID, accessToken); err != nil {
-		fmt.Printf("Failed to sync investments (may not be available): %v\n", err)
-		// Don't fail the entire sync for investments
+// alertWebhookLag notifies a user that an update from their bank was late
+// reaching their accounts
+func (h *Handlers) alertWebhookLag(ctx context.Context, userID, plaidItemID, webhookCode string, lag time.Duration) {
+	if h.notify == nil {
+		return
+	}
+	err := h.notify.Create(ctx, &models.Notification{
+		UserID:  userID,
+		Type:    notifications.TypeAlert,
+		Title:   "Account updates delayed",
+		Message: fmt.Sprintf("An update from your bank took %s to reach your accounts, longer than the usual %s.", lag.Round(time.Second), h.webhookLagSLO),
+		Metadata: map[string]interface{}{
+			"plaid_item_id": plaidItemID,
+			"webhook_code":  webhookCode,
+			"lag_seconds":   int64(lag.Seconds()),
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to notify user of webhook lag", "user_id", userID, "error", err)
 	}
+}
 
-	return nil
+// lockItem takes the sync lock for one Plaid item, by our ID for it rather
+// than Plaid's item_id, so overlapping syncs cannot interleave writes to
+// its accounts and cursor. While another sync holds it the job is
+// deferred with jobs.Busy, which does not use up its attempts. The
+// returned context is cancelled if the lock is lost mid-sync.
+func (h *Handlers) lockItem(ctx context.Context, itemID string) (context.Context, func(), error) {
+	if h.locks == nil {
+		return ctx, func() {}, nil
+	}
+
+	lock, err := h.locks.Acquire(ctx, "plaid_item:"+itemID)
+	if errors.Is(err, locks.ErrLocked) {
+		return nil, nil, jobs.Busy(fmt.Errorf("item %s is being synced: %w", itemID, err))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock item %s: %w", itemID, err)
+	}
+
+	unlock := func() {
+		if err := lock.Release(context.Background()); err != nil {
+			slog.ErrorContext(ctx, "Failed to release sync lock", "error", err)
+		}
+	}
+	return lock.Context(), unlock, nil
 }
 
-func (h *Handlers) syncAccounts(ctx context.Context, userID, plaidItemID, accessToken string) error {
-	accounts, err := h.plaidClient.GetAccounts(accessToken)
+// syncPlaidData fetches an item's accounts and transactions from Plaid, then
+// writes them in one transaction that also advances the item's sync
+// watermark, so a failed sync leaves neither partial data nor a moved cursor.
+// Transactions and investments are only read when the item's consent
+// covers them.
+func (h *Handlers) syncPlaidData(ctx context.Context, userID, plaidItemID, accessToken string, consent models.ItemConsent, progress *jobs.Progress) (map[string]interface{}, error) {
+	accounts, err := h.plaidClient.GetAccounts(ctx, accessToken)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to fetch accounts: %w", err)
 	}
 
-	for _, account := range accounts {
-		// Upsert account
-		_, err := h.db.Pool.Exec(ctx, `
-			INSERT INTO accounts (id, user_id, plaid_item_id, name, mask, official_name, 
-								type, subtype, currency, balance_current, balance_available, 
-								balance_limit, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
-			ON CONFLICT (id) 
-			DO UPDATE SET 
-				name = EXCLUDED.name,
-				balance_current = EXCLUDED.balance_current,
-				balance_available = EXCLUDED.balance_available,
-				balance_limit = EXCLUDED.balance_limit,
-				updated_at = NOW()
-		`, account.ID, userID, plaidItemID, account.Name, account.Mask,
-			account.OfficialName, account.Type, account.Subtype, getIsoCurrency(account.Balances),
-			account.Balances.Current, account.Balances.Available, account.Balances.Limit)
+	var transactions []models.PlaidTransaction
+	var cursor string
+	if consent.Consented(plaid.ProductTransactions) {
+		endDate := time.Now()
+		startDate := endDate.AddDate(0, 0, -transactionBackfillDays)
+		transactions, cursor, err = h.plaidClient.GetTransactions(ctx, accessToken, startDate, endDate, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+		}
+	}
+	progress.Update(ctx, 40, len(accounts))
 
+	var transactionCount int
+	err = h.db.InTx(ctx, func(ctx context.Context) error {
+		if err := h.storeAccounts(ctx, userID, plaidItemID, accounts); err != nil {
+			return fmt.Errorf("failed to sync accounts: %w", err)
+		}
+
+		count, err := h.store.Transactions.UpsertBatch(ctx, userID, transactions)
 		if err != nil {
-			return fmt.Errorf("failed to upsert account %s: %w", account.ID, err)
+			return fmt.Errorf("failed to sync transactions: %w", err)
+		}
+		transactionCount = count
+
+		// Investments and pay stubs are optional, so they sync in
+		// savepoints whose failure doesn't roll back the rest
+		if consent.Consented(plaid.ProductInvestments) {
+			err = h.db.InTx(ctx, func(ctx context.Context) error {
+				return h.syncInvestments(ctx, userID, accessToken)
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to sync investments (may not be available)", "error", err)
+			}
+		}
+		if consent.Consented(plaid.ProductIncomeVerification) {
+			err = h.db.InTx(ctx, func(ctx context.Context) error {
+				return h.syncPayStubs(ctx, userID, plaidItemID, accessToken)
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to sync pay stubs", "error", err)
+			}
 		}
+
+		return h.store.Items.RecordSync(ctx, plaidItemID, cursor)
+	})
+	if err != nil {
+		return nil, err
 	}
+	progress.Update(ctx, 80, len(accounts))
 
-	return nil
+	h.publishLowBalances(ctx, userID, accounts)
+	h.evaluateAlerts(ctx, userID, accounts, transactions)
+
+	return map[string]interface{}{
+		"accounts_synced":     len(accounts),
+		"transactions_synced": transactionCount,
+	}, nil
 }
 
-func (h *Handlers) syncTransactions(ctx context.Context, userID, accessToken string) error {
-	// This would implement transaction syncing with cursor-based pagination
-	// For now, just a placeholder
-	fmt.Printf("Syncing transactions for user %s\n", userID)
-	return nil
+// storeAccounts upserts all of an item's accounts in one batch with their
+// PII encrypted
+func (h *Handlers) storeAccounts(ctx context.Context, userID, plaidItemID string, accounts []models.PlaidAccount) error {
+	stored := make([]models.PlaidAccount, len(accounts))
+	for i, account := range accounts {
+		mask, err := h.encryption.EncryptStringPtr(ctx, account.Mask)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt mask for account %s: %w", account.ID, err)
+		}
+		officialName, err := h.encryption.EncryptStringPtr(ctx, account.OfficialName)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt official name for account %s: %w", account.ID, err)
+		}
+		stored[i] = account
+		stored[i].Mask = mask
+		stored[i].OfficialName = officialName
+		if account.Type == "depository" {
+			stored[i].APY = yield.FromName(account.OfficialName)
+		}
+	}
+	return h.store.Accounts.UpsertBatch(ctx, userID, plaidItemID, stored)
+}
+
+// publishLowBalances sends balance.low events for synced depository
+// accounts below the threshold
+func (h *Handlers) publishLowBalances(ctx context.Context, userID string, accounts []models.PlaidAccount) {
+	for _, account := range accounts {
+		if account.Type == "depository" && account.Balances.Available != nil && account.Balances.Available.LessThan(lowBalanceThreshold) {
+			h.publishEvent(ctx, userID, webhooks.EventBalanceLow, map[string]interface{}{
+				"account_id":        account.ID,
+				"account_name":      account.Name,
+				"balance_available": *account.Balances.Available,
+				"threshold":         lowBalanceThreshold,
+			})
+		}
+	}
+}
+
+// invalidateCache drops a user's cached reads after their data changed
+func (h *Handlers) invalidateCache(ctx context.Context, userID string) {
+	if h.cache == nil {
+		return
+	}
+	if err := h.cache.Invalidate(ctx, userID); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate cache", "user_id", userID, "error", err)
+	}
+}
+
+// publishEvent notifies webhook subscribers, logging rather than failing on errors
+func (h *Handlers) publishEvent(ctx context.Context, userID, eventType string, data interface{}) {
+	if h.webhooks == nil {
+		return
+	}
+	if err := h.webhooks.Publish(ctx, userID, eventType, data); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish event", "event_type", eventType, "error", err)
+	}
 }
 
 func (h *Handlers) syncInvestments(ctx context.Context, userID, accessToken string) error {
 	// This would implement investment syncing
 	// For now, just a placeholder
-	fmt.Printf("Syncing investments for user %s\n", userID)
+	slog.DebugContext(ctx, "Syncing investments", "user_id", userID)
 	return nil
 }
 
@@ -344,14 +625,3 @@ func getStringValue(data interface{}, key string) string {
 	}
 	return ""
 }
-
-// getIsoCurrency extracts currency from PlaidBalance
-func getIsoCurrency(balance models.PlaidBalance) string {
-	if balance.IsoCurrencyCode != nil {
-		return *balance.IsoCurrencyCode
-	}
-	if balance.UnofficialCurrencyCode != nil {
-		return *balance.UnofficialCurrencyCode
-	}
-	return "USD" // default
-}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPeriodDefinitions bounds the custom periods a user may define
+const maxPeriodDefinitions = 20
+
+// numberFormats are the supported ways of writing 1234.56
+var numberFormats = []string{"1,234.56", "1.234,56", "1 234,56", "1'234.56", "1234.56"}
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// GetPreferences returns a user's timezone, locale, digest, fiscal year and
+// budget period preferences, or the defaults if they have set none
+func (h *Handlers) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.store.Preferences.Get(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to query preferences", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query preferences")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"preferences": prefs,
+	})
+}
+
+// UpdatePreferences changes the preferences given in the body, keeping the
+// others
+func (h *Handlers) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID          string  `json:"user_id"`
+		Timezone        *string `json:"timezone"`
+		BaseCurrency    *string `json:"base_currency"`
+		WeekStart       *string `json:"week_start"`
+		NumberFormat    *string `json:"number_format"`
+		DigestFrequency *string `json:"digest_frequency"`
+		FiscalYearStart *int    `json:"fiscal_year_start"`
+		BudgetPeriod    *string `json:"budget_period"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.store.Preferences.Get(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query preferences", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query preferences")
+		return
+	}
+
+	if req.Timezone != nil {
+		loc, err := time.LoadLocation(*req.Timezone)
+		if err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			h.respondError(w, http.StatusBadRequest, "timezone must be an IANA timezone, such as America/New_York")
+			return
+		}
+		prefs.Timezone = loc.String()
+	}
+	if req.BaseCurrency != nil {
+		currency := strings.ToUpper(*req.BaseCurrency)
+		if !currencyCodePattern.MatchString(currency) {
+			h.respondError(w, http.StatusBadRequest, "base_currency must be an ISO 4217 code, such as USD")
+			return
+		}
+		prefs.BaseCurrency = currency
+	}
+	if req.WeekStart != nil {
+		weekStart := strings.ToLower(*req.WeekStart)
+		if _, ok := period.WeekStarts[weekStart]; !ok {
+			h.respondError(w, http.StatusBadRequest, "week_start must be 'monday', 'sunday' or 'saturday'")
+			return
+		}
+		prefs.WeekStart = weekStart
+	}
+	if req.NumberFormat != nil {
+		if !isNumberFormat(*req.NumberFormat) {
+			h.respondError(w, http.StatusBadRequest, "number_format must be one of "+strings.Join(numberFormats, " | "))
+			return
+		}
+		prefs.NumberFormat = *req.NumberFormat
+	}
+	if req.DigestFrequency != nil {
+		frequency := strings.ToLower(*req.DigestFrequency)
+		if !isDigestFrequency(frequency) {
+			h.respondError(w, http.StatusBadRequest, "digest_frequency must be one of "+strings.Join(models.DigestFrequencies, ", "))
+			return
+		}
+		prefs.DigestFrequency = frequency
+	}
+	if req.FiscalYearStart != nil {
+		if *req.FiscalYearStart < 1 || *req.FiscalYearStart > 12 {
+			h.respondError(w, http.StatusBadRequest, "fiscal_year_start must be the month the fiscal year starts, 1 to 12")
+			return
+		}
+		prefs.FiscalYearStart = *req.FiscalYearStart
+	}
+	if req.BudgetPeriod != nil {
+		prefs.BudgetPeriod = nil
+		if name := strings.TrimSpace(*req.BudgetPeriod); name != "" {
+			defs, err := h.store.Periods.List(ctx, userID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to query period definitions", "user_id", userID, "error", err)
+				h.respondError(w, http.StatusInternalServerError, "Failed to query period definitions")
+				return
+			}
+			for _, d := range defs {
+				if strings.EqualFold(d.Name, name) {
+					prefs.BudgetPeriod = &d.Name
+					break
+				}
+			}
+			if prefs.BudgetPeriod == nil {
+				h.respondError(w, http.StatusBadRequest, "budget_period must name one of your period definitions, or be empty for calendar months")
+				return
+			}
+		}
+	}
+
+	if err := h.store.Preferences.Upsert(ctx, &prefs); err != nil {
+		slog.ErrorContext(ctx, "Failed to store preferences", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update preferences")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"preferences": prefs,
+	})
+}
+
+func isNumberFormat(format string) bool {
+	for _, f := range numberFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigestFrequency(frequency string) bool {
+	for _, f := range models.DigestFrequencies {
+		if f == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+// userClock returns the current time in the user's timezone and the day
+// their weeks start, so "today" and calendar buckets follow the user
+// rather than the server. Lookup failures fall back to UTC weeks starting
+// on Monday.
+func (h *Handlers) userClock(ctx context.Context, userID string) (time.Time, time.Weekday) {
+	prefs, err := h.store.Preferences.Get(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to query preferences; using UTC", "user_id", userID, "error", err)
+		return time.Now().UTC(), time.Monday
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return time.Now().In(loc), period.WeekStarts[prefs.WeekStart]
+}
+
+// userCalendar returns the current time in the user's timezone and their
+// calendar: the day their weeks start, their fiscal year, their period
+// definitions and the one budgets run on. Period expressions resolved on
+// it may name the user's own periods, such as "last pay period". Lookup
+// failures fall back to UTC and calendar periods.
+func (h *Handlers) userCalendar(ctx context.Context, userID string) (time.Time, period.Calendar) {
+	prefs, err := h.store.Preferences.Get(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to query preferences; using UTC", "user_id", userID, "error", err)
+		return time.Now().UTC(), period.Calendar{WeekStart: time.Monday}
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	cal := period.Calendar{
+		WeekStart:       period.WeekStarts[prefs.WeekStart],
+		FiscalYearStart: time.Month(prefs.FiscalYearStart),
+	}
+	if prefs.BudgetPeriod != nil {
+		cal.BudgetPeriod = *prefs.BudgetPeriod
+	}
+
+	defs, err := h.store.Periods.List(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to query period definitions; using calendar periods", "user_id", userID, "error", err)
+		return time.Now().In(loc), cal
+	}
+	for _, d := range defs {
+		anchor, err := time.Parse(period.DateLayout, d.AnchorDate)
+		if err != nil {
+			continue
+		}
+		cal.Cycles = append(cal.Cycles, period.Cycle{Name: d.Name, Anchor: anchor, Every: d.Every, Unit: d.Unit})
+	}
+	return time.Now().In(loc), cal
+}
+
+// ListPeriodDefinitions returns a user's own periods by name
+func (h *Handlers) ListPeriodDefinitions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	defs, err := h.store.Periods.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query period definitions", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query period definitions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"periods": defs,
+		"count":   len(defs),
+	})
+}
+
+// CreatePeriodDefinition defines a period of the user's own that repeats
+// every so many days, weeks or months from an anchor date, before and
+// after it. Period expressions then accept "this <name>" and
+// "last <name>", and budgets can run on it through the budget_period
+// preference:
+//
+//	{"name": "pay period", "anchor_date": "2026-01-09", "every": 2, "unit": "week"}
+func (h *Handlers) CreatePeriodDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID     string `json:"user_id"`
+		Name       string `json:"name"`
+		AnchorDate string `json:"anchor_date"`
+		Every      int    `json:"every"`
+		Unit       string `json:"unit"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	def := &models.PeriodDefinition{
+		Name:       strings.Join(strings.Fields(req.Name), " "),
+		AnchorDate: req.AnchorDate,
+		Every:      req.Every,
+		Unit:       strings.ToLower(req.Unit),
+	}
+	if def.Name == "" || len([]rune(def.Name)) > maxAccountNameLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxAccountNameLength))
+		return
+	}
+	if period.IsReserved(def.Name) {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("%q already means a period; choose another name", def.Name))
+		return
+	}
+	anchor, err := time.Parse(period.DateLayout, def.AnchorDate)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "anchor_date must be a YYYY-MM-DD date on which a period starts")
+		return
+	}
+	if !period.IsUnit(def.Unit) {
+		h.respondError(w, http.StatusBadRequest, "unit must be day, week or month")
+		return
+	}
+	if def.Every < 1 || def.Every > 366 {
+		h.respondError(w, http.StatusBadRequest, "every must be from 1 to 366")
+		return
+	}
+
+	defs, err := h.store.Periods.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query period definitions", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query period definitions")
+		return
+	}
+	if len(defs) >= maxPeriodDefinitions {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may define at most 20 periods")
+		return
+	}
+
+	err = h.store.Periods.Create(ctx, userID, def)
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "A period of that name already exists")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create period definition", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create period definition")
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	cycle := period.Cycle{Name: def.Name, Anchor: anchor, Every: def.Every, Unit: def.Unit}
+	current, _ := period.Calendar{Cycles: []period.Cycle{cycle}}.Current(def.Name, now)
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"period": def,
+			"current": models.Period{
+				StartDate: current.Start.Format(period.DateLayout),
+				EndDate:   current.End.Format(period.DateLayout),
+				Days:      current.Days(),
+			},
+		},
+	})
+}
+
+// DeletePeriodDefinition deletes one of a user's own periods. Budgets
+// running on it go back to calendar months.
+func (h *Handlers) DeletePeriodDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	periodID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.store.Periods.Delete(ctx, userID, periodID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Period definition not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete period definition", "period_id", periodID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete period definition")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      periodID,
+	})
+}
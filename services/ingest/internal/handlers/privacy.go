@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/privacy"
+	"github.com/go-chi/chi/v5"
+)
+
+// ExportUserData starts a job building a downloadable archive of all of a user's data
+func (h *Handlers) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	// Full exports are refused while the account is locked, and an export
+	// from a new IP address locks it
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check account lock")
+		return
+	}
+	if lock == nil {
+		locked, err := h.security.CheckExport(ctx, userID, remoteIP(r))
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check export IP", "error", err)
+		}
+		if locked {
+			lock, _ = h.security.TradingLock(ctx, userID)
+		}
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	jobID, err := h.jobs.Enqueue(ctx, jobs.Params{
+		UserID: userID,
+		Type:   jobs.TypeExport,
+	})
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to start export")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionDataExport,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"job_id": jobID},
+	})
+
+	h.respondJSON(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"job_id":  jobID,
+			"message": "Export started; poll the job for the download path",
+		},
+	})
+}
+
+// exportTask builds a user's data export archive
+func (h *Handlers) exportTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	exportID, size, err := h.privacy.CreateExport(ctx, task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"export_id":     exportID,
+		"size_bytes":    size,
+		"download_path": fmt.Sprintf("/users/%s/exports/%s", task.UserID, exportID),
+	}, nil
+}
+
+// DownloadUserExport returns a previously generated export archive
+func (h *Handlers) DownloadUserExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	exportID := chi.URLParam(r, "exportID")
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	archive, err := h.privacy.GetExport(ctx, userID, exportID)
+	if errors.Is(err, privacy.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Export not found or expired")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to load export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"finagent-export-%s.zip\"", exportID))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// DeleteUser schedules all of a user's data for deletion after the grace period
+func (h *Handlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	deletion, err := h.privacy.ScheduleDeletion(ctx, userID, audit.Actor(ctx))
+	if errors.Is(err, privacy.ErrDeletionPending) {
+		h.respondError(w, http.StatusConflict, "Deletion is already scheduled")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to schedule deletion")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionDeletionRequested,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"scheduled_for": deletion.ScheduledFor},
+	})
+
+	h.respondJSON(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"deletion": deletion,
+			"message":  "All data will be permanently deleted at scheduled_for unless cancelled",
+		},
+	})
+}
+
+// CancelUserDeletion cancels a scheduled deletion during the grace period
+func (h *Handlers) CancelUserDeletion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := h.privacy.CancelDeletion(ctx, userID); err != nil {
+		if errors.Is(err, privacy.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "No pending deletion")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to cancel deletion")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionDeletionCancelled,
+		TargetUserID: userID,
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"cancelled": true,
+		"user_id":   userID,
+	})
+}
+
+// recordAudit writes an audit entry, logging rather than failing on errors
+func (h *Handlers) recordAudit(ctx context.Context, entry audit.Entry) {
+	if err := h.audit.Record(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to record audit entry", "action", entry.Action, "error", err)
+	}
+}
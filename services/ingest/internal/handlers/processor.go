@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/store"
+)
+
+// processorAccountSubtypes are the accounts money can be moved from
+var processorAccountSubtypes = map[string]bool{
+	"checking": true,
+	"savings":  true,
+}
+
+// CreateProcessorToken issues a Plaid processor token giving a partner
+// such as a payments processor access to one checking or savings account
+// of a linked item. Only processors allowed by PLAID_PROCESSORS qualify,
+// and the item must be consented to auth. The token is returned once and
+// not stored; its issue is audited.
+func (h *Handlers) CreateProcessorToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID    string `json:"user_id"`
+		ItemID    string `json:"item_id"`
+		AccountID string `json:"account_id"`
+		Processor string `json:"processor"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.ItemID == "" || req.AccountID == "" || req.Processor == "" {
+		h.respondError(w, http.StatusBadRequest, "item_id, account_id and processor are required")
+		return
+	}
+	if !plaid.IsProcessor(req.Processor) {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown processor %q", req.Processor))
+		return
+	}
+	if !h.processorAllowed(req.Processor) {
+		h.respondError(w, http.StatusForbidden, fmt.Sprintf("Processor tokens are not enabled for %s", req.Processor))
+		return
+	}
+
+	consent, err := h.store.Items.Consent(ctx, req.ItemID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Plaid item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query item consent", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query item consent")
+		return
+	}
+	if !consent.Consented(plaid.ProductAuth) {
+		h.respondError(w, http.StatusConflict, "auth is not consented; relink the item through Plaid Link to add it")
+		return
+	}
+
+	accountType, subtype, err := h.store.Items.AccountType(ctx, req.ItemID, req.AccountID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account not found on this item")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query account", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+		return
+	}
+	if accountType != "depository" || !processorAccountSubtypes[subtype] {
+		h.respondError(w, http.StatusBadRequest, "Processor tokens can only be issued for checking and savings accounts")
+		return
+	}
+
+	encryptedToken, err := h.store.Items.AccessToken(ctx, req.ItemID, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query access token", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query Plaid item")
+		return
+	}
+	accessToken, err := h.plaidClient.DecryptToken(ctx, encryptedToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decrypt access token", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to decrypt token")
+		return
+	}
+
+	processorToken, err := h.plaidClient.CreateProcessorToken(ctx, accessToken, req.AccountID, req.Processor)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create processor token", "user_id", userID, "processor", req.Processor, "error", err)
+		h.respondError(w, http.StatusBadGateway, "Failed to create processor token")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionProcessorToken,
+		TargetUserID: userID,
+		Metadata: map[string]interface{}{
+			"item_id":    req.ItemID,
+			"account_id": req.AccountID,
+			"processor":  req.Processor,
+		},
+	})
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"processor_token": processorToken,
+			"processor":       req.Processor,
+			"item_id":         req.ItemID,
+			"account_id":      req.AccountID,
+		},
+	})
+}
+
+// processorAllowed reports whether PLAID_PROCESSORS allows processor
+func (h *Handlers) processorAllowed(processor string) bool {
+	for _, p := range h.plaidProcessors {
+		if p == processor {
+			return true
+		}
+	}
+	return false
+}
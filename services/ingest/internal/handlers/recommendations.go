@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/recommendations"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// generateRecommendations refreshes a user's transfer recommendations
+// from their synced balances and recent spending
+func (h *Handlers) generateRecommendations(ctx context.Context, userID string) {
+	if h.recommend == nil {
+		return
+	}
+
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts for recommendations", "user_id", userID, "error", err)
+		return
+	}
+	now, _ := h.userClock(ctx, userID)
+	transactions, err := h.store.Transactions.List(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: now.AddDate(0, 0, -recommendations.HistoryDays).Format("2006-01-02"),
+		EndDate:   now.Format("2006-01-02"),
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions for recommendations", "user_id", userID, "error", err)
+		return
+	}
+
+	created, err := h.recommend.Generate(ctx, userID, accounts, transactions)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to generate recommendations", "user_id", userID, "error", err)
+		return
+	}
+	if created > 0 {
+		slog.InfoContext(ctx, "Generated recommendations", "user_id", userID, "count", created)
+	}
+}
+
+// ListRecommendations returns a user's transfer recommendations, newest
+// first. Only pending ones are listed unless status is given; "all" lists
+// every status.
+func (h *Handlers) ListRecommendations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.RecommendationPending
+	case "all":
+		status = ""
+	case models.RecommendationPending, models.RecommendationAccepted,
+		models.RecommendationDismissed, models.RecommendationExpired:
+	default:
+		h.respondError(w, http.StatusBadRequest, "status must be pending, accepted, dismissed, expired or all")
+		return
+	}
+
+	limit, offset := parsePagination(r, 20, 100)
+	list, err := h.recommend.List(ctx, userID, status, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list recommendations", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query recommendations")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"recommendations": list,
+		"count":           len(list),
+	})
+}
+
+// DecideRecommendation records that a user accepted or dismissed a
+// pending recommendation. Accepting one moves no money; it tells the
+// engine the advice was taken.
+func (h *Handlers) DecideRecommendation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if req.Status != models.RecommendationAccepted && req.Status != models.RecommendationDismissed {
+		h.respondError(w, http.StatusBadRequest, "status must be 'accepted' or 'dismissed'")
+		return
+	}
+
+	rec, err := h.recommend.Decide(ctx, userID, chi.URLParam(r, "id"), req.Status)
+	if errors.Is(err, recommendations.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Recommendation not found")
+		return
+	}
+	if errors.Is(err, recommendations.ErrDecided) {
+		h.respondError(w, http.StatusConflict, "Recommendation is no longer pending")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decide recommendation", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update recommendation")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"recommendation": rec,
+	})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/rentals"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxPropertyAddressLength bounds rental property addresses, in characters
+const maxPropertyAddressLength = 200
+
+// ListRentalProperties returns a user's rental properties by name
+func (h *Handlers) ListRentalProperties(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	properties, err := h.rentals.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list rental properties", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query rental properties")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"properties": properties,
+		"count":      len(properties),
+	})
+}
+
+// CreateRentalProperty adds a rental property that transactions can be
+// tagged to:
+//
+//	{"name": "Elm St duplex", "address": "12 Elm St, Springfield"}
+func (h *Handlers) CreateRentalProperty(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID  string  `json:"user_id"`
+		Name    string  `json:"name"`
+		Address *string `json:"address"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	property := &models.RentalProperty{
+		UserID:  userID,
+		Name:    strings.TrimSpace(req.Name),
+		Address: trimmedOrNil(req.Address),
+	}
+	if property.Name == "" || len([]rune(property.Name)) > maxAccountNameLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("name is required and must be at most %d characters", maxAccountNameLength))
+		return
+	}
+	if property.Address != nil && len([]rune(*property.Address)) > maxPropertyAddressLength {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("address must be at most %d characters", maxPropertyAddressLength))
+		return
+	}
+
+	err := h.rentals.Create(ctx, property)
+	if errors.Is(err, rentals.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may keep at most 50 rental properties")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create rental property", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create rental property")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"property": property,
+		},
+	})
+}
+
+// DeleteRentalProperty deletes a rental property and untags its
+// transactions
+func (h *Handlers) DeleteRentalProperty(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	propertyID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.rentals.Delete(ctx, userID, propertyID)
+	if errors.Is(err, rentals.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Rental property not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete rental property", "property_id", propertyID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete rental property")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      propertyID,
+	})
+}
+
+// TagRentalTransactions tags transactions to a rental property with what
+// they are for: rent, repairs, mortgage, mortgage_interest, insurance,
+// taxes, utilities, management, hoa or other. Without a kind, money in is
+// rent and money out is guessed from its category. A mortgage payment is
+// tagged one at a time with the interest it paid, the rest being
+// principal. An empty property_id untags the transactions:
+//
+//	{"transaction_ids": ["..."], "property_id": "...", "kind": "mortgage", "interest": 812.40}
+func (h *Handlers) TagRentalTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID         string           `json:"user_id"`
+		TransactionIDs []string         `json:"transaction_ids"`
+		PropertyID     string           `json:"property_id"`
+		Kind           string           `json:"kind"`
+		Interest       *decimal.Decimal `json:"interest"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if len(req.TransactionIDs) == 0 || len(req.TransactionIDs) > maxExpenseTypeTransactions {
+		h.respondError(w, http.StatusBadRequest, "transaction_ids must list 1 to 1000 transactions")
+		return
+	}
+	if req.PropertyID == "" {
+		untagged, err := h.rentals.Untag(ctx, userID, req.TransactionIDs)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to untag rental transactions", "user_id", userID, "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to untag transactions")
+			return
+		}
+		h.respondSuccess(w, map[string]interface{}{
+			"property_id": nil,
+			"updated":     untagged,
+		})
+		return
+	}
+	if req.Kind != "" && !rentals.IsKind(req.Kind) {
+		h.respondError(w, http.StatusBadRequest,
+			"kind must be rent, repairs, mortgage, mortgage_interest, insurance, taxes, utilities, management, hoa, other or empty")
+		return
+	}
+	if req.Kind == rentals.KindMortgage {
+		if len(req.TransactionIDs) != 1 || req.Interest == nil || req.Interest.IsNegative() {
+			h.respondError(w, http.StatusBadRequest, "A mortgage payment is tagged one at a time with the interest it paid")
+			return
+		}
+	} else if req.Interest != nil {
+		h.respondError(w, http.StatusBadRequest, "interest is only taken for mortgage payments")
+		return
+	}
+
+	tagged, err := h.rentals.Tag(ctx, userID, req.PropertyID, req.TransactionIDs, req.Kind, req.Interest)
+	if errors.Is(err, rentals.ErrNotFound) {
+		h.respondError(w, http.StatusBadRequest, "Rental property not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to tag rental transactions", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to tag transactions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"property_id": req.PropertyID,
+		"updated":     tagged,
+	})
+}
+
+// GetRentalPnL returns the profit and loss of each of a user's rental
+// properties over a period such as "last year", by default this year:
+// rent received, expenses by kind, net income, and net cash flow, which
+// counts mortgage principal. property_id limits it to one property; with
+// summary=true the transactions are left out.
+func (h *Handlers) GetRentalPnL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	expr := r.URL.Query().Get("period")
+	if expr == "" {
+		expr = "this year"
+	}
+	now, cal := h.userCalendar(ctx, userID)
+	resolved, err := cal.Resolve(expr, now)
+	if errors.Is(err, period.ErrUnrecognized) {
+		h.respondError(w, http.StatusBadRequest, "Unrecognized period; try \"last year\", \"YTD\" or \"Q2 2024\"")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	properties, err := h.rentals.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list rental properties", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query rental properties")
+		return
+	}
+	if propertyID := r.URL.Query().Get("property_id"); propertyID != "" {
+		var found []models.RentalProperty
+		for _, p := range properties {
+			if p.ID == propertyID {
+				found = append(found, p)
+			}
+		}
+		if len(found) == 0 {
+			h.respondError(w, http.StatusNotFound, "Rental property not found")
+			return
+		}
+		properties = found
+	}
+
+	transactions, err := h.rentals.Transactions(ctx, userID,
+		resolved.Start.Format(period.DateLayout), resolved.End.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list rental transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query rental transactions")
+		return
+	}
+
+	report := rentals.Report(properties, transactions, resolved.Start, resolved.End)
+	if wantSummary(r) {
+		for i := range report.Properties {
+			report.Properties[i].Transactions = nil
+		}
+	}
+	h.respondSuccess(w, map[string]interface{}{
+		"report": report,
+		"label":  resolved.Label,
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/reports"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateReportDefinition saves a named report to run again through
+// GET /reports/run/{definition_id}. Its period is an expression resolved
+// each time it runs:
+//
+//	{"name": "Dining this month", "period": "this month",
+//	 "filters": {"categories": ["Food and Drink"], "direction": "spending"},
+//	 "group_by": "merchant"}
+func (h *Handlers) CreateReportDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID  string               `json:"user_id"`
+		Name    string               `json:"name"`
+		Period  string               `json:"period"`
+		Filters models.ReportFilters `json:"filters"`
+		GroupBy *string              `json:"group_by"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	definition := &models.ReportDefinition{
+		UserID:  userID,
+		Name:    req.Name,
+		Period:  req.Period,
+		Filters: req.Filters,
+		GroupBy: req.GroupBy,
+	}
+	_, cal := h.userCalendar(ctx, userID)
+	if err := reports.Validate(definition, cal); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := h.reports.Create(ctx, definition)
+	if errors.Is(err, reports.ErrConflict) {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, reports.ErrLimit) {
+		h.respondError(w, http.StatusUnprocessableEntity, "A user may keep at most 100 report definitions")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create report definition", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create report definition")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"definition": definition,
+		},
+	})
+}
+
+// ListReportDefinitions returns a user's report definitions by name
+func (h *Handlers) ListReportDefinitions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	definitions, err := h.reports.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list report definitions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query report definitions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"definitions": definitions,
+		"count":       len(definitions),
+	})
+}
+
+// DeleteReportDefinition deletes a report definition
+func (h *Handlers) DeleteReportDefinition(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	definitionID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.reports.Delete(ctx, userID, definitionID)
+	if errors.Is(err, reports.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Report definition not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete report definition", "definition_id", definitionID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete report definition")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      definitionID,
+	})
+}
+
+// RunReport runs a saved report over the period its expression resolves
+// to today. Results are cached until the user's data next changes.
+func (h *Handlers) RunReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	definitionID := chi.URLParam(r, "definition_id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	definition, err := h.reports.Get(ctx, userID, definitionID)
+	if errors.Is(err, reports.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Report definition not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query report definition", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query report definition")
+		return
+	}
+
+	now, cal := h.userCalendar(ctx, userID)
+	rng, err := cal.Resolve(definition.Period, now)
+	if err != nil {
+		h.respondError(w, http.StatusUnprocessableEntity, "The report's period no longer resolves: "+err.Error())
+		return
+	}
+	startDate := rng.Start.Format(period.DateLayout)
+	endDate := rng.End.Format(period.DateLayout)
+
+	// The resolved dates are part of the key, so a relative period such
+	// as "this month" moves on without waiting for the entry to expire
+	endpoint := "report:" + definition.ID + ":" + startDate + ":" + endDate
+	var result models.ReportResult
+	if h.cache != nil {
+		hit, err := h.cache.Get(ctx, userID, endpoint, &result)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to read from cache", "endpoint", endpoint, "error", err)
+		}
+		if hit {
+			h.respondSuccess(w, map[string]interface{}{"report": result})
+			return
+		}
+	}
+
+	filter := store.TransactionFilter{
+		UserID:      userID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Analytics:   true,
+		Kind:        definition.Filters.Kind,
+		ExpenseType: definition.Filters.ExpenseType,
+		Limit:       maxSummaryTransactions,
+	}
+	if len(definition.Filters.AccountIDs) == 1 {
+		filter.AccountID = definition.Filters.AccountIDs[0]
+	}
+	transactions, err := h.listTransactions(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	result = reports.Run(definition, transactions, rng)
+	result.Truncated = len(transactions) == maxSummaryTransactions
+	if h.cache != nil {
+		if err := h.cache.Set(ctx, userID, endpoint, result); err != nil {
+			slog.WarnContext(ctx, "Failed to write to cache", "endpoint", endpoint, "error", err)
+		}
+	}
+
+	h.respondSuccess(w, map[string]interface{}{"report": result})
+}
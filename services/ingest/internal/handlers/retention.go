@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/retention"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminRetentionReport lists the retention policies and how many rows each
+// would expire now
+func (h *Handlers) AdminRetentionReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.retention.Report(r.Context())
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to build retention report")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"policies": h.retention.Policies(),
+		"report":   report,
+	})
+}
+
+// AdminRunRetention applies the retention policies immediately. With
+// dry_run=true it only counts the rows that would be expired.
+func (h *Handlers) AdminRunRetention(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	results, err := h.retention.Apply(r.Context(), dryRun)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to apply retention policies")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"results": results,
+		"dry_run": dryRun,
+	})
+}
+
+// AdminSetRetentionOverride sets how long one user's records are kept under
+// a policy; a null retain_days keeps them forever
+func (h *Handlers) AdminSetRetentionOverride(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	policy := chi.URLParam(r, "policy")
+
+	var req struct {
+		RetainDays *int   `json:"retain_days"`
+		Reason     string `json:"reason"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.RetainDays != nil && *req.RetainDays <= 0 {
+		h.respondError(w, http.StatusBadRequest, "retain_days must be positive")
+		return
+	}
+
+	if err := h.retention.SetOverride(r.Context(), userID, policy, req.RetainDays, req.Reason); err != nil {
+		if errors.Is(err, retention.ErrUnknownPolicy) {
+			h.respondError(w, http.StatusBadRequest, "Unknown retention policy")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to set retention override")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user_id":     userID,
+		"policy":      policy,
+		"retain_days": req.RetainDays,
+	})
+}
+
+// AdminRemoveRetentionOverride returns a user to a policy's default period
+func (h *Handlers) AdminRemoveRetentionOverride(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	policy := chi.URLParam(r, "policy")
+
+	if err := h.retention.RemoveOverride(r.Context(), userID, policy); err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to remove retention override")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user_id": userID,
+		"policy":  policy,
+	})
+}
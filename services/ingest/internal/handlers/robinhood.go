@@ -2,28 +2,89 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/metrics"
 	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/ratelimit"
+	"github.com/finagent/ingest/internal/security"
+	"github.com/finagent/ingest/internal/usage"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/shopspring/decimal"
 )
 
+// maxOrderQuantity caps the size of a single crypto order
+var maxOrderQuantity = decimal.NewFromInt(1000000)
+
 // PlaceCryptoOrder places or simulates a crypto order
 func (h *Handlers) PlaceCryptoOrder(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	var req models.CryptoOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "Invalid request payload")
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
 		return
 	}
+	req.UserID = userID
 
+	order, err := h.submitCryptoOrder(r.Context(), req)
+	if err != nil {
+		h.respondOrderError(w, err, "Failed to place order")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"order":   order,
+		"dry_run": order.DryRun,
+		"message": h.getOrderMessage(order.DryRun, order.Side, order.Symbol),
+	})
+}
+
+// orderError is an order refused or failed before it was placed, with the
+// status and message to report
+type orderError struct {
+	status  int
+	message string
+	lock    *security.Lock // trading is locked
+	quota   *usage.Quota   // the daily order quota is used up
+}
+
+func (e *orderError) Error() string {
+	return e.message
+}
+
+// respondOrderError reports an *orderError, or fallback for other errors.
+// Transfers are refused the same way as orders.
+func (h *Handlers) respondOrderError(w http.ResponseWriter, err error, fallback string) {
+	var oe *orderError
+	switch {
+	case errors.As(err, &oe) && oe.lock != nil:
+		h.respondLocked(w, oe.lock)
+	case errors.As(err, &oe) && oe.quota != nil:
+		h.respondQuotaExceeded(w, oe.quota)
+	case errors.As(err, &oe):
+		h.respondError(w, oe.status, oe.message)
+	default:
+		h.respondError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+// submitCryptoOrder validates an order for an authorized user, checks the
+// trading lock, rate limit and order quota, then places or simulates it.
+// Orders are dry runs unless DryRun is set to false. Refusals are returned
+// as *orderError.
+func (h *Handlers) submitCryptoOrder(ctx context.Context, req models.CryptoOrderRequest) (*models.CryptoOrder, error) {
 	// Validate request
 	if err := h.validateCryptoOrderRequest(req); err != nil {
-		h.respondError(w, http.StatusBadRequest, err.Error())
-		return
+		return nil, &orderError{status: http.StatusBadRequest, message: err.Error()}
 	}
 
 	// Default to dry run for safety
@@ -32,46 +93,76 @@ func (h *Handlers) PlaceCryptoOrder(w http.ResponseWriter, r *http.Request) {
 		req.DryRun = &dryRun
 	}
 
+	// Refuse orders while trading is locked after suspicious activity
+	lock, err := h.security.TradingLock(ctx, req.UserID)
+	if err != nil {
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to check trading lock"}
+	}
+	if lock != nil {
+		metrics.ObserveOrder(req.Side, *req.DryRun, "locked")
+		return nil, &orderError{status: http.StatusLocked, message: "Trading is locked after suspicious activity", lock: lock}
+	}
+
 	// Check rate limits
 	if err := h.checkOrderRateLimit(ctx, req.UserID); err != nil {
-		h.respondError(w, http.StatusTooManyRequests, "Rate limit exceeded")
-		return
+		metrics.ObserveOrder(req.Side, *req.DryRun, "rate_limited")
+		return nil, &orderError{status: http.StatusTooManyRequests, message: "Rate limit exceeded"}
+	}
+
+	if h.usage != nil {
+		quota, err := h.usage.Consume(ctx, req.UserID, usage.MetricOrders)
+		if errors.Is(err, usage.ErrQuotaExceeded) {
+			metrics.ObserveOrder(req.Side, *req.DryRun, "quota_exceeded")
+			return nil, &orderError{status: http.StatusTooManyRequests, message: "Daily orders quota exceeded", quota: quota}
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to meter usage", "metric", usage.MetricOrders, "error", err)
+		}
+	}
+
+	// A burst of live orders locks trading, including this order
+	if !*req.DryRun {
+		locked, err := h.security.RecordOrder(ctx, req.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to record order for anomaly detection", "error", err)
+		}
+		if locked {
+			metrics.ObserveOrder(req.Side, *req.DryRun, "locked")
+			lock, _ := h.security.TradingLock(ctx, req.UserID)
+			return nil, &orderError{status: http.StatusLocked, message: "Trading is locked after suspicious activity", lock: lock}
+		}
 	}
 
 	// Create order record
-	orderID, err := h.createCryptoOrder(ctx, req)
+	orderID, err := h.store.Orders.Create(ctx, req, getOrderType(req))
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to create order")
-		return
+		metrics.ObserveOrder(req.Side, *req.DryRun, "failed")
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to create order"}
 	}
 
 	// Process order
 	if *req.DryRun {
 		// Simulate order
 		if err := h.simulateCryptoOrder(ctx, orderID, req); err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to simulate order")
-			return
+			metrics.ObserveOrder(req.Side, true, "failed")
+			return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to simulate order"}
 		}
 	} else {
 		// Place real order (if Robinhood client is configured)
 		if err := h.placeRealCryptoOrder(ctx, orderID, req); err != nil {
-			h.respondError(w, http.StatusInternalServerError, "Failed to place real order")
-			return
+			metrics.ObserveOrder(req.Side, false, "failed")
+			return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to place real order"}
 		}
 	}
 
+	metrics.ObserveOrder(req.Side, *req.DryRun, "placed")
+
 	// Get the created order
-	order, err := h.getCryptoOrder(ctx, orderID)
+	order, err := h.store.Orders.Get(ctx, orderID, req.UserID)
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "Failed to retrieve order")
-		return
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to retrieve order"}
 	}
-
-	h.respondSuccess(w, map[string]interface{}{
-		"order":   order,
-		"dry_run": *req.DryRun,
-		"message": h.getOrderMessage(*req.DryRun, req.Side, req.Symbol),
-	})
+	return order, nil
 }
 
 func (h *Handlers) validateCryptoOrderRequest(req models.CryptoOrderRequest) error {
@@ -84,12 +175,12 @@ func (h *Handlers) validateCryptoOrderRequest(req models.CryptoOrderRequest) err
 	if req.Side != "buy" && req.Side != "sell" {
 		return fmt.Errorf("side must be 'buy' or 'sell'")
 	}
-	if req.Quantity <= 0 {
+	if !req.Quantity.IsPositive() {
 		return fmt.Errorf("quantity must be positive")
 	}
 
 	// Validate quantity limits
-	if req.Quantity > 1000000 { // Max order size
+	if req.Quantity.GreaterThan(maxOrderQuantity) {
 		return fmt.Errorf("quantity exceeds maximum allowed")
 	}
 
@@ -102,63 +193,70 @@ func (h *Handlers) validateCryptoOrderRequest(req models.CryptoOrderRequest) err
 	return nil
 }
 
+// checkOrderRateLimit caps orders per account regardless of which principal
+// places them, on top of the per-principal limit applied by middleware
 func (h *Handlers) checkOrderRateLimit(ctx context.Context, userID string) error {
-	// Check Redis for rate limiting
-	key := fmt.Sprintf("order_rate_limit:%s", userID)
-	count, err := h.redis.Get(ctx, key).Int()
-	if err != nil && err.Error() != "redis: nil" {
+	// Allow 10 orders per minute
+	result, err := h.limiter.Allow(ctx, "orders:account:"+userID, ratelimit.PerMinute(10))
+	if err != nil {
 		return err
 	}
-
-	// Allow 10 orders per minute
-	if count >= 10 {
+	if !result.Allowed {
 		return fmt.Errorf("rate limit exceeded")
 	}
-
-	// Increment counter
-	pipe := h.redis.Pipeline()
-	pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, time.Minute)
-	_, err = pipe.Exec(ctx)
-	return err
+	return nil
 }
 
-func (h *Handlers) createCryptoOrder(ctx context.Context, req models.CryptoOrderRequest) (string, error) {
-	var orderID string
-	err := h.db.Pool.QueryRow(ctx, `
-		INSERT INTO crypto_orders (user_id, symbol, side, quantity, order_type, 
-								 price, status, dry_run, placed_at)
-		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, NOW())
-		RETURNING id
-	`, req.UserID, req.Symbol, req.Side, req.Quantity,
-		getOrderType(req), req.Price, *req.DryRun).Scan(&orderID)
-
-	return orderID, err
+// orderSimulation is the input of an order simulation job
+type orderSimulation struct {
+	OrderID  string          `json:"order_id"`
+	Symbol   string          `json:"symbol"`
+	Side     string          `json:"side"`
+	Quantity decimal.Decimal `json:"quantity"`
 }
 
 func (h *Handlers) simulateCryptoOrder(ctx context.Context, orderID string, req models.CryptoOrderRequest) error {
 	// Simulate order execution with random delay
-	go func() {
-		time.Sleep(time.Duration(1+time.Now().Unix()%3) * time.Second)
-
-		// Update order as filled
-		simulatedPrice := h.getSimulatedPrice(req.Symbol)
-		_, err := h.db.Pool.Exec(context.Background(), `
-			UPDATE crypto_orders 
-			SET status = 'filled', 
-				filled_quantity = quantity, 
-				average_fill_price = $2,
-				filled_at = NOW(),
-				updated_at = NOW()
-			WHERE id = $1
-		`, orderID, simulatedPrice)
+	_, err := h.jobs.Enqueue(ctx, jobs.Params{
+		UserID: req.UserID,
+		Type:   jobs.TypeOrderSimulation,
+		Input: orderSimulation{
+			OrderID:  orderID,
+			Symbol:   req.Symbol,
+			Side:     req.Side,
+			Quantity: req.Quantity,
+		},
+		Delay: time.Duration(1+time.Now().Unix()%3) * time.Second,
+	})
+	return err
+}
 
-		if err != nil {
-			fmt.Printf("Failed to update simulated order: %v\n", err)
-		}
-	}()
+// orderSimulationTask fills a simulated order at the simulated market price
+func (h *Handlers) orderSimulationTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	var order orderSimulation
+	if err := task.DecodeInput(&order); err != nil {
+		return nil, err
+	}
 
-	return nil
+	// Update order as filled
+	simulatedPrice := h.getSimulatedPrice(order.Symbol)
+	if err := h.store.Orders.MarkFilled(ctx, order.OrderID, simulatedPrice); err != nil {
+		return nil, err
+	}
+
+	h.publishEvent(ctx, task.UserID, webhooks.EventOrderFilled, map[string]interface{}{
+		"order_id":           order.OrderID,
+		"symbol":             order.Symbol,
+		"side":               order.Side,
+		"quantity":           order.Quantity,
+		"average_fill_price": simulatedPrice,
+		"dry_run":            true,
+	})
+
+	return map[string]interface{}{
+		"order_id":           order.OrderID,
+		"average_fill_price": simulatedPrice,
+	}, nil
 }
 
 func (h *Handlers) placeRealCryptoOrder(ctx context.Context, orderID string, req models.CryptoOrderRequest) error {
@@ -168,48 +266,17 @@ func (h *Handlers) placeRealCryptoOrder(ctx context.Context, orderID string, req
 	}
 
 	// This would integrate with actual Robinhood API
-	rhOrderID, err := h.rhClient.PlaceOrder(req.Symbol, req.Side, req.Quantity, req.Price)
+	rhOrderID, err := h.rhClient.PlaceOrder(ctx, req.Symbol, req.Side, req.Quantity, req.Price)
 	if err != nil {
 		// Update order status to failed
-		h.db.Pool.Exec(ctx, `
-			UPDATE crypto_orders 
-			SET status = 'failed', error_message = $2, updated_at = NOW()
-			WHERE id = $1
-		`, orderID, err.Error())
+		if markErr := h.store.Orders.MarkFailed(ctx, orderID, err.Error()); markErr != nil {
+			slog.ErrorContext(ctx, "Failed to mark order failed", "order_id", orderID, "error", markErr)
+		}
 		return err
 	}
 
 	// Update order with Robinhood order ID
-	_, err = h.db.Pool.Exec(ctx, `
-		UPDATE crypto_orders 
-		SET robinhood_order_id = $2, status = 'submitted', updated_at = NOW()
-		WHERE id = $1
-	`, orderID, rhOrderID)
-
-	return err
-}
-
-func (h *Handlers) getCryptoOrder(ctx context.Context, orderID string) (*models.CryptoOrder, error) {
-	var order models.CryptoOrder
-	err := h.db.Pool.QueryRow(ctx, `
-		SELECT id, user_id, symbol, side, quantity, order_type, price,
-			   status, dry_run, filled_quantity, average_fill_price,
-			   fees, placed_at, filled_at, error_message
-		FROM crypto_orders
-		WHERE id = $1
-	`, orderID).Scan(
-		&order.ID, &order.UserID, &order.Symbol, &order.Side,
-		&order.Quantity, &order.OrderType, &order.Price,
-		&order.Status, &order.DryRun, &order.FilledQuantity,
-		&order.AverageFillPrice, &order.Fees, &order.PlacedAt,
-		&order.FilledAt, &order.ErrorMessage,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &order, nil
+	return h.store.Orders.MarkSubmitted(ctx, orderID, rhOrderID)
 }
 
 func (h *Handlers) getOrderMessage(dryRun bool, side, symbol string) string {
@@ -219,14 +286,15 @@ func (h *Handlers) getOrderMessage(dryRun bool, side, symbol string) string {
 	return fmt.Sprintf("Real %s order for %s submitted to Robinhood", side, symbol)
 }
 
-func (h *Handlers) getSimulatedPrice(symbol string) float64 {
+func (h *Handlers) getSimulatedPrice(symbol string) decimal.Decimal {
+	now := time.Now().Unix()
 	// Return simulated prices for common crypto symbols
-	prices := map[string]float64{
-		"BTC":  45000.00 + float64(time.Now().Unix()%1000-500),
-		"ETH":  3200.00 + float64(time.Now().Unix()%200-100),
-		"DOGE": 0.08 + float64(time.Now().Unix()%10-5)/1000,
-		"ADA":  0.45 + float64(time.Now().Unix()%20-10)/1000,
-		"SOL":  95.00 + float64(time.Now().Unix()%50-25),
+	prices := map[string]decimal.Decimal{
+		"BTC":  decimal.NewFromInt(45000 + now%1000 - 500),
+		"ETH":  decimal.NewFromInt(3200 + now%200 - 100),
+		"DOGE": decimal.New(80+now%10-5, -3),
+		"ADA":  decimal.New(450+now%20-10, -3),
+		"SOL":  decimal.NewFromInt(95 + now%50 - 25),
 	}
 
 	if price, exists := prices[symbol]; exists {
@@ -234,11 +302,11 @@ func (h *Handlers) getSimulatedPrice(symbol string) float64 {
 	}
 
 	// Default price for unknown symbols
-	return 1.00 + float64(time.Now().Unix()%100)/100
+	return decimal.New(100+now%100, -2)
 }
 
 func getOrderType(req models.CryptoOrderRequest) string {
-	if req.Price != nil && *req.Price > 0 {
+	if req.Price != nil && req.Price.IsPositive() {
 		return "limit"
 	}
 	return "market"
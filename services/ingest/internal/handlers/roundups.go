@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/roundups"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxRoundUpGoalNameLength bounds round-up goal names, in characters
+const maxRoundUpGoalNameLength = 100
+
+// GetRoundUpLedger returns the round-ups counting towards a user's goal,
+// goal_id or by default the current one, newest first, with the goal's
+// progress. Round-ups are what rounding purchases up to the next dollar
+// would have saved; no money moves.
+func (h *Handlers) GetRoundUpLedger(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+	limit, offset := parsePagination(r, 100, 1000)
+
+	goal, err := h.roundups.GetGoal(ctx, userID, r.URL.Query().Get("goal_id"))
+	if errors.Is(err, roundups.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Round-up goal not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query round-up goal", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query round-up goal")
+		return
+	}
+
+	entries, err := h.roundups.Ledger(ctx, goal, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query round-ups", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query round-ups")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"goal":      goal,
+		"round_ups": entries,
+		"count":     len(entries),
+	})
+}
+
+// ListRoundUpGoals returns a user's round-up goals, current first, with
+// what each accumulated
+func (h *Handlers) ListRoundUpGoals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	goals, err := h.roundups.ListGoals(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list round-up goals", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query round-up goals")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"goals": goals,
+		"count": len(goals),
+	})
+}
+
+// CreateRoundUpGoal chooses a new goal for round-ups to accumulate
+// against from today, ending the current one:
+//
+//	{"name": "Vacation", "target_amount": "500"}
+func (h *Handlers) CreateRoundUpGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID       string          `json:"user_id"`
+		Name         string          `json:"name"`
+		TargetAmount decimal.Decimal `json:"target_amount"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > maxRoundUpGoalNameLength {
+		h.respondError(w, http.StatusBadRequest, "name is required and must be at most 100 characters")
+		return
+	}
+	if !req.TargetAmount.IsPositive() {
+		h.respondError(w, http.StatusBadRequest, "target_amount must be positive")
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	goal := &models.RoundUpGoal{UserID: userID, Name: name, TargetAmount: req.TargetAmount}
+	if err := h.roundups.CreateGoal(ctx, goal, today); err != nil {
+		slog.ErrorContext(ctx, "Failed to create round-up goal", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create round-up goal")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"goal": goal,
+		},
+	})
+}
+
+// DeleteRoundUpGoal deletes a round-up goal
+func (h *Handlers) DeleteRoundUpGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	goalID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.roundups.DeleteGoal(ctx, userID, goalID)
+	if errors.Is(err, roundups.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Round-up goal not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete round-up goal", "goal_id", goalID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete round-up goal")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      goalID,
+	})
+}
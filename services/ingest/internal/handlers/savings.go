@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/shopspring/decimal"
+)
+
+// Savings trends
+const (
+	trendImproving        = "improving"
+	trendDeclining        = "declining"
+	trendStable           = "stable"
+	trendInsufficientData = "insufficient_data"
+)
+
+// savingsTrendThreshold is how many percentage points the savings rate
+// must move between the earlier and later months to be a trend
+const savingsTrendThreshold = 1.0
+
+// ownTransferSubcategories are the Plaid transfer subcategories that move
+// money between a user's own accounts, which is neither income nor
+// spending
+var ownTransferSubcategories = map[string]bool{
+	"Internal Account Transfer": true,
+	"Savings":                   true,
+	"Credit Card":               true,
+}
+
+// GetSavingsRate reports a user's savings rate for each of the last
+// months complete calendar months (6 by default, at most 24), their
+// average income, spending and net burn rate, and the runway their liquid
+// assets give at that net burn, with whether the savings rate is
+// improving. Transfers between the user's own accounts and card payments
+// are neither income nor spending; crypto staking rewards and interest are
+// income at their value when received.
+func (h *Handlers) GetSavingsRate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	months := 6
+	if s := r.URL.Query().Get("months"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 24 {
+			h.respondError(w, http.StatusBadRequest, "months must be between 1 and 24")
+			return
+		}
+		months = n
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	start := thisMonth.AddDate(0, -months, 0)
+	end := thisMonth.AddDate(0, 0, -1)
+
+	transactions, err := h.listTransactions(ctx, store.TransactionFilter{
+		UserID:    userID,
+		StartDate: start.Format(period.DateLayout),
+		EndDate:   end.Format(period.DateLayout),
+		Analytics: true,
+		Limit:     maxSummaryTransactions,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+		return
+	}
+
+	accounts, err := h.listAccounts(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	cryptoIncome, err := h.cryptoIncome.List(ctx, userID, start.Format(period.DateLayout), end.Format(period.DateLayout))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list crypto income", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query crypto income")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"metrics":   summarizeSavings(transactions, cryptoIncome, accounts, start, months),
+		"truncated": len(transactions) == maxSummaryTransactions,
+	})
+}
+
+// summarizeSavings computes savings metrics over the given number of
+// calendar months from start
+func summarizeSavings(transactions []models.Transaction, cryptoIncome []models.CryptoIncome, accounts []models.Account, start time.Time, months int) models.SavingsMetrics {
+	end := start.AddDate(0, months, -1)
+	metrics := models.SavingsMetrics{
+		Period:       summaryPeriod(start.Format(period.DateLayout), end.Format(period.DateLayout)),
+		Months:       make([]models.MonthlySavings, months),
+		LiquidAssets: decimal.Zero,
+		Trend:        trendInsufficientData,
+	}
+
+	index := make(map[string]int, months)
+	for i := range metrics.Months {
+		month := start.AddDate(0, i, 0).Format("2006-01")
+		metrics.Months[i] = models.MonthlySavings{
+			Month:        month,
+			Income:       decimal.Zero,
+			CryptoIncome: decimal.Zero,
+			Spending:     decimal.Zero,
+		}
+		index[month] = i
+	}
+
+	for _, txn := range transactions {
+		i, ok := index[txn.Date.Format("2006-01")]
+		if !ok || isOwnTransfer(txn) {
+			continue
+		}
+		m := &metrics.Months[i]
+		if txn.Amount.IsPositive() {
+			m.Spending = m.Spending.Add(txn.Amount)
+		} else {
+			m.Income = m.Income.Add(txn.Amount.Neg())
+		}
+	}
+	for _, payment := range cryptoIncome {
+		i, ok := index[payment.ReceivedAt.UTC().Format("2006-01")]
+		if !ok {
+			continue
+		}
+		m := &metrics.Months[i]
+		m.CryptoIncome = m.CryptoIncome.Add(payment.CostBasis)
+		m.Income = m.Income.Add(payment.CostBasis)
+	}
+
+	income, spending := decimal.Zero, decimal.Zero
+	var rates []float64
+	for i := range metrics.Months {
+		m := &metrics.Months[i]
+		m.Savings = m.Income.Sub(m.Spending)
+		m.SavingsRate = savingsRate(m.Savings, m.Income)
+		if m.SavingsRate != nil {
+			rates = append(rates, *m.SavingsRate)
+		}
+		income = income.Add(m.Income)
+		spending = spending.Add(m.Spending)
+	}
+
+	n := decimal.NewFromInt(int64(months))
+	metrics.AverageIncome = income.Div(n).Round(2)
+	metrics.AverageSpending = spending.Div(n).Round(2)
+	metrics.SavingsRate = savingsRate(income.Sub(spending), income)
+	metrics.NetBurnRate = spending.Sub(income).Div(n).Round(2)
+
+	for _, acc := range accounts {
+		if acc.Counted() && acc.Type == "depository" && acc.BalanceCurrent != nil {
+			metrics.LiquidAssets = metrics.LiquidAssets.Add(*acc.BalanceCurrent)
+		}
+	}
+	if metrics.NetBurnRate.IsPositive() {
+		runway, _ := metrics.LiquidAssets.Div(metrics.NetBurnRate).Round(1).Float64()
+		metrics.RunwayMonths = &runway
+	}
+
+	metrics.Trend = savingsTrend(rates)
+	return metrics
+}
+
+// isOwnTransfer reports whether a transaction moves money between a
+// user's own accounts
+func isOwnTransfer(txn models.Transaction) bool {
+	if isCardPayment(txn) {
+		return true
+	}
+	return len(txn.Category) > 1 && txn.Category[0] == "Transfer" && ownTransferSubcategories[txn.Category[1]]
+}
+
+// savingsRate is savings as a percent of income, or nil without income
+func savingsRate(savings, income decimal.Decimal) *float64 {
+	if !income.IsPositive() {
+		return nil
+	}
+	rate, _ := savings.Div(income).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+	return &rate
+}
+
+// savingsTrend compares the average savings rate of the later half of the
+// months with that of the earlier half, leaving out the middle month of an
+// odd number
+func savingsTrend(rates []float64) string {
+	if len(rates) < 2 {
+		return trendInsufficientData
+	}
+	half := len(rates) / 2
+	diff := average(rates[len(rates)-half:]) - average(rates[:half])
+	switch {
+	case diff > savingsTrendThreshold:
+		return trendImproving
+	case diff < -savingsTrendThreshold:
+		return trendDeclining
+	}
+	return trendStable
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return math.Round(sum/float64(len(values))*100) / 100
+}
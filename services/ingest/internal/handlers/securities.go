@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/jobs"
+)
+
+// enrichSecuritiesTask classifies securities due for enrichment
+func (h *Handlers) enrichSecuritiesTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	return h.marketData.Enrich(ctx, progress)
+}
+
+// AdminEnrichSecurities starts a job classifying securities not enriched
+// recently, without waiting for the scheduler
+func (h *Handlers) AdminEnrichSecurities(w http.ResponseWriter, r *http.Request) {
+	jobID, err := h.marketData.Queue(r.Context())
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to start security enrichment job")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"job_id": jobID,
+	})
+}
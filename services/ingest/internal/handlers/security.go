@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/security"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSecurityStatus reports whether the user's trading is locked
+func (h *Handlers) GetSecurityStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch security status")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"trading_locked": lock != nil,
+		"lock":           lock,
+	})
+}
+
+// UnlockTrading lifts the caller's trading lock. The caller must have signed
+// in again recently, so a stolen token alone cannot undo the lock.
+func (h *Handlers) UnlockTrading(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok || p.IsService() {
+		h.respondError(w, http.StatusForbidden, "Only the account owner can unlock trading")
+		return
+	}
+	if !h.security.RecentlyVerified(p) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="finagent", error="insufficient_user_authentication"`)
+		h.respondError(w, http.StatusUnauthorized, "Sign in again to unlock trading")
+		return
+	}
+
+	if err := h.security.UnlockTrading(ctx, p.UserID, audit.Actor(ctx)); err != nil {
+		if errors.Is(err, security.ErrNotLocked) {
+			h.respondError(w, http.StatusConflict, "Trading is not locked")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to unlock trading")
+		return
+	}
+
+	// The user just re-verified from this address
+	if err := h.security.TrustIP(ctx, p.UserID, remoteIP(r)); err != nil {
+		slog.ErrorContext(ctx, "Failed to trust client IP", "error", err)
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"trading_locked": false,
+	})
+}
+
+// AdminUnlockTrading lifts a user's trading lock after out-of-band verification
+func (h *Handlers) AdminUnlockTrading(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "id")
+
+	if err := h.security.UnlockTrading(ctx, userID, "admin"); err != nil {
+		if errors.Is(err, security.ErrNotLocked) {
+			h.respondError(w, http.StatusConflict, "Trading is not locked")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to unlock trading")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user_id":        userID,
+		"trading_locked": false,
+	})
+}
+
+// GetInsights lists a user's insights, newest first
+func (h *Handlers) GetInsights(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	unresolved, _ := strconv.ParseBool(r.URL.Query().Get("unresolved"))
+	limit, offset := parsePagination(r, 50, 200)
+
+	list, err := h.insights.List(ctx, userID, unresolved, limit, offset)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch insights")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"insights": list,
+		"count":    len(list),
+	})
+}
+
+// respondLocked rejects a request while the user's trading is locked
+func (h *Handlers) respondLocked(w http.ResponseWriter, lock *security.Lock) {
+	h.respondJSON(w, http.StatusLocked, APIResponse{
+		Success: false,
+		Error:   "Locked after suspicious activity; sign in again and unlock trading to continue",
+		Data:    map[string]interface{}{"lock": lock},
+	})
+}
+
+// remoteIP returns the client address without its port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
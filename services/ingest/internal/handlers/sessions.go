@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/sessions"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSessions lists the caller's login sessions with device and IP details
+func (h *Handlers) ListSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p, ok := h.sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.sessions.List(ctx, p.UserID, p.TokenID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"sessions": list,
+		"count":    len(list),
+	})
+}
+
+// RevokeSession invalidates one of the caller's sessions immediately
+func (h *Handlers) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionID := chi.URLParam(r, "id")
+
+	p, ok := h.sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.sessions.Revoke(ctx, p.UserID, sessionID); err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"revoked": true,
+		"id":      sessionID,
+	})
+}
+
+// RevokeOtherSessions signs the caller out everywhere except the current session
+func (h *Handlers) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p, ok := h.sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := h.sessions.RevokeAll(ctx, p.UserID, p.TokenID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"revoked": count,
+	})
+}
+
+// AdminRevokeUserSessions invalidates every session of a user, e.g. after
+// a reported account compromise
+func (h *Handlers) AdminRevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "id")
+
+	count, err := h.sessions.RevokeAll(ctx, userID, "")
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user_id": userID,
+		"revoked": count,
+	})
+}
+
+// sessionPrincipal returns the calling user, rejecting callers without a
+// user session such as service API keys
+func (h *Handlers) sessionPrincipal(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || p.IsService() || p.TokenID == "" {
+		h.respondError(w, http.StatusForbidden, "Sessions are only available to signed-in users")
+		return nil, false
+	}
+	return p, true
+}
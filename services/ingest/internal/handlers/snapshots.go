@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/snapshot"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminExportSnapshot downloads a user's dataset as a snapshot archive.
+// With sanitize=true identifying fields are masked, for sharing the data's
+// shape in a bug report.
+func (h *Handlers) AdminExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "id")
+	sanitize, _ := strconv.ParseBool(r.URL.Query().Get("sanitize"))
+
+	archive, manifest, err := h.snapshots.Export(ctx, userID, sanitize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to export snapshot", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to export snapshot")
+		return
+	}
+	if manifest.RecordCounts["users"] == 0 {
+		h.respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionSnapshotExported,
+		TargetUserID: userID,
+		Metadata: map[string]interface{}{
+			"sanitized":     sanitize,
+			"record_counts": manifest.RecordCounts,
+		},
+	})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"finagent-snapshot-%s.zip\"", userID))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// AdminRestoreSnapshot loads a snapshot archive sent as the request body as
+// a new user, optionally with the given auth_id and email
+func (h *Handlers) AdminRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	archive, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.respondError(w, http.StatusRequestEntityTooLarge, "Snapshot too large")
+			return
+		}
+		h.respondError(w, http.StatusBadRequest, "Failed to read snapshot")
+		return
+	}
+
+	restored, err := h.snapshots.Restore(ctx, archive, snapshot.RestoreOptions{
+		AuthID: r.URL.Query().Get("auth_id"),
+		Email:  r.URL.Query().Get("email"),
+	})
+	if err != nil {
+		if errors.Is(err, snapshot.ErrInvalidArchive) {
+			h.respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to restore snapshot", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to restore snapshot")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionSnapshotRestored,
+		TargetUserID: restored.UserID,
+		Metadata: map[string]interface{}{
+			"record_counts": restored.RecordCounts,
+		},
+	})
+
+	h.respondSuccess(w, restored)
+}
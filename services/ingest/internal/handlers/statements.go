@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// cycleInferenceDays is how far back card payments are read to infer
+	// a statement cycle
+	cycleInferenceDays = 180
+
+	// paymentGraceDays is the usual time from a statement closing to its
+	// payment being due. Card issuers must allow at least 21 days.
+	paymentGraceDays = 25
+)
+
+// GetStatementSummary returns a credit card's spending in its current
+// statement cycle, the days left in it and a projection of the statement
+// balance at the pace spent so far. The cycle closes on the day the user
+// set on the account, or one inferred from when they pay the card.
+func (h *Handlers) GetStatementSummary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	account, err := h.store.Accounts.Get(ctx, chi.URLParam(r, "id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Account not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query account", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query account")
+		return
+	}
+	if account.Type != "credit" {
+		h.respondError(w, http.StatusBadRequest, "Statement summaries are only available for credit accounts")
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	today := now.Format(period.DateLayout)
+	filter := store.TransactionFilter{
+		UserID:    userID,
+		AccountID: account.ID,
+		EndDate:   today,
+		Limit:     maxSummaryTransactions,
+	}
+
+	// The close day is the user's, or else inferred from the payments
+	// read back far enough to cover the current cycle too
+	var transactions []models.Transaction
+	closeDay, source := 0, models.CycleUser
+	if account.StatementCloseDay != nil {
+		closeDay = *account.StatementCloseDay
+	} else {
+		filter.StartDate = now.AddDate(0, 0, -cycleInferenceDays).Format(period.DateLayout)
+		if transactions, err = h.listTransactions(ctx, filter); err != nil {
+			slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+			return
+		}
+		source = models.CycleInferred
+		if closeDay, ok = inferCloseDay(transactions); !ok {
+			closeDay, source = 31, models.CycleDefault
+		}
+	}
+
+	start, end := statementCycle(now, closeDay)
+	if source == models.CycleUser {
+		filter.StartDate = start.Format(period.DateLayout)
+		if transactions, err = h.listTransactions(ctx, filter); err != nil {
+			slog.ErrorContext(ctx, "Failed to list transactions", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to query transactions")
+			return
+		}
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"statement": summarizeStatement(account, transactions, now, start, end, closeDay, source),
+	})
+}
+
+// summarizeStatement totals a card's transactions from start to today and
+// projects its balance at end from the average daily spending so far
+func summarizeStatement(account *models.Account, transactions []models.Transaction, now, start, end time.Time, closeDay int, source string) models.StatementSummary {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	summary := models.StatementSummary{
+		AccountID:      account.ID,
+		AccountName:    account.DisplayName(),
+		Cycle:          summaryPeriod(start.Format(period.DateLayout), end.Format(period.DateLayout)),
+		CloseDay:       closeDay,
+		CycleSource:    source,
+		DaysElapsed:    int(today.Sub(start).Hours()/24) + 1,
+		DaysRemaining:  int(end.Sub(today).Hours() / 24),
+		Spent:          decimal.Zero,
+		Credits:        decimal.Zero,
+		Payments:       decimal.Zero,
+		CurrentBalance: account.BalanceCurrent,
+		CreditLimit:    account.BalanceLimit,
+		Currency:       account.Currency,
+	}
+
+	first := start.Format(period.DateLayout)
+	for _, txn := range transactions {
+		if txn.Date.Format(period.DateLayout) < first {
+			continue
+		}
+		summary.TransactionCount++
+		switch {
+		case txn.Amount.IsPositive():
+			summary.Spent = summary.Spent.Add(txn.Amount)
+		case isCardPayment(txn):
+			summary.Payments = summary.Payments.Add(txn.Amount.Neg())
+		default:
+			summary.Credits = summary.Credits.Add(txn.Amount.Neg())
+		}
+	}
+
+	if account.BalanceCurrent != nil {
+		pace := summary.Spent.Sub(summary.Credits).Div(decimal.NewFromInt(int64(summary.DaysElapsed)))
+		if pace.IsNegative() {
+			pace = decimal.Zero
+		}
+		projected := account.BalanceCurrent.Add(pace.Mul(decimal.NewFromInt(int64(summary.DaysRemaining)))).Round(2)
+		summary.ProjectedStatementBalance = &projected
+		if account.BalanceLimit != nil && account.BalanceLimit.IsPositive() {
+			pct := percentOf(projected, *account.BalanceLimit)
+			summary.ProjectedUtilizationPct = &pct
+		}
+	}
+	return summary
+}
+
+// statementCycle returns the first and closing days of the statement
+// cycle that closes on closeDay and includes now, as UTC dates
+func statementCycle(now time.Time, closeDay int) (start, end time.Time) {
+	end = closeDate(now.Year(), now.Month(), closeDay)
+	if now.Day() > end.Day() {
+		end = closeDate(now.Year(), now.Month()+1, closeDay)
+	}
+	start = closeDate(end.Year(), end.Month()-1, closeDay).AddDate(0, 0, 1)
+	return start, end
+}
+
+// closeDate is the day a statement closing on day closes in a month,
+// which is the month's last day when it is shorter
+func closeDate(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// inferCloseDay infers the day a card's statement closes from when its
+// payments were made, taking the usual payment day as the due date and
+// counting back paymentGraceDays. It needs at least two payments.
+func inferCloseDay(transactions []models.Transaction) (int, bool) {
+	var days []int
+	for _, txn := range transactions {
+		if txn.Amount.IsNegative() && !txn.IsPending && isCardPayment(txn) {
+			days = append(days, txn.Date.Day())
+		}
+	}
+	if len(days) < 2 {
+		return 0, false
+	}
+	sort.Ints(days)
+	closeDay := days[len(days)/2] - paymentGraceDays
+	if closeDay < 1 {
+		closeDay += 30
+	}
+	return closeDay, true
+}
+
+// GetStatements lists the monthly PDF statements downloaded from a user's
+// institutions, newest first, with signed download URLs. account_id keeps
+// one account's statements.
+func (h *Handlers) GetStatements(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.statements == nil {
+		h.respondError(w, http.StatusServiceUnavailable, "Statements are not configured")
+		return
+	}
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	list, err := h.statements.List(ctx, userID, r.URL.Query().Get("account_id"))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list statements", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query statements")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"statements": list,
+		"count":      len(list),
+	})
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// summaryTopN is how many of the largest entries a summary lists
+const summaryTopN = 10
+
+// maxSummaryTransactions bounds the transactions a summary reads
+const maxSummaryTransactions = 10000
+
+// liabilityAccountTypes are the Plaid account types whose balance is owed
+var liabilityAccountTypes = map[string]bool{"credit": true, "loan": true}
+
+// wantSummary reports whether a read asks for summary=true, which returns
+// compact aggregates in place of rows so agents can fit the answer in a
+// model's context
+func wantSummary(r *http.Request) bool {
+	summary, _ := strconv.ParseBool(r.URL.Query().Get("summary"))
+	return summary
+}
+
+// summaryPeriod describes the days from start to end inclusive
+func summaryPeriod(start, end string) models.Period {
+	from, _ := time.Parse("2006-01-02", start)
+	to, _ := time.Parse("2006-01-02", end)
+	return models.Period{StartDate: start, EndDate: end, Days: int(to.Sub(from).Hours()/24) + 1}
+}
+
+// percentOf is part's share of whole in percent, rounded to two places
+func percentOf(part, whole decimal.Decimal) float64 {
+	if !whole.IsPositive() {
+		return 0
+	}
+	pct, _ := part.Div(whole).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+	return pct
+}
+
+// summarizeSpending totals money out and in, following Plaid's sign
+// convention where positive amounts are spending, and ranks the categories
+// and merchants spent at
+func summarizeSpending(transactions []models.Transaction, start, end string, topN int) models.SpendingSummary {
+	summary := models.SpendingSummary{
+		TotalSpent:       decimal.Zero,
+		TotalIncome:      decimal.Zero,
+		TransactionCount: len(transactions),
+		Categories:       []models.CategorySummary{},
+		Merchants:        []models.MerchantSummary{},
+		Period:           summaryPeriod(start, end),
+	}
+
+	categories := make(map[string]*models.CategorySummary)
+	merchants := make(map[string]*models.MerchantSummary)
+	for _, txn := range transactions {
+		if !txn.Amount.IsPositive() {
+			summary.TotalIncome = summary.TotalIncome.Add(txn.Amount.Neg())
+			continue
+		}
+		summary.TotalSpent = summary.TotalSpent.Add(txn.Amount)
+
+		category := "Uncategorized"
+		if len(txn.Category) > 0 {
+			category = txn.Category[0]
+		}
+		c, ok := categories[category]
+		if !ok {
+			c = &models.CategorySummary{Category: category, Amount: decimal.Zero}
+			categories[category] = c
+		}
+		c.Amount = c.Amount.Add(txn.Amount)
+		c.TransactionCount++
+
+		merchant := "Unknown"
+		if txn.MerchantName != nil && *txn.MerchantName != "" {
+			merchant = *txn.MerchantName
+		} else if txn.Description != nil && *txn.Description != "" {
+			merchant = *txn.Description
+		}
+		m, ok := merchants[merchant]
+		if !ok {
+			m = &models.MerchantSummary{Merchant: merchant, Amount: decimal.Zero}
+			merchants[merchant] = m
+		}
+		m.Amount = m.Amount.Add(txn.Amount)
+		m.TransactionCount++
+	}
+	summary.NetCashFlow = summary.TotalIncome.Sub(summary.TotalSpent)
+
+	for _, c := range categories {
+		c.Percentage = percentOf(c.Amount, summary.TotalSpent)
+		summary.Categories = append(summary.Categories, *c)
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		return summary.Categories[i].Amount.GreaterThan(summary.Categories[j].Amount)
+	})
+	if len(summary.Categories) > topN {
+		summary.Categories = summary.Categories[:topN]
+	}
+
+	for _, m := range merchants {
+		summary.Merchants = append(summary.Merchants, *m)
+	}
+	sort.Slice(summary.Merchants, func(i, j int) bool {
+		return summary.Merchants[i].Amount.GreaterThan(summary.Merchants[j].Amount)
+	})
+	if len(summary.Merchants) > topN {
+		summary.Merchants = summary.Merchants[:topN]
+	}
+
+	return summary
+}
+
+// summarizeAccounts totals the current balances of open accounts, counting
+// credit and loan balances as owed. Hidden accounts and those excluded from
+// analytics are left out.
+func summarizeAccounts(accounts []models.Account) models.AccountsSummary {
+	summary := models.AccountsSummary{
+		Assets:      decimal.Zero,
+		Liabilities: decimal.Zero,
+		Types:       []models.AccountTypeSummary{},
+	}
+
+	types := make(map[string]*models.AccountTypeSummary)
+	for _, acc := range accounts {
+		if !acc.Counted() {
+			continue
+		}
+		summary.AccountCount++
+
+		t, ok := types[acc.Type]
+		if !ok {
+			t = &models.AccountTypeSummary{Type: acc.Type, Balance: decimal.Zero}
+			types[acc.Type] = t
+		}
+		t.Count++
+		if acc.BalanceCurrent == nil {
+			continue
+		}
+		t.Balance = t.Balance.Add(*acc.BalanceCurrent)
+		if liabilityAccountTypes[acc.Type] {
+			summary.Liabilities = summary.Liabilities.Add(*acc.BalanceCurrent)
+		} else {
+			summary.Assets = summary.Assets.Add(*acc.BalanceCurrent)
+		}
+	}
+	summary.Net = summary.Assets.Sub(summary.Liabilities)
+
+	for _, t := range types {
+		summary.Types = append(summary.Types, *t)
+	}
+	sort.Slice(summary.Types, func(i, j int) bool {
+		return summary.Types[i].Type < summary.Types[j].Type
+	})
+	return summary
+}
+
+// summarizeAccountGroups totals accounts per group, in the order of groups,
+// then those in no group under "Ungrouped" if there are any. Accounts are
+// counted as in summarizeAccounts.
+func summarizeAccountGroups(accounts []models.Account, groups []models.AccountGroup) []models.AccountGroupSummary {
+	byGroup := make(map[string][]models.Account, len(groups))
+	for _, g := range groups {
+		byGroup[g.ID] = []models.Account{}
+	}
+	var ungrouped []models.Account
+	for _, acc := range accounts {
+		if acc.GroupID != nil {
+			if _, ok := byGroup[*acc.GroupID]; ok {
+				byGroup[*acc.GroupID] = append(byGroup[*acc.GroupID], acc)
+				continue
+			}
+		}
+		ungrouped = append(ungrouped, acc)
+	}
+
+	total := func(groupID *string, name string, accounts []models.Account) models.AccountGroupSummary {
+		s := summarizeAccounts(accounts)
+		return models.AccountGroupSummary{
+			GroupID:      groupID,
+			Name:         name,
+			AccountCount: s.AccountCount,
+			Assets:       s.Assets,
+			Liabilities:  s.Liabilities,
+			Net:          s.Net,
+		}
+	}
+
+	summaries := make([]models.AccountGroupSummary, 0, len(groups)+1)
+	for _, g := range groups {
+		id := g.ID
+		summaries = append(summaries, total(&id, g.Name, byGroup[g.ID]))
+	}
+	if rest := total(nil, "Ungrouped", ungrouped); rest.AccountCount > 0 {
+		summaries = append(summaries, rest)
+	}
+	return summaries
+}
+
+// summarizePositions totals positions and lists the largest by value
+func summarizePositions(positions []models.PositionSummary, topN int) models.PortfolioSummary {
+	summary := models.PortfolioSummary{
+		PositionCount: len(positions),
+		TotalValue:    decimal.Zero,
+		TopPositions:  []models.PositionSummary{},
+	}
+	for _, pos := range positions {
+		summary.TotalValue = summary.TotalValue.Add(pos.Value)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Value.GreaterThan(positions[j].Value)
+	})
+	if len(positions) > topN {
+		positions = positions[:topN]
+	}
+	for _, pos := range positions {
+		pos.Percentage = percentOf(pos.Value, summary.TotalValue)
+		summary.TopPositions = append(summary.TopPositions, pos)
+	}
+	return summary
+}
+
+// summarizeHoldings summarizes holdings at their institution value,
+// leaving out those of accounts excluded from analytics
+func summarizeHoldings(holdings []models.Holding, topN int) models.PortfolioSummary {
+	positions := make([]models.PositionSummary, 0, len(holdings))
+	for _, holding := range holdings {
+		if holding.Excluded {
+			continue
+		}
+		pos := models.PositionSummary{Name: holding.SecurityName, Value: decimal.Zero}
+		if holding.Symbol != nil {
+			pos.Symbol = *holding.Symbol
+		}
+		if holding.InstitutionValue != nil {
+			pos.Value = *holding.InstitutionValue
+		}
+		positions = append(positions, pos)
+	}
+	return summarizePositions(positions, topN)
+}
+
+// summarizeCryptoPositions summarizes crypto positions at market value
+func summarizeCryptoPositions(cryptoPositions []models.CryptoPosition, topN int) models.PortfolioSummary {
+	positions := make([]models.PositionSummary, 0, len(cryptoPositions))
+	for _, cp := range cryptoPositions {
+		pos := models.PositionSummary{Symbol: cp.Symbol, Value: decimal.Zero}
+		if cp.Name != nil {
+			pos.Name = *cp.Name
+		}
+		if cp.MarketValue != nil {
+			pos.Value = *cp.MarketValue
+		}
+		positions = append(positions, pos)
+	}
+	return summarizePositions(positions, topN)
+}
+
+// summarizeInvestmentActivity totals investment transactions and their
+// fees by type, largest amount first
+func summarizeInvestmentActivity(transactions []models.InvestmentTransaction, start, end string) models.InvestmentActivitySummary {
+	summary := models.InvestmentActivitySummary{
+		TransactionCount: len(transactions),
+		TotalFees:        decimal.Zero,
+		Types:            []models.InvestmentTypeSummary{},
+		Period:           summaryPeriod(start, end),
+	}
+
+	types := make(map[string]*models.InvestmentTypeSummary)
+	for _, txn := range transactions {
+		t, ok := types[txn.Type]
+		if !ok {
+			t = &models.InvestmentTypeSummary{Type: txn.Type, Amount: decimal.Zero}
+			types[txn.Type] = t
+		}
+		t.Amount = t.Amount.Add(txn.Amount)
+		t.TransactionCount++
+		if txn.Fees != nil {
+			summary.TotalFees = summary.TotalFees.Add(*txn.Fees)
+		}
+	}
+
+	for _, t := range types {
+		summary.Types = append(summary.Types, *t)
+	}
+	sort.Slice(summary.Types, func(i, j int) bool {
+		return summary.Types[i].Amount.Abs().GreaterThan(summary.Types[j].Amount.Abs())
+	})
+	return summary
+}
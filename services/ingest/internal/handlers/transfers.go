@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/metrics"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/ratelimit"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// maxTransferAmount caps the size of a single transfer
+var maxTransferAmount = decimal.NewFromInt(10000)
+
+const (
+	// transferConfirmWindow is how long an authorized transfer can be
+	// confirmed for
+	transferConfirmWindow = 10 * time.Minute
+
+	// maxTransferDescription is the longest description ACH carries
+	maxTransferDescription = 15
+
+	// transferEventPage is how many Plaid transfer events are read at once
+	transferEventPage = 25
+)
+
+// transferStatuses maps Plaid transfer event types to transfer statuses
+var transferStatuses = map[string]string{
+	"pending":   models.TransferPending,
+	"posted":    models.TransferPosted,
+	"settled":   models.TransferSettled,
+	"failed":    models.TransferFailed,
+	"returned":  models.TransferReturned,
+	"cancelled": models.TransferCancelled,
+}
+
+// CreateTransfer authorizes an ACH transfer to or from a linked checking
+// or savings account. Transfers are dry runs unless dry_run is false, and
+// live transfers are only made in the Plaid sandbox. No money moves until
+// the transfer is confirmed with ConfirmTransfer.
+func (h *Handlers) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req models.TransferRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	transfer, err := h.submitTransfer(r.Context(), req)
+	if err != nil {
+		h.respondOrderError(w, err, "Failed to create transfer")
+		return
+	}
+
+	message := fmt.Sprintf("Confirm the transfer with POST /transfers/%s/confirm before %s", transfer.ID,
+		transfer.ConfirmBy.Format(time.RFC3339))
+	if transfer.Status == models.TransferDeclined {
+		message = "Plaid declined the transfer"
+	}
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"transfer": transfer,
+			"dry_run":  transfer.DryRun,
+			"message":  message,
+		},
+	})
+}
+
+// submitTransfer validates a transfer for an authorized user, checks the
+// trading lock and rate limit, then has Plaid authorize it, or simulates
+// that for dry runs. Refusals are returned as *orderError.
+func (h *Handlers) submitTransfer(ctx context.Context, req models.TransferRequest) (*models.Transfer, error) {
+	if err := validateTransferRequest(req); err != nil {
+		return nil, &orderError{status: http.StatusBadRequest, message: err.Error()}
+	}
+
+	// Default to dry run for safety
+	if req.DryRun == nil {
+		dryRun := true
+		req.DryRun = &dryRun
+	}
+	dryRun := *req.DryRun
+	if !dryRun && !h.plaidClient.Sandbox() {
+		return nil, &orderError{status: http.StatusForbidden, message: "Live transfers are only enabled in the Plaid sandbox"}
+	}
+
+	// Money movement stops with trading after suspicious activity
+	lock, err := h.security.TradingLock(ctx, req.UserID)
+	if err != nil {
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to check trading lock"}
+	}
+	if lock != nil {
+		metrics.ObserveTransfer(req.Type, dryRun, "locked")
+		return nil, &orderError{status: http.StatusLocked, message: "Transfers are locked after suspicious activity", lock: lock}
+	}
+
+	result, err := h.limiter.Allow(ctx, "transfers:account:"+req.UserID, ratelimit.PerMinute(5))
+	if err != nil || !result.Allowed {
+		metrics.ObserveTransfer(req.Type, dryRun, "rate_limited")
+		return nil, &orderError{status: http.StatusTooManyRequests, message: "Rate limit exceeded"}
+	}
+
+	accountType, subtype, err := h.store.Items.AccountType(ctx, req.ItemID, req.AccountID, req.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, &orderError{status: http.StatusNotFound, message: "Account not found on this item"}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query account", "user_id", req.UserID, "error", err)
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to query account"}
+	}
+	if accountType != "depository" || !processorAccountSubtypes[subtype] {
+		return nil, &orderError{status: http.StatusBadRequest, message: "Transfers can only use checking and savings accounts"}
+	}
+
+	transfer := &models.Transfer{
+		UserID:      req.UserID,
+		ItemID:      req.ItemID,
+		AccountID:   req.AccountID,
+		Type:        req.Type,
+		Network:     "ach",
+		Amount:      req.Amount,
+		Description: req.Description,
+		Status:      models.TransferAuthorized,
+		DryRun:      dryRun,
+		ConfirmBy:   time.Now().Add(transferConfirmWindow).UTC(),
+	}
+
+	if !dryRun {
+		// A burst of live orders and transfers locks trading, including
+		// this transfer
+		locked, err := h.security.RecordOrder(ctx, req.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to record transfer for anomaly detection", "error", err)
+		}
+		if locked {
+			metrics.ObserveTransfer(req.Type, false, "locked")
+			lock, _ := h.security.TradingLock(ctx, req.UserID)
+			return nil, &orderError{status: http.StatusLocked, message: "Transfers are locked after suspicious activity", lock: lock}
+		}
+
+		accessToken, err := h.itemAccessToken(ctx, req.ItemID, req.UserID)
+		if err != nil {
+			metrics.ObserveTransfer(req.Type, false, "failed")
+			return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to query Plaid item"}
+		}
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate idempotency key: %w", err)
+		}
+		authorization, err := h.plaidClient.AuthorizeTransfer(ctx, plaid.TransferAuthorizationRequest{
+			AccessToken:    accessToken,
+			AccountID:      req.AccountID,
+			Type:           req.Type,
+			Amount:         req.Amount,
+			LegalName:      req.LegalName,
+			IdempotencyKey: hex.EncodeToString(key),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to authorize transfer", "user_id", req.UserID, "error", err)
+			metrics.ObserveTransfer(req.Type, false, "failed")
+			return nil, &orderError{status: http.StatusBadGateway, message: "Failed to authorize transfer"}
+		}
+		transfer.AuthorizationID = &authorization.ID
+		if authorization.Decision != plaid.DecisionApproved {
+			transfer.Status = models.TransferDeclined
+			rationale := authorization.Rationale
+			if rationale == "" {
+				rationale = authorization.Decision
+			}
+			transfer.DecisionRationale = &rationale
+		}
+	}
+
+	if err := h.store.Transfers.Create(ctx, transfer); err != nil {
+		slog.ErrorContext(ctx, "Failed to record transfer", "user_id", req.UserID, "error", err)
+		metrics.ObserveTransfer(req.Type, dryRun, "failed")
+		return nil, &orderError{status: http.StatusInternalServerError, message: "Failed to create transfer"}
+	}
+	metrics.ObserveTransfer(req.Type, dryRun, transfer.Status)
+	return transfer, nil
+}
+
+func validateTransferRequest(req models.TransferRequest) error {
+	if req.ItemID == "" || req.AccountID == "" {
+		return fmt.Errorf("item_id and account_id are required")
+	}
+	if req.Type != "debit" && req.Type != "credit" {
+		return fmt.Errorf("type must be 'debit' or 'credit'")
+	}
+	if !req.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if !req.Amount.Equal(req.Amount.Round(2)) {
+		return fmt.Errorf("amount must be in whole cents")
+	}
+	if req.Amount.GreaterThan(maxTransferAmount) {
+		return fmt.Errorf("amount exceeds maximum allowed")
+	}
+	if req.Description == "" || len(req.Description) > maxTransferDescription {
+		return fmt.Errorf("description is required and at most %d characters", maxTransferDescription)
+	}
+	if req.LegalName == "" {
+		return fmt.Errorf("legal_name is required")
+	}
+	return nil
+}
+
+// itemAccessToken returns the decrypted access token of a user's item
+func (h *Handlers) itemAccessToken(ctx context.Context, itemID, userID string) (string, error) {
+	encryptedToken, err := h.store.Items.AccessToken(ctx, itemID, userID)
+	if err != nil {
+		return "", err
+	}
+	return h.plaidClient.DecryptToken(ctx, encryptedToken)
+}
+
+// ConfirmTransfer confirms an authorized transfer, initiating it. Live
+// transfers are sent to Plaid; dry runs settle shortly after.
+func (h *Handlers) ConfirmTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	transferID := chi.URLParam(r, "id")
+
+	transfer, err := h.store.Transfers.Get(ctx, transferID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query transfer", "transfer_id", transferID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transfer")
+		return
+	}
+	if transfer.Status != models.TransferAuthorized {
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("Transfer is %s and cannot be confirmed", transfer.Status))
+		return
+	}
+
+	lock, err := h.security.TradingLock(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to check trading lock")
+		return
+	}
+	if lock != nil {
+		h.respondLocked(w, lock)
+		return
+	}
+
+	transfer, err = h.store.Transfers.Confirm(ctx, transferID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusConflict, "Transfer was already confirmed or has expired")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to confirm transfer", "transfer_id", transferID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to confirm transfer")
+		return
+	}
+
+	if transfer.DryRun {
+		_, err = h.jobs.Enqueue(ctx, jobs.Params{
+			UserID: userID,
+			Type:   jobs.TypeTransferSimulation,
+			Input:  transferSimulation{TransferID: transfer.ID},
+			Delay:  time.Duration(1+time.Now().Unix()%3) * time.Second,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to simulate transfer", "transfer_id", transfer.ID, "error", err)
+		}
+	} else if err := h.initiateTransfer(ctx, transfer); err != nil {
+		slog.ErrorContext(ctx, "Failed to initiate transfer", "transfer_id", transfer.ID, "error", err)
+		h.respondError(w, http.StatusBadGateway, "Failed to initiate transfer")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionTransferConfirmed,
+		TargetUserID: userID,
+		Metadata: map[string]interface{}{
+			"transfer_id": transfer.ID,
+			"account_id":  transfer.AccountID,
+			"type":        transfer.Type,
+			"amount":      transfer.Amount,
+			"dry_run":     transfer.DryRun,
+		},
+	})
+	h.publishEvent(ctx, userID, webhooks.EventTransferUpdated, transfer)
+
+	h.respondSuccess(w, map[string]interface{}{
+		"transfer": transfer,
+		"dry_run":  transfer.DryRun,
+	})
+}
+
+// initiateTransfer sends a confirmed live transfer to Plaid, marking it
+// failed if Plaid does not take it
+func (h *Handlers) initiateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	err := func() error {
+		accessToken, err := h.itemAccessToken(ctx, transfer.ItemID, transfer.UserID)
+		if err != nil {
+			return err
+		}
+		authorizationID := ""
+		if transfer.AuthorizationID != nil {
+			authorizationID = *transfer.AuthorizationID
+		}
+		plaidTransferID, err := h.plaidClient.CreateTransfer(ctx, accessToken, transfer.AccountID,
+			authorizationID, transfer.Description)
+		if err != nil {
+			return err
+		}
+		transfer.PlaidTransferID = &plaidTransferID
+		return h.store.Transfers.MarkSubmitted(ctx, transfer.ID, plaidTransferID)
+	}()
+	if err != nil {
+		reason := err.Error()
+		if markErr := h.store.Transfers.SetStatus(ctx, transfer.ID, models.TransferFailed, &reason); markErr != nil {
+			slog.ErrorContext(ctx, "Failed to mark transfer failed", "transfer_id", transfer.ID, "error", markErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListTransfers returns a page of a user's transfers, newest first
+func (h *Handlers) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(r, 50, 200)
+	transfers, err := h.store.Transfers.List(ctx, userID, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transfers", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transfers")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"transfers": transfers,
+		"count":     len(transfers),
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetTransfer returns one of a user's transfers
+func (h *Handlers) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	transfer, err := h.store.Transfers.Get(ctx, chi.URLParam(r, "id"), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query transfer", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query transfer")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"transfer": transfer,
+	})
+}
+
+// transferSimulation is the input of a transfer simulation job
+type transferSimulation struct {
+	TransferID string `json:"transfer_id"`
+}
+
+// transferSimulationTask settles a confirmed dry-run transfer
+func (h *Handlers) transferSimulationTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	var sim transferSimulation
+	if err := task.DecodeInput(&sim); err != nil {
+		return nil, err
+	}
+
+	if err := h.store.Transfers.SetStatus(ctx, sim.TransferID, models.TransferSettled, nil); err != nil {
+		return nil, err
+	}
+	transfer, err := h.store.Transfers.Get(ctx, sim.TransferID, task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	h.publishEvent(ctx, task.UserID, webhooks.EventTransferUpdated, transfer)
+
+	return map[string]interface{}{
+		"transfer_id": sim.TransferID,
+		"status":      models.TransferSettled,
+	}, nil
+}
+
+// handleTransferWebhook queues a sync of Plaid's transfer events, which
+// the webhook only announces
+func (h *Handlers) handleTransferWebhook(ctx context.Context, webhook models.PlaidWebhook) error {
+	if webhook.WebhookCode != "TRANSFER_EVENTS_UPDATE" {
+		return nil
+	}
+	_, err := h.jobs.Enqueue(ctx, jobs.Params{
+		Type: jobs.TypeTransferEvents,
+		Input: map[string]interface{}{
+			"received_at": time.Now().UTC(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create transfer events job: %w", err)
+	}
+	return nil
+}
+
+// transferEventsTask applies the Plaid transfer events after the latest
+// one applied, notifying each user whose transfer changed
+func (h *Handlers) transferEventsTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	afterID, err := h.store.Transfers.LastEventID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	read, applied := 0, 0
+	for {
+		events, err := h.plaidClient.SyncTransferEvents(ctx, afterID, transferEventPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			read++
+			afterID = event.EventID
+			status, ok := transferStatuses[event.EventType]
+			if !ok {
+				continue
+			}
+			var reason *string
+			if event.FailureReason != "" {
+				reason = &event.FailureReason
+			}
+			transfer, err := h.store.Transfers.ApplyEvent(ctx, event.EventID, event.TransferID, status, reason)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			applied++
+			h.publishEvent(ctx, transfer.UserID, webhooks.EventTransferUpdated, transfer)
+		}
+		if len(events) < transferEventPage {
+			break
+		}
+	}
+
+	return map[string]interface{}{
+		"events_read":    read,
+		"events_applied": applied,
+		"last_event_id":  afterID,
+	}, nil
+}
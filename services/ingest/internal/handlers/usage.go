@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/usage"
+)
+
+// maxUsageDays bounds the range of a usage report
+const maxUsageDays = 366
+
+// GetUsage reports a user's API calls, syncs and orders per day between
+// from and to (YYYY-MM-DD, default the current month) and where they stand
+// against today's quotas
+func (h *Handlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, name+" must be a date in YYYY-MM-DD format")
+			return
+		}
+		*dst = t
+	}
+	if to.Before(from) || to.Sub(from) > maxUsageDays*24*time.Hour {
+		h.respondError(w, http.StatusBadRequest, "from must not be after to, and the range must not exceed "+strconv.Itoa(maxUsageDays)+" days")
+		return
+	}
+
+	report, err := h.usage.Report(r.Context(), userID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to query usage", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query usage")
+		return
+	}
+
+	h.respondSuccess(w, report)
+}
+
+// consumeUsage counts one use of metric for the user, rejecting the
+// request once the daily quota is used up. Metering errors let the request
+// through.
+func (h *Handlers) consumeUsage(w http.ResponseWriter, r *http.Request, userID, metric string) bool {
+	if h.usage == nil {
+		return true
+	}
+	quota, err := h.usage.Consume(r.Context(), userID, metric)
+	if errors.Is(err, usage.ErrQuotaExceeded) {
+		h.respondQuotaExceeded(w, quota)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to meter usage", "metric", metric, "error", err)
+	}
+	return true
+}
+
+// checkUsage rejects the request if the user's daily quota for metric is
+// used up, without counting it
+func (h *Handlers) checkUsage(w http.ResponseWriter, r *http.Request, userID, metric string) bool {
+	if h.usage == nil {
+		return true
+	}
+	quota, err := h.usage.Check(r.Context(), userID, metric)
+	if errors.Is(err, usage.ErrQuotaExceeded) {
+		h.respondQuotaExceeded(w, quota)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to check usage quota", "metric", metric, "error", err)
+	}
+	return true
+}
+
+// recordUsage counts usage that already happened, logging rather than
+// failing on errors
+func (h *Handlers) recordUsage(ctx context.Context, userID, metric string) {
+	if h.usage == nil {
+		return
+	}
+	if err := h.usage.Record(ctx, userID, metric, 1); err != nil {
+		slog.ErrorContext(ctx, "Failed to meter usage", "metric", metric, "error", err)
+	}
+}
+
+// respondQuotaExceeded rejects a request beyond a daily usage quota
+func (h *Handlers) respondQuotaExceeded(w http.ResponseWriter, quota *usage.Quota) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(quota.Reset).Seconds())+1))
+	h.respondJSON(w, http.StatusTooManyRequests, APIResponse{
+		Success: false,
+		Error:   "Daily " + quota.Metric + " quota exceeded",
+		Data:    map[string]interface{}{"quota": quota},
+	})
+}
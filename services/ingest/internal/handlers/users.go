@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxDisplayNameLength bounds display names, in characters
+const maxDisplayNameLength = 100
+
+// ResolveRegistrant is ResolveUserID for registration, where callers may
+// not have a user yet; they resolve to an empty user ID
+func (h *Handlers) ResolveRegistrant(ctx context.Context, authID string) (string, error) {
+	userID, err := h.store.Users.IDByAuthID(ctx, authID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	return userID, err
+}
+
+// CreateUser registers a user. End users register themselves under their
+// token's subject; services register users by auth_id.
+func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		AuthID      string  `json:"auth_id"`
+		Email       *string `json:"email"`
+		DisplayName *string `json:"display_name"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if p, ok := auth.PrincipalFromContext(ctx); ok && !p.IsService() {
+		if p.UserID != "" {
+			h.respondError(w, http.StatusConflict, "User is already registered")
+			return
+		}
+		if req.AuthID != "" && req.AuthID != p.AuthID {
+			h.respondError(w, http.StatusForbidden, "Cannot register another identity")
+			return
+		}
+		req.AuthID = p.AuthID
+	}
+	if req.AuthID == "" {
+		h.respondError(w, http.StatusBadRequest, "auth_id is required")
+		return
+	}
+
+	user := &models.User{AuthID: req.AuthID}
+	if !h.applyProfile(w, r, user, req.Email, req.DisplayName) {
+		return
+	}
+
+	err := h.store.Users.Create(ctx, user)
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "User is already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create user", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionUserRegistered,
+		TargetUserID: user.ID,
+	})
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// GetUser returns a user's profile with their linked items and account
+// counts
+func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query user", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query user")
+		return
+	}
+
+	items, err := h.store.Users.LinkedItems(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query linked items", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query linked items")
+		return
+	}
+	accountCount := 0
+	for _, item := range items {
+		accountCount += item.AccountCount
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user":          user,
+		"items":         items,
+		"item_count":    len(items),
+		"account_count": accountCount,
+	})
+}
+
+// UpdateUser changes the email, display name or onboarding state given in
+// the body. Onboarding only moves forward.
+func (h *Handlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		Email           *string `json:"email"`
+		DisplayName     *string `json:"display_name"`
+		OnboardingState *string `json:"onboarding_state"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, chi.URLParam(r, "id"), auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query user", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query user")
+		return
+	}
+
+	if !h.applyProfile(w, r, user, req.Email, req.DisplayName) {
+		return
+	}
+	if req.OnboardingState != nil {
+		next, current := onboardingRank(*req.OnboardingState), onboardingRank(user.OnboardingState)
+		if next < 0 {
+			h.respondError(w, http.StatusBadRequest, "onboarding_state must be one of "+strings.Join(models.OnboardingStates, ", "))
+			return
+		}
+		if next < current {
+			h.respondError(w, http.StatusConflict, "Onboarding cannot move back from "+user.OnboardingState)
+			return
+		}
+		user.OnboardingState = *req.OnboardingState
+	}
+
+	if err := h.store.Users.Update(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "Failed to update user", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	h.recordAudit(ctx, audit.Entry{
+		Action:       audit.ActionProfileUpdated,
+		TargetUserID: user.ID,
+		Metadata:     map[string]interface{}{"onboarding_state": user.OnboardingState},
+	})
+
+	h.respondSuccess(w, map[string]interface{}{
+		"user": user,
+	})
+}
+
+// applyProfile validates and sets the profile fields given, writing an
+// error response and returning false when one is invalid. An empty string
+// clears a field. Emails must be unique, ignoring case.
+func (h *Handlers) applyProfile(w http.ResponseWriter, r *http.Request, user *models.User, email, displayName *string) bool {
+	if email != nil {
+		normalized := strings.TrimSpace(*email)
+		switch {
+		case normalized == "":
+			user.Email = nil
+		case !validEmail(normalized):
+			h.respondError(w, http.StatusBadRequest, "email must be a valid address, such as name@example.com")
+			return false
+		default:
+			ownerID, err := h.store.Users.IDByEmail(r.Context(), normalized)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				slog.ErrorContext(r.Context(), "Failed to look up email", "error", err)
+				h.respondError(w, http.StatusInternalServerError, "Failed to check email")
+				return false
+			}
+			if err == nil && ownerID != user.ID {
+				h.respondError(w, http.StatusConflict, "Email is already in use")
+				return false
+			}
+			user.Email = &normalized
+		}
+	}
+
+	if displayName != nil {
+		name := strings.TrimSpace(*displayName)
+		if len([]rune(name)) > maxDisplayNameLength {
+			h.respondError(w, http.StatusBadRequest, "display_name must be at most 100 characters")
+			return false
+		}
+		user.DisplayName = &name
+		if name == "" {
+			user.DisplayName = nil
+		}
+	}
+	return true
+}
+
+// validEmail reports whether email is a bare address with a dotted domain
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	return at > 0 && strings.Contains(email[at+1:], ".")
+}
+
+// onboardingRank is the position of an onboarding state, or -1 if unknown
+func onboardingRank(state string) int {
+	for i, s := range models.OnboardingStates {
+		if s == state {
+			return i
+		}
+	}
+	return -1
+}
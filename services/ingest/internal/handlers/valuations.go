@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/finagent/ingest/internal/valuation"
+	"github.com/shopspring/decimal"
+)
+
+// maxHistoryDays bounds the range of a holdings history read
+const maxHistoryDays = 5 * 366
+
+// valuationTask records the end-of-day valuation of every holding
+func (h *Handlers) valuationTask(ctx context.Context, task *jobs.Task, progress *jobs.Progress) (interface{}, error) {
+	var input valuation.Input
+	if err := task.DecodeInput(&input); err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse(period.DateLayout, input.Date); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	recorded, err := h.valuations.Snapshot(ctx, input.Date)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"date": input.Date, "recorded": recorded}, nil
+}
+
+// GetHoldingsHistory returns a user's end-of-day holdings value from
+// start_date to end_date, by default the last 30 days, with the change
+// over the period. With detail=true it also returns each holding's
+// valuations and the return of its price over the period.
+func (h *Handlers) GetHoldingsHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleAdvisor)
+	if !ok {
+		return
+	}
+
+	now, _ := h.userClock(ctx, userID)
+	start, end := now.AddDate(0, 0, -30), now
+	var err error
+	if s := r.URL.Query().Get("start_date"); s != "" {
+		if start, err = time.Parse(period.DateLayout, s); err != nil {
+			h.respondError(w, http.StatusBadRequest, "start_date must be YYYY-MM-DD")
+			return
+		}
+	}
+	if s := r.URL.Query().Get("end_date"); s != "" {
+		if end, err = time.Parse(period.DateLayout, s); err != nil {
+			h.respondError(w, http.StatusBadRequest, "end_date must be YYYY-MM-DD")
+			return
+		}
+	}
+	startDate, endDate := start.Format(period.DateLayout), end.Format(period.DateLayout)
+	if startDate > endDate {
+		h.respondError(w, http.StatusBadRequest, "start_date must not be after end_date")
+		return
+	}
+	if end.Sub(start) > maxHistoryDays*24*time.Hour {
+		h.respondError(w, http.StatusBadRequest, "Date range is too long")
+		return
+	}
+
+	days, holdings, err := h.valuations.History(ctx, userID, startDate, endDate)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query holdings history", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query holdings history")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"period": summaryPeriod(startDate, endDate),
+		"days":   days,
+	}
+	if len(days) > 0 {
+		first, last := days[0], days[len(days)-1]
+		change := last.Value.Sub(first.Value)
+		resp["start_value"] = first.Value
+		resp["end_value"] = last.Value
+		resp["change"] = change
+		if first.Value.IsPositive() {
+			pct, _ := change.Div(first.Value).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+			resp["change_percent"] = pct
+		}
+	}
+	if r.URL.Query().Get("detail") == "true" {
+		resp["holdings"] = holdings
+	} else {
+		resp["holdings_count"] = len(holdings)
+	}
+	h.respondSuccess(w, resp)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/prices"
+	"github.com/finagent/ingest/internal/watchlist"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+)
+
+// stockSymbol matches exchange tickers such as AAPL or BRK.B
+var stockSymbol = regexp.MustCompile(`^[A-Z][A-Z0-9.\-]{0,9}$`)
+
+// ListWatchlist returns a user's watched symbols with their latest
+// prices and alerts
+func (h *Handlers) ListWatchlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	items, err := h.watchlist.List(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list watchlist", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query watchlist")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// AddWatchlistItem puts a stock or crypto symbol on a user's watchlist.
+// Crypto symbols must be ones Robinhood trades.
+func (h *Handlers) AddWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID    string `json:"user_id"`
+		Symbol    string `json:"symbol"`
+		AssetType string `json:"asset_type"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+	switch req.AssetType {
+	case prices.AssetStock:
+		if !stockSymbol.MatchString(symbol) {
+			h.respondError(w, http.StatusBadRequest, "Invalid stock symbol")
+			return
+		}
+	case prices.AssetCrypto:
+		if !h.rhClient.ValidateSymbol(symbol) {
+			h.respondError(w, http.StatusBadRequest, "Unsupported crypto symbol")
+			return
+		}
+	default:
+		h.respondError(w, http.StatusBadRequest, "asset_type must be 'stock' or 'crypto'")
+		return
+	}
+
+	item, err := h.watchlist.Add(ctx, userID, symbol, req.AssetType)
+	if errors.Is(err, watchlist.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "Symbol is already on the watchlist")
+		return
+	}
+	if errors.Is(err, watchlist.ErrLimit) {
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("A watchlist holds at most %d symbols", watchlist.MaxItems))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to add watchlist item", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to add watchlist item")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"item": item},
+	})
+}
+
+// RemoveWatchlistItem takes a symbol and its alerts off a user's watchlist
+func (h *Handlers) RemoveWatchlistItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.watchlist.Remove(ctx, userID, chi.URLParam(r, "id"))
+	if errors.Is(err, watchlist.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Watchlist item not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to remove watchlist item", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to remove watchlist item")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"message": "Watchlist item removed",
+	})
+}
+
+// CreateWatchlistAlert sets a price alert on a watched symbol: a price
+// above or below threshold, or a daily move of threshold percent
+func (h *Handlers) CreateWatchlistAlert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID    string          `json:"user_id"`
+		Condition string          `json:"condition"`
+		Threshold decimal.Decimal `json:"threshold"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	switch req.Condition {
+	case models.WatchAbove, models.WatchBelow, models.WatchDailyMove:
+	default:
+		h.respondError(w, http.StatusBadRequest, "condition must be 'above', 'below' or 'daily_move'")
+		return
+	}
+	if !req.Threshold.IsPositive() {
+		h.respondError(w, http.StatusBadRequest, "threshold must be positive")
+		return
+	}
+
+	alert, err := h.watchlist.AddAlert(ctx, userID, chi.URLParam(r, "id"), req.Condition, req.Threshold)
+	if errors.Is(err, watchlist.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Watchlist item not found")
+		return
+	}
+	if errors.Is(err, watchlist.ErrLimit) {
+		h.respondError(w, http.StatusConflict, fmt.Sprintf("A symbol has at most %d alerts", watchlist.MaxAlertsPerItem))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create watchlist alert", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create watchlist alert")
+		return
+	}
+
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"alert": alert},
+	})
+}
+
+// DeleteWatchlistAlert removes a price alert from a watched symbol
+func (h *Handlers) DeleteWatchlistAlert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.watchlist.RemoveAlert(ctx, userID, chi.URLParam(r, "id"), chi.URLParam(r, "alertID"))
+	if errors.Is(err, watchlist.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Watchlist alert not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete watchlist alert", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete watchlist alert")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"message": "Watchlist alert deleted",
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/store"
+	"github.com/finagent/ingest/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateWebhookSubscription registers a URL to receive events
+func (h *Handlers) CreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		UserID     string   `json:"user_id"`
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, ok := h.authorizeUser(w, r, req.UserID, auth.RoleOwner)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	if req.UserID == "" || req.URL == "" {
+		h.respondError(w, http.StatusBadRequest, "user_id and url are required")
+		return
+	}
+
+	if err := validateWebhookURL(req.URL); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(req.EventTypes) == 0 {
+		h.respondError(w, http.StatusBadRequest, "event_types is required")
+		return
+	}
+	for _, eventType := range req.EventTypes {
+		if !webhooks.IsValidEventType(eventType) {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("unsupported event type: %s", eventType))
+			return
+		}
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to generate signing secret")
+		return
+	}
+
+	sub := models.WebhookSubscription{
+		UserID:     req.UserID,
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+	}
+
+	if err := h.store.Subscriptions.Create(ctx, &sub); err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to create webhook subscription")
+		return
+	}
+
+	// The secret is only returned once, at creation time
+	h.respondJSON(w, http.StatusCreated, APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"subscription": sub,
+			"message":      "Store the secret securely; it is used to verify the X-FinAgent-Signature header",
+		},
+	})
+}
+
+// ListWebhookSubscriptions returns a user's webhook subscriptions
+func (h *Handlers) ListWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.store.Subscriptions.List(ctx, userID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query webhook subscriptions")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// DeleteWebhookSubscription removes a webhook subscription
+func (h *Handlers) DeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionID := chi.URLParam(r, "id")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	err := h.store.Subscriptions.Delete(ctx, subscriptionID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusNotFound, "Webhook subscription not found")
+		return
+	}
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete webhook subscription")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deleted": true,
+		"id":      subscriptionID,
+	})
+}
+
+// ListWebhookDeliveries returns the delivery log for a subscription
+func (h *Handlers) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subscriptionID := chi.URLParam(r, "id")
+	status := r.URL.Query().Get("status")
+	limit := r.URL.Query().Get("limit")
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleOwner)
+	if !ok {
+		return
+	}
+
+	limitInt := 50
+	if limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 500 {
+			limitInt = l
+		}
+	}
+
+	deliveries, err := h.store.Subscriptions.ListDeliveries(ctx, subscriptionID, userID, status, limitInt)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "Failed to query webhook deliveries")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// validateWebhookURL checks that a subscriber URL is an absolute http(s) URL
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("url must use http or https")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/yield"
+	"github.com/shopspring/decimal"
+)
+
+// evaluateYield raises insights for a user's accounts earning well below
+// a high-yield savings account after a sync, and resolves those that no
+// longer do
+func (h *Handlers) evaluateYield(ctx context.Context, userID string) {
+	if h.yield == nil {
+		return
+	}
+
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts for yield insights", "user_id", userID, "error", err)
+		return
+	}
+	raised, err := h.yield.Evaluate(ctx, userID, accounts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to evaluate account yields", "user_id", userID, "error", err)
+		return
+	}
+	if raised > 0 {
+		slog.InfoContext(ctx, "Raised yield insights", "user_id", userID, "count", raised)
+	}
+}
+
+// GetAccountYield estimates the interest a user's depository accounts earn
+// in a year at their APYs, set with PATCH /read/accounts/{id} or read from
+// their names, and what they would earn in a high-yield savings account.
+// benchmark_apy overrides the configured rate, in percent.
+func (h *Handlers) GetAccountYield(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, ok := h.authorizeQueryUser(w, r, auth.RoleViewer)
+	if !ok {
+		return
+	}
+
+	benchmark := h.yield.BenchmarkAPY()
+	if s := r.URL.Query().Get("benchmark_apy"); s != "" {
+		b, err := decimal.NewFromString(s)
+		if err != nil || !b.IsPositive() || b.GreaterThan(decimal.NewFromInt(100)) {
+			h.respondError(w, http.StatusBadRequest, "benchmark_apy must be a percent from 0 to 100")
+			return
+		}
+		benchmark = b
+	}
+
+	accounts, err := h.store.Accounts.List(ctx, userID, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts", "user_id", userID, "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to query accounts")
+		return
+	}
+
+	h.respondSuccess(w, map[string]interface{}{
+		"yield": yield.Compare(accounts, benchmark),
+	})
+}
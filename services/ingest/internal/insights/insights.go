@@ -0,0 +1,89 @@
+package insights
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/webhooks"
+)
+
+// Severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Store persists insights and announces them to webhook subscribers
+type Store struct {
+	db       *database.Database
+	webhooks *webhooks.Dispatcher
+}
+
+// NewStore creates a new insight store
+func NewStore(db *database.Database, dispatcher *webhooks.Dispatcher) *Store {
+	return &Store{db: db, webhooks: dispatcher}
+}
+
+// Create stores an insight and publishes an insight.created event
+func (s *Store) Create(ctx context.Context, insight *models.Insight) error {
+	if insight.Severity == "" {
+		insight.Severity = SeverityInfo
+	}
+
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO insights (user_id, type, severity, title, message, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, insight.UserID, insight.Type, insight.Severity, insight.Title, insight.Message,
+		insight.Metadata).Scan(&insight.ID, &insight.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create insight: %w", err)
+	}
+
+	if s.webhooks != nil {
+		if err := s.webhooks.Publish(ctx, insight.UserID, webhooks.EventInsightCreated, insight); err != nil {
+			slog.ErrorContext(ctx, "Failed to publish insight", "insight_id", insight.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Resolve marks an insight as resolved
+func (s *Store) Resolve(ctx context.Context, insightID string) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"UPDATE insights SET resolved_at = NOW() WHERE id = $1 AND resolved_at IS NULL", insightID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve insight: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's insights, newest first
+func (s *Store) List(ctx context.Context, userID string, unresolvedOnly bool, limit, offset int) ([]models.Insight, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, type, severity, title, message, metadata, resolved_at, created_at
+		FROM insights
+		WHERE user_id = $1 AND (NOT $2 OR resolved_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unresolvedOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query insights: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Insight{}
+	for rows.Next() {
+		var insight models.Insight
+		if err := rows.Scan(&insight.ID, &insight.UserID, &insight.Type, &insight.Severity, &insight.Title,
+			&insight.Message, &insight.Metadata, &insight.ResolvedAt, &insight.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan insight: %w", err)
+		}
+		list = append(list, insight)
+	}
+	return list, rows.Err()
+}
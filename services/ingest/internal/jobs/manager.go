@@ -0,0 +1,281 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Job types
+const (
+	TypeTransactionsWebhook = "TRANSACTIONS"
+	TypeManualSync          = "MANUAL_SYNC"
+	TypeInitialSync         = "INITIAL_SYNC"
+	TypeBackfill            = "BACKFILL"
+	TypeReport              = "REPORT"
+	TypeExport              = "EXPORT"
+	TypeReencrypt           = "REENCRYPT"
+	TypeOrderSimulation     = "ORDER_SIMULATION"
+	TypeDigest              = "DIGEST"
+	TypeEnrichSecurities    = "ENRICH_SECURITIES"
+	TypeHoldingValuation    = "HOLDING_VALUATION"
+	TypeTransferSimulation  = "TRANSFER_SIMULATION"
+	TypeTransferEvents      = "TRANSFER_EVENTS"
+	TypeCategoryMigration   = "CATEGORY_MIGRATION"
+	TypeCryptoIncomeSync    = "CRYPTO_INCOME_SYNC"
+)
+
+// Job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	// StatusRetryable marks a job interrupted by shutdown; it is resumed
+	// ahead of new work without using up an attempt
+	StatusRetryable = "retryable"
+)
+
+// ErrNotFound is returned when a job does not exist
+var ErrNotFound = errors.New("job not found")
+
+// ErrNotFailed is returned when requeueing a job that has not failed
+var ErrNotFailed = errors.New("job is not in failed state")
+
+// Params describes a job to create
+type Params struct {
+	UserID      string
+	PlaidItemID string
+	Type        string
+	Input       interface{}
+	Delay       time.Duration // wait before the job becomes ready
+}
+
+// Manager queues jobs in Postgres, runs them on a pool of workers and tracks
+// their progress. Jobs survive restarts: a job whose worker dies is picked up
+// again once its lease expires.
+type Manager struct {
+	db           *database.Database
+	opts         Options
+	handlers     map[string]Handler
+	workerID     string
+	pollInterval time.Duration
+
+	// running tracks workers; abort cancels in-flight jobs once a shutdown
+	// drain runs out of time
+	running  sync.WaitGroup
+	abortCtx context.Context
+	abort    context.CancelFunc
+}
+
+// NewManager creates a new job manager
+func NewManager(db *database.Database, opts Options) *Manager {
+	abortCtx, abort := context.WithCancel(context.Background())
+	return &Manager{
+		db:           db,
+		opts:         opts,
+		handlers:     make(map[string]Handler),
+		workerID:     defaultWorkerID(),
+		pollInterval: 500 * time.Millisecond,
+		abortCtx:     abortCtx,
+		abort:        abort,
+	}
+}
+
+// IsTerminal reports whether a job status is final
+func IsTerminal(status string) bool {
+	return status == StatusCompleted || status == StatusFailed
+}
+
+// Enqueue records a new pending job for the workers and returns its ID
+func (m *Manager) Enqueue(ctx context.Context, params Params) (string, error) {
+	var jobID string
+	err := m.db.Pool.QueryRow(ctx, `
+		INSERT INTO jobs (user_id, plaid_item_id, job_type, params, status, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, 'pending', $5, NOW() + make_interval(secs => $6))
+		RETURNING id
+	`, nullString(params.UserID), nullString(params.PlaidItemID), params.Type, params.Input,
+		m.opts.MaxAttempts, params.Delay.Seconds()).Scan(&jobID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create job: %w", err)
+	}
+	return jobID, nil
+}
+
+// Requeue moves a dead-lettered job back onto the queue with a fresh set of
+// attempts
+func (m *Manager) Requeue(ctx context.Context, jobID string) error {
+	tag, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'pending', progress = 0, error_message = NULL,
+		    result = NULL, started_at = NULL, completed_at = NULL,
+		    attempts = 0, run_at = NOW()
+		WHERE id = $1 AND status = 'failed'
+	`, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFailed
+	}
+	return nil
+}
+
+// Complete marks a job as completed with an optional result
+func (m *Manager) Complete(ctx context.Context, jobID string, result interface{}) error {
+	_, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'completed', progress = 100, result = $2, completed_at = NOW(),
+		    error_message = NULL, locked_by = NULL, locked_at = NULL
+		WHERE id = $1
+	`, jobID, result)
+	return err
+}
+
+// Fail dead-letters a job: it stays failed until requeued
+func (m *Manager) Fail(ctx context.Context, jobID string, jobErr error) error {
+	_, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'failed', error_message = $2, completed_at = NOW(),
+		    locked_by = NULL, locked_at = NULL
+		WHERE id = $1
+	`, jobID, jobErr.Error())
+	return err
+}
+
+const jobColumns = `
+	id, user_id, plaid_item_id, job_type, status, COALESCE(progress, 0),
+	COALESCE(records_processed, 0), result, error_message,
+	attempts, max_attempts, run_at,
+	started_at, completed_at, created_at
+`
+
+// Get returns a job by ID
+func (m *Manager) Get(ctx context.Context, jobID string) (*models.Job, error) {
+	row := m.db.Pool.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", jobID)
+
+	job, err := scanJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ListByStatus returns jobs with the given status, most recently created first
+func (m *Manager) ListByStatus(ctx context.Context, status string, limit, offset int) ([]models.Job, error) {
+	rows, err := m.db.Pool.Query(ctx,
+		"SELECT "+jobColumns+" FROM jobs WHERE status = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3",
+		status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		result = append(result, *job)
+	}
+
+	return result, rows.Err()
+}
+
+func scanJob(row pgx.Row) (*models.Job, error) {
+	var job models.Job
+	var result []byte
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.PlaidItemID, &job.Type, &job.Status,
+		&job.Progress, &job.RecordsProcessed, &result, &job.ErrorMessage,
+		&job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.StartedAt, &job.CompletedAt, &job.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		job.Result = json.RawMessage(result)
+	}
+	return &job, nil
+}
+
+// Wait blocks until the job reaches a terminal status or ctx is done, and
+// returns the latest known state of the job
+func (m *Manager) Wait(ctx context.Context, jobID string) (*models.Job, error) {
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	var last *models.Job
+	for {
+		job, err := m.Get(ctx, jobID)
+		if err != nil {
+			// The deadline can expire mid-query; report the last state seen
+			if ctx.Err() != nil && last != nil {
+				return last, nil
+			}
+			return nil, err
+		}
+		if IsTerminal(job.Status) {
+			return job, nil
+		}
+		last = job
+
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress lets a running job report how far along it is
+type Progress struct {
+	manager *Manager
+	jobID   string
+}
+
+// JobID returns the ID of the job being run
+func (p *Progress) JobID() string {
+	return p.jobID
+}
+
+// Update records percent complete and the number of records processed so far
+func (p *Progress) Update(ctx context.Context, percent, recordsProcessed int) {
+	if p == nil {
+		return
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	_, err := p.manager.db.Pool.Exec(ctx, `
+		UPDATE jobs SET progress = $2, records_processed = $3 WHERE id = $1
+	`, p.jobID, percent, recordsProcessed)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to update job progress", "job_id", p.jobID, "error", err)
+	}
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
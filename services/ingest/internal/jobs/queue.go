@@ -0,0 +1,402 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/finagent/ingest/internal/metrics"
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+// Options tunes the job queue workers
+type Options struct {
+	Workers      int           // concurrent jobs per process
+	MaxAttempts  int           // attempts before a job is dead-lettered
+	LockTimeout  time.Duration // lease after which a silent worker's job is reclaimed
+	PollInterval time.Duration // how often idle workers look for ready jobs
+	RetryBackoff time.Duration // delay before the first retry, doubled per attempt
+	MaxBackoff   time.Duration
+	MaxDeferral  time.Duration // age after which Busy errors use up attempts
+}
+
+// Task is a claimed job handed to its handler
+type Task struct {
+	ID          string
+	Type        string
+	UserID      string
+	PlaidItemID string
+	Input       json.RawMessage
+	Attempt     int
+}
+
+// DecodeInput decodes the job's input into v; empty input leaves v unchanged
+func (t *Task) DecodeInput(v interface{}) error {
+	if len(t.Input) == 0 || string(t.Input) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(t.Input, v); err != nil {
+		return Permanent(fmt.Errorf("invalid job input: %w", err))
+	}
+	return nil
+}
+
+// Handler runs one job type. The returned value is stored as the job result;
+// errors are retried with backoff unless wrapped with Permanent.
+type Handler func(ctx context.Context, task *Task, progress *Progress) (interface{}, error)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, so the job is dead-lettered
+// immediately
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type busyError struct {
+	err error
+}
+
+func (e busyError) Error() string { return e.err.Error() }
+func (e busyError) Unwrap() error { return e.err }
+
+// Busy marks err as another job holding something this one needs. The job
+// is run again after RetryBackoff without using an attempt, until it is
+// older than MaxDeferral.
+func Busy(err error) error {
+	return busyError{err: err}
+}
+
+// Register sets the handler for a job type. Call it before Start.
+func (m *Manager) Register(jobType string, handler Handler) {
+	m.handlers[jobType] = handler
+}
+
+// Handles reports whether a handler is registered for a job type
+func (m *Manager) Handles(jobType string) bool {
+	_, ok := m.handlers[jobType]
+	return ok
+}
+
+// Start runs the workers and the stale-lease reaper. Once ctx is done the
+// workers stop claiming jobs; jobs already running continue until Shutdown.
+func (m *Manager) Start(ctx context.Context) {
+	for i := 0; i < m.opts.Workers; i++ {
+		m.running.Add(1)
+		go m.work(ctx)
+	}
+	go m.reap(ctx)
+}
+
+// Shutdown waits for running jobs to finish after the ctx passed to Start is
+// done. If ctx expires first, the remaining jobs are cancelled and marked
+// retryable with their progress kept, so they resume after a restart.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.abort()
+	<-drained
+	return ctx.Err()
+}
+
+func (m *Manager) work(ctx context.Context) {
+	defer m.running.Done()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		task, err := m.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to claim job", "error", err)
+		}
+		if task == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(m.opts.PollInterval):
+			}
+			continue
+		}
+		m.execute(task)
+	}
+}
+
+// claim leases the oldest ready job of a registered type, if any
+func (m *Manager) claim(ctx context.Context) (*Task, error) {
+	types := make([]string, 0, len(m.handlers))
+	for jobType := range m.handlers {
+		types = append(types, jobType)
+	}
+
+	var task Task
+	var userID, plaidItemID *string
+	var input []byte
+	err := m.db.Pool.QueryRow(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_by = $2, locked_at = NOW(),
+		    started_at = COALESCE(started_at, NOW())
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status IN ('pending', 'retryable') AND run_at <= NOW() AND job_type = ANY($1)
+			ORDER BY status = 'pending', run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, job_type, user_id, plaid_item_id, params, attempts
+	`, types, m.workerID).Scan(&task.ID, &task.Type, &userID, &plaidItemID, &input, &task.Attempt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if userID != nil {
+		task.UserID = *userID
+	}
+	if plaidItemID != nil {
+		task.PlaidItemID = *plaidItemID
+	}
+	task.Input = input
+	return &task, nil
+}
+
+// execute runs a claimed task, renewing its lease until the handler returns.
+// The handler is not cancelled when the worker stops claiming, only when a
+// shutdown drain times out, so outcomes are recorded without a deadline.
+func (m *Manager) execute(task *Task) {
+	runCtx, cancel := context.WithCancel(tracing.WithOperation(m.abortCtx, "job "+task.Type))
+	defer cancel()
+	go m.heartbeat(runCtx, task.ID)
+
+	ctx := context.Background()
+	start := time.Now()
+	result, err := m.handlers[task.Type](runCtx, task, &Progress{manager: m, jobID: task.ID})
+	if err != nil {
+		if m.abortCtx.Err() != nil {
+			metrics.ObserveJob(task.Type, "interrupted", time.Since(start))
+			if uerr := m.interrupt(ctx, task); uerr != nil {
+				slog.Error("Failed to mark job retryable", "job_id", task.ID, "error", uerr)
+			}
+			return
+		}
+		metrics.ObserveJob(task.Type, "error", time.Since(start))
+		if uerr := m.retryOrFail(ctx, task, err); uerr != nil {
+			slog.Error("Failed to record job failure", "job_id", task.ID, "error", uerr)
+		}
+		return
+	}
+
+	metrics.ObserveJob(task.Type, "completed", time.Since(start))
+	if err := m.Complete(ctx, task.ID, result); err != nil {
+		slog.Error("Failed to mark job completed", "job_id", task.ID, "error", err)
+	}
+}
+
+// interrupt returns a job cut off by shutdown to the queue as retryable.
+// The attempt is not counted and its progress is kept.
+func (m *Manager) interrupt(ctx context.Context, task *Task) error {
+	_, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'retryable', attempts = GREATEST(attempts - 1, 0),
+		    error_message = 'interrupted by shutdown', run_at = NOW(),
+		    locked_by = NULL, locked_at = NULL
+		WHERE id = $1
+	`, task.ID)
+	return err
+}
+
+func (m *Manager) heartbeat(ctx context.Context, jobID string) {
+	ticker := time.NewTicker(m.opts.LockTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := m.db.Pool.Exec(ctx,
+				"UPDATE jobs SET locked_at = NOW() WHERE id = $1 AND locked_by = $2", jobID, m.workerID)
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to renew job lease", "job_id", jobID, "error", err)
+			}
+		}
+	}
+}
+
+// retryOrFail schedules another attempt with exponential backoff, or
+// dead-letters the job once its attempts are used up. Busy jobs are
+// deferred without counting the attempt until MaxDeferral.
+func (m *Manager) retryOrFail(ctx context.Context, task *Task, jobErr error) error {
+	var permanent permanentError
+	if errors.As(jobErr, &permanent) {
+		return m.Fail(ctx, task.ID, jobErr)
+	}
+
+	var busy busyError
+	if errors.As(jobErr, &busy) {
+		tag, err := m.db.Pool.Exec(ctx, `
+			UPDATE jobs
+			SET status = 'pending', attempts = GREATEST(attempts - 1, 0), error_message = $2,
+			    run_at = NOW() + make_interval(secs => $3), locked_by = NULL, locked_at = NULL
+			WHERE id = $1 AND created_at > NOW() - make_interval(secs => $4)
+		`, task.ID, jobErr.Error(), m.opts.RetryBackoff.Seconds(), m.opts.MaxDeferral.Seconds())
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+	}
+
+	tag, err := m.db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = 'pending', error_message = $2, run_at = NOW() + make_interval(secs => $3),
+		    locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND attempts < max_attempts
+	`, task.ID, jobErr.Error(), m.backoff(task.Attempt).Seconds())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return m.Fail(ctx, task.ID, jobErr)
+	}
+	return nil
+}
+
+// backoff returns the delay before retrying after the given attempt
+func (m *Manager) backoff(attempt int) time.Duration {
+	delay := m.opts.RetryBackoff
+	for i := 1; i < attempt && delay < m.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.opts.MaxBackoff {
+		delay = m.opts.MaxBackoff
+	}
+	return delay
+}
+
+// reap returns jobs whose worker stopped renewing its lease to the queue, or
+// dead-letters them if that was their last attempt
+func (m *Manager) reap(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.LockTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tag, err := m.db.Pool.Exec(ctx, `
+			UPDATE jobs
+			SET status = CASE WHEN attempts < max_attempts THEN 'pending' ELSE 'failed' END,
+			    error_message = 'worker lease expired',
+			    completed_at = CASE WHEN attempts < max_attempts THEN NULL ELSE NOW() END,
+			    run_at = NOW(), locked_by = NULL, locked_at = NULL
+			WHERE status = 'running'
+			  AND (locked_at IS NULL OR locked_at < NOW() - make_interval(secs => $1))
+		`, m.opts.LockTimeout.Seconds())
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to reclaim stale jobs", "error", err)
+			}
+			continue
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			slog.WarnContext(ctx, "Reclaimed jobs with expired worker leases", "count", n)
+		}
+	}
+}
+
+// TypeStats counts one job type's jobs by status
+type TypeStats struct {
+	Type      string `json:"type"`
+	Pending   int    `json:"pending"`
+	Running   int    `json:"running"`
+	Retrying  int    `json:"retrying"`
+	Resuming  int    `json:"resuming"`
+	Completed int    `json:"completed_24h"`
+	Failed    int    `json:"failed_24h"`
+}
+
+// Stats summarises the queue for the jobs dashboard
+type Stats struct {
+	Types            []TypeStats `json:"types"`
+	OldestPendingAge *float64    `json:"oldest_pending_age_seconds"`
+	DeadLettered     int         `json:"dead_lettered"`
+}
+
+// Stats returns queue depth per job type, retries in flight, recent outcomes
+// and the age of the oldest ready job
+func (m *Manager) Stats(ctx context.Context) (*Stats, error) {
+	rows, err := m.db.Pool.Query(ctx, `
+		SELECT job_type,
+		       COUNT(*) FILTER (WHERE status = 'pending' AND attempts = 0),
+		       COUNT(*) FILTER (WHERE status = 'running'),
+		       COUNT(*) FILTER (WHERE status = 'pending' AND attempts > 0),
+		       COUNT(*) FILTER (WHERE status = 'retryable'),
+		       COUNT(*) FILTER (WHERE status = 'completed' AND completed_at >= NOW() - INTERVAL '24 hours'),
+		       COUNT(*) FILTER (WHERE status = 'failed' AND completed_at >= NOW() - INTERVAL '24 hours')
+		FROM jobs
+		GROUP BY job_type
+		ORDER BY job_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &Stats{Types: []TypeStats{}}
+	for rows.Next() {
+		var t TypeStats
+		if err := rows.Scan(&t.Type, &t.Pending, &t.Running, &t.Retrying, &t.Resuming, &t.Completed, &t.Failed); err != nil {
+			return nil, fmt.Errorf("failed to scan job stats: %w", err)
+		}
+		stats.Types = append(stats.Types, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query job stats: %w", err)
+	}
+
+	err = m.db.Pool.QueryRow(ctx, `
+		SELECT EXTRACT(EPOCH FROM NOW() - MIN(run_at))::float8,
+		       (SELECT COUNT(*) FROM jobs WHERE status = 'failed')
+		FROM jobs
+		WHERE status IN ('pending', 'retryable') AND run_at <= NOW()
+	`).Scan(&stats.OldestPendingAge, &stats.DeadLettered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job queue age: %w", err)
+	}
+
+	return stats, nil
+}
+
+// defaultWorkerID identifies this process in job leases
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,59 @@
+package keymanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// AWSKMS wraps data keys with an AWS KMS customer master key
+type AWSKMS struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewAWSKMS creates an AWS KMS key manager using the default credential chain
+func NewAWSKMS(ctx context.Context, keyID, region string) (*AWSKMS, error) {
+	var optFns []func(*config.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &AWSKMS{
+		client: kms.NewFromConfig(cfg),
+		keyID:  keyID,
+	}, nil
+}
+
+// GenerateDataKey asks KMS for a new AES-256 data key
+func (k *AWSKMS) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := k.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// DecryptDataKey asks KMS to unwrap a data key
+func (k *AWSKMS) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	return out.Plaintext, nil
+}
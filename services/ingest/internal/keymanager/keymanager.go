@@ -0,0 +1,112 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Providers
+const (
+	ProviderAWS   = "aws"
+	ProviderVault = "vault"
+	ProviderLocal = "local"
+)
+
+// DataKeySize is the size in bytes of generated data keys (AES-256)
+const DataKeySize = 32
+
+// KeyManager generates data encryption keys and wraps them with a master key
+// that never leaves the key management service
+type KeyManager interface {
+	// GenerateDataKey returns a new data key in plaintext and wrapped form
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key returned by GenerateDataKey
+	DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Options selects and configures a key manager provider
+type Options struct {
+	Provider string
+
+	// AWS KMS; the key ID is the initial master key
+	AWSKeyID  string
+	AWSRegion string
+
+	// Vault transit secrets engine; the transit key is the initial master key
+	VaultAddr       string
+	VaultToken      string
+	VaultTransitKey string
+
+	// Local master keys (base64, 32 bytes) for development only.
+	// LocalMasterKey is the initial key, with ID "local"; further keys for
+	// rotation are given as "id=base64,id=base64".
+	LocalMasterKey  string
+	LocalMasterKeys string
+
+	// LegacyKey is the ENCRYPTION_KEY values were sealed with before
+	// envelope encryption; it is only used to read them
+	LegacyKey string
+}
+
+// localDefaultKeyID names the key given by LocalMasterKey
+const localDefaultKeyID = "local"
+
+// DefaultKeyID returns the master key ID configured for the provider
+func (o Options) DefaultKeyID() string {
+	switch o.Provider {
+	case ProviderAWS:
+		return o.AWSKeyID
+	case ProviderVault:
+		return o.VaultTransitKey
+	case ProviderLocal:
+		return localDefaultKeyID
+	default:
+		return ""
+	}
+}
+
+// New creates a key manager for the given master key ID. For AWS the ID is
+// a KMS key ID, ARN or alias; for Vault it is a transit key name.
+func New(ctx context.Context, opts Options, keyID string) (KeyManager, error) {
+	switch opts.Provider {
+	case ProviderAWS:
+		if keyID == "" {
+			return nil, fmt.Errorf("AWS_KMS_KEY_ID is required for the aws provider")
+		}
+		return NewAWSKMS(ctx, keyID, opts.AWSRegion)
+	case ProviderVault:
+		if opts.VaultAddr == "" || opts.VaultToken == "" || keyID == "" {
+			return nil, fmt.Errorf("VAULT_ADDR, VAULT_TOKEN and VAULT_TRANSIT_KEY are required for the vault provider")
+		}
+		return NewVaultTransit(opts.VaultAddr, opts.VaultToken, keyID), nil
+	case ProviderLocal:
+		encoded, err := opts.localMasterKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("local master key %s must be base64: %w", keyID, err)
+		}
+		return NewLocal(key)
+	case "":
+		return nil, fmt.Errorf("KMS_PROVIDER is required (aws, vault or local)")
+	default:
+		return nil, fmt.Errorf("unsupported KMS provider: %s", opts.Provider)
+	}
+}
+
+func (o Options) localMasterKey(keyID string) (string, error) {
+	if keyID == localDefaultKeyID && o.LocalMasterKey != "" {
+		return o.LocalMasterKey, nil
+	}
+	for _, entry := range strings.Split(o.LocalMasterKeys, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && id == keyID {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("no local master key configured for %s", keyID)
+}
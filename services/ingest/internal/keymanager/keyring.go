@@ -0,0 +1,113 @@
+package keymanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Keyring holds the active master key used for new encryptions and lazily
+// loads older master keys that existing ciphertexts were wrapped with
+type Keyring struct {
+	opts Options
+
+	mu     sync.RWMutex
+	active string
+	keys   map[string]KeyManager
+}
+
+// NewKeyring creates a keyring with activeID as the active master key
+func NewKeyring(ctx context.Context, opts Options, activeID string) (*Keyring, error) {
+	km, err := New(ctx, opts, activeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{
+		opts:   opts,
+		active: activeID,
+		keys:   map[string]KeyManager{activeID: km},
+	}, nil
+}
+
+// Active returns the ID and key manager used for new encryptions
+func (k *Keyring) Active() (string, KeyManager) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active, k.keys[k.active]
+}
+
+// ActiveID returns the ID of the active master key
+func (k *Keyring) ActiveID() string {
+	id, _ := k.Active()
+	return id
+}
+
+// DefaultID returns the master key ID from configuration, used for
+// ciphertexts written before key IDs were recorded
+func (k *Keyring) DefaultID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.opts.DefaultKeyID()
+}
+
+// Get returns the key manager for a master key ID, loading it if needed
+func (k *Keyring) Get(ctx context.Context, keyID string) (KeyManager, error) {
+	k.mu.RLock()
+	km, ok := k.keys[keyID]
+	opts := k.opts
+	k.mu.RUnlock()
+	if ok {
+		return km, nil
+	}
+
+	km, err := New(ctx, opts, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load master key %s: %w", keyID, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if existing, ok := k.keys[keyID]; ok {
+		return existing, nil
+	}
+	k.keys[keyID] = km
+	return km, nil
+}
+
+// Reload replaces the key options, e.g. after a credential or local key
+// rotation, and discards loaded keys so they are recreated from the new
+// options. The active key must load under the new options.
+func (k *Keyring) Reload(ctx context.Context, opts Options) error {
+	k.mu.RLock()
+	active := k.active
+	k.mu.RUnlock()
+
+	km, err := New(ctx, opts, active)
+	if err != nil {
+		return fmt.Errorf("failed to reload master key %s: %w", active, err)
+	}
+
+	k.mu.Lock()
+	k.opts = opts
+	k.keys = map[string]KeyManager{active: km}
+	k.mu.Unlock()
+	return nil
+}
+
+// SetActive switches new encryptions to another master key after checking
+// that it can generate data keys
+func (k *Keyring) SetActive(ctx context.Context, keyID string) error {
+	km, err := k.Get(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	if _, _, err := km.GenerateDataKey(ctx); err != nil {
+		return fmt.Errorf("master key %s is not usable: %w", keyID, err)
+	}
+
+	k.mu.Lock()
+	k.active = keyID
+	k.mu.Unlock()
+	return nil
+}
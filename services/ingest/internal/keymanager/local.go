@@ -0,0 +1,64 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// Local wraps data keys with a master key held in process memory. It exists
+// for development and tests; production deployments should use a KMS.
+type Local struct {
+	aead cipher.AEAD
+}
+
+// NewLocal creates a local key manager from a 32-byte master key
+func NewLocal(masterKey []byte) (*Local, error) {
+	if len(masterKey) != DataKeySize {
+		return nil, fmt.Errorf("local master key must be %d bytes, got %d", DataKeySize, len(masterKey))
+	}
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &Local{aead: aead}, nil
+}
+
+// GenerateDataKey creates a random data key and wraps it with the master key
+func (l *Local) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	plaintext := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce := make([]byte, l.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return plaintext, l.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptDataKey unwraps a data key with the master key
+func (l *Local) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	nonceSize := l.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	plaintext, err := l.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	return plaintext, nil
+}
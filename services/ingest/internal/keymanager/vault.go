@@ -0,0 +1,101 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultTransit wraps data keys with a HashiCorp Vault transit key
+type VaultTransit struct {
+	addr       string
+	token      string
+	keyName    string
+	httpClient *http.Client
+}
+
+// NewVaultTransit creates a Vault transit key manager
+func NewVaultTransit(addr, token, keyName string) *VaultTransit {
+	return &VaultTransit{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		keyName:    keyName,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GenerateDataKey asks Vault for a new 256-bit data key
+func (v *VaultTransit) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	err := v.post(ctx, "/v1/transit/datakey/plaintext/"+v.keyName, map[string]interface{}{
+		"bits": DataKeySize * 8,
+	}, &resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	return plaintext, []byte(resp.Data.Ciphertext), nil
+}
+
+// DecryptDataKey asks Vault to unwrap a data key
+func (v *VaultTransit) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	err := v.post(ctx, "/v1/transit/decrypt/"+v.keyName, map[string]interface{}{
+		"ciphertext": string(wrapped),
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (v *VaultTransit) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.addr+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
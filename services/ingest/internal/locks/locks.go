@@ -0,0 +1,152 @@
+// Package locks provides Redis-backed distributed mutexes with automatic
+// lease renewal
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrLocked is returned when another holder has the lock
+var ErrLocked = errors.New("lock is held by another process")
+
+// Only the holder's token may renew or release a lock, so a holder whose
+// lease expired cannot touch a lock someone else has since taken
+var (
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// Locker hands out locks stored in Redis. A lock is a key with a TTL that
+// its holder renews; if the holder dies the key expires, so stale locks
+// clean themselves up after one TTL.
+type Locker struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+// New creates a locker whose leases last ttl between renewals
+func New(redisClient *redis.Client, ttl time.Duration) *Locker {
+	return &Locker{redis: redisClient, ttl: ttl}
+}
+
+// Lock is a held lock. Its context is cancelled if the lease is lost.
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Acquire takes the lock named key, or returns ErrLocked if it is held.
+// The lease is renewed in the background until Release.
+func (l *Locker) Acquire(ctx context.Context, key string) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := l.redis.SetNX(ctx, lockKey(key), token, l.ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	lock := &Lock{
+		locker: l,
+		key:    key,
+		token:  token,
+		ctx:    lockCtx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go lock.renew()
+	return lock, nil
+}
+
+// Context is cancelled when the lock is released or its lease is lost, so
+// work guarded by the lock stops once another holder may have taken it
+func (lk *Lock) Context() context.Context {
+	return lk.ctx
+}
+
+// Release stops renewal and deletes the lock if this holder still owns it
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.cancel()
+	<-lk.done
+
+	err := releaseScript.Run(ctx, lk.locker.redis, []string{lockKey(lk.key)}, lk.token).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to release lock %s: %w", lk.key, err)
+	}
+	return nil
+}
+
+func (lk *Lock) renew() {
+	defer close(lk.done)
+
+	ticker := time.NewTicker(lk.locker.ttl / 3)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-lk.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewed, err := renewScript.Run(lk.ctx, lk.locker.redis, []string{lockKey(lk.key)},
+			lk.token, lk.locker.ttl.Milliseconds()).Int()
+		if err != nil {
+			if lk.ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Failed to renew lock", "lock", lk.key, "error", err)
+			// Keep trying until the lease would have run out
+			if time.Since(renewedAt) >= lk.locker.ttl {
+				lk.cancel()
+				return
+			}
+			continue
+		}
+		if renewed == 0 {
+			slog.Warn("Lost lock; another holder may have taken it", "lock", lk.key)
+			lk.cancel()
+			return
+		}
+		renewedAt = time.Now()
+	}
+}
+
+func lockKey(key string) string {
+	return "lock:" + key
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,183 @@
+// Package logging configures the service's structured logger. Records
+// logged with a request's context carry its request_id, user_id and
+// trace_id, and access logs of high-volume routes are sampled.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultSampledRoutes are the route prefixes whose successful requests are
+// sampled when Options.SampledRoutes is empty
+var DefaultSampledRoutes = []string{"/metrics", "/healthz", "/readyz", "/read/"}
+
+// Options configures the logger
+type Options struct {
+	Level         string   // debug, info, warn or error
+	Format        string   // json or text
+	SampleRate    float64  // share of successful requests logged on sampled routes
+	SampledRoutes []string // route pattern prefixes; errors are always logged
+
+	// Output receives the logs; nil means stdout
+	Output io.Writer
+}
+
+// Setup installs the service logger as the slog default, which also routes
+// the standard log package through it
+func Setup(opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: parseLevel(opts.Level)}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, "text") {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	}
+
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+	return logger
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds the correlation fields found in a record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := chimiddleware.GetReqID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if userID := userID(ctx); userID != "" {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestKey struct{}
+
+// request collects fields that are only known deeper in the handler chain
+// than the access log, such as the authenticated user
+type request struct {
+	userID string
+}
+
+func userID(ctx context.Context) string {
+	if id := auth.UserIDFromContext(ctx); id != "" {
+		return id
+	}
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.userID
+	}
+	return ""
+}
+
+// TagUser records the authenticated user for the request's access log. It
+// belongs after authentication.
+func TagUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := r.Context().Value(requestKey{}).(*request); ok {
+			req.userID = auth.UserIDFromContext(r.Context())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLog logs each request once it completes: server errors at error
+// level, client errors at warn, the rest at info. Successful requests on
+// sampled routes are logged at the sample rate.
+func AccessLog(opts Options) func(http.Handler) http.Handler {
+	sampled := opts.SampledRoutes
+	if len(sampled) == 0 {
+		sampled = DefaultSampledRoutes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			req := &request{}
+			r = r.WithContext(context.WithValue(r.Context(), requestKey{}, req))
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			case hasPrefix(route, sampled) && rand.Float64() >= opts.SampleRate:
+				return
+			}
+
+			slog.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", r.RemoteAddr),
+			)
+		})
+	}
+}
+
+func hasPrefix(route string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/jackc/pgx/v5"
+)
+
+// Options sets how often enrichment is queued and how long a symbol's
+// metadata is trusted before it is looked up again
+type Options struct {
+	Interval     time.Duration // time between scheduler runs; 0 disables the scheduler
+	RefreshAfter time.Duration // age at which securities and cached lookups are refreshed
+}
+
+// Result counts what an enrichment run did
+type Result struct {
+	Symbols  int   `json:"symbols"`   // distinct symbols due for enrichment
+	LookedUp int   `json:"looked_up"` // symbols asked of the provider
+	Cached   int   `json:"cached"`    // symbols served from the lookup cache
+	Unknown  int   `json:"unknown"`   // symbols the provider has no data on
+	Failed   int   `json:"failed"`    // lookups that failed, retried next run
+	Updated  int64 `json:"updated"`   // securities rows written
+}
+
+// typeAssetClass classifies securities by their Plaid type when the
+// provider does not know them. Funds are left unclassified, since their
+// class depends on what they hold.
+const typeAssetClass = `CASE type
+	WHEN 'equity' THEN 'equity'
+	WHEN 'fixed income' THEN 'fixed_income'
+	WHEN 'cash' THEN 'cash'
+	WHEN 'cryptocurrency' THEN 'crypto'
+	WHEN 'derivative' THEN 'derivative'
+	WHEN 'other' THEN 'other'
+END`
+
+// Service schedules enrichment jobs and runs them
+type Service struct {
+	db       *database.Database
+	locks    *locks.Locker
+	jobs     *jobs.Manager
+	provider Provider
+	opts     Options
+}
+
+// NewService creates a security enrichment service. When locker is set,
+// only one instance schedules enrichment at a time.
+func NewService(db *database.Database, locker *locks.Locker, jobManager *jobs.Manager, provider Provider, opts Options) *Service {
+	return &Service{db: db, locks: locker, jobs: jobManager, provider: provider, opts: opts}
+}
+
+// Run queues an enrichment job every Interval, when securities are due
+// for one, until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "security_enrichment")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock security enrichment scheduling", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	var due bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM securities WHERE enriched_at IS NULL OR enriched_at < $1)
+		   AND NOT EXISTS (SELECT 1 FROM jobs WHERE job_type = $2 AND status IN ('pending', 'running', 'retryable'))
+	`, time.Now().Add(-s.opts.RefreshAfter), jobs.TypeEnrichSecurities).Scan(&due)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check for securities to enrich", "error", err)
+		return
+	}
+	if !due {
+		return
+	}
+	if _, err := s.Queue(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to queue security enrichment", "error", err)
+	}
+}
+
+// Queue enqueues an enrichment job and returns its ID
+func (s *Service) Queue(ctx context.Context) (string, error) {
+	return s.jobs.Enqueue(ctx, jobs.Params{Type: jobs.TypeEnrichSecurities})
+}
+
+// Enrich classifies every security not enriched within RefreshAfter.
+// Each symbol is looked up once, from the cache when it was fetched
+// within RefreshAfter, and written to every user's securities with it.
+// Securities without a symbol, and symbols the provider does not know,
+// get an asset class from their Plaid type.
+func (s *Service) Enrich(ctx context.Context, progress *jobs.Progress) (*Result, error) {
+	now := time.Now()
+	staleBefore := now.Add(-s.opts.RefreshAfter)
+	result := &Result{}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE securities SET asset_class = `+typeAssetClass+`, enriched_at = $2
+		WHERE coalesce(symbol, '') = '' AND (enriched_at IS NULL OR enriched_at < $1)
+	`, staleBefore, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify securities without symbols: %w", err)
+	}
+	result.Updated += tag.RowsAffected()
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT DISTINCT upper(symbol) FROM securities
+		WHERE coalesce(symbol, '') <> '' AND (enriched_at IS NULL OR enriched_at < $1)
+		ORDER BY 1
+	`, staleBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query securities to enrich: %w", err)
+	}
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan security symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query securities to enrich: %w", err)
+	}
+	result.Symbols = len(symbols)
+
+	for i, symbol := range symbols {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		md, cached, err := s.lookup(ctx, symbol, staleBefore, now)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to look up security metadata", "symbol", symbol, "error", err)
+			result.Failed++
+			continue
+		}
+		if cached {
+			result.Cached++
+		} else {
+			result.LookedUp++
+		}
+		if md == nil {
+			result.Unknown++
+			md = &Metadata{Symbol: symbol}
+		}
+
+		tag, err := s.db.Pool.Exec(ctx, `
+			UPDATE securities
+			SET sector = $2, asset_class = coalesce($3, `+typeAssetClass+`),
+			    expense_ratio = $4, logo_url = $5, enriched_at = $6
+			WHERE upper(symbol) = $1
+		`, symbol, md.Sector, md.AssetClass, md.ExpenseRatio, md.LogoURL, now)
+		if err != nil {
+			return result, fmt.Errorf("failed to update security %s: %w", symbol, err)
+		}
+		result.Updated += tag.RowsAffected()
+		progress.Update(ctx, (i+1)*100/len(symbols), i+1)
+	}
+	return result, nil
+}
+
+// lookup returns a symbol's metadata, from the cache when it was fetched
+// after staleBefore and otherwise from the provider, caching the answer.
+// It returns nil metadata for a symbol the provider does not know.
+func (s *Service) lookup(ctx context.Context, symbol string, staleBefore, now time.Time) (md *Metadata, cached bool, err error) {
+	var found bool
+	row := &Metadata{Symbol: symbol}
+	err = s.db.Pool.QueryRow(ctx, `
+		SELECT found, sector, asset_class, expense_ratio, logo_url
+		FROM security_metadata WHERE symbol = $1 AND fetched_at >= $2
+	`, symbol, staleBefore).Scan(&found, &row.Sector, &row.AssetClass, &row.ExpenseRatio, &row.LogoURL)
+	switch {
+	case err == nil && found:
+		return row, true, nil
+	case err == nil:
+		return nil, true, nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, false, fmt.Errorf("failed to query cached security metadata: %w", err)
+	}
+
+	md, err = s.provider.Lookup(ctx, symbol)
+	if errors.Is(err, ErrUnknownSymbol) {
+		md, err = nil, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	cache := md
+	if cache == nil {
+		cache = &Metadata{}
+	}
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO security_metadata (symbol, found, sector, asset_class, expense_ratio, logo_url, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (symbol) DO UPDATE SET
+			found = EXCLUDED.found, sector = EXCLUDED.sector, asset_class = EXCLUDED.asset_class,
+			expense_ratio = EXCLUDED.expense_ratio, logo_url = EXCLUDED.logo_url, fetched_at = EXCLUDED.fetched_at
+	`, symbol, md != nil, cache.Sector, cache.AssetClass, cache.ExpenseRatio, cache.LogoURL, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to cache security metadata: %w", err)
+	}
+	return md, false, nil
+}
@@ -0,0 +1,97 @@
+// Package marketdata classifies securities. A job looks up each held
+// symbol's sector, asset class, fund expense ratio and logo through a
+// market data provider and writes them onto the securities table, so
+// holdings can be broken down by more than the type Plaid reports.
+// Lookups are cached by symbol and shared across users.
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Asset classes
+const (
+	AssetEquity      = "equity"
+	AssetFixedIncome = "fixed_income"
+	AssetCash        = "cash"
+	AssetCrypto      = "crypto"
+	AssetDerivative  = "derivative"
+	AssetOther       = "other"
+)
+
+// ErrUnknownSymbol is returned by a provider for a symbol it has no data on
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// Metadata classifies one symbol. Fields the provider does not know are
+// nil; ExpenseRatio is only set for funds, in percent per year.
+type Metadata struct {
+	Symbol       string           `json:"symbol"`
+	Sector       *string          `json:"sector,omitempty"`
+	AssetClass   *string          `json:"asset_class,omitempty"`
+	ExpenseRatio *decimal.Decimal `json:"expense_ratio,omitempty"`
+	LogoURL      *string          `json:"logo_url,omitempty"`
+}
+
+// Provider looks up the metadata of a symbol
+type Provider interface {
+	Lookup(ctx context.Context, symbol string) (*Metadata, error)
+}
+
+// Client is a market data API client
+type Client struct{}
+
+// NewClient creates a new market data client
+func NewClient() *Client {
+	return &Client{}
+}
+
+// mockSecurity is a row of the mock client's reference data
+type mockSecurity struct {
+	sector       string
+	assetClass   string
+	expenseRatio string
+	domain       string
+}
+
+var mockSecurities = map[string]mockSecurity{
+	"AAPL":  {sector: "Technology", assetClass: AssetEquity, domain: "apple.com"},
+	"MSFT":  {sector: "Technology", assetClass: AssetEquity, domain: "microsoft.com"},
+	"NVDA":  {sector: "Technology", assetClass: AssetEquity, domain: "nvidia.com"},
+	"GOOGL": {sector: "Communication Services", assetClass: AssetEquity, domain: "abc.xyz"},
+	"META":  {sector: "Communication Services", assetClass: AssetEquity, domain: "meta.com"},
+	"AMZN":  {sector: "Consumer Cyclical", assetClass: AssetEquity, domain: "amazon.com"},
+	"TSLA":  {sector: "Consumer Cyclical", assetClass: AssetEquity, domain: "tesla.com"},
+	"JPM":   {sector: "Financial Services", assetClass: AssetEquity, domain: "jpmorganchase.com"},
+	"JNJ":   {sector: "Healthcare", assetClass: AssetEquity, domain: "jnj.com"},
+	"XOM":   {sector: "Energy", assetClass: AssetEquity, domain: "exxonmobil.com"},
+	"VTI":   {sector: "Diversified", assetClass: AssetEquity, expenseRatio: "0.03", domain: "vanguard.com"},
+	"VOO":   {sector: "Diversified", assetClass: AssetEquity, expenseRatio: "0.03", domain: "vanguard.com"},
+	"VXUS":  {sector: "Diversified", assetClass: AssetEquity, expenseRatio: "0.05", domain: "vanguard.com"},
+	"SPY":   {sector: "Diversified", assetClass: AssetEquity, expenseRatio: "0.0945", domain: "ssga.com"},
+	"QQQ":   {sector: "Technology", assetClass: AssetEquity, expenseRatio: "0.20", domain: "invesco.com"},
+	"BND":   {sector: "Bonds", assetClass: AssetFixedIncome, expenseRatio: "0.03", domain: "vanguard.com"},
+	"AGG":   {sector: "Bonds", assetClass: AssetFixedIncome, expenseRatio: "0.03", domain: "ishares.com"},
+	"VMFXX": {sector: "Cash", assetClass: AssetCash, expenseRatio: "0.11", domain: "vanguard.com"},
+}
+
+// Lookup gets a symbol's classification (mock implementation)
+func (c *Client) Lookup(ctx context.Context, symbol string) (*Metadata, error) {
+	symbol = strings.ToUpper(symbol)
+	sec, ok := mockSecurities[symbol]
+	if !ok {
+		return nil, ErrUnknownSymbol
+	}
+
+	md := &Metadata{Symbol: symbol, Sector: &sec.sector, AssetClass: &sec.assetClass}
+	if sec.expenseRatio != "" {
+		ratio := decimal.RequireFromString(sec.expenseRatio)
+		md.ExpenseRatio = &ratio
+	}
+	logo := "https://logo.clearbit.com/" + sec.domain
+	md.LogoURL = &logo
+	return md, nil
+}
@@ -0,0 +1,544 @@
+// Package mcp serves tools and read-only resources over the Model Context
+// Protocol, so agent hosts can call the service directly instead of through
+// a separate bridge. It speaks JSON-RPC 2.0 over stdio, one message per
+// line, and over streamable HTTP, where each POST carries a message or batch
+// and is answered with JSON. Every request acts for whoever its context says
+// the caller is. Only a stdio connection is a session, so only there can a
+// client subscribe to resources and be notified when they change.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProtocolVersions are the protocol revisions the server speaks, newest
+// first
+var ProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	codeResourceNotFound = -32002
+)
+
+// maxMessageBytes bounds a single stdio message
+const maxMessageBytes = 4 << 20
+
+// Tool is a callable tool. Handler receives the raw arguments object and
+// returns a JSON-serialisable result; a returned error is reported to the
+// model as a failed call with the error's message, so it must be fit to
+// show.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]interface{} // JSON Schema of the arguments object
+	Handler     func(ctx context.Context, args json.RawMessage) (interface{}, error)
+}
+
+// Resource is a read-only resource served as JSON. Read returns a
+// JSON-serialisable snapshot for the caller in ctx; like a tool's, its
+// errors are shown to the client.
+type Resource struct {
+	URI         string
+	Name        string
+	Description string
+	Read        func(ctx context.Context) (interface{}, error)
+}
+
+// Watcher sends the URIs of resources that changed for the caller in ctx
+// until ctx is done. An error ends the watch early; changes made after it
+// are not notified.
+type Watcher func(ctx context.Context, changed chan<- string) error
+
+// Server dispatches MCP requests to its tools and resources
+type Server struct {
+	name    string
+	version string
+
+	mu        sync.RWMutex
+	tools     map[string]Tool
+	resources map[string]Resource
+	watcher   Watcher
+}
+
+// NewServer creates a server that introduces itself by name and version
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:      name,
+		version:   version,
+		tools:     make(map[string]Tool),
+		resources: make(map[string]Resource),
+	}
+}
+
+// AddTool registers a tool, replacing any of the same name
+func (s *Server) AddTool(tool Tool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[tool.Name] = tool
+}
+
+// AddResource registers a resource, replacing any with the same URI
+func (s *Server) AddResource(resource Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[resource.URI] = resource
+}
+
+// SetWatcher sets how stdio sessions learn of resource changes. Without a
+// watcher, resources cannot be subscribed to.
+func (s *Server) SetWatcher(watcher Watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watcher = watcher
+}
+
+// session is the state of one stdio connection
+type session struct {
+	mu         sync.Mutex
+	subscribed map[string]bool
+}
+
+func (sess *session) subscribe(uri string, on bool) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if on {
+		sess.subscribed[uri] = true
+	} else {
+		delete(sess.subscribed, uri)
+	}
+}
+
+func (sess *session) isSubscribed(uri string) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.subscribed[uri]
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// ServeStdio reads newline-delimited messages from in and writes responses
+// to out until in is exhausted or ctx is done, notifying the client of
+// changes to the resources it subscribes to. Nothing else may write to out,
+// so logs must go elsewhere.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMu sync.Mutex
+	write := func(msg []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := out.Write(append(msg, '\n'))
+		return err
+	}
+
+	sess := &session{subscribed: make(map[string]bool)}
+	s.mu.RLock()
+	watcher := s.watcher
+	s.mu.RUnlock()
+	if watcher != nil {
+		go s.notifyChanges(ctx, watcher, sess, write)
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageBytes)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if reply := s.handle(ctx, sess, line); reply != nil {
+			if err := write(reply); err != nil {
+				return fmt.Errorf("failed to write response: %w", err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	return nil
+}
+
+// notifyChanges runs the watcher for a session, telling the client about
+// changes to the resources it has subscribed to
+func (s *Server) notifyChanges(ctx context.Context, watcher Watcher, sess *session, write func([]byte) error) {
+	changed := make(chan string, 16)
+	go func() {
+		if err := watcher(ctx, changed); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Stopped watching MCP resources", "error", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case uri := <-changed:
+			if !sess.isSubscribed(uri) {
+				continue
+			}
+			msg := marshal(notification{
+				JSONRPC: "2.0",
+				Method:  "notifications/resources/updated",
+				Params:  map[string]string{"uri": uri},
+			})
+			if err := write(msg); err != nil {
+				slog.ErrorContext(ctx, "Failed to notify MCP resource change", "uri", uri, "error", err)
+			}
+		}
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport. Clients POST
+// messages; responses come back as JSON, and posts holding only
+// notifications are accepted without a body. The server never opens a
+// stream of its own, so GET is not allowed.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "MCP messages must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	reply := s.handle(r.Context(), nil, body)
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
+}
+
+// handle answers one message or batch, returning nil when nothing needs a
+// reply. sess is nil outside a stdio session.
+func (s *Server) handle(ctx context.Context, sess *session, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil || len(batch) == 0 {
+			return marshal(errorResponse(nil, codeInvalidRequest, "Invalid batch"))
+		}
+		replies := []*response{}
+		for _, msg := range batch {
+			if resp := s.handleMessage(ctx, sess, msg); resp != nil {
+				replies = append(replies, resp)
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		return marshal(replies)
+	}
+
+	if resp := s.handleMessage(ctx, sess, data); resp != nil {
+		return marshal(resp)
+	}
+	return nil
+}
+
+func (s *Server) handleMessage(ctx context.Context, sess *session, data json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errorResponse(nil, codeParseError, "Parse error")
+	}
+	if req.JSONRPC != "2.0" {
+		return errorResponse(req.ID, codeInvalidRequest, "jsonrpc must be \"2.0\"")
+	}
+	if req.Method == "" {
+		// A client's response; the server sends no requests to answer
+		return nil
+	}
+
+	notification := len(req.ID) == 0
+	result, rpcErr := s.dispatch(ctx, sess, req)
+	if notification {
+		return nil
+	}
+	if rpcErr != nil {
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func (s *Server) dispatch(ctx context.Context, sess *session, req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		subscribe := sess != nil && s.watcher != nil
+		s.mu.RUnlock()
+		return map[string]interface{}{
+			"protocolVersion": negotiateVersion(params.ProtocolVersion),
+			"capabilities": map[string]interface{}{
+				"tools":     map[string]interface{}{"listChanged": false},
+				"resources": map[string]interface{}{"listChanged": false, "subscribe": subscribe},
+			},
+			"serverInfo": map[string]string{"name": s.name, "version": s.version},
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": s.listTools()}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		tool, ok := s.tools[params.Name]
+		s.mu.RUnlock()
+		if !ok {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "Unknown tool: " + params.Name}
+		}
+		return s.callTool(ctx, tool, params.Arguments), nil
+
+	case "resources/list":
+		return map[string]interface{}{"resources": s.listResources()}, nil
+
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": []interface{}{}}, nil
+
+	case "resources/read":
+		resource, rpcErr := s.resource(req.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return s.readResource(ctx, resource)
+
+	case "resources/subscribe", "resources/unsubscribe":
+		resource, rpcErr := s.resource(req.Params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		s.mu.RLock()
+		watched := s.watcher != nil
+		s.mu.RUnlock()
+		if sess == nil || !watched {
+			return nil, &rpcError{Code: codeInvalidRequest, Message: "Subscriptions need a stdio session"}
+		}
+		sess.subscribe(resource.URI, req.Method == "resources/subscribe")
+		return map[string]interface{}{}, nil
+
+	default:
+		if strings.HasPrefix(req.Method, "notifications/") {
+			return nil, nil
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "Method not found: " + req.Method}
+	}
+}
+
+func (s *Server) listTools() []map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		tool := s.tools[name]
+		schema := tool.InputSchema
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"inputSchema": schema,
+		})
+	}
+	return tools
+}
+
+func (s *Server) listResources() []map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	uris := make([]string, 0, len(s.resources))
+	for uri := range s.resources {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	resources := make([]map[string]interface{}, 0, len(uris))
+	for _, uri := range uris {
+		resource := s.resources[uri]
+		resources = append(resources, map[string]interface{}{
+			"uri":         resource.URI,
+			"name":        resource.Name,
+			"description": resource.Description,
+			"mimeType":    "application/json",
+		})
+	}
+	return resources
+}
+
+// resource looks up the resource named by a request's uri parameter
+func (s *Server) resource(params json.RawMessage) (Resource, *rpcError) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return Resource{}, err
+	}
+	s.mu.RLock()
+	resource, ok := s.resources[p.URI]
+	s.mu.RUnlock()
+	if !ok {
+		return Resource{}, &rpcError{Code: codeResourceNotFound, Message: "Resource not found: " + p.URI}
+	}
+	return resource, nil
+}
+
+// readResource reads a resource as JSON text. Unlike a failed tool call, a
+// failed read is a protocol error.
+func (s *Server) readResource(ctx context.Context, resource Resource) (interface{}, *rpcError) {
+	result, err := resource.Read(ctx)
+	if err != nil {
+		slog.DebugContext(ctx, "MCP resource read failed", "uri", resource.URI, "error", err)
+		return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode MCP resource", "uri", resource.URI, "error", err)
+		return nil, &rpcError{Code: codeInternalError, Message: "Failed to encode resource"}
+	}
+	return map[string]interface{}{
+		"contents": []map[string]string{{
+			"uri":      resource.URI,
+			"mimeType": "application/json",
+			"text":     string(data),
+		}},
+	}, nil
+}
+
+// callTool runs a tool, reporting its result as JSON text and, when it is
+// an object, as structured content too. Tool errors are results marked
+// isError rather than protocol errors, so the model can see and act on
+// them.
+func (s *Server) callTool(ctx context.Context, tool Tool, args json.RawMessage) map[string]interface{} {
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage("{}")
+	}
+
+	result, err := tool.Handler(ctx, args)
+	if err != nil {
+		slog.DebugContext(ctx, "MCP tool call failed", "tool", tool.Name, "error", err)
+		return toolText(err.Error(), true)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode MCP tool result", "tool", tool.Name, "error", err)
+		return toolText("Failed to encode result", true)
+	}
+	reply := toolText(string(data), false)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		reply["structuredContent"] = json.RawMessage(data)
+	}
+	return reply
+}
+
+func toolText(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func negotiateVersion(requested string) string {
+	for _, v := range ProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return ProtocolVersions[0]
+}
+
+func decodeParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
+
+func marshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(nil, codeInternalError, "Internal error"))
+	}
+	return data
+}
@@ -0,0 +1,290 @@
+// Package metrics exports the service's Prometheus metrics: request latency
+// per route, job outcomes and durations, Plaid API calls and webhook lag,
+// order placement, upstream circuit breakers, injected faults, and queue
+// depth and connection pool stats read at scrape time
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "finagent"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	jobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_total",
+		Help:      "Job runs by type and outcome (completed, error, interrupted). A failed run is retried until its attempts are used up.",
+	}, []string{"type", "outcome"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Job run time by type, whatever the outcome.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"type"})
+
+	plaidRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plaid_requests_total",
+		Help:      "Plaid API calls by operation and outcome (ok, error).",
+	}, []string{"operation", "outcome"})
+
+	plaidDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "plaid_request_duration_seconds",
+		Help:      "Plaid API call latency by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	ordersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_total",
+		Help:      "Crypto orders by side, mode (dry_run, live) and outcome (placed, locked, rate_limited, quota_exceeded, failed).",
+	}, []string{"side", "mode", "outcome"})
+
+	transfersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "ACH transfers by type, mode (dry_run, live) and outcome (authorized, declined, locked, rate_limited, failed).",
+	}, []string{"type", "mode", "outcome"})
+
+	breakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Upstream circuit breaker state by provider: 0 closed, 1 half-open, 2 open.",
+	}, []string{"provider"})
+
+	breakerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Upstream calls rejected without being made because the provider's breaker was open.",
+	}, []string{"provider"})
+
+	faultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "faults_injected_total",
+		Help:      "Failures injected for resilience testing by kind.",
+	}, []string{"kind"})
+
+	webhookLag = prometheus.NewSummary(prometheus.SummaryOpts{
+		Namespace:  namespace,
+		Name:       "plaid_webhook_lag_seconds",
+		Help:       "Time from receiving a Plaid webhook to its data being persisted, with p50 and p95 over the last 10 minutes.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.95: 0.01},
+		MaxAge:     10 * time.Minute,
+	})
+
+	webhookLagBreaches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plaid_webhook_lag_slo_breaches_total",
+		Help:      "Plaid webhooks whose data was persisted later than the lag SLO allows.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpDuration, jobsTotal, jobDuration, plaidRequests, plaidDuration, ordersTotal, transfersTotal,
+		breakerState, breakerRejections, faultsInjected, webhookLag, webhookLagBreaches,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// Middleware records the latency of each request under its chi route
+// pattern, so path parameters don't multiply series. Requests that matched
+// no route share one label.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveJob records one run of a job
+func ObserveJob(jobType, outcome string, duration time.Duration) {
+	jobsTotal.WithLabelValues(jobType, outcome).Inc()
+	jobDuration.WithLabelValues(jobType).Observe(duration.Seconds())
+}
+
+// ObservePlaidRequest records one Plaid API call started at start
+func ObservePlaidRequest(operation string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	plaidRequests.WithLabelValues(operation, outcome).Inc()
+	plaidDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveWebhookLag records how long a Plaid webhook took to be persisted
+// and whether that breached the SLO
+func ObserveWebhookLag(lag time.Duration, breached bool) {
+	webhookLag.Observe(lag.Seconds())
+	if breached {
+		webhookLagBreaches.Inc()
+	}
+}
+
+// ObserveOrder records one crypto order request
+func ObserveOrder(side string, dryRun bool, outcome string) {
+	mode := "live"
+	if dryRun {
+		mode = "dry_run"
+	}
+	ordersTotal.WithLabelValues(side, mode, outcome).Inc()
+}
+
+// ObserveTransfer records one transfer request
+func ObserveTransfer(transferType string, dryRun bool, outcome string) {
+	mode := "live"
+	if dryRun {
+		mode = "dry_run"
+	}
+	transfersTotal.WithLabelValues(transferType, mode, outcome).Inc()
+}
+
+// SetBreakerState records the state of a provider's circuit breaker
+func SetBreakerState(provider string, state int) {
+	breakerState.WithLabelValues(provider).Set(float64(state))
+}
+
+// ObserveBreakerRejection records a call rejected by an open breaker
+func ObserveBreakerRejection(provider string) {
+	breakerRejections.WithLabelValues(provider).Inc()
+}
+
+// ObserveFault records an injected failure
+func ObserveFault(kind string) {
+	faultsInjected.WithLabelValues(kind).Inc()
+}
+
+// RegisterDatabase adds the connection pool stats and the job queue depth,
+// both read when scraped
+func RegisterDatabase(db *database.Database) {
+	registry.MustRegister(&dbCollector{db: db})
+}
+
+var (
+	poolConns = prometheus.NewDesc(namespace+"_db_pool_connections",
+		"Connections in the primary pool by state (idle, acquired, constructing).", []string{"state"}, nil)
+	poolMaxConns = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"Maximum size of the primary pool.", nil, nil)
+	poolAcquires = prometheus.NewDesc(namespace+"_db_pool_acquires_total",
+		"Connections acquired from the primary pool.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total",
+		"Acquires that waited because the primary pool had no idle connection.", nil, nil)
+	poolAcquireWait = prometheus.NewDesc(namespace+"_db_pool_acquire_wait_seconds_total",
+		"Time spent waiting to acquire connections from the primary pool.", nil, nil)
+	queueDepth = prometheus.NewDesc(namespace+"_job_queue_depth",
+		"Unfinished jobs by type and status (pending, running, retryable).", []string{"type", "status"}, nil)
+	queueOldest = prometheus.NewDesc(namespace+"_job_queue_oldest_ready_seconds",
+		"Age of the oldest job ready to run, 0 when none are waiting.", nil, nil)
+)
+
+// dbCollector reads pool stats and queue depth on each scrape rather than
+// on a timer, so idle replicas of the service don't query the database
+type dbCollector struct {
+	db *database.Database
+}
+
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolConns
+	ch <- poolMaxConns
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolAcquireWait
+	ch <- queueDepth
+	ch <- queueOldest
+}
+
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.db.Pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.IdleConns()), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.ConstructingConns()), "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.collectQueue(ctx, ch); err != nil {
+		ch <- prometheus.NewInvalidMetric(queueDepth, err)
+	}
+}
+
+func (c *dbCollector) collectQueue(ctx context.Context, ch chan<- prometheus.Metric) error {
+	rows, err := c.db.Pool.Query(ctx, `
+		SELECT job_type, status, COUNT(*)
+		FROM jobs
+		WHERE status IN ('pending', 'running', 'retryable')
+		GROUP BY job_type, status
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobType, status string
+		var count int64
+		if err := rows.Scan(&jobType, &status, &count); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepth, prometheus.GaugeValue, float64(count), jobType, status)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var oldest float64
+	err = c.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(run_at)), 0)::float8
+		FROM jobs
+		WHERE status IN ('pending', 'retryable') AND run_at <= NOW()
+	`).Scan(&oldest)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(queueOldest, prometheus.GaugeValue, oldest)
+	return nil
+}
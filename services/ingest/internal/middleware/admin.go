@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// AdminTokenHeader can be used instead of a bearer Authorization header
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth requires the operator admin token on every request. When no
+// token is configured the admin API is disabled entirely.
+func AdminAuth(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminToken == "" {
+				writeError(w, http.StatusNotFound, "Admin API is disabled")
+				return
+			}
+
+			token := r.Header.Get(AdminTokenHeader)
+			if token == "" {
+				token = bearerToken(r)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				writeError(w, http.StatusUnauthorized, "Invalid admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// writeError writes an error in the same envelope the handlers use
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   message,
+	})
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/security"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// BlockFailedAuth rejects IPs blocked after repeated authentication
+// failures and counts 401 responses towards that threshold. Must wrap
+// Authenticate.
+func BlockFailedAuth(detector *security.Detector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			blocked, err := detector.IPBlocked(r.Context(), ip)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check blocked IP", "error", err)
+			}
+			if blocked {
+				writeError(w, http.StatusForbidden, "Too many failed authentication attempts")
+				return
+			}
+
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() == http.StatusUnauthorized {
+				if err := detector.RecordAuthFailure(r.Context(), ip); err != nil {
+					slog.ErrorContext(r.Context(), "Failed to record auth failure", "error", err)
+				}
+			}
+		})
+	}
+}
+
+// ObserveClientIP records the IP addresses each user signs in from, which
+// the detector compares against for sensitive actions. Must run after
+// Authenticate.
+func ObserveClientIP(detector *security.Detector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if p, ok := auth.PrincipalFromContext(r.Context()); ok && !p.IsService() {
+				if err := detector.ObserveIP(r.Context(), p.UserID, clientIP(r)); err != nil {
+					slog.ErrorContext(r.Context(), "Failed to record client IP", "error", err)
+				}
+			}
+		})
+	}
+}
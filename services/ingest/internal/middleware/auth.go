@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/apikeys"
+	"github.com/finagent/ingest/internal/auth"
+)
+
+// APIKeyHeader carries service-to-service API keys
+const APIKeyHeader = "X-API-Key"
+
+// SessionCookie carries the user's JWT for browser sessions
+const SessionCookie = "finagent_session"
+
+// UserResolver maps an identity provider subject to the internal user ID
+type UserResolver func(ctx context.Context, authID string) (string, error)
+
+// Authenticate identifies the caller from an API key (internal services) or
+// a JWT (end users), read from the Authorization header or else the session
+// cookie, and stores the principal in the request context.
+// With no JWT verifier configured, requests without an API key pass through
+// unauthenticated; the service refuses to start that way unless
+// AUTH_DISABLED is set for local development.
+func Authenticate(verifier *auth.Verifier, keys *apikeys.Store, resolveUser UserResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" && keys != nil {
+				key, err := keys.Authenticate(r.Context(), apiKey)
+				if errors.Is(err, apikeys.ErrInvalidKey) {
+					writeError(w, http.StatusUnauthorized, "Invalid API key")
+					return
+				}
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "Failed to verify API key")
+					return
+				}
+
+				principal := &auth.Principal{
+					APIKeyID: key.ID,
+					Name:     key.Name,
+					Scopes:   key.Scopes,
+					Redact:   key.Redact,
+				}
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			if verifier == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, fromCookie := bearerToken(r), false
+			if token == "" {
+				if c, err := r.Cookie(SessionCookie); err == nil && c.Value != "" {
+					token, fromCookie = c.Value, true
+				}
+			}
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="finagent"`)
+				writeError(w, http.StatusUnauthorized, "Missing bearer token")
+				return
+			}
+
+			principal, err := verifier.Verify(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="finagent", error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+
+			principal.FromCookie = fromCookie
+			principal.UserID, err = resolveUser(r.Context(), principal.AuthID)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "Unknown user")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequireScope rejects authenticated callers lacking scope. Unauthenticated
+// requests (authentication disabled) are let through.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := auth.PrincipalFromContext(r.Context()); ok && !p.HasScope(scope) {
+				writeError(w, http.StatusForbidden, "Insufficient scope: "+scope+" required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
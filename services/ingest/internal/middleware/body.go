@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LimitBody caps request bodies at maxBytes, or multipart file uploads at
+// uploadBytes, and rejects JSON payloads nested deeper than maxDepth. JSON
+// bodies are buffered so the depth check runs before any handler starts
+// decoding; handlers refuse to decode JSON sent as any other type.
+func LimitBody(maxBytes, uploadBytes int64, maxDepth int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			maxBytes := maxBytes
+			if isMultipartRequest(r) && uploadBytes > maxBytes {
+				maxBytes = uploadBytes
+			}
+
+			if r.ContentLength > maxBytes {
+				writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+			if !isJSONRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				writeError(w, http.StatusBadRequest, "Failed to read request body")
+				return
+			}
+			if exceedsDepth(body, maxDepth) {
+				writeError(w, http.StatusBadRequest, "Request body nested too deeply")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isMultipartRequest reports whether the body is a multipart form, the
+// encoding file uploads use
+func isMultipartRequest(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/form-data")
+}
+
+// isJSONRequest reports whether the body should be treated as JSON. Clients
+// that omit Content-Type are assumed to send JSON, as every endpoint expects it.
+func isJSONRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "" || strings.Contains(strings.ToLower(contentType), "json")
+}
+
+// exceedsDepth reports whether data nests objects or arrays deeper than
+// maxDepth. It scans bytes rather than tokens so the check itself cannot be
+// used to exhaust memory; malformed JSON is left for the decoder to reject.
+func exceedsDepth(data []byte, maxDepth int) bool {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/finagent/ingest/internal/cache"
+)
+
+// CacheBypass makes requests sent with Cache-Control: no-cache skip the
+// read cache and refresh it from the database
+func CacheBypass(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+			r = r.WithContext(cache.WithBypass(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
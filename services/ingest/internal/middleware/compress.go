@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the content types worth gzipping
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"text/",
+	"image/svg+xml",
+}
+
+// Compress gzips responses larger than minSize bytes for clients that accept
+// it. Bodies are buffered until the threshold is reached so small responses
+// are sent uncompressed with their original headers.
+func Compress(level, minSize int) func(http.Handler) http.Handler {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+
+	pool := &sync.Pool{
+		New: func() interface{} {
+			gz, _ := gzip.NewWriterLevel(nil, level)
+			return gz
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acceptsGzip(r) || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				pool:           pool,
+				minSize:        minSize,
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if enc == "gzip" || strings.HasPrefix(enc, "gzip;") {
+			return !strings.HasSuffix(strings.ReplaceAll(enc, " ", ""), "q=0")
+		}
+	}
+	return false
+}
+
+func isCompressible(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	contentType = strings.ToLower(contentType)
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the response until it can decide whether to compress
+type compressWriter struct {
+	http.ResponseWriter
+	pool    *sync.Pool
+	minSize int
+
+	gz          *gzip.Writer
+	buf         []byte
+	statusCode  int
+	wroteHeader bool // header captured from the handler
+	decided     bool // headers flushed to the underlying writer
+	passthrough bool
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+
+	// Responses without bodies, or already encoded ones, pass straight through
+	if statusCode < 200 || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified ||
+		cw.Header().Get("Content-Encoding") != "" {
+		cw.passthrough = true
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(p)
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) < cw.minSize {
+		return len(p), nil
+	}
+
+	if err := cw.decide(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decide flushes the buffered body, compressed if it is large and compressible enough
+func (cw *compressWriter) decide() error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if len(cw.buf) < cw.minSize || !isCompressible(h.Get("Content-Type")) {
+		cw.passthrough = true
+		cw.ResponseWriter.WriteHeader(cw.statusCode)
+		_, err := cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+		return err
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	cw.gz = cw.pool.Get().(*gzip.Writer)
+	cw.gz.Reset(cw.ResponseWriter)
+
+	_, err := cw.gz.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// Flush sends any buffered data, compressing it if appropriate
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if err := cw.decide(); err != nil {
+		return
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports connection upgrades through the wrapper
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+}
+
+// Close finishes the response, writing any small buffered body uncompressed
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		// Handler never wrote anything; let net/http send its default response
+		return nil
+	}
+	if err := cw.decide(); err != nil {
+		return err
+	}
+	if cw.gz == nil {
+		return nil
+	}
+
+	err := cw.gz.Close()
+	cw.gz.Reset(nil)
+	cw.pool.Put(cw.gz)
+	cw.gz = nil
+	return err
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/auth"
+)
+
+// CSRF double-submit cookie and header names
+const (
+	CSRFCookie = "finagent_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// csrfTokenTTL is how long an issued CSRF token remains valid
+const csrfTokenTTL = 12 * time.Hour
+
+// CSRF rejects mutating requests authenticated by the session cookie unless
+// the X-CSRF-Token header matches the CSRF cookie. Requests carrying an
+// Authorization header or API key cannot be forged cross-site and are not
+// checked. Must run after Authenticate.
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, ok := auth.PrincipalFromContext(r.Context())
+		if !ok || !p.FromCookie {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(CSRFCookie)
+		header := r.Header.Get(CSRFHeader)
+		if err != nil || cookie.Value == "" || header == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+			writeError(w, http.StatusForbidden, "Missing or invalid CSRF token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFToken issues a CSRF token for the SPA, setting it as a cookie and
+// returning it in the body to be echoed in the X-CSRF-Token header. An
+// existing token is reused so concurrent tabs keep working.
+func CSRFToken(secureCookie bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if c, err := r.Cookie(CSRFCookie); err == nil && len(c.Value) == 43 {
+			token = c.Value
+		}
+		if token == "" {
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to generate CSRF token")
+				return
+			}
+			token = base64.RawURLEncoding.EncodeToString(buf)
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     CSRFCookie,
+			Value:    token,
+			Path:     "/",
+			MaxAge:   int(csrfTokenTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   secureCookie,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"csrf_token": token,
+				"header":     CSRFHeader,
+			},
+		})
+	}
+}
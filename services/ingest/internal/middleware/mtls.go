@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/mtls"
+)
+
+// RequireClientCert rejects requests without a verified TLS client
+// certificate carrying one of the allowed SANs
+func RequireClientCert(allowedSANs []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !mtls.Allowed(mtls.PeerSANs(r), allowedSANs) {
+				writeError(w, http.StatusForbidden, "Client certificate required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireServiceClientCert applies RequireClientCert to service principals
+// only, so internal callers using API keys must also present a certificate
+// while end users authenticate with JWTs alone. Must run after Authenticate.
+func RequireServiceClientCert(allowedSANs []string) func(http.Handler) http.Handler {
+	requireCert := RequireClientCert(allowedSANs)
+	return func(next http.Handler) http.Handler {
+		withCert := requireCert(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := auth.PrincipalFromContext(r.Context()); ok && p.IsService() {
+				withCert.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
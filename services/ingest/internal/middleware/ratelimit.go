@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/ratelimit"
+)
+
+// KeyFunc identifies the client a rate limit applies to
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by client IP. RealIP must run first when behind a proxy,
+// with the proxy trusted, or every client shares the proxy's limit.
+func ByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the client address without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByPrincipal keys requests by authenticated user or API key, falling back
+// to client IP when authentication is disabled
+func ByPrincipal(r *http.Request) string {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return ByIP(r)
+	}
+	if p.IsService() {
+		return "api_key:" + p.APIKeyID
+	}
+	return "user:" + p.UserID
+}
+
+// RateLimit rejects requests beyond limit for each client in the named tier
+// and sets X-RateLimit-* headers. Tiers are layered by nesting: since inner
+// tiers are stricter, their headers overwrite those of outer ones. Requests
+// are let through if Redis is unavailable.
+func RateLimit(limiter *ratelimit.Limiter, tier string, limit ratelimit.Limit, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit.Requests <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.Allow(r.Context(), tier+":"+key(r), limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to check rate limit", "tier", tier, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter().Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses the addresses of the proxies in front of the
+// service, each an IP or CIDR range
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// RealIP sets RemoteAddr to the client address forwarded by the trusted
+// proxies. X-Forwarded-For is read from the right, skipping trusted hops,
+// since everything left of the last proxy is whatever the client sent; the
+// headers are ignored unless the connection comes from a trusted proxy.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trusted) == 0 || !isTrusted(clientIP(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var hops []string
+			for _, header := range r.Header.Values("X-Forwarded-For") {
+				for _, hop := range strings.Split(header, ",") {
+					hops = append(hops, strings.TrimSpace(hop))
+				}
+			}
+			if len(hops) == 0 {
+				if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+					r.RemoteAddr = realIP
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for i := len(hops) - 1; i >= 0; i-- {
+				if net.ParseIP(hops[i]) == nil {
+					break
+				}
+				r.RemoteAddr = hops[i]
+				if !isTrusted(hops[i]) {
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
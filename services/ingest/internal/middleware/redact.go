@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/redact"
+)
+
+// RedactedHeader is set on responses whose PII has been masked
+const RedactedHeader = "X-Redacted"
+
+// Redact masks account numbers, locations and identity fields in JSON
+// responses when the caller asks for it with redact=true or its API key has
+// a redaction policy. A policy cannot be switched off per request. Must run
+// after Authenticate.
+func Redact(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wantsRedaction(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &redactWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		rw.finish()
+	})
+}
+
+func wantsRedaction(r *http.Request) bool {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok && p.Redact {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(r.URL.Query().Get("redact"))
+	return enabled
+}
+
+// redactWriter buffers JSON responses so they can be redacted as a whole.
+// Other content types, such as export archives, pass straight through.
+type redactWriter struct {
+	http.ResponseWriter
+	buf         bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	passthrough bool
+}
+
+func (rw *redactWriter) WriteHeader(statusCode int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.statusCode = statusCode
+
+	contentType := strings.ToLower(rw.Header().Get("Content-Type"))
+	if !strings.Contains(contentType, "json") {
+		rw.passthrough = true
+		rw.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.passthrough {
+		return rw.ResponseWriter.Write(p)
+	}
+	return rw.buf.Write(p)
+}
+
+// finish redacts and sends the buffered body. Bodies that fail to parse are
+// replaced with an error rather than risk leaking unredacted data.
+func (rw *redactWriter) finish() {
+	if rw.passthrough || !rw.wroteHeader {
+		return
+	}
+
+	h := rw.Header()
+	h.Del("Content-Length")
+	h.Set(RedactedHeader, "true")
+
+	if rw.buf.Len() == 0 {
+		rw.ResponseWriter.WriteHeader(rw.statusCode)
+		return
+	}
+
+	body, err := redact.JSON(rw.buf.Bytes())
+	if err != nil {
+		slog.Error("Failed to redact response", "error", err)
+		writeError(rw.ResponseWriter, http.StatusInternalServerError, "Failed to redact response")
+		return
+	}
+
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+	rw.ResponseWriter.Write(body)
+}
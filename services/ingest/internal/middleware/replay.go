@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/plaid"
+	"github.com/finagent/ingest/internal/replay"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// PlaidVerificationHeader carries the JWT Plaid signs each webhook with
+const PlaidVerificationHeader = "Plaid-Verification"
+
+// WebhookVerifier authenticates a Plaid-Verification token against the
+// body it was sent with, returning when it was issued and a nonce unique to
+// the token.
+// Tokens that are not valid are reported as plaid.ErrInvalidWebhook.
+type WebhookVerifier interface {
+	Verify(ctx context.Context, token string, body []byte) (time.Time, string, error)
+}
+
+// PlaidWebhookReplay accepts only Plaid webhook deliveries whose
+// Plaid-Verification token is signed by Plaid, covers the body, and was
+// issued within the guard's window, and rejects those already received
+// within it, keyed by the token, as Plaid sends the same body for each new
+// batch of updates to an item. Nonces of deliveries that
+// fail with a server error are released so Plaid's retries are processed.
+func PlaidWebhookReplay(guard *replay.Guard, verifier WebhookVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sentAt, nonce, err := verifier.Verify(r.Context(), r.Header.Get(PlaidVerificationHeader), body)
+			if errors.Is(err, plaid.ErrInvalidWebhook) {
+				slog.WarnContext(r.Context(), "Rejected unverified Plaid webhook", "error", err)
+				writeError(w, http.StatusUnauthorized, "Invalid Plaid-Verification header")
+				return
+			}
+			if err != nil {
+				// Plaid retries deliveries answered with a server error
+				slog.ErrorContext(r.Context(), "Failed to verify Plaid webhook", "error", err)
+				writeError(w, http.StatusServiceUnavailable, "Failed to verify webhook")
+				return
+			}
+
+			err = guard.Check(r.Context(), "plaid_webhook", nonce, sentAt)
+			switch {
+			case errors.Is(err, replay.ErrReplayed):
+				writeError(w, http.StatusConflict, "Duplicate webhook delivery")
+				return
+			case errors.Is(err, replay.ErrExpired):
+				writeError(w, http.StatusBadRequest, "Webhook delivery outside replay window")
+				return
+			case err != nil:
+				// The delivery is authentic and recent, and duplicates are
+				// harmless to the sync pipeline, so fail open
+				slog.ErrorContext(r.Context(), "Failed to check webhook replay", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() >= http.StatusInternalServerError {
+				if err := guard.Forget(r.Context(), "plaid_webhook", nonce); err != nil {
+					slog.ErrorContext(r.Context(), "Failed to release webhook nonce", "error", err)
+				}
+			}
+		})
+	}
+}
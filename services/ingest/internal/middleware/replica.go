@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/finagent/ingest/internal/database"
+)
+
+// PreferReplica lets the request's read queries be served by the read
+// replica. Use it only on read-only routes that tolerate replication lag.
+func PreferReplica(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(database.WithReplica(r.Context())))
+	})
+}
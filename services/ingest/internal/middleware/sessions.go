@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/sessions"
+)
+
+// TrackSessions records the device and IP of each user session and rejects
+// requests whose session has been revoked. Service principals are not
+// tracked. Requests are let through if the session store is unavailable.
+// Must run after Authenticate.
+func TrackSessions(store *sessions.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			if !ok || p.IsService() || p.TokenID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			err := store.Touch(r.Context(), p.UserID, p.TokenID, r.UserAgent(), clientIP(r))
+			if errors.Is(err, sessions.ErrRevoked) {
+				writeError(w, http.StatusUnauthorized, "Session has been revoked")
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to track session", "error", err)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
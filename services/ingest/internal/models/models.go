@@ -1,118 +1,499 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
+// Amounts, balances, prices and quantities are decimal.Decimal rather than
+// float64 so cents and fractional crypto quantities round-trip exactly. They
+// marshal to JSON as strings.
+
 // Account represents a financial account
 type Account struct {
-	ID               string     `json:"id"`
-	Name             string     `json:"name"`
-	Mask             *string    `json:"mask,omitempty"`
-	OfficialName     *string    `json:"official_name,omitempty"`
-	Type             string     `json:"type"`
-	Subtype          *string    `json:"subtype,omitempty"`
-	Currency         string     `json:"currency"`
-	BalanceCurrent   *float64   `json:"balance_current,omitempty"`
-	BalanceAvailable *float64   `json:"balance_available,omitempty"`
-	BalanceLimit     *float64   `json:"balance_limit,omitempty"`
-	IsClosed         bool       `json:"is_closed"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	ID                   string           `json:"id"`
+	Name                 string           `json:"name"`
+	Nickname             *string          `json:"nickname,omitempty"`
+	Mask                 *string          `json:"mask,omitempty"`
+	OfficialName         *string          `json:"official_name,omitempty"`
+	Type                 string           `json:"type"`
+	Subtype              *string          `json:"subtype,omitempty"`
+	Currency             string           `json:"currency"`
+	BalanceCurrent       *decimal.Decimal `json:"balance_current,omitempty"`
+	BalanceAvailable     *decimal.Decimal `json:"balance_available,omitempty"`
+	BalanceLimit         *decimal.Decimal `json:"balance_limit,omitempty"`
+	IsClosed             bool             `json:"is_closed"`
+	Hidden               bool             `json:"hidden"`
+	ExcludeFromAnalytics bool             `json:"exclude_from_analytics"`
+	GroupID              *string          `json:"group_id,omitempty"`
+	StatementCloseDay    *int             `json:"statement_close_day,omitempty"` // credit cards only
+	APY                  *decimal.Decimal `json:"apy,omitempty"`                 // depository accounts, in percent
+	APYSource            *string          `json:"apy_source,omitempty"`          // user or provider
+	UpdatedAt            time.Time        `json:"updated_at"`
+}
+
+// Counted reports whether the account counts toward net worth, budgets
+// and summaries
+func (a Account) Counted() bool {
+	return !a.IsClosed && !a.Hidden && !a.ExcludeFromAnalytics
+}
+
+// DisplayName is the account's nickname, or its name if it has none
+func (a Account) DisplayName() string {
+	if a.Nickname != nil && *a.Nickname != "" {
+		return *a.Nickname
+	}
+	return a.Name
 }
 
 // Transaction represents a financial transaction
 type Transaction struct {
-	ID               string     `json:"id"`
-	AccountID        string     `json:"account_id"`
-	Date             time.Time  `json:"date"`
-	Amount           float64    `json:"amount"`
-	MerchantName     *string    `json:"merchant_name,omitempty"`
-	Category         []string   `json:"category,omitempty"`
-	CategoryDetailed []string   `json:"category_detailed,omitempty"`
-	Description      *string    `json:"description,omitempty"`
-	IsPending        bool       `json:"is_pending"`
-	AccountName      *string    `json:"account_name,omitempty"`
-	AccountMask      *string    `json:"account_mask,omitempty"`
+	ID                              string          `json:"id"`
+	AccountID                       string          `json:"account_id"`
+	Date                            time.Time       `json:"date"`
+	Amount                          decimal.Decimal `json:"amount"`
+	MerchantName                    *string         `json:"merchant_name,omitempty"`
+	Category                        []string        `json:"category,omitempty"`
+	CategoryDetailed                []string        `json:"category_detailed,omitempty"`
+	Description                     *string         `json:"description,omitempty"`
+	IsPending                       bool            `json:"is_pending"`
+	AccountName                     *string         `json:"account_name,omitempty"`
+	AccountMask                     *string         `json:"account_mask,omitempty"`
+	AccountType                     string          `json:"account_type,omitempty"`
+	Location                        *Location       `json:"location,omitempty"`
+	CheckNumber                     *string         `json:"check_number,omitempty"`
+	IsATMWithdrawal                 bool            `json:"is_atm_withdrawal"`
+	ExpenseType                     *string         `json:"expense_type,omitempty"`
+	PersonalFinanceCategory         *string         `json:"personal_finance_category,omitempty"`
+	PersonalFinanceCategoryDetailed *string         `json:"personal_finance_category_detailed,omitempty"`
+}
+
+// Location is where a transaction took place, as Plaid reports it. Any
+// field may be missing, online purchases usually having none.
+type Location struct {
+	Address     *string  `json:"address"`
+	City        *string  `json:"city"`
+	Region      *string  `json:"region"`
+	PostalCode  *string  `json:"postal_code"`
+	Country     *string  `json:"country"`
+	Lat         *float64 `json:"lat"`
+	Lon         *float64 `json:"lon"`
+	StoreNumber *string  `json:"store_number,omitempty"`
+}
+
+// GeoCluster groups the located transactions in one area of a map
+type GeoCluster struct {
+	Lat              float64         `json:"lat"` // centroid of the transactions
+	Lon              float64         `json:"lon"`
+	TransactionCount int             `json:"transaction_count"`
+	Spent            decimal.Decimal `json:"spent"`
+	City             *string         `json:"city,omitempty"`
+	Merchants        []string        `json:"merchants"` // most spent at first
+}
+
+// Attachment is a receipt or document attached to a transaction. URL is a
+// signed download link valid until URLExpiresAt.
+type Attachment struct {
+	ID            string     `json:"id"`
+	TransactionID string     `json:"transaction_id"`
+	Filename      string     `json:"filename"`
+	ContentType   string     `json:"content_type"`
+	SizeBytes     int64      `json:"size_bytes"`
+	SHA256        string     `json:"sha256"`
+	URL           string     `json:"url,omitempty"`
+	URLExpiresAt  *time.Time `json:"url_expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Statement is a monthly account statement from the institution, kept as
+// a PDF and downloaded through a signed URL
+type Statement struct {
+	ID           string     `json:"id"`
+	AccountID    string     `json:"account_id"`
+	Year         int        `json:"year"`
+	Month        int        `json:"month"`
+	DatePosted   *time.Time `json:"date_posted,omitempty"`
+	SizeBytes    int64      `json:"size_bytes"`
+	SHA256       string     `json:"sha256"`
+	URL          string     `json:"url"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 // Holding represents an investment holding
 type Holding struct {
-	ID                string     `json:"id"`
-	AccountID         string     `json:"account_id"`
-	Quantity          float64    `json:"quantity"`
-	InstitutionPrice  *float64   `json:"institution_price,omitempty"`
-	InstitutionValue  *float64   `json:"institution_value,omitempty"`
-	CostBasis         *float64   `json:"cost_basis,omitempty"`
-	LastRefresh       time.Time  `json:"last_refresh"`
-	Symbol            *string    `json:"symbol,omitempty"`
-	SecurityName      string     `json:"security_name"`
-	CUSIP             *string    `json:"cusip,omitempty"`
-	Currency          string     `json:"currency"`
-	AccountName       string     `json:"account_name"`
-	AccountMask       *string    `json:"account_mask,omitempty"`
+	ID               string           `json:"id"`
+	AccountID        string           `json:"account_id"`
+	Quantity         decimal.Decimal  `json:"quantity"`
+	InstitutionPrice *decimal.Decimal `json:"institution_price,omitempty"`
+	InstitutionValue *decimal.Decimal `json:"institution_value,omitempty"`
+	CostBasis        *decimal.Decimal `json:"cost_basis,omitempty"`
+	LastRefresh      time.Time        `json:"last_refresh"`
+	Symbol           *string          `json:"symbol,omitempty"`
+	SecurityName     string           `json:"security_name"`
+	CUSIP            *string          `json:"cusip,omitempty"`
+	Sector           *string          `json:"sector,omitempty"`
+	AssetClass       *string          `json:"asset_class,omitempty"`
+	ExpenseRatio     *decimal.Decimal `json:"expense_ratio,omitempty"` // funds only, percent per year
+	LogoURL          *string          `json:"logo_url,omitempty"`
+	Currency         string           `json:"currency"`
+	AccountName      string           `json:"account_name"`
+	AccountMask      *string          `json:"account_mask,omitempty"`
+	Excluded         bool             `json:"excluded_from_analytics,omitempty"` // its account is hidden or excluded from analytics
+}
+
+// HoldingValuation is a holding's quantity, price and value at the end
+// of a day
+type HoldingValuation struct {
+	Date      string           `json:"date"`
+	Quantity  decimal.Decimal  `json:"quantity"`
+	Price     *decimal.Decimal `json:"price,omitempty"`
+	PriceAsOf *string          `json:"price_as_of,omitempty"` // when the institution last priced it
+	Value     *decimal.Decimal `json:"value,omitempty"`
+	CostBasis *decimal.Decimal `json:"cost_basis,omitempty"`
+}
+
+// HoldingHistory is one holding's end-of-day valuations over a period,
+// with the return of its price from the first to the last
+type HoldingHistory struct {
+	HoldingID      string             `json:"holding_id"`
+	AccountID      string             `json:"account_id"`
+	Symbol         *string            `json:"symbol,omitempty"`
+	SecurityName   string             `json:"security_name"`
+	Currency       string             `json:"currency"`
+	PriceReturnPct *float64           `json:"price_return_percent,omitempty"`
+	Valuations     []HoldingValuation `json:"valuations"`
+}
+
+// ValuationDay totals a user's holdings at the end of a day
+type ValuationDay struct {
+	Date         string          `json:"date"`
+	Value        decimal.Decimal `json:"value"`
+	CostBasis    decimal.Decimal `json:"cost_basis"`
+	HoldingCount int             `json:"holding_count"`
+}
+
+// Cost basis reconciliation statuses
+const (
+	CostBasisMatched     = "matched"
+	CostBasisDiscrepancy = "discrepancy"
+	CostBasisIncomplete  = "incomplete" // the synced history does not account for the holding
+	CostBasisUnreported  = "unreported" // the institution reports no cost basis
+)
+
+// CostBasisLot is a purchase of shares still held, after earlier sales
+// took their shares first in, first out. CostBasis is nil for shares
+// transferred in, whose cost the history does not give.
+type CostBasisLot struct {
+	TransactionID string           `json:"transaction_id"`
+	Date          string           `json:"date"`
+	Quantity      decimal.Decimal  `json:"quantity"`
+	CostBasis     *decimal.Decimal `json:"cost_basis"`
+}
+
+// CostBasisComparison compares the cost basis an institution reports for
+// a holding with the basis of its lots
+type CostBasisComparison struct {
+	HoldingID         string           `json:"holding_id"`
+	AccountID         string           `json:"account_id"`
+	AccountName       string           `json:"account_name"`
+	Symbol            *string          `json:"symbol,omitempty"`
+	SecurityName      string           `json:"security_name"`
+	Quantity          decimal.Decimal  `json:"quantity"`
+	ComputedQuantity  decimal.Decimal  `json:"computed_quantity"`
+	BrokerCostBasis   *decimal.Decimal `json:"broker_cost_basis"`
+	ComputedCostBasis *decimal.Decimal `json:"computed_cost_basis"`
+	Difference        *decimal.Decimal `json:"difference,omitempty"` // broker less computed
+	DifferencePercent *float64         `json:"difference_percent,omitempty"`
+	Status            string           `json:"status"`
+	Reason            string           `json:"reason,omitempty"`
+	Lots              []CostBasisLot   `json:"lots,omitempty"`
+}
+
+// CostBasisReconciliation compares the cost basis of each of a user's
+// holdings as reported and as computed from lots, flagging differences
+// beyond a threshold
+type CostBasisReconciliation struct {
+	ThresholdPercent float64               `json:"threshold_percent"`
+	Holdings         []CostBasisComparison `json:"holdings"`
+	Matched          int                   `json:"matched"`
+	Discrepancies    int                   `json:"discrepancies"`
+	Incomplete       int                   `json:"incomplete"`
+	Unreported       int                   `json:"unreported"`
+}
+
+// YieldComparison is what a depository account earns in a year at its
+// APY and would earn at the benchmark high-yield savings rate. Without
+// an APY, what it earns and misses are unknown.
+type YieldComparison struct {
+	AccountID         string           `json:"account_id"`
+	AccountName       string           `json:"account_name"`
+	Subtype           *string          `json:"subtype,omitempty"`
+	Balance           decimal.Decimal  `json:"balance"`
+	APY               *decimal.Decimal `json:"apy,omitempty"`
+	APYSource         *string          `json:"apy_source,omitempty"`
+	AnnualInterest    *decimal.Decimal `json:"annual_interest,omitempty"`
+	BenchmarkInterest decimal.Decimal  `json:"benchmark_interest"`
+	Shortfall         *decimal.Decimal `json:"shortfall,omitempty"` // interest a year left on the table
+}
+
+// YieldReport compares a user's depository accounts with a high-yield
+// savings account. Totals cover the accounts whose APY is known.
+type YieldReport struct {
+	BenchmarkAPY      decimal.Decimal   `json:"benchmark_apy"`
+	AnnualInterest    decimal.Decimal   `json:"annual_interest"`
+	BenchmarkInterest decimal.Decimal   `json:"benchmark_interest"`
+	Shortfall         decimal.Decimal   `json:"shortfall"`
+	WithoutAPY        int               `json:"accounts_without_apy"`
+	Accounts          []YieldComparison `json:"accounts"`
+}
+
+// PayStub is a pay stub from an income provider, such as Plaid's payroll
+// income. Benefits are deductions for health cover and retirement plans;
+// other deductions are the rest, such as garnishments.
+type PayStub struct {
+	ID              string          `json:"id"`
+	ExternalID      string          `json:"external_id"`
+	Employer        string          `json:"employer"`
+	PayDate         string          `json:"pay_date"`
+	PeriodStart     *string         `json:"period_start,omitempty"`
+	PeriodEnd       *string         `json:"period_end,omitempty"`
+	GrossPay        decimal.Decimal `json:"gross_pay"`
+	Taxes           decimal.Decimal `json:"taxes"`
+	Benefits        decimal.Decimal `json:"benefits"`
+	OtherDeductions decimal.Decimal `json:"other_deductions"`
+	NetPay          decimal.Decimal `json:"net_pay"`
+}
+
+// Paycheck sources
+const (
+	PaycheckDeposit = "deposit"  // a payroll deposit without a pay stub
+	PaycheckStub    = "pay_stub" // a pay stub without a synced deposit
+	PaycheckMatched = "matched"  // a payroll deposit and its pay stub
+)
+
+// Paycheck is one payday's pay from an employer. Net pay is what was
+// deposited; gross pay and its breakdown are only known from a pay stub.
+type Paycheck struct {
+	Date            string           `json:"date"`
+	Employer        string           `json:"employer"`
+	Source          string           `json:"source"`
+	NetPay          decimal.Decimal  `json:"net_pay"`
+	GrossPay        *decimal.Decimal `json:"gross_pay,omitempty"`
+	Taxes           *decimal.Decimal `json:"taxes,omitempty"`
+	Benefits        *decimal.Decimal `json:"benefits,omitempty"`
+	OtherDeductions *decimal.Decimal `json:"other_deductions,omitempty"`
+	WithholdingRate *float64         `json:"withholding_rate,omitempty"` // taxes as a percent of gross pay
+	TransactionID   *string          `json:"transaction_id,omitempty"`
+	AccountID       *string          `json:"account_id,omitempty"`
+	PayStubID       *string          `json:"pay_stub_id,omitempty"`
+}
+
+// PaycheckTotals sums paychecks. Gross pay, taxes, benefits and other
+// deductions cover the paychecks with a pay stub, WithBreakdown of them.
+type PaycheckTotals struct {
+	Count           int             `json:"count"`
+	WithBreakdown   int             `json:"with_breakdown"`
+	NetPay          decimal.Decimal `json:"net_pay"`
+	GrossPay        decimal.Decimal `json:"gross_pay"`
+	Taxes           decimal.Decimal `json:"taxes"`
+	Benefits        decimal.Decimal `json:"benefits"`
+	OtherDeductions decimal.Decimal `json:"other_deductions"`
+	WithholdingRate *float64        `json:"withholding_rate,omitempty"`
+}
+
+// MonthlyPaychecks totals a month's paychecks
+type MonthlyPaychecks struct {
+	Month string `json:"month"` // YYYY-MM
+	PaycheckTotals
+}
+
+// PaycheckReport is a year's paychecks, newest first, with totals to
+// date and by month for take-home and withholding trends
+type PaycheckReport struct {
+	Year      int                `json:"year"`
+	YTD       PaycheckTotals     `json:"ytd"`
+	ByMonth   []MonthlyPaychecks `json:"by_month"`
+	Paychecks []Paycheck         `json:"paychecks,omitempty"`
+}
+
+// Corporate action kinds
+const (
+	CorporateActionSplit        = "split"
+	CorporateActionSymbolChange = "symbol_change"
+)
+
+// CorporateAction is a stock split or symbol change of a security. A
+// split of SplitFrom shares into SplitTo, such as 1 into 4, takes effect
+// on ExDate.
+type CorporateAction struct {
+	ID        string                 `json:"id"`
+	Kind      string                 `json:"kind"`
+	Symbol    string                 `json:"symbol"`
+	NewSymbol *string                `json:"new_symbol,omitempty"`
+	SplitFrom *decimal.Decimal       `json:"split_from,omitempty"`
+	SplitTo   *decimal.Decimal       `json:"split_to,omitempty"`
+	ExDate    string                 `json:"ex_date"`
+	Source    string                 `json:"source"`
+	AppliedAt *time.Time             `json:"applied_at,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
 }
 
 // InvestmentTransaction represents an investment transaction
 type InvestmentTransaction struct {
-	ID           string     `json:"id"`
-	AccountID    string     `json:"account_id"`
-	Date         time.Time  `json:"date"`
-	Name         string     `json:"name"`
-	Quantity     *float64   `json:"quantity,omitempty"`
-	Amount       float64    `json:"amount"`
-	Price        *float64   `json:"price,omitempty"`
-	Fees         *float64   `json:"fees,omitempty"`
-	Type         string     `json:"type"`
-	Subtype      *string    `json:"subtype,omitempty"`
-	Symbol       *string    `json:"symbol,omitempty"`
-	SecurityName *string    `json:"security_name,omitempty"`
-	AccountName  string     `json:"account_name"`
-	AccountMask  *string    `json:"account_mask,omitempty"`
+	ID           string           `json:"id"`
+	AccountID    string           `json:"account_id"`
+	Date         time.Time        `json:"date"`
+	Name         string           `json:"name"`
+	Quantity     *decimal.Decimal `json:"quantity,omitempty"`
+	Amount       decimal.Decimal  `json:"amount"`
+	Price        *decimal.Decimal `json:"price,omitempty"`
+	Fees         *decimal.Decimal `json:"fees,omitempty"`
+	Type         string           `json:"type"`
+	Subtype      *string          `json:"subtype,omitempty"`
+	Symbol       *string          `json:"symbol,omitempty"`
+	SecurityName *string          `json:"security_name,omitempty"`
+	AccountName  string           `json:"account_name"`
+	AccountMask  *string          `json:"account_mask,omitempty"`
 }
 
 // CryptoPosition represents a cryptocurrency position
 type CryptoPosition struct {
-	ID                     string     `json:"id"`
-	Symbol                 string     `json:"symbol"`
-	Name                   *string    `json:"name,omitempty"`
-	Quantity               float64    `json:"quantity"`
-	AveragePrice           *float64   `json:"average_price,omitempty"`
-	MarketValue            *float64   `json:"market_value,omitempty"`
-	CostBasis              *float64   `json:"cost_basis,omitempty"`
-	UnrealizedPnL          *float64   `json:"unrealized_pnl,omitempty"`
-	LastPrice              *float64   `json:"last_price,omitempty"`
-	PriceChange24h         *float64   `json:"price_change_24h,omitempty"`
-	PriceChangePercent24h  *float64   `json:"price_change_percent_24h,omitempty"`
-	LastRefresh            time.Time  `json:"last_refresh"`
+	ID                    string           `json:"id"`
+	Symbol                string           `json:"symbol"`
+	Name                  *string          `json:"name,omitempty"`
+	Quantity              decimal.Decimal  `json:"quantity"`
+	AveragePrice          *decimal.Decimal `json:"average_price,omitempty"`
+	MarketValue           *decimal.Decimal `json:"market_value,omitempty"`
+	CostBasis             *decimal.Decimal `json:"cost_basis,omitempty"`
+	UnrealizedPnL         *decimal.Decimal `json:"unrealized_pnl,omitempty"`
+	LastPrice             *decimal.Decimal `json:"last_price,omitempty"`
+	PriceChange24h        *decimal.Decimal `json:"price_change_24h,omitempty"`
+	PriceChangePercent24h *decimal.Decimal `json:"price_change_percent_24h,omitempty"`
+	LastRefresh           time.Time        `json:"last_refresh"`
 }
 
 // CryptoOrder represents a cryptocurrency order
 type CryptoOrder struct {
-	ID               string     `json:"id"`
-	UserID           string     `json:"user_id"`
-	Symbol           string     `json:"symbol"`
-	Side             string     `json:"side"`
-	Quantity         float64    `json:"quantity"`
-	OrderType        string     `json:"order_type"`
-	Price            *float64   `json:"price,omitempty"`
-	Status           string     `json:"status"`
-	DryRun           bool       `json:"dry_run"`
-	FilledQuantity   *float64   `json:"filled_quantity,omitempty"`
-	AverageFillPrice *float64   `json:"average_fill_price,omitempty"`
-	Fees             *float64   `json:"fees,omitempty"`
-	PlacedAt         time.Time  `json:"placed_at"`
-	FilledAt         *time.Time `json:"filled_at,omitempty"`
-	ErrorMessage     *string    `json:"error_message,omitempty"`
+	ID               string           `json:"id"`
+	UserID           string           `json:"user_id"`
+	Symbol           string           `json:"symbol"`
+	Side             string           `json:"side"`
+	Quantity         decimal.Decimal  `json:"quantity"`
+	OrderType        string           `json:"order_type"`
+	Price            *decimal.Decimal `json:"price,omitempty"`
+	Status           string           `json:"status"`
+	DryRun           bool             `json:"dry_run"`
+	FilledQuantity   *decimal.Decimal `json:"filled_quantity,omitempty"`
+	AverageFillPrice *decimal.Decimal `json:"average_fill_price,omitempty"`
+	Fees             *decimal.Decimal `json:"fees,omitempty"`
+	PlacedAt         time.Time        `json:"placed_at"`
+	FilledAt         *time.Time       `json:"filled_at,omitempty"`
+	ErrorMessage     *string          `json:"error_message,omitempty"`
 }
 
 // CryptoOrderRequest represents a request to place a crypto order
 type CryptoOrderRequest struct {
-	UserID   string   `json:"user_id"`
-	Symbol   string   `json:"symbol"`
-	Side     string   `json:"side"`
-	Quantity float64  `json:"quantity"`
-	Price    *float64 `json:"price,omitempty"`
-	DryRun   *bool    `json:"dry_run,omitempty"`
+	UserID   string           `json:"user_id"`
+	Symbol   string           `json:"symbol"`
+	Side     string           `json:"side"`
+	Quantity decimal.Decimal  `json:"quantity"`
+	Price    *decimal.Decimal `json:"price,omitempty"`
+	DryRun   *bool            `json:"dry_run,omitempty"`
+}
+
+// CryptoIncome is a staking reward or interest paid in crypto. Its cost
+// basis is its fair market value when received, which is also the
+// ordinary income it counts as.
+type CryptoIncome struct {
+	ID         string          `json:"id"`
+	Source     string          `json:"source"`
+	ExternalID string          `json:"external_id"`
+	Symbol     string          `json:"symbol"`
+	Kind       string          `json:"kind"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	Price      decimal.Decimal `json:"price"`
+	CostBasis  decimal.Decimal `json:"cost_basis"`
+	ReceivedAt time.Time       `json:"received_at"`
+}
+
+// CryptoIncomeReport totals a user's crypto income over a period, by kind,
+// asset and month
+type CryptoIncomeReport struct {
+	Period   Period                     `json:"period"`
+	Total    decimal.Decimal            `json:"total"`
+	ByKind   map[string]decimal.Decimal `json:"by_kind"`
+	ByAsset  []CryptoIncomeAsset        `json:"by_asset"`
+	ByMonth  []MonthlyCryptoIncome      `json:"by_month"`
+	Payments []CryptoIncome             `json:"payments,omitempty"`
+}
+
+// CryptoIncomeAsset is the income received in one asset, with the coins
+// received and their cost basis
+type CryptoIncomeAsset struct {
+	Symbol    string          `json:"symbol"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	CostBasis decimal.Decimal `json:"cost_basis"`
+	Count     int             `json:"count"`
+}
+
+// MonthlyCryptoIncome is one calendar month's crypto income
+type MonthlyCryptoIncome struct {
+	Month  string          `json:"month"` // YYYY-MM
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Transfer statuses. Authorized transfers await confirmation; the rest
+// follow Plaid's transfer events.
+const (
+	TransferAuthorized = "authorized"
+	TransferDeclined   = "declined"
+	TransferExpired    = "expired"
+	TransferPending    = "pending"
+	TransferPosted     = "posted"
+	TransferSettled    = "settled"
+	TransferFailed     = "failed"
+	TransferReturned   = "returned"
+	TransferCancelled  = "cancelled"
+)
+
+// Transfer is an ACH transfer between a linked account and the platform.
+// Debits pull money from the account; credits push money to it.
+type Transfer struct {
+	ID                string          `json:"id"`
+	UserID            string          `json:"user_id"`
+	ItemID            string          `json:"item_id"`
+	AccountID         string          `json:"account_id"`
+	Type              string          `json:"type"`
+	Network           string          `json:"network"`
+	Amount            decimal.Decimal `json:"amount"`
+	Description       string          `json:"description"`
+	Status            string          `json:"status"`
+	DryRun            bool            `json:"dry_run"`
+	AuthorizationID   *string         `json:"authorization_id,omitempty"`
+	DecisionRationale *string         `json:"decision_rationale,omitempty"`
+	PlaidTransferID   *string         `json:"plaid_transfer_id,omitempty"`
+	FailureReason     *string         `json:"failure_reason,omitempty"`
+	ConfirmBy         time.Time       `json:"confirm_by"`
+	ConfirmedAt       *time.Time      `json:"confirmed_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// TransferRequest represents a request to move money to or from a linked
+// account
+type TransferRequest struct {
+	UserID      string          `json:"user_id"`
+	ItemID      string          `json:"item_id"`
+	AccountID   string          `json:"account_id"`
+	Type        string          `json:"type"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	LegalName   string          `json:"legal_name"`
+	DryRun      *bool           `json:"dry_run,omitempty"`
 }
 
 // PlaidWebhook represents a webhook from Plaid
@@ -138,60 +519,71 @@ type PlaidAccount struct {
 	Subtype          *string                `json:"subtype"`
 	Balances         PlaidBalance           `json:"balances"`
 	VerificationStatus *string              `json:"verification_status"`
+	APY                *decimal.Decimal       `json:"-"` // read from OfficialName; Plaid does not report yields
 }
 
 // PlaidBalance represents balance information from Plaid
 type PlaidBalance struct {
-	Current              *float64 `json:"current"`
-	Available            *float64 `json:"available"`
-	Limit                *float64 `json:"limit"`
-	IsoCurrencyCode      *string  `json:"iso_currency_code"`
-	UnofficialCurrencyCode *string `json:"unofficial_currency_code"`
+	Current                *decimal.Decimal `json:"current"`
+	Available              *decimal.Decimal `json:"available"`
+	Limit                  *decimal.Decimal `json:"limit"`
+	IsoCurrencyCode        *string          `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string          `json:"unofficial_currency_code"`
 }
 
 // PlaidTransaction represents a transaction from Plaid API
 type PlaidTransaction struct {
-	ID                  string                   `json:"transaction_id"`
-	AccountID           string                   `json:"account_id"`
-	Date                string                   `json:"date"`
-	Amount              float64                  `json:"amount"`
-	MerchantName        *string                  `json:"merchant_name"`
-	Name                string                   `json:"name"`
-	Category            []string                 `json:"category"`
-	CategoryDetailed    []string                 `json:"category_detailed"`
-	Location            interface{}              `json:"location"`
-	PaymentMeta         interface{}              `json:"payment_meta"`
-	AccountOwner        *string                  `json:"account_owner"`
-	Pending             bool                     `json:"pending"`
-	TransactionCode     *string                  `json:"transaction_code"`
-	IsoCurrencyCode     *string                  `json:"iso_currency_code"`
-	UnofficialCurrencyCode *string               `json:"unofficial_currency_code"`
+	ID                      string                   `json:"transaction_id"`
+	AccountID               string                   `json:"account_id"`
+	Date                    string                   `json:"date"`
+	Amount                  decimal.Decimal          `json:"amount"`
+	MerchantName            *string                  `json:"merchant_name"`
+	Name                    string                   `json:"name"`
+	Category                []string                 `json:"category"`
+	CategoryDetailed        []string                 `json:"category_detailed"`
+	Location                *Location                `json:"location"`
+	PaymentMeta             interface{}              `json:"payment_meta"`
+	AccountOwner            *string                  `json:"account_owner"`
+	Pending                 bool                     `json:"pending"`
+	TransactionCode         *string                  `json:"transaction_code"`
+	CheckNumber             *string                  `json:"check_number"`
+	IsoCurrencyCode         *string                  `json:"iso_currency_code"`
+	UnofficialCurrencyCode  *string                  `json:"unofficial_currency_code"`
+	PersonalFinanceCategory *PersonalFinanceCategory `json:"personal_finance_category"`
+}
+
+// PersonalFinanceCategory is a transaction's category in Plaid's current
+// taxonomy, such as FOOD_AND_DRINK and FOOD_AND_DRINK_COFFEE
+type PersonalFinanceCategory struct {
+	Primary         string  `json:"primary"`
+	Detailed        string  `json:"detailed"`
+	ConfidenceLevel *string `json:"confidence_level,omitempty"`
 }
 
 // SpendingSummary represents spending analysis
 type SpendingSummary struct {
-	TotalSpent       float64                    `json:"total_spent"`
-	TotalIncome      float64                    `json:"total_income"`
-	NetCashFlow      float64                    `json:"net_cash_flow"`
-	TransactionCount int                        `json:"transaction_count"`
-	Categories       []CategorySummary          `json:"categories"`
-	Merchants        []MerchantSummary          `json:"merchants"`
-	Period           Period                     `json:"period"`
+	TotalSpent       decimal.Decimal   `json:"total_spent"`
+	TotalIncome      decimal.Decimal   `json:"total_income"`
+	NetCashFlow      decimal.Decimal   `json:"net_cash_flow"`
+	TransactionCount int               `json:"transaction_count"`
+	Categories       []CategorySummary `json:"categories"`
+	Merchants        []MerchantSummary `json:"merchants"`
+	Period           Period            `json:"period"`
 }
 
 // CategorySummary represents spending by category
 type CategorySummary struct {
-	Category        string  `json:"category"`
-	Amount          float64 `json:"amount"`
-	TransactionCount int    `json:"transaction_count"`
-	Percentage      float64 `json:"percentage"`
+	Category         string          `json:"category"`
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int             `json:"transaction_count"`
+	Percentage       float64         `json:"percentage"`
 }
 
 // MerchantSummary represents spending by merchant
 type MerchantSummary struct {
-	Merchant        string  `json:"merchant"`
-	Amount          float64 `json:"amount"`
-	TransactionCount int    `json:"transaction_count"`
+	Merchant         string          `json:"merchant"`
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int             `json:"transaction_count"`
 }
 
 // Period represents a time period
@@ -199,4 +591,990 @@ type Period struct {
 	StartDate string `json:"start_date"`
 	EndDate   string `json:"end_date"`
 	Days      int    `json:"days"`
-}
\ No newline at end of file
+}
+
+// Statement cycle sources
+const (
+	CycleUser     = "user"     // close day entered by the user
+	CycleInferred = "inferred" // close day inferred from card payments
+	CycleDefault  = "default"  // calendar months, for lack of either
+)
+
+// StatementSummary is a credit card's progress through its current
+// statement cycle. Spent counts purchases, Credits refunds and Payments
+// payments made during the cycle.
+type StatementSummary struct {
+	AccountID                 string           `json:"account_id"`
+	AccountName               string           `json:"account_name"`
+	Cycle                     Period           `json:"cycle"`
+	CloseDay                  int              `json:"close_day"`
+	CycleSource               string           `json:"cycle_source"`
+	DaysElapsed               int              `json:"days_elapsed"`
+	DaysRemaining             int              `json:"days_remaining"`
+	Spent                     decimal.Decimal  `json:"spent"`
+	Credits                   decimal.Decimal  `json:"credits"`
+	Payments                  decimal.Decimal  `json:"payments"`
+	TransactionCount          int              `json:"transaction_count"`
+	CurrentBalance            *decimal.Decimal `json:"current_balance,omitempty"`
+	CreditLimit               *decimal.Decimal `json:"credit_limit,omitempty"`
+	ProjectedStatementBalance *decimal.Decimal `json:"projected_statement_balance,omitempty"`
+	ProjectedUtilizationPct   *float64         `json:"projected_utilization_pct,omitempty"`
+	Currency                  string           `json:"currency"`
+}
+
+// FeeReport totals the bank fees and interest charged over a period.
+// Net is what was charged less what was refunded; Annualized scales it to
+// a year.
+type FeeReport struct {
+	Period       Period          `json:"period"`
+	Charged      decimal.Decimal `json:"charged"`
+	Refunded     decimal.Decimal `json:"refunded"`
+	Net          decimal.Decimal `json:"net"`
+	Annualized   decimal.Decimal `json:"annualized"`
+	Kinds        []FeeKindTotal  `json:"kinds"`
+	Transactions []Transaction   `json:"transactions,omitempty"`
+}
+
+// FeeKindTotal totals one kind of fee, such as ATM fees or interest
+type FeeKindTotal struct {
+	Kind       string          `json:"kind"`
+	Net        decimal.Decimal `json:"net"`
+	Annualized decimal.Decimal `json:"annualized"`
+	Count      int             `json:"count"` // fees charged, not counting refunds
+}
+
+// ContributionLimit is the IRS's limit on a year's contributions to one
+// kind of tax-advantaged account, with the catch-up contributions allowed
+// from CatchUpAge on top
+type ContributionLimit struct {
+	Year       int             `json:"year"`
+	Kind       string          `json:"kind"` // 401k, ira, hsa_self or hsa_family
+	Limit      decimal.Decimal `json:"limit"`
+	CatchUp    decimal.Decimal `json:"catch_up"`
+	CatchUpAge int             `json:"catch_up_age"`
+}
+
+// ContributionHeadroom is what a user has contributed to one kind of
+// tax-advantaged account in a year and how much more they may. Limit and
+// Remaining are null when no limit is set for the year.
+type ContributionHeadroom struct {
+	Kind          string           `json:"kind"` // 401k, ira or hsa
+	Contributed   decimal.Decimal  `json:"contributed"`
+	Limit         *decimal.Decimal `json:"limit"` // including any catch-up
+	Remaining     *decimal.Decimal `json:"remaining"`
+	OverLimit     bool             `json:"over_limit"`
+	Contributions []Contribution   `json:"contributions,omitempty"`
+}
+
+// Contribution is a deposit counted toward a contribution limit: an
+// investment account's contribution, or a bank transaction paying into
+// an account the user has not linked
+type Contribution struct {
+	Date          string          `json:"date"`
+	AccountID     string          `json:"account_id"`
+	Name          string          `json:"name"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID string          `json:"transaction_id"`
+	Source        string          `json:"source"` // investment or transaction
+}
+
+// SavingsMetrics describe how much of their income a user keeps over
+// recent months and how long their liquid assets would last at their
+// average net outflow. Averages are per month.
+type SavingsMetrics struct {
+	Period          Period           `json:"period"`
+	Months          []MonthlySavings `json:"months"`
+	AverageIncome   decimal.Decimal  `json:"average_income"`
+	AverageSpending decimal.Decimal  `json:"average_spending"` // gross burn rate
+	SavingsRate     *float64         `json:"savings_rate"`     // percent of income kept over the period; nil without income
+	NetBurnRate     decimal.Decimal  `json:"net_burn_rate"`    // spending less income; negative when saving
+	LiquidAssets    decimal.Decimal  `json:"liquid_assets"`
+	RunwayMonths    *float64         `json:"runway_months"` // nil when income covers spending
+	Trend           string           `json:"trend"`         // improving, declining, stable or insufficient_data
+}
+
+// MonthlySavings is one calendar month's income, spending and savings
+type MonthlySavings struct {
+	Month        string          `json:"month"` // YYYY-MM
+	Income       decimal.Decimal `json:"income"`
+	CryptoIncome decimal.Decimal `json:"crypto_income"` // staking rewards and interest, included in income
+	Spending     decimal.Decimal `json:"spending"`
+	Savings      decimal.Decimal `json:"savings"`
+	SavingsRate  *float64        `json:"savings_rate"` // nil without income
+}
+
+// PeriodSpending is one period of a spending comparison
+type PeriodSpending struct {
+	Label              string          `json:"label"`
+	Summary            SpendingSummary `json:"summary"`
+	SpentChangePercent *float64        `json:"spent_change_percent"` // against the period before; nil for the first, or when that spent nothing
+	Truncated          bool            `json:"truncated"`
+}
+
+// RoundUpGoal is a savings goal a user's purchase round-ups accumulate
+// against while it is current, from StartedOn up to EndedOn
+type RoundUpGoal struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	Name            string          `json:"name"`
+	TargetAmount    decimal.Decimal `json:"target_amount"`
+	StartedOn       time.Time       `json:"started_on"`
+	EndedOn         *time.Time      `json:"ended_on,omitempty"` // exclusive; nil for the current goal
+	Accumulated     decimal.Decimal `json:"accumulated"`
+	RoundUpCount    int             `json:"round_up_count"`
+	ProgressPercent float64         `json:"progress_percent"`
+	Completed       bool            `json:"completed"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// RoundUp is what rounding one purchase up to the next dollar would save
+type RoundUp struct {
+	TransactionID string          `json:"transaction_id"`
+	Date          time.Time       `json:"date"`
+	MerchantName  *string         `json:"merchant_name,omitempty"`
+	Description   *string         `json:"description,omitempty"`
+	Amount        decimal.Decimal `json:"amount"`
+	RoundUp       decimal.Decimal `json:"round_up"`
+}
+
+// ReportDefinition is a named report a user saves to run again: its
+// period expression is resolved when it runs, its filters select the
+// transactions it totals, and GroupBy splits the totals
+type ReportDefinition struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	Name      string        `json:"name"`
+	Period    string        `json:"period"` // such as "last month" or "Q2 2024"
+	Filters   ReportFilters `json:"filters"`
+	GroupBy   *string       `json:"group_by,omitempty"` // category, merchant, account, expense_type, day or month
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// ReportFilters select the transactions a report totals. Each filter
+// that is set must match; a list matches when any of its entries does.
+type ReportFilters struct {
+	AccountIDs  []string         `json:"account_ids,omitempty"`
+	Categories  []string         `json:"categories,omitempty"` // top-level categories
+	Merchants   []string         `json:"merchants,omitempty"`  // text the merchant name contains, ignoring case
+	MinAmount   *decimal.Decimal `json:"min_amount,omitempty"` // of the amount without its sign
+	MaxAmount   *decimal.Decimal `json:"max_amount,omitempty"`
+	Direction   string           `json:"direction,omitempty"`    // spending or income; both when empty
+	ExpenseType string           `json:"expense_type,omitempty"` // business or personal
+	Kind        string           `json:"kind,omitempty"`         // check or atm
+}
+
+// ReportResult is what running a report definition found over the period
+// its expression resolved to
+type ReportResult struct {
+	DefinitionID     string          `json:"definition_id"`
+	Name             string          `json:"name"`
+	Label            string          `json:"label"`
+	Period           Period          `json:"period"`
+	Spent            decimal.Decimal `json:"spent"`
+	Received         decimal.Decimal `json:"received"`
+	TransactionCount int             `json:"transaction_count"`
+	Groups           []ReportGroup   `json:"groups,omitempty"`
+	Truncated        bool            `json:"truncated"`
+}
+
+// ReportGroup is the totals of a report's transactions sharing a key
+type ReportGroup struct {
+	Key              string          `json:"key"`
+	Spent            decimal.Decimal `json:"spent"`
+	Received         decimal.Decimal `json:"received"`
+	TransactionCount int             `json:"transaction_count"`
+	Percentage       float64         `json:"percentage"` // of the report's spending
+}
+
+// ExpenseRule marks the transactions matching all of its criteria as
+// business or personal expenses
+type ExpenseRule struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	ExpenseType string    `json:"expense_type"`
+	Merchant    *string   `json:"merchant,omitempty"`
+	Category    *string   `json:"category,omitempty"`
+	AccountID   *string   `json:"account_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExpenseReport totals business or personal expenses over a period by
+// category and, for business expenses, by Schedule C line. Net is what
+// was charged less what was refunded.
+type ExpenseReport struct {
+	Type         string                 `json:"type"`
+	Period       Period                 `json:"period"`
+	Charged      decimal.Decimal        `json:"charged"`
+	Refunded     decimal.Decimal        `json:"refunded"`
+	Net          decimal.Decimal        `json:"net"`
+	Categories   []ExpenseCategoryTotal `json:"categories"`
+	ScheduleC    []ExpenseCategoryTotal `json:"schedule_c,omitempty"`
+	Transactions []Transaction          `json:"transactions,omitempty"`
+}
+
+// ExpenseCategoryTotal totals the expenses in one category or on one
+// Schedule C line
+type ExpenseCategoryTotal struct {
+	Name  string          `json:"name"`
+	Net   decimal.Decimal `json:"net"`
+	Count int             `json:"count"` // expenses charged, not counting refunds
+}
+
+// RentalProperty is a rental unit a user manages through their linked
+// accounts
+type RentalProperty struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Address   *string   `json:"address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RentalTransaction is a transaction tagged to a rental property with
+// what it is for. Interest is the part of a mortgage payment that paid
+// interest.
+type RentalTransaction struct {
+	TransactionID string           `json:"transaction_id"`
+	AccountID     string           `json:"account_id"`
+	Date          string           `json:"date"`
+	Amount        decimal.Decimal  `json:"amount"`
+	MerchantName  *string          `json:"merchant_name,omitempty"`
+	Description   *string          `json:"description,omitempty"`
+	PropertyID    string           `json:"property_id"`
+	Kind          string           `json:"kind"`
+	Interest      *decimal.Decimal `json:"interest,omitempty"`
+}
+
+// PropertyPnL is a rental property's profit and loss over a period. Net
+// income is rent less expenses; mortgage principal is not an expense but
+// is paid out, so net cash flow is all money in less all money out.
+type PropertyPnL struct {
+	PropertyID    string                     `json:"property_id"`
+	Name          string                     `json:"name"`
+	RentReceived  decimal.Decimal            `json:"rent_received"`
+	Expenses      map[string]decimal.Decimal `json:"expenses"` // by kind
+	TotalExpenses decimal.Decimal            `json:"total_expenses"`
+	NetIncome     decimal.Decimal            `json:"net_income"`
+	PrincipalPaid decimal.Decimal            `json:"principal_paid"`
+	NetCashFlow   decimal.Decimal            `json:"net_cash_flow"`
+	Transactions  []RentalTransaction        `json:"transactions,omitempty"`
+}
+
+// RentalReport is the profit and loss of each of a user's rental
+// properties over a period, with their totals
+type RentalReport struct {
+	Period        Period          `json:"period"`
+	Properties    []PropertyPnL   `json:"properties"`
+	RentReceived  decimal.Decimal `json:"rent_received"`
+	TotalExpenses decimal.Decimal `json:"total_expenses"`
+	NetIncome     decimal.Decimal `json:"net_income"`
+	NetCashFlow   decimal.Decimal `json:"net_cash_flow"`
+}
+
+// Donation classification reasons
+const (
+	DonationCategory = "category" // a donation category
+	DonationCharity  = "charity"  // a known charity's merchant name
+)
+
+// Receipt is a document attached to a donation, downloaded through the
+// transaction's attachments
+type Receipt struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+}
+
+// Donation is a transaction classified as a charitable gift. NeedsReceipt
+// marks a gift large enough to need a written acknowledgment from the
+// charity that has no receipt attached.
+type Donation struct {
+	TransactionID string          `json:"transaction_id"`
+	AccountID     string          `json:"account_id"`
+	Date          string          `json:"date"`
+	Amount        decimal.Decimal `json:"amount"`
+	Charity       string          `json:"charity"`
+	Reason        string          `json:"reason"`
+	Receipts      []Receipt       `json:"receipts"`
+	NeedsReceipt  bool            `json:"needs_receipt"`
+}
+
+// CharityTotal is what was given to one charity
+type CharityTotal struct {
+	Charity string          `json:"charity"`
+	Total   decimal.Decimal `json:"total"`
+	Count   int             `json:"count"`
+}
+
+// GivingReport is a year's charitable giving, by charity, with how much of
+// it is backed by receipts
+type GivingReport struct {
+	Year             int             `json:"year"`
+	Total            decimal.Decimal `json:"total"`
+	Count            int             `json:"count"`
+	WithReceipts     decimal.Decimal `json:"with_receipts"`
+	WithoutReceipts  decimal.Decimal `json:"without_receipts"`
+	NeedingReceipts  int             `json:"needing_receipts"`
+	ReceiptThreshold decimal.Decimal `json:"receipt_threshold"`
+	ByCharity        []CharityTotal  `json:"by_charity"`
+	Donations        []Donation      `json:"donations,omitempty"`
+}
+
+// Dependent data scopes: what of a dependent's data anyone but their
+// parent sees
+const (
+	DependentScopeBalance    = "balance"
+	DependentScopeLedger     = "ledger"
+	DependentScopeAllowances = "allowances"
+)
+
+// Dependent is a child or other dependent a user keeps a pocket money
+// ledger for. Balance, Schedules and Ledger are left out where the
+// dependent's scopes hide them from the caller.
+type Dependent struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id"`
+	Name      string                 `json:"name"`
+	BirthYear *int                   `json:"birth_year,omitempty"`
+	Scopes    []string               `json:"scopes"`
+	Balance   *decimal.Decimal       `json:"balance,omitempty"`
+	Schedules []AllowanceSchedule    `json:"schedules,omitempty"`
+	Ledger    []DependentLedgerEntry `json:"ledger,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// Allowance frequencies
+const (
+	AllowanceWeekly   = "weekly"
+	AllowanceBiweekly = "biweekly"
+	AllowanceMonthly  = "monthly"
+)
+
+// AllowanceSchedule is an allowance paid to a dependent every period from
+// NextDate, the next payment still to be credited
+type AllowanceSchedule struct {
+	ID          string          `json:"id"`
+	DependentID string          `json:"dependent_id"`
+	Amount      decimal.Decimal `json:"amount"`
+	Frequency   string          `json:"frequency"`
+	NextDate    string          `json:"next_date"`
+	Description *string         `json:"description,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Dependent ledger entry kinds
+const (
+	LedgerAllowance  = "allowance"
+	LedgerChore      = "chore"
+	LedgerSpend      = "spend"
+	LedgerAdjustment = "adjustment"
+)
+
+// DependentLedgerEntry is money credited to a dependent, positive, or paid
+// out or spent on their behalf, negative
+type DependentLedgerEntry struct {
+	ID          string          `json:"id"`
+	DependentID string          `json:"dependent_id"`
+	Kind        string          `json:"kind"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description *string         `json:"description,omitempty"`
+	Date        string          `json:"date"`
+	ScheduleID  *string         `json:"schedule_id,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// AccountsSummary represents balances totalled across accounts
+type AccountsSummary struct {
+	AccountCount int                  `json:"account_count"`
+	Assets       decimal.Decimal      `json:"assets"`
+	Liabilities  decimal.Decimal      `json:"liabilities"`
+	Net          decimal.Decimal      `json:"net"`
+	Types        []AccountTypeSummary `json:"types"`
+}
+
+// AccountGroup is a user's named group of accounts, such as an emergency
+// fund or a business
+type AccountGroup struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AccountGroupSummary totals the accounts of one group; accounts in no
+// group are totalled under a nil GroupID
+type AccountGroupSummary struct {
+	GroupID      *string         `json:"group_id"`
+	Name         string          `json:"name"`
+	AccountCount int             `json:"account_count"`
+	Assets       decimal.Decimal `json:"assets"`
+	Liabilities  decimal.Decimal `json:"liabilities"`
+	Net          decimal.Decimal `json:"net"`
+}
+
+// AccountTypeSummary represents the accounts of one type
+type AccountTypeSummary struct {
+	Type    string          `json:"type"`
+	Count   int             `json:"count"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// PortfolioSummary represents the value of holdings or crypto positions
+// with the largest of them
+type PortfolioSummary struct {
+	PositionCount int               `json:"position_count"`
+	TotalValue    decimal.Decimal   `json:"total_value"`
+	TopPositions  []PositionSummary `json:"top_positions"`
+}
+
+// PositionSummary represents one holding or crypto position
+type PositionSummary struct {
+	Symbol     string          `json:"symbol"`
+	Name       string          `json:"name,omitempty"`
+	Value      decimal.Decimal `json:"value"`
+	Percentage float64         `json:"percentage"`
+}
+
+// InvestmentActivitySummary represents investment transactions totalled
+// by type
+type InvestmentActivitySummary struct {
+	TransactionCount int                     `json:"transaction_count"`
+	TotalFees        decimal.Decimal         `json:"total_fees"`
+	Types            []InvestmentTypeSummary `json:"types"`
+	Period           Period                  `json:"period"`
+}
+
+// InvestmentTypeSummary represents investment transactions of one type
+type InvestmentTypeSummary struct {
+	Type             string          `json:"type"`
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int             `json:"transaction_count"`
+}
+
+// WebhookSubscription represents an external consumer's webhook registration
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery represents a single outbound webhook delivery attempt log
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	ErrorMessage   *string    `json:"error_message,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Job represents a tracked unit of background work (sync, backfill, report, export)
+type Job struct {
+	ID               string          `json:"id"`
+	UserID           *string         `json:"user_id,omitempty"`
+	PlaidItemID      *string         `json:"plaid_item_id,omitempty"`
+	Type             string          `json:"type"`
+	Status           string          `json:"status"`
+	Progress         int             `json:"progress"`
+	RecordsProcessed int             `json:"records_processed"`
+	Result           json.RawMessage `json:"result,omitempty"`
+	ErrorMessage     *string         `json:"error_message,omitempty"`
+	Attempts         int             `json:"attempts"`
+	MaxAttempts      int             `json:"max_attempts"`
+	RunAt            *time.Time      `json:"run_at,omitempty"`
+	StartedAt        *time.Time      `json:"started_at,omitempty"`
+	CompletedAt      *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+// APIKey represents a service-to-service API key (the secret is never stored)
+type APIKey struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	KeyPrefix   string     `json:"key_prefix"`
+	Scopes      []string   `json:"scopes"`
+	Redact      bool       `json:"redact"`
+	RotatedFrom *string    `json:"rotated_from,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Grant gives another user read-only access to an owner's data
+type Grant struct {
+	ID            string     `json:"id"`
+	OwnerUserID   string     `json:"owner_user_id"`
+	GranteeUserID string     `json:"grantee_user_id"`
+	GranteeEmail  *string    `json:"grantee_email,omitempty"`
+	Role          string     `json:"role"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Session is a user login session seen by the API
+type Session struct {
+	ID         string     `json:"id"`
+	Device     *string    `json:"device,omitempty"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+	IPAddress  *string    `json:"ip_address,omitempty"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `json:"current"`
+}
+
+// Insight is a notable finding about a user's finances or account activity
+type Insight struct {
+	ID         string                 `json:"id"`
+	UserID     string                 `json:"user_id"`
+	Type       string                 `json:"type"`
+	Severity   string                 `json:"severity"`
+	Title      string                 `json:"title"`
+	Message    string                 `json:"message"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	ResolvedAt *time.Time             `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// Recommendation statuses
+const (
+	RecommendationPending   = "pending"
+	RecommendationAccepted  = "accepted"
+	RecommendationDismissed = "dismissed"
+	RecommendationExpired   = "expired"
+)
+
+// Recommendation is advice to move money between a user's accounts. It
+// is only a record: accepting it moves nothing.
+type Recommendation struct {
+	ID            string                 `json:"id"`
+	UserID        string                 `json:"user_id"`
+	Kind          string                 `json:"kind"`
+	FromAccountID string                 `json:"from_account_id"`
+	ToAccountID   string                 `json:"to_account_id"`
+	Amount        decimal.Decimal        `json:"amount"`
+	Currency      string                 `json:"currency"`
+	Title         string                 `json:"title"`
+	Message       string                 `json:"message"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Status        string                 `json:"status"`
+	DecidedAt     *time.Time             `json:"decided_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// Watchlist alert conditions
+const (
+	WatchAbove     = "above"
+	WatchBelow     = "below"
+	WatchDailyMove = "daily_move"
+)
+
+// WatchlistItem is a stock or crypto symbol a user follows, with its
+// latest quote and the day's opening quote
+type WatchlistItem struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"user_id"`
+	Symbol         string           `json:"symbol"`
+	AssetType      string           `json:"asset_type"`
+	LastPrice      *decimal.Decimal `json:"last_price,omitempty"`
+	DayOpenPrice   *decimal.Decimal `json:"day_open_price,omitempty"`
+	DayChangePct   *float64         `json:"day_change_percent,omitempty"`
+	PriceUpdatedAt *time.Time       `json:"price_updated_at,omitempty"`
+	Alerts         []WatchlistAlert `json:"alerts"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// WatchlistAlert notifies a user when a watched symbol's price crosses a
+// threshold, or moves by Threshold percent since the day's open
+type WatchlistAlert struct {
+	ID              string          `json:"id"`
+	ItemID          string          `json:"item_id"`
+	Condition       string          `json:"condition"`
+	Threshold       decimal.Decimal `json:"threshold"`
+	Enabled         bool            `json:"enabled"`
+	Triggered       bool            `json:"triggered"`
+	LastTriggeredAt *time.Time      `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// RecordVersion is one entry in a financial record's change history: the
+// record's state after an insert, update or soft delete
+type RecordVersion struct {
+	ID        int64           `json:"id"`
+	Table     string          `json:"table"`
+	RecordID  string          `json:"record_id"`
+	Operation string          `json:"operation"`
+	Data      json.RawMessage `json:"data"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// DuplicateLink marks a record as a copy of another that reached the
+// database through a different source. Transaction links carry the ID of
+// the account link they were matched under.
+type DuplicateLink struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	RecordType  string    `json:"record_type"`
+	CanonicalID string    `json:"canonical_id"`
+	DuplicateID string    `json:"duplicate_id"`
+	ParentID    *string   `json:"parent_id,omitempty"`
+	Fingerprint *string   `json:"fingerprint,omitempty"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RefundLink pairs a refund with the charge it reverses. Amount is the
+// charge's; the refund is its inverse.
+type RefundLink struct {
+	ID           string          `json:"id"`
+	ChargeID     string          `json:"charge_id"`
+	RefundID     string          `json:"refund_id"`
+	MerchantName *string         `json:"merchant_name,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	Amount       decimal.Decimal `json:"amount"`
+	ChargeDate   time.Time       `json:"charge_date"`
+	RefundDate   time.Time       `json:"refund_date"`
+	Status       string          `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// UserPreferences are how a user wants dates and amounts presented and
+// bucketed
+type UserPreferences struct {
+	UserID          string    `json:"user_id"`
+	Timezone        string    `json:"timezone"`          // IANA name, such as Europe/Berlin
+	BaseCurrency    string    `json:"base_currency"`     // ISO 4217 code
+	WeekStart       string    `json:"week_start"`        // monday, sunday or saturday
+	NumberFormat    string    `json:"number_format"`     // how 1234.56 is written, such as 1.234,56
+	DigestFrequency string    `json:"digest_frequency"`  // off, weekly or monthly
+	FiscalYearStart int       `json:"fiscal_year_start"` // month the fiscal year starts, 1 to 12
+	BudgetPeriod    *string   `json:"budget_period"`     // period definition budgets run on, or calendar months
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PeriodDefinition is a period of a user's own that repeats every Every
+// units (day, week or month) from AnchorDate, such as a two-week pay
+// period, resolved as "this <name>" or "last <name>"
+type PeriodDefinition struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	AnchorDate string    `json:"anchor_date"`
+	Every      int       `json:"every"`
+	Unit       string    `json:"unit"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DefaultPreferences are the preferences of a user who has set none
+func DefaultPreferences(userID string) UserPreferences {
+	return UserPreferences{
+		UserID:          userID,
+		Timezone:        "UTC",
+		BaseCurrency:    "USD",
+		WeekStart:       "monday",
+		NumberFormat:    "1,234.56",
+		DigestFrequency: DigestOff,
+		FiscalYearStart: 1,
+	}
+}
+
+// Onboarding states, in order
+const (
+	OnboardingRegistered = "registered" // account created
+	OnboardingLinked     = "linked"     // first institution connected
+	OnboardingComplete   = "complete"   // onboarding finished
+)
+
+// OnboardingStates lists the onboarding states in order
+var OnboardingStates = []string{OnboardingRegistered, OnboardingLinked, OnboardingComplete}
+
+// User is a registered user's profile
+type User struct {
+	ID              string    `json:"id"`
+	AuthID          string    `json:"auth_id"`
+	Email           *string   `json:"email,omitempty"`
+	DisplayName     *string   `json:"display_name,omitempty"`
+	OnboardingState string    `json:"onboarding_state"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// LinkedItem is a user's connected institution with its accounts
+type LinkedItem struct {
+	ID              string     `json:"id"`
+	InstitutionName *string    `json:"institution_name,omitempty"`
+	Status          string     `json:"status"`
+	AccountCount    int        `json:"account_count"`
+	LastSyncAt      *time.Time `json:"last_sync_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// ItemConsent is the data a linked item may be read for: the Plaid
+// products it was consented to and when that consent lapses, if the
+// institution limits it
+type ItemConsent struct {
+	ItemID            string     `json:"item_id"`
+	UserID            string     `json:"user_id"`
+	InstitutionName   *string    `json:"institution_name,omitempty"`
+	Status            string     `json:"status"`
+	ConsentedProducts []string   `json:"consented_products"`
+	ConsentExpiresAt  *time.Time `json:"consent_expires_at,omitempty"`
+	ConsentUpdatedAt  *time.Time `json:"consent_updated_at,omitempty"`
+}
+
+// Consented reports whether the item may be read for product
+func (c ItemConsent) Consented(product string) bool {
+	for _, p := range c.ConsentedProducts {
+		if p == product {
+			return true
+		}
+	}
+	return false
+}
+
+// Household member roles and statuses
+const (
+	HouseholdRoleOwner  = "owner"
+	HouseholdRoleMember = "member"
+
+	MemberInvited = "invited"
+	MemberActive  = "active"
+)
+
+// Account visibility within a household
+const (
+	VisibilityFull        = "full"         // balances, holdings and transactions
+	VisibilityBalanceOnly = "balance_only" // balances only
+	VisibilityHidden      = "hidden"       // not shown at all
+)
+
+// Household is a group of users sharing a combined view of their finances
+type Household struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	CreatedBy *string           `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Members   []HouseholdMember `json:"members,omitempty"`
+}
+
+// HouseholdMember is a user belonging to, or invited to, a household
+type HouseholdMember struct {
+	UserID   string     `json:"user_id"`
+	Email    *string    `json:"email,omitempty"`
+	Role     string     `json:"role"`
+	Status   string     `json:"status"`
+	JoinedAt *time.Time `json:"joined_at,omitempty"`
+}
+
+// Budget is a household's spending limit for a category each budget
+// period: a calendar month unless the member viewing it budgets on a
+// period of their own
+type Budget struct {
+	ID           string          `json:"id"`
+	HouseholdID  string          `json:"household_id"`
+	Category     string          `json:"category"`
+	MonthlyLimit decimal.Decimal `json:"monthly_limit"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// BudgetStatus is a budget's spending so far this month
+type BudgetStatus struct {
+	Budget
+	Spent      decimal.Decimal `json:"spent"`
+	Remaining  decimal.Decimal `json:"remaining"`
+	Percentage float64         `json:"percentage"`
+}
+
+// AlertRule raises a notification when a synced transaction or account
+// matches its condition
+type AlertRule struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id"`
+	Name            string     `json:"name"`
+	Condition       string     `json:"condition"`
+	Subject         string     `json:"subject"` // what the condition tests: transaction or account
+	Severity        string     `json:"severity"`
+	Enabled         bool       `json:"enabled"`
+	ChannelIDs      []string   `json:"channel_ids"` // chat channels notified besides the feed
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Notification is a message for a user, such as a triggered alert
+type Notification struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id"`
+	RuleID    *string                `json:"rule_id,omitempty"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Message   string                 `json:"message"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// NotificationChannel is a Slack or Discord incoming webhook that alert
+// notifications are posted to. Its URL is a credential and never returned.
+type NotificationChannel struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Kind           string     `json:"kind"`
+	Name           string     `json:"name"`
+	Enabled        bool       `json:"enabled"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ExportDestination is a bucket of a user's own that their transactions
+// and balances are exported to daily. Its access keys are never returned.
+type ExportDestination struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Provider       string     `json:"provider"` // s3 or gcs
+	Bucket         string     `json:"bucket"`
+	Region         *string    `json:"region,omitempty"`
+	Prefix         string     `json:"prefix"`
+	Format         string     `json:"format"` // csv or parquet
+	KMSKeyID       *string    `json:"kms_key_id,omitempty"`
+	Enabled        bool       `json:"enabled"`
+	LastExportedAt *time.Time `json:"last_exported_at,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// EquityGrant is an RSU or stock option grant and its vesting schedule
+type EquityGrant struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"user_id"`
+	Name           string           `json:"name"`
+	Symbol         string           `json:"symbol"`
+	GrantType      string           `json:"grant_type"` // rsu or option
+	StrikePrice    *decimal.Decimal `json:"strike_price,omitempty"`
+	TotalShares    decimal.Decimal  `json:"total_shares"`
+	GrantDate      string           `json:"grant_date"`
+	AccountID      *string          `json:"account_id,omitempty"` // where shares are delivered
+	VestedShares   decimal.Decimal  `json:"vested_shares"`
+	UnvestedShares decimal.Decimal  `json:"unvested_shares"`
+	Vests          []EquityVest     `json:"vests"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// EquityVest is one vest of a grant. Once it has occurred it may be
+// matched to the investment transaction delivering its shares.
+type EquityVest struct {
+	ID                      string          `json:"id"`
+	GrantID                 string          `json:"grant_id"`
+	Date                    string          `json:"date"`
+	Shares                  decimal.Decimal `json:"shares"`
+	InvestmentTransactionID *string         `json:"investment_transaction_id,omitempty"`
+	MatchedAt               *time.Time      `json:"matched_at,omitempty"`
+}
+
+// UpcomingVest is a vest still to come, valued at the symbol's current
+// price, less the strike price for options. Price and Value are null
+// when the symbol has no price.
+type UpcomingVest struct {
+	GrantID   string           `json:"grant_id"`
+	GrantName string           `json:"grant_name"`
+	GrantType string           `json:"grant_type"`
+	Symbol    string           `json:"symbol"`
+	Date      string           `json:"date"`
+	Shares    decimal.Decimal  `json:"shares"`
+	Price     *decimal.Decimal `json:"price"`
+	Value     *decimal.Decimal `json:"value"`
+}
+
+// NetWorthProjection is a user's net worth today and at the end of each
+// coming month, adding the value of the vests due by then at today's
+// prices. Nothing else is projected.
+type NetWorthProjection struct {
+	NetWorth decimal.Decimal     `json:"net_worth"`
+	Months   []ProjectedNetWorth `json:"months"`
+	Unpriced []string            `json:"unpriced_symbols"` // vests of these are left out
+}
+
+// ProjectedNetWorth is the projected net worth at the end of a month
+type ProjectedNetWorth struct {
+	Month     string          `json:"month"`      // YYYY-MM
+	VestValue decimal.Decimal `json:"vest_value"` // vesting in the month
+	NetWorth  decimal.Decimal `json:"net_worth"`
+}
+
+// Digest frequencies
+const (
+	DigestOff     = "off"
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// DigestFrequencies lists every digest frequency
+var DigestFrequencies = []string{DigestOff, DigestWeekly, DigestMonthly}
+
+// Digest is a weekly or monthly summary of a user's finances, as emailed
+type Digest struct {
+	UserID        string           `json:"user_id"`
+	Frequency     string           `json:"frequency"`
+	Label         string           `json:"label"` // such as "last week" or "March 2026"
+	Period        Period           `json:"period"`
+	Currency      string           `json:"currency"`
+	NumberFormat  string           `json:"number_format"`
+	Spending      SpendingSummary  `json:"spending"`
+	PreviousSpent *decimal.Decimal `json:"previous_spent,omitempty"` // spent in the period before
+	UpcomingBills []UpcomingBill   `json:"upcoming_bills"`
+	Portfolio     PortfolioChange  `json:"portfolio"`
+	Insights      []Insight        `json:"insights"`
+}
+
+// UpcomingBill is a monthly charge expected in the coming days, projected
+// from earlier charges at the same merchant
+type UpcomingBill struct {
+	Merchant string          `json:"merchant"`
+	Amount   decimal.Decimal `json:"amount"` // the last charge
+	DueDate  string          `json:"due_date"`
+}
+
+// PortfolioChange is the value of a user's holdings and crypto positions
+// and how it moved since their previous digest
+type PortfolioChange struct {
+	Value         decimal.Decimal           `json:"value"`
+	PreviousValue *decimal.Decimal          `json:"previous_value,omitempty"`
+	Change        *decimal.Decimal          `json:"change,omitempty"`
+	ChangePercent *float64                  `json:"change_percent,omitempty"`
+	TopPositions  []PositionSummary         `json:"top_positions"`
+	Activity      InvestmentActivitySummary `json:"activity"`
+}
+
+// DigestDelivery records one digest period queued for a user
+type DigestDelivery struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"user_id"`
+	Frequency      string           `json:"frequency"`
+	PeriodStart    string           `json:"period_start"`
+	PeriodEnd      string           `json:"period_end"`
+	Status         string           `json:"status"` // pending, sent or failed
+	PortfolioValue *decimal.Decimal `json:"portfolio_value,omitempty"`
+	Error          *string          `json:"error,omitempty"`
+	SentAt         *time.Time       `json:"sent_at,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
@@ -0,0 +1,67 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ServerConfig loads the server certificate and, if clientCAFile is set, the
+// CA used to verify client certificates. Client certificates are verified
+// when presented but not required at the TLS layer, so end users can still
+// connect; routes for internal callers enforce them with RequireClientCert.
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+// PeerSANs returns the DNS and URI subject alternative names of the verified
+// client certificate, or nil if the client did not present one
+func PeerSANs(r *http.Request) []string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	sans := append([]string{}, leaf.DNSNames...)
+	for _, uri := range leaf.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// Allowed reports whether any of sans is in the allowed list
+func Allowed(sans, allowed []string) bool {
+	for _, san := range sans {
+		for _, a := range allowed {
+			if san == a {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,365 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Chat channel kinds
+const (
+	ChannelSlack   = "slack"
+	ChannelDiscord = "discord"
+)
+
+// ChannelKinds lists every supported chat channel kind
+var ChannelKinds = []string{ChannelSlack, ChannelDiscord}
+
+var (
+	// ErrChannelNotFound is returned for a channel that does not exist or
+	// belongs to another user
+	ErrChannelNotFound = errors.New("notification channel not found")
+	// ErrInvalidWebhookURL is returned for a URL that is not an incoming
+	// webhook of the channel's kind
+	ErrInvalidWebhookURL = errors.New("invalid webhook URL")
+)
+
+// webhookHosts are the hosts incoming webhooks of each kind are served
+// from. Only these are posted to, so channels cannot reach internal
+// services.
+var webhookHosts = map[string][]string{
+	ChannelSlack:   {"hooks.slack.com"},
+	ChannelDiscord: {"discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com"},
+}
+
+// Chat deliveries are retried with exponential backoff
+const (
+	chatAttempts = 3
+	chatBackoff  = 2 * time.Second
+)
+
+// severityColors are Discord embed colors by alert severity
+var severityColors = map[string]int{
+	"info":     0x3498db,
+	"warning":  0xf1c40f,
+	"critical": 0xe74c3c,
+}
+
+// ValidateWebhookURL checks that raw is an HTTPS incoming webhook URL of
+// the given kind
+func ValidateWebhookURL(kind, raw string) error {
+	hosts, ok := webhookHosts[kind]
+	if !ok {
+		return fmt.Errorf("%w: unknown channel kind %q", ErrInvalidWebhookURL, kind)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return fmt.Errorf("%w: must be an https URL", ErrInvalidWebhookURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range hosts {
+		if host != h {
+			continue
+		}
+		if kind == ChannelDiscord && !strings.HasPrefix(u.Path, "/api/webhooks/") {
+			break
+		}
+		if kind == ChannelSlack && !strings.HasPrefix(u.Path, "/services/") {
+			break
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: not a %s incoming webhook", ErrInvalidWebhookURL, kind)
+}
+
+// CreateChannel stores a channel with its webhook URL encrypted, filling
+// in its ID and creation time
+func (s *Store) CreateChannel(ctx context.Context, channel *models.NotificationChannel, webhookURL string) error {
+	if err := ValidateWebhookURL(channel.Kind, webhookURL); err != nil {
+		return err
+	}
+	encrypted, err := s.enc.EncryptString(ctx, webhookURL)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook URL: %w", err)
+	}
+
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO notification_channels (user_id, kind, name, webhook_url, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, channel.UserID, channel.Kind, channel.Name, encrypted, channel.Enabled).Scan(&channel.ID, &channel.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", err)
+	}
+	return nil
+}
+
+// ListChannels returns a user's channels, oldest first
+func (s *Store) ListChannels(ctx context.Context, userID string) ([]models.NotificationChannel, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, kind, name, enabled, last_delivery_at, last_error, created_at
+		FROM notification_channels
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels := []models.NotificationChannel{}
+	for rows.Next() {
+		var c models.NotificationChannel
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Kind, &c.Name, &c.Enabled,
+			&c.LastDeliveryAt, &c.LastError, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// DeleteChannel deletes one of a user's channels, removing it from every
+// rule it was routed from
+func (s *Store) DeleteChannel(ctx context.Context, userID, channelID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM notification_channels WHERE id = $1 AND user_id = $2
+	`, channelID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
+
+// TestChannel posts a test message to one of a user's channels right away,
+// returning the delivery error if it fails
+func (s *Store) TestChannel(ctx context.Context, userID, channelID string) error {
+	var target chatTarget
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, kind, webhook_url FROM notification_channels WHERE id = $1 AND user_id = $2
+	`, channelID, userID).Scan(&target.id, &target.kind, &target.url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrChannelNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query notification channel: %w", err)
+	}
+
+	n := &models.Notification{
+		UserID:    userID,
+		Type:      TypeTest,
+		Title:     "FinAgent test notification",
+		Message:   "Alerts routed to this channel will appear here.",
+		CreatedAt: time.Now().UTC(),
+	}
+	err = s.post(ctx, target, n)
+	s.recordDelivery(ctx, target.id, err)
+	return err
+}
+
+type chatTarget struct {
+	id   string
+	kind string
+	url  string // encrypted until posted to
+}
+
+// deliverToChannels posts a rule's notification to the enabled channels
+// the rule routes to, in the background
+func (s *Store) deliverToChannels(ctx context.Context, n *models.Notification) error {
+	if n.RuleID == nil {
+		return nil
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT c.id, c.kind, c.webhook_url
+		FROM notification_channels c
+		JOIN alert_rule_channels rc ON rc.channel_id = c.id
+		WHERE rc.rule_id = $1 AND c.enabled
+	`, *n.RuleID)
+	if err != nil {
+		return fmt.Errorf("failed to query notification channels: %w", err)
+	}
+	var targets []chatTarget
+	for rows.Next() {
+		var t chatTarget
+		if err := rows.Scan(&t.id, &t.kind, &t.url); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query notification channels: %w", err)
+	}
+
+	for _, target := range targets {
+		s.inFlight.Add(1)
+		go func(target chatTarget) {
+			defer s.inFlight.Done()
+			s.deliver(context.Background(), target, n)
+		}(target)
+	}
+	return nil
+}
+
+// Wait blocks until in-flight chat deliveries finish or ctx is done
+func (s *Store) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver posts a notification with retries, recording the outcome on the
+// channel
+func (s *Store) deliver(ctx context.Context, target chatTarget, n *models.Notification) {
+	backoff := chatBackoff
+	var err error
+	for attempt := 1; attempt <= chatAttempts; attempt++ {
+		if err = s.post(ctx, target, n); err == nil {
+			break
+		}
+		if attempt < chatAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to post notification to chat", "channel_id", target.id,
+			"kind", target.kind, "notification_id", n.ID, "error", err)
+	}
+	s.recordDelivery(ctx, target.id, err)
+}
+
+func (s *Store) post(ctx context.Context, target chatTarget, n *models.Notification) error {
+	webhookURL, err := s.enc.DecryptString(ctx, target.url)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt webhook URL: %w", err)
+	}
+	// Checked again in case the allowed hosts have narrowed since
+	if err := ValidateWebhookURL(target.kind, webhookURL); err != nil {
+		return err
+	}
+
+	var message interface{}
+	switch target.kind {
+	case ChannelSlack:
+		message = slackMessage(n)
+	case ChannelDiscord:
+		message = discordMessage(n)
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// The error names the URL, which must not reach logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to post to %s: %w", target.kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %d", target.kind, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Store) recordDelivery(ctx context.Context, channelID string, deliveryErr error) {
+	var lastError *string
+	if deliveryErr != nil {
+		msg := deliveryErr.Error()
+		lastError = &msg
+	}
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE notification_channels
+		SET last_error = $2,
+			last_delivery_at = CASE WHEN $2::text IS NULL THEN NOW() ELSE last_delivery_at END
+		WHERE id = $1
+	`, channelID, lastError)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record chat delivery", "channel_id", channelID, "error", err)
+	}
+}
+
+// slackMessage formats a notification as Slack Block Kit, with a plain
+// text fallback for clients that show no blocks
+func slackMessage(n *models.Notification) map[string]interface{} {
+	body := "*" + slackEscape(n.Title) + "*\n" + slackEscape(n.Message)
+	footer := []map[string]interface{}{
+		{"type": "mrkdwn", "text": "FinAgent · " + n.CreatedAt.UTC().Format("Jan 2, 2006 15:04 UTC")},
+	}
+	if condition, ok := n.Metadata["condition"].(string); ok {
+		footer = append(footer, map[string]interface{}{"type": "mrkdwn", "text": "Rule: `" + slackEscape(condition) + "`"})
+	}
+	return map[string]interface{}{
+		"text": slackEscape(n.Title + ": " + n.Message),
+		"blocks": []map[string]interface{}{
+			{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": body}},
+			{"type": "context", "elements": footer},
+		},
+	}
+}
+
+// slackEscape escapes the characters Slack treats as control sequences
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// discordMessage formats a notification as a Discord embed colored by the
+// alert's severity. Mentions are disabled so merchant names cannot ping.
+func discordMessage(n *models.Notification) map[string]interface{} {
+	color, ok := severityColors[fmt.Sprint(n.Metadata["severity"])]
+	if !ok {
+		color = severityColors["info"]
+	}
+	embed := map[string]interface{}{
+		"title":       n.Title,
+		"description": n.Message,
+		"color":       color,
+		"timestamp":   n.CreatedAt.UTC().Format(time.RFC3339),
+		"footer":      map[string]interface{}{"text": "FinAgent"},
+	}
+	if condition, ok := n.Metadata["condition"].(string); ok {
+		embed["fields"] = []map[string]interface{}{
+			{"name": "Rule", "value": "`" + condition + "`"},
+		}
+	}
+	return map[string]interface{}{
+		"embeds":           []map[string]interface{}{embed},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+}
@@ -0,0 +1,117 @@
+// Package notifications stores messages for users, such as triggered
+// alerts, announces them to webhook subscribers and posts alert
+// notifications to the Slack and Discord channels their rule routes to
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/finagent/ingest/internal/webhooks"
+)
+
+// Notification types
+const (
+	TypeAlert   = "alert"
+	TypeConsent = "consent"
+	TypePrice   = "price"
+	TypeTest    = "test"
+)
+
+// ErrNotFound is returned for a notification that does not exist or
+// belongs to another user
+var ErrNotFound = errors.New("notification not found")
+
+// Store persists notifications, announces them to webhook subscribers and
+// delivers them to chat channels
+type Store struct {
+	db         *database.Database
+	webhooks   *webhooks.Dispatcher
+	enc        *encryption.Service
+	httpClient *http.Client
+	inFlight   sync.WaitGroup
+}
+
+// NewStore creates a new notification store. Channel webhook URLs are
+// encrypted with enc.
+func NewStore(db *database.Database, dispatcher *webhooks.Dispatcher, enc *encryption.Service) *Store {
+	return &Store{
+		db:         db,
+		webhooks:   dispatcher,
+		enc:        enc,
+		httpClient: tracing.HTTPClient(10 * time.Second),
+	}
+}
+
+// Create stores a notification, publishes a notification.created event and
+// posts it to its rule's chat channels
+func (s *Store) Create(ctx context.Context, n *models.Notification) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, rule_id, type, title, message, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, n.UserID, n.RuleID, n.Type, n.Title, n.Message, n.Metadata).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	if s.webhooks != nil {
+		if err := s.webhooks.Publish(ctx, n.UserID, webhooks.EventNotificationCreated, n); err != nil {
+			slog.ErrorContext(ctx, "Failed to publish notification", "notification_id", n.ID, "error", err)
+		}
+	}
+	if err := s.deliverToChannels(ctx, n); err != nil {
+		slog.ErrorContext(ctx, "Failed to deliver notification to channels", "notification_id", n.ID, "error", err)
+	}
+	return nil
+}
+
+// List returns a user's notifications, newest first
+func (s *Store) List(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, rule_id, type, title, message, metadata, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.RuleID, &n.Type, &n.Title, &n.Message,
+			&n.Metadata, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// MarkRead marks one of a user's notifications as read
+func (s *Store) MarkRead(ctx context.Context, userID, notificationID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`, notificationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package paychecks breaks a user's pay into paychecks. Payroll deposits
+// are found among transactions by their category. Where an item is
+// consented to income verification, the pay stubs its payroll provider
+// reports are matched to the deposits, giving each paycheck's gross pay,
+// taxes and deductions; a stub whose pay went to an account that is not
+// linked is a paycheck of its own.
+package paychecks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// matchDays is how many days a deposit may land from its stub's pay date
+const matchDays = 3
+
+// netTolerance is how far a deposit may be from its stub's net pay
+var netTolerance = decimal.RequireFromString("0.01")
+
+// IsPayroll reports whether a transaction is a payroll deposit
+func IsPayroll(txn models.Transaction) bool {
+	if !txn.Amount.IsNegative() {
+		return false
+	}
+	if txn.PersonalFinanceCategoryDetailed != nil && *txn.PersonalFinanceCategoryDetailed == "INCOME_WAGES" {
+		return true
+	}
+	for _, c := range txn.Category {
+		if strings.EqualFold(c, "Payroll") {
+			return true
+		}
+	}
+	return false
+}
+
+// Service stores and lists pay stubs
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a paycheck service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// StoreStubs upserts the pay stubs an item's payroll provider reports,
+// returning how many were new
+func (s *Service) StoreStubs(ctx context.Context, userID, plaidItemID string, stubs []models.PayStub) (int, error) {
+	added := 0
+	for _, stub := range stubs {
+		var inserted bool
+		err := s.db.Pool.QueryRow(ctx, `
+			INSERT INTO pay_stubs (user_id, plaid_item_id, external_id, employer, pay_date, period_start,
+			                       period_end, gross_pay, taxes, benefits, other_deductions, net_pay)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (user_id, external_id) DO UPDATE SET
+				employer = EXCLUDED.employer,
+				pay_date = EXCLUDED.pay_date,
+				period_start = EXCLUDED.period_start,
+				period_end = EXCLUDED.period_end,
+				gross_pay = EXCLUDED.gross_pay,
+				taxes = EXCLUDED.taxes,
+				benefits = EXCLUDED.benefits,
+				other_deductions = EXCLUDED.other_deductions,
+				net_pay = EXCLUDED.net_pay
+			RETURNING xmax = 0
+		`, userID, plaidItemID, stub.ExternalID, stub.Employer, stub.PayDate, stub.PeriodStart, stub.PeriodEnd,
+			stub.GrossPay, stub.Taxes, stub.Benefits, stub.OtherDeductions, stub.NetPay).Scan(&inserted)
+		if err != nil {
+			return added, fmt.Errorf("failed to store pay stub: %w", err)
+		}
+		if inserted {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// ListStubs returns a user's pay stubs paid from start to end, inclusive
+// dates, oldest first
+func (s *Service) ListStubs(ctx context.Context, userID, start, end string) ([]models.PayStub, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, external_id, employer, pay_date::text, period_start::text, period_end::text,
+		       gross_pay, taxes, benefits, other_deductions, net_pay
+		FROM pay_stubs
+		WHERE user_id = $1 AND pay_date BETWEEN $2 AND $3
+		ORDER BY pay_date, employer
+	`, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pay stubs: %w", err)
+	}
+	defer rows.Close()
+
+	stubs := []models.PayStub{}
+	for rows.Next() {
+		var stub models.PayStub
+		if err := rows.Scan(&stub.ID, &stub.ExternalID, &stub.Employer, &stub.PayDate, &stub.PeriodStart,
+			&stub.PeriodEnd, &stub.GrossPay, &stub.Taxes, &stub.Benefits, &stub.OtherDeductions,
+			&stub.NetPay); err != nil {
+			return nil, fmt.Errorf("failed to scan pay stub: %w", err)
+		}
+		stubs = append(stubs, stub)
+	}
+	return stubs, rows.Err()
+}
+
+// Build turns payroll deposits and pay stubs into paychecks, newest
+// first. Each deposit is matched to the unmatched stub nearest its date
+// with the same net pay, within matchDays.
+func Build(transactions []models.Transaction, stubs []models.PayStub) []models.Paycheck {
+	var deposits []models.Transaction
+	for _, txn := range transactions {
+		if IsPayroll(txn) {
+			deposits = append(deposits, txn)
+		}
+	}
+	sort.SliceStable(deposits, func(i, j int) bool { return deposits[i].Date.Before(deposits[j].Date) })
+
+	matched := make([]bool, len(stubs))
+	paychecks := make([]models.Paycheck, 0, len(deposits)+len(stubs))
+	for _, txn := range deposits {
+		net := txn.Amount.Neg()
+		best, bestDays := -1, matchDays+1
+		for i, stub := range stubs {
+			if matched[i] || stub.NetPay.Sub(net).Abs().GreaterThan(netTolerance) {
+				continue
+			}
+			payDate, err := time.Parse(period.DateLayout, stub.PayDate)
+			if err != nil {
+				continue
+			}
+			days := int(txn.Date.Sub(payDate).Hours() / 24)
+			if days < 0 {
+				days = -days
+			}
+			if days < bestDays {
+				best, bestDays = i, days
+			}
+		}
+
+		txnID, accountID := txn.ID, txn.AccountID
+		p := models.Paycheck{
+			Date:          txn.Date.Format(period.DateLayout),
+			Employer:      employer(txn),
+			Source:        models.PaycheckDeposit,
+			NetPay:        net,
+			TransactionID: &txnID,
+			AccountID:     &accountID,
+		}
+		if best >= 0 {
+			matched[best] = true
+			p.Source = models.PaycheckMatched
+			p.Employer = stubs[best].Employer
+			withStub(&p, stubs[best])
+		}
+		paychecks = append(paychecks, p)
+	}
+
+	for i, stub := range stubs {
+		if matched[i] {
+			continue
+		}
+		p := models.Paycheck{
+			Date:     stub.PayDate,
+			Employer: stub.Employer,
+			Source:   models.PaycheckStub,
+			NetPay:   stub.NetPay,
+		}
+		withStub(&p, stub)
+		paychecks = append(paychecks, p)
+	}
+
+	sort.SliceStable(paychecks, func(i, j int) bool { return paychecks[i].Date > paychecks[j].Date })
+	return paychecks
+}
+
+// withStub adds a pay stub's breakdown to a paycheck
+func withStub(p *models.Paycheck, stub models.PayStub) {
+	stubID := stub.ID
+	gross, taxes, benefits, other := stub.GrossPay, stub.Taxes, stub.Benefits, stub.OtherDeductions
+	p.PayStubID = &stubID
+	p.GrossPay, p.Taxes, p.Benefits, p.OtherDeductions = &gross, &taxes, &benefits, &other
+	p.WithholdingRate = withholdingRate(taxes, gross)
+}
+
+// employer names a deposit's payer
+func employer(txn models.Transaction) string {
+	if txn.MerchantName != nil && *txn.MerchantName != "" {
+		return *txn.MerchantName
+	}
+	if txn.Description != nil && *txn.Description != "" {
+		return *txn.Description
+	}
+	return "Unknown employer"
+}
+
+// withholdingRate is taxes as a percent of gross pay, or nil without it
+func withholdingRate(taxes, gross decimal.Decimal) *float64 {
+	if !gross.IsPositive() {
+		return nil
+	}
+	rate, _ := taxes.Div(gross).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+	return &rate
+}
+
+// Report totals a year's paychecks to date and for each month through
+// the last one
+func Report(year int, through time.Time, paychecks []models.Paycheck) models.PaycheckReport {
+	report := models.PaycheckReport{
+		Year:      year,
+		YTD:       newTotals(),
+		ByMonth:   []models.MonthlyPaychecks{},
+		Paychecks: paychecks,
+	}
+
+	last := time.December
+	if through.Year() == year {
+		last = through.Month()
+	}
+	months := make(map[string]int)
+	for m := time.January; m <= last; m++ {
+		month := fmt.Sprintf("%04d-%02d", year, int(m))
+		months[month] = len(report.ByMonth)
+		report.ByMonth = append(report.ByMonth, models.MonthlyPaychecks{Month: month, PaycheckTotals: newTotals()})
+	}
+
+	for _, p := range paychecks {
+		add(&report.YTD, p)
+		if len(p.Date) >= 7 {
+			if i, ok := months[p.Date[:7]]; ok {
+				add(&report.ByMonth[i].PaycheckTotals, p)
+			}
+		}
+	}
+	report.YTD.WithholdingRate = withholdingRate(report.YTD.Taxes, report.YTD.GrossPay)
+	for i := range report.ByMonth {
+		t := &report.ByMonth[i].PaycheckTotals
+		t.WithholdingRate = withholdingRate(t.Taxes, t.GrossPay)
+	}
+	return report
+}
+
+func newTotals() models.PaycheckTotals {
+	return models.PaycheckTotals{
+		NetPay:          decimal.Zero,
+		GrossPay:        decimal.Zero,
+		Taxes:           decimal.Zero,
+		Benefits:        decimal.Zero,
+		OtherDeductions: decimal.Zero,
+	}
+}
+
+func add(t *models.PaycheckTotals, p models.Paycheck) {
+	t.Count++
+	t.NetPay = t.NetPay.Add(p.NetPay)
+	if p.GrossPay == nil {
+		return
+	}
+	t.WithBreakdown++
+	t.GrossPay = t.GrossPay.Add(*p.GrossPay)
+	t.Taxes = t.Taxes.Add(*p.Taxes)
+	t.Benefits = t.Benefits.Add(*p.Benefits)
+	t.OtherDeductions = t.OtherDeductions.Add(*p.OtherDeductions)
+}
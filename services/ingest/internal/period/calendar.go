@@ -0,0 +1,232 @@
+package period
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Units custom periods repeat in
+const (
+	UnitDay   = "day"
+	UnitWeek  = "week"
+	UnitMonth = "month"
+)
+
+// Calendar is how a user divides time: the day their weeks start, the
+// month their fiscal year starts, January when unset, periods of their
+// own, and which of those their budgets run on
+type Calendar struct {
+	WeekStart       time.Weekday
+	FiscalYearStart time.Month
+	Cycles          []Cycle
+	BudgetPeriod    string // name of a cycle, or empty for calendar months
+}
+
+// Cycle is a period of a user's own repeating every Every units from
+// Anchor, before and after it, such as a pay period of two weeks from a
+// payday or a month starting on the 25th. Monthly cycles anchored past
+// the 28th start on the last day of shorter months.
+type Cycle struct {
+	Name   string
+	Anchor time.Time
+	Every  int
+	Unit   string
+}
+
+// IsUnit reports whether u is a unit custom periods repeat in
+func IsUnit(u string) bool {
+	return u == UnitDay || u == UnitWeek || u == UnitMonth
+}
+
+// IsReserved reports whether expressions naming a custom period would
+// already mean something else, such as "week" or "fiscal year"
+func IsReserved(name string) bool {
+	today := day(time.Now().UTC())
+	for _, prefix := range []string{"", "this ", "last "} {
+		if _, err := (Calendar{}).Resolve(prefix+name, today); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Current returns the occurrence of a named custom period containing now,
+// in full rather than ending today, or false if the calendar has no such
+// period
+func (c Calendar) Current(name string, now time.Time) (Range, bool) {
+	cycle, ok := c.cycle(normalize(name))
+	if !ok {
+		return Range{}, false
+	}
+	return cycle.at(day(now), 0), true
+}
+
+// Budget returns the budget period containing now in full: the occurrence
+// of the custom period budgets run on, or the calendar month
+func (c Calendar) Budget(now time.Time) Range {
+	if c.BudgetPeriod != "" {
+		if r, ok := c.Current(c.BudgetPeriod, now); ok {
+			return r
+		}
+	}
+	start := startOfMonth(day(now))
+	return Range{start, start.AddDate(0, 1, -1), start.Format("January 2006")}
+}
+
+// cycle returns the custom period of a normalized name
+func (c Calendar) cycle(name string) (Cycle, bool) {
+	for _, cycle := range c.Cycles {
+		if normalize(cycle.Name) == name {
+			return cycle, true
+		}
+	}
+	return Cycle{}, false
+}
+
+// resolveCycle resolves "this", "last" or a bare custom period name
+func (c Calendar) resolveCycle(e string, today time.Time) (Range, bool) {
+	m := cyclePattern.FindStringSubmatch(e)
+	if m == nil {
+		return Range{}, false
+	}
+	cycle, ok := c.cycle(m[2])
+	if !ok {
+		return Range{}, false
+	}
+	if m[1] == "last" || m[1] == "previous" {
+		return cycle.at(today, -1), true
+	}
+	return clip(cycle.at(today, 0), today), true
+}
+
+// at returns the occurrence n after the one containing d, or before it
+// when n is negative
+func (c Cycle) at(d time.Time, n int) Range {
+	anchor := time.Date(c.Anchor.Year(), c.Anchor.Month(), c.Anchor.Day(), 0, 0, 0, 0, d.Location())
+	every := c.Every
+	if every < 1 {
+		every = 1
+	}
+
+	var start, next time.Time
+	if c.Unit == UnitMonth {
+		months := (d.Year()-anchor.Year())*12 + int(d.Month()) - int(anchor.Month())
+		k := floorDiv(months, every)
+		if addMonths(anchor, k*every).After(d) {
+			k--
+		}
+		k += n
+		start, next = addMonths(anchor, k*every), addMonths(anchor, (k+1)*every)
+	} else {
+		length := every
+		if c.Unit == UnitWeek {
+			length *= 7
+		}
+		days := int(utcDay(d).Sub(utcDay(anchor)).Hours() / 24)
+		k := floorDiv(days, length) + n
+		start = anchor.AddDate(0, 0, k*length)
+		next = start.AddDate(0, 0, length)
+	}
+	end := next.AddDate(0, 0, -1)
+	return Range{start, end, fmt.Sprintf("%s %s to %s", c.Name, start.Format(DateLayout), end.Format(DateLayout))}
+}
+
+// addMonths moves a date n months on, keeping its day of the month or
+// falling back to the last day of a shorter month
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	d := t.Day()
+	if d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+// utcDay is a date at midnight UTC, so days between dates are whole
+// across daylight saving changes
+func utcDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// resolveFiscal resolves fiscal year and quarter expressions
+func (c Calendar) resolveFiscal(e string, today time.Time) (Range, bool) {
+	start := c.startOfFiscalYear(today)
+	switch e {
+	case "this fiscal year", "fiscal year to date", "fiscal ytd", "fytd":
+		return Range{start, today, fiscalYearLabel(start) + " to date"}, true
+	case "last fiscal year", "previous fiscal year":
+		start = start.AddDate(-1, 0, 0)
+		return Range{start, start.AddDate(1, 0, -1), fiscalYearLabel(start)}, true
+	case "this fiscal quarter", "fiscal quarter to date":
+		q := c.startOfFiscalQuarter(today)
+		return Range{q, today, fiscalQuarterLabel(start, q) + " to date"}, true
+	case "last fiscal quarter", "previous fiscal quarter":
+		q := c.startOfFiscalQuarter(today).AddDate(0, -3, 0)
+		return Range{q, q.AddDate(0, 3, -1), fiscalQuarterLabel(c.startOfFiscalYear(q), q)}, true
+	}
+
+	if m := fiscalYearPattern.FindStringSubmatch(e); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		start := c.fiscalYear(year, today.Location())
+		return clip(Range{start, start.AddDate(1, 0, -1), fiscalYearLabel(start)}, today), true
+	}
+	if m := fiscalQPattern.FindStringSubmatch(e); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		start := c.fiscalYear(year, today.Location())
+		q := start.AddDate(0, 3*(n-1), 0)
+		return clip(Range{q, q.AddDate(0, 3, -1), fiscalQuarterLabel(start, q)}, today), true
+	}
+	return Range{}, false
+}
+
+// fiscalStart is the month the fiscal year starts
+func (c Calendar) fiscalStart() time.Month {
+	if c.FiscalYearStart < time.January || c.FiscalYearStart > time.December {
+		return time.January
+	}
+	return c.FiscalYearStart
+}
+
+// fiscalYear returns the first day of the fiscal year named year, which
+// is the calendar year it ends in
+func (c Calendar) fiscalYear(year int, loc *time.Location) time.Time {
+	month := c.fiscalStart()
+	if month != time.January {
+		year--
+	}
+	return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+}
+
+func (c Calendar) startOfFiscalYear(today time.Time) time.Time {
+	start := time.Date(today.Year(), c.fiscalStart(), 1, 0, 0, 0, 0, today.Location())
+	if start.After(today) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+func (c Calendar) startOfFiscalQuarter(today time.Time) time.Time {
+	start := c.startOfFiscalYear(today)
+	months := (today.Year()-start.Year())*12 + int(today.Month()) - int(start.Month())
+	return start.AddDate(0, 3*(months/3), 0)
+}
+
+func fiscalYearLabel(start time.Time) string {
+	return fmt.Sprintf("FY%d", start.AddDate(1, 0, -1).Year())
+}
+
+func fiscalQuarterLabel(yearStart, quarterStart time.Time) string {
+	months := (quarterStart.Year()-yearStart.Year())*12 + int(quarterStart.Month()) - int(yearStart.Month())
+	return fmt.Sprintf("%s Q%d", fiscalYearLabel(yearStart), months/3+1)
+}
@@ -0,0 +1,244 @@
+// Package period resolves natural date range expressions such as "last
+// month", "YTD", "past 90 days" or "Q2 2024" into concrete dates, so agents
+// need not do calendar arithmetic themselves. Expressions are resolved
+// relative to a moment in the user's timezone; ranges that include today
+// end today rather than at the end of the period. A user's calendar adds
+// their fiscal year, such as "last fiscal year" or "FY2025", and periods
+// of their own, such as "last pay period".
+package period
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezones resolve even on hosts without zoneinfo
+)
+
+// DateLayout formats resolved dates
+const DateLayout = "2006-01-02"
+
+// WeekStarts maps the week_start preference to the first day of the week
+var WeekStarts = map[string]time.Weekday{
+	"monday":   time.Monday,
+	"sunday":   time.Sunday,
+	"saturday": time.Saturday,
+}
+
+// ErrUnrecognized is returned for expressions the resolver does not know
+var ErrUnrecognized = errors.New("unrecognized period expression")
+
+// Range is a resolved range of whole days, both ends inclusive
+type Range struct {
+	Start time.Time
+	End   time.Time
+	Label string // canonical description, such as "Q2 2024"
+}
+
+// Days is the number of days in the range
+func (r Range) Days() int {
+	return int(r.End.Sub(r.Start).Hours()/24+0.5) + 1
+}
+
+var (
+	relativePattern    = regexp.MustCompile(`^(?:past|last|previous|trailing)\s+(\d+)\s+(day|week|month|quarter|year)s?$`)
+	quarterPattern     = regexp.MustCompile(`^q([1-4])(?:\s+(\d{4}))?$`)
+	yearQuarterPattern = regexp.MustCompile(`^(\d{4})\s+q([1-4])$`)
+	monthPattern       = regexp.MustCompile(`^([a-z]+)(?:\s+(\d{4}))?$`)
+	yearPattern        = regexp.MustCompile(`^(\d{4})$`)
+	betweenPattern     = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\s*(?:to|through|until|-|\.\.)\s*(\d{4}-\d{2}-\d{2})$`)
+	fiscalYearPattern  = regexp.MustCompile(`^(?:fy|fiscal year)\s*(\d{4})$`)
+	fiscalQPattern     = regexp.MustCompile(`^(?:fy|fiscal year)\s*(\d{4})\s+q([1-4])$`)
+	cyclePattern       = regexp.MustCompile(`^(?:(this|current|last|previous)\s+)?(.+)$`)
+)
+
+// months maps month names and their abbreviations to months
+var months = map[string]time.Month{}
+
+func init() {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		months[name] = m
+		months[name[:3]] = m
+	}
+	months["sept"] = time.September
+}
+
+// Resolve turns expr into a range of days relative to now, whose location
+// is the user's timezone. Weeks start on weekStart and fiscal years are
+// calendar years.
+func Resolve(expr string, now time.Time, weekStart time.Weekday) (Range, error) {
+	return Calendar{WeekStart: weekStart}.Resolve(expr, now)
+}
+
+// Resolve turns expr into a range of days relative to now, whose location
+// is the user's timezone, on the calendar
+func (c Calendar) Resolve(expr string, now time.Time) (Range, error) {
+	e := normalize(expr)
+	today := day(now)
+
+	switch e {
+	case "":
+		return Range{}, errors.New("period expression is required")
+	case "today":
+		return Range{today, today, "today"}, nil
+	case "yesterday":
+		y := today.AddDate(0, 0, -1)
+		return Range{y, y, "yesterday"}, nil
+	case "this week", "week to date", "wtd":
+		return Range{startOfWeek(today, c.WeekStart), today, "this week"}, nil
+	case "last week", "previous week":
+		start := startOfWeek(today, c.WeekStart).AddDate(0, 0, -7)
+		return Range{start, start.AddDate(0, 0, 6), "last week"}, nil
+	case "this month", "month to date", "mtd":
+		return Range{startOfMonth(today), today, "this month"}, nil
+	case "last month", "previous month":
+		start := startOfMonth(today).AddDate(0, -1, 0)
+		return Range{start, start.AddDate(0, 1, -1), start.Format("January 2006")}, nil
+	case "this quarter", "quarter to date", "qtd":
+		return Range{startOfQuarter(today), today, "this quarter"}, nil
+	case "last quarter", "previous quarter":
+		start := startOfQuarter(today).AddDate(0, -3, 0)
+		return Range{start, start.AddDate(0, 3, -1), quarterLabel(start)}, nil
+	case "this year", "year to date", "ytd":
+		return Range{startOfYear(today), today, "year to date"}, nil
+	case "last year", "previous year":
+		start := startOfYear(today).AddDate(-1, 0, 0)
+		return Range{start, start.AddDate(1, 0, -1), strconv.Itoa(start.Year())}, nil
+	}
+
+	if m := relativePattern.FindStringSubmatch(e); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > 3650 {
+			return Range{}, fmt.Errorf("%w: %q", ErrUnrecognized, expr)
+		}
+		// The range is exactly n units ending today, so it starts the day
+		// after the date n units back. Counting back months from the 31st
+		// lands on the last day of shorter ones.
+		var start time.Time
+		switch m[2] {
+		case "day":
+			start = today.AddDate(0, 0, -n)
+		case "week":
+			start = today.AddDate(0, 0, -7*n)
+		case "month":
+			start = addMonths(today, -n)
+		case "quarter":
+			start = addMonths(today, -3*n)
+		case "year":
+			start = addMonths(today, -12*n)
+		}
+		start = start.AddDate(0, 0, 1)
+		unit := m[2]
+		if n != 1 {
+			unit += "s"
+		}
+		return Range{start, today, fmt.Sprintf("past %d %s", n, unit)}, nil
+	}
+
+	if m := quarterPattern.FindStringSubmatch(e); m != nil {
+		return quarter(m[1], m[2], today), nil
+	}
+	if m := yearQuarterPattern.FindStringSubmatch(e); m != nil {
+		return quarter(m[2], m[1], today), nil
+	}
+
+	if m := yearPattern.FindStringSubmatch(e); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, today.Location())
+		return clip(Range{start, start.AddDate(1, 0, -1), m[1]}, today), nil
+	}
+
+	if m := monthPattern.FindStringSubmatch(e); m != nil {
+		if month, ok := months[m[1]]; ok {
+			year := today.Year()
+			if m[2] != "" {
+				year, _ = strconv.Atoi(m[2])
+			} else if month > today.Month() {
+				// A bare month means its most recent occurrence
+				year--
+			}
+			start := time.Date(year, month, 1, 0, 0, 0, 0, today.Location())
+			return clip(Range{start, start.AddDate(0, 1, -1), start.Format("January 2006")}, today), nil
+		}
+	}
+
+	if m := betweenPattern.FindStringSubmatch(e); m != nil {
+		start, err := time.ParseInLocation(DateLayout, m[1], today.Location())
+		if err != nil {
+			return Range{}, fmt.Errorf("%w: %q", ErrUnrecognized, expr)
+		}
+		end, err := time.ParseInLocation(DateLayout, m[2], today.Location())
+		if err != nil {
+			return Range{}, fmt.Errorf("%w: %q", ErrUnrecognized, expr)
+		}
+		if end.Before(start) {
+			return Range{}, errors.New("period ends before it starts")
+		}
+		return Range{start, end, m[1] + " to " + m[2]}, nil
+	}
+
+	if r, ok := c.resolveFiscal(e, today); ok {
+		return r, nil
+	}
+	if r, ok := c.resolveCycle(e, today); ok {
+		return r, nil
+	}
+
+	return Range{}, fmt.Errorf("%w: %q", ErrUnrecognized, expr)
+}
+
+// normalize lowercases an expression and collapses its whitespace
+func normalize(expr string) string {
+	e := strings.Join(strings.Fields(strings.ToLower(strings.TrimSpace(expr))), " ")
+	return strings.TrimSuffix(e, ".")
+}
+
+// quarter resolves quarter q of year, or of the current year when year is
+// empty
+func quarter(q, year string, today time.Time) Range {
+	n, _ := strconv.Atoi(q)
+	y := today.Year()
+	if year != "" {
+		y, _ = strconv.Atoi(year)
+	}
+	start := time.Date(y, time.Month(3*(n-1)+1), 1, 0, 0, 0, 0, today.Location())
+	return clip(Range{start, start.AddDate(0, 3, -1), quarterLabel(start)}, today)
+}
+
+// clip ends a range that runs past today at today
+func clip(r Range, today time.Time) Range {
+	if r.End.After(today) && !r.Start.After(today) {
+		r.End = today
+	}
+	return r
+}
+
+func quarterLabel(start time.Time) string {
+	return fmt.Sprintf("Q%d %d", (int(start.Month())-1)/3+1, start.Year())
+}
+
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(today time.Time, weekStart time.Weekday) time.Time {
+	offset := (int(today.Weekday()) - int(weekStart) + 7) % 7
+	return today.AddDate(0, 0, -offset)
+}
+
+func startOfMonth(today time.Time) time.Time {
+	return time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+}
+
+func startOfQuarter(today time.Time) time.Time {
+	month := time.Month(3*((int(today.Month())-1)/3) + 1)
+	return time.Date(today.Year(), month, 1, 0, 0, 0, 0, today.Location())
+}
+
+func startOfYear(today time.Time) time.Time {
+	return time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, today.Location())
+}
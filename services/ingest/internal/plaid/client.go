@@ -1,278 +1,370 @@
 package plaid
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"net/http"
+	"sync"
 	"time"
 
+	"github.com/finagent/ingest/internal/breaker"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/faultinjection"
+	"github.com/finagent/ingest/internal/metrics"
 	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/retry"
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/shopspring/decimal"
 )
 
 // Client wraps Plaid API interactions
 type Client struct {
+	mu          sync.RWMutex
 	clientID    string
 	secret      string
 	environment string
-	encryptionKey []byte
+	encryption  *encryption.Service
+	httpClient  *http.Client
+	breaker     *breaker.Breaker
+	retry       retry.Policy
+	faults      *faultinjection.Injector
 }
 
-// NewClient creates a new Plaid client
-func NewClient(clientID, secret, environment string) *Client {
+// baseURLs are the Plaid API hosts per environment
+var baseURLs = map[string]string{
+	"sandbox":     "https://sandbox.plaid.com",
+	"development": "https://development.plaid.com",
+	"production":  "https://production.plaid.com",
+}
+
+// NewClient creates a new Plaid client whose calls go through a circuit
+// breaker configured by breakerOpts and are retried per retryPolicy
+func NewClient(clientID, secret, environment string, enc *encryption.Service, breakerOpts breaker.Options, retryPolicy retry.Policy) *Client {
 	return &Client{
 		clientID:    clientID,
 		secret:      secret,
 		environment: environment,
-		encryptionKey: []byte("dev-key-32-chars-long-for-aes-256"), // This should come from config
+		encryption:  enc,
+		httpClient:  tracing.HTTPClient(10 * time.Second),
+		breaker:     breaker.New("plaid", breakerOpts),
+		retry:       retryPolicy,
+	}
+}
+
+// SetFaults makes the client's calls fail and slow down as the injector
+// decides, for resilience testing
+func (c *Client) SetFaults(faults *faultinjection.Injector) {
+	c.faults = faults
+}
+
+// Breaker is the circuit breaker guarding the client's API calls
+func (c *Client) Breaker() *breaker.Breaker {
+	return c.breaker
+}
+
+// Ping checks that the Plaid API of the client's environment is reachable.
+// Any HTTP response counts; only failing to connect is an error.
+func (c *Client) Ping(ctx context.Context) error {
+	baseURL, ok := baseURLs[c.environment]
+	if !ok {
+		return fmt.Errorf("unknown Plaid environment %q", c.environment)
 	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Plaid: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SetCredentials replaces the API credentials, e.g. after a secret rotation
+func (c *Client) SetCredentials(clientID, secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clientID = clientID
+	c.secret = secret
 }
 
 // ExchangePublicToken exchanges a public token for an access token
-func (c *Client) ExchangePublicToken(publicToken string) (accessToken, itemID string, err error) {
-	// This is a mock implementation
-	// In a real implementation, you would call the Plaid API
-	
+func (c *Client) ExchangePublicToken(ctx context.Context, publicToken string) (accessToken, itemID string, err error) {
 	if publicToken == "" {
 		return "", "", fmt.Errorf("public token is required")
 	}
+
+	err = c.call(ctx, "/item/public_token/exchange", func(ctx context.Context) error {
+		// This is a mock implementation
+		// In a real implementation, you would call the Plaid API
 	
-	// Generate mock values for development
-	accessToken = fmt.Sprintf("access-sandbox-%d", time.Now().Unix())
-	itemID = fmt.Sprintf("item-%d", time.Now().Unix())
-	
+		// Generate mock values for development
+		accessToken = fmt.Sprintf("access-sandbox-%d", time.Now().Unix())
+		itemID = fmt.Sprintf("item-%d", time.Now().Unix())
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
 	return accessToken, itemID, nil
 }
 
 // CreateLinkToken creates a Link token for Plaid Link
-func (c *Client) CreateLinkToken(userID string) (linkToken string, expiration time.Time, err error) {
+func (c *Client) CreateLinkToken(ctx context.Context, userID string) (linkToken string, expiration time.Time, err error) {
 	if userID == "" {
 		return "", time.Time{}, fmt.Errorf("user ID is required")
 	}
 	
-	// Mock implementation
-	linkToken = fmt.Sprintf("link-sandbox-%s-%d", userID, time.Now().Unix())
-	expiration = time.Now().Add(4 * time.Hour)
-	
+	err = c.call(ctx, "/link/token/create", func(ctx context.Context) error {
+		// Mock implementation
+		linkToken = fmt.Sprintf("link-sandbox-%s-%d", userID, time.Now().Unix())
+		expiration = time.Now().Add(4 * time.Hour)
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
 	return linkToken, expiration, nil
 }
 
 // GetInstitution gets institution information
-func (c *Client) GetInstitution(itemID string) (map[string]interface{}, error) {
-	// Mock institution data
-	institution := map[string]interface{}{
-		"institution_id": "ins_109508",
-		"name":          "First Platypus Bank",
-		"products":      []string{"assets", "auth", "balance", "transactions", "investments"},
-		"country_codes": []string{"US"},
+func (c *Client) GetInstitution(ctx context.Context, itemID string) (institution map[string]interface{}, err error) {
+	err = c.call(ctx, "/institutions/get_by_id", func(ctx context.Context) error {
+		// Mock institution data
+		institution = map[string]interface{}{
+			"institution_id": "ins_109508",
+			"name":          "First Platypus Bank",
+			"products":      []string{"assets", "auth", "balance", "transactions", "investments"},
+			"country_codes": []string{"US"},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
 	return institution, nil
 }
 
 // GetAccounts retrieves accounts for an access token
-func (c *Client) GetAccounts(accessToken string) ([]models.PlaidAccount, error) {
+func (c *Client) GetAccounts(ctx context.Context, accessToken string) (accounts []models.PlaidAccount, err error) {
 	if accessToken == "" {
 		return nil, fmt.Errorf("access token is required")
 	}
 	
-	// Mock account data for development
-	accounts := []models.PlaidAccount{
-		{
-			ID:           "acc_1_checking",
-			Name:         "Plaid Checking",
-			Mask:         stringPtr("0000"),
-			OfficialName: stringPtr("Plaid Gold Standard 0% Interest Checking"),
-			Type:         "depository",
-			Subtype:      stringPtr("checking"),
-			Balances: models.PlaidBalance{
-				Current:           float64Ptr(1250.55),
-				Available:         float64Ptr(1200.55),
-				IsoCurrencyCode:   stringPtr("USD"),
+	err = c.call(ctx, "/accounts/get", func(ctx context.Context) error {
+		// Mock account data for development
+		accounts = []models.PlaidAccount{
+			{
+				ID:           "acc_1_checking",
+				Name:         "Plaid Checking",
+				Mask:         stringPtr("0000"),
+				OfficialName: stringPtr("Plaid Gold Standard 0% Interest Checking"),
+				Type:         "depository",
+				Subtype:      stringPtr("checking"),
+				Balances: models.PlaidBalance{
+					Current:           decimalPtr("1250.55"),
+					Available:         decimalPtr("1200.55"),
+					IsoCurrencyCode:   stringPtr("USD"),
+				},
 			},
-		},
-		{
-			ID:           "acc_2_savings",
-			Name:         "Plaid Savings",
-			Mask:         stringPtr("1111"),
-			OfficialName: stringPtr("Plaid Silver Standard 0.1% Interest Savings"),
-			Type:         "depository",
-			Subtype:      stringPtr("savings"),
-			Balances: models.PlaidBalance{
-				Current:           float64Ptr(5025.10),
-				Available:         float64Ptr(5025.10),
-				IsoCurrencyCode:   stringPtr("USD"),
+			{
+				ID:           "acc_2_savings",
+				Name:         "Plaid Savings",
+				Mask:         stringPtr("1111"),
+				OfficialName: stringPtr("Plaid Silver Standard 0.1% Interest Savings"),
+				Type:         "depository",
+				Subtype:      stringPtr("savings"),
+				Balances: models.PlaidBalance{
+					Current:           decimalPtr("5025.10"),
+					Available:         decimalPtr("5025.10"),
+					IsoCurrencyCode:   stringPtr("USD"),
+				},
 			},
-		},
-		{
-			ID:           "acc_3_investment",
-			Name:         "Plaid Investment",
-			Mask:         stringPtr("2222"),
-			OfficialName: stringPtr("Plaid Diamond 12-Month CD"),
-			Type:         "investment",
-			Subtype:      stringPtr("cd"),
-			Balances: models.PlaidBalance{
-				Current:           float64Ptr(15750.25),
-				IsoCurrencyCode:   stringPtr("USD"),
+			{
+				ID:           "acc_3_investment",
+				Name:         "Plaid Investment",
+				Mask:         stringPtr("2222"),
+				OfficialName: stringPtr("Plaid Diamond 12-Month CD"),
+				Type:         "investment",
+				Subtype:      stringPtr("cd"),
+				Balances: models.PlaidBalance{
+					Current:           decimalPtr("15750.25"),
+					IsoCurrencyCode:   stringPtr("USD"),
+				},
 			},
-		},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
 	return accounts, nil
 }
 
 // GetTransactions retrieves transactions for an access token
-func (c *Client) GetTransactions(accessToken string, startDate, endDate time.Time, cursor string) ([]models.PlaidTransaction, string, error) {
+func (c *Client) GetTransactions(ctx context.Context, accessToken string, startDate, endDate time.Time, cursor string) (transactions []models.PlaidTransaction, nextCursor string, err error) {
 	if accessToken == "" {
 		return nil, "", fmt.Errorf("access token is required")
 	}
 	
-	// Mock transaction data
-	transactions := []models.PlaidTransaction{
-		{
-			ID:           "txn_1_coffee",
-			AccountID:    "acc_1_checking",
-			Date:         time.Now().AddDate(0, 0, -1).Format("2006-01-02"),
-			Amount:       4.50,
-			MerchantName: stringPtr("Starbucks"),
-			Name:         "Starbucks Store #1234",
-			Category:     []string{"Food and Drink", "Coffee"},
-			Pending:      false,
-		},
-		{
-			ID:           "txn_2_grocery",
-			AccountID:    "acc_1_checking",
-			Date:         time.Now().AddDate(0, 0, -2).Format("2006-01-02"),
-			Amount:       125.67,
-			MerchantName: stringPtr("Whole Foods Market"),
-			Name:         "Whole Foods Market #456",
-			Category:     []string{"Food and Drink", "Groceries"},
-			Pending:      false,
-		},
-		{
-			ID:           "txn_3_payroll",
-			AccountID:    "acc_1_checking",
-			Date:         time.Now().AddDate(0, 0, -3).Format("2006-01-02"),
-			Amount:       -2500.00, // Negative for income in Plaid
-			MerchantName: stringPtr("Acme Corp"),
-			Name:         "Acme Corp Payroll",
-			Category:     []string{"Payroll", "Salary"},
-			Pending:      false,
-		},
-	}
-	
-	nextCursor := fmt.Sprintf("cursor-%d", time.Now().Unix())
+	err = c.call(ctx, "/transactions/sync", func(ctx context.Context) error {
+		// Mock transaction data
+		transactions = []models.PlaidTransaction{
+			{
+				ID:           "txn_1_coffee",
+				AccountID:    "acc_1_checking",
+				Date:         time.Now().AddDate(0, 0, -1).Format("2006-01-02"),
+				Amount:       decimal.RequireFromString("4.50"),
+				MerchantName: stringPtr("Starbucks"),
+				Name:         "Starbucks Store #1234",
+				Category:     []string{"Food and Drink", "Coffee"},
+				Location:     mockLocation("San Francisco", "CA", 37.7793, -122.4193),
+				Pending:      false,
+			},
+			{
+				ID:           "txn_2_grocery",
+				AccountID:    "acc_1_checking",
+				Date:         time.Now().AddDate(0, 0, -2).Format("2006-01-02"),
+				Amount:       decimal.RequireFromString("125.67"),
+				MerchantName: stringPtr("Whole Foods Market"),
+				Name:         "Whole Foods Market #456",
+				Category:     []string{"Food and Drink", "Groceries"},
+				Location:     mockLocation("San Francisco", "CA", 37.7810, -122.4101),
+				Pending:      false,
+			},
+			{
+				ID:           "txn_3_payroll",
+				AccountID:    "acc_1_checking",
+				Date:         time.Now().AddDate(0, 0, -3).Format("2006-01-02"),
+				Amount:       decimal.RequireFromString("-2500.00"), // Negative for income in Plaid
+				MerchantName: stringPtr("Acme Corp"),
+				Name:         "Acme Corp Payroll",
+				Category:     []string{"Payroll", "Salary"},
+				Pending:      false,
+			},
+		}
 	
+		nextCursor = fmt.Sprintf("cursor-%d", time.Now().Unix())
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
 	return transactions, nextCursor, nil
 }
 
 // GetHoldings retrieves investment holdings
-func (c *Client) GetHoldings(accessToken string) (interface{}, error) {
+func (c *Client) GetHoldings(ctx context.Context, accessToken string) (holdings interface{}, err error) {
 	if accessToken == "" {
 		return nil, fmt.Errorf("access token is required")
 	}
 	
-	// Mock holdings data
-	holdings := map[string]interface{}{
-		"accounts": []interface{}{
-			map[string]interface{}{
-				"account_id": "acc_3_investment",
-				"holdings": []interface{}{
-					map[string]interface{}{
-						"account_id":         "acc_3_investment",
-						"security_id":        "sec_AAPL",
-						"institution_price":  150.25,
-						"institution_value":  1502.50,
-						"cost_basis":        1400.00,
-						"quantity":          10.0,
-						"iso_currency_code": "USD",
-					},
-					map[string]interface{}{
-						"account_id":         "acc_3_investment",
-						"security_id":        "sec_TSLA",
-						"institution_price":  245.75,
-						"institution_value":  1228.75,
-						"cost_basis":        1100.00,
-						"quantity":          5.0,
-						"iso_currency_code": "USD",
+	err = c.call(ctx, "/investments/holdings/get", func(ctx context.Context) error {
+		// Mock holdings data
+		holdings = map[string]interface{}{
+			"accounts": []interface{}{
+				map[string]interface{}{
+					"account_id": "acc_3_investment",
+					"holdings": []interface{}{
+						map[string]interface{}{
+							"account_id":         "acc_3_investment",
+							"security_id":        "sec_AAPL",
+							"institution_price":  150.25,
+							"institution_value":  1502.50,
+							"cost_basis":        1400.00,
+							"quantity":          10.0,
+							"iso_currency_code": "USD",
+						},
+						map[string]interface{}{
+							"account_id":         "acc_3_investment",
+							"security_id":        "sec_TSLA",
+							"institution_price":  245.75,
+							"institution_value":  1228.75,
+							"cost_basis":        1100.00,
+							"quantity":          5.0,
+							"iso_currency_code": "USD",
+						},
 					},
 				},
 			},
-		},
-		"securities": []interface{}{
-			map[string]interface{}{
-				"security_id": "sec_AAPL",
-				"cusip":      "037833100",
-				"symbol":     "AAPL",
-				"name":       "Apple Inc.",
-				"type":       "equity",
-			},
-			map[string]interface{}{
-				"security_id": "sec_TSLA",
-				"cusip":      "88160R101",
-				"symbol":     "TSLA",
-				"name":       "Tesla, Inc.",
-				"type":       "equity",
+			"securities": []interface{}{
+				map[string]interface{}{
+					"security_id": "sec_AAPL",
+					"cusip":      "037833100",
+					"symbol":     "AAPL",
+					"name":       "Apple Inc.",
+					"type":       "equity",
+				},
+				map[string]interface{}{
+					"security_id": "sec_TSLA",
+					"cusip":      "88160R101",
+					"symbol":     "TSLA",
+					"name":       "Tesla, Inc.",
+					"type":       "equity",
+				},
 			},
-		},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
 	return holdings, nil
 }
 
 // EncryptToken encrypts an access token
-func (c *Client) EncryptToken(token string) ([]byte, error) {
-	block, err := aes.NewCipher(c.encryptionKey)
-	if err != nil {
-		return nil, err
-	}
-	
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
-	
-	ciphertext := gcm.Seal(nonce, nonce, []byte(token), nil)
-	return ciphertext, nil
+func (c *Client) EncryptToken(ctx context.Context, token string) ([]byte, error) {
+	return c.encryption.Encrypt(ctx, []byte(token))
 }
 
 // DecryptToken decrypts an access token
-func (c *Client) DecryptToken(encryptedToken []byte) (string, error) {
-	block, err := aes.NewCipher(c.encryptionKey)
-	if err != nil {
-		return "", err
-	}
-	
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-	
-	nonceSize := gcm.NonceSize()
-	if len(encryptedToken) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-	
-	nonce, ciphertext := encryptedToken[:nonceSize], encryptedToken[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+func (c *Client) DecryptToken(ctx context.Context, encryptedToken []byte) (string, error) {
+	plaintext, err := c.encryption.Decrypt(ctx, encryptedToken)
 	if err != nil {
 		return "", err
 	}
-	
 	return string(plaintext), nil
 }
 
+// call runs one Plaid API operation under the client's retry policy. Each
+// attempt goes through the breaker, gets its own client span and is
+// recorded for metrics; errors Plaid documents as transient are retried.
+func (c *Client) call(ctx context.Context, operation string, attempt func(ctx context.Context) error) error {
+	return retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		done, err := c.breaker.Allow()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		ctx, span := tracing.StartClientSpan(ctx, "plaid "+operation)
+		err = c.faults.Upstream(ctx, "plaid")
+		if errors.Is(err, faultinjection.ErrInjected) {
+			err = &Error{StatusCode: http.StatusInternalServerError, Type: ErrorTypeAPI, Code: "INTERNAL_SERVER_ERROR", Message: err.Error()}
+		}
+		if err == nil {
+			err = attempt(ctx)
+		}
+		err = classifyError(err)
+		tracing.SetSpanError(span, err)
+		span.End()
+		done(err)
+		metrics.ObservePlaidRequest(operation, start, err)
+		return err
+	})
+}
+
 // Helper functions
 func stringPtr(s string) *string {
 	return &s
 }
 
-func float64Ptr(f float64) *float64 {
-	return &f
-}
\ No newline at end of file
+func decimalPtr(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+func mockLocation(city, region string, lat, lon float64) *models.Location {
+	country := "US"
+	return &models.Location{City: &city, Region: &region, Country: &country, Lat: &lat, Lon: &lon}
+}
@@ -0,0 +1,56 @@
+package plaid
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/finagent/ingest/internal/retry"
+)
+
+// Plaid error types that can be worth retrying
+const (
+	ErrorTypeRateLimit   = "RATE_LIMIT_EXCEEDED"
+	ErrorTypeAPI         = "API_ERROR"
+	ErrorTypeInstitution = "INSTITUTION_ERROR"
+)
+
+// Error is an error returned by the Plaid API
+type Error struct {
+	StatusCode int
+	Type       string // error_type, e.g. RATE_LIMIT_EXCEEDED
+	Code       string // error_code, e.g. TRANSACTIONS_LIMIT
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration // from the Retry-After header, 0 if absent
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("plaid %s (%s): %s", e.Code, e.Type, e.Message)
+}
+
+// Transient reports whether Plaid documents the error as one to retry:
+// rate limits, its own internal errors and maintenance, and institutions
+// that are down or not responding
+func (e *Error) Transient() bool {
+	switch e.Type {
+	case ErrorTypeRateLimit:
+		return true
+	case ErrorTypeAPI:
+		return e.Code == "INTERNAL_SERVER_ERROR" || e.Code == "PLANNED_MAINTENANCE"
+	case ErrorTypeInstitution:
+		return e.Code == "INSTITUTION_DOWN" || e.Code == "INSTITUTION_NOT_RESPONDING"
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// classifyError marks transient Plaid errors retryable, honouring the
+// Retry-After Plaid sent with them
+func classifyError(err error) error {
+	var pe *Error
+	if errors.As(err, &pe) && pe.Transient() {
+		return retry.Retryable(err, pe.RetryAfter)
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package plaid
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// GetPayStubs gets the pay stubs of an item's payroll provider, newest
+// first
+func (c *Client) GetPayStubs(ctx context.Context, accessToken string) (stubs []models.PayStub, err error) {
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+
+	err = c.call(ctx, "/credit/payroll_income/get", func(ctx context.Context) error {
+		// Mock biweekly pay stubs, the latest paying the mock payroll
+		// deposit
+		payDate := time.Now().AddDate(0, 0, -3)
+		for i := 0; i < 2; i++ {
+			date := payDate.AddDate(0, 0, -14*i)
+			start, end := date.AddDate(0, 0, -17).Format("2006-01-02"), date.AddDate(0, 0, -4).Format("2006-01-02")
+			stubs = append(stubs, models.PayStub{
+				ExternalID:      fmt.Sprintf("paystub_%s", date.Format("20060102")),
+				Employer:        "Acme Corp",
+				PayDate:         date.Format("2006-01-02"),
+				PeriodStart:     &start,
+				PeriodEnd:       &end,
+				GrossPay:        decimal.RequireFromString("3461.54"),
+				Taxes:           decimal.RequireFromString("692.31"),
+				Benefits:        decimal.RequireFromString("269.23"),
+				OtherDeductions: decimal.Zero,
+				NetPay:          decimal.RequireFromString("2500.00"),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stubs, nil
+}
@@ -0,0 +1,61 @@
+package plaid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Products an item can be consented to. The sync reads transactions,
+// investments and pay stubs, and statements are downloaded on their own
+// schedule; the rest are listed so consent Plaid reports is kept as is.
+const (
+	ProductAuth               = "auth"
+	ProductBalance            = "balance"
+	ProductIdentity           = "identity"
+	ProductIncomeVerification = "income_verification"
+	ProductInvestments        = "investments"
+	ProductLiabilities        = "liabilities"
+	ProductStatements         = "statements"
+	ProductTransactions       = "transactions"
+)
+
+// Products lists every product an item can be consented to
+var Products = []string{
+	ProductAuth,
+	ProductBalance,
+	ProductIdentity,
+	ProductIncomeVerification,
+	ProductInvestments,
+	ProductLiabilities,
+	ProductStatements,
+	ProductTransactions,
+}
+
+// Item is what Plaid reports about a linked item's consent
+type Item struct {
+	ItemID                string
+	ConsentedProducts     []string
+	ConsentExpirationTime *time.Time
+}
+
+// GetItem gets the products an item was consented to and when that
+// consent expires, if the institution limits it
+func (c *Client) GetItem(ctx context.Context, accessToken string) (item *Item, err error) {
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+
+	err = c.call(ctx, "/item/get", func(ctx context.Context) error {
+		// Mock implementation
+		item = &Item{
+			ItemID:            fmt.Sprintf("item-%d", time.Now().Unix()),
+			ConsentedProducts: []string{ProductTransactions, ProductInvestments},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
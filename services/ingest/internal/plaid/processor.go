@@ -0,0 +1,56 @@
+package plaid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Processors are the partners Plaid can issue processor tokens for. A
+// processor token gives one partner access to one account, for moving
+// money or verifying it, without sharing the item's access token.
+var Processors = []string{
+	"adyen",
+	"alpaca",
+	"checkout",
+	"drivewealth",
+	"dwolla",
+	"galileo",
+	"increase",
+	"modern_treasury",
+	"moov",
+	"treasury_prime",
+	"unit",
+	"wise",
+}
+
+// IsProcessor reports whether Plaid issues processor tokens for processor
+func IsProcessor(processor string) bool {
+	for _, p := range Processors {
+		if p == processor {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateProcessorToken creates a token giving processor access to one
+// account of an item
+func (c *Client) CreateProcessorToken(ctx context.Context, accessToken, accountID, processor string) (processorToken string, err error) {
+	if accessToken == "" || accountID == "" {
+		return "", fmt.Errorf("access token and account ID are required")
+	}
+	if !IsProcessor(processor) {
+		return "", fmt.Errorf("unknown processor %q", processor)
+	}
+
+	err = c.call(ctx, "/processor/token/create", func(ctx context.Context) error {
+		// Mock implementation
+		processorToken = fmt.Sprintf("processor-sandbox-%s-%d", processor, time.Now().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return processorToken, nil
+}
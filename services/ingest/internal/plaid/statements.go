@@ -0,0 +1,51 @@
+package plaid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Statement is a monthly statement an institution published for an
+// account
+type Statement struct {
+	AccountID   string
+	StatementID string
+	Year        int
+	Month       int
+	DatePosted  *time.Time
+}
+
+// ListStatements lists the statements available for an item's accounts
+func (c *Client) ListStatements(ctx context.Context, accessToken string) (statements []Statement, err error) {
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+
+	err = c.call(ctx, "/statements/list", func(ctx context.Context) error {
+		// Mock implementation: the sandbox items have no statements
+		statements = []Statement{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+// DownloadStatement downloads a statement as a PDF
+func (c *Client) DownloadStatement(ctx context.Context, accessToken, statementID string) (pdf []byte, err error) {
+	if accessToken == "" || statementID == "" {
+		return nil, fmt.Errorf("access token and statement ID are required")
+	}
+
+	err = c.call(ctx, "/statements/download", func(ctx context.Context) error {
+		// Mock implementation
+		pdf = []byte(fmt.Sprintf("%%PDF-1.4\n%% statement %s\n%%%%EOF\n", statementID))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
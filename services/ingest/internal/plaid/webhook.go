@@ -0,0 +1,218 @@
+package plaid
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/metrics"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// webhookKeyTTL is how long a verification key is used before it is fetched
+// again, so a key Plaid expires stops being accepted
+const webhookKeyTTL = time.Hour
+
+// ErrInvalidWebhook is returned for webhooks whose Plaid-Verification token
+// is missing, not signed by Plaid, or does not cover the body
+var ErrInvalidWebhook = errors.New("invalid webhook verification")
+
+// webhookClaims are the claims of a Plaid-Verification token
+type webhookClaims struct {
+	BodySHA256 string `json:"request_body_sha256"`
+	jwt.RegisteredClaims
+}
+
+// WebhookVerifier checks the Plaid-Verification token sent with each webhook
+// against the signing keys Plaid publishes, caching them by key ID
+type WebhookVerifier struct {
+	client *Client
+
+	mu   sync.Mutex
+	keys map[string]cachedWebhookKey
+}
+
+type cachedWebhookKey struct {
+	key       *ecdsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewWebhookVerifier creates a webhook verifier fetching keys with client
+func NewWebhookVerifier(client *Client) *WebhookVerifier {
+	return &WebhookVerifier{client: client, keys: make(map[string]cachedWebhookKey)}
+}
+
+// Verify checks that token is an ES256 JWT signed by Plaid whose
+// request_body_sha256 is the hash of body, and returns when it was issued
+// and a hash of the token identifying the delivery. Plaid sends identical
+// bodies for each new SYNC_UPDATES_AVAILABLE, so only the token tells a
+// replay from a fresh notification. Errors other than ErrInvalidWebhook
+// mean the key could not be fetched.
+func (v *WebhookVerifier) Verify(ctx context.Context, token string, body []byte) (time.Time, string, error) {
+	if token == "" {
+		return time.Time{}, "", fmt.Errorf("%w: missing token", ErrInvalidWebhook)
+	}
+
+	var fetchErr error
+	claims := &webhookClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		keyID, _ := t.Header["kid"].(string)
+		if keyID == "" {
+			return nil, fmt.Errorf("missing key ID")
+		}
+		key, err := v.key(ctx, keyID)
+		fetchErr = err
+		return key, err
+	})
+	if fetchErr != nil {
+		return time.Time{}, "", fetchErr
+	}
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if claims.IssuedAt == nil {
+		return time.Time{}, "", fmt.Errorf("%w: missing iat", ErrInvalidWebhook)
+	}
+
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(claims.BodySHA256), []byte(hex.EncodeToString(sum[:]))) != 1 {
+		return time.Time{}, "", fmt.Errorf("%w: body does not match request_body_sha256", ErrInvalidWebhook)
+	}
+	sum = sha256.Sum256([]byte(token))
+	return claims.IssuedAt.Time, hex.EncodeToString(sum[:]), nil
+}
+
+// key returns the verification key with keyID, from the cache or Plaid
+func (v *WebhookVerifier) key(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	cached, ok := v.keys[keyID]
+	v.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < webhookKeyTTL {
+		return cached.key, nil
+	}
+
+	key, err := v.client.GetWebhookVerificationKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.keys[keyID] = cachedWebhookKey{key: key, fetchedAt: time.Now()}
+	v.mu.Unlock()
+	return key, nil
+}
+
+// GetWebhookVerificationKey fetches the public key Plaid signs webhooks
+// with under keyID. Unknown keys and keys Plaid has expired are reported as
+// ErrInvalidWebhook.
+func (c *Client) GetWebhookVerificationKey(ctx context.Context, keyID string) (key *ecdsa.PublicKey, err error) {
+	baseURL, ok := baseURLs[c.environment]
+	if !ok {
+		return nil, fmt.Errorf("unknown Plaid environment %q", c.environment)
+	}
+
+	c.mu.RLock()
+	payload, err := json.Marshal(map[string]string{
+		"client_id": c.clientID,
+		"secret":    c.secret,
+		"key_id":    keyID,
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Key struct {
+			Alg       string `json:"alg"`
+			Crv       string `json:"crv"`
+			Kty       string `json:"kty"`
+			X         string `json:"x"`
+			Y         string `json:"y"`
+			ExpiredAt *int64 `json:"expired_at"`
+		} `json:"key"`
+	}
+	// Not through the breaker: anyone can make us look up a key ID, and
+	// unknown ones must not count as Plaid failures. Transient errors are
+	// not retried; the delivery fails and Plaid sends it again.
+	start := time.Now()
+	err = c.post(ctx, baseURL+"/webhook_verification_key/get", payload, &resp)
+	metrics.ObservePlaidRequest("/webhook_verification_key/get", start, err)
+	var pe *Error
+	if errors.As(err, &pe) && !pe.Transient() {
+		// Plaid has no such key, so the token was not issued by it
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := resp.Key
+	if k.ExpiredAt != nil {
+		return nil, fmt.Errorf("%w: key %s has expired", ErrInvalidWebhook, keyID)
+	}
+	if k.Kty != "EC" || k.Crv != "P-256" || k.Alg != jwt.SigningMethodES256.Alg() {
+		return nil, fmt.Errorf("unsupported webhook verification key %s/%s/%s", k.Kty, k.Crv, k.Alg)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook verification key: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook verification key: %w", err)
+	}
+
+	key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("invalid webhook verification key: point not on curve")
+	}
+	return key, nil
+}
+
+// post sends a JSON request to the Plaid API and decodes the response into
+// out, or Plaid's error into an *Error
+func (c *Client) post(ctx context.Context, url string, payload []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			ErrorType    string `json:"error_type"`
+			ErrorCode    string `json:"error_code"`
+			ErrorMessage string `json:"error_message"`
+			RequestID    string `json:"request_id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Type:       body.ErrorType,
+			Code:       body.ErrorCode,
+			Message:    body.ErrorMessage,
+			RequestID:  body.RequestID,
+		}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,84 @@
+// Package prices quotes current market prices for stock and crypto
+// symbols behind one interface, so callers need not know which source
+// serves which asset type
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Asset types
+const (
+	AssetStock  = "stock"
+	AssetCrypto = "crypto"
+)
+
+// AssetTypes lists the asset types that can be quoted
+var AssetTypes = []string{AssetStock, AssetCrypto}
+
+// ErrNoPrice is returned when no price is known for a symbol
+var ErrNoPrice = errors.New("no price for symbol")
+
+// Provider quotes the current price of a symbol
+type Provider interface {
+	Price(ctx context.Context, symbol string) (decimal.Decimal, error)
+}
+
+// ProviderFunc adapts a function, such as a brokerage client's market
+// price method, to a Provider
+type ProviderFunc func(ctx context.Context, symbol string) (decimal.Decimal, error)
+
+// Price calls f
+func (f ProviderFunc) Price(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	return f(ctx, symbol)
+}
+
+// Router quotes each asset type from its own provider
+type Router map[string]Provider
+
+// Price quotes symbol from the provider for assetType
+func (r Router) Price(ctx context.Context, assetType, symbol string) (decimal.Decimal, error) {
+	p, ok := r[assetType]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no price provider for asset type %q", assetType)
+	}
+	return p.Price(ctx, symbol)
+}
+
+// securityProvider quotes stocks at the latest institution price Plaid
+// synced for any holding of the symbol
+type securityProvider struct {
+	db *database.Database
+}
+
+// NewSecurityProvider creates a stock price provider backed by synced
+// holdings. Symbols no one holds have no price.
+func NewSecurityProvider(db *database.Database) Provider {
+	return &securityProvider{db: db}
+}
+
+func (p *securityProvider) Price(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	var price decimal.Decimal
+	err := p.db.Reader(ctx).QueryRow(ctx, `
+		SELECT h.institution_price
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		WHERE upper(s.symbol) = $1 AND h.institution_price IS NOT NULL AND h.deleted_at IS NULL
+		ORDER BY h.institution_price_as_of DESC NULLS LAST, h.last_refresh DESC NULLS LAST
+		LIMIT 1
+	`, strings.ToUpper(symbol)).Scan(&price)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return decimal.Zero, ErrNoPrice
+	}
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to query price of %s: %w", symbol, err)
+	}
+	return price, nil
+}
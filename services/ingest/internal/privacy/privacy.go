@@ -0,0 +1,355 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportTTL is how long a generated archive remains downloadable
+const exportTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrNotFound is returned when an export or deletion request does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrDeletionPending is returned when a deletion is already scheduled
+	ErrDeletionPending = errors.New("deletion already scheduled")
+)
+
+// exportTables lists every table holding user data and the columns included
+// in an export. Secrets (access tokens, webhook signing secrets) are omitted.
+var exportTables = []struct {
+	name  string
+	query string
+}{
+	{"user", `SELECT id, auth_id, email, created_at, updated_at FROM users WHERE id = $1`},
+	{"user_preferences", `SELECT timezone, base_currency, week_start, number_format, fiscal_year_start, budget_period, updated_at FROM user_preferences WHERE user_id = $1`},
+	{"plaid_items", `SELECT id, institution_id, institution_name, status, created_at, updated_at, last_sync_at FROM plaid_items WHERE user_id = $1`},
+	{"accounts", `SELECT * FROM accounts WHERE user_id = $1`},
+	{"account_groups", `SELECT id, name, created_at, updated_at FROM account_groups WHERE user_id = $1`},
+	{"transactions", `SELECT * FROM transactions WHERE user_id = $1 ORDER BY date`},
+	{"securities", `SELECT * FROM securities WHERE user_id = $1`},
+	{"holdings", `SELECT * FROM holdings WHERE user_id = $1`},
+	{"investment_transactions", `SELECT * FROM investment_transactions WHERE user_id = $1 ORDER BY date`},
+	{"record_versions", `SELECT table_name, record_id, operation, data, changed_at FROM record_versions WHERE user_id = $1 ORDER BY id`},
+	{"duplicate_links", `SELECT record_type, canonical_id, duplicate_id, status, created_at, updated_at FROM duplicate_links WHERE user_id = $1 ORDER BY created_at`},
+	{"expense_rules", `SELECT id, expense_type, merchant, category, account_id, created_at, updated_at FROM expense_rules WHERE user_id = $1 ORDER BY created_at`},
+	{"report_definitions", `SELECT id, name, period, filters, group_by, created_at, updated_at FROM report_definitions WHERE user_id = $1 ORDER BY created_at`},
+	{"roundup_goals", `SELECT id, name, target_amount, started_on, ended_on, created_at, updated_at FROM roundup_goals WHERE user_id = $1 ORDER BY started_on`},
+	{"refund_links", `SELECT charge_id, refund_id, status, created_at, updated_at FROM refund_links WHERE user_id = $1 ORDER BY created_at`},
+	{"crypto_positions", `SELECT * FROM crypto_positions WHERE user_id = $1`},
+	{"crypto_income", `SELECT id, source, external_id, symbol, kind, quantity, price, cost_basis, received_at, created_at FROM crypto_income WHERE user_id = $1 ORDER BY received_at`},
+	{"pay_stubs", `SELECT id, external_id, employer, pay_date, period_start, period_end, gross_pay, taxes, benefits, other_deductions, net_pay, created_at, updated_at FROM pay_stubs WHERE user_id = $1 ORDER BY pay_date`},
+	{"rental_properties", `SELECT id, name, address, created_at, updated_at FROM rental_properties WHERE user_id = $1 ORDER BY created_at`},
+	{"rental_transactions", `SELECT transaction_id, property_id, kind, interest, tagged_at FROM rental_transactions WHERE user_id = $1 ORDER BY tagged_at`},
+	{"dependents", `SELECT id, name, birth_year, scopes, created_at, updated_at FROM dependents WHERE user_id = $1 ORDER BY created_at`},
+	{"allowance_schedules", `SELECT id, dependent_id, amount, frequency, next_date, description, created_at, updated_at FROM allowance_schedules WHERE user_id = $1 ORDER BY created_at`},
+	{"dependent_ledger", `SELECT id, dependent_id, kind, amount, description, entry_date, schedule_id, created_at FROM dependent_ledger WHERE user_id = $1 ORDER BY entry_date, created_at`},
+	{"period_definitions", `SELECT id, name, anchor_date, every, unit, created_at, updated_at FROM period_definitions WHERE user_id = $1 ORDER BY created_at`},
+	{"equity_grants", `SELECT id, name, symbol, grant_type, strike_price, total_shares, grant_date, account_id, created_at, updated_at FROM equity_grants WHERE user_id = $1 ORDER BY grant_date`},
+	{"equity_vests", `SELECT id, grant_id, vest_date, shares, investment_transaction_id, matched_at FROM equity_vests WHERE user_id = $1 ORDER BY vest_date`},
+	{"alert_rules", `SELECT id, name, condition, severity, enabled, last_triggered_at, created_at, updated_at FROM alert_rules WHERE user_id = $1 ORDER BY created_at`},
+	{"notifications", `SELECT id, rule_id, type, title, message, metadata, read_at, created_at FROM notifications WHERE user_id = $1 ORDER BY created_at`},
+	{"export_destinations", `SELECT id, provider, bucket, region, prefix, format, kms_key_id, enabled, last_exported_at, last_error, created_at, updated_at FROM export_destinations WHERE user_id = $1 ORDER BY created_at`},
+	{"notification_channels", `SELECT id, kind, name, enabled, last_delivery_at, last_error, created_at FROM notification_channels WHERE user_id = $1 ORDER BY created_at`},
+	{"recommendations", `SELECT id, kind, from_account_id, to_account_id, amount, currency, title, message, status, decided_at, created_at FROM recommendations WHERE user_id = $1 ORDER BY created_at`},
+	{"holding_valuations", `SELECT holding_id, date, account_id, symbol, security_name, quantity, price, value, cost_basis, currency FROM holding_valuations WHERE user_id = $1 ORDER BY date, holding_id`},
+	{"watchlist_items", `SELECT id, symbol, asset_type, last_price, price_updated_at, created_at FROM watchlist_items WHERE user_id = $1 ORDER BY created_at`},
+	{"watchlist_alerts", `SELECT id, item_id, condition, threshold, enabled, last_triggered_at, created_at FROM watchlist_alerts WHERE user_id = $1 ORDER BY created_at`},
+	{"digest_deliveries", `SELECT id, frequency, period_start, period_end, status, portfolio_value, sent_at, created_at FROM digest_deliveries WHERE user_id = $1 ORDER BY created_at`},
+	{"household_memberships", `SELECT hm.household_id, h.name, hm.role, hm.status, hm.joined_at FROM household_members hm JOIN households h ON h.id = hm.household_id WHERE hm.user_id = $1`},
+	{"crypto_orders", `SELECT * FROM crypto_orders WHERE user_id = $1 ORDER BY created_at`},
+	{"transfers", `SELECT * FROM transfers WHERE user_id = $1 ORDER BY created_at`},
+	{"jobs", `SELECT id, plaid_item_id, job_type, status, progress, records_processed, error_message, started_at, completed_at, created_at FROM jobs WHERE user_id = $1 ORDER BY created_at`},
+	{"webhook_subscriptions", `SELECT id, url, event_types, is_active, created_at, updated_at FROM webhook_subscriptions WHERE user_id = $1`},
+	{"sessions", `SELECT id, device, user_agent, ip_address, last_seen_at, revoked_at, created_at FROM sessions WHERE user_id = $1`},
+	{"insights", `SELECT id, type, severity, title, message, metadata, resolved_at, created_at FROM insights WHERE user_id = $1 ORDER BY created_at`},
+	{"statements", `SELECT id, account_id, year, month, date_posted, size_bytes, sha256, created_at FROM statements WHERE user_id = $1 ORDER BY year, month`},
+	{"transaction_attachments", `SELECT id, transaction_id, filename, content_type, size_bytes, sha256, created_at FROM transaction_attachments WHERE user_id = $1 ORDER BY created_at`},
+	{"grants", `SELECT id, owner_user_id, grantee_user_id, role, revoked_at, created_at FROM grants WHERE owner_user_id = $1 OR grantee_user_id = $1`},
+}
+
+// encryptedColumns are decrypted before export
+var encryptedColumns = map[string][]string{
+	"accounts": {"mask", "official_name"},
+}
+
+// Deletion describes a scheduled account deletion
+type Deletion struct {
+	UserID       string     `json:"user_id"`
+	RequestedBy  string     `json:"requested_by"`
+	RequestedAt  time.Time  `json:"requested_at"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// Service exports and deletes user data for GDPR/CCPA requests
+type Service struct {
+	db         *database.Database
+	encryption *encryption.Service
+	audit      *audit.Logger
+	grace      time.Duration
+
+	// beforePurge clean up what a user has outside Postgres
+	beforePurge []func(ctx context.Context, userID string) error
+}
+
+// NewService creates a privacy service. Deletions are carried out once the
+// grace period has passed, giving users time to cancel.
+func NewService(db *database.Database, enc *encryption.Service, auditLog *audit.Logger, grace time.Duration) *Service {
+	return &Service{
+		db:         db,
+		encryption: enc,
+		audit:      auditLog,
+		grace:      grace,
+	}
+}
+
+// OnPurge registers fn to remove a user's data kept outside Postgres, such
+// as stored files, before the user is deleted. A failing fn leaves the
+// deletion due, to be retried.
+func (s *Service) OnPurge(fn func(ctx context.Context, userID string) error) {
+	s.beforePurge = append(s.beforePurge, fn)
+}
+
+// CreateExport builds a zip archive of all of a user's data, stores it for
+// download and returns its ID and size
+func (s *Service) CreateExport(ctx context.Context, userID string) (string, int, error) {
+	archive, err := s.buildArchive(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var exportID string
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO data_exports (user_id, archive, size_bytes, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, userID, archive, len(archive), time.Now().Add(exportTTL)).Scan(&exportID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to store export: %w", err)
+	}
+
+	return exportID, len(archive), nil
+}
+
+// GetExport returns a stored, unexpired export archive
+func (s *Service) GetExport(ctx context.Context, userID, exportID string) ([]byte, error) {
+	var archive []byte
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT archive FROM data_exports
+		WHERE id = $1 AND user_id = $2 AND expires_at > NOW()
+	`, exportID, userID).Scan(&archive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export: %w", err)
+	}
+	return archive, nil
+}
+
+func (s *Service) buildArchive(ctx context.Context, userID string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	counts := make(map[string]int)
+	for _, table := range exportTables {
+		var data []byte
+		err := s.db.Pool.QueryRow(ctx,
+			"SELECT COALESCE(json_agg(t), '[]'::json) FROM ("+table.query+") t", userID).Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table.name, err)
+		}
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", table.name, err)
+		}
+		if err := s.decryptColumns(ctx, rows, encryptedColumns[table.name]); err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", table.name, err)
+		}
+		counts[table.name] = len(rows)
+
+		if err := writeJSON(zw, table.name+".json", rows); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := map[string]interface{}{
+		"user_id":       userID,
+		"generated_at":  time.Now().UTC(),
+		"record_counts": counts,
+	}
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Service) decryptColumns(ctx context.Context, rows []map[string]interface{}, columns []string) error {
+	for _, row := range rows {
+		for _, column := range columns {
+			value, ok := row[column].(string)
+			if !ok {
+				continue
+			}
+			decrypted, err := s.encryption.DecryptString(ctx, value)
+			if err != nil {
+				return err
+			}
+			row[column] = decrypted
+		}
+	}
+	return nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ScheduleDeletion schedules a user's data for purging after the grace period
+func (s *Service) ScheduleDeletion(ctx context.Context, userID, requestedBy string) (*Deletion, error) {
+	d := Deletion{UserID: userID, RequestedBy: requestedBy}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_deletions (user_id, requested_by, requested_at, scheduled_for)
+		VALUES ($1, $2, NOW(), $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET requested_by = EXCLUDED.requested_by, requested_at = EXCLUDED.requested_at,
+		    scheduled_for = EXCLUDED.scheduled_for, cancelled_at = NULL
+		WHERE user_deletions.cancelled_at IS NOT NULL
+		RETURNING requested_at, scheduled_for
+	`, userID, requestedBy, time.Now().Add(s.grace)).Scan(&d.RequestedAt, &d.ScheduledFor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDeletionPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+	return &d, nil
+}
+
+// CancelDeletion cancels a pending deletion during the grace period
+func (s *Service) CancelDeletion(ctx context.Context, userID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE user_deletions SET cancelled_at = NOW()
+		WHERE user_id = $1 AND cancelled_at IS NULL AND completed_at IS NULL
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeDue deletes every user whose grace period has expired and returns
+// their IDs. Deleting the user row cascades to all of their data, including
+// Plaid access tokens, accounts, transactions, holdings and orders.
+func (s *Service) PurgeDue(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT user_id FROM user_deletions
+		WHERE scheduled_for <= NOW() AND cancelled_at IS NULL AND completed_at IS NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due deletions: %w", err)
+	}
+
+	var due []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan deletion: %w", err)
+		}
+		due = append(due, userID)
+	}
+	rows.Close()
+
+	var purged []string
+	for _, userID := range due {
+		if err := s.purge(ctx, userID); err != nil {
+			return purged, err
+		}
+		purged = append(purged, userID)
+	}
+	return purged, nil
+}
+
+func (s *Service) purge(ctx context.Context, userID string) error {
+	for _, fn := range s.beforePurge {
+		if err := fn(ctx, userID); err != nil {
+			return fmt.Errorf("failed to clean up user %s: %w", userID, err)
+		}
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM users WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", userID, err)
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE user_deletions SET completed_at = NOW() WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to complete deletion for %s: %w", userID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit deletion for %s: %w", userID, err)
+	}
+
+	return s.audit.RecordAs(ctx, audit.ActorSystem, audit.Entry{
+		Action:       audit.ActionDeletionCompleted,
+		TargetUserID: userID,
+	})
+}
+
+// RunPurger purges due deletions every interval until ctx is cancelled
+func (s *Service) RunPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDue(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to purge deleted users", "error", err)
+		}
+		if len(purged) > 0 {
+			slog.InfoContext(ctx, "Purged deleted users", "count", len(purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often windows with no recent requests are dropped
+const memorySweepInterval = time.Minute
+
+// memoryWindows is the in-process counterpart of the slidingWindow script:
+// each key keeps the times of its admitted requests within the window
+type memoryWindows struct {
+	mu        sync.Mutex
+	windows   map[string]*memoryWindow
+	lastSweep time.Time
+}
+
+type memoryWindow struct {
+	hits    []time.Time
+	expires time.Time
+}
+
+func newMemoryWindows() *memoryWindows {
+	return &memoryWindows{windows: make(map[string]*memoryWindow), lastSweep: time.Now()}
+}
+
+func (m *memoryWindows) allow(key string, limit Limit, now time.Time) Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= memorySweepInterval {
+		m.sweep(now)
+	}
+
+	w, ok := m.windows[key]
+	if !ok {
+		w = &memoryWindow{}
+		m.windows[key] = w
+	}
+
+	cutoff := now.Add(-limit.Window)
+	kept := w.hits[:0]
+	for _, hit := range w.hits {
+		if hit.After(cutoff) {
+			kept = append(kept, hit)
+		}
+	}
+	w.hits = kept
+
+	allowed := len(w.hits) < limit.Requests
+	if allowed {
+		w.hits = append(w.hits, now)
+	}
+	w.expires = now.Add(limit.Window)
+
+	reset := now.Add(limit.Window)
+	if len(w.hits) > 0 {
+		reset = w.hits[0].Add(limit.Window)
+	}
+	return Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: max(limit.Requests-len(w.hits), 0),
+		Reset:     reset,
+	}
+}
+
+// sweep drops windows whose every request has aged out, like the PEXPIRE on
+// the Redis keys
+func (m *memoryWindows) sweep(now time.Time) {
+	for key, w := range m.windows {
+		if !now.Before(w.expires) {
+			delete(m.windows, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindow atomically drops entries older than the window, admits the
+// request if fewer than limit remain, and returns allowed, count and the time
+// in milliseconds at which the oldest entry expires
+var slidingWindow = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = now + window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window
+end
+return {allowed, count, reset}
+`)
+
+// Limit is a maximum number of requests within a sliding window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// PerMinute returns a limit of n requests per minute
+func PerMinute(n int) Limit {
+	return Limit{Requests: n, Window: time.Minute}
+}
+
+// Result is the outcome of a rate limit check
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RetryAfter returns how long the caller should wait before retrying
+func (r Result) RetryAfter() time.Duration {
+	wait := time.Until(r.Reset)
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// Limiter enforces sliding-window rate limits shared across instances via
+// Redis, or per process when created with NewMemory
+type Limiter struct {
+	redis  *redis.Client
+	memory *memoryWindows
+}
+
+// New creates a new rate limiter
+func New(redisClient *redis.Client) *Limiter {
+	return &Limiter{redis: redisClient}
+}
+
+// NewMemory creates a rate limiter that keeps its windows in process memory,
+// for local development and tests. Limits are not shared between instances.
+func NewMemory() *Limiter {
+	return &Limiter{memory: newMemoryWindows()}
+}
+
+// Allow records a request against key and reports whether it is within limit
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if l.memory != nil {
+		return l.memory.allow(key, limit, time.Now()), nil
+	}
+
+	now := time.Now().UnixMilli()
+	window := limit.Window.Milliseconds()
+	member := strconv.FormatInt(now, 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
+
+	values, err := slidingWindow.Run(ctx, l.redis, []string{"ratelimit:" + key},
+		now, window, limit.Requests, member).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit response: %v", values)
+	}
+
+	remaining := limit.Requests - int(values[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		Allowed:   values[0] == 1,
+		Limit:     limit.Requests,
+		Remaining: remaining,
+		Reset:     time.UnixMilli(values[2]),
+	}, nil
+}
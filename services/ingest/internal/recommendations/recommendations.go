@@ -0,0 +1,320 @@
+// Package recommendations turns surplus cash into advice. After a sync it
+// compares each checking account's balance with what the account spends
+// in a typical month; cash beyond a cushion of that spending is
+// recommended for the user's savings account, where it earns more. It
+// only keeps records: nothing here moves money.
+package recommendations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// KindTransfer recommends moving cash between two of a user's accounts
+const KindTransfer = "transfer"
+
+// HistoryDays is how far back Generate should be given transactions to
+// measure an account's monthly spending
+const HistoryDays = 90
+
+// A checking account keeps bufferMonths of its spending, and at least
+// minBuffer; a surplus is only worth moving from minTransfer, and is
+// rounded down to a multiple of roundTo
+var (
+	bufferMonths = decimal.NewFromFloat(1.5)
+	minBuffer    = decimal.NewFromInt(1000)
+	minTransfer  = decimal.NewFromInt(100)
+	roundTo      = decimal.NewFromInt(50)
+)
+
+// assumedSavingsAPY is the yield, in percent, used to estimate what a
+// transfer earns; Plaid does not report account yields
+const assumedSavingsAPY = 4.0
+
+// decisionCooldown is how long after a user accepts or dismisses a
+// transfer the same one is not recommended again
+const decisionCooldown = 14 * 24 * time.Hour
+
+var (
+	// ErrNotFound is returned for a recommendation that does not exist or
+	// belongs to another user
+	ErrNotFound = errors.New("recommendation not found")
+	// ErrDecided is returned when a recommendation is no longer pending
+	ErrDecided = errors.New("recommendation already decided")
+)
+
+// Engine generates recommendations and tracks users' decisions on them
+type Engine struct {
+	db *database.Database
+}
+
+// NewEngine creates a new recommendation engine
+func NewEngine(db *database.Database) *Engine {
+	return &Engine{db: db}
+}
+
+// Transfer is surplus cash worth moving from a checking account to a
+// savings account
+type Transfer struct {
+	From           models.Account
+	To             models.Account
+	Amount         decimal.Decimal
+	Balance        decimal.Decimal
+	Buffer         decimal.Decimal
+	MonthlyOutflow decimal.Decimal
+}
+
+// Plan finds the transfers worth recommending from accounts and their
+// last HistoryDays of transactions, largest first. Each checking account
+// keeps a cushion of its monthly spending; the rest goes to the largest
+// savings account in the same currency. Accounts not counted in analytics
+// are left out.
+func Plan(accounts []models.Account, transactions []models.Transaction) []Transfer {
+	savings := make(map[string]models.Account)
+	for _, acc := range accounts {
+		if !acc.Counted() || !isSavings(acc) || acc.BalanceCurrent == nil {
+			continue
+		}
+		best, ok := savings[acc.Currency]
+		if !ok || acc.BalanceCurrent.GreaterThan(*best.BalanceCurrent) ||
+			(acc.BalanceCurrent.Equal(*best.BalanceCurrent) && acc.ID < best.ID) {
+			savings[acc.Currency] = acc
+		}
+	}
+
+	outflows := make(map[string]decimal.Decimal)
+	for _, txn := range transactions {
+		if !txn.IsPending && txn.Amount.IsPositive() {
+			outflows[txn.AccountID] = outflows[txn.AccountID].Add(txn.Amount)
+		}
+	}
+	months := decimal.NewFromInt(HistoryDays).Div(decimal.NewFromInt(30))
+
+	transfers := []Transfer{}
+	for _, acc := range accounts {
+		if !acc.Counted() || !isChecking(acc) {
+			continue
+		}
+		to, ok := savings[acc.Currency]
+		if !ok {
+			continue
+		}
+		balance := acc.BalanceAvailable
+		if balance == nil {
+			balance = acc.BalanceCurrent
+		}
+		if balance == nil {
+			continue
+		}
+
+		monthly := outflows[acc.ID].Div(months).Round(2)
+		buffer := decimal.Max(minBuffer, monthly.Mul(bufferMonths).Round(2))
+		amount := balance.Sub(buffer).Div(roundTo).Floor().Mul(roundTo)
+		if amount.LessThan(minTransfer) {
+			continue
+		}
+		transfers = append(transfers, Transfer{
+			From:           acc,
+			To:             to,
+			Amount:         amount,
+			Balance:        *balance,
+			Buffer:         buffer,
+			MonthlyOutflow: monthly,
+		})
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].Amount.Equal(transfers[j].Amount) {
+			return transfers[i].Amount.GreaterThan(transfers[j].Amount)
+		}
+		return transfers[i].From.ID < transfers[j].From.ID
+	})
+	return transfers
+}
+
+func isChecking(acc models.Account) bool {
+	return acc.Type == "depository" && acc.Subtype != nil && *acc.Subtype == "checking"
+}
+
+func isSavings(acc models.Account) bool {
+	return acc.Type == "depository" && acc.Subtype != nil &&
+		(*acc.Subtype == "savings" || *acc.Subtype == "money market")
+}
+
+// recommendation words a transfer as advice
+func recommendation(userID string, t Transfer) models.Recommendation {
+	currency := t.From.Currency
+	money := func(amount decimal.Decimal) string {
+		return digest.FormatAmount(amount, currency, "")
+	}
+	interest := t.Amount.Mul(decimal.NewFromFloat(assumedSavingsAPY / 100)).Round(2)
+
+	return models.Recommendation{
+		UserID:        userID,
+		Kind:          KindTransfer,
+		FromAccountID: t.From.ID,
+		ToAccountID:   t.To.ID,
+		Amount:        t.Amount,
+		Currency:      currency,
+		Title:         fmt.Sprintf("Move %s to %s", money(t.Amount), t.To.DisplayName()),
+		Message: fmt.Sprintf("%s holds %s more than a cushion of %s, about %s months of its spending. "+
+			"In %s at an assumed %.1f%% APY it could earn about %s a year.",
+			t.From.DisplayName(), money(t.Amount), money(t.Buffer), bufferMonths.String(),
+			t.To.DisplayName(), assumedSavingsAPY, money(interest)),
+		Metadata: map[string]interface{}{
+			"balance":                   t.Balance,
+			"buffer":                    t.Buffer,
+			"monthly_outflow":           t.MonthlyOutflow,
+			"assumed_apy":               assumedSavingsAPY,
+			"estimated_annual_interest": interest,
+		},
+		Status: models.RecommendationPending,
+	}
+}
+
+// Generate records the transfers Plan finds for a user. A pending
+// recommendation for the same accounts is refreshed rather than repeated,
+// and one the user decided on recently is not made again. Pending
+// recommendations Plan no longer finds are expired. It returns how many
+// recommendations are new.
+func (e *Engine) Generate(ctx context.Context, userID string, accounts []models.Account, transactions []models.Transaction) (int, error) {
+	transfers := Plan(accounts, transactions)
+
+	created := 0
+	err := e.db.InTx(ctx, func(ctx context.Context) error {
+		db := e.db.Writer(ctx)
+		planned := make([]string, 0, len(transfers))
+		for _, t := range transfers {
+			planned = append(planned, t.From.ID+":"+t.To.ID)
+
+			var decided bool
+			err := db.QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM recommendations
+					WHERE user_id = $1 AND kind = $2 AND from_account_id = $3 AND to_account_id = $4
+					  AND status IN ('accepted', 'dismissed') AND decided_at > $5)
+			`, userID, KindTransfer, t.From.ID, t.To.ID, time.Now().Add(-decisionCooldown)).Scan(&decided)
+			if err != nil {
+				return fmt.Errorf("failed to query recommendation decisions: %w", err)
+			}
+			if decided {
+				continue
+			}
+
+			rec := recommendation(userID, t)
+			var inserted bool
+			err = db.QueryRow(ctx, `
+				INSERT INTO recommendations (user_id, kind, from_account_id, to_account_id,
+					amount, currency, title, message, metadata)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				ON CONFLICT (user_id, kind, from_account_id, to_account_id) WHERE status = 'pending'
+				DO UPDATE SET amount = EXCLUDED.amount, title = EXCLUDED.title,
+					message = EXCLUDED.message, metadata = EXCLUDED.metadata
+				RETURNING xmax = 0
+			`, rec.UserID, rec.Kind, rec.FromAccountID, rec.ToAccountID, rec.Amount, rec.Currency,
+				rec.Title, rec.Message, rec.Metadata).Scan(&inserted)
+			if err != nil {
+				return fmt.Errorf("failed to store recommendation: %w", err)
+			}
+			if inserted {
+				created++
+			}
+		}
+
+		_, err := db.Exec(ctx, `
+			UPDATE recommendations SET status = 'expired', decided_at = NOW()
+			WHERE user_id = $1 AND kind = $2 AND status = 'pending'
+			  AND NOT (from_account_id || ':' || to_account_id = ANY($3))
+		`, userID, KindTransfer, planned)
+		if err != nil {
+			return fmt.Errorf("failed to expire recommendations: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+const recommendationColumns = `id, user_id, kind, from_account_id, to_account_id, amount, currency,
+	title, message, metadata, status, decided_at, created_at, updated_at`
+
+func scanRecommendation(row pgx.Row) (*models.Recommendation, error) {
+	var r models.Recommendation
+	err := row.Scan(&r.ID, &r.UserID, &r.Kind, &r.FromAccountID, &r.ToAccountID, &r.Amount,
+		&r.Currency, &r.Title, &r.Message, &r.Metadata, &r.Status, &r.DecidedAt,
+		&r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// List returns a user's recommendations in a status, or in any status if
+// status is empty, newest first
+func (e *Engine) List(ctx context.Context, userID, status string, limit, offset int) ([]models.Recommendation, error) {
+	rows, err := e.db.Reader(ctx).Query(ctx, `
+		SELECT `+recommendationColumns+`
+		FROM recommendations
+		WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, userID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Recommendation{}
+	for rows.Next() {
+		r, err := scanRecommendation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recommendation: %w", err)
+		}
+		list = append(list, *r)
+	}
+	return list, rows.Err()
+}
+
+// Decide records that a user accepted or dismissed a pending
+// recommendation. It returns ErrNotFound, or ErrDecided if the
+// recommendation is no longer pending.
+func (e *Engine) Decide(ctx context.Context, userID, recommendationID, status string) (*models.Recommendation, error) {
+	if status != models.RecommendationAccepted && status != models.RecommendationDismissed {
+		return nil, fmt.Errorf("invalid recommendation decision %q", status)
+	}
+
+	r, err := scanRecommendation(e.db.Pool.QueryRow(ctx, `
+		UPDATE recommendations SET status = $3, decided_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = 'pending'
+		RETURNING `+recommendationColumns,
+		recommendationID, userID, status))
+	if err == nil {
+		return r, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to update recommendation: %w", err)
+	}
+
+	var exists bool
+	err = e.db.Pool.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM recommendations WHERE id = $1 AND user_id = $2)",
+		recommendationID, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recommendation: %w", err)
+	}
+	if exists {
+		return nil, ErrDecided
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,170 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Replacement values for redacted fields
+const (
+	MaskedNumber = "****"
+	Redacted     = "[REDACTED]"
+)
+
+// accountNumberFields hold account, routing or card numbers, even partial ones
+var accountNumberFields = map[string]bool{
+	"mask":           true,
+	"account_mask":   true,
+	"account_number": true,
+	"routing_number": true,
+	"wire_routing":   true,
+	"card_number":    true,
+	"iban":           true,
+}
+
+// locationFields pinpoint where a purchase happened or where someone lives
+var locationFields = map[string]bool{
+	"location":             true,
+	"address":              true,
+	"city":                 true,
+	"region":               true,
+	"postal_code":          true,
+	"country":              true,
+	"lat":                  true,
+	"lon":                  true,
+	"latitude":             true,
+	"longitude":            true,
+	"location_address":     true,
+	"location_city":        true,
+	"location_region":      true,
+	"location_postal_code": true,
+	"location_country":     true,
+	"store_number":         true,
+	"ip_address":           true,
+}
+
+// identityFields identify the account holder
+var identityFields = map[string]bool{
+	"official_name":  true,
+	"account_owner":  true,
+	"owner_names":    true,
+	"email":          true,
+	"phone":          true,
+	"phone_number":   true,
+	"date_of_birth":  true,
+	"ssn":            true,
+	"auth_id":        true,
+	"payment_meta":   true,
+	"payee":          true,
+	"payer":          true,
+	"reference":      true,
+	"by_order_of":    true,
+	"ppd_id":         true,
+	"user_agent":     true,
+	"device":         true,
+	"legal_name":     true,
+	"full_name":      true,
+	"mailing_name":   true,
+	"recipient_name": true,
+}
+
+// secretFields hold credentials, which only appear in requests and
+// responses between the service and its owners but must never be kept
+var secretFields = map[string]bool{
+	"access_token":  true,
+	"public_token":  true,
+	"link_token":    true,
+	"refresh_token": true,
+	"token":         true,
+	"api_key":       true,
+	"key":           true,
+	"secret":        true,
+	"client_secret": true,
+	"password":      true,
+	"authorization": true,
+}
+
+// Value returns a copy of a decoded JSON value with sensitive fields masked.
+// Account numbers become MaskedNumber; location and identity strings become
+// Redacted, and non-string values for those fields are nulled.
+func Value(v interface{}) interface{} {
+	return value(v, false)
+}
+
+// Secrets is Value that also redacts credentials, for payloads that are
+// kept rather than returned to their owner
+func Secrets(v interface{}) interface{} {
+	return value(v, true)
+}
+
+func value(v interface{}, secrets bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			out[key] = redactField(key, field, secrets)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = value(item, secrets)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func redactField(key string, v interface{}, secrets bool) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	key = strings.ToLower(key)
+	switch {
+	case secrets && secretFields[key]:
+		return Redacted
+	case accountNumberFields[key]:
+		if _, ok := v.(string); ok {
+			return MaskedNumber
+		}
+		return nil
+	case locationFields[key], identityFields[key]:
+		if _, ok := v.(string); ok {
+			return Redacted
+		}
+		return nil
+	default:
+		return value(v, secrets)
+	}
+}
+
+// JSON redacts a JSON document. Numbers are preserved exactly.
+func JSON(data []byte) ([]byte, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Value(v))
+}
+
+// SecretsJSON redacts a JSON document with Secrets
+func SecretsJSON(data []byte) ([]byte, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Secrets(v))
+}
+
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
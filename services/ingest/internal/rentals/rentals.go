@@ -0,0 +1,363 @@
+// Package rentals tracks the rental properties a user manages through
+// their linked accounts. Transactions are tagged to a property with what
+// they are for, such as rent or repairs, and each property's profit and
+// loss and cash flow is reported from them. Plaid amounts are positive
+// for money out, so rent arrives negative.
+package rentals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of rental transactions. A mortgage payment is tagged with the
+// interest it paid; KindMortgageInterest is for interest charged on its
+// own.
+const (
+	KindRent             = "rent"
+	KindRepairs          = "repairs"
+	KindMortgage         = "mortgage"
+	KindMortgageInterest = "mortgage_interest"
+	KindInsurance        = "insurance"
+	KindTaxes            = "taxes"
+	KindUtilities        = "utilities"
+	KindManagement       = "management"
+	KindHOA              = "hoa"
+	KindOther            = "other"
+)
+
+// ExpenseKinds lists the kinds that are expenses, in report order.
+// Mortgage payments are expenses only for their interest, reported as
+// mortgage_interest.
+var ExpenseKinds = []string{
+	KindRepairs, KindMortgageInterest, KindInsurance, KindTaxes,
+	KindUtilities, KindManagement, KindHOA, KindOther,
+}
+
+// MaxProperties bounds the properties a user may keep
+const MaxProperties = 50
+
+var (
+	// ErrNotFound is returned for a property that does not exist or
+	// belongs to another user
+	ErrNotFound = errors.New("rental property not found")
+	// ErrLimit is returned when the user has MaxProperties properties
+	ErrLimit = errors.New("rental property limit reached")
+)
+
+// IsKind reports whether k is a kind of rental transaction
+func IsKind(k string) bool {
+	if k == KindRent || k == KindMortgage {
+		return true
+	}
+	for _, e := range ExpenseKinds {
+		if k == e {
+			return true
+		}
+	}
+	return false
+}
+
+// pfcKinds map Plaid personal finance categories, detailed then primary,
+// to kinds
+var pfcKinds = map[string]string{
+	"HOME_IMPROVEMENT_REPAIR_AND_MAINTENANCE": KindRepairs,
+	"HOME_IMPROVEMENT":                        KindRepairs,
+	"GENERAL_SERVICES_INSURANCE":              KindInsurance,
+	"GOVERNMENT_AND_NON_PROFIT_TAX_PAYMENT":   KindTaxes,
+	"RENT_AND_UTILITIES_RENT":                 KindOther,
+	"RENT_AND_UTILITIES":                      KindUtilities,
+}
+
+// categoryKinds map legacy Plaid categories to kinds
+var categoryKinds = map[string]string{
+	"Repair Services":  KindRepairs,
+	"Home Improvement": KindRepairs,
+	"Insurance":        KindInsurance,
+	"Tax":              KindTaxes,
+	"Utilities":        KindUtilities,
+	"Real Estate":      KindManagement,
+}
+
+// KindOf guesses what a transaction tagged without a kind is for: money
+// in is rent, and money out is an expense by its category. Mortgage
+// payments are never guessed, needing their interest.
+func KindOf(txn models.Transaction) string {
+	if txn.Amount.IsNegative() {
+		return KindRent
+	}
+	for _, pfc := range []*string{txn.PersonalFinanceCategoryDetailed, txn.PersonalFinanceCategory} {
+		if pfc == nil {
+			continue
+		}
+		if kind, ok := pfcKinds[*pfc]; ok {
+			return kind
+		}
+	}
+	for i := len(txn.Category) - 1; i >= 0; i-- {
+		if kind, ok := categoryKinds[txn.Category[i]]; ok {
+			return kind
+		}
+	}
+	return KindOther
+}
+
+// Service keeps rental properties and their transactions
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a rental property service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// Create adds a property and sets its ID and timestamps
+func (s *Service) Create(ctx context.Context, p *models.RentalProperty) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO rental_properties (user_id, name, address)
+		SELECT $1, $2, $3
+		WHERE (SELECT count(*) FROM rental_properties WHERE user_id = $1) < $4
+		RETURNING id, created_at, updated_at
+	`, p.UserID, p.Name, p.Address, MaxProperties).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLimit
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create rental property: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's properties by name
+func (s *Service) List(ctx context.Context, userID string) ([]models.RentalProperty, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, name, address, created_at, updated_at
+		FROM rental_properties
+		WHERE user_id = $1
+		ORDER BY lower(name), created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rental properties: %w", err)
+	}
+	defer rows.Close()
+
+	properties := []models.RentalProperty{}
+	for rows.Next() {
+		var p models.RentalProperty
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.Address, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rental property: %w", err)
+		}
+		properties = append(properties, p)
+	}
+	return properties, rows.Err()
+}
+
+// Delete deletes one of a user's properties and untags its transactions
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM rental_properties WHERE id::text = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete rental property: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Tag tags a user's transactions to a property as kind, or by KindOf when
+// kind is empty, returning how many were tagged. Transactions tagged to
+// another property move to this one. interest is required for, and only
+// taken by, mortgage payments.
+func (s *Service) Tag(ctx context.Context, userID, propertyID string, transactionIDs []string, kind string, interest *decimal.Decimal) (int, error) {
+	if kind != "" && !IsKind(kind) {
+		return 0, fmt.Errorf("invalid rental transaction kind %q", kind)
+	}
+	if (kind == KindMortgage) != (interest != nil) {
+		return 0, errors.New("interest is required for mortgage payments only")
+	}
+
+	var tagged int
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		var owned bool
+		err := s.db.Writer(ctx).QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM rental_properties WHERE id::text = $1 AND user_id = $2)",
+			propertyID, userID).Scan(&owned)
+		if err != nil {
+			return fmt.Errorf("failed to look up rental property: %w", err)
+		}
+		if !owned {
+			return ErrNotFound
+		}
+
+		rows, err := s.db.Writer(ctx).Query(ctx, `
+			SELECT id, amount, category, personal_finance_category, personal_finance_category_detailed
+			FROM transactions
+			WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL
+		`, userID, transactionIDs)
+		if err != nil {
+			return fmt.Errorf("failed to query transactions: %w", err)
+		}
+		var transactions []models.Transaction
+		for rows.Next() {
+			var txn models.Transaction
+			if err := rows.Scan(&txn.ID, &txn.Amount, &txn.Category, &txn.PersonalFinanceCategory,
+				&txn.PersonalFinanceCategoryDetailed); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan transaction: %w", err)
+			}
+			transactions = append(transactions, txn)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, txn := range transactions {
+			txnKind := kind
+			if txnKind == "" {
+				txnKind = KindOf(txn)
+			}
+			_, err := s.db.Writer(ctx).Exec(ctx, `
+				INSERT INTO rental_transactions (transaction_id, user_id, property_id, kind, interest)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (transaction_id) DO UPDATE
+				SET property_id = EXCLUDED.property_id, kind = EXCLUDED.kind,
+				    interest = EXCLUDED.interest, tagged_at = NOW()
+			`, txn.ID, userID, propertyID, txnKind, interest)
+			if err != nil {
+				return fmt.Errorf("failed to tag transaction: %w", err)
+			}
+		}
+		tagged = len(transactions)
+		return nil
+	})
+	return tagged, err
+}
+
+// Untag removes a user's transactions from whichever property they are
+// tagged to, returning how many were
+func (s *Service) Untag(ctx context.Context, userID string, transactionIDs []string) (int, error) {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM rental_transactions WHERE user_id = $1 AND transaction_id = ANY($2)", userID, transactionIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to untag transactions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Transactions returns a user's tagged transactions dated from start to
+// end, inclusive dates, oldest first
+func (s *Service) Transactions(ctx context.Context, userID, start, end string) ([]models.RentalTransaction, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT t.id, t.account_id, t.date::text, t.amount, t.merchant_name, t.description,
+		       rt.property_id::text, rt.kind, rt.interest
+		FROM rental_transactions rt
+		JOIN transactions t ON t.id = rt.transaction_id
+		WHERE rt.user_id = $1 AND t.deleted_at IS NULL AND t.date BETWEEN $2 AND $3
+		ORDER BY t.date, t.id
+	`, userID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rental transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []models.RentalTransaction{}
+	for rows.Next() {
+		var t models.RentalTransaction
+		if err := rows.Scan(&t.TransactionID, &t.AccountID, &t.Date, &t.Amount, &t.MerchantName,
+			&t.Description, &t.PropertyID, &t.Kind, &t.Interest); err != nil {
+			return nil, fmt.Errorf("failed to scan rental transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+// Report totals each property's transactions from start to end into its
+// profit and loss, keeping the properties' order. Refunds reduce the
+// kind they are tagged as.
+func Report(properties []models.RentalProperty, transactions []models.RentalTransaction, start, end time.Time) models.RentalReport {
+	report := models.RentalReport{
+		Period: models.Period{
+			StartDate: start.Format(period.DateLayout),
+			EndDate:   end.Format(period.DateLayout),
+			Days:      int(end.Sub(start).Hours()/24) + 1,
+		},
+		Properties:    make([]models.PropertyPnL, 0, len(properties)),
+		RentReceived:  decimal.Zero,
+		TotalExpenses: decimal.Zero,
+		NetIncome:     decimal.Zero,
+		NetCashFlow:   decimal.Zero,
+	}
+
+	index := make(map[string]int, len(properties))
+	for _, p := range properties {
+		pnl := models.PropertyPnL{
+			PropertyID:    p.ID,
+			Name:          p.Name,
+			RentReceived:  decimal.Zero,
+			Expenses:      make(map[string]decimal.Decimal, len(ExpenseKinds)),
+			TotalExpenses: decimal.Zero,
+			PrincipalPaid: decimal.Zero,
+			NetCashFlow:   decimal.Zero,
+			Transactions:  []models.RentalTransaction{},
+		}
+		for _, kind := range ExpenseKinds {
+			pnl.Expenses[kind] = decimal.Zero
+		}
+		index[p.ID] = len(report.Properties)
+		report.Properties = append(report.Properties, pnl)
+	}
+
+	for _, t := range transactions {
+		i, ok := index[t.PropertyID]
+		if !ok {
+			continue
+		}
+		pnl := &report.Properties[i]
+		pnl.Transactions = append(pnl.Transactions, t)
+		pnl.NetCashFlow = pnl.NetCashFlow.Sub(t.Amount)
+
+		switch t.Kind {
+		case KindRent:
+			pnl.RentReceived = pnl.RentReceived.Sub(t.Amount)
+		case KindMortgage:
+			interest := decimal.Zero
+			if t.Interest != nil {
+				interest = *t.Interest
+			}
+			pnl.Expenses[KindMortgageInterest] = pnl.Expenses[KindMortgageInterest].Add(interest)
+			pnl.PrincipalPaid = pnl.PrincipalPaid.Add(t.Amount.Sub(interest))
+		default:
+			kind := t.Kind
+			if _, ok := pnl.Expenses[kind]; !ok {
+				kind = KindOther
+			}
+			pnl.Expenses[kind] = pnl.Expenses[kind].Add(t.Amount)
+		}
+	}
+
+	for i := range report.Properties {
+		pnl := &report.Properties[i]
+		for _, amount := range pnl.Expenses {
+			pnl.TotalExpenses = pnl.TotalExpenses.Add(amount)
+		}
+		pnl.NetIncome = pnl.RentReceived.Sub(pnl.TotalExpenses)
+
+		report.RentReceived = report.RentReceived.Add(pnl.RentReceived)
+		report.TotalExpenses = report.TotalExpenses.Add(pnl.TotalExpenses)
+		report.NetIncome = report.NetIncome.Add(pnl.NetIncome)
+		report.NetCashFlow = report.NetCashFlow.Add(pnl.NetCashFlow)
+	}
+	return report
+}
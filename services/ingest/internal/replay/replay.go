@@ -0,0 +1,66 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// clockSkew is tolerated between the sender's clock and ours
+const clockSkew = time.Minute
+
+var (
+	// ErrReplayed is returned when a nonce has already been seen within the window
+	ErrReplayed = errors.New("delivery already received")
+	// ErrExpired is returned when a delivery was sent too long ago
+	ErrExpired = errors.New("delivery outside replay window")
+)
+
+// Guard rejects deliveries that repeat a recently seen nonce or were sent
+// outside the replay window. Nonces are stored in Redis for the length of
+// the window, after which the age check alone rejects replays.
+type Guard struct {
+	redis  *redis.Client
+	window time.Duration
+}
+
+// New creates a new replay guard
+func New(redisClient *redis.Client, window time.Duration) *Guard {
+	return &Guard{redis: redisClient, window: window}
+}
+
+// Window returns how long deliveries are accepted after being sent
+func (g *Guard) Window() time.Duration {
+	return g.window
+}
+
+// Check records nonce under namespace and returns ErrReplayed if it was
+// already recorded or ErrExpired if sentAt, which callers must have
+// authenticated, is outside the window. A zero sentAt is always outside it.
+func (g *Guard) Check(ctx context.Context, namespace, nonce string, sentAt time.Time) error {
+	age := time.Since(sentAt)
+	if sentAt.IsZero() || age > g.window || age < -clockSkew {
+		return ErrExpired
+	}
+
+	ok, err := g.redis.SetNX(ctx, "replay:"+namespace+":"+nonce, time.Now().Unix(), g.window+clockSkew).Result()
+	if err != nil {
+		return fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if !ok {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// Forget removes a recorded nonce so a delivery that failed to process can
+// be retried by the sender
+func (g *Guard) Forget(ctx context.Context, namespace, nonce string) error {
+	if err := g.redis.Del(ctx, "replay:"+namespace+":"+nonce).Err(); err != nil {
+		return fmt.Errorf("failed to forget nonce: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package reports stores the reports users define for questions they ask
+// often, a period expression, transaction filters and a grouping, and
+// totals their transactions when they run, so the same question is
+// answered the same way each time.
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/descriptors"
+	"github.com/finagent/ingest/internal/expenses"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+)
+
+// Groupings
+const (
+	GroupCategory    = "category"
+	GroupMerchant    = "merchant"
+	GroupAccount     = "account"
+	GroupExpenseType = "expense_type"
+	GroupDay         = "day"
+	GroupMonth       = "month"
+)
+
+// Directions
+const (
+	DirectionSpending = "spending"
+	DirectionIncome   = "income"
+)
+
+// MaxDefinitions bounds the report definitions a user may keep
+const MaxDefinitions = 100
+
+const (
+	maxNameLength  = 100
+	maxFilterItems = 50
+)
+
+var groupings = map[string]bool{
+	GroupCategory:    true,
+	GroupMerchant:    true,
+	GroupAccount:     true,
+	GroupExpenseType: true,
+	GroupDay:         true,
+	GroupMonth:       true,
+}
+
+var (
+	// ErrNotFound is returned for a definition that does not exist or
+	// belongs to another user
+	ErrNotFound = errors.New("report definition not found")
+	// ErrConflict is returned when the user has a definition of that name
+	ErrConflict = errors.New("a report definition of that name already exists")
+	// ErrLimit is returned when the user has MaxDefinitions definitions
+	ErrLimit = errors.New("report definition limit reached")
+)
+
+// Validate normalizes a definition and checks it can run on the user's
+// calendar, whose own periods the definition's period may name
+func Validate(d *models.ReportDefinition, cal period.Calendar) error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" || len([]rune(d.Name)) > maxNameLength {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+
+	d.Period = strings.TrimSpace(d.Period)
+	if d.Period == "" {
+		return errors.New("period is required, such as \"last month\" or \"Q2 2024\"")
+	}
+	if _, err := cal.Resolve(d.Period, time.Now()); errors.Is(err, period.ErrUnrecognized) {
+		return fmt.Errorf("unrecognized period %q; try \"last month\" or \"Q2 2024\"", d.Period)
+	} else if err != nil {
+		return err
+	}
+
+	if d.GroupBy != nil && *d.GroupBy == "" {
+		d.GroupBy = nil
+	}
+	if d.GroupBy != nil && !groupings[*d.GroupBy] {
+		return errors.New("group_by must be category, merchant, account, expense_type, day or month")
+	}
+
+	f := &d.Filters
+	f.AccountIDs = trimAll(f.AccountIDs)
+	f.Categories = trimAll(f.Categories)
+	f.Merchants = trimAll(f.Merchants)
+	if len(f.AccountIDs) > maxFilterItems || len(f.Categories) > maxFilterItems || len(f.Merchants) > maxFilterItems {
+		return errors.New("filters may list at most 50 accounts, categories or merchants")
+	}
+	if (f.MinAmount != nil && f.MinAmount.IsNegative()) || (f.MaxAmount != nil && f.MaxAmount.IsNegative()) {
+		return errors.New("min_amount and max_amount must not be negative")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && f.MinAmount.GreaterThan(*f.MaxAmount) {
+		return errors.New("min_amount must not exceed max_amount")
+	}
+	if f.Direction != "" && f.Direction != DirectionSpending && f.Direction != DirectionIncome {
+		return errors.New("direction must be 'spending', 'income' or empty")
+	}
+	if f.ExpenseType != "" && !expenses.IsType(f.ExpenseType) {
+		return errors.New("expense_type must be 'business', 'personal' or empty")
+	}
+	if f.Kind != "" && f.Kind != descriptors.KindCheck && f.Kind != descriptors.KindATM {
+		return errors.New("kind must be 'check', 'atm' or empty")
+	}
+	return nil
+}
+
+// Service stores report definitions
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a report service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// Create stores a validated definition
+func (s *Service) Create(ctx context.Context, d *models.ReportDefinition) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO report_definitions (user_id, name, period, filters, group_by)
+		SELECT $1, $2, $3, $4, $5
+		WHERE (SELECT count(*) FROM report_definitions WHERE user_id = $1) < $6
+		RETURNING id, created_at, updated_at
+	`, d.UserID, d.Name, d.Period, d.Filters, d.GroupBy, MaxDefinitions).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrConflict
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLimit
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create report definition: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's definitions by name
+func (s *Service) List(ctx context.Context, userID string) ([]models.ReportDefinition, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, name, period, filters, group_by, created_at, updated_at
+		FROM report_definitions
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report definitions: %w", err)
+	}
+	defer rows.Close()
+
+	definitions := []models.ReportDefinition{}
+	for rows.Next() {
+		d, err := scanDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, *d)
+	}
+	return definitions, rows.Err()
+}
+
+// Get returns one of a user's definitions
+func (s *Service) Get(ctx context.Context, userID, id string) (*models.ReportDefinition, error) {
+	d, err := scanDefinition(s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT id, user_id, name, period, filters, group_by, created_at, updated_at
+		FROM report_definitions
+		WHERE id::text = $1 AND user_id = $2
+	`, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+// Delete deletes one of a user's definitions
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM report_definitions WHERE id::text = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete report definition: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanDefinition(row pgx.Row) (*models.ReportDefinition, error) {
+	var d models.ReportDefinition
+	err := row.Scan(&d.ID, &d.UserID, &d.Name, &d.Period, &d.Filters, &d.GroupBy, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan report definition: %w", err)
+	}
+	return &d, nil
+}
+
+// Run totals the transactions of rng, the period the definition resolved
+// to, that match its filters, grouped as it asks. Amounts follow Plaid's
+// sign convention: positive amounts are spent and negative ones received.
+func Run(d *models.ReportDefinition, transactions []models.Transaction, rng period.Range) models.ReportResult {
+	start, end := rng.Start.Format(period.DateLayout), rng.End.Format(period.DateLayout)
+	result := models.ReportResult{
+		DefinitionID: d.ID,
+		Name:         d.Name,
+		Label:        rng.Label,
+		Period:       models.Period{StartDate: start, EndDate: end, Days: rng.Days()},
+		Spent:        decimal.Zero,
+		Received:     decimal.Zero,
+	}
+
+	groups := make(map[string]*models.ReportGroup)
+	for _, txn := range transactions {
+		if !matches(&d.Filters, txn) {
+			continue
+		}
+		result.TransactionCount++
+		if txn.Amount.IsPositive() {
+			result.Spent = result.Spent.Add(txn.Amount)
+		} else {
+			result.Received = result.Received.Add(txn.Amount.Neg())
+		}
+
+		if d.GroupBy == nil {
+			continue
+		}
+		key := groupKey(*d.GroupBy, txn)
+		g, ok := groups[key]
+		if !ok {
+			g = &models.ReportGroup{Key: key, Spent: decimal.Zero, Received: decimal.Zero}
+			groups[key] = g
+		}
+		g.TransactionCount++
+		if txn.Amount.IsPositive() {
+			g.Spent = g.Spent.Add(txn.Amount)
+		} else {
+			g.Received = g.Received.Add(txn.Amount.Neg())
+		}
+	}
+
+	if d.GroupBy == nil {
+		return result
+	}
+	result.Groups = make([]models.ReportGroup, 0, len(groups))
+	for _, g := range groups {
+		if result.Spent.IsPositive() {
+			g.Percentage, _ = g.Spent.Div(result.Spent).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+		}
+		result.Groups = append(result.Groups, *g)
+	}
+
+	// Days and months read in order; the rest largest spending first
+	byTime := *d.GroupBy == GroupDay || *d.GroupBy == GroupMonth
+	sort.Slice(result.Groups, func(i, j int) bool {
+		a, b := result.Groups[i], result.Groups[j]
+		if !byTime && !a.Spent.Equal(b.Spent) {
+			return a.Spent.GreaterThan(b.Spent)
+		}
+		return a.Key < b.Key
+	})
+	return result
+}
+
+// matches reports whether txn passes every filter that is set
+func matches(f *models.ReportFilters, txn models.Transaction) bool {
+	if len(f.AccountIDs) > 0 && !contains(f.AccountIDs, txn.AccountID) {
+		return false
+	}
+	if len(f.Categories) > 0 && (len(txn.Category) == 0 || !containsFold(f.Categories, txn.Category[0])) {
+		return false
+	}
+	if len(f.Merchants) > 0 {
+		name := ""
+		if txn.MerchantName != nil {
+			name = strings.ToLower(*txn.MerchantName)
+		}
+		found := false
+		for _, m := range f.Merchants {
+			if name != "" && strings.Contains(name, strings.ToLower(m)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	amount := txn.Amount.Abs()
+	if f.MinAmount != nil && amount.LessThan(*f.MinAmount) {
+		return false
+	}
+	if f.MaxAmount != nil && amount.GreaterThan(*f.MaxAmount) {
+		return false
+	}
+	switch f.Direction {
+	case DirectionSpending:
+		if !txn.Amount.IsPositive() {
+			return false
+		}
+	case DirectionIncome:
+		if txn.Amount.IsPositive() {
+			return false
+		}
+	}
+
+	if f.ExpenseType != "" && (txn.ExpenseType == nil || *txn.ExpenseType != f.ExpenseType) {
+		return false
+	}
+	switch f.Kind {
+	case descriptors.KindCheck:
+		return txn.CheckNumber != nil
+	case descriptors.KindATM:
+		return txn.IsATMWithdrawal
+	}
+	return true
+}
+
+// groupKey is the key txn is grouped under
+func groupKey(groupBy string, txn models.Transaction) string {
+	switch groupBy {
+	case GroupCategory:
+		if len(txn.Category) > 0 {
+			return txn.Category[0]
+		}
+		return "Uncategorized"
+	case GroupMerchant:
+		if txn.MerchantName != nil && *txn.MerchantName != "" {
+			return *txn.MerchantName
+		}
+		if txn.Description != nil && *txn.Description != "" {
+			return *txn.Description
+		}
+		return "Unknown"
+	case GroupAccount:
+		if txn.AccountName != nil && *txn.AccountName != "" {
+			return *txn.AccountName
+		}
+		return txn.AccountID
+	case GroupExpenseType:
+		if txn.ExpenseType != nil {
+			return *txn.ExpenseType
+		}
+		return "unclassified"
+	case GroupDay:
+		return txn.Date.Format(period.DateLayout)
+	case GroupMonth:
+		return txn.Date.Format("2006-01")
+	}
+	return ""
+}
+
+// trimAll trims each entry, dropping those left empty
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+// Package retention archives or deletes records once they pass their
+// retention period, with per-user overrides and dry-run reporting
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/locks"
+)
+
+// Actions a policy takes on expired rows
+const (
+	ActionArchive = "archive" // move to archived_records, then delete
+	ActionDelete  = "delete"
+)
+
+// ErrUnknownPolicy is returned for a policy name that does not exist
+var ErrUnknownPolicy = errors.New("unknown retention policy")
+
+// Policy expires the rows of one table older than RetainDays
+type Policy struct {
+	Name       string `json:"name"`
+	Table      string `json:"table"`
+	Action     string `json:"action"`
+	RetainDays int    `json:"retain_days"` // 0 disables the policy
+
+	ageColumn string // timestamp or date the age is measured from
+	userExpr  string // SQL for the row's user, with the table aliased t
+	where     string // extra condition on which rows may expire
+}
+
+// Options sets the default retention period of each policy in days, where
+// 0 disables it, and how the maintenance worker runs
+type Options struct {
+	TransactionDays           int
+	InvestmentTransactionDays int
+	WebhookDeliveryDays       int
+	JobDays                   int
+	RecordVersionDays         int
+	WebhookLagDays            int
+
+	Interval  time.Duration // time between maintenance runs
+	BatchSize int           // rows expired per statement
+	DryRun    bool          // report what would expire without changing anything
+}
+
+// Result reports what one policy expired, or would have in a dry run
+type Result struct {
+	Policy     string `json:"policy"`
+	Action     string `json:"action"`
+	RetainDays int    `json:"retain_days"`
+	Rows       int64  `json:"rows"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// Service applies the retention policies
+type Service struct {
+	db       *database.Database
+	locks    *locks.Locker
+	opts     Options
+	policies []Policy
+}
+
+// NewService creates a retention service. When locker is set, only one
+// instance runs maintenance at a time.
+func NewService(db *database.Database, locker *locks.Locker, opts Options) *Service {
+	return &Service{
+		db:    db,
+		locks: locker,
+		opts:  opts,
+		policies: []Policy{
+			{
+				Name: "transactions", Table: "transactions", Action: ActionArchive,
+				RetainDays: opts.TransactionDays, ageColumn: "date", userExpr: "t.user_id",
+			},
+			{
+				Name: "investment_transactions", Table: "investment_transactions", Action: ActionArchive,
+				RetainDays: opts.InvestmentTransactionDays, ageColumn: "date", userExpr: "t.user_id",
+			},
+			{
+				Name: "webhook_deliveries", Table: "webhook_deliveries", Action: ActionDelete,
+				RetainDays: opts.WebhookDeliveryDays, ageColumn: "created_at",
+				userExpr: "(SELECT s.user_id FROM webhook_subscriptions s WHERE s.id = t.subscription_id)",
+				where:    "t.status <> 'pending'",
+			},
+			{
+				Name: "jobs", Table: "jobs", Action: ActionDelete,
+				RetainDays: opts.JobDays, ageColumn: "completed_at", userExpr: "t.user_id",
+				where: "t.status IN ('completed', 'failed')",
+			},
+			{
+				Name: "record_versions", Table: "record_versions", Action: ActionDelete,
+				RetainDays: opts.RecordVersionDays, ageColumn: "changed_at", userExpr: "t.user_id",
+			},
+			{
+				// Lag samples belong to no user, so overrides never apply
+				Name: "webhook_lag", Table: "webhook_lag", Action: ActionDelete,
+				RetainDays: opts.WebhookLagDays, ageColumn: "persisted_at", userExpr: "NULL::uuid",
+			},
+		},
+	}
+}
+
+// Policies lists the retention policies with their default periods
+func (s *Service) Policies() []Policy {
+	return s.policies
+}
+
+// HasPolicy reports whether name is a retention policy
+func (s *Service) HasPolicy(name string) bool {
+	for _, p := range s.policies {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expired selects the IDs of a policy's rows past retention. A user's
+// override replaces the default period; an override without a period
+// keeps their rows forever.
+func (p Policy) expired() string {
+	query := fmt.Sprintf(`
+		SELECT t.id FROM %s t
+		LEFT JOIN retention_overrides o ON o.user_id = %s AND o.policy = $1
+		WHERE ((o.user_id IS NULL AND t.%s < NOW() - make_interval(days => $2))
+		    OR (o.retain_days IS NOT NULL AND t.%s < NOW() - make_interval(days => o.retain_days)))
+	`, p.Table, p.userExpr, p.ageColumn, p.ageColumn)
+	if p.where != "" {
+		query += " AND " + p.where
+	}
+	return query
+}
+
+// Report counts the rows each enabled policy would expire now
+func (s *Service) Report(ctx context.Context) ([]Result, error) {
+	results := []Result{}
+	for _, p := range s.policies {
+		if p.RetainDays <= 0 {
+			continue
+		}
+		var count int64
+		err := s.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM ("+p.expired()+") expired",
+			p.Name, p.RetainDays).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count expired %s: %w", p.Name, err)
+		}
+		results = append(results, Result{
+			Policy: p.Name, Action: p.Action, RetainDays: p.RetainDays, Rows: count, DryRun: true,
+		})
+	}
+	return results, nil
+}
+
+// Apply expires rows under every enabled policy in batches. A dry run
+// reports the counts without changing anything.
+func (s *Service) Apply(ctx context.Context, dryRun bool) ([]Result, error) {
+	if dryRun {
+		return s.Report(ctx)
+	}
+
+	results := []Result{}
+	for _, p := range s.policies {
+		if p.RetainDays <= 0 {
+			continue
+		}
+		rows, err := s.expire(ctx, p)
+		results = append(results, Result{
+			Policy: p.Name, Action: p.Action, RetainDays: p.RetainDays, Rows: rows,
+		})
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// expire archives or deletes one policy's expired rows, a batch at a time
+func (s *Service) expire(ctx context.Context, p Policy) (int64, error) {
+	var query string
+	switch p.Action {
+	case ActionArchive:
+		query = fmt.Sprintf(`
+			WITH expired AS (%s LIMIT $3),
+			moved AS (
+				DELETE FROM %s t USING expired e WHERE t.id = e.id
+				RETURNING t.*
+			)
+			INSERT INTO archived_records (table_name, record_id, user_id, data)
+			SELECT $4, moved.id::text, moved.user_id, to_jsonb(moved) FROM moved
+		`, p.expired(), p.Table)
+	default:
+		query = fmt.Sprintf(`
+			WITH expired AS (%s LIMIT $3)
+			DELETE FROM %s t USING expired e WHERE t.id = e.id
+		`, p.expired(), p.Table)
+	}
+
+	var total int64
+	for {
+		args := []interface{}{p.Name, p.RetainDays, s.opts.BatchSize}
+		if p.Action == ActionArchive {
+			args = append(args, p.Table)
+		}
+		tag, err := s.db.Pool.Exec(ctx, query, args...)
+		if err != nil {
+			return total, fmt.Errorf("failed to %s expired %s: %w", p.Action, p.Name, err)
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(s.opts.BatchSize) || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
+
+// SetOverride sets a user's retention period for one policy; nil retainDays
+// keeps their records forever
+func (s *Service) SetOverride(ctx context.Context, userID, policy string, retainDays *int, reason string) error {
+	if !s.HasPolicy(policy) {
+		return ErrUnknownPolicy
+	}
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO retention_overrides (user_id, policy, retain_days, reason)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (user_id, policy)
+		DO UPDATE SET retain_days = EXCLUDED.retain_days, reason = EXCLUDED.reason
+	`, userID, policy, retainDays, reason)
+	if err != nil {
+		return fmt.Errorf("failed to set retention override: %w", err)
+	}
+	return nil
+}
+
+// RemoveOverride returns a user to the default period for one policy
+func (s *Service) RemoveOverride(ctx context.Context, userID, policy string) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM retention_overrides WHERE user_id = $1 AND policy = $2", userID, policy)
+	if err != nil {
+		return fmt.Errorf("failed to remove retention override: %w", err)
+	}
+	return nil
+}
+
+// Run applies the policies every interval until ctx is cancelled. Only one
+// instance runs at a time; the others skip while it holds the lock.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "retention")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock retention run", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	results, err := s.Apply(ctx, s.opts.DryRun)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to apply retention policies", "error", err)
+	}
+	for _, r := range results {
+		if r.Rows == 0 {
+			continue
+		}
+		if r.DryRun {
+			slog.InfoContext(ctx, "Retention dry run", "policy", r.Policy, "action", r.Action, "rows", r.Rows)
+		} else {
+			slog.InfoContext(ctx, "Retention applied", "policy", r.Policy, "action", r.Action, "rows", r.Rows)
+		}
+	}
+}
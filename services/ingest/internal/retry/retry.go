@@ -0,0 +1,129 @@
+// Package retry retries upstream calls that failed transiently, waiting
+// exponentially longer, jittered delays between attempts. A delay the
+// upstream asked for with Retry-After is honoured, and no retry is started
+// that could not be waited for within the context's deadline.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy bounds the retries of one call
+type Policy struct {
+	MaxAttempts int           // attempts per call, including the first
+	BaseDelay   time.Duration // delay before the first retry, doubled for each further one
+	MaxDelay    time.Duration // longest single delay, including one asked for by the upstream
+	Budget      time.Duration // total time for a call whose context has no deadline; 0 for none
+}
+
+// Error marks an upstream error as transient, so the call is worth trying
+// again
+type Error struct {
+	Err   error
+	After time.Duration // delay the upstream asked for, 0 if none
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable marks err as transient, to be retried no sooner than after
+func Retryable(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err, After: after}
+}
+
+// IsRetryable reports whether err was marked transient
+func IsRetryable(err error) bool {
+	var re *Error
+	return errors.As(err, &re)
+}
+
+// Do runs attempt until it succeeds, fails with an error not marked
+// Retryable, or the policy's attempts, delays or the context's deadline
+// rule out another try. The last attempt's error is returned.
+func Do(ctx context.Context, p Policy, attempt func(ctx context.Context) error) error {
+	if _, ok := ctx.Deadline(); !ok && p.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Budget)
+		defer cancel()
+	}
+
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		var re *Error
+		if err == nil || n >= p.MaxAttempts || !errors.As(err, &re) {
+			return err
+		}
+
+		delay := p.backoff(n)
+		if re.After > delay {
+			delay = re.After
+		}
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			// The upstream wants more time than this call will wait
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the delay before retry n: BaseDelay doubled n-1 times and
+// capped at MaxDelay, with equal jitter so retries of calls that failed
+// together spread out
+func (p Policy) backoff(n int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// ParseRetryAfter reads a Retry-After header value, given either in
+// seconds or as an HTTP date. It returns 0 for an empty, invalid or past
+// value.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
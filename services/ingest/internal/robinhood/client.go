@@ -1,8 +1,16 @@
 package robinhood
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/finagent/ingest/internal/breaker"
+	"github.com/finagent/ingest/internal/faultinjection"
+	"github.com/finagent/ingest/internal/retry"
+	"github.com/finagent/ingest/internal/tracing"
+	"github.com/shopspring/decimal"
 )
 
 // Client wraps Robinhood API interactions
@@ -10,75 +18,151 @@ type Client struct {
 	username string
 	password string
 	token    string
+	breaker  *breaker.Breaker
+	retry    retry.Policy
+	faults   *faultinjection.Injector
 }
 
-// NewClient creates a new Robinhood client
-func NewClient(username, password string) *Client {
+// NewClient creates a new Robinhood client whose calls go through a
+// circuit breaker configured by breakerOpts and are retried per
+// retryPolicy
+func NewClient(username, password string, breakerOpts breaker.Options, retryPolicy retry.Policy) *Client {
 	return &Client{
 		username: username,
 		password: password,
+		breaker:  breaker.New("robinhood", breakerOpts),
+		retry:    retryPolicy,
 	}
 }
 
+// SetFaults makes the client's calls fail and slow down as the injector
+// decides, for resilience testing
+func (c *Client) SetFaults(faults *faultinjection.Injector) {
+	c.faults = faults
+}
+
+// Breaker is the circuit breaker guarding the client's API calls
+func (c *Client) Breaker() *breaker.Breaker {
+	return c.breaker
+}
+
 // Authenticate authenticates with Robinhood (mock implementation)
-func (c *Client) Authenticate() error {
+func (c *Client) Authenticate(ctx context.Context) (err error) {
 	if c.username == "" || c.password == "" {
 		return fmt.Errorf("username and password are required")
 	}
 	
-	// Mock authentication
-	c.token = fmt.Sprintf("rh-token-%d", time.Now().Unix())
+	err = c.call(ctx, "authenticate", func(ctx context.Context) error {
+		// Mock authentication
+		c.token = fmt.Sprintf("rh-token-%d", time.Now().Unix())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 // GetCryptoPositions retrieves crypto positions (mock implementation)
-func (c *Client) GetCryptoPositions() ([]map[string]interface{}, error) {
-	// Mock crypto positions
-	positions := []map[string]interface{}{
-		{
-			"symbol":                     "BTC",
-			"name":                       "Bitcoin",
-			"quantity":                   "0.05000000",
-			"average_price":              "45000.00",
-			"market_value":               "2250.00",
-			"cost_basis":                 "2000.00",
-			"unrealized_pnl":             "250.00",
-			"last_price":                 "45000.00",
-			"price_change_24h":           "1250.00",
-			"price_change_percent_24h":   "2.85",
-		},
-		{
-			"symbol":                     "ETH",
-			"name":                       "Ethereum",
-			"quantity":                   "2.50000000",
-			"average_price":              "3200.00",
-			"market_value":               "8000.00",
-			"cost_basis":                 "7500.00",
-			"unrealized_pnl":             "500.00",
-			"last_price":                 "3200.00",
-			"price_change_24h":           "-50.00",
-			"price_change_percent_24h":   "-1.54",
-		},
-		{
-			"symbol":                     "DOGE",
-			"name":                       "Dogecoin",
-			"quantity":                   "1000.00000000",
-			"average_price":              "0.08",
-			"market_value":               "80.00",
-			"cost_basis":                 "100.00",
-			"unrealized_pnl":             "-20.00",
-			"last_price":                 "0.08",
-			"price_change_24h":           "0.001",
-			"price_change_percent_24h":   "1.25",
-		},
+func (c *Client) GetCryptoPositions(ctx context.Context) (positions []map[string]interface{}, err error) {
+	err = c.call(ctx, "positions", func(ctx context.Context) error {
+		// Mock crypto positions
+		positions = []map[string]interface{}{
+			{
+				"symbol":                     "BTC",
+				"name":                       "Bitcoin",
+				"quantity":                   "0.05000000",
+				"average_price":              "45000.00",
+				"market_value":               "2250.00",
+				"cost_basis":                 "2000.00",
+				"unrealized_pnl":             "250.00",
+				"last_price":                 "45000.00",
+				"price_change_24h":           "1250.00",
+				"price_change_percent_24h":   "2.85",
+			},
+			{
+				"symbol":                     "ETH",
+				"name":                       "Ethereum",
+				"quantity":                   "2.50000000",
+				"average_price":              "3200.00",
+				"market_value":               "8000.00",
+				"cost_basis":                 "7500.00",
+				"unrealized_pnl":             "500.00",
+				"last_price":                 "3200.00",
+				"price_change_24h":           "-50.00",
+				"price_change_percent_24h":   "-1.54",
+			},
+			{
+				"symbol":                     "DOGE",
+				"name":                       "Dogecoin",
+				"quantity":                   "1000.00000000",
+				"average_price":              "0.08",
+				"market_value":               "80.00",
+				"cost_basis":                 "100.00",
+				"unrealized_pnl":             "-20.00",
+				"last_price":                 "0.08",
+				"price_change_24h":           "0.001",
+				"price_change_percent_24h":   "1.25",
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
 	return positions, nil
 }
 
+// GetCryptoRewards retrieves staking rewards and interest paid since the
+// given time, each with its USD price when received (mock implementation)
+func (c *Client) GetCryptoRewards(ctx context.Context, since time.Time) (rewards []map[string]interface{}, err error) {
+	err = c.call(ctx, "rewards", func(ctx context.Context) error {
+		// Mock rewards paid at the end of each of the last three months
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 3; i++ {
+			paid := month.AddDate(0, -i, 0).Add(-12 * time.Hour)
+			if !paid.After(since) {
+				continue
+			}
+			rewards = append(rewards,
+				map[string]interface{}{
+					"id":          fmt.Sprintf("rwd-eth-%s", paid.Format("200601")),
+					"symbol":      "ETH",
+					"type":        "staking",
+					"quantity":    "0.00520000",
+					"price":       "3200.00",
+					"received_at": paid.Format(time.RFC3339),
+				},
+				map[string]interface{}{
+					"id":          fmt.Sprintf("rwd-sol-%s", paid.Format("200601")),
+					"symbol":      "SOL",
+					"type":        "staking",
+					"quantity":    "0.06100000",
+					"price":       "95.00",
+					"received_at": paid.Format(time.RFC3339),
+				},
+				map[string]interface{}{
+					"id":          fmt.Sprintf("int-btc-%s", paid.Format("200601")),
+					"symbol":      "BTC",
+					"type":        "interest",
+					"quantity":    "0.00004100",
+					"price":       "45000.00",
+					"received_at": paid.Format(time.RFC3339),
+				},
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rewards, nil
+}
+
 // PlaceOrder places a crypto order (mock implementation)
-func (c *Client) PlaceOrder(symbol, side string, quantity float64, price *float64) (string, error) {
-	if symbol == "" || side == "" || quantity <= 0 {
+func (c *Client) PlaceOrder(ctx context.Context, symbol, side string, quantity decimal.Decimal, price *decimal.Decimal) (orderID string, err error) {
+	if symbol == "" || side == "" || !quantity.IsPositive() {
 		return "", fmt.Errorf("invalid order parameters")
 	}
 	
@@ -87,41 +171,79 @@ func (c *Client) PlaceOrder(symbol, side string, quantity float64, price *float6
 	}
 	
 	// Validate quantity limits
-	if quantity > 1000000 {
+	if quantity.GreaterThan(decimal.NewFromInt(1000000)) {
 		return "", fmt.Errorf("quantity exceeds maximum allowed")
 	}
 	
-	// Mock order placement
-	orderID := fmt.Sprintf("rh-order-%s-%s-%d", symbol, side, time.Now().Unix())
+	// Orders are not idempotent: only a rejection the API made before
+	// accepting the order, such as a rate limit, may be marked retryable
+	err = c.call(ctx, "place_order", func(ctx context.Context) error {
+		// Mock order placement
+		orderID = fmt.Sprintf("rh-order-%s-%s-%d", symbol, side, time.Now().Unix())
 	
-	// Simulate potential errors
-	if symbol == "FAIL" {
-		return "", fmt.Errorf("simulated order failure")
+		// Simulate potential errors
+		if symbol == "FAIL" {
+			return fmt.Errorf("simulated order failure")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-	
 	return orderID, nil
 }
 
 // GetOrderStatus gets the status of an order (mock implementation)
-func (c *Client) GetOrderStatus(orderID string) (map[string]interface{}, error) {
+func (c *Client) GetOrderStatus(ctx context.Context, orderID string) (status map[string]interface{}, err error) {
 	if orderID == "" {
 		return nil, fmt.Errorf("order ID is required")
 	}
 	
-	// Mock order status
-	status := map[string]interface{}{
-		"id":                orderID,
-		"status":            "filled",
-		"filled_quantity":   "0.01000000",
-		"average_fill_price": "45000.00",
-		"fees":              "0.50",
-		"created_at":        time.Now().Add(-5*time.Minute).Format(time.RFC3339),
-		"filled_at":         time.Now().Add(-2*time.Minute).Format(time.RFC3339),
+	err = c.call(ctx, "order_status", func(ctx context.Context) error {
+		// Mock order status
+		status = map[string]interface{}{
+			"id":                orderID,
+			"status":            "filled",
+			"filled_quantity":   "0.01000000",
+			"average_fill_price": "45000.00",
+			"fees":              "0.50",
+			"created_at":        time.Now().Add(-5*time.Minute).Format(time.RFC3339),
+			"filled_at":         time.Now().Add(-2*time.Minute).Format(time.RFC3339),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	
 	return status, nil
 }
 
+// call runs one Robinhood API operation under the client's retry policy.
+// Each attempt goes through the breaker and gets its own client span; only
+// errors the operation marks retry.Retryable are retried.
+func (c *Client) call(ctx context.Context, operation string, attempt func(ctx context.Context) error) error {
+	return retry.Do(ctx, c.retry, func(ctx context.Context) error {
+		done, err := c.breaker.Allow()
+		if err != nil {
+			return err
+		}
+		ctx, span := tracing.StartClientSpan(ctx, "robinhood "+operation)
+		// Injected failures happen before the request is sent, so even
+		// orders can safely retry them
+		err = c.faults.Upstream(ctx, "robinhood")
+		if errors.Is(err, faultinjection.ErrInjected) {
+			err = retry.Retryable(err, 0)
+		}
+		if err == nil {
+			err = attempt(ctx)
+		}
+		tracing.SetSpanError(span, err)
+		span.End()
+		done(err)
+		return err
+	})
+}
+
 // GetSupportedCrypto returns list of supported crypto symbols
 func (c *Client) GetSupportedCrypto() []string {
 	return []string{
@@ -143,40 +265,46 @@ func (c *Client) ValidateSymbol(symbol string) bool {
 }
 
 // GetMarketPrice gets current market price for a symbol (mock implementation)
-func (c *Client) GetMarketPrice(symbol string) (float64, error) {
+func (c *Client) GetMarketPrice(ctx context.Context, symbol string) (price decimal.Decimal, err error) {
 	if !c.ValidateSymbol(symbol) {
-		return 0, fmt.Errorf("unsupported symbol: %s", symbol)
+		return decimal.Zero, fmt.Errorf("unsupported symbol: %s", symbol)
 	}
 	
-	// Mock prices
-	prices := map[string]float64{
-		"BTC":   45000.00,
-		"ETH":   3200.00,
-		"DOGE":  0.08,
-		"LTC":   150.00,
-		"BCH":   400.00,
-		"ETC":   25.00,
-		"BSV":   50.00,
-		"ADA":   0.45,
-		"XRP":   0.60,
-		"SOL":   95.00,
-		"MATIC": 1.20,
-		"AVAX":  35.00,
-		"DOT":   7.50,
-		"LINK":  15.00,
-		"UNI":   8.50,
-		"ALGO":  0.25,
-		"ATOM":  12.00,
-		"XLM":   0.12,
-		"COMP":  65.00,
-		"AAVE":  85.00,
-	}
+	err = c.call(ctx, "market_price", func(ctx context.Context) error {
+		// Mock prices
+		prices := map[string]decimal.Decimal{
+			"BTC":   decimal.RequireFromString("45000.00"),
+			"ETH":   decimal.RequireFromString("3200.00"),
+			"DOGE":  decimal.RequireFromString("0.08"),
+			"LTC":   decimal.RequireFromString("150.00"),
+			"BCH":   decimal.RequireFromString("400.00"),
+			"ETC":   decimal.RequireFromString("25.00"),
+			"BSV":   decimal.RequireFromString("50.00"),
+			"ADA":   decimal.RequireFromString("0.45"),
+			"XRP":   decimal.RequireFromString("0.60"),
+			"SOL":   decimal.RequireFromString("95.00"),
+			"MATIC": decimal.RequireFromString("1.20"),
+			"AVAX":  decimal.RequireFromString("35.00"),
+			"DOT":   decimal.RequireFromString("7.50"),
+			"LINK":  decimal.RequireFromString("15.00"),
+			"UNI":   decimal.RequireFromString("8.50"),
+			"ALGO":  decimal.RequireFromString("0.25"),
+			"ATOM":  decimal.RequireFromString("12.00"),
+			"XLM":   decimal.RequireFromString("0.12"),
+			"COMP":  decimal.RequireFromString("65.00"),
+			"AAVE":  decimal.RequireFromString("85.00"),
+		}
 	
-	if price, exists := prices[symbol]; exists {
-		// Add some randomness to simulate price movement
-		variation := float64(time.Now().Unix()%100-50) / 1000 * price
-		return price + variation, nil
+		price = decimal.NewFromInt(1) // Default price for unknown symbols
+		if quote, exists := prices[symbol]; exists {
+			// Add some randomness to simulate price movement
+			variation := quote.Mul(decimal.New(time.Now().Unix()%100-50, -3))
+			price = quote.Add(variation)
+		}
+		return nil
+	})
+	if err != nil {
+		return decimal.Zero, err
 	}
-	
-	return 1.00, nil // Default price for unknown symbols
+	return price, nil
 }
\ No newline at end of file
@@ -0,0 +1,182 @@
+// Package roundups works out what rounding each purchase up to the next
+// dollar would have saved, and accumulates it against the savings goal a
+// user has chosen. It is analytical only: no money moves.
+package roundups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNotFound is returned for a goal that does not exist or belongs to
+// another user, or when a user has no current goal
+var ErrNotFound = errors.New("round-up goal not found")
+
+// roundUpPurchases selects the purchases t whose round-ups count towards
+// a goal g: posted, fractional charges on the user's counted bank and
+// card accounts while the goal was current. Transfers, payments and fees
+// are not purchases, and duplicates and refunded charges are left out.
+const roundUpPurchases = `
+	FROM transactions t
+	JOIN accounts a ON a.id = t.account_id
+	WHERE t.user_id = g.user_id
+	  AND t.date >= g.started_on AND (g.ended_on IS NULL OR t.date < g.ended_on)
+	  AND t.deleted_at IS NULL AND t.is_pending = false
+	  AND t.amount > 0 AND t.amount <> ceil(t.amount)
+	  AND COALESCE(t.category[1], '') NOT IN ('Transfer', 'Payment', 'Bank Fees')
+	  AND a.type IN ('depository', 'credit') AND a.hidden = false AND a.exclude_from_analytics = false
+	  AND NOT EXISTS (
+	      SELECT 1 FROM duplicate_links dl
+	      WHERE dl.record_type = 'transaction' AND dl.duplicate_id = t.id AND dl.status <> 'rejected')
+	  AND NOT EXISTS (
+	      SELECT 1 FROM refund_links rl
+	      WHERE (rl.charge_id = t.id OR rl.refund_id = t.id) AND rl.status <> 'rejected')`
+
+// goalColumns selects goals g with their round-up total and count
+const goalColumns = `
+	SELECT g.id, g.user_id, g.name, g.target_amount, g.started_on, g.ended_on, g.created_at, g.updated_at,
+	       COALESCE(s.total, 0), s.count
+	FROM roundup_goals g
+	CROSS JOIN LATERAL (
+	    SELECT SUM(ceil(t.amount) - t.amount) AS total, COUNT(*) AS count` + roundUpPurchases + `
+	) s`
+
+// Service tracks round-up goals
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a round-up service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// CreateGoal makes goal the user's current goal from today, ending the
+// previous one
+func (s *Service) CreateGoal(ctx context.Context, goal *models.RoundUpGoal, today time.Time) error {
+	goal.StartedOn = today
+	goal.Accumulated = decimal.Zero
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		_, err := s.db.Writer(ctx).Exec(ctx, `
+			UPDATE roundup_goals SET ended_on = GREATEST(started_on, $2::date)
+			WHERE user_id = $1 AND ended_on IS NULL
+		`, goal.UserID, today)
+		if err != nil {
+			return fmt.Errorf("failed to end current round-up goal: %w", err)
+		}
+
+		err = s.db.Writer(ctx).QueryRow(ctx, `
+			INSERT INTO roundup_goals (user_id, name, target_amount, started_on)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at
+		`, goal.UserID, goal.Name, goal.TargetAmount, today).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create round-up goal: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListGoals returns a user's goals, current first, with what they have
+// accumulated
+func (s *Service) ListGoals(ctx context.Context, userID string) ([]models.RoundUpGoal, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, goalColumns+`
+		WHERE g.user_id = $1
+		ORDER BY g.ended_on DESC NULLS FIRST, g.started_on DESC, g.id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query round-up goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []models.RoundUpGoal{}
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *goal)
+	}
+	return goals, rows.Err()
+}
+
+// GetGoal returns one of a user's goals, or with an empty goalID their
+// current one
+func (s *Service) GetGoal(ctx context.Context, userID, goalID string) (*models.RoundUpGoal, error) {
+	goal, err := scanGoal(s.db.Reader(ctx).QueryRow(ctx, goalColumns+`
+		WHERE g.user_id = $1 AND (($2 = '' AND g.ended_on IS NULL) OR g.id::text = $2)
+	`, userID, goalID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return goal, err
+}
+
+// DeleteGoal deletes one of a user's goals. Round-ups made while it was
+// current count towards no goal.
+func (s *Service) DeleteGoal(ctx context.Context, userID, goalID string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM roundup_goals WHERE id::text = $1 AND user_id = $2", goalID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete round-up goal: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Ledger returns a page of the round-ups counting towards a goal, newest
+// first
+func (s *Service) Ledger(ctx context.Context, goal *models.RoundUpGoal, limit, offset int) ([]models.RoundUp, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT r.id, r.date, r.merchant_name, r.description, r.amount, r.round_up
+		FROM roundup_goals g
+		CROSS JOIN LATERAL (
+		    SELECT t.id, t.date, t.merchant_name, t.description, t.amount,
+		           ceil(t.amount) - t.amount AS round_up`+roundUpPurchases+`
+		) r
+		WHERE g.id = $1
+		ORDER BY r.date DESC, r.id
+		LIMIT $2 OFFSET $3
+	`, goal.ID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query round-ups: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.RoundUp{}
+	for rows.Next() {
+		var e models.RoundUp
+		err := rows.Scan(&e.TransactionID, &e.Date, &e.MerchantName, &e.Description, &e.Amount, &e.RoundUp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan round-up: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func scanGoal(row pgx.Row) (*models.RoundUpGoal, error) {
+	var g models.RoundUpGoal
+	err := row.Scan(&g.ID, &g.UserID, &g.Name, &g.TargetAmount, &g.StartedOn, &g.EndedOn,
+		&g.CreatedAt, &g.UpdatedAt, &g.Accumulated, &g.RoundUpCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan round-up goal: %w", err)
+	}
+	g.Completed = g.Accumulated.GreaterThanOrEqual(g.TargetAmount)
+	if g.TargetAmount.IsPositive() {
+		g.ProgressPercent, _ = g.Accumulated.Div(g.TargetAmount).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+	}
+	return &g, nil
+}
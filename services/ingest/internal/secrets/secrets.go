@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Providers supported by NewSource
+const (
+	ProviderVault = "vault"
+	ProviderSSM   = "ssm"
+)
+
+// Source fetches the current set of secrets, keyed by the environment
+// variable name they replace (e.g. PLAID_SECRET)
+type Source interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// Options selects and configures the secrets provider
+type Options struct {
+	Provider   string
+	VaultAddr  string
+	VaultToken string
+	VaultPath  string // KV v2 API path, e.g. secret/data/finagent/ingest
+	SSMPath    string // parameter path prefix, e.g. /finagent/ingest/
+	AWSRegion  string
+}
+
+// NewSource creates the configured secrets source. It returns nil when no
+// provider is set and secrets come from the environment only.
+func NewSource(ctx context.Context, opts Options) (Source, error) {
+	switch opts.Provider {
+	case "":
+		return nil, nil
+	case ProviderVault:
+		if opts.VaultAddr == "" || opts.VaultToken == "" || opts.VaultPath == "" {
+			return nil, fmt.Errorf("VAULT_ADDR, VAULT_TOKEN and SECRETS_VAULT_PATH are required for vault secrets")
+		}
+		return NewVaultKV(opts.VaultAddr, opts.VaultToken, opts.VaultPath), nil
+	case ProviderSSM:
+		if opts.SSMPath == "" {
+			return nil, fmt.Errorf("SECRETS_SSM_PATH is required for ssm secrets")
+		}
+		return NewSSM(ctx, opts.SSMPath, opts.AWSRegion)
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", opts.Provider)
+	}
+}
+
+// Store holds the latest secrets from a source and notifies subscribers
+// when a refresh finds rotated values
+type Store struct {
+	source Source
+
+	mu          sync.RWMutex
+	values      map[string]string
+	subscribers []func(changed map[string]string)
+}
+
+// Load fetches secrets from the configured provider. It returns a nil store
+// when no provider is set.
+func Load(ctx context.Context, opts Options) (*Store, error) {
+	source, err := NewSource(ctx, opts)
+	if err != nil || source == nil {
+		return nil, err
+	}
+
+	values, err := source.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{source: source, values: values}, nil
+}
+
+// Get returns a secret and whether the provider supplied it
+func (s *Store) Get(key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Values returns a copy of all secrets
+func (s *Store) Values() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make(map[string]string, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// OnChange registers fn to be called with the secrets whose values changed
+// after each refresh
+func (s *Store) OnChange(fn func(changed map[string]string)) {
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, fn)
+	s.mu.Unlock()
+}
+
+// Refresh re-fetches secrets and notifies subscribers of any changes
+func (s *Store) Refresh(ctx context.Context) error {
+	values, err := s.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	changed := make(map[string]string)
+	for key, value := range values {
+		if old, ok := s.values[key]; !ok || old != value {
+			changed[key] = value
+		}
+	}
+	s.values = values
+	subscribers := append([]func(map[string]string){}, s.subscribers...)
+	s.mu.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	for _, fn := range subscribers {
+		fn(changed)
+	}
+	return nil
+}
+
+// Watch refreshes secrets every interval until ctx is cancelled
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to refresh secrets", "error", err)
+			}
+		}
+	}
+}
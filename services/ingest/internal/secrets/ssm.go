@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// SSM reads secrets from AWS Systems Manager Parameter Store. Each parameter
+// under the path is one secret named by the last path element.
+type SSM struct {
+	client *ssm.Client
+	path   string
+}
+
+// NewSSM creates a Parameter Store secrets source using the default
+// credential chain
+func NewSSM(ctx context.Context, paramPath, region string) (*SSM, error) {
+	var optFns []func(*config.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &SSM{
+		client: ssm.NewFromConfig(cfg),
+		path:   paramPath,
+	}, nil
+}
+
+// Fetch reads every parameter under the path, decrypting SecureStrings
+func (s *SSM) Fetch(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+
+	paginator := ssm.NewGetParametersByPathPaginator(s.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(s.path),
+		Recursive:      aws.Bool(false),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSM parameters: %w", err)
+		}
+		for _, p := range page.Parameters {
+			values[path.Base(aws.ToString(p.Name))] = aws.ToString(p.Value)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultKV reads secrets from a HashiCorp Vault KV version 2 secret
+type VaultKV struct {
+	addr       string
+	token      string
+	path       string
+	httpClient *http.Client
+}
+
+// NewVaultKV creates a Vault KV secrets source
+func NewVaultKV(addr, token, path string) *VaultKV {
+	return &VaultKV{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		path:       strings.Trim(path, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch reads the latest version of the secret
+func (v *VaultKV) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+v.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode vault secret: %w", err)
+	}
+
+	values := make(map[string]string, len(out.Data.Data))
+	for key, value := range out.Data.Data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,273 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/finagent/ingest/internal/audit"
+	"github.com/finagent/ingest/internal/auth"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/ratelimit"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lock reasons, also used as insight types
+const (
+	ReasonOrderBurst  = "security.order_burst"
+	ReasonNewIPExport = "security.new_ip_export"
+)
+
+// An IP address becomes established once it has been in use for
+// establishedAfter, and is forgotten knownIPsTTL after the user's last request
+const (
+	establishedAfter = 24 * time.Hour
+	knownIPsTTL      = 90 * 24 * time.Hour
+)
+
+const reverifyHint = "If this was you, sign in again and unlock trading from your security settings."
+
+// ErrNotLocked is returned when unlocking a user whose trading is not locked
+var ErrNotLocked = errors.New("trading is not locked")
+
+// Options sets the detection thresholds
+type Options struct {
+	OrderBurst        int // live orders within OrderWindow that lock trading
+	OrderWindow       time.Duration
+	AuthFailures      int // failed authentications within AuthFailureWindow that block an IP
+	AuthFailureWindow time.Duration
+	IPLockout         time.Duration // how long an IP stays blocked
+	ReverifyWindow    time.Duration // how recent a sign-in must be to unlock trading
+}
+
+// Lock describes an active trading lock
+type Lock struct {
+	Reason    string    `json:"reason"`
+	InsightID *string   `json:"insight_id,omitempty"`
+	LockedAt  time.Time `json:"locked_at"`
+}
+
+// Detector spots suspicious API usage using Redis counters, locks trading
+// for affected users and raises an insight asking them to re-verify
+type Detector struct {
+	db       *database.Database
+	redis    *redis.Client
+	limiter  *ratelimit.Limiter
+	insights *insights.Store
+	audit    *audit.Logger
+	opts     Options
+}
+
+// NewDetector creates a new anomaly detector
+func NewDetector(db *database.Database, redisClient *redis.Client, limiter *ratelimit.Limiter, insightStore *insights.Store, auditLog *audit.Logger, opts Options) *Detector {
+	return &Detector{
+		db:       db,
+		redis:    redisClient,
+		limiter:  limiter,
+		insights: insightStore,
+		audit:    auditLog,
+		opts:     opts,
+	}
+}
+
+// RecordOrder counts a live order and locks trading on a burst, reporting
+// whether it did
+func (d *Detector) RecordOrder(ctx context.Context, userID string) (bool, error) {
+	result, err := d.limiter.Allow(ctx, "anomaly:orders:"+userID,
+		ratelimit.Limit{Requests: d.opts.OrderBurst, Window: d.opts.OrderWindow})
+	if err != nil {
+		return false, err
+	}
+	if result.Allowed {
+		return false, nil
+	}
+
+	return true, d.LockTrading(ctx, userID, ReasonOrderBurst,
+		fmt.Sprintf("More than %d orders were placed within %s.", d.opts.OrderBurst, d.opts.OrderWindow),
+		map[string]interface{}{"orders": d.opts.OrderBurst, "window": d.opts.OrderWindow.String()})
+}
+
+// CheckExport locks trading when a data export is requested from an IP
+// first used within the last day by a user with established IPs, reporting
+// whether it did
+func (d *Detector) CheckExport(ctx context.Context, userID, ip string) (bool, error) {
+	known, err := d.redis.HGetAll(ctx, knownIPsKey(userID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check known IPs: %w", err)
+	}
+
+	established := func(firstSeen string) bool {
+		ts, err := strconv.ParseInt(firstSeen, 10, 64)
+		return err == nil && time.Since(time.Unix(ts, 0)) >= establishedAfter
+	}
+	if firstSeen, ok := known[ip]; ok && established(firstSeen) {
+		return false, nil
+	}
+
+	// New users have nothing to compare against yet
+	hasEstablished := false
+	for other, firstSeen := range known {
+		if other != ip && established(firstSeen) {
+			hasEstablished = true
+			break
+		}
+	}
+	if !hasEstablished {
+		return false, nil
+	}
+
+	return true, d.LockTrading(ctx, userID, ReasonNewIPExport,
+		fmt.Sprintf("A full data export was requested from a new IP address (%s).", ip),
+		map[string]interface{}{"ip": ip})
+}
+
+// ObserveIP remembers when the user first used an IP address
+func (d *Detector) ObserveIP(ctx context.Context, userID, ip string) error {
+	key := knownIPsKey(userID)
+	pipe := d.redis.Pipeline()
+	pipe.HSetNX(ctx, key, ip, time.Now().Unix())
+	pipe.Expire(ctx, key, knownIPsTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TrustIP marks an IP address as established, e.g. after the user re-verified from it
+func (d *Detector) TrustIP(ctx context.Context, userID, ip string) error {
+	key := knownIPsKey(userID)
+	pipe := d.redis.Pipeline()
+	pipe.HSet(ctx, key, ip, 0)
+	pipe.Expire(ctx, key, knownIPsTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecordAuthFailure counts a failed authentication and blocks the IP once
+// the threshold is reached
+func (d *Detector) RecordAuthFailure(ctx context.Context, ip string) error {
+	result, err := d.limiter.Allow(ctx, "anomaly:authfail:"+ip,
+		ratelimit.Limit{Requests: d.opts.AuthFailures, Window: d.opts.AuthFailureWindow})
+	if err != nil {
+		return err
+	}
+	if result.Allowed {
+		return nil
+	}
+
+	slog.WarnContext(ctx, "Blocking IP after repeated authentication failures", "ip", ip, "lockout", d.opts.IPLockout)
+	return d.redis.Set(ctx, blockedIPKey(ip), 1, d.opts.IPLockout).Err()
+}
+
+// IPBlocked reports whether an IP is blocked after repeated auth failures
+func (d *Detector) IPBlocked(ctx context.Context, ip string) (bool, error) {
+	n, err := d.redis.Exists(ctx, blockedIPKey(ip)).Result()
+	return n > 0, err
+}
+
+// TradingLock returns the user's active trading lock, or nil if unlocked
+func (d *Detector) TradingLock(ctx context.Context, userID string) (*Lock, error) {
+	var lock Lock
+	err := d.db.Pool.QueryRow(ctx, `
+		SELECT reason, insight_id, locked_at FROM trading_locks
+		WHERE user_id = $1 AND unlocked_at IS NULL
+	`, userID).Scan(&lock.Reason, &lock.InsightID, &lock.LockedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trading lock: %w", err)
+	}
+	return &lock, nil
+}
+
+// LockTrading locks a user's trading endpoints and creates a critical
+// insight explaining why. Already locked users are left as they are.
+func (d *Detector) LockTrading(ctx context.Context, userID, reason, detail string, metadata map[string]interface{}) error {
+	existing, err := d.TradingLock(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	insight := &models.Insight{
+		UserID:   userID,
+		Type:     reason,
+		Severity: insights.SeverityCritical,
+		Title:    "Trading locked after suspicious activity",
+		Message:  detail + " " + reverifyHint,
+		Metadata: metadata,
+	}
+	if err := d.insights.Create(ctx, insight); err != nil {
+		return err
+	}
+
+	_, err = d.db.Pool.Exec(ctx, `
+		INSERT INTO trading_locks (user_id, reason, insight_id, locked_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET reason = EXCLUDED.reason, insight_id = EXCLUDED.insight_id,
+		    locked_at = EXCLUDED.locked_at, unlocked_at = NULL, unlocked_by = NULL
+	`, userID, reason, insight.ID)
+	if err != nil {
+		return fmt.Errorf("failed to lock trading: %w", err)
+	}
+
+	return d.audit.RecordAs(ctx, audit.ActorSystem, audit.Entry{
+		Action:       audit.ActionTradingLocked,
+		TargetUserID: userID,
+		Metadata:     map[string]interface{}{"reason": reason, "insight_id": insight.ID},
+	})
+}
+
+// UnlockTrading lifts a trading lock and resolves its insight
+func (d *Detector) UnlockTrading(ctx context.Context, userID, unlockedBy string) error {
+	var insightID *string
+	err := d.db.Pool.QueryRow(ctx, `
+		UPDATE trading_locks SET unlocked_at = NOW(), unlocked_by = $2
+		WHERE user_id = $1 AND unlocked_at IS NULL
+		RETURNING insight_id
+	`, userID, unlockedBy).Scan(&insightID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotLocked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlock trading: %w", err)
+	}
+
+	if insightID != nil {
+		if err := d.insights.Resolve(ctx, *insightID); err != nil {
+			return err
+		}
+	}
+
+	return d.audit.Record(ctx, audit.Entry{
+		Action:       audit.ActionTradingUnlocked,
+		TargetUserID: userID,
+	})
+}
+
+// RecentlyVerified reports whether the principal signed in within the
+// re-verification window, by its token's auth_time claim. iat is not used,
+// as refreshing a token renews it without the user signing in again.
+func (d *Detector) RecentlyVerified(p *auth.Principal) bool {
+	ts, ok := p.Claims["auth_time"].(float64)
+	if !ok {
+		return false
+	}
+	return time.Since(time.Unix(int64(ts), 0)) <= d.opts.ReverifyWindow
+}
+
+func knownIPsKey(userID string) string {
+	return "security:known_ips:" + userID
+}
+
+func blockedIPKey(ip string) string {
+	return "security:blocked_ip:" + ip
+}
@@ -0,0 +1,196 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5"
+)
+
+// Redis caches which sessions were seen recently and which were revoked, so
+// most requests are checked without touching Postgres
+const (
+	touchInterval = time.Minute
+	revokedTTL    = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrRevoked is returned when a request uses a revoked session
+	ErrRevoked = errors.New("session revoked")
+	// ErrNotFound is returned when a session does not exist for the user
+	ErrNotFound = errors.New("session not found")
+)
+
+// Store records user sessions and enforces revocation
+type Store struct {
+	db    *database.Database
+	redis *redis.Client
+}
+
+// NewStore creates a new session store
+func NewStore(db *database.Database, redisClient *redis.Client) *Store {
+	return &Store{db: db, redis: redisClient}
+}
+
+// Touch records activity on a session, creating it on first use, and
+// returns ErrRevoked if it has been revoked
+func (s *Store) Touch(ctx context.Context, userID, tokenID, userAgent, ip string) error {
+	revoked, err := s.redis.Exists(ctx, revokedKey(tokenID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if revoked > 0 {
+		return ErrRevoked
+	}
+
+	// Only write to Postgres once per touchInterval per session
+	first, err := s.redis.SetNX(ctx, touchKey(tokenID), 1, touchInterval).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if !first {
+		return nil
+	}
+
+	var revokedAt *time.Time
+	err = s.db.Pool.QueryRow(ctx, `
+		INSERT INTO sessions (user_id, token_id, device, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (token_id) DO UPDATE
+		SET last_seen_at = NOW(), user_agent = EXCLUDED.user_agent, ip_address = EXCLUDED.ip_address
+		WHERE sessions.user_id = EXCLUDED.user_id
+		RETURNING revoked_at
+	`, userID, tokenID, Device(userAgent), userAgent, ip).Scan(&revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Token ID belongs to another user; never let it through
+		return ErrRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record session: %w", err)
+	}
+	if revokedAt != nil {
+		s.redis.Set(ctx, revokedKey(tokenID), 1, revokedTTL)
+		return ErrRevoked
+	}
+	return nil
+}
+
+// List returns a user's sessions, most recently active first. The session
+// matching currentTokenID is flagged as current.
+func (s *Store) List(ctx context.Context, userID, currentTokenID string) ([]models.Session, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, token_id, device, user_agent, ip_address, last_seen_at, revoked_at, created_at
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY revoked_at IS NOT NULL, last_seen_at DESC
+		LIMIT 100
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var tokenID string
+		if err := rows.Scan(&session.ID, &tokenID, &session.Device, &session.UserAgent,
+			&session.IPAddress, &session.LastSeenAt, &session.RevokedAt, &session.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.Current = tokenID == currentTokenID
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// Revoke invalidates one of a user's sessions immediately
+func (s *Store) Revoke(ctx context.Context, userID, sessionID string) error {
+	var tokenID string
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE sessions SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND user_id = $2
+		RETURNING token_id
+	`, sessionID, userID).Scan(&tokenID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return s.redis.Set(ctx, revokedKey(tokenID), 1, revokedTTL).Err()
+}
+
+// RevokeAll invalidates all of a user's active sessions except keepTokenID,
+// which may be empty, and returns how many were revoked
+func (s *Store) RevokeAll(ctx context.Context, userID, keepTokenID string) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND token_id <> $2
+		RETURNING token_id
+	`, userID, keepTokenID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	var tokenIDs []string
+	for rows.Next() {
+		var tokenID string
+		if err := rows.Scan(&tokenID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	pipe := s.redis.Pipeline()
+	for _, tokenID := range tokenIDs {
+		pipe.Set(ctx, revokedKey(tokenID), 1, revokedTTL)
+	}
+	if len(tokenIDs) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return len(tokenIDs), fmt.Errorf("failed to cache revoked sessions: %w", err)
+		}
+	}
+	return len(tokenIDs), nil
+}
+
+// Device derives a coarse device label from a User-Agent header
+func Device(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		return "iOS"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	default:
+		return "other"
+	}
+}
+
+func revokedKey(tokenID string) string {
+	return "session:revoked:" + tokenID
+}
+
+func touchKey(tokenID string) string {
+	return "session:seen:" + tokenID
+}
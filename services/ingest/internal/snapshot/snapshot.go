@@ -0,0 +1,594 @@
+// Package snapshot exports a user's financial dataset as a portable archive
+// and restores it as a new user, for moving data between environments and
+// reproducing bug reports against a user's data shape
+package snapshot
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/encryption"
+	"github.com/finagent/ingest/internal/redact"
+)
+
+// Format identifies snapshot archives in their manifest
+const Format = "finagent-snapshot/v1"
+
+// ErrInvalidArchive is returned when a restore is given something other than
+// a snapshot this schema can load
+var ErrInvalidArchive = errors.New("invalid snapshot archive")
+
+// table is one table in a snapshot. ids are the columns given fresh values
+// on restore; refs point a column at the table whose new IDs it takes.
+type table struct {
+	name  string
+	query string
+	ids   map[string]idKind
+	refs  map[string][]string
+}
+
+type idKind int
+
+const (
+	uuidID idKind = iota
+	textID        // provider IDs such as Plaid account IDs, kept recognisable
+)
+
+// tables lists the snapshot contents in restore order, parents first.
+// Secrets (access tokens, webhook signing secrets) and operational data
+// (sessions, jobs, audit logs, API keys, grants) are left out.
+var tables = []table{
+	{
+		name:  "users",
+		query: `SELECT id, email, created_at, updated_at FROM users WHERE id = $1`,
+		ids:   map[string]idKind{"id": uuidID},
+	},
+	{
+		name: "plaid_items",
+		query: `SELECT id, user_id, institution_id, institution_name, status, cursor, created_at, updated_at, last_sync_at
+			FROM plaid_items WHERE user_id = $1`,
+		ids:  map[string]idKind{"id": uuidID},
+		refs: map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "account_groups",
+		query: `SELECT * FROM account_groups WHERE user_id = $1`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "accounts",
+		query: `SELECT * FROM accounts WHERE user_id = $1`,
+		ids:   map[string]idKind{"id": textID},
+		refs:  map[string][]string{"user_id": {"users"}, "plaid_item_id": {"plaid_items"}, "group_id": {"account_groups"}},
+	},
+	{
+		name:  "securities",
+		query: `SELECT * FROM securities WHERE user_id = $1`,
+		ids:   map[string]idKind{"id": uuidID, "security_id": textID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "expense_rules",
+		query: `SELECT * FROM expense_rules WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}, "account_id": {"accounts"}},
+	},
+	{
+		name:  "transactions",
+		query: `SELECT * FROM transactions WHERE user_id = $1 ORDER BY date`,
+		ids:   map[string]idKind{"id": textID},
+		refs:  map[string][]string{"user_id": {"users"}, "account_id": {"accounts"}, "expense_rule_id": {"expense_rules"}},
+	},
+	{
+		name:  "holdings",
+		query: `SELECT * FROM holdings WHERE user_id = $1`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}, "account_id": {"accounts"}, "security_id": {"securities"}},
+	},
+	{
+		name: "holding_valuations",
+		query: `SELECT * FROM holding_valuations
+			WHERE user_id = $1 AND holding_id IN (SELECT id FROM holdings WHERE user_id = $1) ORDER BY date`,
+		refs: map[string][]string{
+			"user_id":     {"users"},
+			"holding_id":  {"holdings"},
+			"account_id":  {"accounts"},
+			"security_id": {"securities"},
+		},
+	},
+	{
+		name:  "investment_transactions",
+		query: `SELECT * FROM investment_transactions WHERE user_id = $1 ORDER BY date`,
+		ids:   map[string]idKind{"id": textID},
+		refs:  map[string][]string{"user_id": {"users"}, "account_id": {"accounts"}, "security_id": {"securities"}},
+	},
+	{
+		name:  "crypto_positions",
+		query: `SELECT * FROM crypto_positions WHERE user_id = $1`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "pay_stubs",
+		query: `SELECT * FROM pay_stubs WHERE user_id = $1 ORDER BY pay_date`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}, "plaid_item_id": {"plaid_items"}},
+	},
+	{
+		name:  "crypto_income",
+		query: `SELECT * FROM crypto_income WHERE user_id = $1 ORDER BY received_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "crypto_orders",
+		query: `SELECT * FROM crypto_orders WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "duplicate_links",
+		query: `SELECT * FROM duplicate_links WHERE user_id = $1 ORDER BY parent_id NULLS FIRST, created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":      {"users"},
+			"canonical_id": {"accounts", "transactions"},
+			"duplicate_id": {"accounts", "transactions"},
+			"parent_id":    {"duplicate_links"},
+		},
+	},
+	{
+		name:  "refund_links",
+		query: `SELECT * FROM refund_links WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":   {"users"},
+			"charge_id": {"transactions"},
+			"refund_id": {"transactions"},
+		},
+	},
+	{
+		name:  "report_definitions",
+		query: `SELECT * FROM report_definitions WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "roundup_goals",
+		query: `SELECT * FROM roundup_goals WHERE user_id = $1 ORDER BY started_on`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "equity_grants",
+		query: `SELECT * FROM equity_grants WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":    {"users"},
+			"account_id": {"accounts"},
+		},
+	},
+	{
+		name:  "rental_properties",
+		query: `SELECT * FROM rental_properties WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "rental_transactions",
+		query: `SELECT * FROM rental_transactions WHERE user_id = $1 ORDER BY tagged_at`,
+		refs: map[string][]string{
+			"user_id":        {"users"},
+			"transaction_id": {"transactions"},
+			"property_id":    {"rental_properties"},
+		},
+	},
+	{
+		name:  "dependents",
+		query: `SELECT * FROM dependents WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "allowance_schedules",
+		query: `SELECT * FROM allowance_schedules WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":      {"users"},
+			"dependent_id": {"dependents"},
+		},
+	},
+	{
+		name:  "dependent_ledger",
+		query: `SELECT * FROM dependent_ledger WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":      {"users"},
+			"dependent_id": {"dependents"},
+			"schedule_id":  {"allowance_schedules"},
+		},
+	},
+	{
+		name:  "period_definitions",
+		query: `SELECT * FROM period_definitions WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+	{
+		name:  "equity_vests",
+		query: `SELECT * FROM equity_vests WHERE user_id = $1 ORDER BY vest_date`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs: map[string][]string{
+			"user_id":                   {"users"},
+			"grant_id":                  {"equity_grants"},
+			"investment_transaction_id": {"investment_transactions"},
+		},
+	},
+	{
+		name:  "insights",
+		query: `SELECT * FROM insights WHERE user_id = $1 ORDER BY created_at`,
+		ids:   map[string]idKind{"id": uuidID},
+		refs:  map[string][]string{"user_id": {"users"}},
+	},
+}
+
+// encryptedColumns are stored decrypted in snapshots, since the target
+// environment has its own keys, and encrypted again on restore
+var encryptedColumns = map[string][]string{
+	"accounts": {"mask", "official_name"},
+}
+
+// Manifest describes a snapshot archive
+type Manifest struct {
+	Format        string         `json:"format"`
+	SchemaVersion uint           `json:"schema_version"`
+	SourceUserID  string         `json:"source_user_id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Sanitized     bool           `json:"sanitized"`
+	RecordCounts  map[string]int `json:"record_counts"`
+}
+
+// RestoreOptions names the user a snapshot is restored as
+type RestoreOptions struct {
+	AuthID string // identity provider subject; generated when empty
+	Email  string // replaces the snapshot's email when set
+}
+
+// Restored describes a restored snapshot
+type Restored struct {
+	UserID       string         `json:"user_id"`
+	AuthID       string         `json:"auth_id"`
+	RecordCounts map[string]int `json:"record_counts"`
+}
+
+// Service builds and restores snapshots
+type Service struct {
+	db         *database.Database
+	encryption *encryption.Service
+}
+
+// NewService creates a snapshot service
+func NewService(db *database.Database, enc *encryption.Service) *Service {
+	return &Service{db: db, encryption: enc}
+}
+
+// Export builds a zip archive of a user's dataset. Sanitized snapshots have
+// account numbers, names, emails, locations and other identifying fields
+// masked, including inside raw provider payloads, while amounts, dates,
+// categories and record relationships are kept.
+func (s *Service) Export(ctx context.Context, userID string, sanitize bool) ([]byte, *Manifest, error) {
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	manifest := &Manifest{
+		Format:        Format,
+		SchemaVersion: version,
+		SourceUserID:  userID,
+		CreatedAt:     time.Now().UTC(),
+		Sanitized:     sanitize,
+		RecordCounts:  make(map[string]int),
+	}
+
+	for _, t := range tables {
+		var data []byte
+		err := s.db.Reader(ctx).QueryRow(ctx,
+			"SELECT COALESCE(json_agg(t), '[]'::json) FROM ("+t.query+") t", userID).Scan(&data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to export %s: %w", t.name, err)
+		}
+
+		rows, err := decodeRows(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", t.name, err)
+		}
+		for _, row := range rows {
+			for _, column := range encryptedColumns[t.name] {
+				value, ok := row[column].(string)
+				if !ok {
+					continue
+				}
+				decrypted, err := s.encryption.DecryptString(ctx, value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to decrypt %s.%s: %w", t.name, column, err)
+				}
+				row[column] = decrypted
+			}
+		}
+		if sanitize {
+			for i, row := range rows {
+				rows[i] = redact.Value(row).(map[string]interface{})
+			}
+		}
+		manifest.RecordCounts[t.name] = len(rows)
+
+		if err := writeJSON(zw, t.name+".json", rows); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+	return buf.Bytes(), manifest, nil
+}
+
+// Restore loads a snapshot as a new user in one transaction. Every record
+// gets a fresh ID, so a snapshot can be restored next to its source user or
+// restored more than once. Restored Plaid items have no access token and
+// are marked restored, so they never sync.
+func (s *Service) Restore(ctx context.Context, archive []byte, opts RestoreOptions) (*Restored, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var manifest Manifest
+	if err := readJSON(files, "manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidArchive, manifest.Format)
+	}
+	version, err := s.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion > version {
+		return nil, fmt.Errorf("%w: snapshot schema version %d is newer than this database's %d",
+			ErrInvalidArchive, manifest.SchemaVersion, version)
+	}
+
+	restored := &Restored{AuthID: opts.AuthID, RecordCounts: make(map[string]int)}
+	if restored.AuthID == "" {
+		suffix, err := randomHex(8)
+		if err != nil {
+			return nil, err
+		}
+		restored.AuthID = "snapshot|" + suffix
+	}
+
+	tag, err := randomHex(4)
+	if err != nil {
+		return nil, err
+	}
+	newIDs := make(map[string]map[string]string)
+
+	err = s.db.InTx(ctx, func(ctx context.Context) error {
+		for _, t := range tables {
+			var raw json.RawMessage
+			if err := readJSON(files, t.name+".json", &raw); err != nil {
+				if errors.Is(err, errMissingFile) {
+					continue
+				}
+				return err
+			}
+			rows, err := decodeRows(raw)
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, t.name, err)
+			}
+
+			if err := s.prepareRows(ctx, t, rows, newIDs, tag, opts, restored); err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				continue
+			}
+
+			data, err := json.Marshal(rows)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %w", t.name, err)
+			}
+			// Columns missing from an older snapshot are left NULL and
+			// columns this schema no longer has are ignored
+			inserted, err := s.db.Writer(ctx).Exec(ctx,
+				"INSERT INTO "+t.name+" SELECT * FROM jsonb_populate_recordset(NULL::"+t.name+", $1::jsonb)", data)
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", t.name, err)
+			}
+			restored.RecordCounts[t.name] = int(inserted.RowsAffected())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	restored.UserID = newIDs["users"][manifest.SourceUserID]
+	if restored.UserID == "" {
+		return nil, fmt.Errorf("%w: snapshot has no user", ErrInvalidArchive)
+	}
+	return restored, nil
+}
+
+// prepareRows gives a table's rows fresh IDs, points their references at
+// the restored parents and fills in what snapshots leave out
+func (s *Service) prepareRows(ctx context.Context, t table, rows []map[string]interface{}, newIDs map[string]map[string]string, tag string, opts RestoreOptions, restored *Restored) error {
+	ids := newIDs[t.name]
+	if ids == nil {
+		ids = make(map[string]string)
+		newIDs[t.name] = ids
+	}
+
+	for _, row := range rows {
+		for column, kind := range t.ids {
+			old, ok := row[column].(string)
+			if !ok {
+				continue
+			}
+			var id string
+			switch kind {
+			case uuidID:
+				var err error
+				if id, err = newUUID(); err != nil {
+					return err
+				}
+			case textID:
+				id = tag + "-" + old
+			}
+			// Only the primary key is looked up by referencing tables
+			if column == "id" {
+				ids[old] = id
+			}
+			row[column] = id
+		}
+
+		for column, parents := range t.refs {
+			old, ok := row[column].(string)
+			if !ok {
+				continue
+			}
+			row[column] = nil
+			for _, parent := range parents {
+				if id, ok := newIDs[parent][old]; ok {
+					row[column] = id
+					break
+				}
+			}
+		}
+
+		for _, column := range encryptedColumns[t.name] {
+			value, ok := row[column].(string)
+			if !ok {
+				continue
+			}
+			encrypted, err := s.encryption.EncryptString(ctx, value)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s.%s: %w", t.name, column, err)
+			}
+			row[column] = encrypted
+		}
+
+		switch t.name {
+		case "users":
+			row["auth_id"] = restored.AuthID
+			if opts.Email != "" {
+				row["email"] = opts.Email
+			} else if email, _ := row["email"].(string); email == redact.Redacted {
+				row["email"] = nil
+			}
+		case "plaid_items":
+			row["access_token_enc"] = `\x`
+			row["status"] = "restored"
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the applied migration version
+func (s *Service) schemaVersion(ctx context.Context) (uint, error) {
+	var version int64
+	err := s.db.Pool.QueryRow(ctx, "SELECT version FROM schema_migrations LIMIT 1").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return uint(version), nil
+}
+
+// decodeRows decodes table rows keeping numbers exact, so amounts survive
+// the round trip unchanged
+func decodeRows(data []byte) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var rows []map[string]interface{}
+	if err := dec.Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+var errMissingFile = errors.New("file missing from snapshot")
+
+func readJSON(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", errMissingFile, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidArchive, name, err)
+	}
+	return nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to snapshot: %w", name, err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
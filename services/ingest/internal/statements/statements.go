@@ -0,0 +1,282 @@
+// Package statements downloads the monthly PDF statements institutions
+// publish through Plaid's Statements product, for items consented to it,
+// and keeps them in the attachment object storage so users and the agent
+// can refer to the real document.
+package statements
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/finagent/ingest/internal/attachments"
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/plaid"
+)
+
+// Options sets how often statements are checked for, where they are kept
+// and how long download URLs last. An Interval of 0 disables the worker.
+type Options struct {
+	Interval  time.Duration
+	Prefix    string // key prefix within the attachment bucket
+	URLExpiry time.Duration
+}
+
+// Service downloads and serves account statements
+type Service struct {
+	db      *database.Database
+	locks   *locks.Locker
+	plaid   *plaid.Client
+	objects attachments.ObjectStore
+	opts    Options
+}
+
+// NewService creates a statement service storing PDFs in objects. When
+// locker is set, only one instance downloads statements at a time.
+func NewService(db *database.Database, locker *locks.Locker, plaidClient *plaid.Client, objects attachments.ObjectStore, opts Options) *Service {
+	return &Service{db: db, locks: locker, plaid: plaidClient, objects: objects, opts: opts}
+}
+
+// Run downloads new statements every Interval until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "statements")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock statements", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	stored, err := s.Sync(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to sync statements", "error", err)
+	}
+	if stored > 0 {
+		slog.InfoContext(ctx, "Stored statements", "count", stored)
+	}
+}
+
+// item is a linked item consented to statements
+type item struct {
+	id             string
+	userID         string
+	encryptedToken []byte
+}
+
+// Sync downloads the statements not yet stored for every active item
+// consented to statements, returning how many were stored. An item that
+// fails is logged and skipped.
+func (s *Service) Sync(ctx context.Context) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, access_token_enc FROM plaid_items
+		WHERE status = 'active' AND $1 = ANY(consented_products)
+			AND (consent_expires_at IS NULL OR consent_expires_at > NOW())
+		ORDER BY id
+	`, plaid.ProductStatements)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query items: %w", err)
+	}
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.id, &it.userID, &it.encryptedToken); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query items: %w", err)
+	}
+
+	stored := 0
+	for _, it := range items {
+		n, err := s.syncItem(ctx, it)
+		stored += n
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to sync item statements", "item_id", it.id, "error", err)
+		}
+		if ctx.Err() != nil {
+			return stored, ctx.Err()
+		}
+	}
+	return stored, nil
+}
+
+// syncItem stores an item's statements that are not stored yet, for the
+// accounts that are
+func (s *Service) syncItem(ctx context.Context, it item) (int, error) {
+	accessToken, err := s.plaid.DecryptToken(ctx, it.encryptedToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+	available, err := s.plaid.ListStatements(ctx, accessToken)
+	if err != nil {
+		return 0, err
+	}
+	if len(available) == 0 {
+		return 0, nil
+	}
+
+	known := make(map[string]bool)
+	accounts := make(map[string]bool)
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT 'statement', plaid_statement_id FROM statements WHERE plaid_item_id = $1
+		UNION ALL
+		SELECT 'account', id FROM accounts WHERE plaid_item_id = $1
+	`, it.id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query stored statements: %w", err)
+	}
+	for rows.Next() {
+		var kind, id string
+		if err := rows.Scan(&kind, &id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan stored statement: %w", err)
+		}
+		if kind == "statement" {
+			known[id] = true
+		} else {
+			accounts[id] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query stored statements: %w", err)
+	}
+
+	stored := 0
+	for _, st := range available {
+		if known[st.StatementID] || !accounts[st.AccountID] {
+			continue
+		}
+		if err := s.store(ctx, it, accessToken, st); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// store downloads one statement, uploads it and records it, removing the
+// upload if the record cannot be written
+func (s *Service) store(ctx context.Context, it item, accessToken string, st plaid.Statement) error {
+	pdf, err := s.plaid.DownloadStatement(ctx, accessToken, st.StatementID)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(s.opts.Prefix, it.userID, st.StatementID+".pdf")
+	if err := s.objects.Put(ctx, key, "application/pdf", pdf); err != nil {
+		return fmt.Errorf("failed to store statement: %w", err)
+	}
+
+	sum := sha256.Sum256(pdf)
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO statements (user_id, plaid_item_id, account_id, plaid_statement_id, year, month,
+			date_posted, object_key, size_bytes, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (plaid_statement_id) DO NOTHING
+	`, it.userID, it.id, st.AccountID, st.StatementID, st.Year, st.Month, st.DatePosted, key,
+		len(pdf), hex.EncodeToString(sum[:]))
+	if err != nil {
+		if delErr := s.objects.Delete(context.Background(), key); delErr != nil {
+			slog.ErrorContext(ctx, "Failed to remove orphaned statement", "key", key, "error", delErr)
+		}
+		return fmt.Errorf("failed to record statement: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's statements, newest first, each with a signed
+// download URL. A non-empty accountID keeps one account's.
+func (s *Service) List(ctx context.Context, userID, accountID string) ([]models.Statement, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, account_id, year, month, date_posted, object_key, size_bytes, sha256, created_at
+		FROM statements
+		WHERE user_id = $1 AND ($2 = '' OR account_id = $2)
+		ORDER BY year DESC, month DESC, account_id
+	`, userID, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statements: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Statement{}
+	for rows.Next() {
+		var st models.Statement
+		var key string
+		if err := rows.Scan(&st.ID, &st.AccountID, &st.Year, &st.Month, &st.DatePosted, &key,
+			&st.SizeBytes, &st.SHA256, &st.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan statement: %w", err)
+		}
+		filename := fmt.Sprintf("statement-%d-%02d.pdf", st.Year, st.Month)
+		url, err := s.objects.SignedURL(ctx, key, filename, s.opts.URLExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign statement URL: %w", err)
+		}
+		expires := time.Now().Add(s.opts.URLExpiry).UTC()
+		st.URL = url
+		st.URLExpiresAt = &expires
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+// DeleteUser removes the files of all of a user's statements, before the
+// user's rows are deleted
+func (s *Service) DeleteUser(ctx context.Context, userID string) error {
+	rows, err := s.db.Pool.Query(ctx,
+		"SELECT object_key FROM statements WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to query statements: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan statement: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query statements: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.objects.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete statement file: %w", err)
+		}
+	}
+	return nil
+}
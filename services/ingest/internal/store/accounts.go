@@ -0,0 +1,333 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+)
+
+// EncryptedAccount holds an account's encrypted PII columns
+type EncryptedAccount struct {
+	ID           string
+	Mask         *string
+	OfficialName *string
+}
+
+// AccountStore reads and writes linked financial accounts. Mask and
+// official name are stored encrypted; callers encrypt and decrypt them.
+type AccountStore interface {
+	// List returns a user's open accounts ordered by name, leaving out
+	// duplicates of another account and, unless includeHidden, hidden ones
+	List(ctx context.Context, userID string, includeHidden bool) ([]models.Account, error)
+	// Get returns one of a user's accounts, or ErrNotFound
+	Get(ctx context.Context, accountID, userID string) (*models.Account, error)
+	// Update changes how one of a user's accounts is named, grouped, shown
+	// and counted and returns it, or ErrNotFound
+	Update(ctx context.Context, accountID, userID string, update AccountUpdate) (*models.Account, error)
+	// ListGroups returns a user's account groups ordered by name
+	ListGroups(ctx context.Context, userID string) ([]models.AccountGroup, error)
+	// Group returns one of a user's account groups, or ErrNotFound
+	Group(ctx context.Context, groupID, userID string) (*models.AccountGroup, error)
+	// CreateGroup creates an account group and sets its ID and timestamps,
+	// or returns ErrConflict if the user has a group of that name
+	CreateGroup(ctx context.Context, group *models.AccountGroup) error
+	// RenameGroup renames one of a user's account groups and returns it,
+	// or returns ErrNotFound or ErrConflict
+	RenameGroup(ctx context.Context, groupID, userID, name string) (*models.AccountGroup, error)
+	// DeleteGroup deletes one of a user's account groups, leaving its
+	// accounts ungrouped, or returns ErrNotFound
+	DeleteGroup(ctx context.Context, groupID, userID string) error
+	// UpsertBatch inserts or refreshes the accounts synced from one Plaid item
+	UpsertBatch(ctx context.Context, userID, plaidItemID string, accounts []models.PlaidAccount) error
+	// ListEncrypted returns up to limit accounts with PII after afterID, by ID
+	ListEncrypted(ctx context.Context, afterID string, limit int) ([]EncryptedAccount, error)
+	// UpdateEncrypted replaces an account's encrypted PII columns
+	UpdateEncrypted(ctx context.Context, account EncryptedAccount) error
+}
+
+// AccountUpdate changes how an account is named, grouped, shown and
+// counted, and what it yields. Nil fields are left as they are; an empty
+// Nickname or GroupID, a StatementCloseDay of 0, or a negative APY
+// clears it. An APY set here is the user's and is kept through syncs.
+type AccountUpdate struct {
+	Nickname             *string
+	GroupID              *string
+	Hidden               *bool
+	ExcludeFromAnalytics *bool
+	StatementCloseDay    *int
+	APY                  *decimal.Decimal
+}
+
+type accountStore struct {
+	db *database.Database
+}
+
+// NewAccountStore creates a Postgres-backed account store
+func NewAccountStore(db *database.Database) AccountStore {
+	return &accountStore{db: db}
+}
+
+const accountColumns = `a.id, a.name, a.nickname, a.mask, a.official_name, a.type, a.subtype,
+		       a.currency, a.balance_current, a.balance_available, a.balance_limit,
+		       a.is_closed, a.hidden, a.exclude_from_analytics, a.group_id, a.statement_close_day,
+		       a.apy, a.apy_source, a.updated_at`
+
+func scanAccount(row pgx.Row) (*models.Account, error) {
+	var acc models.Account
+	err := row.Scan(
+		&acc.ID, &acc.Name, &acc.Nickname, &acc.Mask, &acc.OfficialName,
+		&acc.Type, &acc.Subtype, &acc.Currency,
+		&acc.BalanceCurrent, &acc.BalanceAvailable, &acc.BalanceLimit,
+		&acc.IsClosed, &acc.Hidden, &acc.ExcludeFromAnalytics, &acc.GroupID, &acc.StatementCloseDay,
+		&acc.APY, &acc.APYSource, &acc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+func (s *accountStore) List(ctx context.Context, userID string, includeHidden bool) ([]models.Account, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT `+accountColumns+`
+		FROM accounts a
+		WHERE a.user_id = $1 AND a.is_closed = false AND a.deleted_at IS NULL
+		  AND ($2 OR a.hidden = false)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'account' AND dl.duplicate_id = a.id AND dl.status <> 'rejected')
+		ORDER BY a.name
+	`, userID, includeHidden)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []models.Account
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, *acc)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *accountStore) Get(ctx context.Context, accountID, userID string) (*models.Account, error) {
+	acc, err := scanAccount(s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT `+accountColumns+`
+		FROM accounts a
+		WHERE a.id = $1 AND a.user_id = $2 AND a.deleted_at IS NULL
+	`, accountID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account: %w", err)
+	}
+	return acc, nil
+}
+
+func (s *accountStore) Update(ctx context.Context, accountID, userID string, update AccountUpdate) (*models.Account, error) {
+	acc, err := scanAccount(s.db.Pool.QueryRow(ctx, `
+		UPDATE accounts a
+		SET nickname = CASE WHEN $3::text IS NULL THEN a.nickname ELSE NULLIF($3, '') END,
+		    group_id = CASE WHEN $4::text IS NULL THEN a.group_id ELSE NULLIF($4, '')::uuid END,
+		    hidden = COALESCE($5, a.hidden),
+		    exclude_from_analytics = COALESCE($6, a.exclude_from_analytics),
+		    statement_close_day = CASE WHEN $7::int IS NULL THEN a.statement_close_day ELSE NULLIF($7, 0) END,
+		    apy = CASE WHEN $8::numeric IS NULL THEN a.apy WHEN $8 < 0 THEN NULL ELSE $8 END,
+		    apy_source = CASE WHEN $8::numeric IS NULL THEN a.apy_source WHEN $8 < 0 THEN NULL ELSE 'user' END,
+		    apy_updated_at = CASE WHEN $8::numeric IS NULL THEN a.apy_updated_at ELSE NOW() END,
+		    updated_at = NOW()
+		WHERE a.id = $1 AND a.user_id = $2 AND a.deleted_at IS NULL
+		RETURNING `+accountColumns,
+		accountID, userID, update.Nickname, update.GroupID, update.Hidden, update.ExcludeFromAnalytics,
+		update.StatementCloseDay, update.APY))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+	return acc, nil
+}
+
+func (s *accountStore) ListGroups(ctx context.Context, userID string) ([]models.AccountGroup, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, name, created_at, updated_at
+		FROM account_groups
+		WHERE user_id = $1
+		ORDER BY lower(name)
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []models.AccountGroup{}
+	for rows.Next() {
+		var g models.AccountGroup
+		if err := rows.Scan(&g.ID, &g.UserID, &g.Name, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (s *accountStore) Group(ctx context.Context, groupID, userID string) (*models.AccountGroup, error) {
+	var g models.AccountGroup
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, user_id, name, created_at, updated_at
+		FROM account_groups
+		WHERE id = $1 AND user_id = $2
+	`, groupID, userID).Scan(&g.ID, &g.UserID, &g.Name, &g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account group: %w", err)
+	}
+	return &g, nil
+}
+
+func (s *accountStore) CreateGroup(ctx context.Context, group *models.AccountGroup) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO account_groups (user_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, lower(name)) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`, group.UserID, group.Name).Scan(&group.ID, &group.CreatedAt, &group.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create account group: %w", err)
+	}
+	return nil
+}
+
+func (s *accountStore) RenameGroup(ctx context.Context, groupID, userID, name string) (*models.AccountGroup, error) {
+	var g models.AccountGroup
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE account_groups SET name = $3
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, name, created_at, updated_at
+	`, groupID, userID, name).Scan(&g.ID, &g.UserID, &g.Name, &g.CreatedAt, &g.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation: the user has a group of that name
+		return nil, ErrConflict
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename account group: %w", err)
+	}
+	return &g, nil
+}
+
+func (s *accountStore) DeleteGroup(ctx context.Context, groupID, userID string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM account_groups WHERE id = $1 AND user_id = $2", groupID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete account group: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *accountStore) UpsertBatch(ctx context.Context, userID, plaidItemID string, accounts []models.PlaidAccount) error {
+	columns := []string{"id", "user_id", "plaid_item_id", "name", "mask", "official_name",
+		"type", "subtype", "currency", "balance_current", "balance_available", "balance_limit", "apy"}
+
+	rows := make([][]interface{}, 0, len(accounts))
+	for _, account := range accounts {
+		rows = append(rows, []interface{}{
+			account.ID, userID, plaidItemID, account.Name, account.Mask,
+			account.OfficialName, account.Type, account.Subtype, isoCurrency(account.Balances),
+			account.Balances.Current, account.Balances.Available, account.Balances.Limit, account.APY,
+		})
+	}
+
+	_, err := bulkUpsert(ctx, s.db, "accounts", columns, rows, `
+		INSERT INTO accounts (id, user_id, plaid_item_id, name, mask, official_name,
+							type, subtype, currency, balance_current, balance_available,
+							balance_limit, apy, apy_source, apy_updated_at, updated_at)
+		SELECT DISTINCT ON (id) id, user_id, plaid_item_id, name, mask, official_name,
+		       type, subtype, currency, balance_current, balance_available,
+		       balance_limit, apy, CASE WHEN apy IS NOT NULL THEN 'provider' END,
+		       CASE WHEN apy IS NOT NULL THEN NOW() END, NOW()
+		FROM accounts_stage
+		ORDER BY id
+		ON CONFLICT (id)
+		DO UPDATE SET
+			name = EXCLUDED.name,
+			mask = EXCLUDED.mask,
+			official_name = EXCLUDED.official_name,
+			balance_current = EXCLUDED.balance_current,
+			balance_available = EXCLUDED.balance_available,
+			balance_limit = EXCLUDED.balance_limit,
+			apy = CASE WHEN accounts.apy_source = 'user' THEN accounts.apy ELSE EXCLUDED.apy END,
+			apy_source = CASE WHEN accounts.apy_source = 'user' THEN 'user' ELSE EXCLUDED.apy_source END,
+			apy_updated_at = CASE
+				WHEN accounts.apy_source = 'user' OR accounts.apy IS NOT DISTINCT FROM EXCLUDED.apy
+				THEN accounts.apy_updated_at ELSE EXCLUDED.apy_updated_at END,
+			deleted_at = NULL,
+			updated_at = NOW()
+	`)
+	return err
+}
+
+func (s *accountStore) ListEncrypted(ctx context.Context, afterID string, limit int) ([]EncryptedAccount, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, mask, official_name FROM accounts
+		WHERE id > $1 AND (mask IS NOT NULL OR official_name IS NOT NULL)
+		ORDER BY id LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []EncryptedAccount
+	for rows.Next() {
+		var a EncryptedAccount
+		if err := rows.Scan(&a.ID, &a.Mask, &a.OfficialName); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		batch = append(batch, a)
+	}
+	return batch, rows.Err()
+}
+
+func (s *accountStore) UpdateEncrypted(ctx context.Context, account EncryptedAccount) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"UPDATE accounts SET mask = $2, official_name = $3 WHERE id = $1",
+		account.ID, account.Mask, account.OfficialName)
+	if err != nil {
+		return fmt.Errorf("failed to update account %s: %w", account.ID, err)
+	}
+	return nil
+}
+
+// isoCurrency extracts the currency from a Plaid balance
+func isoCurrency(balance models.PlaidBalance) string {
+	if balance.IsoCurrencyCode != nil {
+		return *balance.IsoCurrencyCode
+	}
+	if balance.UnofficialCurrencyCode != nil {
+		return *balance.UnofficialCurrencyCode
+	}
+	return "USD" // default
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/finagent/ingest/internal/cache"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// WithCache wraps the account, holding and crypto position reads in
+// stores with a cache-aside layer. Rows are cached as stored, so encrypted
+// columns stay encrypted in Redis. Cache errors fall through to the database.
+func WithCache(stores *Stores, c *cache.Cache) *Stores {
+	cached := *stores
+	cached.Accounts = &cachedAccountStore{AccountStore: stores.Accounts, cache: c}
+	cached.Holdings = &cachedHoldingStore{HoldingStore: stores.Holdings, cache: c}
+	cached.Orders = &cachedOrderStore{OrderStore: stores.Orders, cache: c}
+	return &cached
+}
+
+// cacheRead serves dest from the cache, or fills it with load and caches it
+func cacheRead(ctx context.Context, c *cache.Cache, userID, endpoint string, dest interface{}, load func() error) error {
+	hit, err := c.Get(ctx, userID, endpoint, dest)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read from cache", "endpoint", endpoint, "error", err)
+	}
+	if hit {
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	if err := c.Set(ctx, userID, endpoint, dest); err != nil {
+		slog.WarnContext(ctx, "Failed to write to cache", "endpoint", endpoint, "error", err)
+	}
+	return nil
+}
+
+type cachedAccountStore struct {
+	AccountStore
+	cache *cache.Cache
+}
+
+func (s *cachedAccountStore) List(ctx context.Context, userID string, includeHidden bool) ([]models.Account, error) {
+	endpoint := "accounts"
+	if includeHidden {
+		endpoint = "accounts_all"
+	}
+	var accounts []models.Account
+	err := cacheRead(ctx, s.cache, userID, endpoint, &accounts, func() (err error) {
+		accounts, err = s.AccountStore.List(ctx, userID, includeHidden)
+		return err
+	})
+	return accounts, err
+}
+
+type cachedHoldingStore struct {
+	HoldingStore
+	cache *cache.Cache
+}
+
+func (s *cachedHoldingStore) List(ctx context.Context, userID string) ([]models.Holding, error) {
+	var holdings []models.Holding
+	err := cacheRead(ctx, s.cache, userID, "holdings", &holdings, func() (err error) {
+		holdings, err = s.HoldingStore.List(ctx, userID)
+		return err
+	})
+	return holdings, err
+}
+
+type cachedOrderStore struct {
+	OrderStore
+	cache *cache.Cache
+}
+
+func (s *cachedOrderStore) ListPositions(ctx context.Context, userID string) ([]models.CryptoPosition, error) {
+	var positions []models.CryptoPosition
+	err := cacheRead(ctx, s.cache, userID, "positions", &positions, func() (err error) {
+		positions, err = s.OrderStore.ListPositions(ctx, userID)
+		return err
+	})
+	return positions, err
+}
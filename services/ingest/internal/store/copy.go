@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// bulkUpsert loads rows into a temporary copy of table with COPY, then runs
+// merge to move them into table, all in one transaction, or in a savepoint
+// when ctx is already in one. merge reads from the staging table, which is
+// named table + "_stage" and dropped once merged.
+// It returns the number of rows merge affected.
+func bulkUpsert(ctx context.Context, db *database.Database, table string, columns []string, rows [][]interface{}, merge string) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Writer(ctx).Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin bulk upsert: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	stage := table + "_stage"
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", stage, table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create staging table for %s: %w", table, err)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{stage}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return 0, fmt.Errorf("failed to copy %s: %w", table, err)
+	}
+
+	tag, err := tx.Exec(ctx, merge)
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge %s: %w", table, err)
+	}
+
+	// ON COMMIT DROP only fires when the outermost transaction commits
+	if _, err := tx.Exec(ctx, "DROP TABLE "+stage); err != nil {
+		return 0, fmt.Errorf("failed to drop staging table for %s: %w", table, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit bulk upsert: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
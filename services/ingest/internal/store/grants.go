@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// GrantStore reads and writes delegated access grants
+type GrantStore interface {
+	// Role returns the role ownerID has granted to granteeID, or an empty
+	// string if there is no active grant
+	Role(ctx context.Context, ownerID, granteeID string) (string, error)
+	// Upsert creates a grant or reactivates it with a new role, filling in
+	// its ID and creation time
+	Upsert(ctx context.Context, grant *models.Grant) error
+	// ListActive returns the active grants a user has given or received
+	ListActive(ctx context.Context, userID string) ([]models.Grant, error)
+	// Revoke revokes a grant the user gave or received, or returns ErrNotFound
+	Revoke(ctx context.Context, grantID, userID string) error
+}
+
+type grantStore struct {
+	db *database.Database
+}
+
+// NewGrantStore creates a Postgres-backed grant store
+func NewGrantStore(db *database.Database) GrantStore {
+	return &grantStore{db: db}
+}
+
+func (s *grantStore) Role(ctx context.Context, ownerID, granteeID string) (string, error) {
+	var role string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT role FROM grants
+		WHERE owner_user_id = $1 AND grantee_user_id = $2 AND revoked_at IS NULL
+	`, ownerID, granteeID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query grant: %w", err)
+	}
+	return role, nil
+}
+
+func (s *grantStore) Upsert(ctx context.Context, grant *models.Grant) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO grants (owner_user_id, grantee_user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (owner_user_id, grantee_user_id)
+		DO UPDATE SET role = EXCLUDED.role, revoked_at = NULL
+		RETURNING id, created_at
+	`, grant.OwnerUserID, grant.GranteeUserID, grant.Role).Scan(&grant.ID, &grant.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create grant: %w", err)
+	}
+	return nil
+}
+
+func (s *grantStore) ListActive(ctx context.Context, userID string) ([]models.Grant, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT g.id, g.owner_user_id, g.grantee_user_id, u.email, g.role, g.revoked_at, g.created_at
+		FROM grants g
+		JOIN users u ON u.id = g.grantee_user_id
+		WHERE (g.owner_user_id = $1 OR g.grantee_user_id = $1) AND g.revoked_at IS NULL
+		ORDER BY g.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []models.Grant
+	for rows.Next() {
+		var g models.Grant
+		err := rows.Scan(&g.ID, &g.OwnerUserID, &g.GranteeUserID, &g.GranteeEmail,
+			&g.Role, &g.RevokedAt, &g.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *grantStore) Revoke(ctx context.Context, grantID, userID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE grants SET revoked_at = NOW()
+		WHERE id = $1 AND (owner_user_id = $2 OR grantee_user_id = $2) AND revoked_at IS NULL
+	`, grantID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke grant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
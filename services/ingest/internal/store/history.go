@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// HistoryStore reads the append-only change history of accounts,
+// transactions and holdings. Versions are written by database triggers.
+type HistoryStore interface {
+	// Changes returns up to limit of a user's versions recorded after since,
+	// oldest first, optionally limited to one table
+	Changes(ctx context.Context, userID string, since time.Time, table string, limit int) ([]models.RecordVersion, error)
+	// Record returns every version of one record, oldest first
+	Record(ctx context.Context, userID, table, recordID string) ([]models.RecordVersion, error)
+}
+
+type historyStore struct {
+	db *database.Database
+}
+
+// NewHistoryStore creates a Postgres-backed history store
+func NewHistoryStore(db *database.Database) HistoryStore {
+	return &historyStore{db: db}
+}
+
+func (s *historyStore) Changes(ctx context.Context, userID string, since time.Time, table string, limit int) ([]models.RecordVersion, error) {
+	query := `
+		SELECT id, table_name, record_id, operation, data, changed_at
+		FROM record_versions
+		WHERE user_id = $1 AND changed_at > $2
+	`
+	args := []interface{}{userID, since}
+	argIndex := 3
+
+	if table != "" {
+		query += fmt.Sprintf(" AND table_name = $%d", argIndex)
+		args = append(args, table)
+		argIndex++
+	}
+
+	query += " ORDER BY changed_at, id"
+	query += fmt.Sprintf(" LIMIT $%d", argIndex)
+	args = append(args, limit)
+
+	return s.query(ctx, query, args...)
+}
+
+func (s *historyStore) Record(ctx context.Context, userID, table, recordID string) ([]models.RecordVersion, error) {
+	return s.query(ctx, `
+		SELECT id, table_name, record_id, operation, data, changed_at
+		FROM record_versions
+		WHERE user_id = $1 AND table_name = $2 AND record_id = $3
+		ORDER BY id
+	`, userID, table, recordID)
+}
+
+func (s *historyStore) query(ctx context.Context, query string, args ...interface{}) ([]models.RecordVersion, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query record history: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.RecordVersion{}
+	for rows.Next() {
+		var v models.RecordVersion
+		if err := rows.Scan(&v.ID, &v.Table, &v.RecordID, &v.Operation, &v.Data, &v.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan record version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// HoldingStore reads investment holdings
+type HoldingStore interface {
+	// List returns a user's holdings, largest first, leaving out those of
+	// duplicate accounts. Holdings of accounts hidden or excluded from
+	// analytics are marked Excluded.
+	List(ctx context.Context, userID string) ([]models.Holding, error)
+}
+
+type holdingStore struct {
+	db *database.Database
+}
+
+// NewHoldingStore creates a Postgres-backed holding store
+func NewHoldingStore(db *database.Database) HoldingStore {
+	return &holdingStore{db: db}
+}
+
+func (s *holdingStore) List(ctx context.Context, userID string) ([]models.Holding, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT h.id, h.account_id, h.quantity, h.institution_price,
+		       h.institution_value, h.cost_basis, h.last_refresh,
+		       s.symbol, s.name as security_name, s.cusip, s.currency,
+		       s.sector, s.asset_class, s.expense_ratio, s.logo_url,
+		       a.name as account_name, a.mask as account_mask,
+		       a.hidden OR a.exclude_from_analytics AS excluded
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		JOIN accounts a ON h.account_id = a.id
+		WHERE h.user_id = $1 AND h.deleted_at IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'account' AND dl.duplicate_id = h.account_id AND dl.status <> 'rejected')
+		ORDER BY h.institution_value DESC NULLS LAST
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var holdings []models.Holding
+	for rows.Next() {
+		var holding models.Holding
+		err := rows.Scan(
+			&holding.ID, &holding.AccountID, &holding.Quantity,
+			&holding.InstitutionPrice, &holding.InstitutionValue,
+			&holding.CostBasis, &holding.LastRefresh,
+			&holding.Symbol, &holding.SecurityName, &holding.CUSIP,
+			&holding.Currency, &holding.Sector, &holding.AssetClass,
+			&holding.ExpenseRatio, &holding.LogoURL,
+			&holding.AccountName, &holding.AccountMask,
+			&holding.Excluded,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan holding: %w", err)
+		}
+		holdings = append(holdings, holding)
+	}
+	return holdings, rows.Err()
+}
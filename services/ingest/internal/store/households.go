@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// HouseholdStore reads and writes households, their members, account
+// visibility and budgets
+type HouseholdStore interface {
+	// Create creates a household with its creator as its active owner,
+	// filling in its ID and creation time
+	Create(ctx context.Context, household *models.Household) error
+	// Get returns a household with its members, or ErrNotFound
+	Get(ctx context.Context, householdID string) (*models.Household, error)
+	// ListForUser returns the households a user belongs to or is invited
+	// to, oldest first, without members
+	ListForUser(ctx context.Context, userID string) ([]models.Household, error)
+	// Delete deletes a household, or returns ErrNotFound
+	Delete(ctx context.Context, householdID string) error
+	// Member returns a user's membership of a household, or ErrNotFound
+	Member(ctx context.Context, householdID, userID string) (*models.HouseholdMember, error)
+	// Invite invites a user to a household, or returns ErrConflict if they
+	// already belong to it or are invited
+	Invite(ctx context.Context, householdID, userID, invitedBy string) error
+	// Join accepts a user's invitation, or returns ErrNotFound if they have
+	// none
+	Join(ctx context.Context, householdID, userID string) error
+	// RemoveMember removes a member or invitation, or returns ErrNotFound
+	RemoveMember(ctx context.Context, householdID, userID string) error
+	// Visibility returns the visibility set for accounts in a household,
+	// by account ID; accounts missing from it are shared in full
+	Visibility(ctx context.Context, householdID string) (map[string]string, error)
+	// SetVisibility sets how much of one of userID's accounts a household
+	// sees, or returns ErrNotFound if the account is not theirs
+	SetVisibility(ctx context.Context, householdID, userID, accountID, visibility string) error
+	// UpsertBudget creates a budget or replaces the limit of the category's
+	// existing one, filling in its ID and creation time
+	UpsertBudget(ctx context.Context, budget *models.Budget, createdBy string) error
+	// Budgets returns a household's budgets by category
+	Budgets(ctx context.Context, householdID string) ([]models.Budget, error)
+	// DeleteBudget deletes a household's budget, or returns ErrNotFound
+	DeleteBudget(ctx context.Context, householdID, budgetID string) error
+}
+
+type householdStore struct {
+	db *database.Database
+}
+
+// NewHouseholdStore creates a Postgres-backed household store
+func NewHouseholdStore(db *database.Database) HouseholdStore {
+	return &householdStore{db: db}
+}
+
+func (s *householdStore) Create(ctx context.Context, household *models.Household) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		err := s.db.Writer(ctx).QueryRow(ctx, `
+			INSERT INTO households (name, created_by)
+			VALUES ($1, $2)
+			RETURNING id, created_at
+		`, household.Name, household.CreatedBy).Scan(&household.ID, &household.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create household: %w", err)
+		}
+		_, err = s.db.Writer(ctx).Exec(ctx, `
+			INSERT INTO household_members (household_id, user_id, role, status, joined_at)
+			VALUES ($1, $2, 'owner', 'active', NOW())
+		`, household.ID, household.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to add household owner: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *householdStore) Get(ctx context.Context, householdID string) (*models.Household, error) {
+	var household models.Household
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT id, name, created_by, created_at FROM households WHERE id = $1
+	`, householdID).Scan(&household.ID, &household.Name, &household.CreatedBy, &household.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household: %w", err)
+	}
+
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT hm.user_id, u.email, hm.role, hm.status, hm.joined_at
+		FROM household_members hm
+		JOIN users u ON u.id = hm.user_id
+		WHERE hm.household_id = $1
+		ORDER BY hm.created_at
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household members: %w", err)
+	}
+	defer rows.Close()
+
+	household.Members = []models.HouseholdMember{}
+	for rows.Next() {
+		var m models.HouseholdMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.Status, &m.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan household member: %w", err)
+		}
+		household.Members = append(household.Members, m)
+	}
+	return &household, rows.Err()
+}
+
+func (s *householdStore) ListForUser(ctx context.Context, userID string) ([]models.Household, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT h.id, h.name, h.created_by, h.created_at
+		FROM households h
+		JOIN household_members hm ON hm.household_id = h.id
+		WHERE hm.user_id = $1
+		ORDER BY h.created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query households: %w", err)
+	}
+	defer rows.Close()
+
+	households := []models.Household{}
+	for rows.Next() {
+		var h models.Household
+		if err := rows.Scan(&h.ID, &h.Name, &h.CreatedBy, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan household: %w", err)
+		}
+		households = append(households, h)
+	}
+	return households, rows.Err()
+}
+
+func (s *householdStore) Delete(ctx context.Context, householdID string) error {
+	tag, err := s.db.Pool.Exec(ctx, "DELETE FROM households WHERE id = $1", householdID)
+	if err != nil {
+		return fmt.Errorf("failed to delete household: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *householdStore) Member(ctx context.Context, householdID, userID string) (*models.HouseholdMember, error) {
+	m := models.HouseholdMember{UserID: userID}
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT role, status, joined_at FROM household_members
+		WHERE household_id = $1 AND user_id = $2
+	`, householdID, userID).Scan(&m.Role, &m.Status, &m.JoinedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household member: %w", err)
+	}
+	return &m, nil
+}
+
+func (s *householdStore) Invite(ctx context.Context, householdID, userID, invitedBy string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO household_members (household_id, user_id, invited_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (household_id, user_id) DO NOTHING
+	`, householdID, userID, invitedBy)
+	if err != nil {
+		return fmt.Errorf("failed to invite household member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *householdStore) Join(ctx context.Context, householdID, userID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE household_members SET status = 'active', joined_at = NOW()
+		WHERE household_id = $1 AND user_id = $2 AND status = 'invited'
+	`, householdID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to join household: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *householdStore) RemoveMember(ctx context.Context, householdID, userID string) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		tag, err := s.db.Writer(ctx).Exec(ctx, `
+			DELETE FROM household_members WHERE household_id = $1 AND user_id = $2
+		`, householdID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove household member: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		// Settings for the member's accounts go with them
+		_, err = s.db.Writer(ctx).Exec(ctx, `
+			DELETE FROM household_account_visibility hav
+			USING accounts a
+			WHERE hav.household_id = $1 AND hav.account_id = a.id AND a.user_id = $2
+		`, householdID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to clear account visibility: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *householdStore) Visibility(ctx context.Context, householdID string) (map[string]string, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT account_id, visibility FROM household_account_visibility WHERE household_id = $1
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account visibility: %w", err)
+	}
+	defer rows.Close()
+
+	visibility := make(map[string]string)
+	for rows.Next() {
+		var accountID, v string
+		if err := rows.Scan(&accountID, &v); err != nil {
+			return nil, fmt.Errorf("failed to scan account visibility: %w", err)
+		}
+		visibility[accountID] = v
+	}
+	return visibility, rows.Err()
+}
+
+func (s *householdStore) SetVisibility(ctx context.Context, householdID, userID, accountID, visibility string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO household_account_visibility (household_id, account_id, visibility)
+		SELECT $1, a.id, $4 FROM accounts a
+		WHERE a.id = $2 AND a.user_id = $3 AND a.deleted_at IS NULL
+		ON CONFLICT (household_id, account_id)
+		DO UPDATE SET visibility = EXCLUDED.visibility, updated_at = NOW()
+	`, householdID, accountID, userID, visibility)
+	if err != nil {
+		return fmt.Errorf("failed to set account visibility: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *householdStore) UpsertBudget(ctx context.Context, budget *models.Budget, createdBy string) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO household_budgets (household_id, category, monthly_limit, created_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (household_id, category)
+		DO UPDATE SET monthly_limit = EXCLUDED.monthly_limit, updated_at = NOW()
+		RETURNING id, created_at
+	`, budget.HouseholdID, budget.Category, budget.MonthlyLimit, createdBy).Scan(&budget.ID, &budget.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save budget: %w", err)
+	}
+	return nil
+}
+
+func (s *householdStore) Budgets(ctx context.Context, householdID string) ([]models.Budget, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, household_id, category, monthly_limit, created_at
+		FROM household_budgets
+		WHERE household_id = $1
+		ORDER BY category
+	`, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := []models.Budget{}
+	for rows.Next() {
+		var b models.Budget
+		if err := rows.Scan(&b.ID, &b.HouseholdID, &b.Category, &b.MonthlyLimit, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+func (s *householdStore) DeleteBudget(ctx context.Context, householdID, budgetID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM household_budgets WHERE id = $1 AND household_id = $2
+	`, budgetID, householdID)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,567 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// EncryptedToken is a Plaid item's encrypted access token
+type EncryptedToken struct {
+	ID    string
+	Token []byte
+}
+
+// ItemHealth summarises a Plaid item's recent sync history
+type ItemHealth struct {
+	ID              string     `json:"id"`
+	UserID          *string    `json:"user_id"`
+	InstitutionName *string    `json:"institution_name"`
+	Status          string     `json:"status"`
+	LastSyncAt      *time.Time `json:"last_sync_at"`
+	LastJobStatus   *string    `json:"last_job_status"`
+	LastJobError    *string    `json:"last_job_error"`
+	LastJobAt       *time.Time `json:"last_job_at"`
+	FailedJobs7d    int        `json:"failed_jobs_7d"`
+	Healthy         bool       `json:"healthy"`
+}
+
+// SyncOverview summarises sync jobs since a point in time, for operators
+// checking that ingestion is healthy
+type SyncOverview struct {
+	Since        time.Time          `json:"since"`
+	Statuses     []SyncStatusCount  `json:"statuses"`
+	Durations    []SyncDuration     `json:"durations"`
+	Institutions []InstitutionSyncs `json:"institutions"`
+	WebhookLag   WebhookLag         `json:"webhook_lag"`
+}
+
+// SyncStatusCount counts one job type's sync jobs in one status
+type SyncStatusCount struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// SyncDuration is how long one job type's completed syncs ran
+type SyncDuration struct {
+	Type       string  `json:"type"`
+	Completed  int     `json:"completed"`
+	AvgSeconds float64 `json:"avg_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+}
+
+// InstitutionSyncs is the share of an institution's finished syncs that
+// failed for good
+type InstitutionSyncs struct {
+	InstitutionName string  `json:"institution_name"`
+	Items           int     `json:"items"`
+	Finished        int     `json:"finished"`
+	Failed          int     `json:"failed"`
+	FailureRate     float64 `json:"failure_rate"`
+}
+
+// WebhookLag is the time from receiving a Plaid webhook to its data being
+// committed, measured on webhook jobs that completed
+type WebhookLag struct {
+	Count      int      `json:"count"`
+	AvgSeconds *float64 `json:"avg_seconds"`
+	P95Seconds *float64 `json:"p95_seconds"`
+	MaxSeconds *float64 `json:"max_seconds"`
+}
+
+// WebhookLagSample is one Plaid webhook's time from receipt to its data
+// being persisted
+type WebhookLagSample struct {
+	PlaidItemID string // Plaid's item_id, as the webhook gave it
+	WebhookCode string
+	ReceivedAt  time.Time
+	PersistedAt time.Time
+	SLOBreached bool
+}
+
+// ItemWebhookLag summarises one item's webhook lag
+type ItemWebhookLag struct {
+	PlaidItemID    string    `json:"plaid_item_id"`
+	Count          int       `json:"count"`
+	P50Seconds     float64   `json:"p50_seconds"`
+	P95Seconds     float64   `json:"p95_seconds"`
+	MaxSeconds     float64   `json:"max_seconds"`
+	SLOBreaches    int       `json:"slo_breaches"`
+	LastReceivedAt time.Time `json:"last_received_at"`
+}
+
+// ItemStore reads and writes linked Plaid items (bank connections)
+type ItemStore interface {
+	// Create stores a newly linked item and returns its ID
+	Create(ctx context.Context, item NewItem) (string, error)
+	// AccessToken returns the encrypted access token of a user's item, or ErrNotFound
+	AccessToken(ctx context.Context, itemID, userID string) ([]byte, error)
+	// AccountType returns the type and subtype of an account on a user's
+	// item, or ErrNotFound
+	AccountType(ctx context.Context, itemID, accountID, userID string) (accountType, subtype string, err error)
+	// MarkError flags the item a Plaid webhook reported an error for
+	MarkError(ctx context.Context, plaidItemID string) error
+	// ItemID returns the ID and owner of the item Plaid names plaidItemID,
+	// or ErrNotFound
+	ItemID(ctx context.Context, plaidItemID string) (string, string, error)
+	// Consent returns a user's item's consent, or ErrNotFound
+	Consent(ctx context.Context, itemID, userID string) (*models.ItemConsent, error)
+	// ListConsents returns the consent of each of a user's items, oldest
+	// first
+	ListConsents(ctx context.Context, userID string) ([]models.ItemConsent, error)
+	// UpdateConsent replaces a user's item's consented products and
+	// expiration, or returns ErrNotFound
+	UpdateConsent(ctx context.Context, itemID, userID string, products []string, expiresAt *time.Time) (*models.ItemConsent, error)
+	// RecordConsentExpiration sets when the item Plaid names plaidItemID
+	// loses consent, reporting whether that changed, or returns ErrNotFound
+	RecordConsentExpiration(ctx context.Context, plaidItemID string, expiresAt time.Time) (*models.ItemConsent, bool, error)
+	// Health lists items with their latest job, least healthy first; an
+	// empty status matches all items
+	Health(ctx context.Context, status string, limit, offset int) ([]ItemHealth, error)
+	// ListTokens returns up to limit encrypted tokens after afterID, by ID
+	ListTokens(ctx context.Context, afterID string, limit int) ([]EncryptedToken, error)
+	// UpdateToken replaces an item's encrypted access token
+	UpdateToken(ctx context.Context, token EncryptedToken) error
+	// RecordSync advances an item's sync watermark: its transactions cursor
+	// and last sync time. An empty cursor keeps the current one. Call it in
+	// the sync's transaction so the watermark only moves if the synced data
+	// commits.
+	RecordSync(ctx context.Context, itemID, cursor string) error
+	// SyncOverview aggregates the given sync job types created since since;
+	// webhookType is the type whose jobs measure webhook lag
+	SyncOverview(ctx context.Context, since time.Time, syncTypes []string, webhookType string) (*SyncOverview, error)
+	// RecordWebhookLag stores how long a webhook took to be persisted
+	RecordWebhookLag(ctx context.Context, sample WebhookLagSample) error
+	// WebhookLag summarises each item's webhook lag since since, slowest
+	// p95 first
+	WebhookLag(ctx context.Context, since time.Time, limit int) ([]ItemWebhookLag, error)
+}
+
+type itemStore struct {
+	db *database.Database
+}
+
+// NewItem is a newly linked item with the products it was consented to
+type NewItem struct {
+	UserID           string
+	PlaidItemID      string
+	EncryptedToken   []byte
+	InstitutionID    string
+	InstitutionName  string
+	Products         []string
+	ConsentExpiresAt *time.Time
+}
+
+// NewItemStore creates a Postgres-backed Plaid item store
+func NewItemStore(db *database.Database) ItemStore {
+	return &itemStore{db: db}
+}
+
+func (s *itemStore) Create(ctx context.Context, item NewItem) (string, error) {
+	products := item.Products
+	if products == nil {
+		products = []string{}
+	}
+
+	var itemID string
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO plaid_items (user_id, plaid_item_id, access_token_enc, institution_id, institution_name,
+			status, consented_products, consent_expires_at, consent_updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, 'active', $6, $7, NOW())
+		RETURNING id
+	`, item.UserID, item.PlaidItemID, item.EncryptedToken, item.InstitutionID, item.InstitutionName,
+		products, item.ConsentExpiresAt).Scan(&itemID)
+	if err != nil {
+		return "", fmt.Errorf("failed to store Plaid item: %w", err)
+	}
+	return itemID, nil
+}
+
+func (s *itemStore) AccessToken(ctx context.Context, itemID, userID string) ([]byte, error) {
+	var encryptedToken []byte
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT access_token_enc FROM plaid_items WHERE id = $1 AND user_id = $2",
+		itemID, userID).Scan(&encryptedToken)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Plaid item: %w", err)
+	}
+	return encryptedToken, nil
+}
+
+func (s *itemStore) AccountType(ctx context.Context, itemID, accountID, userID string) (string, string, error) {
+	var accountType string
+	var subtype *string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT type, subtype FROM accounts
+		WHERE id = $1 AND plaid_item_id = $2 AND user_id = $3
+	`, accountID, itemID, userID).Scan(&accountType, &subtype)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query account: %w", err)
+	}
+	if subtype == nil {
+		return accountType, "", nil
+	}
+	return accountType, *subtype, nil
+}
+
+func (s *itemStore) MarkError(ctx context.Context, plaidItemID string) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"UPDATE plaid_items SET status = 'error', updated_at = NOW() WHERE plaid_item_id = $1",
+		plaidItemID,
+	)
+	return err
+}
+
+func (s *itemStore) ItemID(ctx context.Context, plaidItemID string) (string, string, error) {
+	var itemID, userID string
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT id, user_id FROM plaid_items WHERE plaid_item_id = $1", plaidItemID,
+	).Scan(&itemID, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query plaid item: %w", err)
+	}
+	return itemID, userID, nil
+}
+
+const consentColumns = `id, user_id, institution_name, status, consented_products,
+	consent_expires_at, consent_updated_at`
+
+func scanConsent(row pgx.Row, extra ...interface{}) (*models.ItemConsent, error) {
+	var c models.ItemConsent
+	dest := append([]interface{}{&c.ItemID, &c.UserID, &c.InstitutionName, &c.Status,
+		&c.ConsentedProducts, &c.ConsentExpiresAt, &c.ConsentUpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *itemStore) Consent(ctx context.Context, itemID, userID string) (*models.ItemConsent, error) {
+	c, err := scanConsent(s.db.Pool.QueryRow(ctx,
+		"SELECT "+consentColumns+" FROM plaid_items WHERE id = $1 AND user_id = $2",
+		itemID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item consent: %w", err)
+	}
+	return c, nil
+}
+
+func (s *itemStore) ListConsents(ctx context.Context, userID string) ([]models.ItemConsent, error) {
+	rows, err := s.db.Pool.Query(ctx,
+		"SELECT "+consentColumns+" FROM plaid_items WHERE user_id = $1 ORDER BY created_at",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item consents: %w", err)
+	}
+	defer rows.Close()
+
+	consents := []models.ItemConsent{}
+	for rows.Next() {
+		c, err := scanConsent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item consent: %w", err)
+		}
+		consents = append(consents, *c)
+	}
+	return consents, rows.Err()
+}
+
+func (s *itemStore) UpdateConsent(ctx context.Context, itemID, userID string, products []string, expiresAt *time.Time) (*models.ItemConsent, error) {
+	c, err := scanConsent(s.db.Pool.QueryRow(ctx, `
+		UPDATE plaid_items
+		SET consented_products = $3, consent_expires_at = $4, consent_updated_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+consentColumns,
+		itemID, userID, products, expiresAt))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update item consent: %w", err)
+	}
+	return c, nil
+}
+
+func (s *itemStore) RecordConsentExpiration(ctx context.Context, plaidItemID string, expiresAt time.Time) (*models.ItemConsent, bool, error) {
+	var changed bool
+	c, err := scanConsent(s.db.Pool.QueryRow(ctx, `
+		UPDATE plaid_items p
+		SET consent_expires_at = $2, consent_updated_at = NOW(), updated_at = NOW()
+		FROM (
+			SELECT id, consent_expires_at AS previous FROM plaid_items
+			WHERE plaid_item_id = $1
+			FOR UPDATE
+		) old
+		WHERE p.id = old.id
+		RETURNING p.id, p.user_id, p.institution_name, p.status, p.consented_products,
+			p.consent_expires_at, p.consent_updated_at, old.previous IS DISTINCT FROM $2
+	`, plaidItemID, expiresAt), &changed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record consent expiration: %w", err)
+	}
+	return c, changed, nil
+}
+
+func (s *itemStore) Health(ctx context.Context, status string, limit, offset int) ([]ItemHealth, error) {
+	query := `
+		SELECT pi.id, pi.user_id, pi.institution_name, pi.status, pi.last_sync_at,
+		       lj.status AS last_job_status, lj.error_message AS last_job_error,
+		       lj.created_at AS last_job_at,
+		       (SELECT COUNT(*) FROM jobs j
+		         WHERE j.plaid_item_id = pi.id AND j.status = 'failed'
+		           AND j.created_at >= NOW() - INTERVAL '7 days') AS failed_jobs_7d
+		FROM plaid_items pi
+		LEFT JOIN LATERAL (
+			SELECT status, error_message, created_at
+			FROM jobs
+			WHERE plaid_item_id = pi.id
+			ORDER BY created_at DESC
+			LIMIT 1
+		) lj ON true
+	`
+
+	args := []interface{}{}
+	argIndex := 1
+
+	if status != "" {
+		query += fmt.Sprintf(" WHERE pi.status = $%d", argIndex)
+		args = append(args, status)
+		argIndex++
+	}
+
+	query += " ORDER BY failed_jobs_7d DESC, pi.last_sync_at ASC NULLS FIRST"
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item health: %w", err)
+	}
+	defer rows.Close()
+
+	var items []ItemHealth
+	for rows.Next() {
+		var item ItemHealth
+		err := rows.Scan(&item.ID, &item.UserID, &item.InstitutionName, &item.Status, &item.LastSyncAt,
+			&item.LastJobStatus, &item.LastJobError, &item.LastJobAt, &item.FailedJobs7d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *itemStore) ListTokens(ctx context.Context, afterID string, limit int) ([]EncryptedToken, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, access_token_enc FROM plaid_items
+		WHERE id > $1 ORDER BY id LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plaid items: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []EncryptedToken
+	for rows.Next() {
+		var t EncryptedToken
+		if err := rows.Scan(&t.ID, &t.Token); err != nil {
+			return nil, fmt.Errorf("failed to scan plaid item: %w", err)
+		}
+		batch = append(batch, t)
+	}
+	return batch, rows.Err()
+}
+
+func (s *itemStore) UpdateToken(ctx context.Context, token EncryptedToken) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"UPDATE plaid_items SET access_token_enc = $2 WHERE id = $1", token.ID, token.Token)
+	if err != nil {
+		return fmt.Errorf("failed to update plaid item %s: %w", token.ID, err)
+	}
+	return nil
+}
+
+func (s *itemStore) RecordSync(ctx context.Context, itemID, cursor string) error {
+	_, err := s.db.Writer(ctx).Exec(ctx,
+		"UPDATE plaid_items SET cursor = COALESCE(NULLIF($2, ''), cursor), last_sync_at = NOW(), updated_at = NOW() WHERE id = $1",
+		itemID, cursor)
+	if err != nil {
+		return fmt.Errorf("failed to record sync of plaid item %s: %w", itemID, err)
+	}
+	return nil
+}
+
+func (s *itemStore) SyncOverview(ctx context.Context, since time.Time, syncTypes []string, webhookType string) (*SyncOverview, error) {
+	overview := &SyncOverview{
+		Since:        since,
+		Statuses:     []SyncStatusCount{},
+		Durations:    []SyncDuration{},
+		Institutions: []InstitutionSyncs{},
+	}
+
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT job_type, status, COUNT(*)
+		FROM jobs
+		WHERE job_type = ANY($1) AND created_at >= $2
+		GROUP BY job_type, status
+		ORDER BY job_type, status
+	`, syncTypes, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sync jobs: %w", err)
+	}
+	for rows.Next() {
+		var c SyncStatusCount
+		if err := rows.Scan(&c.Type, &c.Status, &c.Count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan sync job count: %w", err)
+		}
+		overview.Statuses = append(overview.Statuses, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count sync jobs: %w", err)
+	}
+
+	rows, err = s.db.Reader(ctx).Query(ctx, `
+		SELECT job_type, COUNT(*),
+		       AVG(EXTRACT(EPOCH FROM completed_at - started_at))::float8,
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM completed_at - started_at))::float8
+		FROM jobs
+		WHERE job_type = ANY($1) AND created_at >= $2
+		  AND status = 'completed' AND started_at IS NOT NULL
+		GROUP BY job_type
+		ORDER BY job_type
+	`, syncTypes, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync durations: %w", err)
+	}
+	for rows.Next() {
+		var d SyncDuration
+		if err := rows.Scan(&d.Type, &d.Completed, &d.AvgSeconds, &d.P95Seconds); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan sync duration: %w", err)
+		}
+		overview.Durations = append(overview.Durations, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sync durations: %w", err)
+	}
+
+	// Webhook jobs name items by Plaid's item_id, so only syncs of our own
+	// items can be attributed to an institution
+	rows, err = s.db.Reader(ctx).Query(ctx, `
+		SELECT COALESCE(pi.institution_name, 'unknown'),
+		       COUNT(DISTINCT pi.id),
+		       COUNT(*) FILTER (WHERE j.status IN ('completed', 'failed')),
+		       COUNT(*) FILTER (WHERE j.status = 'failed')
+		FROM jobs j
+		JOIN plaid_items pi ON pi.id = j.plaid_item_id
+		WHERE j.job_type = ANY($1) AND j.created_at >= $2
+		GROUP BY 1
+		ORDER BY 4 DESC, 1
+	`, syncTypes, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query institution syncs: %w", err)
+	}
+	for rows.Next() {
+		var inst InstitutionSyncs
+		if err := rows.Scan(&inst.InstitutionName, &inst.Items, &inst.Finished, &inst.Failed); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan institution syncs: %w", err)
+		}
+		if inst.Finished > 0 {
+			inst.FailureRate = float64(inst.Failed) / float64(inst.Finished)
+		}
+		overview.Institutions = append(overview.Institutions, inst)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query institution syncs: %w", err)
+	}
+
+	// Jobs are enqueued as webhooks arrive and completed in the transaction
+	// after the synced data commits
+	err = s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT COUNT(*),
+		       AVG(EXTRACT(EPOCH FROM completed_at - created_at))::float8,
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM completed_at - created_at))::float8,
+		       MAX(EXTRACT(EPOCH FROM completed_at - created_at))::float8
+		FROM jobs
+		WHERE job_type = $1 AND created_at >= $2 AND status = 'completed'
+	`, webhookType, since).Scan(&overview.WebhookLag.Count, &overview.WebhookLag.AvgSeconds,
+		&overview.WebhookLag.P95Seconds, &overview.WebhookLag.MaxSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook lag: %w", err)
+	}
+
+	return overview, nil
+}
+
+func (s *itemStore) RecordWebhookLag(ctx context.Context, sample WebhookLagSample) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO webhook_lag (plaid_item_id, webhook_code, received_at, persisted_at, lag_ms, slo_breached)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, sample.PlaidItemID, sample.WebhookCode, sample.ReceivedAt, sample.PersistedAt,
+		sample.PersistedAt.Sub(sample.ReceivedAt).Milliseconds(), sample.SLOBreached)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook lag: %w", err)
+	}
+	return nil
+}
+
+func (s *itemStore) WebhookLag(ctx context.Context, since time.Time, limit int) ([]ItemWebhookLag, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT plaid_item_id, COUNT(*),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY lag_ms)::float8 / 1000,
+		       percentile_cont(0.95) WITHIN GROUP (ORDER BY lag_ms)::float8 / 1000,
+		       MAX(lag_ms)::float8 / 1000,
+		       COUNT(*) FILTER (WHERE slo_breached),
+		       MAX(received_at)
+		FROM webhook_lag
+		WHERE persisted_at >= $1
+		GROUP BY plaid_item_id
+		ORDER BY 4 DESC, 1
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook lag: %w", err)
+	}
+	defer rows.Close()
+
+	items := []ItemWebhookLag{}
+	for rows.Next() {
+		var item ItemWebhookLag
+		if err := rows.Scan(&item.PlaidItemID, &item.Count, &item.P50Seconds, &item.P95Seconds,
+			&item.MaxSeconds, &item.SLOBreaches, &item.LastReceivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook lag: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query webhook lag: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// OrderStore records crypto orders and reads crypto positions
+type OrderStore interface {
+	// Create records a pending order and returns its ID
+	Create(ctx context.Context, req models.CryptoOrderRequest, orderType string) (string, error)
+	// Get returns an order belonging to userID, or ErrNotFound
+	Get(ctx context.Context, orderID, userID string) (*models.CryptoOrder, error)
+	// MarkFilled records an order as completely filled at price
+	MarkFilled(ctx context.Context, orderID string, price decimal.Decimal) error
+	// MarkSubmitted records the broker's ID for a live order
+	MarkSubmitted(ctx context.Context, orderID, brokerOrderID string) error
+	// MarkFailed records why an order could not be placed
+	MarkFailed(ctx context.Context, orderID, message string) error
+	// ListPositions returns a user's crypto positions, largest first
+	ListPositions(ctx context.Context, userID string) ([]models.CryptoPosition, error)
+}
+
+type orderStore struct {
+	db *database.Database
+}
+
+// NewOrderStore creates a Postgres-backed order store
+func NewOrderStore(db *database.Database) OrderStore {
+	return &orderStore{db: db}
+}
+
+func (s *orderStore) Create(ctx context.Context, req models.CryptoOrderRequest, orderType string) (string, error) {
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	var orderID string
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO crypto_orders (user_id, symbol, side, quantity, order_type,
+								 price, status, dry_run, placed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, NOW())
+		RETURNING id
+	`, req.UserID, req.Symbol, req.Side, req.Quantity,
+		orderType, req.Price, dryRun).Scan(&orderID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create order: %w", err)
+	}
+	return orderID, nil
+}
+
+func (s *orderStore) Get(ctx context.Context, orderID, userID string) (*models.CryptoOrder, error) {
+	var order models.CryptoOrder
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, user_id, symbol, side, quantity, order_type, price,
+			   status, dry_run, filled_quantity, average_fill_price,
+			   fees, placed_at, filled_at, error_message
+		FROM crypto_orders
+		WHERE id = $1 AND user_id = $2
+	`, orderID, userID).Scan(
+		&order.ID, &order.UserID, &order.Symbol, &order.Side,
+		&order.Quantity, &order.OrderType, &order.Price,
+		&order.Status, &order.DryRun, &order.FilledQuantity,
+		&order.AverageFillPrice, &order.Fees, &order.PlacedAt,
+		&order.FilledAt, &order.ErrorMessage,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order: %w", err)
+	}
+	return &order, nil
+}
+
+func (s *orderStore) MarkFilled(ctx context.Context, orderID string, price decimal.Decimal) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE crypto_orders
+		SET status = 'filled',
+			filled_quantity = quantity,
+			average_fill_price = $2,
+			filled_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1
+	`, orderID, price)
+	if err != nil {
+		return fmt.Errorf("failed to mark order filled: %w", err)
+	}
+	return nil
+}
+
+func (s *orderStore) MarkSubmitted(ctx context.Context, orderID, brokerOrderID string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE crypto_orders
+		SET robinhood_order_id = $2, status = 'submitted', updated_at = NOW()
+		WHERE id = $1
+	`, orderID, brokerOrderID)
+	if err != nil {
+		return fmt.Errorf("failed to mark order submitted: %w", err)
+	}
+	return nil
+}
+
+func (s *orderStore) MarkFailed(ctx context.Context, orderID, message string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE crypto_orders
+		SET status = 'failed', error_message = $2, updated_at = NOW()
+		WHERE id = $1
+	`, orderID, message)
+	if err != nil {
+		return fmt.Errorf("failed to mark order failed: %w", err)
+	}
+	return nil
+}
+
+func (s *orderStore) ListPositions(ctx context.Context, userID string) ([]models.CryptoPosition, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, symbol, name, quantity, average_price, market_value,
+		       cost_basis, unrealized_pnl, last_price, price_change_24h,
+		       price_change_percent_24h, last_refresh
+		FROM crypto_positions
+		WHERE user_id = $1
+		ORDER BY market_value DESC NULLS LAST
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query crypto positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []models.CryptoPosition
+	for rows.Next() {
+		var pos models.CryptoPosition
+		err := rows.Scan(
+			&pos.ID, &pos.Symbol, &pos.Name, &pos.Quantity,
+			&pos.AveragePrice, &pos.MarketValue, &pos.CostBasis,
+			&pos.UnrealizedPnL, &pos.LastPrice, &pos.PriceChange24h,
+			&pos.PriceChangePercent24h, &pos.LastRefresh,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan crypto position: %w", err)
+		}
+		positions = append(positions, pos)
+	}
+	return positions, rows.Err()
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// testDatabase connects to TEST_DATABASE_URL with migrations applied, or
+// skips the test when it is unset
+func testDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	migrator, err := database.NewMigrator(url)
+	if err != nil {
+		t.Fatalf("failed to initialize migrations: %v", err)
+	}
+	defer migrator.Close()
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	db, err := database.Connect(url, database.Options{MaxConns: 2, MinConns: 1})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// testUser creates a user deleted, with everything it owns, after the test
+func testUser(t *testing.T, db *database.Database, authID string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	var userID string
+	err := db.Pool.QueryRow(ctx,
+		"INSERT INTO users (auth_id) VALUES ($1) RETURNING id", authID+"|"+t.Name(),
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Pool.Exec(context.Background(), "DELETE FROM users WHERE id = $1", userID)
+	})
+	return userID
+}
+
+func TestOrderGetIsScopedToUser(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	orders := NewOrderStore(db)
+
+	owner := testUser(t, db, "owner")
+	other := testUser(t, db, "other")
+
+	orderID, err := orders.Create(ctx, models.CryptoOrderRequest{
+		UserID:   owner,
+		Symbol:   "BTC",
+		Side:     "buy",
+		Quantity: decimal.NewFromInt(1),
+	}, "market")
+	if err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	order, err := orders.Get(ctx, orderID, owner)
+	if err != nil {
+		t.Fatalf("owner's lookup failed: %v", err)
+	}
+	if order.ID != orderID || order.UserID != owner {
+		t.Fatalf("got order %s of %s, want %s of %s", order.ID, order.UserID, orderID, owner)
+	}
+
+	if _, err := orders.Get(ctx, orderID, other); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("another user's lookup: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PeriodStore reads and writes users' custom period definitions
+type PeriodStore interface {
+	// List returns a user's period definitions by name
+	List(ctx context.Context, userID string) ([]models.PeriodDefinition, error)
+	// Create creates a period definition, filling in its ID and creation
+	// time, or returns ErrConflict if the user has one of that name
+	Create(ctx context.Context, userID string, def *models.PeriodDefinition) error
+	// Delete deletes a period definition, or returns ErrNotFound. Budgets
+	// running on it go back to calendar months.
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type periodStore struct {
+	db *database.Database
+}
+
+// NewPeriodStore creates a Postgres-backed period definition store
+func NewPeriodStore(db *database.Database) PeriodStore {
+	return &periodStore{db: db}
+}
+
+func (s *periodStore) List(ctx context.Context, userID string) ([]models.PeriodDefinition, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, name, anchor_date::text, every, unit, created_at
+		FROM period_definitions
+		WHERE user_id = $1
+		ORDER BY lower(name)
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period definitions: %w", err)
+	}
+	defer rows.Close()
+
+	defs := []models.PeriodDefinition{}
+	for rows.Next() {
+		var d models.PeriodDefinition
+		if err := rows.Scan(&d.ID, &d.Name, &d.AnchorDate, &d.Every, &d.Unit, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan period definition: %w", err)
+		}
+		defs = append(defs, d)
+	}
+	return defs, rows.Err()
+}
+
+func (s *periodStore) Create(ctx context.Context, userID string, def *models.PeriodDefinition) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO period_definitions (user_id, name, anchor_date, every, unit)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, lower(name)) DO NOTHING
+		RETURNING id, created_at
+	`, userID, def.Name, def.AnchorDate, def.Every, def.Unit).Scan(&def.ID, &def.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create period definition: %w", err)
+	}
+	return nil
+}
+
+func (s *periodStore) Delete(ctx context.Context, userID, id string) error {
+	return s.db.InTx(ctx, func(ctx context.Context) error {
+		var name string
+		err := s.db.Writer(ctx).QueryRow(ctx, `
+			DELETE FROM period_definitions WHERE id::text = $1 AND user_id = $2 RETURNING name
+		`, id, userID).Scan(&name)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete period definition: %w", err)
+		}
+		if _, err := s.db.Writer(ctx).Exec(ctx, `
+			UPDATE user_preferences SET budget_period = NULL WHERE user_id = $1 AND lower(budget_period) = lower($2)
+		`, userID, name); err != nil {
+			return fmt.Errorf("failed to reset budget period: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// PreferenceStore reads and writes user preferences
+type PreferenceStore interface {
+	// Get returns a user's preferences, or the defaults if they set none
+	Get(ctx context.Context, userID string) (models.UserPreferences, error)
+	// Upsert stores a user's preferences, filling in their update time
+	Upsert(ctx context.Context, prefs *models.UserPreferences) error
+}
+
+type preferenceStore struct {
+	db *database.Database
+}
+
+// NewPreferenceStore creates a Postgres-backed preference store
+func NewPreferenceStore(db *database.Database) PreferenceStore {
+	return &preferenceStore{db: db}
+}
+
+func (s *preferenceStore) Get(ctx context.Context, userID string) (models.UserPreferences, error) {
+	prefs := models.DefaultPreferences(userID)
+	err := s.db.Reader(ctx).QueryRow(ctx, `
+		SELECT timezone, base_currency, week_start, number_format, digest_frequency, fiscal_year_start,
+		       budget_period, updated_at
+		FROM user_preferences
+		WHERE user_id = $1
+	`, userID).Scan(&prefs.Timezone, &prefs.BaseCurrency, &prefs.WeekStart, &prefs.NumberFormat,
+		&prefs.DigestFrequency, &prefs.FiscalYearStart, &prefs.BudgetPeriod, &prefs.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.DefaultPreferences(userID), nil
+	}
+	if err != nil {
+		return prefs, fmt.Errorf("failed to query preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (s *preferenceStore) Upsert(ctx context.Context, prefs *models.UserPreferences) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_preferences (user_id, timezone, base_currency, week_start, number_format, digest_frequency,
+		                              fiscal_year_start, budget_period)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET
+			timezone = EXCLUDED.timezone,
+			base_currency = EXCLUDED.base_currency,
+			week_start = EXCLUDED.week_start,
+			number_format = EXCLUDED.number_format,
+			digest_frequency = EXCLUDED.digest_frequency,
+			fiscal_year_start = EXCLUDED.fiscal_year_start,
+			budget_period = EXCLUDED.budget_period,
+			updated_at = NOW()
+		RETURNING updated_at
+	`, prefs.UserID, prefs.Timezone, prefs.BaseCurrency, prefs.WeekStart, prefs.NumberFormat,
+		prefs.DigestFrequency, prefs.FiscalYearStart, prefs.BudgetPeriod).Scan(&prefs.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Package store holds the SQL behind the HTTP handlers as typed
+// repositories, so handler logic can be exercised against fakes.
+package store
+
+import (
+	"errors"
+
+	"github.com/finagent/ingest/internal/database"
+)
+
+// ErrNotFound is returned when a requested row does not exist or is not
+// visible to the given user
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a row being created already exists
+var ErrConflict = errors.New("already exists")
+
+// Stores bundles the repositories used by the handlers
+type Stores struct {
+	Accounts      AccountStore
+	Transactions  TransactionStore
+	Holdings      HoldingStore
+	Orders        OrderStore
+	Transfers     TransferStore
+	Items         ItemStore
+	Users         UserStore
+	Grants        GrantStore
+	Subscriptions SubscriptionStore
+	History       HistoryStore
+	Preferences   PreferenceStore
+	Periods       PeriodStore
+	Households    HouseholdStore
+}
+
+// New creates Postgres-backed repositories
+func New(db *database.Database) *Stores {
+	return &Stores{
+		Accounts:      NewAccountStore(db),
+		Transactions:  NewTransactionStore(db),
+		Holdings:      NewHoldingStore(db),
+		Orders:        NewOrderStore(db),
+		Transfers:     NewTransferStore(db),
+		Items:         NewItemStore(db),
+		Users:         NewUserStore(db),
+		Grants:        NewGrantStore(db),
+		Subscriptions: NewSubscriptionStore(db),
+		History:       NewHistoryStore(db),
+		Preferences:   NewPreferenceStore(db),
+		Periods:       NewPeriodStore(db),
+		Households:    NewHouseholdStore(db),
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// FailedDelivery is an outbound webhook delivery that exhausted its retries
+type FailedDelivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	UserID         *string    `json:"user_id"`
+	URL            string     `json:"url"`
+	EventType      string     `json:"event_type"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"response_status"`
+	ErrorMessage   *string    `json:"error_message"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// SubscriptionStore manages outbound webhook subscriptions and reads their
+// delivery logs
+type SubscriptionStore interface {
+	// Create stores a subscription, filling in its ID, state and creation time
+	Create(ctx context.Context, sub *models.WebhookSubscription) error
+	// List returns a user's subscriptions, newest first, without secrets
+	List(ctx context.Context, userID string) ([]models.WebhookSubscription, error)
+	// Delete removes a user's subscription, or returns ErrNotFound
+	Delete(ctx context.Context, subscriptionID, userID string) error
+	// ListDeliveries returns a user's subscription's deliveries, newest
+	// first; an empty status matches all deliveries
+	ListDeliveries(ctx context.Context, subscriptionID, userID, status string, limit int) ([]models.WebhookDelivery, error)
+	// ListFailedDeliveries returns failed deliveries across all users
+	ListFailedDeliveries(ctx context.Context, limit, offset int) ([]FailedDelivery, error)
+}
+
+type subscriptionStore struct {
+	db *database.Database
+}
+
+// NewSubscriptionStore creates a Postgres-backed webhook subscription store
+func NewSubscriptionStore(db *database.Database) SubscriptionStore {
+	return &subscriptionStore{db: db}
+}
+
+func (s *subscriptionStore) Create(ctx context.Context, sub *models.WebhookSubscription) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (user_id, url, event_types, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, is_active, created_at
+	`, sub.UserID, sub.URL, sub.EventTypes, sub.Secret).Scan(&sub.ID, &sub.IsActive, &sub.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *subscriptionStore) List(ctx context.Context, userID string) ([]models.WebhookSubscription, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, url, event_types, is_active, created_at
+		FROM webhook_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.WebhookSubscription
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.URL, &sub.EventTypes, &sub.IsActive, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (s *subscriptionStore) Delete(ctx context.Context, subscriptionID, userID string) error {
+	tag, err := s.db.Pool.Exec(ctx,
+		"DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2",
+		subscriptionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *subscriptionStore) ListDeliveries(ctx context.Context, subscriptionID, userID, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT d.id, d.subscription_id, d.event_type, d.status, d.attempts,
+		       d.response_status, d.error_message, d.last_attempt_at,
+		       d.delivered_at, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON d.subscription_id = s.id
+		WHERE d.subscription_id = $1 AND s.user_id = $2
+	`
+
+	args := []interface{}{subscriptionID, userID}
+	argIndex := 3
+
+	if status != "" {
+		query += fmt.Sprintf(" AND d.status = $%d", argIndex)
+		args = append(args, status)
+		argIndex++
+	}
+
+	query += " ORDER BY d.created_at DESC"
+	query += fmt.Sprintf(" LIMIT $%d", argIndex)
+	args = append(args, limit)
+
+	rows, err := s.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(
+			&d.ID, &d.SubscriptionID, &d.EventType, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.ErrorMessage, &d.LastAttemptAt,
+			&d.DeliveredAt, &d.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *subscriptionStore) ListFailedDeliveries(ctx context.Context, limit, offset int) ([]FailedDelivery, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT d.id, d.subscription_id, s.user_id, s.url, d.event_type,
+		       d.attempts, d.response_status, d.error_message,
+		       d.last_attempt_at, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON d.subscription_id = s.id
+		WHERE d.status = 'failed'
+		ORDER BY d.last_attempt_at DESC NULLS LAST
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []FailedDelivery
+	for rows.Next() {
+		var f FailedDelivery
+		err := rows.Scan(&f.ID, &f.SubscriptionID, &f.UserID, &f.URL, &f.EventType,
+			&f.Attempts, &f.ResponseStatus, &f.ErrorMessage, &f.LastAttemptAt, &f.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook failure: %w", err)
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
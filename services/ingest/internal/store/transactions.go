@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/descriptors"
+	"github.com/finagent/ingest/internal/models"
+)
+
+// TransactionFilter selects a user's transactions. Dates are YYYY-MM-DD and
+// inclusive; empty Merchant and Category match everything.
+type TransactionFilter struct {
+	UserID          string
+	AccountID       string // only this account's transactions, when set
+	StartDate       string
+	EndDate         string
+	Merchant        string
+	Category        string
+	ExcludeAccounts []string // accounts whose transactions are left out
+	Analytics       bool     // leave out accounts hidden or excluded from analytics, and refunded charges
+	Located         bool     // only transactions with coordinates
+	Near            *GeoArea // only transactions within the area
+	Kind            string   // only checks or ATM withdrawals, when set to a descriptors kind
+	CheckNumber     string   // only the check of this number, when set
+	ExpenseType     string   // only business or personal expenses, when set
+	Limit           int
+}
+
+// GeoArea is a circle on the map, RadiusKm kilometres around a point
+type GeoArea struct {
+	Lat      float64
+	Lon      float64
+	RadiusKm float64
+}
+
+// haversineKm is the great-circle distance in kilometres from the
+// transaction to the point in the parameters numbered %[1]d (latitude)
+// and %[2]d (longitude)
+const haversineKm = `(2 * 6371 * asin(sqrt(
+	power(sin(radians(t.latitude - $%[1]d) / 2), 2) +
+	cos(radians($%[1]d)) * cos(radians(t.latitude)) * power(sin(radians(t.longitude - $%[2]d) / 2), 2))))`
+
+// TransactionStore reads and writes bank and investment transactions
+type TransactionStore interface {
+	// UpsertBatch inserts or refreshes synced Plaid transactions in bulk and
+	// returns the number of rows written
+	UpsertBatch(ctx context.Context, userID string, txns []models.PlaidTransaction) (int, error)
+	// List returns matching transactions, newest first, leaving out
+	// duplicates of another transaction. For analytics, charges paired
+	// with a refund are left out together with the refund.
+	List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error)
+	// ListInvestment returns investment transactions in the filter's date
+	// range, newest first, leaving out those of duplicate accounts;
+	// AccountID, Merchant, Category and ExcludeAccounts are ignored
+	ListInvestment(ctx context.Context, filter TransactionFilter) ([]models.InvestmentTransaction, error)
+	// MarkRemoved soft-deletes transactions Plaid reported as removed from
+	// an item's accounts and returns how many were still live. Transactions
+	// of other items are left alone. Upserting one again restores it.
+	MarkRemoved(ctx context.Context, itemID string, ids []string) (int, error)
+}
+
+type transactionStore struct {
+	db *database.Database
+}
+
+// NewTransactionStore creates a Postgres-backed transaction store
+func NewTransactionStore(db *database.Database) TransactionStore {
+	return &transactionStore{db: db}
+}
+
+func (s *transactionStore) List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error) {
+	query := `
+		SELECT t.id, t.account_id, t.date, t.amount, t.merchant_name,
+		       t.category, t.category_detailed, t.description, t.is_pending,
+		       a.name as account_name, a.mask as account_mask, a.type as account_type,
+		       t.location_address, t.location_city, t.location_region, t.location_postal_code,
+		       t.location_country, t.latitude, t.longitude, t.check_number, t.is_atm_withdrawal,
+		       t.expense_type, t.personal_finance_category, t.personal_finance_category_detailed
+		FROM transactions t
+		JOIN accounts a ON t.account_id = a.id
+		WHERE t.user_id = $1 AND t.date >= $2 AND t.date <= $3 AND t.deleted_at IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'transaction' AND dl.duplicate_id = t.id AND dl.status <> 'rejected')
+	`
+
+	args := []interface{}{filter.UserID, filter.StartDate, filter.EndDate}
+	argIndex := 4
+
+	if filter.AccountID != "" {
+		query += fmt.Sprintf(" AND t.account_id = $%d", argIndex)
+		args = append(args, filter.AccountID)
+		argIndex++
+	}
+
+	if filter.Merchant != "" {
+		query += fmt.Sprintf(" AND t.merchant_name ILIKE $%d", argIndex)
+		args = append(args, "%"+filter.Merchant+"%")
+		argIndex++
+	}
+
+	if filter.Category != "" {
+		query += fmt.Sprintf(" AND $%d = ANY(t.category)", argIndex)
+		args = append(args, filter.Category)
+		argIndex++
+	}
+
+	if len(filter.ExcludeAccounts) > 0 {
+		query += fmt.Sprintf(" AND NOT (t.account_id = ANY($%d))", argIndex)
+		args = append(args, filter.ExcludeAccounts)
+		argIndex++
+	}
+
+	switch filter.Kind {
+	case descriptors.KindCheck:
+		query += " AND t.check_number IS NOT NULL"
+	case descriptors.KindATM:
+		query += " AND t.is_atm_withdrawal"
+	}
+
+	if filter.CheckNumber != "" {
+		query += fmt.Sprintf(" AND t.check_number = $%d", argIndex)
+		args = append(args, filter.CheckNumber)
+		argIndex++
+	}
+
+	if filter.ExpenseType != "" {
+		query += fmt.Sprintf(" AND t.expense_type = $%d", argIndex)
+		args = append(args, filter.ExpenseType)
+		argIndex++
+	}
+
+	if filter.Analytics {
+		query += ` AND a.hidden = false AND a.exclude_from_analytics = false
+		  AND NOT EXISTS (
+		      SELECT 1 FROM refund_links rl
+		      WHERE (rl.charge_id = t.id OR rl.refund_id = t.id) AND rl.status <> 'rejected')`
+	}
+
+	if filter.Located || filter.Near != nil {
+		query += " AND t.latitude IS NOT NULL AND t.longitude IS NOT NULL"
+	}
+
+	if filter.Near != nil {
+		query += fmt.Sprintf(" AND "+haversineKm+" <= $%[3]d", argIndex, argIndex+1, argIndex+2)
+		args = append(args, filter.Near.Lat, filter.Near.Lon, filter.Near.RadiusKm)
+		argIndex += 3
+	}
+
+	query += " ORDER BY t.date DESC, t.amount DESC"
+	query += fmt.Sprintf(" LIMIT $%d", argIndex)
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.Reader(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var txn models.Transaction
+		var loc models.Location
+		err := rows.Scan(
+			&txn.ID, &txn.AccountID, &txn.Date, &txn.Amount,
+			&txn.MerchantName, &txn.Category, &txn.CategoryDetailed,
+			&txn.Description, &txn.IsPending,
+			&txn.AccountName, &txn.AccountMask, &txn.AccountType,
+			&loc.Address, &loc.City, &loc.Region, &loc.PostalCode,
+			&loc.Country, &loc.Lat, &loc.Lon, &txn.CheckNumber, &txn.IsATMWithdrawal,
+			&txn.ExpenseType, &txn.PersonalFinanceCategory, &txn.PersonalFinanceCategoryDetailed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if loc != (models.Location{}) {
+			txn.Location = &loc
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions, rows.Err()
+}
+
+func (s *transactionStore) ListInvestment(ctx context.Context, filter TransactionFilter) ([]models.InvestmentTransaction, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT it.id, it.account_id, it.date, it.name, it.quantity,
+		       it.amount, it.price, it.fees, it.type, it.subtype,
+		       s.symbol, s.name as security_name,
+		       a.name as account_name, a.mask as account_mask
+		FROM investment_transactions it
+		LEFT JOIN securities s ON it.security_id = s.id
+		JOIN accounts a ON it.account_id = a.id
+		WHERE it.user_id = $1 AND it.date >= $2 AND it.date <= $3 AND it.deleted_at IS NULL
+		  AND ($5 = false OR (a.hidden = false AND a.exclude_from_analytics = false))
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'account' AND dl.duplicate_id = it.account_id AND dl.status <> 'rejected')
+		ORDER BY it.date DESC
+		LIMIT $4
+	`, filter.UserID, filter.StartDate, filter.EndDate, filter.Limit, filter.Analytics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query investment transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []models.InvestmentTransaction
+	for rows.Next() {
+		var txn models.InvestmentTransaction
+		err := rows.Scan(
+			&txn.ID, &txn.AccountID, &txn.Date, &txn.Name,
+			&txn.Quantity, &txn.Amount, &txn.Price, &txn.Fees,
+			&txn.Type, &txn.Subtype, &txn.Symbol, &txn.SecurityName,
+			&txn.AccountName, &txn.AccountMask,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan investment transaction: %w", err)
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions, rows.Err()
+}
+
+func (s *transactionStore) UpsertBatch(ctx context.Context, userID string, txns []models.PlaidTransaction) (int, error) {
+	columns := []string{"id", "user_id", "account_id", "date", "amount", "merchant_name",
+		"category", "category_detailed", "description", "location", "payment_meta",
+		"account_owner", "is_pending", "raw", "location_address", "location_city",
+		"location_region", "location_postal_code", "location_country", "latitude", "longitude",
+		"check_number", "is_atm_withdrawal", "personal_finance_category", "personal_finance_category_detailed"}
+
+	rows := make([][]interface{}, 0, len(txns))
+	for _, txn := range txns {
+		date, err := time.Parse("2006-01-02", txn.Date)
+		if err != nil {
+			return 0, fmt.Errorf("invalid date for transaction %s: %w", txn.ID, err)
+		}
+		raw, err := json.Marshal(txn)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode transaction %s: %w", txn.ID, err)
+		}
+		loc := txn.Location
+		if loc == nil {
+			loc = &models.Location{}
+		}
+		details := descriptors.Parse(txn)
+		var pfc, pfcDetailed *string
+		if txn.PersonalFinanceCategory != nil {
+			pfc, pfcDetailed = &txn.PersonalFinanceCategory.Primary, &txn.PersonalFinanceCategory.Detailed
+		}
+		rows = append(rows, []interface{}{
+			txn.ID, userID, txn.AccountID, date, txn.Amount, txn.MerchantName,
+			txn.Category, txn.CategoryDetailed, txn.Name, txn.Location, txn.PaymentMeta,
+			txn.AccountOwner, txn.Pending, raw, loc.Address, loc.City,
+			loc.Region, loc.PostalCode, loc.Country, loc.Lat, loc.Lon,
+			details.CheckNumber, details.IsATMWithdrawal, pfc, pfcDetailed,
+		})
+	}
+
+	affected, err := bulkUpsert(ctx, s.db, "transactions", columns, rows, `
+		INSERT INTO transactions (id, user_id, account_id, date, amount, merchant_name,
+								  category, category_detailed, description, location,
+								  payment_meta, account_owner, is_pending, raw, location_address,
+								  location_city, location_region, location_postal_code,
+								  location_country, latitude, longitude, check_number,
+								  is_atm_withdrawal, personal_finance_category,
+								  personal_finance_category_detailed, updated_at)
+		SELECT DISTINCT ON (id) id, user_id, account_id, date, amount, merchant_name,
+		       category, category_detailed, description, location,
+		       payment_meta, account_owner, is_pending, raw, location_address,
+		       location_city, location_region, location_postal_code,
+		       location_country, latitude, longitude, check_number,
+		       is_atm_withdrawal, personal_finance_category,
+		       personal_finance_category_detailed, NOW()
+		FROM transactions_stage
+		ORDER BY id
+		ON CONFLICT (id)
+		DO UPDATE SET
+			date = EXCLUDED.date,
+			amount = EXCLUDED.amount,
+			merchant_name = EXCLUDED.merchant_name,
+			category = EXCLUDED.category,
+			category_detailed = EXCLUDED.category_detailed,
+			description = EXCLUDED.description,
+			location = EXCLUDED.location,
+			location_address = EXCLUDED.location_address,
+			location_city = EXCLUDED.location_city,
+			location_region = EXCLUDED.location_region,
+			location_postal_code = EXCLUDED.location_postal_code,
+			location_country = EXCLUDED.location_country,
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			check_number = EXCLUDED.check_number,
+			is_atm_withdrawal = EXCLUDED.is_atm_withdrawal,
+			-- Rows Plaid sends without one keep the category migrated
+			-- from their legacy categories
+			personal_finance_category = COALESCE(EXCLUDED.personal_finance_category, transactions.personal_finance_category),
+			personal_finance_category_detailed = COALESCE(EXCLUDED.personal_finance_category_detailed, transactions.personal_finance_category_detailed),
+			payment_meta = EXCLUDED.payment_meta,
+			account_owner = EXCLUDED.account_owner,
+			is_pending = EXCLUDED.is_pending,
+			raw = EXCLUDED.raw,
+			deleted_at = NULL,
+			updated_at = NOW()
+	`)
+	return int(affected), err
+}
+
+func (s *transactionStore) MarkRemoved(ctx context.Context, itemID string, ids []string) (int, error) {
+	tag, err := s.db.Writer(ctx).Exec(ctx, `
+		UPDATE transactions SET deleted_at = NOW()
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		  AND account_id IN (SELECT id FROM accounts WHERE plaid_item_id = $2)
+	`, ids, itemID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark transactions removed: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
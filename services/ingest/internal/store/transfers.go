@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// TransferStore records ACH transfers and their status
+type TransferStore interface {
+	// Create records an authorized or declined transfer, setting its ID
+	// and timestamps
+	Create(ctx context.Context, transfer *models.Transfer) error
+	// Get returns a transfer belonging to userID, or ErrNotFound
+	Get(ctx context.Context, transferID, userID string) (*models.Transfer, error)
+	// List returns a page of a user's transfers, newest first
+	List(ctx context.Context, userID string, limit, offset int) ([]models.Transfer, error)
+	// Confirm moves a user's authorized transfer to pending, once, if it
+	// is confirmed before it expires; otherwise it returns ErrNotFound
+	Confirm(ctx context.Context, transferID, userID string) (*models.Transfer, error)
+	// MarkSubmitted records Plaid's ID for a confirmed live transfer
+	MarkSubmitted(ctx context.Context, transferID, plaidTransferID string) error
+	// SetStatus sets a transfer's status and, for failures, why
+	SetStatus(ctx context.Context, transferID, status string, failureReason *string) error
+	// ApplyEvent applies a Plaid transfer event to the transfer it is for,
+	// returning the updated transfer, or ErrNotFound for unknown transfers
+	ApplyEvent(ctx context.Context, eventID int64, plaidTransferID, status string, failureReason *string) (*models.Transfer, error)
+	// LastEventID returns the latest Plaid transfer event applied, or 0
+	LastEventID(ctx context.Context) (int64, error)
+}
+
+type transferStore struct {
+	db *database.Database
+}
+
+// NewTransferStore creates a Postgres-backed transfer store
+func NewTransferStore(db *database.Database) TransferStore {
+	return &transferStore{db: db}
+}
+
+// transferColumns reads authorized transfers past their confirmation
+// deadline as expired
+const transferColumns = `id, user_id, plaid_item_id, account_id, type, network, amount, description,
+	CASE WHEN status = 'authorized' AND confirm_by <= NOW() THEN 'expired' ELSE status END,
+	dry_run, authorization_id, decision_rationale, plaid_transfer_id, failure_reason, confirm_by, confirmed_at, created_at, updated_at`
+
+func scanTransfer(row pgx.Row) (*models.Transfer, error) {
+	var t models.Transfer
+	err := row.Scan(&t.ID, &t.UserID, &t.ItemID, &t.AccountID, &t.Type, &t.Network, &t.Amount,
+		&t.Description, &t.Status, &t.DryRun, &t.AuthorizationID, &t.DecisionRationale,
+		&t.PlaidTransferID, &t.FailureReason, &t.ConfirmBy, &t.ConfirmedAt, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *transferStore) Create(ctx context.Context, t *models.Transfer) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO transfers (user_id, plaid_item_id, account_id, type, network, amount, description,
+			status, dry_run, authorization_id, decision_rationale, confirm_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at
+	`, t.UserID, t.ItemID, t.AccountID, t.Type, t.Network, t.Amount, t.Description,
+		t.Status, t.DryRun, t.AuthorizationID, t.DecisionRationale, t.ConfirmBy).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+	return nil
+}
+
+func (s *transferStore) Get(ctx context.Context, transferID, userID string) (*models.Transfer, error) {
+	t, err := scanTransfer(s.db.Pool.QueryRow(ctx,
+		"SELECT "+transferColumns+" FROM transfers WHERE id::text = $1 AND user_id = $2",
+		transferID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer: %w", err)
+	}
+	return t, nil
+}
+
+func (s *transferStore) List(ctx context.Context, userID string, limit, offset int) ([]models.Transfer, error) {
+	rows, err := s.db.Pool.Query(ctx, "SELECT "+transferColumns+`
+		FROM transfers WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []models.Transfer{}
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, *t)
+	}
+	return transfers, rows.Err()
+}
+
+func (s *transferStore) Confirm(ctx context.Context, transferID, userID string) (*models.Transfer, error) {
+	t, err := scanTransfer(s.db.Pool.QueryRow(ctx, `
+		UPDATE transfers SET status = 'pending', confirmed_at = NOW()
+		WHERE id::text = $1 AND user_id = $2 AND status = 'authorized' AND confirm_by > NOW()
+		RETURNING `+transferColumns,
+		transferID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm transfer: %w", err)
+	}
+	return t, nil
+}
+
+func (s *transferStore) MarkSubmitted(ctx context.Context, transferID, plaidTransferID string) error {
+	_, err := s.db.Pool.Exec(ctx,
+		"UPDATE transfers SET plaid_transfer_id = $2 WHERE id = $1", transferID, plaidTransferID)
+	if err != nil {
+		return fmt.Errorf("failed to mark transfer submitted: %w", err)
+	}
+	return nil
+}
+
+func (s *transferStore) SetStatus(ctx context.Context, transferID, status string, failureReason *string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE transfers SET status = $2, failure_reason = COALESCE($3, failure_reason)
+		WHERE id = $1
+	`, transferID, status, failureReason)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer status: %w", err)
+	}
+	return nil
+}
+
+func (s *transferStore) ApplyEvent(ctx context.Context, eventID int64, plaidTransferID, status string, failureReason *string) (*models.Transfer, error) {
+	t, err := scanTransfer(s.db.Pool.QueryRow(ctx, `
+		UPDATE transfers
+		SET status = $3, failure_reason = COALESCE($4, failure_reason), last_event_id = $2
+		WHERE plaid_transfer_id = $1 AND (last_event_id IS NULL OR last_event_id < $2)
+		RETURNING `+transferColumns,
+		plaidTransferID, eventID, status, failureReason))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply transfer event: %w", err)
+	}
+	return t, nil
+}
+
+func (s *transferStore) LastEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT COALESCE(MAX(last_event_id), 0) FROM transfers").Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query transfer events: %w", err)
+	}
+	return id, nil
+}
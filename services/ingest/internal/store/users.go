@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// UserSummary is a user with counts of their linked items and accounts
+type UserSummary struct {
+	ID           string    `json:"id"`
+	AuthID       string    `json:"auth_id"`
+	Email        *string   `json:"email"`
+	CreatedAt    time.Time `json:"created_at"`
+	ItemCount    int       `json:"item_count"`
+	AccountCount int       `json:"account_count"`
+}
+
+// UserStore looks up users
+type UserStore interface {
+	// IDByAuthID maps an identity provider subject to the internal user ID
+	IDByAuthID(ctx context.Context, authID string) (string, error)
+	// IDByEmail finds a user by email, case-insensitively, or ErrNotFound
+	IDByEmail(ctx context.Context, email string) (string, error)
+	// List returns users with item and account counts, newest first
+	List(ctx context.Context, limit, offset int) ([]UserSummary, error)
+	// Create registers a user, filling in their ID, onboarding state and
+	// timestamps, or returns ErrConflict if the auth ID is taken
+	Create(ctx context.Context, user *models.User) error
+	// Get returns a user's profile, or ErrNotFound
+	Get(ctx context.Context, userID string) (*models.User, error)
+	// Update saves a user's email, display name and onboarding state
+	Update(ctx context.Context, user *models.User) error
+	// AdvanceOnboarding moves a user from one onboarding state to the
+	// next, doing nothing if they are in any other state
+	AdvanceOnboarding(ctx context.Context, userID, from, to string) error
+	// LinkedItems returns a user's items with their account counts,
+	// oldest first
+	LinkedItems(ctx context.Context, userID string) ([]models.LinkedItem, error)
+}
+
+type userStore struct {
+	db *database.Database
+}
+
+// NewUserStore creates a Postgres-backed user store
+func NewUserStore(db *database.Database) UserStore {
+	return &userStore{db: db}
+}
+
+func (s *userStore) IDByAuthID(ctx context.Context, authID string) (string, error) {
+	var userID string
+	err := s.db.Pool.QueryRow(ctx, "SELECT id FROM users WHERE auth_id = $1", authID).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve user: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *userStore) IDByEmail(ctx context.Context, email string) (string, error) {
+	var userID string
+	err := s.db.Pool.QueryRow(ctx,
+		"SELECT id FROM users WHERE lower(email) = lower($1)", email).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up user: %w", err)
+	}
+	return userID, nil
+}
+
+func (s *userStore) List(ctx context.Context, limit, offset int) ([]UserSummary, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT u.id, u.auth_id, u.email, u.created_at,
+		       (SELECT COUNT(*) FROM plaid_items pi WHERE pi.user_id = u.id) AS item_count,
+		       (SELECT COUNT(*) FROM accounts a WHERE a.user_id = u.id AND a.is_closed = false AND a.deleted_at IS NULL) AS account_count
+		FROM users u
+		ORDER BY u.created_at DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var u UserSummary
+		if err := rows.Scan(&u.ID, &u.AuthID, &u.Email, &u.CreatedAt, &u.ItemCount, &u.AccountCount); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *userStore) Create(ctx context.Context, user *models.User) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO users (auth_id, email, display_name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (auth_id) DO NOTHING
+		RETURNING id, onboarding_state, created_at, updated_at
+	`, user.AuthID, user.Email, user.DisplayName).Scan(&user.ID, &user.OnboardingState, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (s *userStore) Get(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, auth_id, email, display_name, onboarding_state, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&user.ID, &user.AuthID, &user.Email, &user.DisplayName, &user.OnboardingState, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return &user, nil
+}
+
+func (s *userStore) Update(ctx context.Context, user *models.User) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE users
+		SET email = $2, display_name = $3, onboarding_state = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at
+	`, user.ID, user.Email, user.DisplayName, user.OnboardingState).Scan(&user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+func (s *userStore) AdvanceOnboarding(ctx context.Context, userID, from, to string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE users SET onboarding_state = $3, updated_at = NOW()
+		WHERE id = $1 AND onboarding_state = $2
+	`, userID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to update onboarding state: %w", err)
+	}
+	return nil
+}
+
+func (s *userStore) LinkedItems(ctx context.Context, userID string) ([]models.LinkedItem, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT pi.id, pi.institution_name, pi.status, pi.last_sync_at, pi.created_at,
+		       (SELECT COUNT(*) FROM accounts a WHERE a.plaid_item_id = pi.id AND a.is_closed = false AND a.deleted_at IS NULL)
+		FROM plaid_items pi
+		WHERE pi.user_id = $1
+		ORDER BY pi.created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.LinkedItem{}
+	for rows.Next() {
+		var item models.LinkedItem
+		if err := rows.Scan(&item.ID, &item.InstitutionName, &item.Status, &item.LastSyncAt, &item.CreatedAt, &item.AccountCount); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -3,27 +3,57 @@ package tracing
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// InitTracer initializes the tracer with Jaeger exporter
-func InitTracer(serviceName, jaegerEndpoint string) (*tracesdk.TracerProvider, error) {
-	// Create Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
+// Exporters
+const (
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterJaeger   = "jaeger" // deprecated collector protocol, kept for older deployments
+	ExporterNone     = "none"
+)
+
+// Options selects the span exporter and sampling
+type Options struct {
+	Exporter       string
+	OTLPEndpoint   string            // host:port of the collector
+	OTLPHeaders    map[string]string // e.g. an API key for a hosted backend
+	OTLPInsecure   bool              // plaintext instead of TLS
+	JaegerEndpoint string            // collector URL for the jaeger exporter
+	SampleRatio    float64           // share of new traces sampled; callers' decisions are kept
+}
+
+// InitTracer initializes the tracer with the configured exporter. It returns
+// a nil provider when tracing is disabled.
+func InitTracer(ctx context.Context, serviceName string, opts Options) (*tracesdk.TracerProvider, error) {
+	exp, err := newExporter(ctx, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Jaeger exporter: %w", err)
+		return nil, err
+	}
+	if exp == nil {
+		return nil, nil
 	}
 
 	// Create tracer provider
 	tp := tracesdk.NewTracerProvider(
 		tracesdk.WithBatcher(exp),
+		tracesdk.WithSampler(tracesdk.ParentBased(tracesdk.TraceIDRatioBased(opts.SampleRatio))),
 		tracesdk.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
 			semconv.ServiceNameKey.String(serviceName),
@@ -31,28 +61,128 @@ func InitTracer(serviceName, jaegerEndpoint string) (*tracesdk.TracerProvider, e
 		)),
 	)
 
-	// Set global tracer provider
+	// Set global tracer provider and propagate W3C trace context and baggage
 	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
 
 	return tp, nil
 }
 
+func newExporter(ctx context.Context, opts Options) (tracesdk.SpanExporter, error) {
+	switch opts.Exporter {
+	case ExporterOTLPGRPC:
+		clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.OTLPEndpoint)}
+		if len(opts.OTLPHeaders) > 0 {
+			clientOpts = append(clientOpts, otlptracegrpc.WithHeaders(opts.OTLPHeaders))
+		}
+		if opts.OTLPInsecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize OTLP gRPC exporter: %w", err)
+		}
+		return exp, nil
+	case ExporterOTLPHTTP:
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.OTLPEndpoint)}
+		if len(opts.OTLPHeaders) > 0 {
+			clientOpts = append(clientOpts, otlptracehttp.WithHeaders(opts.OTLPHeaders))
+		}
+		if opts.OTLPInsecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize OTLP HTTP exporter: %w", err)
+		}
+		return exp, nil
+	case ExporterJaeger:
+		exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(opts.JaegerEndpoint)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Jaeger exporter: %w", err)
+		}
+		return exp, nil
+	case ExporterNone, "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q; use %s, %s, %s or %s",
+			opts.Exporter, ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterJaeger, ExporterNone)
+	}
+}
+
+type operationKey struct{}
+
+// WithOperation names the work ctx belongs to, e.g. a background job, for
+// spans and logs that have no HTTP route
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Operation names the work ctx belongs to: the name set by WithOperation,
+// else the method and route pattern of the request being handled
+func Operation(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return rctx.RouteMethod + " " + pattern
+		}
+	}
+	return "unknown"
+}
+
 // StartSpan starts a new span with the given name
-func StartSpan(ctx context.Context, spanName string) (context.Context, trace.Span) {
+func StartSpan(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
 	tracer := otel.Tracer("finagent-ingest")
-	return tracer.Start(ctx, spanName)
+	return tracer.Start(ctx, spanName, opts...)
+}
+
+// StartClientSpan starts a span for a call to an external API, e.g.
+// "plaid /accounts/get"
+func StartClientSpan(ctx context.Context, spanName string) (context.Context, trace.Span) {
+	return StartSpan(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// Middleware starts a server span per request, continuing the trace of a
+// caller that sent a traceparent header. Spans are renamed to the chi
+// route pattern once routing has matched one.
+func Middleware(serviceName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					span := trace.SpanFromContext(r.Context())
+					span.SetName(r.Method + " " + pattern)
+					span.SetAttributes(semconv.HTTPRouteKey.String(pattern))
+				}
+			}
+		})
+		return otelhttp.NewHandler(named, serviceName,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}))
+	}
+}
+
+// HTTPClient returns a client whose requests are traced and carry the
+// trace context of the request's context to the server
+func HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
 }
 
-// AddSpanEvent adds an event to the current span
+// AddSpanEvent adds an event with the given attributes to a span
 func AddSpanEvent(span trace.Span, name string, attributes map[string]interface{}) {
-	attrs := make([]trace.EventOption, 0, len(attributes))
-	for _, value := range attributes {
-		// Convert value to string for simplicity
-		attrs = append(attrs, trace.WithAttributes(
-			semconv.HTTPMethodKey.String(fmt.Sprintf("%v", value)),
-		))
+	attrs := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		attrs = append(attrs, attribute.String(key, fmt.Sprintf("%v", value)))
 	}
-	span.AddEvent(name, attrs...)
+	span.AddEvent(name, trace.WithAttributes(attrs...))
 }
 
 // SetSpanError sets error information on a span
@@ -0,0 +1,333 @@
+// Package usage meters each user's API calls, item syncs and orders for
+// fair-use quotas and future billing tiers. Counts for the current day live
+// in Redis, where quotas are enforced, and are flushed to Postgres for
+// reporting.
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/go-redis/redis/v8"
+)
+
+// Metered quantities
+const (
+	MetricAPICalls = "api_calls" // authorized API requests acting on the user's data
+	MetricSyncs    = "syncs"     // initial and manual item syncs run
+	MetricOrders   = "orders"    // crypto order requests, live or dry run
+)
+
+// Metrics lists every metered quantity
+var Metrics = []string{MetricAPICalls, MetricSyncs, MetricOrders}
+
+// dayLayout formats days, which follow UTC
+const dayLayout = "2006-01-02"
+
+// redisTTL keeps a day's counters around long enough to be flushed after
+// it ends
+const redisTTL = 3 * 24 * time.Hour
+
+// ErrQuotaExceeded is returned when a user has used up a daily quota
+var ErrQuotaExceeded = errors.New("usage quota exceeded")
+
+// consume adds ARGV[2] to the user's count of metric ARGV[1] for the day
+// unless that would exceed the limit ARGV[3] (0 for none), registers the
+// user for flushing, and returns whether it did and the resulting count
+var consume = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local n = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+if limit > 0 and used + n > limit then
+	return {0, used}
+end
+used = redis.call('HINCRBY', KEYS[1], ARGV[1], n)
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('SADD', KEYS[2], ARGV[5])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return {1, used}
+`)
+
+// Options sets the daily quotas and how often counts reach Postgres
+type Options struct {
+	Quotas        map[string]int64 // daily limit per metric; 0 or missing for none
+	FlushInterval time.Duration
+}
+
+// Quota is a user's standing against one daily quota
+type Quota struct {
+	Metric    string    `json:"metric"`
+	Limit     int64     `json:"limit"` // 0 when unlimited
+	Used      int64     `json:"used"`
+	Remaining *int64    `json:"remaining,omitempty"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Meter counts usage and enforces quotas
+type Meter struct {
+	db    *database.Database
+	redis *redis.Client
+	opts  Options
+}
+
+// NewMeter creates a new usage meter
+func NewMeter(db *database.Database, redisClient *redis.Client, opts Options) *Meter {
+	return &Meter{db: db, redis: redisClient, opts: opts}
+}
+
+// Record counts n uses of metric without enforcing its quota, for usage
+// that has already happened
+func (m *Meter) Record(ctx context.Context, userID, metric string, n int64) error {
+	_, _, err := m.add(ctx, userID, metric, n, 0)
+	return err
+}
+
+// Consume counts one use of metric if the user's daily quota allows it,
+// returning ErrQuotaExceeded with the quota when it does not
+func (m *Meter) Consume(ctx context.Context, userID, metric string) (*Quota, error) {
+	limit := m.opts.Quotas[metric]
+	allowed, used, err := m.add(ctx, userID, metric, 1, limit)
+	if err != nil {
+		return nil, err
+	}
+	quota := m.quota(metric, used, time.Now())
+	if !allowed {
+		return quota, ErrQuotaExceeded
+	}
+	return quota, nil
+}
+
+// Check returns the user's standing against a daily quota without using
+// it, with ErrQuotaExceeded once it is used up
+func (m *Meter) Check(ctx context.Context, userID, metric string) (*Quota, error) {
+	now := time.Now()
+	used, err := m.redis.HGet(ctx, countsKey(userID, now), metric).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	quota := m.quota(metric, used, now)
+	if quota.Limit > 0 && used >= quota.Limit {
+		return quota, ErrQuotaExceeded
+	}
+	return quota, nil
+}
+
+// Quotas returns the user's standing against every daily quota
+func (m *Meter) Quotas(ctx context.Context, userID string) ([]Quota, error) {
+	now := time.Now()
+	counts, err := m.redisCounts(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	quotas := make([]Quota, 0, len(Metrics))
+	for _, metric := range Metrics {
+		quotas = append(quotas, *m.quota(metric, counts[metric], now))
+	}
+	return quotas, nil
+}
+
+func (m *Meter) add(ctx context.Context, userID, metric string, n, limit int64) (bool, int64, error) {
+	now := time.Now()
+	res, err := consume.Run(ctx, m.redis, []string{countsKey(userID, now), usersKey(now)},
+		metric, n, limit, int(redisTTL.Seconds()), userID).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to record usage: %w", err)
+	}
+	return res[0] == 1, res[1], nil
+}
+
+func (m *Meter) quota(metric string, used int64, now time.Time) *Quota {
+	quota := &Quota{
+		Metric: metric,
+		Limit:  m.opts.Quotas[metric],
+		Used:   used,
+		Reset:  startOfDay(now).AddDate(0, 0, 1),
+	}
+	if quota.Limit > 0 {
+		remaining := max(quota.Limit-used, 0)
+		quota.Remaining = &remaining
+	}
+	return quota
+}
+
+func (m *Meter) redisCounts(ctx context.Context, userID string, day time.Time) (map[string]int64, error) {
+	fields, err := m.redis.HGetAll(ctx, countsKey(userID, day)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	counts := make(map[string]int64, len(fields))
+	for metric, value := range fields {
+		var n int64
+		if _, err := fmt.Sscan(value, &n); err == nil {
+			counts[metric] = n
+		}
+	}
+	return counts, nil
+}
+
+// Run flushes counts to Postgres every FlushInterval until ctx is done
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Flush what was counted since the last tick
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := m.Flush(flushCtx); err != nil {
+				slog.Error("Failed to flush usage", "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := m.Flush(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to flush usage", "error", err)
+			}
+		}
+	}
+}
+
+// Flush copies yesterday's and today's counts from Redis to Postgres.
+// Counts only grow, so every instance may flush and the larger count wins.
+func (m *Meter) Flush(ctx context.Context) error {
+	now := time.Now()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		users, err := m.redis.SMembers(ctx, usersKey(day)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to list metered users: %w", err)
+		}
+		for _, userID := range users {
+			counts, err := m.redisCounts(ctx, userID, day)
+			if err != nil {
+				return err
+			}
+			for metric, count := range counts {
+				// Service principals may name users that don't exist
+				_, err := m.db.Pool.Exec(ctx, `
+					INSERT INTO usage_daily (user_id, day, metric, count)
+					SELECT id, $2, $3, $4 FROM users WHERE id::text = $1
+					ON CONFLICT (user_id, day, metric)
+					DO UPDATE SET count = GREATEST(usage_daily.count, EXCLUDED.count), updated_at = NOW()
+				`, userID, startOfDay(day), metric, count)
+				if err != nil {
+					return fmt.Errorf("failed to store usage: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Day is a user's usage on one day
+type Day struct {
+	Date   string           `json:"date"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// Report is a user's usage over a range of days
+type Report struct {
+	UserID string           `json:"user_id"`
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Totals map[string]int64 `json:"totals"`
+	Days   []Day            `json:"days"`
+	Quotas []Quota          `json:"quotas"`
+}
+
+// Report returns the user's usage per day from from to to inclusive, with
+// counts not yet flushed read from Redis
+func (m *Meter) Report(ctx context.Context, userID string, from, to time.Time) (*Report, error) {
+	from, to = startOfDay(from), startOfDay(to)
+
+	days := make(map[string]map[string]int64)
+	rows, err := m.db.Reader(ctx).Query(ctx, `
+		SELECT day, metric, count
+		FROM usage_daily
+		WHERE user_id::text = $1 AND day BETWEEN $2 AND $3
+	`, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	for rows.Next() {
+		var day time.Time
+		var metric string
+		var count int64
+		if err := rows.Scan(&day, &metric, &count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		date := day.Format(dayLayout)
+		if days[date] == nil {
+			days[date] = make(map[string]int64)
+		}
+		days[date][metric] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+
+	now := time.Now()
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		day = startOfDay(day)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+		counts, err := m.redisCounts(ctx, userID, day)
+		if err != nil {
+			return nil, err
+		}
+		date := day.Format(dayLayout)
+		for metric, count := range counts {
+			if days[date] == nil {
+				days[date] = make(map[string]int64)
+			}
+			days[date][metric] = max(days[date][metric], count)
+		}
+	}
+
+	report := &Report{
+		UserID: userID,
+		From:   from.Format(dayLayout),
+		To:     to.Format(dayLayout),
+		Totals: make(map[string]int64, len(Metrics)),
+		Days:   []Day{},
+	}
+	for _, metric := range Metrics {
+		report.Totals[metric] = 0
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		counts, ok := days[day.Format(dayLayout)]
+		if !ok {
+			continue
+		}
+		for metric, count := range counts {
+			report.Totals[metric] += count
+		}
+		report.Days = append(report.Days, Day{Date: day.Format(dayLayout), Counts: counts})
+	}
+
+	report.Quotas, err = m.Quotas(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, mo, d := t.UTC().Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+}
+
+func countsKey(userID string, day time.Time) string {
+	return "usage:" + day.UTC().Format(dayLayout) + ":user:" + userID
+}
+
+func usersKey(day time.Time) string {
+	return "usage:" + day.UTC().Format(dayLayout) + ":users"
+}
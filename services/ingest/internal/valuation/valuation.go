@@ -0,0 +1,232 @@
+// Package valuation keeps the end-of-day history of users' holdings.
+// Plaid reports only a holding's latest institution price, so each
+// trading day, once the US market has closed, a job records every
+// holding's quantity, price and value. Holdings history charts and
+// period returns are read back from those records.
+package valuation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/jobs"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/period"
+	"github.com/shopspring/decimal"
+)
+
+// Valuations are taken for a trading day from closeHour in the market's
+// timezone, once brokers have published closing prices
+const (
+	marketTimezone = "America/New_York"
+	closeHour      = 18
+)
+
+// Options sets how often the scheduler checks for a day to value. 0
+// disables it.
+type Options struct {
+	Interval time.Duration
+}
+
+// Input is the input of a valuation job
+type Input struct {
+	Date string `json:"date"`
+}
+
+// Service schedules and records end-of-day valuations and reads them back
+type Service struct {
+	db    *database.Database
+	locks *locks.Locker
+	jobs  *jobs.Manager
+	opts  Options
+}
+
+// NewService creates a valuation service. When locker is set, only one
+// instance schedules valuations at a time.
+func NewService(db *database.Database, locker *locks.Locker, jobManager *jobs.Manager, opts Options) *Service {
+	return &Service{db: db, locks: locker, jobs: jobManager, opts: opts}
+}
+
+// Date returns the latest trading day closed at now: today after the
+// close, otherwise the weekday before. Market holidays are not skipped;
+// their valuations repeat the day before's prices.
+func Date(now time.Time) time.Time {
+	loc, err := time.LoadLocation(marketTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if now.Hour() < closeHour {
+		day = day.AddDate(0, 0, -1)
+	}
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// Run queues the valuation of each trading day once it closes, checking
+// every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "holding_valuations")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock valuation scheduling", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	date := Date(time.Now()).Format(period.DateLayout)
+	var due bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM holdings WHERE deleted_at IS NULL)
+		   AND NOT EXISTS (SELECT 1 FROM holding_valuations WHERE date = $1)
+		   AND NOT EXISTS (
+		       SELECT 1 FROM jobs
+		       WHERE job_type = $2 AND params->>'date' = $1 AND status IN ('pending', 'running', 'retryable'))
+	`, date, jobs.TypeHoldingValuation).Scan(&due)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check for holdings to value", "error", err)
+		return
+	}
+	if !due {
+		return
+	}
+	if _, err := s.jobs.Enqueue(ctx, jobs.Params{Type: jobs.TypeHoldingValuation, Input: Input{Date: date}}); err != nil {
+		slog.ErrorContext(ctx, "Failed to queue holding valuation", "date", date, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Queued holding valuation", "date", date)
+}
+
+// Snapshot records every current holding's end-of-day valuation for
+// date, returning how many it recorded. Holdings already valued that day
+// keep their first valuation.
+func (s *Service) Snapshot(ctx context.Context, date string) (int64, error) {
+	tag, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO holding_valuations (holding_id, date, user_id, account_id, security_id, symbol, security_name,
+			quantity, price, price_as_of, value, cost_basis, currency)
+		SELECT h.id, $1::date, h.user_id, h.account_id, h.security_id, s.symbol, s.name,
+		       h.quantity, h.institution_price, h.institution_price_as_of,
+		       coalesce(h.institution_value, h.quantity * h.institution_price), h.cost_basis,
+		       coalesce(h.unofficial_currency_code, s.currency)
+		FROM holdings h
+		JOIN securities s ON h.security_id = s.id
+		WHERE h.deleted_at IS NULL AND h.user_id IS NOT NULL AND h.account_id IS NOT NULL
+		ON CONFLICT (holding_id, date) DO NOTHING
+	`, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record holding valuations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// History returns a user's daily totals and each holding's valuations
+// from start to end, leaving out accounts hidden or excluded from
+// analytics and duplicates of other accounts
+func (s *Service) History(ctx context.Context, userID, start, end string) ([]models.ValuationDay, []models.HoldingHistory, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT v.holding_id, v.date::text, v.account_id, v.symbol, v.security_name, v.currency,
+		       v.quantity, v.price, v.price_as_of::text, v.value, v.cost_basis
+		FROM holding_valuations v
+		JOIN accounts a ON v.account_id = a.id
+		WHERE v.user_id = $1 AND v.date BETWEEN $2 AND $3
+		  AND NOT a.hidden AND NOT a.exclude_from_analytics
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_links dl
+		      WHERE dl.record_type = 'account' AND dl.duplicate_id = v.account_id AND dl.status <> 'rejected')
+		ORDER BY v.date, v.holding_id
+	`, userID, start, end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query holding valuations: %w", err)
+	}
+	defer rows.Close()
+
+	days := []models.ValuationDay{}
+	holdings := []models.HoldingHistory{}
+	index := map[string]int{}
+	for rows.Next() {
+		var holding models.HoldingHistory
+		var v models.HoldingValuation
+		if err := rows.Scan(&holding.HoldingID, &v.Date, &holding.AccountID, &holding.Symbol,
+			&holding.SecurityName, &holding.Currency, &v.Quantity, &v.Price, &v.PriceAsOf,
+			&v.Value, &v.CostBasis); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan holding valuation: %w", err)
+		}
+
+		if len(days) == 0 || days[len(days)-1].Date != v.Date {
+			days = append(days, models.ValuationDay{Date: v.Date, Value: decimal.Zero, CostBasis: decimal.Zero})
+		}
+		day := &days[len(days)-1]
+		day.HoldingCount++
+		if v.Value != nil {
+			day.Value = day.Value.Add(*v.Value)
+		}
+		if v.CostBasis != nil {
+			day.CostBasis = day.CostBasis.Add(*v.CostBasis)
+		}
+
+		i, ok := index[holding.HoldingID]
+		if !ok {
+			i = len(holdings)
+			index[holding.HoldingID] = i
+			holdings = append(holdings, holding)
+		}
+		holdings[i].Valuations = append(holdings[i].Valuations, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query holding valuations: %w", err)
+	}
+
+	for i := range holdings {
+		holdings[i].PriceReturnPct = priceReturn(holdings[i].Valuations)
+	}
+	return days, holdings, nil
+}
+
+// priceReturn is the percent change from the first priced valuation to
+// the last. Unlike a change in value, it is not moved by buying or
+// selling shares.
+func priceReturn(valuations []models.HoldingValuation) *float64 {
+	var first, last *decimal.Decimal
+	for _, v := range valuations {
+		if v.Price == nil {
+			continue
+		}
+		if first == nil {
+			first = v.Price
+		}
+		last = v.Price
+	}
+	if first == nil || !first.IsPositive() {
+		return nil
+	}
+	pct, _ := last.Sub(*first).Div(*first).Mul(decimal.NewFromInt(100)).Round(2).Float64()
+	return &pct
+}
@@ -0,0 +1,442 @@
+// Package watchlist keeps users' watchlists of stock and crypto symbols.
+// A background worker quotes every watched symbol through the price
+// providers and raises a notification when one of a user's alerts on it
+// fires: a price above or below a threshold, or a daily move of at least
+// a percentage since the UTC day's first quote.
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/locks"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/finagent/ingest/internal/notifications"
+	"github.com/finagent/ingest/internal/prices"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shopspring/decimal"
+)
+
+// Limits on a user's watchlist
+const (
+	MaxItems         = 50
+	MaxAlertsPerItem = 10
+)
+
+// quoteCurrency is the currency prices are quoted in
+const quoteCurrency = "USD"
+
+var (
+	// ErrNotFound is returned for a watchlist item or alert that does not
+	// exist or belongs to another user
+	ErrNotFound = errors.New("watchlist item not found")
+	// ErrConflict is returned when adding a symbol already on the watchlist
+	ErrConflict = errors.New("symbol already on watchlist")
+	// ErrLimit is returned when a watchlist or item has no room left
+	ErrLimit = errors.New("watchlist limit reached")
+)
+
+// Options sets how often watched symbols are quoted. 0 disables the
+// refresh worker.
+type Options struct {
+	Interval time.Duration
+}
+
+// Service stores watchlists and refreshes their prices and alerts
+type Service struct {
+	db     *database.Database
+	locks  *locks.Locker
+	prices prices.Router
+	notify *notifications.Store
+	opts   Options
+}
+
+// NewService creates a watchlist service. When locker is set, only one
+// instance refreshes prices at a time.
+func NewService(db *database.Database, locker *locks.Locker, router prices.Router, notify *notifications.Store, opts Options) *Service {
+	return &Service{db: db, locks: locker, prices: router, notify: notify, opts: opts}
+}
+
+// Run refreshes watched prices every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runOnce(ctx context.Context) {
+	if s.locks != nil {
+		lock, err := s.locks.Acquire(ctx, "watchlist")
+		if errors.Is(err, locks.ErrLocked) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to lock watchlist refresh", "error", err)
+			return
+		}
+		defer lock.Release(context.Background())
+		ctx = lock.Context()
+	}
+
+	fired, err := s.Refresh(ctx, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to refresh watchlist prices", "error", err)
+	}
+	if fired > 0 {
+		slog.InfoContext(ctx, "Fired watchlist alerts", "count", fired)
+	}
+}
+
+// Refresh quotes every watched symbol once and evaluates the alerts on
+// it, returning how many alerts fired. A symbol that cannot be quoted
+// keeps its last price and is tried again next time.
+func (s *Service) Refresh(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT DISTINCT asset_type, symbol FROM watchlist_items`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query watched symbols: %w", err)
+	}
+	type symbol struct{ assetType, symbol string }
+	var symbols []symbol
+	for rows.Next() {
+		var sym symbol
+		if err := rows.Scan(&sym.assetType, &sym.symbol); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan watched symbol: %w", err)
+		}
+		symbols = append(symbols, sym)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query watched symbols: %w", err)
+	}
+
+	fired := 0
+	for _, sym := range symbols {
+		if ctx.Err() != nil {
+			return fired, ctx.Err()
+		}
+		n, err := s.refreshSymbol(ctx, sym.assetType, sym.symbol, now)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to refresh watched symbol", "asset_type", sym.assetType, "symbol", sym.symbol, "error", err)
+			continue
+		}
+		fired += n
+	}
+	return fired, nil
+}
+
+// refreshSymbol stores a fresh quote on every item watching a symbol and
+// evaluates their alerts
+func (s *Service) refreshSymbol(ctx context.Context, assetType, symbol string, now time.Time) (int, error) {
+	price, err := s.prices.Price(ctx, assetType, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		UPDATE watchlist_items
+		SET last_price = $3, price_updated_at = $4,
+		    day_open_price = CASE WHEN day_open_date IS DISTINCT FROM $5::date THEN $3 ELSE day_open_price END,
+		    day_open_date = $5::date
+		WHERE asset_type = $1 AND symbol = $2
+	`, assetType, symbol, price, now, now.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to store price of %s: %w", symbol, err)
+	}
+	return s.evaluate(ctx, assetType, symbol, price, now)
+}
+
+// pendingAlert is an enabled alert on a freshly quoted symbol
+type pendingAlert struct {
+	models.WatchlistAlert
+	userID  string
+	dayOpen *decimal.Decimal
+}
+
+// evaluate fires the alerts on a symbol whose conditions have become
+// true, and re-arms those whose conditions no longer hold
+func (s *Service) evaluate(ctx context.Context, assetType, symbol string, price decimal.Decimal, now time.Time) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT a.id, a.item_id, a.user_id, a.condition, a.threshold, a.triggered, i.day_open_price
+		FROM watchlist_alerts a
+		JOIN watchlist_items i ON a.item_id = i.id
+		WHERE i.asset_type = $1 AND i.symbol = $2 AND a.enabled
+	`, assetType, symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query watchlist alerts: %w", err)
+	}
+	var alerts []pendingAlert
+	for rows.Next() {
+		var a pendingAlert
+		if err := rows.Scan(&a.ID, &a.ItemID, &a.userID, &a.Condition, &a.Threshold, &a.Triggered, &a.dayOpen); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan watchlist alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query watchlist alerts: %w", err)
+	}
+
+	fired := 0
+	for _, a := range alerts {
+		met := Met(a.Condition, a.Threshold, price, a.dayOpen)
+		if met == a.Triggered {
+			continue
+		}
+		if !met {
+			if _, err := s.db.Pool.Exec(ctx, `UPDATE watchlist_alerts SET triggered = false WHERE id = $1`, a.ID); err != nil {
+				return fired, fmt.Errorf("failed to re-arm watchlist alert: %w", err)
+			}
+			continue
+		}
+
+		// Only the refresh that flips the alert notifies
+		tag, err := s.db.Pool.Exec(ctx, `
+			UPDATE watchlist_alerts SET triggered = true, last_triggered_at = $2
+			WHERE id = $1 AND NOT triggered
+		`, a.ID, now)
+		if err != nil {
+			return fired, fmt.Errorf("failed to record watchlist alert: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		if err := s.notify.Create(ctx, alertNotification(a, symbol, price)); err != nil {
+			slog.ErrorContext(ctx, "Failed to notify watchlist alert", "alert_id", a.ID, "error", err)
+			continue
+		}
+		fired++
+	}
+	return fired, nil
+}
+
+// Met reports whether an alert's condition holds at price. A daily move
+// needs the day's opening price and is measured in percent either way.
+func Met(condition string, threshold, price decimal.Decimal, dayOpen *decimal.Decimal) bool {
+	switch condition {
+	case models.WatchAbove:
+		return price.GreaterThanOrEqual(threshold)
+	case models.WatchBelow:
+		return price.LessThanOrEqual(threshold)
+	case models.WatchDailyMove:
+		if dayOpen == nil || !dayOpen.IsPositive() {
+			return false
+		}
+		return dailyChange(price, *dayOpen).Abs().GreaterThanOrEqual(threshold)
+	}
+	return false
+}
+
+// dailyChange is the percent change from open to price
+func dailyChange(price, open decimal.Decimal) decimal.Decimal {
+	return price.Sub(open).Div(open).Mul(decimal.NewFromInt(100))
+}
+
+// alertNotification describes a fired alert to its user
+func alertNotification(a pendingAlert, symbol string, price decimal.Decimal) *models.Notification {
+	quote := digest.FormatAmount(price, quoteCurrency, "")
+	n := &models.Notification{
+		UserID: a.userID,
+		Type:   notifications.TypePrice,
+		Metadata: map[string]interface{}{
+			"watchlist_alert_id": a.ID,
+			"watchlist_item_id":  a.ItemID,
+			"symbol":             symbol,
+			"condition":          a.Condition,
+			"threshold":          a.Threshold,
+			"price":              price,
+		},
+	}
+
+	switch a.Condition {
+	case models.WatchDailyMove:
+		change := dailyChange(price, *a.dayOpen).Round(2)
+		direction := "up"
+		if change.IsNegative() {
+			direction = "down"
+		}
+		n.Title = fmt.Sprintf("%s is %s %s%% today", symbol, direction, change.Abs().String())
+		n.Message = fmt.Sprintf("%s is at %s, %s %s%% from today's open of %s.",
+			symbol, quote, direction, change.Abs().String(), digest.FormatAmount(*a.dayOpen, quoteCurrency, ""))
+	default:
+		threshold := digest.FormatAmount(a.Threshold, quoteCurrency, "")
+		n.Title = fmt.Sprintf("%s is %s %s", symbol, a.Condition, threshold)
+		n.Message = fmt.Sprintf("%s is at %s, %s your alert at %s.", symbol, quote, a.Condition, threshold)
+	}
+	return n
+}
+
+// List returns a user's watchlist with each item's alerts, by symbol
+func (s *Service) List(ctx context.Context, userID string) ([]models.WatchlistItem, error) {
+	rows, err := s.db.Reader(ctx).Query(ctx, `
+		SELECT id, user_id, symbol, asset_type, last_price, day_open_price, price_updated_at, created_at
+		FROM watchlist_items
+		WHERE user_id = $1
+		ORDER BY symbol, asset_type
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist: %w", err)
+	}
+	items := []models.WatchlistItem{}
+	index := map[string]int{}
+	for rows.Next() {
+		var item models.WatchlistItem
+		if err := rows.Scan(&item.ID, &item.UserID, &item.Symbol, &item.AssetType, &item.LastPrice,
+			&item.DayOpenPrice, &item.PriceUpdatedAt, &item.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan watchlist item: %w", err)
+		}
+		item.Alerts = []models.WatchlistAlert{}
+		if item.LastPrice != nil && item.DayOpenPrice != nil && item.DayOpenPrice.IsPositive() {
+			pct, _ := dailyChange(*item.LastPrice, *item.DayOpenPrice).Round(2).Float64()
+			item.DayChangePct = &pct
+		}
+		index[item.ID] = len(items)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query watchlist: %w", err)
+	}
+
+	rows, err = s.db.Reader(ctx).Query(ctx, `
+		SELECT id, item_id, condition, threshold, enabled, triggered, last_triggered_at, created_at
+		FROM watchlist_alerts
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist alerts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[a.ItemID]; ok {
+			items[i].Alerts = append(items[i].Alerts, *a)
+		}
+	}
+	return items, rows.Err()
+}
+
+// Add puts a symbol on a user's watchlist and quotes it. The symbol is
+// stored upper-cased; a quote that fails is left to the next refresh.
+func (s *Service) Add(ctx context.Context, userID, symbol, assetType string) (*models.WatchlistItem, error) {
+	symbol = strings.ToUpper(symbol)
+	item := &models.WatchlistItem{UserID: userID, Symbol: symbol, AssetType: assetType, Alerts: []models.WatchlistAlert{}}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO watchlist_items (user_id, symbol, asset_type)
+		SELECT $1, $2, $3
+		WHERE (SELECT count(*) FROM watchlist_items WHERE user_id = $1) < $4
+		RETURNING id, created_at
+	`, userID, symbol, assetType, MaxItems).Scan(&item.ID, &item.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrConflict
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLimit
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add watchlist item: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := s.refreshSymbol(ctx, assetType, symbol, now); err != nil {
+		slog.WarnContext(ctx, "Failed to quote watched symbol", "asset_type", assetType, "symbol", symbol, "error", err)
+		return item, nil
+	}
+	err = s.db.Pool.QueryRow(ctx, `
+		SELECT last_price, day_open_price, price_updated_at FROM watchlist_items WHERE id = $1
+	`, item.ID).Scan(&item.LastPrice, &item.DayOpenPrice, &item.PriceUpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist item: %w", err)
+	}
+	return item, nil
+}
+
+// Remove takes an item, and its alerts, off a user's watchlist
+func (s *Service) Remove(ctx context.Context, userID, itemID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM watchlist_items WHERE id = $1 AND user_id = $2`, itemID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove watchlist item: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddAlert sets an alert on one of a user's watchlist items. It fires
+// at the next refresh if its condition already holds.
+func (s *Service) AddAlert(ctx context.Context, userID, itemID, condition string, threshold decimal.Decimal) (*models.WatchlistAlert, error) {
+	var owned bool
+	var alerts int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM watchlist_items WHERE id = $1 AND user_id = $2),
+		       (SELECT count(*) FROM watchlist_alerts WHERE item_id = $1)
+	`, itemID, userID).Scan(&owned, &alerts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist item: %w", err)
+	}
+	if !owned {
+		return nil, ErrNotFound
+	}
+	if alerts >= MaxAlertsPerItem {
+		return nil, ErrLimit
+	}
+
+	row := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO watchlist_alerts (user_id, item_id, condition, threshold)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, item_id, condition, threshold, enabled, triggered, last_triggered_at, created_at
+	`, userID, itemID, condition, threshold)
+	a, err := scanAlert(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add watchlist alert: %w", err)
+	}
+	return a, nil
+}
+
+// RemoveAlert deletes an alert from one of a user's watchlist items
+func (s *Service) RemoveAlert(ctx context.Context, userID, itemID, alertID string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM watchlist_alerts WHERE id = $1 AND item_id = $2 AND user_id = $3
+	`, alertID, itemID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove watchlist alert: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAlert(row pgx.Row) (*models.WatchlistAlert, error) {
+	var a models.WatchlistAlert
+	if err := row.Scan(&a.ID, &a.ItemID, &a.Condition, &a.Threshold, &a.Enabled, &a.Triggered,
+		&a.LastTriggeredAt, &a.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan watchlist alert: %w", err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/tracing"
+)
+
+// Event types that external consumers can subscribe to
+const (
+	EventSyncCompleted       = "sync.completed"
+	EventOrderFilled         = "order.filled"
+	EventInsightCreated      = "insight.created"
+	EventBalanceLow          = "balance.low"
+	EventNotificationCreated = "notification.created"
+	EventTransferUpdated     = "transfer.updated"
+)
+
+// EventTypes lists every supported event type
+var EventTypes = []string{
+	EventSyncCompleted,
+	EventOrderFilled,
+	EventInsightCreated,
+	EventBalanceLow,
+	EventNotificationCreated,
+	EventTransferUpdated,
+}
+
+// Headers set on every outbound delivery
+const (
+	SignatureHeader = "X-FinAgent-Signature"
+	EventHeader     = "X-FinAgent-Event"
+	DeliveryHeader  = "X-FinAgent-Delivery"
+)
+
+// Event is the JSON envelope posted to subscribers
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	UserID    string      `json:"user_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher fans events out to matching subscriptions and delivers them
+type Dispatcher struct {
+	db          *database.Database
+	httpClient  *http.Client
+	maxAttempts int
+	baseBackoff time.Duration
+
+	// inFlight tracks background deliveries so shutdown can wait for them
+	inFlight sync.WaitGroup
+}
+
+// NewDispatcher creates a new webhook dispatcher
+func NewDispatcher(db *database.Database) *Dispatcher {
+	return &Dispatcher{
+		db:          db,
+		httpClient:  tracing.HTTPClient(10 * time.Second),
+		maxAttempts: 5,
+		baseBackoff: 2 * time.Second,
+	}
+}
+
+// IsValidEventType checks if an event type is supported
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSecret returns a random signing secret for a new subscription
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Sign computes the HMAC-SHA256 signature of a payload for the given timestamp
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type subscriptionTarget struct {
+	id     string
+	url    string
+	secret string
+}
+
+// Publish records a delivery for every active subscription of the user that
+// listens to eventType and delivers them in the background
+func (d *Dispatcher) Publish(ctx context.Context, userID, eventType string, data interface{}) error {
+	if !IsValidEventType(eventType) {
+		return fmt.Errorf("unsupported event type: %s", eventType)
+	}
+
+	rows, err := d.db.Pool.Query(ctx, `
+		SELECT id, url, secret
+		FROM webhook_subscriptions
+		WHERE user_id = $1 AND is_active = true AND $2 = ANY(event_types)
+	`, userID, eventType)
+	if err != nil {
+		return fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+
+	var targets []subscriptionTarget
+	for rows.Next() {
+		var t subscriptionTarget
+		if err := rows.Scan(&t.id, &t.url, &t.secret); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	for _, target := range targets {
+		var deliveryID string
+		event := Event{
+			Type:      eventType,
+			UserID:    userID,
+			CreatedAt: time.Now().UTC(),
+			Data:      data,
+		}
+
+		err := d.db.Pool.QueryRow(ctx, `
+			INSERT INTO webhook_deliveries (subscription_id, event_type, payload, status)
+			VALUES ($1, $2, $3, 'pending')
+			RETURNING id
+		`, target.id, eventType, event).Scan(&deliveryID)
+		if err != nil {
+			return fmt.Errorf("failed to record delivery: %w", err)
+		}
+
+		event.ID = deliveryID
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		d.inFlight.Add(1)
+		go func(deliveryID string, target subscriptionTarget) {
+			defer d.inFlight.Done()
+			d.deliver(context.Background(), deliveryID, eventType, target, payload)
+		}(deliveryID, target)
+	}
+
+	return nil
+}
+
+// Wait blocks until in-flight deliveries finish or ctx is done. Deliveries
+// still retrying when ctx expires are left in their recorded state.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver posts the payload with retries and exponential backoff, logging each attempt
+func (d *Dispatcher) deliver(ctx context.Context, deliveryID, eventType string, target subscriptionTarget, payload []byte) {
+	backoff := d.baseBackoff
+
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		statusCode, err := d.send(ctx, deliveryID, eventType, target, payload)
+
+		if err == nil {
+			d.recordAttempt(ctx, deliveryID, "succeeded", attempt, statusCode, "")
+			return
+		}
+
+		status := "retrying"
+		if attempt == d.maxAttempts {
+			status = "failed"
+		}
+		d.recordAttempt(ctx, deliveryID, status, attempt, statusCode, err.Error())
+
+		if attempt < d.maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, deliveryID, eventType string, target subscriptionTarget, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(target.secret, timestamp, payload)))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) recordAttempt(ctx context.Context, deliveryID, status string, attempts, statusCode int, errorMsg string) {
+	var responseStatus *int
+	if statusCode != 0 {
+		responseStatus = &statusCode
+	}
+
+	var errorMessage *string
+	if errorMsg != "" {
+		errorMessage = &errorMsg
+	}
+
+	_, err := d.db.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, error_message = $5,
+			last_attempt_at = NOW(),
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE delivered_at END
+		WHERE id = $1
+	`, deliveryID, status, attempts, responseStatus, errorMessage)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record webhook delivery", "delivery_id", deliveryID, "error", err)
+	}
+}
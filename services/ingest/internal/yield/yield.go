@@ -0,0 +1,210 @@
+// Package yield compares what a user's depository accounts earn with a
+// high-yield savings account, and raises an insight for each account
+// leaving enough interest on the table to be worth moving. An account's
+// APY is the user's, or read from a rate its institution gives in the
+// account's official name, as Plaid does not report yields. Interest is
+// estimated on the current balance over a year; the APY already allows
+// for compounding.
+package yield
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/finagent/ingest/internal/database"
+	"github.com/finagent/ingest/internal/digest"
+	"github.com/finagent/ingest/internal/insights"
+	"github.com/finagent/ingest/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// InsightType is the type of insights about low-yield accounts
+const InsightType = "low_yield"
+
+// DefaultBenchmarkAPY is the high-yield savings rate, in percent, used
+// when none is configured
+const DefaultBenchmarkAPY = 4.0
+
+// minShortfall is the least interest a year an account must miss for an
+// insight to be worth raising
+var minShortfall = decimal.NewFromInt(50)
+
+var hundred = decimal.NewFromInt(100)
+
+// nameRate finds a rate such as "0.1%" or "4.25% APY" in an account name
+var nameRate = regexp.MustCompile(`(\d{1,2}(?:\.\d+)?)\s*%`)
+
+// FromName reads the APY an institution gives in an account's official
+// name, such as "Silver Standard 0.1% Interest Savings", or returns nil
+func FromName(name *string) *decimal.Decimal {
+	if name == nil {
+		return nil
+	}
+	m := nameRate.FindStringSubmatch(*name)
+	if m == nil {
+		return nil
+	}
+	apy, err := decimal.NewFromString(m[1])
+	if err != nil {
+		return nil
+	}
+	return &apy
+}
+
+// Compare estimates what each of a user's counted depository accounts
+// with a positive balance earns in a year, and what it would at the
+// benchmark APY, those missing the most first
+func Compare(accounts []models.Account, benchmarkAPY decimal.Decimal) models.YieldReport {
+	report := models.YieldReport{
+		BenchmarkAPY:      benchmarkAPY,
+		AnnualInterest:    decimal.Zero,
+		BenchmarkInterest: decimal.Zero,
+		Shortfall:         decimal.Zero,
+		Accounts:          []models.YieldComparison{},
+	}
+	for _, acc := range accounts {
+		if acc.Type != "depository" || !acc.Counted() || acc.BalanceCurrent == nil || !acc.BalanceCurrent.IsPositive() {
+			continue
+		}
+		balance := *acc.BalanceCurrent
+		c := models.YieldComparison{
+			AccountID:         acc.ID,
+			AccountName:       acc.DisplayName(),
+			Subtype:           acc.Subtype,
+			Balance:           balance,
+			APY:               acc.APY,
+			APYSource:         acc.APYSource,
+			BenchmarkInterest: balance.Mul(benchmarkAPY).Div(hundred).Round(2),
+		}
+		if acc.APY == nil {
+			report.WithoutAPY++
+			report.Accounts = append(report.Accounts, c)
+			continue
+		}
+
+		interest := balance.Mul(*acc.APY).Div(hundred).Round(2)
+		shortfall := decimal.Max(c.BenchmarkInterest.Sub(interest), decimal.Zero)
+		c.AnnualInterest, c.Shortfall = &interest, &shortfall
+		report.AnnualInterest = report.AnnualInterest.Add(interest)
+		report.BenchmarkInterest = report.BenchmarkInterest.Add(c.BenchmarkInterest)
+		report.Shortfall = report.Shortfall.Add(shortfall)
+		report.Accounts = append(report.Accounts, c)
+	}
+	sort.SliceStable(report.Accounts, func(i, j int) bool {
+		a, b := report.Accounts[i], report.Accounts[j]
+		if (a.Shortfall == nil) != (b.Shortfall == nil) {
+			return b.Shortfall == nil
+		}
+		if a.Shortfall != nil && !a.Shortfall.Equal(*b.Shortfall) {
+			return a.Shortfall.GreaterThan(*b.Shortfall)
+		}
+		return a.Balance.GreaterThan(b.Balance)
+	})
+	return report
+}
+
+// Service compares accounts with the benchmark and keeps their insights
+type Service struct {
+	db        *database.Database
+	insights  *insights.Store
+	benchmark decimal.Decimal
+}
+
+// NewService creates a yield service comparing accounts with a
+// high-yield savings account paying benchmarkAPY percent
+func NewService(db *database.Database, store *insights.Store, benchmarkAPY float64) *Service {
+	if benchmarkAPY <= 0 {
+		benchmarkAPY = DefaultBenchmarkAPY
+	}
+	return &Service{db: db, insights: store, benchmark: decimal.NewFromFloat(benchmarkAPY)}
+}
+
+// BenchmarkAPY is the configured high-yield savings rate, in percent
+func (s *Service) BenchmarkAPY() decimal.Decimal {
+	return s.benchmark
+}
+
+// Evaluate raises an insight for each of a user's accounts missing at
+// least minShortfall a year against the benchmark, unless one is open
+// for it already, and resolves those of accounts that no longer miss it.
+// It returns how many insights were raised.
+func (s *Service) Evaluate(ctx context.Context, userID string, accounts []models.Account) (int, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, COALESCE(metadata->>'account_id', '')
+		FROM insights
+		WHERE user_id = $1 AND type = $2 AND resolved_at IS NULL
+	`, userID, InsightType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query yield insights: %w", err)
+	}
+	open := make(map[string]string)
+	for rows.Next() {
+		var id, accountID string
+		if err := rows.Scan(&id, &accountID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan yield insight: %w", err)
+		}
+		open[accountID] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	currencies := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		currencies[acc.ID] = acc.Currency
+	}
+
+	report := Compare(accounts, s.benchmark)
+	raised := 0
+	for _, c := range report.Accounts {
+		if c.Shortfall == nil || c.Shortfall.LessThan(minShortfall) {
+			continue
+		}
+		if _, ok := open[c.AccountID]; ok {
+			delete(open, c.AccountID)
+			continue
+		}
+		if err := s.insights.Create(ctx, insight(userID, currencies[c.AccountID], report.BenchmarkAPY, c)); err != nil {
+			return raised, err
+		}
+		raised++
+	}
+
+	for _, id := range open {
+		if err := s.insights.Resolve(ctx, id); err != nil {
+			return raised, err
+		}
+	}
+	return raised, nil
+}
+
+// insight words what an account leaves on the table
+func insight(userID, currency string, benchmarkAPY decimal.Decimal, c models.YieldComparison) *models.Insight {
+	money := func(amount decimal.Decimal) string {
+		return digest.FormatAmount(amount, currency, "")
+	}
+	return &models.Insight{
+		UserID:   userID,
+		Type:     InsightType,
+		Severity: insights.SeverityInfo,
+		Title:    fmt.Sprintf("%s could earn %s more a year", c.AccountName, money(*c.Shortfall)),
+		Message: fmt.Sprintf("%s earns %s%% APY, about %s a year on %s. "+
+			"A high-yield savings account at %s%% APY would earn about %s.",
+			c.AccountName, c.APY.StringFixed(2), money(*c.AnnualInterest), money(c.Balance),
+			benchmarkAPY.StringFixed(2), money(c.BenchmarkInterest)),
+		Metadata: map[string]interface{}{
+			"account_id":         c.AccountID,
+			"balance":            c.Balance,
+			"apy":                c.APY,
+			"apy_source":         c.APYSource,
+			"benchmark_apy":      benchmarkAPY,
+			"annual_interest":    c.AnnualInterest,
+			"benchmark_interest": c.BenchmarkInterest,
+			"shortfall":          c.Shortfall,
+		},
+	}
+}